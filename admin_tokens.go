@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/tokens"
+)
+
+// tokenRequest is the body of POST /admin/tokens and PATCH
+// /admin/tokens/{id}; fields left out of a PATCH are kept
+type tokenRequest struct {
+	Label     *string       `json:"label"`
+	Tenant    *string       `json:"tenant"`
+	Scopes    *[]string     `json:"scopes"`
+	RateLimit *int          `json:"rate_limit"`
+	Quotas    *quota.Limits `json:"quotas"`
+	ExpiresAt *time.Time    `json:"expires_at"`
+}
+
+// tokenResponse is a managed token as returned by the admin API. Secret is
+// only set when the token is created.
+type tokenResponse struct {
+	tokens.Token
+	Hash   string `json:"hash,omitempty"` // Shadows Token.Hash, so it is never returned
+	Secret string `json:"secret,omitempty"`
+}
+
+// decodeTokenRequest reads and checks a token request body
+func decodeTokenRequest(r *http.Request) (tokenRequest, error) {
+	var req tokenRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 64<<10))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		return req, fmt.Errorf("invalid request body: %v", err)
+	}
+	if req.Tenant != nil && *req.Tenant != "" {
+		if err := storage.ValidateTenant(*req.Tenant); err != nil {
+			return req, err
+		}
+	}
+	if req.RateLimit != nil && *req.RateLimit < 0 {
+		return req, errors.New("rate_limit must not be negative")
+	}
+	if req.Quotas != nil {
+		if err := req.Quotas.Validate(); err != nil {
+			return req, fmt.Errorf("quotas: %w", err)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return req, errors.New("expires_at must be in the future")
+	}
+	return req, nil
+}
+
+// writeTokenJSON writes v as the JSON response of an admin request
+func writeTokenJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeTokenError writes an admin API error
+func writeTokenError(w http.ResponseWriter, status int, err error) {
+	writeTokenJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeTokenStoreError maps a token store error to its HTTP status
+func writeTokenStoreError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, tokens.ErrNotFound):
+		writeTokenError(w, http.StatusNotFound, err)
+	case errors.Is(err, tokens.ErrRevoked):
+		writeTokenError(w, http.StatusConflict, err)
+	default:
+		log.Printf("Token store error: %v", err)
+		writeTokenError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// handleListTokens lists all managed tokens, revoked ones included
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	list := s.serviceTokens.List()
+	response := make([]tokenResponse, len(list))
+	for i, t := range list {
+		response[i] = tokenResponse{Token: t}
+	}
+	writeTokenJSON(w, http.StatusOK, map[string]any{"tokens": response})
+}
+
+// handleCreateToken creates a token and returns its secret, which is not
+// shown again
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeTokenRequest(r)
+	if err != nil {
+		writeTokenError(w, http.StatusBadRequest, err)
+		return
+	}
+	var t tokens.Token
+	if req.Label != nil {
+		t.Label = *req.Label
+	}
+	if req.Tenant != nil {
+		t.Tenant = *req.Tenant
+	}
+	if req.Scopes != nil {
+		t.Scopes = *req.Scopes
+	}
+	if req.RateLimit != nil {
+		t.RateLimit = *req.RateLimit
+	}
+	if req.Quotas != nil {
+		t.Quotas = *req.Quotas
+	}
+	t.ExpiresAt = req.ExpiresAt
+
+	created, secret, err := s.serviceTokens.Create(t)
+	if err != nil {
+		writeTokenStoreError(w, err)
+		return
+	}
+	log.Printf("Admin API: created token %s (%q)", created.ID, created.Label)
+	writeTokenJSON(w, http.StatusCreated, tokenResponse{Token: created, Secret: secret})
+}
+
+// handleUpdateToken changes the label, tenant, scopes, rate limit, quotas or
+// expiry of a token
+func (s *Server) handleUpdateToken(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeTokenRequest(r)
+	if err != nil {
+		writeTokenError(w, http.StatusBadRequest, err)
+		return
+	}
+	updated, err := s.serviceTokens.Update(r.PathValue("id"), tokens.Update{
+		Label:     req.Label,
+		Tenant:    req.Tenant,
+		Scopes:    req.Scopes,
+		RateLimit: req.RateLimit,
+		Quotas:    req.Quotas,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		writeTokenStoreError(w, err)
+		return
+	}
+	log.Printf("Admin API: updated token %s", updated.ID)
+	writeTokenJSON(w, http.StatusOK, tokenResponse{Token: updated})
+}
+
+// handleRevokeToken revokes a token; requests using it are rejected at once
+func (s *Server) handleRevokeToken(w http.ResponseWriter, r *http.Request) {
+	revoked, err := s.serviceTokens.Revoke(r.PathValue("id"))
+	if err != nil {
+		writeTokenStoreError(w, err)
+		return
+	}
+	log.Printf("Admin API: revoked token %s", revoked.ID)
+	writeTokenJSON(w, http.StatusOK, tokenResponse{Token: revoked})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Limits for editing every frame of an animated input
+const (
+	defaultAnimationFrames = 12
+	maxAnimationFrames     = 48
+)
+
+// stillInput returns a path to the first frame of an animated GIF or WebP
+// input, chaining the removal of the copy onto cleanup, so that tools which
+// expect a still image can work with animations. Still GIFs, which models do
+// not accept, are converted to PNG the same way. Other inputs are returned
+// as-is.
+func (s *Server) stillInput(ctx context.Context, localPath string, cleanup func()) (string, func(), error) {
+	if !isAnimationFile(localPath) {
+		return localPath, cleanup, nil
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil || !(imaging.IsAnimated(data) || imaging.DetectMIME(data) == "image/gif") {
+		return localPath, cleanup, nil
+	}
+
+	frame, mimeType, err := imaging.RepresentativeFrame(data)
+	if err != nil {
+		warnings.Add(ctx, "failed to extract a frame from %s: %v", localPath, err)
+		return localPath, cleanup, nil
+	}
+	warnings.Add(ctx, "%s is animated; only its first frame was used", filepath.Base(localPath))
+
+	pattern := "gemini-mcp-frame-*.png"
+	if mimeType == "image/webp" {
+		pattern = "gemini-mcp-frame-*.webp"
+	}
+	return s.replaceInput(localPath, frame, pattern, cleanup)
+}
+
+// isAnimationFile reports whether the file at path is a GIF or WebP, the
+// formats that can hold animations
+func isAnimationFile(path string) bool {
+	header := readHeader(path, 12)
+	if header == nil {
+		return false
+	}
+	return string(header[:4]) == "GIF8" || (string(header[:4]) == "RIFF" && string(header[8:12]) == "WEBP")
+}
+
+// loadAnimation decodes the input at inputPath if it is an animated GIF or
+// WebP, returning nil for still images
+func (s *Server) loadAnimation(ctx context.Context, inputPath string) (*imaging.Animation, error) {
+	localPath, cleanup, err := s.locateInputPath(ctx, inputPath)
+	if err != nil {
+		return nil, err
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if !isAnimationFile(localPath) {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, err
+	}
+	if !imaging.IsAnimated(data) {
+		return nil, nil
+	}
+	return imaging.DecodeAnimation(data)
+}
+
+// editAnimation applies an edit to each frame of an animation and reassembles
+// the edited frames into an animated GIF
+func (s *Server) editAnimation(ctx context.Context, anim *imaging.Animation, input GeminiImageEditInput, model, editType, promptText, outputDir string) (*mcp.CallToolResult, GeminiImageEditOutput, error) {
+	maxFrames := input.MaxFrames
+	if maxFrames == 0 {
+		maxFrames = defaultAnimationFrames
+	}
+	sourceFrames := len(anim.Frames)
+	anim = anim.Sample(maxFrames)
+	log.Printf("Editing %d of %d frames of animated %s", len(anim.Frames), sourceFrames, input.InputImagePath)
+	if len(anim.Frames) < sourceFrames {
+		warnings.Add(ctx, "the animation has %d frames; %d evenly sampled frames were edited (max_frames)", sourceFrames, len(anim.Frames))
+	}
+
+	edited := make([]image.Image, len(anim.Frames))
+	var safetyFeedback *safety.Feedback
+	for i, frame := range anim.Frames {
+		frameData, mimeType := frame.Data, "image/webp"
+		if frame.Image != nil {
+			var err error
+			if frameData, err = imaging.EncodePNG(frame.Image); err != nil {
+				return nil, GeminiImageEditOutput{}, err
+			}
+			mimeType = "image/png"
+		}
+
+		prompt := fmt.Sprintf("%s. This is frame %d of %d of an animation: apply exactly the same edit to every frame so that they stay consistent, and keep the framing and composition of the frame unchanged", promptText, i+1, len(anim.Frames))
+		img, feedback, err := s.editFrame(ctx, model, prompt, frameData, mimeType)
+		if err != nil {
+			return nil, GeminiImageEditOutput{}, fmt.Errorf("error editing frame %d: %w", i+1, err)
+		}
+		if img == nil {
+			if feedback != nil && feedback.Blocked {
+				return safetyBlockedResult(ctx, feedback), GeminiImageEditOutput{Model: model, Safety: feedback, GeneratedAt: time.Now().Format("20060102_150405")}, nil
+			}
+			return nil, GeminiImageEditOutput{}, toolerr.Errorf(toolerr.Upstream, "no edited content was generated for frame %d", i+1)
+		}
+		edited[i] = img
+		if feedback != nil {
+			safetyFeedback = feedback
+		}
+	}
+
+	gifData, err := anim.Reassemble(edited)
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, err
+	}
+
+	result, err := s.storage.Store(ctx, gifData, "image/gif", "gemini_edit")
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to store edited animation: %w", err)
+	}
+	copyToOutputDirectory(outputDir, result.ObjectKey, gifData)
+	log.Printf("Stored edited animation: %s", result.Location)
+
+	metadata := editMetadata(input, editType)
+	metadata["animation"] = "all_frames"
+	metadata["frames"] = fmt.Sprintf("%d", len(anim.Frames))
+	metadata["source_frames"] = fmt.Sprintf("%d", sourceFrames)
+
+	dataURIs := s.addDataURI(nil, result, gifData)
+	output := GeminiImageEditOutput{
+		GenerationResult: s.generationResult("completed", []*storage.StorageResult{result}, dataURIs, metadata),
+
+		OriginalImage: input.InputImagePath,
+		EditedImage:   result.Location,
+		EditType:      editType,
+		AspectRatio:   input.AspectRatio,
+		Model:         model,
+		SavedFiles:    []string{result.ObjectKey},
+		DataURIs:      dataURIs,
+		Thumbnails:    addThumbnail(nil, result),
+		Safety:        safetyFeedback,
+		GeneratedAt:   time.Now().Format("20060102_150405"),
+	}
+
+	if s.storage.IsRemote() {
+		output.DownloadURLs = []string{result.Location}
+		contentText := fmt.Sprintf("Edited %d frames of the animation. Download URL:\n1. %s\n", len(anim.Frames), result.Location)
+		if result.ExpiresAt != nil {
+			output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			contentText += fmt.Sprintf("\nURLs expire at: %s", output.ExpiresAt)
+		}
+		return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: contentText}}}, output, nil
+	}
+	return &mcp.CallToolResult{Content: s.mediaContent(gifData, result)}, output, nil
+}
+
+// editFrame sends one frame to the model and decodes the edited image. A nil
+// image is returned, with any safety feedback, when the model produced none.
+func (s *Server) editFrame(ctx context.Context, model, prompt string, data []byte, mimeType string) (image.Image, *safety.Feedback, error) {
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			genai.NewPartFromText(prompt),
+			{InlineData: &genai.Blob{MIMEType: mimeType, Data: data}},
+		}, genai.RoleUser),
+	}
+
+	// Wait for a free image generation slot
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	usage.Add(ctx, response)
+
+	feedback := safety.FromContentResponse(response)
+	if response == nil {
+		return nil, feedback, nil
+	}
+	for _, candidate := range response.Candidates {
+		if candidate.Content == nil {
+			continue
+		}
+		for _, part := range candidate.Content.Parts {
+			if part.InlineData != nil && len(part.InlineData.Data) > 0 {
+				img, _, err := imaging.Decode(part.InlineData.Data)
+				if err != nil {
+					return nil, nil, err
+				}
+				return img, feedback, nil
+			}
+		}
+	}
+	return nil, feedback, nil
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/bench"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Latency benchmark
+type BenchmarkInput struct {
+	Models      []string `json:"models,omitempty" jsonschema:"description:Models to benchmark. Text models answer a one-word prompt; image models (names containing 'image') generate one 1K image per request. Defaults to gemini-2.5-flash."`
+	Iterations  int      `json:"iterations,omitempty" jsonschema:"description:Requests per model and storage operation (1-50),default:5"`
+	Concurrency int      `json:"concurrency,omitempty" jsonschema:"description:Requests in flight at once (1-8),default:1"`
+	SkipStorage bool     `json:"skip_storage,omitempty" jsonschema:"description:Skip the storage store/retrieve/delete round trips"`
+	PayloadKB   int      `json:"payload_kb,omitempty" jsonschema:"description:Size of the random object used for storage operations in KiB (1-10240),default:256"`
+}
+
+// BenchmarkResult is the latency summary of one target and operation
+type BenchmarkResult struct {
+	Target     string  `json:"target"`
+	Operation  string  `json:"operation"`
+	Requests   int     `json:"requests"`
+	Errors     int     `json:"errors"`
+	MinMs      float64 `json:"min_ms"`
+	MeanMs     float64 `json:"mean_ms"`
+	P50Ms      float64 `json:"p50_ms"`
+	P95Ms      float64 `json:"p95_ms"`
+	MaxMs      float64 `json:"max_ms"`
+	Throughput float64 `json:"throughput_per_sec"`
+	LastError  string  `json:"last_error,omitempty"`
+}
+
+type BenchmarkOutput struct {
+	Results     []BenchmarkResult `json:"results"`
+	Iterations  int               `json:"iterations"`
+	Concurrency int               `json:"concurrency"`
+	Storage     string            `json:"storage,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	GeneratedAt string            `json:"generated_at"`
+}
+
+func (s *Server) handleBenchmark(ctx context.Context, req *mcp.CallToolRequest, input BenchmarkInput) (*mcp.CallToolResult, BenchmarkOutput, error) {
+	output, err := s.runBenchmark(ctx, input)
+	if err != nil {
+		return nil, BenchmarkOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: formatBenchmark(output),
+			},
+		},
+	}, output, nil
+}
+
+// runBenchmark runs the standardized workload shared by the benchmark tool
+// and the -benchmark command line mode
+func (s *Server) runBenchmark(ctx context.Context, input BenchmarkInput) (BenchmarkOutput, error) {
+	models := input.Models
+	if len(models) == 0 {
+		models = []string{"gemini-2.5-flash"}
+	}
+	for _, model := range models {
+		if err := s.allowlist.Check("benchmark", model); err != nil {
+			return BenchmarkOutput{}, err
+		}
+	}
+
+	iterations := input.Iterations
+	if iterations == 0 {
+		iterations = 5
+	}
+	if iterations < 1 || iterations > 50 {
+		return BenchmarkOutput{}, toolerr.Errorf(toolerr.InvalidInput, "iterations must be between 1 and 50")
+	}
+
+	concurrency := input.Concurrency
+	if concurrency == 0 {
+		concurrency = 1
+	}
+	if concurrency < 1 || concurrency > 8 {
+		return BenchmarkOutput{}, toolerr.Errorf(toolerr.InvalidInput, "concurrency must be between 1 and 8")
+	}
+
+	payloadKB := input.PayloadKB
+	if payloadKB == 0 {
+		payloadKB = 256
+	}
+	if payloadKB < 1 || payloadKB > 10240 {
+		return BenchmarkOutput{}, toolerr.Errorf(toolerr.InvalidInput, "payload_kb must be between 1 and 10240")
+	}
+
+	log.Printf("Running benchmark: models %v, %d iterations, concurrency %d", models, iterations, concurrency)
+
+	var results []BenchmarkResult
+	for _, model := range models {
+		op := "generate_text"
+		if strings.Contains(model, "image") {
+			op = "generate_image"
+		}
+		results = append(results, runTimed(ctx, model, op, iterations, concurrency, func(ctx context.Context) error {
+			return s.benchmarkModel(ctx, model)
+		}))
+	}
+
+	storageName := ""
+	if !input.SkipStorage {
+		storageName = "local"
+		if s.config.S3Enabled {
+			storageName = "s3"
+		}
+		results = append(results, s.benchmarkStorage(ctx, storageName, iterations, concurrency, payloadKB*1024)...)
+	}
+
+	return BenchmarkOutput{
+		Results:     results,
+		Iterations:  iterations,
+		Concurrency: concurrency,
+		Storage:     storageName,
+		Metadata: map[string]string{
+			"payload_kb": fmt.Sprintf("%d", payloadKB),
+		},
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}, nil
+}
+
+// benchmarkModel sends one minimal request to model. Benchmark requests bypass
+// the generation queue so that queueing does not skew the measured latency.
+func (s *Server) benchmarkModel(ctx context.Context, model string) error {
+	var contents []*genai.Content
+	var config *genai.GenerateContentConfig
+	if strings.Contains(model, "image") {
+		contents = genai.Text("A plain red circle on a white background")
+		config = &genai.GenerateContentConfig{
+			ResponseModalities: []string{"IMAGE"},
+			ImageConfig:        &genai.ImageConfig{AspectRatio: "1:1", ImageSize: "1K"},
+		}
+	} else {
+		contents = genai.Text("Reply with the single word OK.")
+		config = &genai.GenerateContentConfig{MaxOutputTokens: 8}
+	}
+
+	_, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	return err
+}
+
+// benchmarkStorage times store, retrieve and delete round trips of a random
+// payload against the configured storage backend
+func (s *Server) benchmarkStorage(ctx context.Context, target string, iterations, concurrency, size int) []BenchmarkResult {
+	payload := make([]byte, size)
+	rand.Read(payload)
+
+	var mu sync.Mutex
+	var keys []string
+	var seq uint64
+	store := runTimed(ctx, target, "store", iterations, concurrency, func(ctx context.Context) error {
+		// Storage keys are content-addressed, so give every object a unique header
+		data := append([]byte(nil), payload...)
+		mu.Lock()
+		seq++
+		binary.LittleEndian.PutUint64(data, seq)
+		mu.Unlock()
+
+		result, err := s.storage.Store(ctx, data, "application/octet-stream", "benchmark")
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, result.ObjectKey)
+		mu.Unlock()
+		return nil
+	})
+
+	next := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(keys) == 0 {
+			return ""
+		}
+		key := keys[0]
+		keys = append(keys[1:], key)
+		return key
+	}
+	retrieve := runTimed(ctx, target, "retrieve", iterations, concurrency, func(ctx context.Context) error {
+		key := next()
+		if key == "" {
+			return fmt.Errorf("no stored object to retrieve")
+		}
+		_, cleanup, err := s.storage.Retrieve(ctx, key)
+		if err != nil {
+			return err
+		}
+		if cleanup != nil {
+			cleanup()
+		}
+		return nil
+	})
+
+	// Delete every stored object exactly once, even if iterations differ
+	toDelete := append([]string(nil), keys...)
+	var deleteIdx int
+	del := runTimed(ctx, target, "delete", len(toDelete), concurrency, func(ctx context.Context) error {
+		mu.Lock()
+		key := toDelete[deleteIdx]
+		deleteIdx++
+		mu.Unlock()
+		return s.storage.Delete(ctx, key)
+	})
+
+	return []BenchmarkResult{store, retrieve, del}
+}
+
+// runTimed calls fn iterations times with up to concurrency calls in flight
+// and summarises their latencies
+func runTimed(ctx context.Context, target, operation string, iterations, concurrency int, fn func(context.Context) error) BenchmarkResult {
+	var (
+		mu        sync.Mutex
+		latencies []time.Duration
+		errors    int
+		lastErr   error
+		wg        sync.WaitGroup
+	)
+
+	jobs := make(chan struct{}, iterations)
+	for i := 0; i < iterations; i++ {
+		jobs <- struct{}{}
+	}
+	close(jobs)
+
+	start := time.Now()
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				opStart := time.Now()
+				err := fn(ctx)
+				elapsed := time.Since(opStart)
+
+				mu.Lock()
+				if err != nil {
+					errors++
+					lastErr = err
+				} else {
+					latencies = append(latencies, elapsed)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	summary := bench.Summarize(latencies, errors, time.Since(start))
+	if lastErr != nil {
+		log.Printf("Benchmark %s %s: %d/%d requests failed, last error: %v", target, operation, errors, iterations, lastErr)
+	}
+
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	result := BenchmarkResult{
+		Target:     target,
+		Operation:  operation,
+		Requests:   iterations,
+		Errors:     summary.Errors,
+		MinMs:      ms(summary.Min),
+		MeanMs:     ms(summary.Mean),
+		P50Ms:      ms(summary.P50),
+		P95Ms:      ms(summary.P95),
+		MaxMs:      ms(summary.Max),
+		Throughput: summary.Throughput,
+	}
+	if lastErr != nil {
+		result.LastError = lastErr.Error()
+	}
+	return result
+}
+
+// formatBenchmark renders benchmark results as a plain-text table
+func formatBenchmark(output BenchmarkOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Benchmark (%d iterations, concurrency %d)\n\n", output.Iterations, output.Concurrency)
+	fmt.Fprintf(&b, "%-28s %-15s %6s %10s %10s %10s %8s\n", "TARGET", "OPERATION", "ERRORS", "P50 (ms)", "P95 (ms)", "MEAN (ms)", "OPS/S")
+	for _, r := range output.Results {
+		fmt.Fprintf(&b, "%-28s %-15s %6d %10.1f %10.1f %10.1f %8.2f\n", r.Target, r.Operation, r.Errors, r.P50Ms, r.P95Ms, r.MeanMs, r.Throughput)
+	}
+	for _, r := range output.Results {
+		if r.LastError != "" {
+			fmt.Fprintf(&b, "\n%s %s: %s", r.Target, r.Operation, r.LastError)
+		}
+	}
+	return b.String()
+}
+
+// runBenchmarkCLI runs the benchmark from the command line and prints the results
+func runBenchmarkCLI(ctx context.Context, s *Server, input BenchmarkInput) error {
+	output, err := s.runBenchmark(ctx, input)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stdout, formatBenchmark(output))
+	return nil
+}
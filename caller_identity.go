@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"gemini-mcp/internal/middleware"
+)
+
+// callerIdentity identifies the caller of a request without exposing its
+// credentials: the JWT subject, which for managed tokens is token:<id>, or a
+// hash of the service token. It returns "" for callers without credentials,
+// such as stdio clients.
+func callerIdentity(ctx context.Context) string {
+	if claims := middleware.GetClaims(ctx); claims != nil && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	if token := middleware.GetAuthToken(ctx); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	return ""
+}
+
+// callerLabel is the callerIdentity of a request with its tenant, or "local"
+// for callers without credentials. It labels callers in safety reports and
+// records who started work whose prompts may be redacted.
+func callerLabel(ctx context.Context) string {
+	label := callerIdentity(ctx)
+	if label == "" {
+		label = "local"
+	}
+	if tenant := middleware.GetTenant(ctx); tenant != "" {
+		label += " (tenant " + tenant + ")"
+	}
+	return label
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gemini-mcp/internal/media"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Server capabilities
+type ServerCapabilitiesInput struct{}
+
+type ServerCapabilitiesOutput struct {
+	Version        string             `json:"version"`
+	Transport      string             `json:"transport"`
+	Storage        string             `json:"storage"`                   // "s3" or "local"
+	StorageTargets []string           `json:"storage_targets,omitempty"` // Targets storage_target can name, the default first
+	Thumbnails     bool               `json:"thumbnails"`
+	Media          media.Capabilities `json:"media"`
+}
+
+func (s *Server) handleServerCapabilities(ctx context.Context, req *mcp.CallToolRequest, input ServerCapabilitiesInput) (*mcp.CallToolResult, ServerCapabilitiesOutput, error) {
+	output := ServerCapabilitiesOutput{
+		Version:    version,
+		Transport:  s.config.Transport,
+		Storage:    "local",
+		Thumbnails: s.config.StoreThumbnails,
+		Media:      s.media.Capabilities(),
+	}
+	if s.config.S3Enabled {
+		output.Storage = "s3"
+	}
+	if s.targets != nil {
+		output.StorageTargets = s.targets.Names()
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s %s (transport: %s, storage: %s)\n", serviceName, output.Version, output.Transport, output.Storage)
+	if len(output.StorageTargets) > 0 {
+		fmt.Fprintf(&text, "storage targets: %s\n", strings.Join(output.StorageTargets, ", "))
+	}
+	if output.Media.FFmpeg {
+		fmt.Fprintf(&text, "ffmpeg: %s\n", output.Media.FFmpegPath)
+	} else {
+		text.WriteString("ffmpeg: not installed, pure-Go fallbacks in use\n")
+	}
+	ops := make([]string, 0, len(output.Media.Operations))
+	for op := range output.Media.Operations {
+		ops = append(ops, op)
+	}
+	slices.Sort(ops)
+	for _, op := range ops {
+		fmt.Fprintf(&text, "- %s: %s\n", op, output.Media.Operations[op])
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.TrimSuffix(text.String(), "\n")}},
+	}, output, nil
+}
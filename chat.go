@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/chat"
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+const (
+	// maxChatSessions caps the sessions kept at once; the least recently used is evicted
+	maxChatSessions = 256
+	// maxChatHistory bounds the contents replayed to the model on every turn
+	maxChatHistory = 40
+	// maxChatAttachmentBytes bounds the inline attachments of a single turn
+	maxChatAttachmentBytes = 20 << 20
+)
+
+// Chat sessions
+type GeminiChatInput struct {
+	SessionID         string   `json:"session_id,omitempty" jsonschema:"description:Session ID returned by an earlier gemini_chat call. Omit to start a new conversation."`
+	Message           string   `json:"message" jsonschema:"description:The user's message for this turn, e.g. 'make the sky darker' to refine an image from an earlier turn"`
+	Attachments       []string `json:"attachments,omitempty" jsonschema:"description:Files to include with this turn: images, audio, short videos or PDFs. Each can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media. Attachments stay in the conversation history."`
+	Model             string   `json:"model,omitempty" jsonschema:"description:Model for a new session; existing sessions keep the model they were started with. Use an image model such as 'gemini-3-pro-image-preview' to generate and iteratively refine images.,default:gemini-2.5-flash"`
+	SystemInstruction string   `json:"system_instruction,omitempty" jsonschema:"description:Optional system instruction for a new session"`
+	AspectRatio       string   `json:"aspect_ratio,omitempty" jsonschema:"description:Aspect ratio of images generated in this turn (image models only): '1:1', '3:4', '4:3', '9:16', '16:9'"`
+	EndSession        bool     `json:"end_session,omitempty" jsonschema:"description:Delete the session after this turn. With an empty message the session is deleted without another turn.,default:false"`
+	LinkTTL           string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget     string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type GeminiChatOutput struct {
+	SessionID        string            `json:"session_id"`
+	Model            string            `json:"model"`
+	Text             string            `json:"text,omitempty"`
+	Turn             int               `json:"turn"`
+	SavedFiles       []string          `json:"saved_files,omitempty"`
+	DataURIs         map[string]string `json:"data_uris,omitempty"`
+	Thumbnails       map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs     []string          `json:"download_urls,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	SessionExpiresAt string            `json:"session_expires_at,omitempty"`
+	SessionEnded     bool              `json:"session_ended,omitempty"`
+	Safety           *safety.Feedback  `json:"safety,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
+	Tokens           *usage.Tokens     `json:"tokens,omitempty"` // Gemini tokens used by this turn
+	GeneratedAt      string            `json:"generated_at"`
+}
+
+func (s *Server) handleGeminiChat(ctx context.Context, req *mcp.CallToolRequest, input GeminiChatInput) (*mcp.CallToolResult, GeminiChatOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	ctx, used := usage.WithCollector(ctx)
+	if input.EndSession && input.Message == "" && len(input.Attachments) == 0 {
+		if input.SessionID == "" {
+			return nil, GeminiChatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "session_id is required to end a session")
+		}
+		if !s.chats.Delete(input.SessionID) {
+			return nil, GeminiChatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "chat session %q not found", input.SessionID)
+		}
+		log.Printf("Ended chat session %s", input.SessionID)
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Ended chat session %s", input.SessionID)}},
+		}, GeminiChatOutput{SessionID: input.SessionID, SessionEnded: true, GeneratedAt: time.Now().Format("20060102_150405")}, nil
+	}
+	if strings.TrimSpace(input.Message) == "" {
+		return nil, GeminiChatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "message is required")
+	}
+
+	// Load attachments before touching the session so a bad path costs nothing
+	parts := []*genai.Part{genai.NewPartFromText(input.Message)}
+	attachmentBytes := 0
+	for _, path := range input.Attachments {
+		part, size, err := s.loadChatAttachment(ctx, path)
+		if err != nil {
+			return nil, GeminiChatOutput{}, fmt.Errorf("attachment %s: %w", path, err)
+		}
+		if attachmentBytes += size; attachmentBytes > maxChatAttachmentBytes {
+			return nil, GeminiChatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "attachments exceed %d MB per turn", maxChatAttachmentBytes>>20)
+		}
+		parts = append(parts, part)
+	}
+
+	var sess *chat.Session
+	var err error
+	if input.SessionID == "" {
+		model := input.Model
+		if model == "" {
+			model = s.config.TextDefaultModel
+		}
+		if err := s.allowlist.Check("gemini_chat", model); err != nil {
+			return nil, GeminiChatOutput{}, err
+		}
+		if sess, err = s.chats.Create(model, input.SystemInstruction); err != nil {
+			return nil, GeminiChatOutput{}, err
+		}
+		log.Printf("Started chat session %s (model: %s)", sess.ID, model)
+	} else if sess, err = s.chats.Get(input.SessionID); err != nil {
+		return nil, GeminiChatOutput{}, err
+	}
+
+	sess.Lock()
+	defer sess.Unlock()
+
+	isImageModel := strings.Contains(sess.Model, "image")
+	config := &genai.GenerateContentConfig{}
+	if sess.SystemInstruction != "" {
+		config.SystemInstruction = genai.NewContentFromText(sess.SystemInstruction, genai.RoleUser)
+	}
+	if isImageModel {
+		config.ResponseModalities = []string{"IMAGE", "TEXT"}
+		if input.AspectRatio != "" {
+			config.ImageConfig = &genai.ImageConfig{AspectRatio: input.AspectRatio}
+		}
+
+		release, err := s.acquireGeneration(ctx, quota.Images)
+		if err != nil {
+			return nil, GeminiChatOutput{}, err
+		}
+		defer release()
+	}
+
+	userTurn := genai.NewContentFromParts(parts, genai.RoleUser)
+	contents := append(append([]*genai.Content(nil), sess.History...), userTurn)
+
+	log.Printf("Chat session %s turn %d with model %s (%d attachments, %d history contents)", sess.ID, sess.Turns+1, sess.Model, len(input.Attachments), len(sess.History))
+
+	response, err := s.client.Models.GenerateContent(ctx, sess.Model, contents, config)
+	if err != nil {
+		return nil, GeminiChatOutput{}, fmt.Errorf("error generating chat reply: %w", err)
+	}
+	usage.Add(ctx, response)
+
+	output := GeminiChatOutput{
+		SessionID:   sess.ID,
+		Model:       sess.Model,
+		Turn:        sess.Turns,
+		Safety:      safety.FromContentResponse(response),
+		Tokens:      used.Tokens(),
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	if output.Safety != nil && output.Safety.Blocked {
+		// Blocked turns are not added to the history, so the user can rephrase
+		return safetyBlockedResult(ctx, output.Safety), output, nil
+	}
+	if len(response.Candidates) == 0 || response.Candidates[0].Content == nil {
+		return nil, GeminiChatOutput{}, toolerr.Errorf(toolerr.Upstream, "no reply was generated")
+	}
+	reply := response.Candidates[0].Content
+	reply.Role = genai.RoleModel
+
+	var texts []string
+	var media []mcp.Content
+	for _, part := range reply.Parts {
+		switch {
+		case part.Thought:
+			continue
+		case part.Text != "":
+			texts = append(texts, part.Text)
+		case part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "image/"):
+			result, err := s.storage.Store(ctx, part.InlineData.Data, part.InlineData.MIMEType, "gemini_chat")
+			if err != nil {
+				return nil, GeminiChatOutput{}, fmt.Errorf("failed to store image: %w", err)
+			}
+			log.Printf("Stored chat image: %s", result.Location)
+			output.SavedFiles = append(output.SavedFiles, result.ObjectKey)
+			output.DataURIs = s.addDataURI(output.DataURIs, result, part.InlineData.Data)
+			output.Thumbnails = addThumbnail(output.Thumbnails, result)
+			if s.storage.IsRemote() {
+				output.DownloadURLs = append(output.DownloadURLs, result.Location)
+				if result.ExpiresAt != nil && output.ExpiresAt == "" {
+					output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+				}
+			} else {
+				media = append(media, s.mediaContent(part.InlineData.Data, result)...)
+			}
+		}
+	}
+	output.Text = strings.Join(texts, "\n")
+
+	sess.Append(maxChatHistory, userTurn, reply)
+	sess.Turns++
+	output.Turn = sess.Turns
+
+	if input.EndSession {
+		s.chats.Delete(sess.ID)
+		output.SessionEnded = true
+	} else {
+		if err := s.chats.Save(sess); err != nil {
+			warnings.Add(ctx, "%v", err)
+		}
+		output.SessionExpiresAt = s.chats.ExpiresAt(sess).Format(time.RFC3339)
+	}
+
+	summary := output.Text
+	if summary == "" && len(output.SavedFiles) > 0 {
+		summary = fmt.Sprintf("Generated %d image(s)", len(output.SavedFiles))
+	}
+	for i, url := range output.DownloadURLs {
+		summary += fmt.Sprintf("\n%d. %s", i+1, url)
+	}
+	if output.SessionEnded {
+		summary += fmt.Sprintf("\n\n(Chat session %s ended)", sess.ID)
+	} else {
+		summary += fmt.Sprintf("\n\n(Chat session %s, turn %d - pass session_id to continue)", sess.ID, sess.Turns)
+	}
+	output.Warnings = collected.List()
+
+	return &mcp.CallToolResult{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, media...),
+	}, output, nil
+}
+
+// loadChatAttachment reads an attachment into an inline part, returning its size
+func (s *Server) loadChatAttachment(ctx context.Context, path string) (*genai.Part, int, error) {
+	localPath, cleanup, err := s.resolveInputPath(ctx, path)
+	if err != nil {
+		return nil, 0, err
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	mimeType := imaging.DetectMIME(data)
+	if mimeType == "audio/wave" {
+		mimeType = "audio/wav"
+	}
+	if videoType := videoMIMEFromPath(localPath); videoType != "" {
+		mimeType = videoType
+	}
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		if mimeType, err = imaging.DetectInputMIME(data); err != nil {
+			return nil, 0, err
+		}
+	case strings.HasPrefix(mimeType, "audio/"), strings.HasPrefix(mimeType, "video/"), mimeType == "application/pdf":
+	default:
+		return nil, 0, toolerr.Errorf(toolerr.InvalidInput, "unsupported attachment type %s (supported: images, audio, video, PDF)", mimeType)
+	}
+	return genai.NewPartFromBytes(data, mimeType), len(data), nil
+}
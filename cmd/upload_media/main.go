@@ -1,15 +1,17 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var (
@@ -18,6 +20,14 @@ var (
 	gitCommit = "unknown"
 )
 
+const (
+	// maxRetries is how often a failed chunk is retried from the offset the
+	// server reached
+	maxRetries = 5
+	// retryDelay is the wait before the first retry; it doubles every retry
+	retryDelay = time.Second
+)
+
 // UploadResult is the JSON response from the server
 type UploadResult struct {
 	ObjectKey   string `json:"object_key"`
@@ -27,17 +37,30 @@ type UploadResult struct {
 	Size        int64  `json:"size"`
 	Message     string `json:"message"`
 	UploadedAt  string `json:"uploaded_at"`
+	UploadID    string `json:"upload_id,omitempty"`
+}
+
+// UploadStatus is the JSON state of a resumable upload
+type UploadStatus struct {
+	UploadID string `json:"upload_id"`
+	Offset   int64  `json:"offset"`
+	Length   int64  `json:"length"`
+	Error    string `json:"error,omitempty"`
 }
 
 // ErrorResult is the JSON response for errors
 type ErrorResult struct {
-	Error string `json:"error"`
+	Error    string `json:"error"`
+	UploadID string `json:"upload_id,omitempty"` // Set when the upload can be resumed
+	Resume   string `json:"resume,omitempty"`    // Command that resumes the upload
 }
 
 func main() {
 	// Define flags
 	serverURL := flag.String("server", "", "Server upload URL (e.g., http://localhost:8080/upload)")
 	token := flag.String("token", "", "One-time authentication token")
+	resumeID := flag.String("resume", "", "Upload ID of an interrupted upload to continue")
+	chunkMiB := flag.Int("chunk-size", 8, "Size of each chunk sent in MiB")
 	showVersion := flag.Bool("version", false, "Show version information")
 	showHelp := flag.Bool("help", false, "Show help")
 
@@ -71,8 +94,12 @@ func main() {
 		outputError("--server is required")
 		os.Exit(1)
 	}
-	if *token == "" {
-		outputError("--token is required")
+	if *token == "" && *resumeID == "" {
+		outputError("--token is required (or --resume to continue an interrupted upload)")
+		os.Exit(1)
+	}
+	if *chunkMiB <= 0 {
+		outputError("--chunk-size must be positive")
 		os.Exit(1)
 	}
 
@@ -97,6 +124,10 @@ func main() {
 		outputError("Path is a directory, not a file: " + filePath)
 		os.Exit(1)
 	}
+	if info.Size() == 0 {
+		outputError("File is empty: " + filePath)
+		os.Exit(1)
+	}
 
 	// Open file
 	file, err := os.Open(filePath)
@@ -106,71 +137,170 @@ func main() {
 	}
 	defer file.Close()
 
-	// Create multipart form
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
+	u := &uploader{
+		client:    &http.Client{},
+		serverURL: strings.TrimRight(*serverURL, "/"),
+		file:      file,
+		size:      info.Size(),
+		chunkSize: int64(*chunkMiB) << 20,
+	}
 
-	// Add file to form
-	part, err := writer.CreateFormFile("file", filepath.Base(filePath))
+	// Start a new upload, or continue an interrupted one where it stopped
+	var status UploadStatus
+	if *resumeID != "" {
+		status, err = u.status(*resumeID)
+		if err == nil && status.Length != info.Size() {
+			err = fmt.Errorf("upload %s is %d bytes but %s is %d bytes; resume with the same file", *resumeID, status.Length, filePath, info.Size())
+		}
+	} else {
+		status, err = u.create(*token, filepath.Base(filePath))
+	}
 	if err != nil {
-		outputError(fmt.Sprintf("Failed to create form file: %v", err))
+		outputError(err.Error())
 		os.Exit(1)
 	}
 
-	if _, err := io.Copy(part, file); err != nil {
-		outputError(fmt.Sprintf("Failed to copy file data: %v", err))
+	result, err := u.send(status)
+	if err != nil {
+		outputJSON(os.Stderr, ErrorResult{
+			Error:    err.Error(),
+			UploadID: status.UploadID,
+			Resume:   fmt.Sprintf("%s --server %q --resume %q %s", os.Args[0], *serverURL, status.UploadID, filePath),
+		})
 		os.Exit(1)
 	}
 
-	writer.Close()
+	// Output server response (already JSON formatted)
+	fmt.Println(string(result))
+}
+
+// uploader sends a file to the server's resumable upload endpoint
+type uploader struct {
+	client    *http.Client
+	serverURL string
+	file      *os.File
+	size      int64
+	chunkSize int64
+}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", *serverURL, &body)
+// create starts an upload with the one-time token
+func (u *uploader) create(token, filename string) (UploadStatus, error) {
+	req, err := http.NewRequest(http.MethodPost, u.serverURL, nil)
 	if err != nil {
-		outputError(fmt.Sprintf("Failed to create request: %v", err))
-		os.Exit(1)
+		return UploadStatus{}, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Upload-Length", strconv.FormatInt(u.size, 10))
+	req.Header.Set("Upload-Filename", filename)
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", "Bearer "+*token)
+	var status UploadStatus
+	if _, err := u.do(req, http.StatusCreated, &status); err != nil {
+		return UploadStatus{}, err
+	}
+	return status, nil
+}
 
-	// Send request
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// status returns how much of an upload the server received
+func (u *uploader) status(id string) (UploadStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, u.serverURL+"/"+id, nil)
 	if err != nil {
-		outputError(fmt.Sprintf("Failed to send request: %v", err))
-		os.Exit(1)
+		return UploadStatus{}, fmt.Errorf("failed to create request: %w", err)
 	}
-	defer resp.Body.Close()
+	var status UploadStatus
+	if _, err := u.do(req, http.StatusOK, &status); err != nil {
+		return UploadStatus{}, err
+	}
+	return status, nil
+}
+
+// send uploads the file from the offset in status in chunks and returns the
+// server's upload result. A failed chunk is retried from the offset the
+// server reports, so bytes that arrived before a dropped connection are not
+// sent again.
+func (u *uploader) send(status UploadStatus) ([]byte, error) {
+	retries := 0
+	for {
+		end := min(status.Offset+u.chunkSize, u.size)
+		req, err := http.NewRequest(http.MethodPatch, u.serverURL+"/"+status.UploadID, io.NewSectionReader(u.file, status.Offset, end-status.Offset))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.ContentLength = end - status.Offset
+		req.Header.Set("Upload-Offset", strconv.FormatInt(status.Offset, 10))
+
+		// The last chunk returns the upload result instead of the status
+		var next struct {
+			UploadStatus
+			ObjectKey string `json:"object_key"`
+		}
+		body, err := u.do(req, http.StatusOK, &next)
+		if err == nil {
+			if next.ObjectKey != "" {
+				return body, nil
+			}
+			status.Offset, retries = next.Offset, 0
+			continue
+		}
+
+		// An expired upload or a file the server refuses cannot be retried
+		var se *statusError
+		if errors.As(err, &se) && (se.code == http.StatusNotFound || se.code == http.StatusRequestEntityTooLarge) {
+			return nil, err
+		}
+		if retries == maxRetries {
+			return nil, fmt.Errorf("upload interrupted at %d of %d bytes: %w", status.Offset, u.size, err)
+		}
+		time.Sleep(retryDelay << retries)
+		retries++
+		current, statusErr := u.status(status.UploadID)
+		if statusErr != nil {
+			continue
+		}
+		status.Offset = current.Offset
+	}
+}
 
-	// Read response body
-	respBody, err := io.ReadAll(resp.Body)
+// do sends req and decodes a response with status want into v, returning
+// the response body
+func (u *uploader) do(req *http.Request, want int, v any) ([]byte, error) {
+	resp, err := u.client.Do(req)
 	if err != nil {
-		outputError(fmt.Sprintf("Failed to read response: %v", err))
-		os.Exit(1)
+		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != want {
 		// Try to parse error response
 		var errResult ErrorResult
-		if json.Unmarshal(respBody, &errResult) == nil && errResult.Error != "" {
-			outputError(errResult.Error)
-		} else {
-			outputError(fmt.Sprintf("Server returned status %d: %s", resp.StatusCode, string(respBody)))
+		if json.Unmarshal(body, &errResult) == nil && errResult.Error != "" {
+			return nil, &statusError{code: resp.StatusCode, msg: errResult.Error}
 		}
-		os.Exit(1)
+		return nil, &statusError{code: resp.StatusCode, msg: fmt.Sprintf("server returned status %d: %s", resp.StatusCode, string(body))}
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
 	}
+	return body, nil
+}
 
-	// Output server response (already JSON formatted)
-	fmt.Println(string(respBody))
+// statusError is an error response of the server
+type statusError struct {
+	code int
+	msg  string
 }
 
+func (e *statusError) Error() string { return e.msg }
+
 func printUsage() {
 	fmt.Fprintf(os.Stderr, `upload_media - Upload files to gemini-mcp server
 
 Usage:
   upload_media --server <url> --token <token> <file_path>
+  upload_media --server <url> --resume <upload_id> <file_path>
   upload_media -version
   upload_media -help
 
@@ -178,6 +308,11 @@ Required Flags:
   --server    Server upload URL (e.g., "http://localhost:8080/upload")
   --token     One-time authentication token (provided by upload_media MCP tool)
 
+Optional Flags:
+  --resume       Upload ID of an interrupted upload to continue instead of
+                 starting a new one (no token needed)
+  --chunk-size   Size of each chunk sent in MiB (default: 8)
+
 Arguments:
   <file_path>    Absolute path to the file to upload
 
@@ -188,13 +323,22 @@ Output:
   JSON object with object_key, download_url, mime_type, size, etc.
   Use the object_key with gemini_image_edit, gemini_multi_image, or veo_image_to_video.
 
+Resuming:
+  The file is sent in chunks. Dropped connections are retried from the
+  offset the server reached. If the upload still fails, the error output
+  includes an upload_id and the command that resumes the upload with
+  --resume; it can be resumed until the server's UPLOAD_SESSION_TTL passes.
+
 Note:
   The token is ONE-TIME USE only. Get a new token by calling the upload_media MCP tool.
 `)
 }
 
 func outputError(msg string) {
-	result := ErrorResult{Error: msg}
-	jsonBytes, _ := json.MarshalIndent(result, "", "  ")
-	fmt.Fprintln(os.Stderr, string(jsonBytes))
+	outputJSON(os.Stderr, ErrorResult{Error: msg})
+}
+
+func outputJSON(w io.Writer, v any) {
+	jsonBytes, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(w, string(jsonBytes))
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Depth map generation
+type GenerateDepthMapInput struct {
+	ImagePath      string  `json:"image_path" jsonschema:"description:Path to the image to estimate depth for. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Model          string  `json:"model,omitempty" jsonschema:"description:Gemini image model used to estimate depth,default:gemini-3-pro-image-preview"`
+	NormalMap      bool    `json:"normal_map,omitempty" jsonschema:"description:Also derive a tangent-space normal map (OpenGL convention) from the depth map,default:false"`
+	NormalStrength float64 `json:"normal_strength,omitempty" jsonschema:"description:Slope multiplier used when deriving the normal map. Higher values exaggerate surface relief.,default:2.0"`
+	Invert         bool    `json:"invert,omitempty" jsonschema:"description:Invert the depth map so near surfaces are black and far surfaces are white,default:false"`
+	LinkTTL        string  `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget  string  `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename       string  `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+}
+
+type GenerateDepthMapOutput struct {
+	SourceImage   string            `json:"source_image"`
+	DepthMapFile  string            `json:"depth_map_file"`
+	NormalMapFile string            `json:"normal_map_file,omitempty"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	SavedFiles    []string          `json:"saved_files,omitempty"`
+	DataURIs      map[string]string `json:"data_uris,omitempty"`
+	Thumbnails    map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs  []string          `json:"download_urls,omitempty"`
+	ExpiresAt     string            `json:"expires_at,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	GeneratedAt   string            `json:"generated_at"`
+}
+
+const depthMapPrompt = "Convert this image into a monocular depth map. Output only the depth map: a smooth grayscale image with exactly the same framing and composition, where the nearest surfaces are pure white and the farthest surfaces are pure black. No colors, no outlines, no text, no shading from lighting."
+
+func (s *Server) handleGenerateDepthMap(ctx context.Context, req *mcp.CallToolRequest, input GenerateDepthMapInput) (*mcp.CallToolResult, GenerateDepthMapOutput, error) {
+	if input.ImagePath == "" {
+		return nil, GenerateDepthMapOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+
+	// Set defaults
+	model := input.Model
+	if model == "" {
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("generate_depth_map", model); err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+
+	strength := input.NormalStrength
+	if strength <= 0 {
+		strength = 2.0
+	}
+
+	log.Printf("Generating depth map for %s with model %s (normal map: %v)", input.ImagePath, model, input.NormalMap)
+
+	// Resolve input image path (may download from S3)
+	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	imgData, err := os.ReadFile(localImagePath)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, fmt.Errorf("failed to read input image: %w", err)
+	}
+
+	imgMIMEType, err := imaging.DetectInputMIME(imgData)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid input image: %w", err)
+	}
+
+	source, _, err := imaging.Decode(imgData)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+
+	estimate, _, err := s.generateImage(ctx, model, depthMapPrompt, closestAspectRatio(width, height), "1K",
+		&genai.Part{InlineData: &genai.Blob{MIMEType: imgMIMEType, Data: imgData}})
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+
+	estimateImg, _, err := imaging.Decode(estimate)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+
+	// Match the source dimensions so the maps line up pixel for pixel
+	depth := imaging.Normalize(imaging.Grayscale(imaging.CropToFill(estimateImg, width, height), input.Invert))
+
+	output := GenerateDepthMapOutput{
+		SourceImage: input.ImagePath,
+		Width:       width,
+		Height:      height,
+		Metadata: map[string]string{
+			"source_image": input.ImagePath,
+			"model":        model,
+			"invert":       fmt.Sprintf("%v", input.Invert),
+		},
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+
+	var contents []mcp.Content
+	store := func(data []byte, prefix string) (string, error) {
+		result, err := s.storage.Store(ctx, data, "image/png", prefix)
+		if err != nil {
+			return "", fmt.Errorf("failed to store %s: %w", prefix, err)
+		}
+		log.Printf("Stored %s: %s", prefix, result.Location)
+		output.SavedFiles = append(output.SavedFiles, result.ObjectKey)
+		output.DataURIs = s.addDataURI(output.DataURIs, result, data)
+		output.Thumbnails = addThumbnail(output.Thumbnails, result)
+		if s.storage.IsRemote() {
+			output.DownloadURLs = append(output.DownloadURLs, result.Location)
+			if result.ExpiresAt != nil && output.ExpiresAt == "" {
+				output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+		} else {
+			contents = append(contents, s.mediaContent(data, result)...)
+		}
+		return result.ObjectKey, nil
+	}
+
+	depthData, err := imaging.EncodePNG(depth)
+	if err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+	if output.DepthMapFile, err = store(depthData, "depth_map"); err != nil {
+		return nil, GenerateDepthMapOutput{}, err
+	}
+
+	if input.NormalMap {
+		// Normals are always derived with near = light, regardless of invert
+		heights := depth
+		if input.Invert {
+			heights = imaging.Grayscale(depth, true)
+		}
+		normalData, err := imaging.EncodePNG(imaging.NormalMap(heights, strength))
+		if err != nil {
+			return nil, GenerateDepthMapOutput{}, err
+		}
+		if output.NormalMapFile, err = store(normalData, "normal_map"); err != nil {
+			return nil, GenerateDepthMapOutput{}, err
+		}
+		output.Metadata["normal_strength"] = fmt.Sprintf("%.2f", strength)
+	}
+
+	summary := fmt.Sprintf("Generated depth map (%dx%d)", width, height)
+	if input.NormalMap {
+		summary += " and normal map"
+	}
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := summary + ". Download URLs:\n"
+		for i, url := range output.DownloadURLs {
+			contentText += fmt.Sprintf("%d. %s\n", i+1, url)
+		}
+		if output.ExpiresAt != "" {
+			contentText += fmt.Sprintf("\nURLs expire at: %s", output.ExpiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary + "."}}, contents...),
+		}
+	}
+
+	return result, output, nil
+}
+
+// closestAspectRatio picks the supported generation aspect ratio nearest to width x height
+func closestAspectRatio(width, height int) string {
+	ratios := []struct {
+		name  string
+		value float64
+	}{
+		{"1:1", 1}, {"2:3", 2.0 / 3}, {"3:2", 3.0 / 2}, {"3:4", 3.0 / 4}, {"4:3", 4.0 / 3},
+		{"4:5", 4.0 / 5}, {"5:4", 5.0 / 4}, {"9:16", 9.0 / 16}, {"16:9", 16.0 / 9}, {"21:9", 21.0 / 9},
+	}
+	target := float64(width) / float64(height)
+	best, bestDiff := "1:1", -1.0
+	for _, r := range ratios {
+		diff := target/r.value - 1
+		if diff < 0 {
+			diff = -diff
+		}
+		if bestDiff < 0 || diff < bestDiff {
+			best, bestDiff = r.name, diff
+		}
+	}
+	return best
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/video"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// defaultThumbnailSize is the longest side of scene thumbnails in pixels
+const defaultThumbnailSize = 320
+
+// Scene detection
+type DetectScenesInput struct {
+	VideoPath      string `json:"video_path" jsonschema:"description:Path to the video. Can be a local MP4/MOV/WebM file path or an object key returned by a Veo tool (found in saved_files) or by upload_media."`
+	SkipThumbnails bool   `json:"skip_thumbnails,omitempty" jsonschema:"description:Only return the scene list, without extracting a thumbnail from the middle of each scene (thumbnails require ffmpeg on the server),default:false"`
+	ThumbnailSize  int    `json:"thumbnail_size,omitempty" jsonschema:"description:Longest side of the thumbnails in pixels (64-1920),default:320"`
+	Model          string `json:"model,omitempty" jsonschema:"description:Gemini model used to find the scene boundaries,default:gemini-2.5-flash"`
+	SegmentSeconds int    `json:"segment_seconds,omitempty" jsonschema:"description:Detect scenes in segments of this many seconds, for videos too long to process in one pass. By default videos longer than 40 minutes are split into 10-minute segments. Minimum 60."`
+	LinkTTL        string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget  string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+// DetectedScene is one shot or scene of a video. Timestamps refer to the full
+// video; StartSeconds and EndSeconds can be passed to clip or edit tools.
+type DetectedScene struct {
+	Index        int     `json:"index"`
+	Start        string  `json:"start"`
+	End          string  `json:"end"`
+	StartSeconds float64 `json:"start_seconds"`
+	EndSeconds   float64 `json:"end_seconds"`
+	Transition   string  `json:"transition,omitempty"`
+	Description  string  `json:"description"`
+	Thumbnail    string  `json:"thumbnail,omitempty"`
+	ThumbnailURL string  `json:"thumbnail_url,omitempty"`
+}
+
+type DetectScenesOutput struct {
+	VideoPath    string            `json:"video_path"`
+	Model        string            `json:"model"`
+	Duration     string            `json:"duration,omitempty"`
+	Scenes       []DetectedScene   `json:"scenes"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Warnings     []string          `json:"warnings,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+const detectScenesPrompt = "List every shot in this video in order. A new shot starts at each cut or transition (hard cut, fade, dissolve, wipe) or when the camera setup changes. For each shot give the start and end timestamps (MM:SS, or HH:MM:SS past one hour, with fractional seconds where useful), the transition that starts it ('cut', 'fade', 'dissolve', 'wipe', or 'none' for the first shot), and a one-sentence description of its content and framing. Shots must be contiguous and cover the whole video."
+
+// detectScenesConfig requests the shot list as JSON
+var detectScenesConfig = &genai.GenerateContentConfig{
+	ResponseMIMEType: "application/json",
+	ResponseSchema: &genai.Schema{
+		Type: genai.TypeArray,
+		Items: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"start":       {Type: genai.TypeString},
+				"end":         {Type: genai.TypeString},
+				"transition":  {Type: genai.TypeString},
+				"description": {Type: genai.TypeString},
+			},
+			Required: []string{"start", "end", "description"},
+		},
+	},
+}
+
+func (s *Server) handleDetectScenes(ctx context.Context, req *mcp.CallToolRequest, input DetectScenesInput) (*mcp.CallToolResult, DetectScenesOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	if input.VideoPath == "" {
+		return nil, DetectScenesOutput{}, toolerr.Errorf(toolerr.InvalidInput, "video_path is required")
+	}
+
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("detect_scenes", model); err != nil {
+		return nil, DetectScenesOutput{}, err
+	}
+
+	thumbSize := input.ThumbnailSize
+	if thumbSize == 0 {
+		thumbSize = defaultThumbnailSize
+	}
+	if thumbSize < 64 || thumbSize > 1920 {
+		return nil, DetectScenesOutput{}, toolerr.Errorf(toolerr.InvalidInput, "thumbnail_size must be between 64 and 1920")
+	}
+
+	if input.SegmentSeconds != 0 && input.SegmentSeconds < 60 {
+		return nil, DetectScenesOutput{}, toolerr.Errorf(toolerr.InvalidInput, "segment_seconds must be at least 60")
+	}
+
+	mimeType := videoMIMEFromPath(input.VideoPath)
+	if mimeType == "" {
+		return nil, DetectScenesOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported video format: %s (supported: .mp4, .mov, .webm)", filepath.Ext(input.VideoPath))
+	}
+
+	log.Printf("Detecting scenes in %s with model %s", input.VideoPath, model)
+
+	// Resolve input video path (may download from S3)
+	localVideoPath, cleanup, err := s.resolveInputPath(ctx, input.VideoPath)
+	if err != nil {
+		return nil, DetectScenesOutput{}, fmt.Errorf("failed to resolve input video: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	// Short clips are sent inline; larger videos go through the Files API
+	inputs := s.newInputParts()
+	defer inputs.Close()
+	videoPart, file, err := inputs.addFile(ctx, localVideoPath, mimeType)
+	if err != nil {
+		return nil, DetectScenesOutput{}, err
+	}
+
+	duration := videoDuration(file, localVideoPath)
+	segmentLength := time.Duration(input.SegmentSeconds) * time.Second
+	if segmentLength == 0 && duration > longVideoThreshold {
+		segmentLength = defaultVideoSegment
+	}
+	spans := []video.Segment{{Start: 0, End: duration}}
+	if segmentLength > 0 && duration > segmentLength {
+		spans = video.Split(duration, segmentLength)
+		log.Printf("Detecting scenes in %s (%s) in %d segments", input.VideoPath, video.FormatTimestamp(duration), len(spans))
+	}
+
+	var scenes []DetectedScene
+	for i, span := range spans {
+		var clip *genai.VideoMetadata
+		prompt := detectScenesPrompt
+		if len(spans) > 1 {
+			clip = &genai.VideoMetadata{StartOffset: span.Start, EndOffset: span.End}
+			prompt = fmt.Sprintf("This clip is segment %d of %d of a longer video. Give timestamps relative to the start of the clip.\n%s", i+1, len(spans), prompt)
+		}
+		text, err := s.analyzeVideoPart(ctx, model, videoPart, clip, prompt, detectScenesConfig)
+		if err != nil {
+			return nil, DetectScenesOutput{}, fmt.Errorf("segment %d: %w", i+1, err)
+		}
+		found, err := parseDetectedScenes(ctx, text, span.Start)
+		if err != nil {
+			return nil, DetectScenesOutput{}, fmt.Errorf("failed to parse scene list: %w", err)
+		}
+		scenes = append(scenes, found...)
+	}
+	for i := range scenes {
+		scenes[i].Index = i + 1
+	}
+
+	output := DetectScenesOutput{
+		VideoPath: input.VideoPath,
+		Model:     model,
+		Scenes:    scenes,
+		Metadata: map[string]string{
+			"scene_count": fmt.Sprintf("%d", len(scenes)),
+		},
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	if duration > 0 {
+		output.Duration = video.FormatTimestamp(duration)
+	}
+	if file != nil {
+		output.Metadata["gemini_file"] = file.Name
+	}
+	if len(spans) > 1 {
+		output.Metadata["segments"] = fmt.Sprintf("%d", len(spans))
+	}
+
+	var contents []mcp.Content
+	if !input.SkipThumbnails {
+		contents, err = s.sceneThumbnails(ctx, localVideoPath, thumbSize, &output)
+		if err != nil {
+			warnings.Add(ctx, "skipping scene thumbnails: %v", err)
+			output.Metadata["thumbnails"] = "skipped: " + err.Error()
+		}
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Detected %d scenes", len(scenes))
+	if output.Duration != "" {
+		fmt.Fprintf(&summary, " in %s of video", output.Duration)
+	}
+	summary.WriteString(":\n")
+	for _, scene := range scenes {
+		fmt.Fprintf(&summary, "%d. [%s - %s] %s", scene.Index, scene.Start, scene.End, scene.Description)
+		if scene.Transition != "" && scene.Transition != "none" {
+			fmt.Fprintf(&summary, " (%s)", scene.Transition)
+		}
+		if scene.ThumbnailURL != "" {
+			fmt.Fprintf(&summary, "\n   %s", scene.ThumbnailURL)
+		}
+		summary.WriteString("\n")
+	}
+	if output.ExpiresAt != "" {
+		fmt.Fprintf(&summary, "\nURLs expire at: %s", output.ExpiresAt)
+	}
+	output.Warnings = collected.List()
+
+	return &mcp.CallToolResult{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: summary.String()}}, contents...),
+	}, output, nil
+}
+
+// parseDetectedScenes decodes the model's shot list and moves its timestamps
+// onto the full video's timeline
+func parseDetectedScenes(ctx context.Context, text string, offset time.Duration) ([]DetectedScene, error) {
+	var raw []struct {
+		Start       string `json:"start"`
+		End         string `json:"end"`
+		Transition  string `json:"transition"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil, err
+	}
+
+	scenes := make([]DetectedScene, 0, len(raw))
+	for _, r := range raw {
+		start, okStart := video.ParseTimestamp(r.Start)
+		end, okEnd := video.ParseTimestamp(r.End)
+		if !okStart || !okEnd || end < start {
+			warnings.Add(ctx, "ignoring scene with invalid timestamps %q - %q", r.Start, r.End)
+			continue
+		}
+		start, end = start+offset, end+offset
+		scenes = append(scenes, DetectedScene{
+			Start:        video.FormatTimestamp(start),
+			End:          video.FormatTimestamp(end),
+			StartSeconds: start.Seconds(),
+			EndSeconds:   end.Seconds(),
+			Transition:   strings.ToLower(r.Transition),
+			Description:  r.Description,
+		})
+	}
+	return scenes, nil
+}
+
+// sceneThumbnails extracts and stores a thumbnail from the middle of each
+// scene, returning the content blocks to show for local storage
+func (s *Server) sceneThumbnails(ctx context.Context, localVideoPath string, size int, output *DetectScenesOutput) ([]mcp.Content, error) {
+	if s.media.Backend(media.OpFrameExtraction) == media.Unavailable {
+		return nil, media.ErrNoFFmpeg
+	}
+
+	var contents []mcp.Content
+	for i := range output.Scenes {
+		scene := &output.Scenes[i]
+		mid := time.Duration((scene.StartSeconds + scene.EndSeconds) / 2 * float64(time.Second))
+		data, err := s.media.ExtractFrame(ctx, localVideoPath, mid, size)
+		if err != nil {
+			warnings.Add(ctx, "no thumbnail for scene %d: %v", scene.Index, err)
+			continue
+		}
+
+		result, err := s.storage.Store(ctx, data, "image/jpeg", "scene_thumb")
+		if err != nil {
+			return contents, fmt.Errorf("failed to store thumbnail: %w", err)
+		}
+		scene.Thumbnail = result.ObjectKey
+		output.SavedFiles = append(output.SavedFiles, result.ObjectKey)
+		output.DataURIs = s.addDataURI(output.DataURIs, result, data)
+		output.Thumbnails = addThumbnail(output.Thumbnails, result)
+		if s.storage.IsRemote() {
+			scene.ThumbnailURL = result.Location
+			output.DownloadURLs = append(output.DownloadURLs, result.Location)
+			if result.ExpiresAt != nil && output.ExpiresAt == "" {
+				output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+		} else {
+			contents = append(contents, s.mediaContent(data, result)...)
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/certs"
+	"gemini-mcp/internal/common"
+	"gemini-mcp/internal/doctor"
+	"gemini-mcp/internal/fieldcrypt"
+	"gemini-mcp/internal/httpclient"
+	"gemini-mcp/internal/i18n"
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/models"
+	"gemini-mcp/internal/storage"
+
+	"google.golang.org/genai"
+)
+
+// doctorCheckTimeout bounds each check of the -doctor mode
+const doctorCheckTimeout = 30 * time.Second
+
+// runDoctor checks the deployment the configuration describes - settings,
+// Gemini API access with each key, storage round trips and presigned URLs,
+// writable directories and ffmpeg - and prints a pass/fail report to w.
+// It returns false if any check failed.
+func runDoctor(ctx context.Context, config *common.Config, w io.Writer) bool {
+	httpTransport := httpclient.NewTransport(httpclient.PoolConfig{
+		MaxIdleConns:        config.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: config.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     config.HTTPIdleConnTimeout,
+	})
+	httpClient := &http.Client{Transport: httpTransport}
+	s3Transport, s3PoolErr := s3Pool(config, httpTransport)
+	httpMode := config.HTTPEnabled()
+
+	var stor storage.Storage
+	defer func() {
+		if stor != nil {
+			stor.Close()
+		}
+	}()
+
+	checks := []doctor.Check{
+		{Name: "configuration", Run: func(ctx context.Context) (string, error) {
+			if err := doctorValidateConfig(config); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("transport %s, %d API key(s)", config.Transport, len(config.APIKeys)), nil
+		}},
+		{Name: "authentication", Run: func(ctx context.Context) (string, error) {
+			if !httpMode {
+				return "", doctor.Skipped("not used by the %s transport", config.Transport)
+			}
+			if !config.AuthEnabled {
+				return "", doctor.Warning(errors.New("no SERVICE_TOKENS or JWT issuer configured; the server is publicly accessible"))
+			}
+			detail := fmt.Sprintf("%d service token(s)", len(config.ServiceTokens))
+			if config.JWTEnabled {
+				validator, err := middleware.NewJWTValidator(middleware.JWTConfig{JWKSURL: config.JWTJWKSURL, Issuer: config.JWTIssuer})
+				if err != nil {
+					return "", fmt.Errorf("JWT authentication: %w", err)
+				}
+				keys, err := validator.FetchKeys(ctx)
+				if err != nil {
+					return "", fmt.Errorf("JWT authentication: %w", err)
+				}
+				detail += fmt.Sprintf(", %d JWT signing key(s) fetched", keys)
+			}
+			return detail, nil
+		}},
+		doctor.Check{Name: "tls certificate", Run: func(ctx context.Context) (string, error) {
+			if !httpMode || config.TLSCertFile == "" {
+				return "", doctor.Skipped("TLS_CERT_FILE is not set; serving plain HTTP")
+			}
+			reloader, err := certs.New(config.TLSCertFile, config.TLSKeyFile)
+			if err != nil {
+				return "", err
+			}
+			cert, _ := reloader.GetCertificate(nil)
+			leaf := cert.Leaf
+			if leaf == nil {
+				return config.TLSCertFile, nil
+			}
+			detail := fmt.Sprintf("%s, expires %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.DateOnly))
+			switch remaining := time.Until(leaf.NotAfter); {
+			case remaining <= 0:
+				return "", fmt.Errorf("certificate %s expired on %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.DateOnly))
+			case remaining < 14*24*time.Hour:
+				return "", doctor.Warning(fmt.Errorf("%s; renew it soon", detail))
+			}
+			return detail, nil
+		}},
+	}
+
+	for i, key := range config.APIKeys {
+		checks = append(checks, doctor.Check{Name: fmt.Sprintf("gemini api key %d", i+1), Run: func(ctx context.Context) (string, error) {
+			return doctorPingGemini(ctx, httpClient, key, config.TextDefaultModel)
+		}})
+	}
+	if len(config.APIKeys) == 0 {
+		checks = append(checks, doctor.Check{Name: "gemini api", Run: func(ctx context.Context) (string, error) {
+			return "", errors.New("GOOGLE_API_KEY or GOOGLE_API_KEYS is not set")
+		}})
+	}
+
+	checks = append(checks,
+		doctor.Check{Name: "storage", Run: func(ctx context.Context) (string, error) {
+			if s3PoolErr != nil {
+				return "", s3PoolErr
+			}
+			var err error
+			if stor, err = storage.NewStorage(config, s3Transport, nil); err != nil {
+				return "", err
+			}
+			key, err := doctorStoreObject(ctx, stor)
+			if err != nil {
+				return "", err
+			}
+			localPath, cleanup, err := stor.Retrieve(ctx, key)
+			if err != nil {
+				return "", fmt.Errorf("retrieve %s: %w", key, err)
+			}
+			_, statErr := os.Stat(localPath)
+			if cleanup != nil {
+				cleanup()
+			}
+			if statErr != nil {
+				return "", fmt.Errorf("retrieve %s: %w", key, statErr)
+			}
+			if err := stor.Delete(ctx, key); err != nil {
+				return "", fmt.Errorf("delete %s: %w", key, err)
+			}
+			if config.S3Enabled {
+				return fmt.Sprintf("stored, retrieved and deleted an object in S3 bucket %s", config.S3Bucket), nil
+			}
+			return fmt.Sprintf("stored, retrieved and deleted a file in %s", config.OutputDir), nil
+		}},
+		doctor.Check{Name: "presigned urls", Run: func(ctx context.Context) (string, error) {
+			if !config.S3Enabled {
+				return "", doctor.Skipped("S3 storage is not enabled")
+			}
+			if stor == nil {
+				return "", doctor.Skipped("storage is unavailable")
+			}
+			key, err := doctorStoreObject(ctx, stor)
+			if err != nil {
+				return "", err
+			}
+			defer stor.Delete(context.WithoutCancel(ctx), key)
+			link, _, err := stor.ShareLink(ctx, key, time.Minute)
+			if err != nil {
+				return "", err
+			}
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
+			if err != nil {
+				return "", err
+			}
+			resp, err := (&http.Client{Transport: s3Transport}).Do(req)
+			if err != nil {
+				return "", doctor.Warning(fmt.Errorf("presigned URL generated but not reachable from here: %w", err))
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return "", fmt.Errorf("presigned URL returned HTTP %d", resp.StatusCode)
+			}
+			return "generated and downloaded a presigned URL", nil
+		}},
+		doctor.Check{Name: "output directory", Run: func(ctx context.Context) (string, error) {
+			if config.S3Enabled {
+				return "", doctor.Skipped("S3 storage is enabled")
+			}
+			return doctorCheckWritable(config.OutputDir)
+		}},
+		doctor.Check{Name: "chat session directory", Run: func(ctx context.Context) (string, error) {
+			if config.ChatSessionDir == "" {
+				return "", doctor.Skipped("CHAT_SESSION_DIR is not set; sessions are kept in memory")
+			}
+			if err := os.MkdirAll(config.ChatSessionDir, 0700); err != nil {
+				return "", err
+			}
+			return doctorCheckWritable(config.ChatSessionDir)
+		}},
+		doctor.Check{Name: "ffmpeg", Run: func(ctx context.Context) (string, error) {
+			caps := media.New(config.FFmpegPath, nil).Capabilities()
+			if !caps.FFmpeg {
+				var fallbacks, unavailable []string
+				for op, backend := range caps.Operations {
+					if backend == media.PureGo {
+						fallbacks = append(fallbacks, op)
+					} else {
+						unavailable = append(unavailable, op)
+					}
+				}
+				slices.Sort(fallbacks)
+				slices.Sort(unavailable)
+				return "", doctor.Warning(fmt.Errorf("%s not found; pure-Go fallbacks for %s, unavailable: %s", config.FFmpegPath, strings.Join(fallbacks, ", "), strings.Join(unavailable, ", ")))
+			}
+			return caps.FFmpegPath, nil
+		}},
+	)
+
+	results := doctor.Run(ctx, checks, doctorCheckTimeout)
+	doctor.Write(w, results)
+	return !doctor.Failed(results)
+}
+
+// doctorValidateConfig runs the validation the server does at startup,
+// reporting every problem instead of stopping at the first
+func doctorValidateConfig(config *common.Config) error {
+	var errs []error
+	if err := config.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if _, ok := i18n.Lookup(config.ResponseLanguage); !ok {
+		errs = append(errs, fmt.Errorf("invalid RESPONSE_LANGUAGE %q", config.ResponseLanguage))
+	}
+	if _, err := models.Parse(config.ModelAllowlist); err != nil {
+		errs = append(errs, fmt.Errorf("invalid MODEL_ALLOWLIST: %w", err))
+	}
+	if _, err := fieldcrypt.ParseKeyring(config.PromptEncryptionKeys); err != nil {
+		errs = append(errs, fmt.Errorf("invalid PROMPT_ENCRYPTION_KEYS: %w", err))
+	}
+	if _, err := storage.NewPathPolicy(config.FollowSymlinks, config.AllowedMounts); err != nil {
+		errs = append(errs, fmt.Errorf("invalid local path policy: %w", err))
+	}
+	tenants, err := middleware.ParseTokenTenants(config.ServiceTokenTenants)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid SERVICE_TOKEN_TENANTS: %w", err))
+	}
+	for _, tenant := range tenants {
+		if err := storage.ValidateTenant(tenant); err != nil {
+			errs = append(errs, fmt.Errorf("invalid SERVICE_TOKEN_TENANTS: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// doctorPingGemini sends a one-word prompt to model with a single API key
+func doctorPingGemini(ctx context.Context, httpClient *http.Client, apiKey, model string) (string, error) {
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: httpClient,
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := client.Models.GenerateContent(ctx, model, genai.Text("Reply with the single word OK."), &genai.GenerateContentConfig{MaxOutputTokens: 8}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s answered (key ...%s)", model, apiKey[max(len(apiKey)-4, 0):]), nil
+}
+
+// doctorStoreObject stores a small text object and returns its key
+func doctorStoreObject(ctx context.Context, stor storage.Storage) (string, error) {
+	data := []byte(fmt.Sprintf("gemini-mcp doctor check %d\n", time.Now().UnixNano()))
+	result, err := stor.Store(ctx, data, "text/plain", "doctor")
+	if err != nil {
+		return "", fmt.Errorf("store: %w", err)
+	}
+	return result.ObjectKey, nil
+}
+
+// doctorCheckWritable creates and removes a temporary file in dir
+func doctorCheckWritable(dir string) (string, error) {
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		return "", fmt.Errorf("%s is not writable: %w", dir, err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return dir + " is writable", nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// pendingOperation is a Veo operation being polled. Operations still running
+// when the drain times out are written to PENDING_OPERATIONS_FILE, since
+// their videos are billed and can still be fetched from the Gemini API.
+type pendingOperation struct {
+	OperationID string `json:"operation_id"`
+	Label       string `json:"label"`
+	Tenant      string `json:"tenant,omitempty"`
+	StartedAt   string `json:"started_at"`
+}
+
+// pendingOperations tracks the Veo operations being polled
+type pendingOperations struct {
+	mu  sync.Mutex
+	ops map[string]pendingOperation
+}
+
+func newPendingOperations() *pendingOperations {
+	return &pendingOperations{ops: make(map[string]pendingOperation)}
+}
+
+// add records an operation until the returned function is called
+func (p *pendingOperations) add(ctx context.Context, operationID, label string) (done func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.ops[operationID] = pendingOperation{
+		OperationID: operationID,
+		Label:       label,
+		Tenant:      middleware.GetTenant(ctx),
+		StartedAt:   time.Now().Format(time.RFC3339),
+	}
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.ops, operationID)
+	}
+}
+
+// list returns the operations being polled, oldest first
+func (p *pendingOperations) list() []pendingOperation {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ops := make([]pendingOperation, 0, len(p.ops))
+	for _, op := range p.ops {
+		ops = append(ops, op)
+	}
+	slices.SortFunc(ops, func(a, b pendingOperation) int {
+		return strings.Compare(a.StartedAt+a.OperationID, b.StartedAt+b.OperationID)
+	})
+	return ops
+}
+
+// drainMiddleware refuses tool calls once the server has started draining
+// for shutdown and counts the calls in flight until then
+func (s *Server) drainMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		leave, ok := s.drainer.Enter()
+		if !ok {
+			err := toolerr.Errorf(toolerr.Unavailable, "the server is shutting down and accepts no new tool calls")
+			if recorded, ok := ctx.Value(toolErrorKey{}).(**toolerr.Error); ok {
+				*recorded = err.(*toolerr.Error)
+			}
+			return &mcp.CallToolResult{
+				Content: []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+				IsError: true,
+			}, nil
+		}
+		defer leave()
+		return next(ctx, method, req)
+	}
+}
+
+// drain stops accepting tool calls and waits until the calls and background
+// video jobs in flight have finished or ctx is done. Veo operations still
+// running then are written to the pending operations file.
+func (s *Server) drain(ctx context.Context) {
+	if active := s.drainer.Active(); active > 0 {
+		log.Printf("Draining: waiting for %d tool calls and background jobs to finish", active)
+	}
+	if err := s.drainer.Drain(ctx); err != nil {
+		log.Printf("Drain ended with %d tool calls and background jobs still running", s.drainer.Active())
+	}
+	if err := savePendingOperations(s.config.PendingOperationsFile, s.pendingOps.list()); err != nil {
+		log.Printf("Warning: failed to save pending operations: %v", err)
+	}
+}
+
+// savePendingOperations writes ops to path, or removes the file left by an
+// earlier shutdown if there are none
+func savePendingOperations(path string, ops []pendingOperation) error {
+	if len(ops) == 0 {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(ops, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	for _, op := range ops {
+		log.Printf("Saved pending %s %s to %s", op.Label, op.OperationID, path)
+	}
+	return nil
+}
+
+// reportPendingOperations logs the operations an earlier shutdown left
+// running, so their videos can be recovered from the Gemini API
+func reportPendingOperations(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var ops []pendingOperation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		log.Printf("Warning: unreadable pending operations file %s: %v", path, err)
+		return
+	}
+	for _, op := range ops {
+		log.Printf("Warning: %s %s (started %s) was still running at the last shutdown; its video can be fetched from the Gemini API operation", op.Label, op.OperationID, op.StartedAt)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/storage"
+)
+
+// fileServingStorage wraps local storage in HTTP mode so that stored objects
+// are reported with signed /files/ download URLs instead of server-local paths,
+// which remote MCP clients cannot open
+type fileServingStorage struct {
+	storage.Storage
+	signer  *storage.URLSigner
+	baseURL string // Public base URL; falls back to the request's server URL
+}
+
+// Store saves content and replaces its location with a signed download URL
+func (f *fileServingStorage) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*storage.StorageResult, error) {
+	result, err := f.Storage.Store(ctx, data, mimeType, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	location, expiresAt := f.signedURL(ctx, result.ObjectKey, storage.LinkTTL(ctx, f.signer.TTL()))
+	result.Location = location
+	result.ExpiresAt = &expiresAt
+	return result, nil
+}
+
+// ShareLink signs a new download URL for an existing object
+func (f *fileServingStorage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	_, cleanup, err := f.Storage.Retrieve(ctx, objectKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if cleanup != nil {
+		cleanup()
+	}
+	location, expiresAt := f.signedURL(ctx, objectKey, ttl)
+	return location, expiresAt, nil
+}
+
+// signedURL returns an absolute signed download URL for objectKey
+func (f *fileServingStorage) signedURL(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time) {
+	baseURL := f.baseURL
+	if baseURL == "" {
+		baseURL = middleware.GetServerURL(ctx)
+	}
+	path, expiresAt := f.signer.SignedPathTTL(objectKey, ttl)
+	return strings.TrimSuffix(baseURL, "/") + path, expiresAt
+}
+
+// IsRemote reports true so that tools return download URLs rather than
+// local file references
+func (f *fileServingStorage) IsRemote() bool {
+	return true
+}
+
+// handleFileDownload streams a stored object identified by the {key} path value
+func (s *Server) handleFileDownload(w http.ResponseWriter, r *http.Request) {
+	objectKey := r.PathValue("key")
+	if err := storage.ValidateObjectKey(objectKey); err != nil {
+		http.Error(w, `{"error":"Invalid object key"}`, http.StatusBadRequest)
+		return
+	}
+
+	// Signed URLs carry no tenant; authenticated callers only reach their own files
+	tenant := middleware.GetTenant(r.Context())
+	if tenant != "" && storage.ValidateTenant(tenant) != nil {
+		http.Error(w, `{"error":"Invalid tenant"}`, http.StatusForbidden)
+		return
+	}
+	localPath, cleanup, err := s.storage.Retrieve(storage.WithTenant(r.Context(), tenant), objectKey)
+	if err != nil {
+		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+		return
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	file, err := os.Open(localPath)
+	if err != nil {
+		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Serving file %s (%d bytes) to %s", objectKey, info.Size(), r.RemoteAddr)
+	http.ServeContent(s.fileLimiter.ResponseWriter(r.Context(), w), r, filepath.Base(objectKey), info.ModTime(), file)
+}
+
+// handleFileReissue redirects an authenticated caller to a fresh download URL
+// for the {key} path value. It serves callers whose signed /files URL has
+// expired and, with S3 storage, any /files request, giving clients a stable
+// address that always resolves to a live link.
+func (s *Server) handleFileReissue(w http.ResponseWriter, r *http.Request) {
+	objectKey := r.PathValue("key")
+	if err := storage.ValidateObjectKey(objectKey); err != nil {
+		http.Error(w, `{"error":"Invalid object key"}`, http.StatusBadRequest)
+		return
+	}
+
+	tenant := middleware.GetTenant(r.Context())
+	if tenant != "" && storage.ValidateTenant(tenant) != nil {
+		http.Error(w, `{"error":"Invalid tenant"}`, http.StatusForbidden)
+		return
+	}
+	ttl := s.config.FilesURLTTL
+	if s.config.S3Enabled {
+		ttl = s.config.S3PresignTTL
+	}
+	location, expiresAt, err := s.storage.ShareLink(storage.WithTenant(r.Context(), tenant), objectKey, ttl)
+	if err != nil {
+		http.Error(w, `{"error":"File not found"}`, http.StatusNotFound)
+		return
+	}
+
+	log.Printf("Re-issued download URL for %s to %s (expires %s)", objectKey, r.RemoteAddr, expiresAt.Format(time.RFC3339))
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, location, http.StatusTemporaryRedirect)
+}
+
+// writeExpiredURL tells a caller without credentials that its download URL
+// has expired and how to get a new one
+func writeExpiredURL(w http.ResponseWriter, objectKey, expires string) {
+	response := map[string]string{
+		"error":      "Download URL expired",
+		"code":       "url_expired",
+		"object_key": objectKey,
+		"hint":       "Request the URL again with a service token or JWT to be redirected to a fresh link, or call create_share_link with this object_key",
+	}
+	if unix, err := strconv.ParseInt(expires, 10, 64); err == nil {
+		response["expired_at"] = time.Unix(unix, 0).UTC().Format(time.RFC3339)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(response)
+}
+
+// filesHandler serves /files/{key} for requests carrying a valid URL
+// signature, re-issues expired URLs through reissue, and passes everything
+// else through authenticated
+func (s *Server) filesHandler(authenticated, reissue http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("signature") != "" {
+			err := s.fileSigner.Verify(r.PathValue("key"), query.Get("expires"), query.Get("signature"))
+			switch {
+			case errors.Is(err, storage.ErrURLExpired) && r.Header.Get("Authorization") != "":
+				reissue.ServeHTTP(w, r)
+				return
+			case errors.Is(err, storage.ErrURLExpired):
+				log.Printf("Rejected expired file download of %s from %s", r.PathValue("key"), r.RemoteAddr)
+				writeExpiredURL(w, r.PathValue("key"), query.Get("expires"))
+				return
+			case err != nil:
+				log.Printf("Rejected file download from %s: %v", r.RemoteAddr, err)
+				http.Error(w, `{"error":"Invalid or expired download URL"}`, http.StatusForbidden)
+				return
+			}
+			s.handleFileDownload(w, r)
+			return
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
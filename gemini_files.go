@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+
+	"google.golang.org/genai"
+)
+
+const (
+	// fileProcessingPollInterval is how often an uploaded file's state is checked
+	fileProcessingPollInterval = 5 * time.Second
+	// fileProcessingTimeout bounds how long to wait for the Files API to process an upload
+	fileProcessingTimeout = 5 * time.Minute
+)
+
+// uploadGeminiFile uploads a local file to the Gemini Files API and waits until it
+// is ACTIVE and can be referenced from GenerateContent. The returned cleanup
+// function deletes the remote file and is never nil.
+func (s *Server) uploadGeminiFile(ctx context.Context, localPath, mimeType string) (*genai.File, func(), error) {
+	file, err := s.client.Files.UploadFromPath(ctx, localPath, &genai.UploadFileConfig{
+		MIMEType: mimeType,
+	})
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to upload file to Gemini: %w", err)
+	}
+	log.Printf("Uploaded %s to Gemini Files API as %s", localPath, file.Name)
+	return s.awaitGeminiFile(ctx, file)
+}
+
+// uploadGeminiData is uploadGeminiFile for content already in memory
+func (s *Server) uploadGeminiData(ctx context.Context, data []byte, mimeType string) (*genai.File, func(), error) {
+	file, err := s.client.Files.Upload(ctx, bytes.NewReader(data), &genai.UploadFileConfig{
+		MIMEType: mimeType,
+	})
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("failed to upload file to Gemini: %w", err)
+	}
+	log.Printf("Uploaded %d bytes of %s to Gemini Files API as %s", len(data), mimeType, file.Name)
+	return s.awaitGeminiFile(ctx, file)
+}
+
+// awaitGeminiFile waits until an uploaded file is ACTIVE. The returned
+// cleanup function deletes the remote file and is never nil.
+func (s *Server) awaitGeminiFile(ctx context.Context, file *genai.File) (*genai.File, func(), error) {
+	var err error
+	cleanup := func() {
+		// Detach from cancellation so the file is removed even if the request was
+		// cancelled, while keeping the request's API key binding
+		deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if _, err := s.client.Files.Delete(deleteCtx, file.Name, nil); err != nil {
+			log.Printf("Warning: failed to delete Gemini file %s: %v", file.Name, err)
+		}
+	}
+
+	deadline := time.Now().Add(fileProcessingTimeout)
+	for file.State == genai.FileStateProcessing {
+		if time.Now().After(deadline) {
+			cleanup()
+			return nil, func() {}, toolerr.Errorf(toolerr.Timeout, "timed out waiting for Gemini to process %s", file.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			cleanup()
+			return nil, func() {}, ctx.Err()
+		case <-time.After(fileProcessingPollInterval):
+		}
+
+		file, err = s.client.Files.Get(ctx, file.Name, nil)
+		if err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("failed to check Gemini file state: %w", err)
+		}
+	}
+
+	if file.State == genai.FileStateFailed {
+		cleanup()
+		if file.Error != nil {
+			return nil, func() {}, toolerr.Errorf(toolerr.Upstream, "gemini failed to process file: %s", file.Error.Message)
+		}
+		return nil, func() {}, toolerr.Errorf(toolerr.Upstream, "gemini failed to process file")
+	}
+
+	return file, cleanup, nil
+}
+
+// inputParts builds the media parts of one GenerateContent request. Inputs
+// are sent inline until their total size would exceed
+// GEMINI_INLINE_MAX_BYTES, since inline data counts against the request size
+// limit; larger inputs are uploaded to the Gemini Files API and referenced by
+// URI. Close deletes the uploaded files.
+type inputParts struct {
+	s           *Server
+	inlineBytes int
+	uploaded    []string // Names of uploaded files
+	cleanups    []func()
+}
+
+func (s *Server) newInputParts() *inputParts {
+	return &inputParts{s: s}
+}
+
+// add returns the part for data, uploading it if it does not fit inline
+func (p *inputParts) add(ctx context.Context, data []byte, mimeType string) (*genai.Part, error) {
+	if p.inlineBytes+len(data) <= p.s.config.GeminiInlineMaxBytes {
+		p.inlineBytes += len(data)
+		return &genai.Part{InlineData: &genai.Blob{MIMEType: mimeType, Data: data}}, nil
+	}
+	file, cleanup, err := p.s.uploadGeminiData(ctx, data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+	p.uploaded = append(p.uploaded, file.Name)
+	p.cleanups = append(p.cleanups, cleanup)
+	return genai.NewPartFromURI(file.URI, file.MIMEType), nil
+}
+
+// addFile is add for a local file, which is uploaded from disk when it does
+// not fit inline. It also returns the uploaded file, or nil if it is inline.
+func (p *inputParts) addFile(ctx context.Context, localPath, mimeType string) (*genai.Part, *genai.File, error) {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+	if p.inlineBytes+int(info.Size()) <= p.s.config.GeminiInlineMaxBytes {
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", localPath, err)
+		}
+		p.inlineBytes += len(data)
+		return &genai.Part{InlineData: &genai.Blob{MIMEType: mimeType, Data: data}}, nil, nil
+	}
+	file, cleanup, err := p.s.uploadGeminiFile(ctx, localPath, mimeType)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.uploaded = append(p.uploaded, file.Name)
+	p.cleanups = append(p.cleanups, cleanup)
+	return genai.NewPartFromURI(file.URI, file.MIMEType), file, nil
+}
+
+// Uploaded returns the names of the files uploaded to the Files API
+func (p *inputParts) Uploaded() []string {
+	return p.uploaded
+}
+
+// Close deletes the uploaded files
+func (p *inputParts) Close() {
+	for _, cleanup := range p.cleanups {
+		cleanup()
+	}
+	p.cleanups = nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// GenerationResult is the envelope shared by the generation tools. It is
+// embedded in their outputs, so every tool reports its stored files, status,
+// stats and warnings under the same keys next to its tool-specific fields.
+type GenerationResult struct {
+	Status   string            `json:"status"`           // completed, blocked, failed, generating or timeout
+	Cached   bool              `json:"cached,omitempty"` // Returned from the response cache instead of generated
+	Assets   []GeneratedAsset  `json:"assets,omitempty"`
+	URLs     []string          `json:"urls,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Stats    GenerationStats   `json:"stats"`
+	Warnings []string          `json:"warnings,omitempty"`
+	// EnhancedPrompt is the prompt the client's model wrote with enhance
+	EnhancedPrompt string `json:"enhanced_prompt,omitempty"`
+}
+
+// GeneratedAsset is one file stored by a generation call
+type GeneratedAsset struct {
+	ObjectKey string `json:"object_key"`
+	MIMEType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	URL       string `json:"url,omitempty"` // Download URL (remote storage only)
+	ExpiresAt string `json:"expires_at,omitempty"`
+	DataURI   string `json:"data_uri,omitempty"`
+	Thumbnail string `json:"thumbnail,omitempty"`
+}
+
+// GenerationStats summarizes a generation call
+type GenerationStats struct {
+	Assets     int   `json:"assets"`
+	Bytes      int64 `json:"bytes"`
+	DurationMS int64 `json:"duration_ms"`
+	// Tokens are the Gemini tokens the call's GenerateContent requests used;
+	// absent for calls that made none, such as Veo and Imagen generations
+	Tokens *usage.Tokens `json:"tokens,omitempty"`
+}
+
+// generationOutput is implemented by tool outputs that embed GenerationResult
+type generationOutput interface {
+	generation() *GenerationResult
+	clearLegacyFields() // Drops the fields that GenerationResult.Assets and URLs replace
+}
+
+func (r *GenerationResult) generation() *GenerationResult { return r }
+
+// generationResult builds the envelope for the files a call stored. dataURIs
+// is the map filled by addDataURI. Warnings are filled in by
+// withGenerationResult.
+func (s *Server) generationResult(status string, stored []*storage.StorageResult, dataURIs, metadata map[string]string) GenerationResult {
+	r := GenerationResult{Status: status, Metadata: metadata}
+	for _, result := range stored {
+		asset := GeneratedAsset{
+			ObjectKey: result.ObjectKey,
+			MIMEType:  result.MIMEType,
+			Size:      result.Size,
+			DataURI:   dataURIs[result.ObjectKey],
+		}
+		if s.storage.IsRemote() {
+			asset.URL = result.Location
+			r.URLs = append(r.URLs, result.Location)
+		}
+		if result.ExpiresAt != nil {
+			asset.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+		}
+		if result.Thumbnail != nil {
+			asset.Thumbnail = result.Thumbnail.Location
+		}
+		r.Assets = append(r.Assets, asset)
+		r.Stats.Bytes += result.Size
+	}
+	r.Stats.Assets = len(r.Assets)
+	return r
+}
+
+// urlExpiryWarning is how soon download URLs must expire to be flagged
+const urlExpiryWarning = time.Hour
+
+// warnExpiringURLs warns when download URLs expire within urlExpiryWarning,
+// so the caller can fetch the files in time or request a new link
+func warnExpiringURLs(ctx context.Context, assets []GeneratedAsset) {
+	for _, asset := range assets {
+		if asset.URL == "" || asset.ExpiresAt == "" {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339, asset.ExpiresAt)
+		if err != nil {
+			continue
+		}
+		if remaining := time.Until(expiresAt); remaining < urlExpiryWarning {
+			warnings.Add(ctx, "download URLs expire in %s (at %s); download the files soon or use create_share_link for a new link",
+				remaining.Round(time.Minute), asset.ExpiresAt)
+			return
+		}
+	}
+}
+
+// discardStored deletes the files, and their thumbnails, that a cancelled
+// request stored, so abandoned generations do not leave orphaned objects.
+// Deduplicated files were already stored by an earlier request and are kept.
+func (s *Server) discardStored(ctx context.Context, stored []*storage.StorageResult) {
+	if len(stored) == 0 {
+		return
+	}
+	// Detach from cancellation while keeping the request's values
+	deleteCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+	defer cancel()
+	for _, result := range stored {
+		var keys []string
+		for _, file := range []*storage.StorageResult{result, result.Thumbnail} {
+			if file != nil && !file.Deduplicated {
+				keys = append(keys, file.ObjectKey)
+			}
+		}
+		for _, key := range keys {
+			if err := s.storage.Delete(deleteCtx, key); err != nil {
+				log.Printf("Warning: failed to delete %s after cancellation: %v", key, err)
+			} else {
+				log.Printf("Deleted %s after the request was cancelled", key)
+			}
+		}
+	}
+}
+
+// withGenerationResult wraps a generation tool handler to complete its
+// envelope: outputs without a status are reported as completed (or blocked
+// for safety-blocked results), the call duration, the tokens used and the
+// warnings raised during the call are recorded, and with LEGACY_OUTPUT_FIELDS=false the
+// fields the envelope replaces are dropped
+func withGenerationResult[In, Out any](s *Server, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		start := time.Now()
+		ctx, collected := warnings.WithCollector(ctx)
+		ctx, used := usage.WithCollector(ctx)
+		result, output, err := next(ctx, req, input)
+		if err != nil {
+			return result, output, err
+		}
+		if out, ok := any(&output).(generationOutput); ok {
+			envelope := out.generation()
+			if envelope.Status == "" {
+				envelope.Status = "completed"
+				if result != nil && result.IsError {
+					envelope.Status = "blocked"
+				}
+			}
+			envelope.Stats.DurationMS = time.Since(start).Milliseconds()
+			envelope.Stats.Tokens = used.Tokens()
+			warnExpiringURLs(ctx, envelope.Assets)
+			envelope.Warnings = append(envelope.Warnings, collected.List()...)
+			if !s.config.LegacyOutputFields {
+				out.clearLegacyFields()
+			}
+		}
+		return result, output, nil
+	}
+}
+
+func (o *GeminiImageGenerationOutput) clearLegacyFields() {
+	o.SavedFiles, o.DataURIs, o.Thumbnails, o.DownloadURLs, o.ExpiresAt = nil, nil, nil, nil, ""
+	o.ImagesCreated = 0
+}
+
+func (o *GeminiImageEditOutput) clearLegacyFields() {
+	o.SavedFiles, o.DataURIs, o.Thumbnails, o.DownloadURLs, o.ExpiresAt = nil, nil, nil, nil, ""
+	o.EditedImage = ""
+}
+
+func (o *GeminiMultiImageOutput) clearLegacyFields() {
+	o.SavedFiles, o.DataURIs, o.Thumbnails, o.DownloadURLs, o.ExpiresAt = nil, nil, nil, nil, ""
+	o.CombinedImage = ""
+}
+
+func (o *VeoGenerationOutput) clearLegacyFields() {
+	o.SavedFiles, o.DataURIs, o.Thumbnails, o.DownloadURLs, o.ExpiresAt = nil, nil, nil, nil, ""
+	o.VideoURL = ""
+}
+
+// Video analysis stores no files, so it has no legacy fields to drop
+func (o *GeminiVideoAnalysisOutput) clearLegacyFields() {}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"image/color"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Icon Set Generation
+type GenerateIconSetInput struct {
+	Prompt         string   `json:"prompt,omitempty" jsonschema:"description:Description of the icon to generate (e.g., 'a friendly blue robot head, flat design'). Either prompt or image_path is required."`
+	ImagePath      string   `json:"image_path,omitempty" jsonschema:"description:Optional existing image to turn into an icon set instead of generating one. Can be a local file path or an object key returned by another tool or by upload_media."`
+	Model          string   `json:"model,omitempty" jsonschema:"description:Gemini model used when generating from a prompt,default:gemini-3-pro-image-preview"`
+	Sizes          []int    `json:"sizes,omitempty" jsonschema:"description:Pixel sizes to render (16-1024). Defaults to 16, 32, 48, 64, 128, 256, 512, 1024."`
+	PaddingPercent int      `json:"padding_percent,omitempty" jsonschema:"description:Empty margin on each side as a percentage of the icon size (0-25),default:10"`
+	Background     string   `json:"background,omitempty" jsonschema:"description:Icon background: 'transparent' or a hex color such as '#FFFFFF'. Transparent backgrounds are produced by removing the generated background.,default:transparent"`
+	Formats        []string `json:"formats,omitempty" jsonschema:"description:Bundle formats to assemble: 'png' (individual PNGs in a zip), 'ico' (Windows, sizes up to 256), 'icns' (macOS). Defaults to all three."`
+	LinkTTL        string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget  string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type IconSetOutput struct {
+	SourceImage  string            `json:"source_image,omitempty"`
+	Sizes        []int             `json:"sizes"`
+	Background   string            `json:"background"`
+	PreviewFile  string            `json:"preview_file,omitempty"`
+	ICOFile      string            `json:"ico_file,omitempty"`
+	ICNSFile     string            `json:"icns_file,omitempty"`
+	BundleFile   string            `json:"bundle_file,omitempty"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+var defaultIconSizes = []int{16, 32, 48, 64, 128, 256, 512, 1024}
+
+func (s *Server) handleGenerateIconSet(ctx context.Context, req *mcp.CallToolRequest, input GenerateIconSetInput) (*mcp.CallToolResult, IconSetOutput, error) {
+	if input.Prompt == "" && input.ImagePath == "" {
+		return nil, IconSetOutput{}, toolerr.Errorf(toolerr.InvalidInput, "either prompt or image_path is required")
+	}
+
+	// Set defaults
+	model := input.Model
+	if model == "" {
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("generate_icon_set", model); err != nil {
+		return nil, IconSetOutput{}, err
+	}
+
+	sizes := input.Sizes
+	if len(sizes) == 0 {
+		sizes = defaultIconSizes
+	}
+	sizes = append([]int(nil), sizes...)
+	sort.Ints(sizes)
+	for _, size := range sizes {
+		if size < 16 || size > 1024 {
+			return nil, IconSetOutput{}, toolerr.Errorf(toolerr.InvalidInput, "icon sizes must be between 16 and 1024 (got %d)", size)
+		}
+	}
+
+	padding := input.PaddingPercent
+	if padding == 0 {
+		padding = 10
+	}
+	if padding < 0 || padding > 25 {
+		return nil, IconSetOutput{}, toolerr.Errorf(toolerr.InvalidInput, "padding_percent must be between 0 and 25")
+	}
+
+	background := input.Background
+	if background == "" {
+		background = "transparent"
+	}
+	var bg color.Color
+	if background != "transparent" {
+		c, err := imaging.ParseHexColor(background)
+		if err != nil {
+			return nil, IconSetOutput{}, err
+		}
+		bg = c
+	}
+
+	formats := map[string]bool{"png": true, "ico": true, "icns": true}
+	if len(input.Formats) > 0 {
+		formats = map[string]bool{}
+		for _, f := range input.Formats {
+			f = strings.ToLower(f)
+			if f != "png" && f != "ico" && f != "icns" {
+				return nil, IconSetOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported format %q (supported: png, ico, icns)", f)
+			}
+			formats[f] = true
+		}
+	}
+
+	// Obtain the master artwork
+	var masterData []byte
+	if input.ImagePath != "" {
+		log.Printf("Building icon set from %s (sizes: %v)", input.ImagePath, sizes)
+
+		localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+		if err != nil {
+			return nil, IconSetOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+
+		masterData, err = os.ReadFile(localImagePath)
+		if err != nil {
+			return nil, IconSetOutput{}, fmt.Errorf("failed to read input image: %w", err)
+		}
+	} else {
+		log.Printf("Generating icon set with model %s for prompt: %s (sizes: %v)", model, input.Prompt, sizes)
+
+		prompt := fmt.Sprintf("%s. App icon design, single centered symbol, bold simple shapes that stay legible at 16 pixels, no text, isolated subject centered on a plain solid white background, no shadows, no border", input.Prompt)
+		var err error
+		masterData, _, err = s.generateImage(ctx, model, prompt, "1:1", "1K")
+		if err != nil {
+			return nil, IconSetOutput{}, err
+		}
+	}
+
+	if background == "transparent" {
+		var err error
+		masterData, err = imaging.TransparentPNG(masterData, imaging.DefaultBackgroundTolerance)
+		if err != nil {
+			return nil, IconSetOutput{}, err
+		}
+	}
+
+	master, _, err := imaging.Decode(masterData)
+	if err != nil {
+		return nil, IconSetOutput{}, err
+	}
+	master = imaging.TrimTransparent(master)
+
+	// Render every size from the trimmed master
+	var icons []imaging.IconImage
+	for _, size := range sizes {
+		rendered := imaging.PadSquare(master, size, padding, bg)
+		pngData, err := imaging.EncodePNG(rendered)
+		if err != nil {
+			return nil, IconSetOutput{}, fmt.Errorf("failed to encode %dpx icon: %w", size, err)
+		}
+		icons = append(icons, imaging.IconImage{Size: size, PNG: pngData})
+	}
+
+	output := IconSetOutput{
+		SourceImage: input.ImagePath,
+		Sizes:       sizes,
+		Background:  background,
+		Metadata: map[string]string{
+			"padding_percent": fmt.Sprintf("%d", padding),
+		},
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	if input.Prompt != "" {
+		output.Metadata["prompt"] = input.Prompt
+		output.Metadata["model"] = model
+	}
+
+	var contents []mcp.Content
+	store := func(data []byte, mimeType, prefix string) (string, error) {
+		result, err := s.storage.Store(ctx, data, mimeType, prefix)
+		if err != nil {
+			return "", fmt.Errorf("failed to store %s: %w", prefix, err)
+		}
+		log.Printf("Stored %s: %s", prefix, result.Location)
+		output.SavedFiles = append(output.SavedFiles, result.ObjectKey)
+		output.DataURIs = s.addDataURI(output.DataURIs, result, data)
+		output.Thumbnails = addThumbnail(output.Thumbnails, result)
+		if s.storage.IsRemote() {
+			output.DownloadURLs = append(output.DownloadURLs, result.Location)
+			if result.ExpiresAt != nil && output.ExpiresAt == "" {
+				output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+		} else {
+			contents = append(contents, s.mediaContent(data, result)...)
+		}
+		return result.ObjectKey, nil
+	}
+
+	// Largest rendition doubles as the preview
+	if output.PreviewFile, err = store(icons[len(icons)-1].PNG, "image/png", "icon_preview"); err != nil {
+		return nil, IconSetOutput{}, err
+	}
+
+	bundle := map[string][]byte{}
+	if formats["png"] {
+		for _, icon := range icons {
+			bundle[fmt.Sprintf("png/icon_%dx%d.png", icon.Size, icon.Size)] = icon.PNG
+		}
+	}
+	if formats["ico"] {
+		ico, err := imaging.EncodeICO(icons)
+		if err != nil {
+			return nil, IconSetOutput{}, err
+		}
+		bundle["favicon.ico"] = ico
+		if output.ICOFile, err = store(ico, "image/x-icon", "icon_ico"); err != nil {
+			return nil, IconSetOutput{}, err
+		}
+	}
+	if formats["icns"] {
+		icns, err := imaging.EncodeICNS(icons)
+		if err != nil {
+			return nil, IconSetOutput{}, err
+		}
+		bundle["AppIcon.icns"] = icns
+		if output.ICNSFile, err = store(icns, "image/icns", "icon_icns"); err != nil {
+			return nil, IconSetOutput{}, err
+		}
+	}
+
+	zipData, err := buildZip(bundle)
+	if err != nil {
+		return nil, IconSetOutput{}, err
+	}
+	if output.BundleFile, err = store(zipData, "application/zip", "icon_bundle"); err != nil {
+		return nil, IconSetOutput{}, err
+	}
+
+	summary := fmt.Sprintf("Generated icon set with %d sizes (%s).", len(sizes), joinInts(sizes, ", "))
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := summary + " Download URLs:\n"
+		for i, url := range output.DownloadURLs {
+			contentText += fmt.Sprintf("%d. %s\n", i+1, url)
+		}
+		if output.ExpiresAt != "" {
+			contentText += fmt.Sprintf("\nURLs expire at: %s", output.ExpiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, contents...),
+		}
+	}
+
+	return result, output, nil
+}
+
+// buildZip packs the named files into a zip archive with deterministic ordering
+func buildZip(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s to bundle: %w", name, err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, fmt.Errorf("failed to write %s to bundle: %w", name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finalize bundle: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func joinInts(values []int, sep string) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%d", v)
+	}
+	return strings.Join(parts, sep)
+}
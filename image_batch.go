@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Limits for gemini_image_batch
+const (
+	maxBatchPrompts         = 50
+	defaultBatchConcurrency = 4
+	maxBatchConcurrency     = 8
+)
+
+// Batch image generation
+type GeminiImageBatchInput struct {
+	Prompts               []string `json:"prompts" jsonschema:"description:Prompts to generate, one image generation per prompt (1-50). Results are returned in the same order."`
+	Model                 string   `json:"model,omitempty" jsonschema:"description:Image generation model used for every prompt,default:gemini-3-pro-image-preview"`
+	Style                 string   `json:"style,omitempty" jsonschema:"description:Style applied to every prompt, e.g. 'storyboard sketch', 'photorealistic', 'watercolor'"`
+	AspectRatio           string   `json:"aspect_ratio,omitempty" jsonschema:"description:Aspect ratio for every image: '1:1', '3:4', '4:3', '9:16', '16:9'"`
+	ImageSize             string   `json:"image_size,omitempty" jsonschema:"description:Resolution for every image: '1K', '2K' or '4K',default:1K,enum:1K,enum:2K,enum:4K"`
+	Quality               string   `json:"quality,omitempty" jsonschema:"description:Image quality preference: 'high', 'medium', 'draft',default:high"`
+	NegativePrompt        string   `json:"negative_prompt,omitempty" jsonschema:"description:Elements, styles, or artifacts that should not appear in any of the images"`
+	Preset                string   `json:"preset,omitempty" jsonschema:"description:Optional output preset applied to every image (see gemini_image_generation)"`
+	TransparentBackground bool     `json:"transparent_background,omitempty" jsonschema:"description:Produce PNGs with transparent backgrounds,default:false"`
+	OutputFormat          string   `json:"output_format,omitempty" jsonschema:"description:Optional format every image is converted to: 'png', 'jpeg' or 'webp' (see gemini_image_generation)"`
+	CompressionQuality    int      `json:"compression_quality,omitempty" jsonschema:"description:Optional quality for jpeg and webp output, from 1 to 100,default:85"`
+	Concurrency           int      `json:"concurrency,omitempty" jsonschema:"description:Maximum number of prompts generated at the same time (1-8). The server-wide generation limit still applies.,default:4"`
+	OutputDirectory       string   `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the generated images will also be saved."`
+	LinkTTL               string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget         string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+// BatchImageResult is the outcome of one prompt of a batch
+type BatchImageResult struct {
+	Index        int               `json:"index"`
+	Prompt       string            `json:"prompt"`
+	Status       string            `json:"status"` // completed, blocked or failed
+	Error        string            `json:"error,omitempty"`
+	ErrorCode    toolerr.Code      `json:"error_code,omitempty"`
+	Retryable    bool              `json:"retryable,omitempty"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Safety       *safety.Feedback  `json:"safety,omitempty"`
+	Warnings     []string          `json:"warnings,omitempty"`
+}
+
+type GeminiImageBatchOutput struct {
+	Model        string             `json:"model"`
+	Results      []BatchImageResult `json:"results"`
+	Succeeded    int                `json:"succeeded"`
+	Failed       int                `json:"failed"`
+	SavedFiles   []string           `json:"saved_files,omitempty"`
+	DownloadURLs []string           `json:"download_urls,omitempty"`
+	GeneratedAt  string             `json:"generated_at"`
+}
+
+func (s *Server) handleGeminiImageBatch(ctx context.Context, req *mcp.CallToolRequest, input GeminiImageBatchInput) (*mcp.CallToolResult, GeminiImageBatchOutput, error) {
+	if len(input.Prompts) == 0 {
+		return nil, GeminiImageBatchOutput{}, toolerr.Errorf(toolerr.InvalidInput, "prompts is required")
+	}
+	if len(input.Prompts) > maxBatchPrompts {
+		return nil, GeminiImageBatchOutput{}, toolerr.Errorf(toolerr.InvalidInput, "at most %d prompts are supported per batch", maxBatchPrompts)
+	}
+	for i, prompt := range input.Prompts {
+		if strings.TrimSpace(prompt) == "" {
+			return nil, GeminiImageBatchOutput{}, toolerr.Errorf(toolerr.InvalidInput, "prompt %d is empty", i+1)
+		}
+	}
+
+	if _, err := s.resolveImageFormat(input.OutputFormat, input.CompressionQuality, input.TransparentBackground); err != nil {
+		return nil, GeminiImageBatchOutput{}, err
+	}
+
+	concurrency := input.Concurrency
+	if concurrency == 0 {
+		concurrency = defaultBatchConcurrency
+	}
+	if concurrency < 1 || concurrency > maxBatchConcurrency {
+		return nil, GeminiImageBatchOutput{}, toolerr.Errorf(toolerr.InvalidInput, "concurrency must be between 1 and %d", maxBatchConcurrency)
+	}
+	concurrency = min(concurrency, len(input.Prompts))
+
+	model := input.Model
+	if model == "" {
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_image_batch", model); err != nil {
+		return nil, GeminiImageBatchOutput{}, err
+	}
+
+	log.Printf("Generating batch of %d images with model %s (concurrency %d)", len(input.Prompts), model, concurrency)
+
+	results := make([]BatchImageResult, len(input.Prompts))
+	jobs := make(chan int, len(input.Prompts))
+	for i := range input.Prompts {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = s.generateBatchImage(ctx, req, input, model, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	output := GeminiImageBatchOutput{
+		Model:       model,
+		Results:     results,
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	var summary strings.Builder
+	for _, r := range results {
+		if r.Status == "completed" {
+			output.Succeeded++
+		} else {
+			output.Failed++
+		}
+		output.SavedFiles = append(output.SavedFiles, r.SavedFiles...)
+		output.DownloadURLs = append(output.DownloadURLs, r.DownloadURLs...)
+
+		fmt.Fprintf(&summary, "\n%d. [%s] %s", r.Index+1, r.Status, r.Prompt)
+		switch {
+		case r.Error != "":
+			fmt.Fprintf(&summary, "\n   Error: %s", r.Error)
+		case len(r.DownloadURLs) > 0:
+			fmt.Fprintf(&summary, "\n   %s", strings.Join(r.DownloadURLs, "\n   "))
+		case len(r.SavedFiles) > 0:
+			fmt.Fprintf(&summary, "\n   %s", strings.Join(r.SavedFiles, "\n   "))
+		}
+	}
+	log.Printf("Batch finished: %d succeeded, %d failed", output.Succeeded, output.Failed)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("Generated %d of %d images (%d failed)", output.Succeeded, len(results), output.Failed) + summary.String(),
+			},
+		},
+	}, output, nil
+}
+
+// generateBatchImage runs one prompt of a batch through the single-image
+// generation handler, turning failures into a result instead of an error
+func (s *Server) generateBatchImage(ctx context.Context, req *mcp.CallToolRequest, input GeminiImageBatchInput, model string, index int) BatchImageResult {
+	result := BatchImageResult{Index: index, Prompt: input.Prompts[index]}
+	if ctx.Err() != nil {
+		result.Status = "failed"
+		result.Error = ctx.Err().Error()
+		return result
+	}
+
+	// Each prompt reports its own warnings
+	ctx, collected := warnings.WithCollector(ctx)
+	itemInput, err := applyToolDefaults(s, "gemini_image_generation", GeminiImageGenerationInput{
+		Prompt:                input.Prompts[index],
+		Model:                 model,
+		Style:                 input.Style,
+		AspectRatio:           input.AspectRatio,
+		ImageSize:             input.ImageSize,
+		Quality:               input.Quality,
+		NegativePrompt:        input.NegativePrompt,
+		Preset:                input.Preset,
+		TransparentBackground: input.TransparentBackground,
+		OutputFormat:          input.OutputFormat,
+		CompressionQuality:    input.CompressionQuality,
+		OutputDirectory:       input.OutputDirectory,
+	})
+	var toolResult *mcp.CallToolResult
+	var output GeminiImageGenerationOutput
+	if err == nil {
+		toolResult, output, err = s.handleGeminiImageGeneration(ctx, req, itemInput)
+	}
+	result.Warnings = collected.List()
+	switch {
+	case err != nil:
+		log.Printf("Batch prompt %d failed: %v", index+1, err)
+		result.Status = "failed"
+		result.Error = err.Error()
+		classified := toolerr.Classify(err)
+		result.ErrorCode, result.Retryable = classified.Code, classified.Retryable
+	case toolResult != nil && toolResult.IsError:
+		result.Status = "blocked"
+		result.ErrorCode = toolerr.ModelBlocked
+		result.Safety = output.Safety
+		if output.Safety != nil {
+			result.Error = output.Safety.Summary()
+		}
+	default:
+		result.Status = "completed"
+		result.SavedFiles = output.SavedFiles
+		result.DataURIs = output.DataURIs
+		result.Thumbnails = output.Thumbnails
+		result.DownloadURLs = output.DownloadURLs
+		result.ExpiresAt = output.ExpiresAt
+		result.Safety = output.Safety
+	}
+	return result
+}
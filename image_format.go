@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+)
+
+// imageFormat is the format generated images are converted to before they
+// are stored. The zero value keeps images as the model returned them.
+type imageFormat struct {
+	name     string
+	mimeType string
+	quality  int  // JPEG and WebP quality, 1-100
+	explicit bool // quality was given by the caller
+}
+
+// resolveImageFormat validates the output_format and compression_quality
+// inputs of an image generation
+func (s *Server) resolveImageFormat(format string, quality int, transparent bool) (imageFormat, error) {
+	if quality != 0 && (quality < 1 || quality > 100) {
+		return imageFormat{}, toolerr.Errorf(toolerr.InvalidInput, "compression_quality must be between 1 and 100")
+	}
+	if format == "" {
+		if quality != 0 {
+			return imageFormat{}, toolerr.Errorf(toolerr.InvalidInput, "compression_quality requires output_format jpeg or webp")
+		}
+		return imageFormat{}, nil
+	}
+
+	name, mimeType, err := imaging.ParseFormat(format)
+	if err != nil {
+		return imageFormat{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+	f := imageFormat{name: name, mimeType: mimeType, quality: quality, explicit: quality != 0}
+	if f.quality == 0 {
+		f.quality = imaging.DefaultQuality
+	}
+
+	switch name {
+	case imaging.FormatPNG:
+		if f.explicit {
+			return imageFormat{}, toolerr.Errorf(toolerr.InvalidInput, "compression_quality requires output_format jpeg or webp")
+		}
+	case imaging.FormatJPEG:
+		if transparent {
+			return imageFormat{}, toolerr.Errorf(toolerr.InvalidInput, "output_format jpeg cannot keep a transparent background; use png or webp")
+		}
+	case imaging.FormatWebP:
+		if s.media.Backend(media.OpWebPEncode) == media.Unavailable {
+			return imageFormat{}, toolerr.Errorf(toolerr.InvalidInput, "output_format webp requires ffmpeg (set FFMPEG_PATH)")
+		}
+	}
+	return f, nil
+}
+
+// transcodeImage converts a generated image to the requested format. Images
+// already in that format are kept unless a quality was given. On failure
+// the original image is returned unchanged.
+func (s *Server) transcodeImage(ctx context.Context, data []byte, mimeType string, f imageFormat) ([]byte, string) {
+	if f.name == "" || (mimeType == f.mimeType && !f.explicit) {
+		return data, mimeType
+	}
+
+	var converted []byte
+	var err error
+	if f.name == imaging.FormatWebP {
+		converted, err = s.media.EncodeWebP(ctx, data, f.quality)
+	} else {
+		img, _, decodeErr := imaging.Decode(data)
+		switch {
+		case decodeErr != nil:
+			err = decodeErr
+		case f.name == imaging.FormatJPEG:
+			converted, err = imaging.EncodeJPEG(img, f.quality)
+		default:
+			converted, err = imaging.EncodeCompactPNG(img)
+		}
+	}
+	if err != nil {
+		warnings.Add(ctx, "conversion to %s failed, kept the original image: %v", f.name, err)
+		return data, mimeType
+	}
+
+	log.Printf("Converted image %s -> %s (%d -> %d bytes)", mimeType, f.mimeType, len(data), len(converted))
+	return converted, f.mimeType
+}
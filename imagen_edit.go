@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Imagen editing modes
+const (
+	imagenModeEdit             = "edit"
+	imagenModeBackgroundSwap   = "background_swap"
+	imagenModeProductRecontext = "product_recontext"
+)
+
+const (
+	// defaultImagenEditModel serves the edit and background_swap modes,
+	// defaultImagenRecontextModel the product_recontext mode
+	defaultImagenEditModel      = "imagen-3.0-capability-001"
+	defaultImagenRecontextModel = "imagen-product-recontext-preview-06-30"
+
+	// maxProductImages is how many views of one product recontextualization accepts
+	maxProductImages = 3
+)
+
+// Imagen editing
+type ImagenEditInput struct {
+	ImagePath         string   `json:"image_path" jsonschema:"description:Path to the image to edit, or with mode 'product_recontext' the product image. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Prompt            string   `json:"prompt,omitempty" jsonschema:"description:What to change. For 'edit' the whole edit (e.g. 'make it a watercolor painting'), for 'background_swap' the new background (e.g. 'on a marble kitchen counter, morning light'), for 'product_recontext' the optional scene to place the product in. Required except for 'product_recontext'."`
+	Mode              string   `json:"mode,omitempty" jsonschema:"description:Editing mode: 'edit' (mask-free edit of the whole image), 'background_swap' (keep the foreground subject and replace the background, masked automatically) or 'product_recontext' (show a product in a new scene, keeping its appearance),default:edit,enum:edit,enum:background_swap,enum:product_recontext"`
+	ProductImagePaths []string `json:"product_image_paths,omitempty" jsonschema:"description:With mode 'product_recontext', up to 2 further images of the same product from other angles. Object keys or local paths like image_path."`
+	Model             string   `json:"model,omitempty" jsonschema:"description:Imagen model to use. Defaults to 'imagen-3.0-capability-001' for 'edit' and 'background_swap' and 'imagen-product-recontext-preview-06-30' for 'product_recontext'."`
+	NegativePrompt    string   `json:"negative_prompt,omitempty" jsonschema:"description:Elements that should NOT appear in the result. Not supported with 'product_recontext'."`
+	AspectRatio       string   `json:"aspect_ratio,omitempty" jsonschema:"description:Aspect ratio of the result for 'edit' and 'background_swap': '1:1', '3:4', '4:3', '9:16' or '16:9'. Defaults to that of the input image."`
+	MaskDilation      float64  `json:"mask_dilation,omitempty" jsonschema:"description:With mode 'background_swap', how far (0-1, as a fraction of the image) the automatic foreground mask is grown, to blend the subject's edges into the new background,default:0"`
+	SafetyLevel       string   `json:"safety_level,omitempty" jsonschema:"description:Content safety level: 'strict', 'moderate', 'permissive'. Controls content filtering.,default:moderate"`
+	WebhookURL        string   `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL           string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget     string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename          string   `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage  string   `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+}
+
+type ImagenEditOutput struct {
+	GenerationResult
+
+	SourceImage string           `json:"source_image"`
+	Mode        string           `json:"mode"`
+	Model       string           `json:"model"`
+	Safety      *safety.Feedback `json:"safety,omitempty"`
+	GeneratedAt string           `json:"generated_at"`
+}
+
+// Imagen edits return their files in the envelope only
+func (o *ImagenEditOutput) clearLegacyFields() {}
+
+func (s *Server) handleImagenEdit(ctx context.Context, req *mcp.CallToolRequest, input ImagenEditInput) (*mcp.CallToolResult, ImagenEditOutput, error) {
+	if input.ImagePath == "" {
+		return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+	mode := input.Mode
+	if mode == "" {
+		mode = imagenModeEdit
+	}
+	model := input.Model
+	switch mode {
+	case imagenModeEdit, imagenModeBackgroundSwap:
+		if strings.TrimSpace(input.Prompt) == "" {
+			return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "prompt is required for mode '%s'", mode)
+		}
+		if len(input.ProductImagePaths) > 0 {
+			return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "product_image_paths requires mode 'product_recontext'")
+		}
+		if model == "" {
+			model = defaultImagenEditModel
+		}
+	case imagenModeProductRecontext:
+		if len(input.ProductImagePaths) > maxProductImages-1 {
+			return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "product_image_paths accepts at most %d images", maxProductImages-1)
+		}
+		if input.NegativePrompt != "" || input.AspectRatio != "" {
+			return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "negative_prompt and aspect_ratio are not supported with mode 'product_recontext'")
+		}
+		if model == "" {
+			model = defaultImagenRecontextModel
+		}
+	default:
+		return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "mode must be 'edit', 'background_swap' or 'product_recontext'")
+	}
+	if input.MaskDilation != 0 && (mode != imagenModeBackgroundSwap || input.MaskDilation < 0 || input.MaskDilation > 1) {
+		return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "mask_dilation must be between 0 and 1 and requires mode 'background_swap'")
+	}
+	if err := s.allowlist.Check("imagen_edit", model); err != nil {
+		return nil, ImagenEditOutput{}, err
+	}
+	if s.vertex == nil {
+		return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.InvalidInput,
+			"imagen_edit requires Vertex AI: set GOOGLE_PROJECT_ID and provide Application Default Credentials")
+	}
+
+	log.Printf("Imagen %s of %s with model %s: %s", mode, input.ImagePath, model, input.Prompt)
+
+	source, err := s.readImagenImage(ctx, input.ImagePath)
+	if err != nil {
+		return nil, ImagenEditOutput{}, err
+	}
+
+	// Wait for a free image generation slot
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, ImagenEditOutput{}, err
+	}
+	defer release()
+
+	var generated []*genai.GeneratedImage
+	if mode == imagenModeProductRecontext {
+		products := []*genai.ProductImage{{ProductImage: source}}
+		for _, path := range input.ProductImagePaths {
+			view, err := s.readImagenImage(ctx, path)
+			if err != nil {
+				return nil, ImagenEditOutput{}, err
+			}
+			products = append(products, &genai.ProductImage{ProductImage: view})
+		}
+		response, err := s.vertex.Models.RecontextImage(ctx, model,
+			&genai.RecontextImageSource{Prompt: input.Prompt, ProductImages: products},
+			&genai.RecontextImageConfig{
+				NumberOfImages:    genai.Ptr[int32](1),
+				SafetyFilterLevel: imagenSafetyFilter(input.SafetyLevel),
+				OutputMIMEType:    "image/png",
+			})
+		if err != nil {
+			return nil, ImagenEditOutput{}, fmt.Errorf("error recontextualizing product: %w", err)
+		}
+		generated = response.GeneratedImages
+	} else {
+		references := []genai.ReferenceImage{genai.NewRawReferenceImage(source, 0)}
+		editMode := genai.EditModeDefault
+		if mode == imagenModeBackgroundSwap {
+			editMode = genai.EditModeBgswap
+			mask := &genai.MaskReferenceConfig{MaskMode: genai.MaskReferenceModeMaskModeBackground}
+			if input.MaskDilation > 0 {
+				mask.MaskDilation = genai.Ptr(float32(input.MaskDilation))
+			}
+			references = append(references, genai.NewMaskReferenceImage(nil, 1, mask))
+		}
+		response, err := s.vertex.Models.EditImage(ctx, model, input.Prompt, references, &genai.EditImageConfig{
+			EditMode:          editMode,
+			NumberOfImages:    1,
+			NegativePrompt:    input.NegativePrompt,
+			AspectRatio:       input.AspectRatio,
+			SafetyFilterLevel: imagenSafetyFilter(input.SafetyLevel),
+			IncludeRAIReason:  true,
+			OutputMIMEType:    "image/png",
+		})
+		if err != nil {
+			return nil, ImagenEditOutput{}, fmt.Errorf("error editing image: %w", err)
+		}
+		generated = response.GeneratedImages
+	}
+
+	timestamp := time.Now().Format("20060102_150405")
+	safetyFeedback := safety.FromImagesResponse(&genai.GenerateImagesResponse{GeneratedImages: generated})
+
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var contents []mcp.Content
+	for _, image := range generated {
+		if image == nil || image.Image == nil || len(image.Image.ImageBytes) == 0 {
+			continue
+		}
+		data := image.Image.ImageBytes
+		mimeType := image.Image.MIMEType
+		if mimeType == "" {
+			mimeType = "image/png"
+		}
+		result, err := s.storage.Store(ctx, data, mimeType, "imagen_edit")
+		if err != nil {
+			return nil, ImagenEditOutput{}, toolerr.Wrap(toolerr.StorageError, fmt.Errorf("failed to store edited image: %w", err))
+		}
+		log.Printf("Stored Imagen edit: %s", result.Location)
+		stored = append(stored, result)
+		dataURIs = s.addDataURI(dataURIs, result, data)
+		if !s.storage.IsRemote() {
+			contents = append(contents, s.mediaContent(data, result)...)
+		}
+	}
+	if len(stored) == 0 {
+		if safetyFeedback != nil {
+			return safetyBlockedResult(ctx, safetyFeedback), ImagenEditOutput{Mode: mode, Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+		}
+		return nil, ImagenEditOutput{}, toolerr.Errorf(toolerr.Upstream, "no edited image was generated")
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, ImagenEditOutput{}, ctx.Err()
+	}
+
+	metadata := map[string]string{
+		"source_image": input.ImagePath,
+		"mode":         mode,
+		"prompt":       input.Prompt,
+	}
+	if input.NegativePrompt != "" {
+		metadata["negative_prompt"] = input.NegativePrompt
+	}
+	if len(input.ProductImagePaths) > 0 {
+		metadata["product_images"] = strings.Join(input.ProductImagePaths, ", ")
+	}
+
+	output := ImagenEditOutput{
+		GenerationResult: s.generationResult("completed", stored, dataURIs, metadata),
+		SourceImage:      input.ImagePath,
+		Mode:             mode,
+		Model:            model,
+		Safety:           safetyFeedback,
+		GeneratedAt:      timestamp,
+	}
+
+	summary := fmt.Sprintf("Edited image with Imagen (%s)", mode)
+	if s.storage.IsRemote() {
+		text := summary + ". Download URL:\n" + stored[0].Location
+		if stored[0].ExpiresAt != nil {
+			text += fmt.Sprintf("\n\nURL expires at: %s", stored[0].ExpiresAt.Format(time.RFC3339))
+		}
+		contents = []mcp.Content{&mcp.TextContent{Text: text}}
+	} else {
+		contents = append([]mcp.Content{&mcp.TextContent{Text: summary + ": " + stored[0].ObjectKey}}, contents...)
+	}
+	return &mcp.CallToolResult{Content: contents}, output, nil
+}
+
+// readImagenImage reads an input image of imagen_edit by local path or
+// object key
+func (s *Server) readImagenImage(ctx context.Context, path string) (*genai.Image, error) {
+	localPath, cleanup, err := s.resolveInputPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input image %s: %w", path, err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input image %s: %w", path, err)
+	}
+	mimeType, err := imaging.DetectInputMIME(data)
+	if err != nil {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "invalid input image %s: %w", path, err)
+	}
+	return &genai.Image{ImageBytes: data, MIMEType: mimeType}, nil
+}
+
+// imagenSafetyFilter maps a safety_level to the Imagen filter level; the
+// default is left to the API
+func imagenSafetyFilter(level string) genai.SafetyFilterLevel {
+	switch level {
+	case "strict":
+		return genai.SafetyFilterLevelBlockLowAndAbove
+	case "permissive":
+		return genai.SafetyFilterLevelBlockOnlyHigh
+	case "moderate":
+		return genai.SafetyFilterLevelBlockMediumAndAbove
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// PCM is signed 16-bit little-endian audio at a given sample rate. Samples
+// of multi-channel audio are interleaved.
+type PCM struct {
+	Data       []byte
+	SampleRate int
+	Channels   int // 0 is mono
+}
+
+// DecodeWAV extracts 16-bit PCM from a RIFF/WAVE file, downmixing
+// multi-channel audio to mono
+func DecodeWAV(data []byte) (*PCM, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+
+	var (
+		format     uint16
+		channels   uint16
+		sampleRate uint32
+		bits       uint16
+		samples    []byte
+		haveFmt    bool
+	)
+	for off := 12; off+8 <= len(data); {
+		id := string(data[off : off+4])
+		size := int(binary.LittleEndian.Uint32(data[off+4 : off+8]))
+		body := data[off+8:]
+		if size > len(body) {
+			size = len(body) // tolerate truncated streams
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid WAV fmt chunk")
+			}
+			format = binary.LittleEndian.Uint16(body[0:2])
+			channels = binary.LittleEndian.Uint16(body[2:4])
+			sampleRate = binary.LittleEndian.Uint32(body[4:8])
+			bits = binary.LittleEndian.Uint16(body[14:16])
+			haveFmt = true
+		case "data":
+			samples = body
+		}
+		off += 8 + size + size%2 // chunks are word aligned
+	}
+
+	if !haveFmt || samples == nil {
+		return nil, fmt.Errorf("WAV file is missing fmt or data chunk")
+	}
+	// 1 = PCM, 0xFFFE = WAVE_FORMAT_EXTENSIBLE (assumed PCM sub-format)
+	if (format != 1 && format != 0xFFFE) || bits != 16 {
+		return nil, fmt.Errorf("unsupported WAV encoding (need 16-bit PCM, got format %d with %d bits)", format, bits)
+	}
+	if channels == 0 || sampleRate == 0 {
+		return nil, fmt.Errorf("invalid WAV header")
+	}
+
+	if channels == 1 {
+		return &PCM{Data: samples, SampleRate: int(sampleRate)}, nil
+	}
+
+	frame := int(channels) * 2
+	mono := make([]byte, 0, len(samples)/int(channels))
+	for i := 0; i+frame <= len(samples); i += frame {
+		var sum int
+		for c := 0; c < int(channels); c++ {
+			sum += int(int16(binary.LittleEndian.Uint16(samples[i+2*c:])))
+		}
+		mono = binary.LittleEndian.AppendUint16(mono, uint16(int16(sum/int(channels))))
+	}
+	return &PCM{Data: mono, SampleRate: int(sampleRate)}, nil
+}
+
+// EncodeWAV wraps 16-bit PCM in a RIFF/WAVE container
+func EncodeWAV(pcm *PCM) []byte {
+	channels := pcm.channels()
+	var buf bytes.Buffer
+	buf.Grow(44 + len(pcm.Data))
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+len(pcm.Data)))
+	buf.WriteString("WAVE")
+
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16))
+	binary.Write(&buf, binary.LittleEndian, uint16(1)) // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(pcm.SampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(pcm.SampleRate*2*channels)) // byte rate
+	binary.Write(&buf, binary.LittleEndian, uint16(2*channels))                // block align
+	binary.Write(&buf, binary.LittleEndian, uint16(16))                        // bits per sample
+
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(len(pcm.Data)))
+	buf.Write(pcm.Data)
+	return buf.Bytes()
+}
+
+// MIMEType returns the Live API MIME type for the PCM stream
+func (p *PCM) MIMEType() string {
+	return fmt.Sprintf("audio/pcm;rate=%d", p.SampleRate)
+}
+
+// Duration returns the length of the audio in seconds
+func (p *PCM) Duration() float64 {
+	if p.SampleRate == 0 {
+		return 0
+	}
+	return float64(len(p.Data)/2/p.channels()) / float64(p.SampleRate)
+}
+
+func (p *PCM) channels() int {
+	return max(p.Channels, 1)
+}
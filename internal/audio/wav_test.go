@@ -0,0 +1,70 @@
+package audio
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	pcm := &PCM{SampleRate: 16000}
+	for i := 0; i < 160; i++ {
+		pcm.Data = binary.LittleEndian.AppendUint16(pcm.Data, uint16(int16(i*100-8000)))
+	}
+
+	decoded, err := DecodeWAV(EncodeWAV(pcm))
+	if err != nil {
+		t.Fatalf("DecodeWAV: %v", err)
+	}
+	if decoded.SampleRate != 16000 || string(decoded.Data) != string(pcm.Data) {
+		t.Errorf("round trip mismatch: rate %d, %d bytes", decoded.SampleRate, len(decoded.Data))
+	}
+	if d := decoded.Duration(); d != 0.01 {
+		t.Errorf("expected 0.01s duration, got %v", d)
+	}
+	if decoded.MIMEType() != "audio/pcm;rate=16000" {
+		t.Errorf("unexpected MIME type %q", decoded.MIMEType())
+	}
+}
+
+func TestDecodeWAVDownmixesStereo(t *testing.T) {
+	mono := EncodeWAV(&PCM{SampleRate: 8000})
+	// Patch the header to stereo and append two frames: (100, 300) and (-200, -400)
+	binary.LittleEndian.PutUint16(mono[22:24], 2)
+	var samples []byte
+	for _, v := range []int16{100, 300, -200, -400} {
+		samples = binary.LittleEndian.AppendUint16(samples, uint16(v))
+	}
+	binary.LittleEndian.PutUint32(mono[40:44], uint32(len(samples)))
+	stereo := append(mono, samples...)
+
+	decoded, err := DecodeWAV(stereo)
+	if err != nil {
+		t.Fatalf("DecodeWAV: %v", err)
+	}
+	if len(decoded.Data) != 4 {
+		t.Fatalf("expected 2 mono samples, got %d bytes", len(decoded.Data))
+	}
+	if a, b := int16(binary.LittleEndian.Uint16(decoded.Data)), int16(binary.LittleEndian.Uint16(decoded.Data[2:])); a != 200 || b != -300 {
+		t.Errorf("expected averaged samples 200, -300; got %d, %d", a, b)
+	}
+}
+
+func TestDecodeWAVRejectsNonWAV(t *testing.T) {
+	if _, err := DecodeWAV([]byte("ID3\x03not a wav file")); err == nil {
+		t.Error("expected error for non-WAV input")
+	}
+}
+
+func TestEncodeWAVStereo(t *testing.T) {
+	pcm := &PCM{Data: make([]byte, 48000*2*2), SampleRate: 48000, Channels: 2}
+	wav := EncodeWAV(pcm)
+	if channels := binary.LittleEndian.Uint16(wav[22:24]); channels != 2 {
+		t.Errorf("expected 2 channels in the header, got %d", channels)
+	}
+	if byteRate := binary.LittleEndian.Uint32(wav[28:32]); byteRate != 48000*4 {
+		t.Errorf("expected byte rate %d, got %d", 48000*4, byteRate)
+	}
+	if d := pcm.Duration(); d != 1 {
+		t.Errorf("expected 1s duration, got %v", d)
+	}
+}
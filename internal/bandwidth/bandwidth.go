@@ -0,0 +1,154 @@
+// Package bandwidth caps the throughput of large transfers, such as video
+// downloads, S3 uploads and served files, so that one transfer cannot
+// saturate the host's network link. A Limiter is shared by all transfers of
+// a kind, which split its rate between them.
+package bandwidth
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// chunkSize is the most a transfer moves before waiting for its share
+const chunkSize = 32 << 10
+
+// Limiter is a token bucket allowing a number of bytes per second, with
+// bursts of up to one second's worth. A nil *Limiter allows any rate.
+type Limiter struct {
+	rate  float64 // Bytes per second
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64 // Bytes that may be sent now; negative while transfers wait
+	last   time.Time
+}
+
+// New returns a limiter allowing bytesPerSecond, or nil if bytesPerSecond is
+// not positive
+func New(bytesPerSecond int) *Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	rate := float64(bytesPerSecond)
+	return &Limiter{rate: rate, burst: rate, tokens: rate, last: time.Now()}
+}
+
+// WaitN blocks until n more bytes may be transferred or ctx is done
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	if l == nil || n <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n) // Reserved even if ctx ends, keeping the rate for others
+	delay := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Reader returns r limited to the rate of l, or r itself if l is nil
+func (l *Limiter) Reader(ctx context.Context, r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, l: l}
+}
+
+type reader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+	n, err := r.r.Read(p)
+	if waitErr := r.l.WaitN(r.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+	return n, err
+}
+
+// readCloser limits an HTTP body while keeping its Close
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// ResponseWriter returns w with the body limited to the rate of l, or w
+// itself if l is nil
+func (l *Limiter) ResponseWriter(ctx context.Context, w http.ResponseWriter) http.ResponseWriter {
+	if l == nil {
+		return w
+	}
+	return &responseWriter{ResponseWriter: w, ctx: ctx, l: l}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+	l   *Limiter
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), chunkSize)]
+		if err := w.l.WaitN(w.ctx, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.ResponseWriter.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Transport limits the response bodies of next to the rate of download and
+// its request bodies to the rate of upload. Either may be nil.
+func Transport(next http.RoundTripper, download, upload *Limiter) http.RoundTripper {
+	if download == nil && upload == nil {
+		return next
+	}
+	return &transport{next: next, download: download, upload: upload}
+}
+
+type transport struct {
+	next     http.RoundTripper
+	download *Limiter
+	upload   *Limiter
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.upload != nil && req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = readCloser{Reader: t.upload.Reader(req.Context(), req.Body), Closer: req.Body}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || t.download == nil {
+		return resp, err
+	}
+	resp.Body = readCloser{Reader: t.download.Reader(req.Context(), resp.Body), Closer: resp.Body}
+	return resp, nil
+}
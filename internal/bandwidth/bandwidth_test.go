@@ -0,0 +1,81 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestReaderRate(t *testing.T) {
+	l := New(20000)
+	start := time.Now()
+	// The first second's worth is a burst; the rest takes about half a second
+	n, err := io.Copy(io.Discard, l.Reader(context.Background(), bytes.NewReader(make([]byte, 30000))))
+	if err != nil || n != 30000 {
+		t.Fatalf("Copy = %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("30000 bytes at 20000 B/s took %v, want about 500ms", elapsed)
+	}
+}
+
+func TestReaderCanceled(t *testing.T) {
+	l := New(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := io.Copy(io.Discard, l.Reader(ctx, bytes.NewReader(make([]byte, 10000)))); err != context.DeadlineExceeded {
+		t.Errorf("Copy = %v, want the context's error", err)
+	}
+}
+
+func TestNilLimiter(t *testing.T) {
+	var l *Limiter = New(0)
+	r := bytes.NewReader(nil)
+	if l.Reader(context.Background(), r) != io.Reader(r) {
+		t.Error("nil limiter wrapped the reader")
+	}
+	if Transport(http.DefaultTransport, nil, nil) != http.DefaultTransport {
+		t.Error("Transport without limiters wrapped the transport")
+	}
+}
+
+func TestTransportLimitsBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write(make([]byte, 30000))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport, New(20000), nil)}
+	start := time.Now()
+	resp, err := client.Post(server.URL, "application/octet-stream", bytes.NewReader([]byte("x")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if n, _ := io.Copy(io.Discard, resp.Body); n != 30000 {
+		t.Fatalf("read %d bytes, want 30000", n)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("download took %v, want it limited to about 500ms", elapsed)
+	}
+}
+
+func TestResponseWriterRate(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	w := New(20000).ResponseWriter(context.Background(), recorder)
+	start := time.Now()
+	if n, err := w.Write(make([]byte, 30000)); err != nil || n != 30000 {
+		t.Fatalf("Write = %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("writing took %v, want it limited to about 500ms", elapsed)
+	}
+	if recorder.Body.Len() != 30000 {
+		t.Errorf("wrote %d bytes, want 30000", recorder.Body.Len())
+	}
+}
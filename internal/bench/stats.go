@@ -0,0 +1,60 @@
+package bench
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Summary describes the latency distribution of a set of timed operations
+type Summary struct {
+	Count      int           // Successful operations
+	Errors     int           // Failed operations
+	Min        time.Duration // Fastest successful operation
+	Mean       time.Duration // Average successful operation
+	P50        time.Duration // Median latency
+	P95        time.Duration // 95th percentile latency
+	Max        time.Duration // Slowest successful operation
+	Throughput float64       // Successful operations per second of wall time
+}
+
+// Summarize computes latency percentiles and throughput for the successful
+// operations in latencies, which completed over wall clock time.
+func Summarize(latencies []time.Duration, errors int, wall time.Duration) Summary {
+	s := Summary{Count: len(latencies), Errors: errors}
+	if len(latencies) == 0 {
+		return s
+	}
+
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	s.Mean = total / time.Duration(len(sorted))
+	s.P50 = Percentile(sorted, 50)
+	s.P95 = Percentile(sorted, 95)
+	if wall > 0 {
+		s.Throughput = float64(len(sorted)) / wall.Seconds()
+	}
+	return s
+}
+
+// Percentile returns the p-th percentile (0-100) of sorted latencies using
+// linear interpolation between the closest ranks
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(rank)
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := rank - float64(lo)
+	return sorted[lo] + time.Duration(math.Round(frac*float64(sorted[lo+1]-sorted[lo])))
+}
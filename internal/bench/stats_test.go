@@ -0,0 +1,40 @@
+package bench
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	var latencies []time.Duration
+	for i := 10; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+
+	s := Summarize(latencies, 2, 5*time.Second)
+	if s.Count != 10 || s.Errors != 2 {
+		t.Fatalf("unexpected counts: %+v", s)
+	}
+	if s.Min != time.Millisecond || s.Max != 10*time.Millisecond {
+		t.Errorf("unexpected min/max: %v/%v", s.Min, s.Max)
+	}
+	if s.Mean != 5500*time.Microsecond {
+		t.Errorf("expected mean 5.5ms, got %v", s.Mean)
+	}
+	if s.P50 != 5500*time.Microsecond {
+		t.Errorf("expected p50 5.5ms, got %v", s.P50)
+	}
+	if s.P95 != 9550*time.Microsecond {
+		t.Errorf("expected p95 9.55ms, got %v", s.P95)
+	}
+	if s.Throughput != 2 {
+		t.Errorf("expected 2 ops/s, got %v", s.Throughput)
+	}
+}
+
+func TestSummarizeEmpty(t *testing.T) {
+	s := Summarize(nil, 3, time.Second)
+	if s.Count != 0 || s.Errors != 3 || s.P95 != 0 {
+		t.Errorf("unexpected summary: %+v", s)
+	}
+}
@@ -0,0 +1,182 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/storage"
+)
+
+// Config controls which failures are injected and how often
+type Config struct {
+	ErrorRate    float64       // Probability an upstream API call fails with a 429/500/503
+	PollDropRate float64       // Probability an operation status poll fails at the network level
+	StorageDelay time.Duration // Maximum random delay added to each storage call
+	Seed         int64         // Random seed (0 = time based)
+}
+
+// ErrDroppedPoll is returned for operation polls dropped by the fault injector
+var ErrDroppedPoll = errors.New("chaos: operation poll dropped")
+
+// Injector decides which calls fail. It is safe for concurrent use.
+type Injector struct {
+	cfg Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// New creates an injector for cfg
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (i *Injector) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < p
+}
+
+func (i *Injector) pick(n int) int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Intn(n)
+}
+
+func (i *Injector) delay() time.Duration {
+	if i.cfg.StorageDelay <= 0 {
+		return 0
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return time.Duration(i.rng.Int63n(int64(i.cfg.StorageDelay)))
+}
+
+// upstreamFailures are the API errors clients see most often in production
+var upstreamFailures = []struct {
+	status int
+	body   string
+}{
+	{http.StatusTooManyRequests, `{"error":{"code":429,"message":"Resource has been exhausted (e.g. check quota). [injected by chaos mode]","status":"RESOURCE_EXHAUSTED"}}`},
+	{http.StatusInternalServerError, `{"error":{"code":500,"message":"An internal error has occurred. [injected by chaos mode]","status":"INTERNAL"}}`},
+	{http.StatusServiceUnavailable, `{"error":{"code":503,"message":"The model is overloaded. Please try again later. [injected by chaos mode]","status":"UNAVAILABLE"}}`},
+}
+
+// Transport wraps next so that upstream calls fail at the configured rates.
+// Operation polls (GET requests for long-running operations) are dropped at
+// PollDropRate; all other calls fail with a realistic API error at ErrorRate.
+func (i *Injector) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Method == http.MethodGet && strings.Contains(req.URL.Path, "/operations/") {
+			if i.roll(i.cfg.PollDropRate) {
+				log.Printf("Chaos: dropping operation poll %s", req.URL.Path)
+				return nil, ErrDroppedPoll
+			}
+			return next.RoundTrip(req)
+		}
+
+		if i.roll(i.cfg.ErrorRate) {
+			failure := upstreamFailures[i.pick(len(upstreamFailures))]
+			log.Printf("Chaos: failing %s %s with status %d", req.Method, req.URL.Path, failure.status)
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return &http.Response{
+				Status:     http.StatusText(failure.status),
+				StatusCode: failure.status,
+				Proto:      "HTTP/1.1",
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Content-Type": []string{"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(failure.body)),
+				Request:    req,
+			}, nil
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// Storage wraps a storage backend so that every call is delayed by a random
+// amount up to StorageDelay
+func (i *Injector) Storage(next storage.Storage) storage.Storage {
+	if i.cfg.StorageDelay <= 0 {
+		return next
+	}
+	return &slowStorage{Storage: next, injector: i}
+}
+
+type slowStorage struct {
+	storage.Storage
+	injector *Injector
+}
+
+func (s *slowStorage) wait(ctx context.Context) error {
+	timer := time.NewTimer(s.injector.delay())
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *slowStorage) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*storage.StorageResult, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Storage.Store(ctx, data, mimeType, prefix)
+}
+
+func (s *slowStorage) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
+	if err := s.wait(ctx); err != nil {
+		return "", nil, err
+	}
+	return s.Storage.Retrieve(ctx, objectKey)
+}
+
+func (s *slowStorage) Delete(ctx context.Context, objectKey string) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	return s.Storage.Delete(ctx, objectKey)
+}
+
+func (s *slowStorage) Move(ctx context.Context, objectKey, newKey string) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	return s.Storage.Move(ctx, objectKey, newKey)
+}
+
+func (s *slowStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	return s.Storage.List(ctx, prefix)
+}
+
+func (s *slowStorage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	if err := s.wait(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+	return s.Storage.ShareLink(ctx, objectKey, ttl)
+}
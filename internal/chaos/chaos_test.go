@@ -0,0 +1,42 @@
+package chaos
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransportInjectsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	// Always fail generation calls and drop polls
+	client := &http.Client{Transport: New(Config{ErrorRate: 1, PollDropRate: 1, Seed: 1}).Transport(http.DefaultTransport)}
+
+	resp, err := client.Post(srv.URL+"/v1beta/models/gemini:generateContent", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 429 {
+		t.Errorf("expected injected API error, got status %d", resp.StatusCode)
+	}
+
+	if _, err := client.Get(srv.URL + "/v1beta/models/veo/operations/abc"); !errors.Is(err, ErrDroppedPoll) {
+		t.Errorf("expected dropped poll, got %v", err)
+	}
+
+	// Zero rates pass everything through
+	client = &http.Client{Transport: New(Config{Seed: 1}).Transport(http.DefaultTransport)}
+	resp, err = client.Get(srv.URL + "/v1beta/models/veo/operations/abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected pass-through, got status %d", resp.StatusCode)
+	}
+}
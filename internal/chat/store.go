@@ -0,0 +1,366 @@
+// Package chat keeps the state of multi-turn Gemini sessions - conversation
+// history and image revision chains - in memory, expiring idle sessions and
+// optionally persisting them as JSON files so they survive restarts. The
+// prompts of persisted sessions can be encrypted with a fieldcrypt.Keyring.
+package chat
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/fieldcrypt"
+
+	"google.golang.org/genai"
+)
+
+// Session is one conversation. Callers hold its lock for the duration of a
+// turn so that concurrent messages to the same session are applied in order.
+type Session struct {
+	sync.Mutex `json:"-"`
+
+	ID                string           `json:"id"`
+	Model             string           `json:"model"`
+	SystemInstruction string           `json:"system_instruction,omitempty"`
+	History           []*genai.Content `json:"history"`
+	Turns             int              `json:"turns"`
+	Revisions         []Revision       `json:"revisions,omitempty"`
+	Created           time.Time        `json:"created"`
+	Updated           time.Time        `json:"updated"`
+}
+
+// Revision is one step of an image revision chain
+type Revision struct {
+	Index     int       `json:"index"`  // 1-based position in the chain
+	Parent    int       `json:"parent"` // Revision this one was derived from (0 for the first)
+	Prompt    string    `json:"prompt"`
+	ObjectKey string    `json:"object_key"`
+	Created   time.Time `json:"created"`
+}
+
+// AddRevision appends a revision derived from parent and returns it
+func (s *Session) AddRevision(parent int, prompt, objectKey string) Revision {
+	rev := Revision{
+		Index:     len(s.Revisions) + 1,
+		Parent:    parent,
+		Prompt:    prompt,
+		ObjectKey: objectKey,
+		Created:   time.Now(),
+	}
+	s.Revisions = append(s.Revisions, rev)
+	return rev
+}
+
+// Append adds contents to the history, then drops the oldest exchanges so that
+// at most maxContents remain (0 for no limit). The history always starts with
+// a user turn.
+func (s *Session) Append(maxContents int, contents ...*genai.Content) {
+	s.History = append(s.History, contents...)
+	if maxContents <= 0 || len(s.History) <= maxContents {
+		return
+	}
+	start := len(s.History) - maxContents
+	for start < len(s.History) && s.History[start].Role != genai.RoleUser {
+		start++
+	}
+	s.History = append([]*genai.Content(nil), s.History[start:]...)
+}
+
+// Store tracks chat sessions by ID
+type Store struct {
+	mu          sync.Mutex
+	sessions    map[string]*Session
+	ttl         time.Duration
+	dir         string // Persistence directory; empty keeps sessions in memory only
+	maxSessions int
+	keys        *fieldcrypt.Keyring // Encrypts the prompts of persisted sessions; nil stores them in plaintext
+}
+
+// sealedFields are the session fields holding prompts and responses, which
+// are persisted encrypted as one "sealed" field when a keyring is configured
+var sealedFields = []string{"system_instruction", "history", "revisions"}
+
+// NewStore creates a session store whose sessions expire after ttl without
+// use. If dir is set, sessions are saved there and reloaded on startup, with
+// their prompts and history encrypted if keys is set. When maxSessions is
+// reached the least recently used session is evicted. Expired sessions are
+// removed while Run is running.
+func NewStore(ttl time.Duration, dir string, maxSessions int, keys *fieldcrypt.Keyring) (*Store, error) {
+	st := &Store{
+		sessions:    make(map[string]*Session),
+		ttl:         ttl,
+		dir:         dir,
+		maxSessions: maxSessions,
+		keys:        keys,
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("failed to create chat session directory: %w", err)
+		}
+		if err := st.load(); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// TTL returns how long sessions are kept without use
+func (st *Store) TTL() time.Duration {
+	return st.ttl
+}
+
+// Create starts a new, empty session
+func (st *Store) Create(model, systemInstruction string) (*Session, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	now := time.Now()
+	sess := &Session{
+		ID:                "chat_" + hex.EncodeToString(b),
+		Model:             model,
+		SystemInstruction: systemInstruction,
+		Created:           now,
+		Updated:           now,
+	}
+
+	st.mu.Lock()
+	var evicted string
+	if st.maxSessions > 0 && len(st.sessions) >= st.maxSessions {
+		for id, s := range st.sessions {
+			if evicted == "" || s.Updated.Before(st.sessions[evicted].Updated) {
+				evicted = id
+			}
+		}
+		delete(st.sessions, evicted)
+	}
+	st.sessions[sess.ID] = sess
+	st.mu.Unlock()
+
+	if evicted != "" {
+		log.Printf("Evicted chat session %s (limit of %d sessions reached)", evicted, st.maxSessions)
+		st.removeFile(evicted)
+	}
+	return sess, nil
+}
+
+// Get returns a session that has not expired
+func (st *Store) Get(id string) (*Session, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	sess, ok := st.sessions[id]
+	if !ok || time.Since(sess.Updated) > st.ttl {
+		return nil, fmt.Errorf("chat session %q not found (it may have expired after %v without use)", id, st.ttl)
+	}
+	return sess, nil
+}
+
+// Save marks a session as used and persists it. The caller must hold the
+// session's lock.
+func (st *Store) Save(sess *Session) error {
+	st.mu.Lock()
+	sess.Updated = time.Now()
+	st.mu.Unlock()
+
+	if st.dir == "" {
+		return nil
+	}
+	data, err := st.encode(sess)
+	if err != nil {
+		return fmt.Errorf("failed to encode chat session: %w", err)
+	}
+	tmp, err := os.CreateTemp(st.dir, sess.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save chat session: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), st.path(sess.ID))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save chat session: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a session, reporting whether it existed
+func (st *Store) Delete(id string) bool {
+	st.mu.Lock()
+	_, ok := st.sessions[id]
+	delete(st.sessions, id)
+	st.mu.Unlock()
+
+	if ok {
+		st.removeFile(id)
+	}
+	return ok
+}
+
+// ExpiresAt returns when a session expires if it is not used again
+func (st *Store) ExpiresAt(sess *Session) time.Time {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return sess.Updated.Add(st.ttl)
+}
+
+// load reads persisted sessions, discarding expired ones
+func (st *Store) load() error {
+	entries, err := os.ReadDir(st.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read chat session directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(st.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Printf("Warning: failed to read chat session %s: %v", entry.Name(), err)
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		sess, err := st.decode(data, id)
+		if err != nil || sess.ID != id {
+			log.Printf("Warning: ignoring invalid chat session file %s: %v", entry.Name(), err)
+			continue
+		}
+		if time.Since(sess.Updated) > st.ttl {
+			os.Remove(path)
+			continue
+		}
+		st.sessions[sess.ID] = sess
+	}
+	if len(st.sessions) > 0 {
+		log.Printf("Restored %d chat sessions from %s", len(st.sessions), st.dir)
+	}
+	return nil
+}
+
+// encode returns the file content of a session, with its sealedFields
+// encrypted if the store has a keyring
+func (st *Store) encode(sess *Session) ([]byte, error) {
+	data, err := json.Marshal(sess)
+	if err != nil || st.keys == nil {
+		return data, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	private := make(map[string]json.RawMessage)
+	for _, name := range sealedFields {
+		if value, ok := fields[name]; ok {
+			private[name] = value
+			delete(fields, name)
+		}
+	}
+	plaintext, err := json.Marshal(private)
+	if err != nil {
+		return nil, err
+	}
+	// Binding the value to the session ID keeps it from being copied into
+	// another session's file
+	sealed, err := st.keys.Seal(plaintext, sess.ID)
+	if err != nil {
+		return nil, err
+	}
+	if fields["sealed"], err = json.Marshal(sealed); err != nil {
+		return nil, err
+	}
+	return json.Marshal(fields)
+}
+
+// decode parses the file content of session id. Files written without a
+// keyring are read as they are, and encrypted on their next save.
+func (st *Store) decode(data []byte, id string) (*Session, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if raw, ok := fields["sealed"]; ok {
+		if st.keys == nil {
+			return nil, fmt.Errorf("session is encrypted but no encryption key is configured")
+		}
+		var sealed string
+		if err := json.Unmarshal(raw, &sealed); err != nil {
+			return nil, err
+		}
+		plaintext, err := st.keys.Open(sealed, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(plaintext, &fields); err != nil {
+			return nil, err
+		}
+		delete(fields, "sealed")
+		if data, err = json.Marshal(fields); err != nil {
+			return nil, err
+		}
+	}
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// Run periodically forgets sessions that have not been used within the TTL,
+// until ctx is done
+func (st *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, id := range st.expired(now) {
+				log.Printf("Chat session %s expired", id)
+				st.removeFile(id)
+			}
+		}
+	}
+}
+
+// expired removes and returns the sessions last used more than the TTL before now
+func (st *Store) expired(now time.Time) []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var ids []string
+	for id, sess := range st.sessions {
+		if now.Sub(sess.Updated) > st.ttl {
+			ids = append(ids, id)
+			delete(st.sessions, id)
+		}
+	}
+	return ids
+}
+
+// path returns the file a session is persisted to
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, id+".json")
+}
+
+// removeFile deletes a persisted session, if persistence is enabled
+func (st *Store) removeFile(id string) {
+	if st.dir == "" {
+		return
+	}
+	if err := os.Remove(st.path(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to delete chat session file %s: %v", id, err)
+	}
+}
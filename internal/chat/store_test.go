@@ -0,0 +1,170 @@
+package chat
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-mcp/internal/fieldcrypt"
+
+	"google.golang.org/genai"
+)
+
+func TestAppendTrimsOldestExchanges(t *testing.T) {
+	var s Session
+	for i := 0; i < 3; i++ {
+		s.Append(4,
+			genai.NewContentFromText("question", genai.RoleUser),
+			genai.NewContentFromText("answer", genai.RoleModel),
+		)
+	}
+	if len(s.History) != 4 || s.History[0].Role != genai.RoleUser {
+		t.Fatalf("history has %d contents starting with %q, want 4 starting with a user turn", len(s.History), s.History[0].Role)
+	}
+
+	// An odd limit must not leave a model turn first
+	s.Append(3, genai.NewContentFromText("question", genai.RoleUser), genai.NewContentFromText("answer", genai.RoleModel))
+	if s.History[0].Role != genai.RoleUser {
+		t.Errorf("history starts with %q after trimming, want user", s.History[0].Role)
+	}
+}
+
+func TestStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+	st, err := NewStore(time.Hour, dir, 0, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	sess, err := st.Create("gemini-2.5-flash", "be brief")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	sess.Append(0, genai.NewContentFromText("hello", genai.RoleUser), genai.NewContentFromText("hi", genai.RoleModel))
+	sess.Turns++
+	if err := st.Save(sess); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	reloaded, err := NewStore(time.Hour, dir, 0, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	got, err := reloaded.Get(sess.ID)
+	if err != nil {
+		t.Fatalf("Get after reload: %v", err)
+	}
+	if got.Model != "gemini-2.5-flash" || got.SystemInstruction != "be brief" || got.Turns != 1 || len(got.History) != 2 {
+		t.Errorf("reloaded session = %+v", got)
+	}
+
+	if !reloaded.Delete(sess.ID) {
+		t.Error("Delete reported a missing session")
+	}
+	if _, err := NewStore(time.Hour, dir, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reloaded.Get(sess.ID); err == nil {
+		t.Error("deleted session is still available")
+	}
+}
+
+func TestStoreExpiryAndEviction(t *testing.T) {
+	st, err := NewStore(time.Minute, "", 2, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	first, _ := st.Create("m", "")
+	first.Updated = time.Now().Add(-30 * time.Second)
+	second, _ := st.Create("m", "")
+	third, _ := st.Create("m", "")
+
+	if _, err := st.Get(first.ID); err == nil {
+		t.Error("least recently used session was not evicted")
+	}
+	if _, err := st.Get(second.ID); err != nil {
+		t.Errorf("Get(second): %v", err)
+	}
+
+	if ids := st.expired(time.Now().Add(2 * time.Minute)); len(ids) != 2 {
+		t.Errorf("expired = %v, want both remaining sessions", ids)
+	}
+	if _, err := st.Get(third.ID); err == nil {
+		t.Error("expired session is still available")
+	}
+}
+
+func TestAddRevision(t *testing.T) {
+	var s Session
+	s.AddRevision(0, "original", "a.png")
+	s.AddRevision(1, "darker sky", "b.png")
+	rev := s.AddRevision(1, "add a boat", "c.png")
+	if rev.Index != 3 || rev.Parent != 1 || len(s.Revisions) != 3 {
+		t.Errorf("AddRevision = %+v with %d revisions", rev, len(s.Revisions))
+	}
+}
+
+func TestStoreEncryption(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := fieldcrypt.ParseKeyring(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+
+	// A session saved before encryption was enabled is still readable
+	plain, err := NewStore(time.Hour, dir, 0, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	old, _ := plain.Create("gemini-2.5-flash", "")
+	if err := plain.Save(old); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	st, err := NewStore(time.Hour, dir, 0, keys)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if _, err := st.Get(old.ID); err != nil {
+		t.Errorf("plaintext session not loaded: %v", err)
+	}
+	sess, _ := st.Create("gemini-2.5-flash", "confidential brief")
+	sess.Append(0, genai.NewContentFromText("launch codename", genai.RoleUser))
+	sess.AddRevision(0, "unreleased logo", "key.png")
+	if err := st.Save(sess); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, sess.ID+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"confidential", "codename", "unreleased"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("session file contains %q in plaintext: %s", secret, data)
+		}
+	}
+
+	reloaded, err := NewStore(time.Hour, dir, 0, keys)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	got, err := reloaded.Get(sess.ID)
+	if err != nil {
+		t.Fatalf("Get after reload: %v", err)
+	}
+	if got.SystemInstruction != "confidential brief" || len(got.History) != 1 || len(got.Revisions) != 1 || got.Revisions[0].Prompt != "unreleased logo" {
+		t.Errorf("reloaded session = %+v", got)
+	}
+
+	// Without the key, encrypted sessions are skipped rather than exposed
+	unkeyed, err := NewStore(time.Hour, dir, 0, nil)
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	if _, err := unkeyed.Get(sess.ID); err == nil {
+		t.Error("encrypted session loaded without a key")
+	}
+}
@@ -2,72 +2,407 @@ package common
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/redact"
 )
 
 type Config struct {
 	// Gemini API Configuration
-	APIKey    string
-	ProjectID string
-	Location  string
+	APIKey         string
+	APIKeys        []string      // Keys rotated across requests; defaults to APIKey alone
+	APIKeyCooldown time.Duration // How long a key that hit its quota is shelved (default: 15m)
+	ProjectID      string
+	Location       string
 
 	// Server Configuration
 	Port           string
-	Transport      string
+	Transport      string // stdio, http (sse is an alias) or both, e.g. "stdio,http"
+	GRPCPort       string // Port of the gRPC API beside the HTTP transport (default: empty, disabled)
+	GRPCReflection bool   // Serve gRPC server reflection, which lists the API to any client (default: false)
 	OutputDir      string
 	GenmediaBucket string
 
+	// TLS (HTTP mode)
+	TLSCertFile       string        // PEM certificate chain; with TLSKeyFile the HTTP transport serves HTTPS
+	TLSKeyFile        string        // PEM private key of TLSCertFile
+	TLSReloadInterval time.Duration // How often the certificate files are checked for rotation (default: 0, never)
+
+	// Local Path Policy (user-supplied input paths and output_directory)
+	FollowSymlinks bool     // Allow paths that are or pass through symbolic links (default: true)
+	AllowedMounts  []string // Directories local paths must resolve into, e.g. bind-mounted workspaces (default: any)
+
+	// Local File Downloads (HTTP mode without S3)
+	PublicBaseURL  string        // Base URL used in /files download links (default: the request's host)
+	FilesURLSecret string        // Key for signing /files URLs (default: random per process)
+	FilesURLTTL    time.Duration // How long signed /files URLs stay valid (default: 24h)
+
+	// Download URLs (S3 presigned and signed /files URLs)
+	PresignClockSkew time.Duration // Extra validity tolerated for clock differences between the server, S3 and clients (default: 5m)
+
+	// Resumable Uploads (HTTP mode)
+	UploadSessionTTL time.Duration // How long an interrupted upload to /upload can be resumed after its last chunk (default: 24h)
+	MaxUploadBytes   int           // Largest file accepted by /upload, in bytes (default: 64MiB; uploads are held in memory while stored)
+
+	// Temporary Files
+	TempDir        string        // Directory of downloaded inputs and intermediate files (default: <system temp>/gemini-mcp)
+	TempFileMaxAge time.Duration // Age after which temp files count as orphaned and are removed (default: 6h)
+	TempGCInterval time.Duration // How often orphaned temp files are removed, after a sweep at startup (default: 15m)
+
+	// Trash
+	TrashRetention time.Duration // How long delete_media and purge_media keep objects restorable under _trash/ (default: 24h; 0 deletes at once)
+
+	// Shutdown
+	ShutdownDrainTimeout  time.Duration // How long tool calls in flight may finish after SIGTERM (default: 5m; 0 exits at once)
+	PendingOperationsFile string        // JSON file receiving the Veo operations still running when the drain times out (default: OUTPUT_DIR/pending_operations.json)
+
+	// Response Configuration
+	ResponseMode           string // How local assets are returned: "inline", "link", or "auto" (default: auto)
+	ResponseInlineMaxBytes int    // Largest asset inlined as base64 in "auto" mode (default: 1MiB)
+	DataURIMaxBytes        int    // Largest asset also returned as a data: URI in structured output (default: 0, disabled)
+
+	// Models
+	ImageDefaultModel string   // Model image tools use when a call names none (default: gemini-3-pro-image-preview)
+	VeoDefaultModel   string   // Model Veo tools use when a call names none (default: veo-3.1-generate-preview)
+	TextDefaultModel  string   // Model chat and media understanding tools use when a call names none (default: gemini-2.5-flash)
+	ModelAllowlist    []string // Models tools may use, as "model" or "tool=model" patterns (default: any)
+
+	// Request Inputs
+	GeminiInlineMaxBytes int // Total size of input files sent inline with a Gemini request; larger inputs go through the Files API (default: 15MiB)
+
+	// Video Tools
+	FFmpegPath            string   // ffmpeg binary used to extract video frames (default: ffmpeg on PATH)
+	VeoConfirmResolutions []string // Veo resolutions that require confirm_cost or user confirmation (default: none)
+	VeoConfirmModels      []string // Veo models that require confirm_cost or user confirmation (default: none)
+	VeoConfirmMaxVideos   int      // Videos one call may render without confirm_cost or user confirmation (default: 0, no limit)
+
+	// Chat sessions
+	ChatSessionTTL       time.Duration // How long gemini_chat sessions are kept without use (default: 1h)
+	ChatSessionDir       string        // Directory gemini_chat sessions are persisted to (default: memory only)
+	PromptEncryptionKeys string        // Base64 AES-256 keys encrypting persisted prompts, the one to encrypt with first (default: plaintext)
+
+	// Thumbnails
+	StoreThumbnails bool // Store a small JPEG preview next to every stored image and video (default: false)
+	ThumbnailSize   int  // Longest side of stored previews in pixels (default: 256)
+
+	// Output compatibility
+	LegacyOutputFields bool // Keep the saved_files/download_urls/... fields replaced by the assets envelope (default: true; removed in the next release)
+
+	// Localization
+	ResponseLanguage string // Language of human-readable tool result text unless a call sets response_language (default: en)
+
+	// Concurrency Configuration
+	MaxConcurrentImageGenerations int           // Concurrent image generation calls (0 = unlimited)
+	MaxConcurrentVideoGenerations int           // Concurrent video generation calls (0 = unlimited)
+	GenerationQueueSize           int           // Requests allowed to wait for a slot (0 = unbounded)
+	GenerationQueueTimeout        time.Duration // How long a queued request waits for a slot (default: 2m)
+	BatchTokens                   []string      // Service tokens whose requests are scheduled as batch work
+	JWTBatchScope                 string        // JWT scope that marks a caller's requests as batch work
+
+	// Connection Pool Configuration
+	HTTPMaxIdleConns        int           // Idle connections kept across Gemini API and S3 hosts (default: 100)
+	HTTPMaxIdleConnsPerHost int           // Idle connections kept per host (default: 32)
+	HTTPIdleConnTimeout     time.Duration // How long idle connections stay open (default: 90s)
+	ConnectionWarmInterval  time.Duration // How often idle connections are refreshed (default: 60s, 0 = only at startup)
+
+	// Bandwidth Limits (bytes per second shared by all transfers, 0 = unlimited)
+	DownloadRateLimit    int // Downloads from the Gemini API, e.g. Veo videos, and from S3
+	S3UploadRateLimit    int // Uploads to S3
+	FileServingRateLimit int // Files served over /files
+
+	// Webhook Notifications
+	WebhookURL          string   // Default URL that receives generation completion events (optional)
+	WebhookSecret       string   // Secret used to sign webhook payloads with HMAC-SHA256 (optional)
+	WebhookAllowedHosts []string // Hosts per-request webhook_url values may point at, "*.example.com" for subdomains (default: none, overrides disabled)
+
+	// Response Cache
+	ResponseCacheEnabled bool          // Return the stored result of an identical earlier generation call (default: false)
+	ResponseCacheSize    int           // Results kept in memory; all are also indexed in storage (default: 256)
+	ResponseCacheTTL     time.Duration // How long a result is served from the cache (default: 24h)
+
+	// Job Scheduling
+	ScheduleTimezone string // IANA time zone of schedule_job run_after times (default: server's local zone)
+
+	// Anonymous Usage Statistics (opt-in)
+	TelemetryEnabled  bool          // Send aggregate tool call and error counts to TelemetryEndpoint (default: false)
+	TelemetryEndpoint string        // URL the statistics are POSTed to; required when enabled
+	TelemetryInterval time.Duration // How often statistics are sent (default: 24h)
+
+	// Fault Injection (for client resilience testing)
+	ChaosEnabled      bool          // Enable the fault-injection layer (default: false)
+	ChaosErrorRate    float64       // Probability a Gemini API call fails with 429/500/503
+	ChaosPollDropRate float64       // Probability a Veo operation poll is dropped
+	ChaosStorageDelay time.Duration // Maximum random delay added to each storage call
+
 	// Authentication Configuration
-	ServiceTokens []string // Comma-separated list of valid Bearer tokens
-	AuthEnabled   bool     // Whether authentication is required for HTTP transport
+	ServiceTokens       []string // Comma-separated list of valid Bearer tokens
+	ServiceTokenTenants []string // token=tenant entries scoping service tokens to a tenant's storage
+	AuthEnabled         bool     // Whether authentication is required for HTTP transport
+
+	// Prompt Redaction
+	PromptRedaction string // off, hide or hash: how listings show other callers' prompts to callers without the read_prompts scope (default: off)
+
+	// Managed Tokens (HTTP mode only)
+	TokenStoreFile string   // JSON file keeping tokens created through the admin API
+	AdminTokens    []string // Bearer tokens allowed to use the /admin/tokens API
+
+	// Generation Quotas (HTTP mode only)
+	Quotas         string // Generations each caller may make per day and month, e.g. images=200/day,videos=20/day (default: unlimited)
+	QuotaUsageFile string // JSON file keeping each caller's generations (default: OUTPUT_DIR/quota_usage.json)
+
+	// JWT / OIDC Authentication (HTTP mode only)
+	JWTJWKSURL       string        // JWKS endpoint with the identity provider's signing keys
+	JWTIssuer        string        // Expected iss claim; also used for OIDC discovery when no JWKS URL is set
+	JWTAudience      string        // Expected aud claim (optional)
+	JWTScopeClaim    string        // Claim holding granted scopes (default: scope)
+	JWTTenantClaim   string        // Claim holding the caller's tenant (default: tenant)
+	JWTRequiredScope string        // Scope every token must carry (optional)
+	JWTCacheTTL      time.Duration // How long fetched signing keys are cached (default: 1h)
+	JWTEnabled       bool          // Auto-enabled when a JWKS URL or issuer is configured
 
 	// S3 Storage Configuration (HTTP mode only)
 	S3Endpoint        string        // S3/MinIO endpoint (e.g., "minio:9000" or "s3.amazonaws.com")
 	S3Bucket          string        // Bucket name for storing generated files
 	S3Region          string        // AWS region (default: us-east-1)
+	S3Credentials     string        // Credential provider: static, default, iam, web_identity or assume_role (default: static)
 	S3AccessKeyID     string        // Access key ID
 	S3SecretAccessKey string        // Secret access key
 	S3UseSSL          bool          // Use SSL/TLS for S3 connection (default: true)
 	S3PresignTTL      time.Duration // TTL for presigned URLs (default: 24h)
 	S3ObjectTTL       time.Duration // TTL for objects before auto-deletion (default: 24h)
 	S3CleanupInterval time.Duration // Cleanup task interval (default: 1h)
+	S3LifecycleExpiry bool          // Expire objects with a bucket lifecycle rule instead of the cleanup task (default: false)
 	S3Enabled         bool          // Auto-enabled when S3 is configured in HTTP mode
+
+	// S3-compatible stores (R2, B2, MinIO, Ceph, ...)
+	S3ForcePathStyle   bool   // Address buckets as endpoint/bucket instead of bucket.endpoint (default: false, chosen by endpoint)
+	S3CABundle         string // PEM file of CA certificates trusted for the S3 endpoint besides the system roots
+	S3SignatureVersion string // Request signature version: v4 or v2 (default: v4)
+
+	// Storage Targets (HTTP mode)
+	StorageTargets []string // Further targets tools can store to with storage_target: "local" or "name=bucket" on the S3 endpoint
+
+	// S3 role-based credentials (S3_CREDENTIALS=web_identity or assume_role)
+	S3RoleARN              string // Role to assume (default: AWS_ROLE_ARN)
+	S3RoleSessionName      string // Session name for the assumed role (default: gemini-mcp)
+	S3ExternalID           string // External ID required by the role's trust policy, if any
+	S3WebIdentityTokenFile string // Projected service account token (default: AWS_WEB_IDENTITY_TOKEN_FILE)
+	S3STSEndpoint          string // STS endpoint (default: AWS STS)
 }
 
 func LoadConfig() *Config {
 	config := &Config{
 		APIKey:         os.Getenv("GOOGLE_API_KEY"),
+		APIKeys:        parseServiceTokens(os.Getenv("GOOGLE_API_KEYS")),
+		APIKeyCooldown: getEnvOrDefaultDuration("API_KEY_COOLDOWN", 15*time.Minute),
 		ProjectID:      os.Getenv("GOOGLE_PROJECT_ID"),
 		Location:       getEnvOrDefault("GOOGLE_LOCATION", "us-central1"),
 		Port:           getEnvOrDefault("PORT", "8080"),
 		Transport:      getEnvOrDefault("TRANSPORT", "stdio"),
+		GRPCPort:       os.Getenv("GRPC_PORT"),
+		GRPCReflection: getEnvOrDefaultBool("GRPC_REFLECTION", false),
 		OutputDir:      getEnvOrDefault("OUTPUT_DIR", "/tmp/gemini-mcp"),
 		GenmediaBucket: os.Getenv("GENMEDIA_BUCKET"),
 		ServiceTokens:  parseServiceTokens(os.Getenv("SERVICE_TOKENS")),
 
+		// Tenants of service tokens
+		ServiceTokenTenants: parseServiceTokens(os.Getenv("SERVICE_TOKEN_TENANTS")),
+
+		// Prompt redaction
+		PromptRedaction: strings.ToLower(getEnvOrDefault("PROMPT_REDACTION", "off")),
+
+		// Managed tokens
+		TokenStoreFile: os.Getenv("TOKEN_STORE_FILE"),
+		AdminTokens:    parseServiceTokens(os.Getenv("ADMIN_TOKENS")),
+
+		// Generation quotas
+		Quotas:         os.Getenv("QUOTAS"),
+		QuotaUsageFile: os.Getenv("QUOTA_USAGE_FILE"),
+
+		// JWT configuration
+		JWTJWKSURL:       os.Getenv("JWT_JWKS_URL"),
+		JWTIssuer:        os.Getenv("JWT_ISSUER"),
+		JWTAudience:      os.Getenv("JWT_AUDIENCE"),
+		JWTScopeClaim:    getEnvOrDefault("JWT_SCOPE_CLAIM", "scope"),
+		JWTTenantClaim:   getEnvOrDefault("JWT_TENANT_CLAIM", "tenant"),
+		JWTRequiredScope: os.Getenv("JWT_REQUIRED_SCOPE"),
+		JWTCacheTTL:      getEnvOrDefaultDuration("JWT_CACHE_TTL", 1*time.Hour),
+
+		// TLS
+		TLSCertFile:       os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:        os.Getenv("TLS_KEY_FILE"),
+		TLSReloadInterval: getEnvOrDefaultDuration("TLS_RELOAD_INTERVAL", 0),
+
+		// Local path policy
+		FollowSymlinks: getEnvOrDefaultBool("FOLLOW_SYMLINKS", true),
+		AllowedMounts:  parseServiceTokens(os.Getenv("ALLOWED_MOUNTS")),
+
+		// Local file downloads
+		PublicBaseURL:  os.Getenv("PUBLIC_BASE_URL"),
+		FilesURLSecret: os.Getenv("FILES_URL_SECRET"),
+		FilesURLTTL:    getEnvOrDefaultDuration("FILES_URL_TTL", 24*time.Hour),
+
+		// Download URLs
+		PresignClockSkew: getEnvOrDefaultDuration("PRESIGN_CLOCK_SKEW", 5*time.Minute),
+
+		// Resumable uploads
+		UploadSessionTTL: getEnvOrDefaultDuration("UPLOAD_SESSION_TTL", 24*time.Hour),
+		MaxUploadBytes:   getEnvOrDefaultInt("MAX_UPLOAD_BYTES", 64<<20),
+
+		// Temporary files
+		TempDir:        getEnvOrDefault("TEMP_DIR", filepath.Join(os.TempDir(), "gemini-mcp")),
+		TempFileMaxAge: getEnvOrDefaultDuration("TEMP_FILE_MAX_AGE", 6*time.Hour),
+		TempGCInterval: getEnvOrDefaultDuration("TEMP_GC_INTERVAL", 15*time.Minute),
+
+		// Trash
+		TrashRetention: getEnvOrDefaultDuration("TRASH_RETENTION", 24*time.Hour),
+
+		// Shutdown
+		ShutdownDrainTimeout:  getEnvOrDefaultDuration("SHUTDOWN_DRAIN_TIMEOUT", 5*time.Minute),
+		PendingOperationsFile: os.Getenv("PENDING_OPERATIONS_FILE"),
+
+		// Response configuration
+		ResponseMode:           strings.ToLower(getEnvOrDefault("RESPONSE_MODE", "auto")),
+		ResponseInlineMaxBytes: getEnvOrDefaultInt("RESPONSE_INLINE_MAX_BYTES", 1<<20),
+		DataURIMaxBytes:        getEnvOrDefaultInt("DATA_URI_MAX_BYTES", 0),
+
+		// Models
+		ImageDefaultModel: getEnvOrDefault("IMAGE_DEFAULT_MODEL", "gemini-3-pro-image-preview"),
+		VeoDefaultModel:   getEnvOrDefault("VEO_DEFAULT_MODEL", "veo-3.1-generate-preview"),
+		TextDefaultModel:  getEnvOrDefault("TEXT_DEFAULT_MODEL", "gemini-2.5-flash"),
+		ModelAllowlist:    parseServiceTokens(os.Getenv("MODEL_ALLOWLIST")),
+
+		// Request inputs
+		GeminiInlineMaxBytes: getEnvOrDefaultInt("GEMINI_INLINE_MAX_BYTES", 15<<20),
+
+		// Video tools
+		FFmpegPath:            getEnvOrDefault("FFMPEG_PATH", "ffmpeg"),
+		VeoConfirmResolutions: parseServiceTokens(os.Getenv("VEO_CONFIRM_RESOLUTIONS")),
+		VeoConfirmModels:      parseServiceTokens(os.Getenv("VEO_CONFIRM_MODELS")),
+		VeoConfirmMaxVideos:   getEnvOrDefaultInt("VEO_CONFIRM_MAX_VIDEOS", 0),
+
+		// Chat sessions
+		ChatSessionTTL:       getEnvOrDefaultDuration("CHAT_SESSION_TTL", time.Hour),
+		ChatSessionDir:       os.Getenv("CHAT_SESSION_DIR"),
+		PromptEncryptionKeys: os.Getenv("PROMPT_ENCRYPTION_KEYS"),
+
+		// Thumbnails
+		StoreThumbnails: getEnvOrDefaultBool("STORE_THUMBNAILS", false),
+		ThumbnailSize:   getEnvOrDefaultInt("THUMBNAIL_SIZE", 256),
+
+		// Output compatibility
+		LegacyOutputFields: getEnvOrDefaultBool("LEGACY_OUTPUT_FIELDS", true),
+
+		// Localization
+		ResponseLanguage: getEnvOrDefault("RESPONSE_LANGUAGE", "en"),
+
+		// Concurrency configuration
+		GenerationQueueSize:    getEnvOrDefaultInt("GENERATION_QUEUE_SIZE", 0),
+		GenerationQueueTimeout: getEnvOrDefaultDuration("GENERATION_QUEUE_TIMEOUT", 2*time.Minute),
+		BatchTokens:            parseServiceTokens(os.Getenv("BATCH_TOKENS")),
+		JWTBatchScope:          os.Getenv("JWT_BATCH_SCOPE"),
+
+		// Connection pool
+		HTTPMaxIdleConns:        getEnvOrDefaultInt("HTTP_MAX_IDLE_CONNS", 100),
+		HTTPMaxIdleConnsPerHost: getEnvOrDefaultInt("HTTP_MAX_IDLE_CONNS_PER_HOST", 32),
+		HTTPIdleConnTimeout:     getEnvOrDefaultDuration("HTTP_IDLE_CONN_TIMEOUT", 90*time.Second),
+		ConnectionWarmInterval:  getEnvOrDefaultDuration("CONNECTION_WARM_INTERVAL", 60*time.Second),
+
+		// Bandwidth limits
+		DownloadRateLimit:    getEnvOrDefaultInt("DOWNLOAD_RATE_LIMIT", 0),
+		S3UploadRateLimit:    getEnvOrDefaultInt("S3_UPLOAD_RATE_LIMIT", 0),
+		FileServingRateLimit: getEnvOrDefaultInt("FILE_SERVING_RATE_LIMIT", 0),
+
+		// Webhooks
+		WebhookURL:          os.Getenv("WEBHOOK_URL"),
+		WebhookSecret:       os.Getenv("WEBHOOK_SECRET"),
+		WebhookAllowedHosts: parseServiceTokens(os.Getenv("WEBHOOK_ALLOWED_HOSTS")),
+
+		// Response cache
+		ResponseCacheEnabled: getEnvOrDefaultBool("RESPONSE_CACHE_ENABLED", false),
+		ResponseCacheSize:    getEnvOrDefaultInt("RESPONSE_CACHE_SIZE", 256),
+		ResponseCacheTTL:     getEnvOrDefaultDuration("RESPONSE_CACHE_TTL", 24*time.Hour),
+
+		// Job scheduling
+		ScheduleTimezone: os.Getenv("SCHEDULE_TIMEZONE"),
+
+		// Anonymous usage statistics
+		TelemetryEnabled:  getEnvOrDefaultBool("TELEMETRY_ENABLED", false),
+		TelemetryEndpoint: os.Getenv("TELEMETRY_ENDPOINT"),
+		TelemetryInterval: getEnvOrDefaultDuration("TELEMETRY_INTERVAL", 24*time.Hour),
+
+		// Fault injection
+		ChaosEnabled:      getEnvOrDefaultBool("CHAOS_ENABLED", false),
+		ChaosErrorRate:    getEnvOrDefaultFloat("CHAOS_ERROR_RATE", 0.1),
+		ChaosPollDropRate: getEnvOrDefaultFloat("CHAOS_POLL_DROP_RATE", 0.2),
+		ChaosStorageDelay: getEnvOrDefaultDuration("CHAOS_STORAGE_DELAY", 2*time.Second),
+
 		// S3 configuration
 		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
 		S3Bucket:          getEnvOrDefault("S3_BUCKET", "gemini-media"),
 		S3Region:          getEnvOrDefault("S3_REGION", "us-east-1"),
+		S3Credentials:     getEnvOrDefault("S3_CREDENTIALS", "static"),
 		S3AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
 		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
 		S3UseSSL:          getEnvOrDefaultBool("S3_USE_SSL", true),
 		S3PresignTTL:      getEnvOrDefaultDuration("S3_PRESIGN_TTL", 24*time.Hour),
 		S3ObjectTTL:       getEnvOrDefaultDuration("S3_OBJECT_TTL", 24*time.Hour),
 		S3CleanupInterval: getEnvOrDefaultDuration("S3_CLEANUP_INTERVAL", 1*time.Hour),
+		S3LifecycleExpiry: getEnvOrDefaultBool("S3_LIFECYCLE_EXPIRY", false),
+
+		// S3-compatible stores
+		S3ForcePathStyle:   getEnvOrDefaultBool("S3_FORCE_PATH_STYLE", false),
+		S3CABundle:         os.Getenv("S3_CA_BUNDLE"),
+		S3SignatureVersion: getEnvOrDefault("S3_SIGNATURE_VERSION", "v4"),
+
+		// Storage targets
+		StorageTargets: parseServiceTokens(os.Getenv("STORAGE_TARGETS")),
+
+		// S3 role-based credentials
+		S3RoleARN:              getEnvOrDefault("S3_ROLE_ARN", os.Getenv("AWS_ROLE_ARN")),
+		S3RoleSessionName:      getEnvOrDefault("S3_ROLE_SESSION_NAME", "gemini-mcp"),
+		S3ExternalID:           os.Getenv("S3_EXTERNAL_ID"),
+		S3WebIdentityTokenFile: getEnvOrDefault("S3_WEB_IDENTITY_TOKEN_FILE", os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")),
+		S3STSEndpoint:          os.Getenv("S3_STS_ENDPOINT"),
+	}
+
+	// Per-kind limits fall back to the shared MAX_CONCURRENT_GENERATIONS
+	maxConcurrent := getEnvOrDefaultInt("MAX_CONCURRENT_GENERATIONS", 0)
+	config.MaxConcurrentImageGenerations = getEnvOrDefaultInt("MAX_CONCURRENT_IMAGE_GENERATIONS", maxConcurrent)
+	config.MaxConcurrentVideoGenerations = getEnvOrDefaultInt("MAX_CONCURRENT_VIDEO_GENERATIONS", maxConcurrent)
+
+	// A single GOOGLE_API_KEY is a pool of one; with only GOOGLE_API_KEYS set,
+	// the first key is the primary key
+	if len(config.APIKeys) == 0 && config.APIKey != "" {
+		config.APIKeys = []string{config.APIKey}
+	} else if config.APIKey == "" && len(config.APIKeys) > 0 {
+		config.APIKey = config.APIKeys[0]
 	}
 
-	// Enable auth if tokens are configured
-	config.AuthEnabled = len(config.ServiceTokens) > 0
+	// Enable auth if tokens, a token store or a JWT identity provider are
+	// configured
+	config.JWTEnabled = config.JWTJWKSURL != "" || config.JWTIssuer != ""
+	config.AuthEnabled = len(config.ServiceTokens) > 0 || config.TokenStoreFile != "" || config.JWTEnabled
 
-	// Enable S3 if endpoint is configured and transport is HTTP
+	// Enable S3 if endpoint and credentials are configured and transport is
+	// HTTP. Role-based providers obtain their keys at runtime.
 	config.S3Enabled = config.S3Endpoint != "" &&
-		config.S3AccessKeyID != "" &&
-		config.S3SecretAccessKey != "" &&
-		(config.Transport == "http" || config.Transport == "sse")
+		(config.S3AccessKeyID != "" && config.S3SecretAccessKey != "" || !s3NeedsStaticKeys(config.S3Credentials)) &&
+		config.HTTPEnabled()
+
+	if config.PendingOperationsFile == "" {
+		config.PendingOperationsFile = filepath.Join(config.OutputDir, "pending_operations.json")
+	}
+	if config.QuotaUsageFile == "" {
+		config.QuotaUsageFile = filepath.Join(config.OutputDir, "quota_usage.json")
+	}
 
 	// Create output directory if it doesn't exist (for stdio mode or S3 disabled)
 	if config.OutputDir != "" && !config.S3Enabled {
@@ -79,6 +414,12 @@ func LoadConfig() *Config {
 	return config
 }
 
+// s3NeedsStaticKeys reports whether an S3 credential provider signs with, or
+// assumes a role from, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY
+func s3NeedsStaticKeys(provider string) bool {
+	return provider == "static" || provider == "assume_role"
+}
+
 // parseServiceTokens parses a comma-separated list of tokens
 func parseServiceTokens(tokensStr string) []string {
 	if tokensStr == "" {
@@ -113,6 +454,28 @@ func getEnvOrDefaultBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+func getEnvOrDefaultInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
+func getEnvOrDefaultFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return parsed
+	}
+	return defaultValue
+}
+
 func getEnvOrDefaultDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		parsed, err := time.ParseDuration(value)
@@ -126,11 +489,147 @@ func getEnvOrDefaultDuration(key string, defaultValue time.Duration) time.Durati
 
 func (c *Config) Validate() error {
 	if c.APIKey == "" {
-		return fmt.Errorf("GOOGLE_API_KEY environment variable is required")
+		return fmt.Errorf("GOOGLE_API_KEY or GOOGLE_API_KEYS environment variable is required")
+	}
+	switch c.ResponseMode {
+	case "inline", "link", "auto":
+	default:
+		return fmt.Errorf("RESPONSE_MODE must be one of: inline, link, auto (got %q)", c.ResponseMode)
+	}
+	switch c.S3Credentials {
+	case "static", "default", "iam", "assume_role":
+	case "web_identity":
+		if c.S3Endpoint != "" && c.S3WebIdentityTokenFile == "" {
+			return fmt.Errorf("S3_CREDENTIALS=web_identity requires S3_WEB_IDENTITY_TOKEN_FILE or AWS_WEB_IDENTITY_TOKEN_FILE")
+		}
+	default:
+		return fmt.Errorf("S3_CREDENTIALS must be one of: static, default, iam, web_identity, assume_role (got %q)", c.S3Credentials)
+	}
+	if (c.S3Credentials == "web_identity" || c.S3Credentials == "assume_role") && c.S3Endpoint != "" && c.S3RoleARN == "" {
+		return fmt.Errorf("S3_CREDENTIALS=%s requires S3_ROLE_ARN or AWS_ROLE_ARN", c.S3Credentials)
+	}
+	switch c.S3SignatureVersion {
+	case "v4", "v2":
+	default:
+		return fmt.Errorf("S3_SIGNATURE_VERSION must be one of: v4, v2 (got %q)", c.S3SignatureVersion)
+	}
+	if c.S3CABundle != "" {
+		if _, err := os.Stat(c.S3CABundle); err != nil {
+			return fmt.Errorf("S3_CA_BUNDLE: %w", err)
+		}
+	}
+	if _, err := redact.ParseMode(c.PromptRedaction); err != nil {
+		return fmt.Errorf("PROMPT_REDACTION: %w", err)
+	}
+	if _, err := quota.ParseLimits(c.Quotas); err != nil {
+		return fmt.Errorf("QUOTAS: %w", err)
+	}
+	if len(c.AdminTokens) > 0 && c.TokenStoreFile == "" {
+		return fmt.Errorf("ADMIN_TOKENS requires TOKEN_STORE_FILE")
+	}
+	if c.GeminiInlineMaxBytes < 0 {
+		return fmt.Errorf("GEMINI_INLINE_MAX_BYTES must not be negative")
+	}
+	if c.UploadSessionTTL <= 0 {
+		return fmt.Errorf("UPLOAD_SESSION_TTL must be positive")
+	}
+	if c.DownloadRateLimit < 0 || c.S3UploadRateLimit < 0 || c.FileServingRateLimit < 0 {
+		return fmt.Errorf("DOWNLOAD_RATE_LIMIT, S3_UPLOAD_RATE_LIMIT and FILE_SERVING_RATE_LIMIT must not be negative")
+	}
+	if c.MaxUploadBytes <= 0 {
+		return fmt.Errorf("MAX_UPLOAD_BYTES must be positive")
+	}
+	if c.TempFileMaxAge < time.Minute {
+		return fmt.Errorf("TEMP_FILE_MAX_AGE must be at least 1m, so files in use are not removed")
+	}
+	if c.TempGCInterval <= 0 {
+		return fmt.Errorf("TEMP_GC_INTERVAL must be positive")
+	}
+	if c.TrashRetention < 0 {
+		return fmt.Errorf("TRASH_RETENTION must not be negative")
+	}
+	for _, transport := range strings.Split(c.Transport, ",") {
+		switch strings.TrimSpace(transport) {
+		case "stdio", "http", "sse":
+		default:
+			return fmt.Errorf("invalid TRANSPORT %q: must be stdio, http, sse or a comma-separated list such as stdio,http", c.Transport)
+		}
+	}
+	if len(c.StorageTargets) > 0 && !c.HTTPEnabled() {
+		return fmt.Errorf("STORAGE_TARGETS requires TRANSPORT=http")
+	}
+	if c.GRPCPort != "" && !c.HTTPEnabled() {
+		return fmt.Errorf("GRPC_PORT requires TRANSPORT=http")
+	}
+	if c.GRPCPort != "" && c.GRPCPort == c.Port {
+		return fmt.Errorf("GRPC_PORT must differ from PORT")
+	}
+	if c.GRPCReflection && c.GRPCPort == "" {
+		return fmt.Errorf("GRPC_REFLECTION requires GRPC_PORT")
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSReloadInterval < 0 {
+		return fmt.Errorf("TLS_RELOAD_INTERVAL must not be negative")
+	}
+	if c.ShutdownDrainTimeout < 0 {
+		return fmt.Errorf("SHUTDOWN_DRAIN_TIMEOUT must not be negative")
+	}
+	if c.PresignClockSkew < 0 || c.PresignClockSkew > time.Hour {
+		return fmt.Errorf("PRESIGN_CLOCK_SKEW must be between 0 and 1h")
+	}
+	if c.ScheduleTimezone != "" {
+		if _, err := time.LoadLocation(c.ScheduleTimezone); err != nil {
+			return fmt.Errorf("SCHEDULE_TIMEZONE: unknown time zone %q", c.ScheduleTimezone)
+		}
+	}
+	if c.ResponseCacheEnabled && (c.ResponseCacheSize <= 0 || c.ResponseCacheTTL <= 0) {
+		return fmt.Errorf("RESPONSE_CACHE_SIZE and RESPONSE_CACHE_TTL must be positive")
+	}
+	if c.ChaosErrorRate < 0 || c.ChaosErrorRate > 1 || c.ChaosPollDropRate < 0 || c.ChaosPollDropRate > 1 {
+		return fmt.Errorf("CHAOS_ERROR_RATE and CHAOS_POLL_DROP_RATE must be between 0 and 1")
+	}
+	if c.TelemetryEnabled {
+		if u, err := url.Parse(c.TelemetryEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("TELEMETRY_ENABLED requires TELEMETRY_ENDPOINT to be an absolute http or https URL")
+		}
+		if c.TelemetryInterval < time.Minute {
+			return fmt.Errorf("TELEMETRY_INTERVAL must be at least 1m")
+		}
 	}
 	return nil
 }
 
+// Transports returns the transports to run, without duplicates: "stdio"
+// and/or "http", to which "sse" is mapped
+func (c *Config) Transports() []string {
+	var transports []string
+	for _, transport := range strings.Split(c.Transport, ",") {
+		transport = strings.TrimSpace(transport)
+		if transport == "sse" {
+			transport = "http"
+		}
+		if transport != "" && !slices.Contains(transports, transport) {
+			transports = append(transports, transport)
+		}
+	}
+	if len(transports) == 0 {
+		return []string{"stdio"}
+	}
+	return transports
+}
+
+// HTTPEnabled reports whether the server runs the HTTP transport
+func (c *Config) HTTPEnabled() bool {
+	return slices.Contains(c.Transports(), "http")
+}
+
+// StdioEnabled reports whether the server runs the stdio transport
+func (c *Config) StdioEnabled() bool {
+	return slices.Contains(c.Transports(), "stdio")
+}
+
 // UploadConfig is a minimal config for the upload_media CLI
 type UploadConfig struct {
 	S3Endpoint        string
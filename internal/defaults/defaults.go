@@ -0,0 +1,172 @@
+// Package defaults applies operator-configured tool argument defaults, read
+// from DEFAULTS_<TOOL>_<ARGUMENT> environment variables, to incoming tool
+// calls.
+package defaults
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts every defaults environment variable
+const EnvPrefix = "DEFAULTS_"
+
+// Overrides maps tool names to the default value of each overridden argument
+type Overrides map[string]map[string]any
+
+// ArgumentTypes maps tool names to the JSON Schema type of each argument
+type ArgumentTypes map[string]map[string]string
+
+// SchemaTypes extracts argument types from a tool input schema in its JSON
+// form. Arguments whose type is a list (e.g. ["null", "string"]) use the first
+// non-null entry.
+func SchemaTypes(schema any) map[string]string {
+	var root struct {
+		Properties map[string]struct {
+			Type json.RawMessage `json:"type"`
+		} `json:"properties"`
+	}
+	data, err := json.Marshal(schema)
+	if err != nil || json.Unmarshal(data, &root) != nil {
+		return nil
+	}
+
+	types := make(map[string]string, len(root.Properties))
+	for name, prop := range root.Properties {
+		var single string
+		var list []string
+		if json.Unmarshal(prop.Type, &single) == nil {
+			types[name] = single
+		} else if json.Unmarshal(prop.Type, &list) == nil {
+			for _, t := range list {
+				if t != "null" {
+					types[name] = t
+					break
+				}
+			}
+		}
+	}
+	return types
+}
+
+// Parse resolves DEFAULTS_ variables in environ (as returned by os.Environ)
+// against the known tools. The variable name is matched case-insensitively
+// against "<tool>_<argument>", preferring the longest tool name, and the value
+// is converted to the argument's type: numbers and booleans are parsed and
+// arrays are split on commas. Variables that name no known argument or whose
+// value does not fit are ignored and described in the returned warnings.
+func Parse(environ []string, tools ArgumentTypes) (Overrides, []string) {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	overrides := make(Overrides)
+	var warnings []string
+	for _, entry := range environ {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok || !strings.HasPrefix(key, EnvPrefix) || value == "" {
+			continue
+		}
+		rest := strings.ToLower(strings.TrimPrefix(key, EnvPrefix))
+
+		matched := false
+		for _, tool := range names {
+			arg, ok := strings.CutPrefix(rest, tool+"_")
+			if !ok {
+				continue
+			}
+			argType, ok := tools[tool][arg]
+			if !ok {
+				continue
+			}
+			matched = true
+			converted, err := convert(value, argType)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s: %v", key, err))
+				break
+			}
+			if overrides[tool] == nil {
+				overrides[tool] = make(map[string]any)
+			}
+			overrides[tool][arg] = converted
+			break
+		}
+		if !matched {
+			warnings = append(warnings, fmt.Sprintf("%s does not name a tool argument", key))
+		}
+	}
+	return overrides, warnings
+}
+
+// convert parses an environment value as the given JSON Schema type
+func convert(value, argType string) (any, error) {
+	switch argType {
+	case "integer":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an integer", value)
+		}
+		return n, nil
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", value)
+		}
+		return f, nil
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a boolean", value)
+		}
+		return b, nil
+	case "array":
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items, nil
+	case "string", "":
+		return value, nil
+	default:
+		return nil, fmt.Errorf("arguments of type %s cannot be set from the environment", argType)
+	}
+}
+
+// Apply fills in the overridden defaults of a tool that are missing from its
+// call arguments. Arguments the caller set, even to null, are kept.
+func (o Overrides) Apply(tool string, arguments json.RawMessage) (json.RawMessage, error) {
+	defaults := o[tool]
+	if len(defaults) == 0 {
+		return arguments, nil
+	}
+
+	args := make(map[string]json.RawMessage)
+	if len(arguments) > 0 && string(arguments) != "null" {
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+	}
+	changed := false
+	for name, value := range defaults {
+		if _, ok := args[name]; ok {
+			continue
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		args[name] = data
+		changed = true
+	}
+	if !changed {
+		return arguments, nil
+	}
+	return json.Marshal(args)
+}
@@ -0,0 +1,82 @@
+package defaults
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+var testTools = ArgumentTypes{
+	"gemini_image":            {"model": "string"},
+	"gemini_image_generation": {"quality": "string", "number_of_images": "integer", "transparent_background": "boolean", "tags": "array"},
+}
+
+func TestSchemaTypes(t *testing.T) {
+	schema := map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"prompt": map[string]any{"type": "string"},
+			"seed":   map[string]any{"type": []string{"null", "integer"}},
+		},
+	}
+	types := SchemaTypes(schema)
+	if types["prompt"] != "string" || types["seed"] != "integer" {
+		t.Errorf("SchemaTypes = %v", types)
+	}
+}
+
+func TestParse(t *testing.T) {
+	overrides, warnings := Parse([]string{
+		"PATH=/usr/bin",
+		"DEFAULTS_GEMINI_IMAGE_GENERATION_QUALITY=medium",
+		"DEFAULTS_GEMINI_IMAGE_GENERATION_NUMBER_OF_IMAGES=2",
+		"DEFAULTS_GEMINI_IMAGE_GENERATION_TRANSPARENT_BACKGROUND=true",
+		"DEFAULTS_GEMINI_IMAGE_GENERATION_TAGS=a, b",
+		"DEFAULTS_GEMINI_IMAGE_MODEL=fast",
+		"DEFAULTS_GEMINI_IMAGE_GENERATION_NUMBER_OF_IMAGES_X=1",
+		"DEFAULTS_VEO_TEXT_TO_VIDEO_RESOLUTION=1080p",
+	}, testTools)
+
+	gen := overrides["gemini_image_generation"]
+	if gen["quality"] != "medium" || gen["number_of_images"] != int64(2) || gen["transparent_background"] != true {
+		t.Errorf("gemini_image_generation overrides = %v", gen)
+	}
+	if tags, ok := gen["tags"].([]string); !ok || strings.Join(tags, "|") != "a|b" {
+		t.Errorf("tags = %v", gen["tags"])
+	}
+	if overrides["gemini_image"]["model"] != "fast" {
+		t.Errorf("gemini_image overrides = %v", overrides["gemini_image"])
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %q, want one per unknown variable", warnings)
+	}
+
+	_, warnings = Parse([]string{"DEFAULTS_GEMINI_IMAGE_GENERATION_NUMBER_OF_IMAGES=two"}, testTools)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "not an integer") {
+		t.Errorf("warnings = %q, want a type error", warnings)
+	}
+}
+
+func TestApply(t *testing.T) {
+	overrides := Overrides{"gemini_image_generation": {"quality": "medium", "number_of_images": int64(2)}}
+
+	out, err := overrides.Apply("gemini_image_generation", json.RawMessage(`{"prompt":"cat","quality":"high"}`))
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	var args map[string]any
+	if err := json.Unmarshal(out, &args); err != nil {
+		t.Fatal(err)
+	}
+	if args["quality"] != "high" || args["number_of_images"] != float64(2) || args["prompt"] != "cat" {
+		t.Errorf("Apply = %s", out)
+	}
+
+	if out, _ := overrides.Apply("gemini_image_generation", nil); !strings.Contains(string(out), `"quality":"medium"`) {
+		t.Errorf("Apply without arguments = %s", out)
+	}
+	in := json.RawMessage(`{"prompt":"cat"}`)
+	if out, _ := overrides.Apply("other_tool", in); string(out) != string(in) {
+		t.Errorf("Apply changed the arguments of a tool without overrides: %s", out)
+	}
+}
@@ -0,0 +1,106 @@
+// Package doctor runs deployment checks - configuration, API access, storage
+// - and prints a pass/fail report, for the -doctor command line mode.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN" // The server works, but a feature is unavailable or degraded
+	Fail Status = "FAIL"
+	Skip Status = "SKIP" // Not applicable to the configuration, or an earlier check failed
+)
+
+// Check is one named deployment check. Run returns a detail for the report,
+// or an error to fail the check; errors wrapped by Warning or Skipped only
+// warn or skip.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name     string
+	Status   Status
+	Detail   string
+	Duration time.Duration
+}
+
+type statusError struct {
+	status Status
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// Warning makes a check warn about err instead of failing
+func Warning(err error) error {
+	return &statusError{status: Warn, err: err}
+}
+
+// Skipped skips a check for the reason given
+func Skipped(format string, args ...any) error {
+	return &statusError{status: Skip, err: fmt.Errorf(format, args...)}
+}
+
+// Run runs checks in order, each with timeout
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		detail, err := check.Run(checkCtx)
+		cancel()
+
+		result := Result{Name: check.Name, Status: Pass, Detail: detail, Duration: time.Since(start)}
+		var se *statusError
+		switch {
+		case errors.As(err, &se):
+			result.Status, result.Detail = se.status, se.err.Error()
+		case err != nil:
+			result.Status, result.Detail = Fail, err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// Failed reports whether any check failed
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
+
+// Write prints results as an aligned report followed by a summary line
+func Write(w io.Writer, results []Result) {
+	width := 0
+	for _, r := range results {
+		width = max(width, len(r.Name))
+	}
+	counts := make(map[Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+		line := fmt.Sprintf("%-4s  %-*s  %s", r.Status, width, r.Name, strings.ReplaceAll(r.Detail, "\n", "; "))
+		if r.Status != Skip {
+			line += fmt.Sprintf(" (%dms)", r.Duration.Milliseconds())
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n", counts[Pass], counts[Warn], counts[Fail], counts[Skip])
+}
@@ -0,0 +1,40 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	checks := []Check{
+		{Name: "ok", Run: func(ctx context.Context) (string, error) { return "fine", nil }},
+		{Name: "degraded", Run: func(ctx context.Context) (string, error) { return "", Warning(errors.New("slow")) }},
+		{Name: "broken", Run: func(ctx context.Context) (string, error) { return "", errors.New("first\nsecond") }},
+		{Name: "n/a", Run: func(ctx context.Context) (string, error) { return "", Skipped("not configured") }},
+		{Name: "hangs", Run: func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		}},
+	}
+	results := Run(context.Background(), checks, 10*time.Millisecond)
+	want := []Status{Pass, Warn, Fail, Skip, Fail}
+	for i, r := range results {
+		if r.Status != want[i] {
+			t.Errorf("%s: status %s, want %s", r.Name, r.Status, want[i])
+		}
+	}
+	if !Failed(results) || Failed(results[:2]) {
+		t.Error("Failed does not reflect failed checks")
+	}
+
+	var report strings.Builder
+	Write(&report, results)
+	for _, line := range []string{"PASS  ok        fine", "FAIL  broken    first; second", "SKIP  n/a       not configured\n", "1 passed, 1 warnings, 2 failed, 1 skipped"} {
+		if !strings.Contains(report.String(), line) {
+			t.Errorf("report missing %q:\n%s", line, report.String())
+		}
+	}
+}
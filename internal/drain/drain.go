@@ -0,0 +1,96 @@
+// Package drain lets a server finish the work in flight when it shuts down:
+// once draining starts, new work is refused while admitted work runs to
+// completion, so generations that were already billed are not thrown away.
+package drain
+
+import (
+	"context"
+	"sync"
+)
+
+// Drainer counts work in flight and waits for it to finish on shutdown
+type Drainer struct {
+	mu       sync.Mutex
+	draining bool
+	active   int
+	idle     chan struct{} // Closed when draining and no work is active
+}
+
+// New creates a drainer that admits work
+func New() *Drainer {
+	return &Drainer{idle: make(chan struct{})}
+}
+
+// Enter admits a unit of work, such as a tool call, and returns the function
+// that marks it finished. It refuses work once draining has started.
+func (d *Drainer) Enter() (leave func(), ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	d.active++
+	return d.leave(), true
+}
+
+// Hold counts work started by admitted work that outlives it, such as a
+// background job, even while draining. It refuses work once a drain has
+// found nothing in flight, as nothing waits for it any more.
+func (d *Drainer) Hold() (release func(), ok bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining && d.active == 0 {
+		return nil, false
+	}
+	d.active++
+	return d.leave(), true
+}
+
+// leave returns a function ending one unit of work, safe to call more than once
+func (d *Drainer) leave() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			d.active--
+			if d.draining && d.active == 0 {
+				close(d.idle)
+			}
+		})
+	}
+}
+
+// Active returns the number of units of work in flight
+func (d *Drainer) Active() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.active
+}
+
+// Draining reports whether draining has started
+func (d *Drainer) Draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.draining
+}
+
+// Drain stops admitting work and waits until the work in flight has
+// finished or ctx is done, returning ctx's error in the latter case
+func (d *Drainer) Drain(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.draining {
+		d.draining = true
+		if d.active == 0 {
+			close(d.idle)
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,91 @@
+package drain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForWork(t *testing.T) {
+	d := New()
+	leave, ok := d.Enter()
+	if !ok {
+		t.Fatal("expected work to be admitted")
+	}
+	release, ok := d.Hold()
+	if !ok {
+		t.Fatal("expected held work to be admitted")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- d.Drain(context.Background()) }()
+
+	// New work is refused once draining, work in flight keeps running
+	for !d.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := d.Enter(); ok {
+		t.Error("expected work to be refused while draining")
+	}
+	leave()
+	leave() // Idempotent
+	select {
+	case <-done:
+		t.Fatal("Drain returned with held work in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if n := d.Active(); n != 1 {
+		t.Errorf("Active = %d, want 1", n)
+	}
+
+	release()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Drain = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not return after the work finished")
+	}
+}
+
+func TestDrainDeadline(t *testing.T) {
+	d := New()
+	d.Enter()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := d.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain = %v, want deadline exceeded", err)
+	}
+	if n := d.Active(); n != 1 {
+		t.Errorf("Active = %d, want 1", n)
+	}
+}
+
+func TestDrainIdle(t *testing.T) {
+	d := New()
+	if err := d.Drain(context.Background()); err != nil {
+		t.Errorf("Drain = %v", err)
+	}
+	// Draining again returns at once
+	if err := d.Drain(context.Background()); err != nil {
+		t.Errorf("second Drain = %v", err)
+	}
+}
+
+func TestHoldAfterDrain(t *testing.T) {
+	d := New()
+	if err := d.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain = %v", err)
+	}
+	// Nothing waits for work held after an idle drain, and releasing it must
+	// not finish the drain a second time
+	if _, ok := d.Hold(); ok {
+		t.Error("expected held work to be refused after the drain finished")
+	}
+	if n := d.Active(); n != 0 {
+		t.Errorf("Active = %d, want 0", n)
+	}
+}
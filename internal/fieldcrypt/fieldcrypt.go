@@ -0,0 +1,107 @@
+// Package fieldcrypt encrypts individual fields of persisted records, such as
+// the prompts of saved chat sessions, with AES-256-GCM keys so that they are
+// not stored in plaintext on shared disks.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks sealed values: "enc:v1:<key id>:<base64 nonce and ciphertext>"
+const prefix = "enc:v1:"
+
+// Keyring seals values with its first key and opens values sealed with any of
+// its keys, so that a new key can be introduced while older values remain
+// readable
+type Keyring struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// ParseKeyring parses comma-separated base64-encoded 32-byte keys, the key
+// to seal with first. An empty spec returns nil, which disables encryption.
+func ParseKeyring(spec string) (*Keyring, error) {
+	var k *Keyring
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(entry)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %d is not a base64-encoded 32-byte key", i+1)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		id := KeyID(key)
+		if k == nil {
+			k = &Keyring{current: id, aeads: make(map[string]cipher.AEAD)}
+		}
+		k.aeads[id] = aead
+	}
+	return k, nil
+}
+
+// KeyID identifies a key in sealed values without revealing it
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// CurrentKeyID returns the ID of the key new values are sealed with
+func (k *Keyring) CurrentKeyID() string {
+	return k.current
+}
+
+// Seal encrypts plaintext with the current key. context binds the value to
+// the record it belongs to, such as a session ID; Open must be given the same
+// context.
+func (k *Keyring) Seal(plaintext []byte, context string) (string, error) {
+	aead := k.aeads[k.current]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(context))
+	return prefix + k.current + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value returned by Seal
+func (k *Keyring) Open(value, context string) ([]byte, error) {
+	id, encoded, ok := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	if !ok || !IsSealed(value) {
+		return nil, errors.New("value is not sealed")
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, fmt.Errorf("value was sealed with unknown key %s", id)
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, errors.New("sealed value is malformed")
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(context))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value sealed with key %s", id)
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether value was returned by Seal
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
@@ -0,0 +1,57 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+func TestSealOpen(t *testing.T) {
+	old, err := ParseKeyring(testKey(1))
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	sealed, err := old.Seal([]byte("a secret brief"), "chat_1")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "secret") {
+		t.Fatalf("sealed value = %q", sealed)
+	}
+	if _, err := old.Open(sealed, "chat_2"); err == nil {
+		t.Error("Open with another context succeeded")
+	}
+
+	// A rotated keyring seals with the new key and still opens old values
+	rotated, err := ParseKeyring(testKey(2) + ", " + testKey(1))
+	if err != nil {
+		t.Fatalf("ParseKeyring: %v", err)
+	}
+	if rotated.CurrentKeyID() == old.CurrentKeyID() {
+		t.Error("rotated keyring seals with the old key")
+	}
+	plaintext, err := rotated.Open(sealed, "chat_1")
+	if err != nil || string(plaintext) != "a secret brief" {
+		t.Errorf("Open = %q, %v", plaintext, err)
+	}
+	resealed, _ := rotated.Seal(plaintext, "chat_1")
+	if _, err := old.Open(resealed, "chat_1"); err == nil {
+		t.Error("old keyring opened a value sealed with a key it lacks")
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	if k, err := ParseKeyring(" "); k != nil || err != nil {
+		t.Errorf("empty spec = %v, %v; want nil, nil", k, err)
+	}
+	for _, spec := range []string{"not-base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKeyring(spec); err == nil {
+			t.Errorf("ParseKeyring(%q) accepted an invalid key", spec)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"google.golang.org/grpc"
+)
+
+// Client calls the API over a gRPC connection. Credentials and headers such
+// as X-Priority are sent as outgoing metadata of the context, e.g. with
+// metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer ...").
+type Client struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewClient returns a Client calling the API over conn
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{conn: conn}
+}
+
+// ListTools returns the tools the caller can call
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	out := newMessage("ListToolsResponse")
+	if err := c.conn.Invoke(ctx, method("ListTools"), newMessage("ListToolsRequest"), out); err != nil {
+		return nil, err
+	}
+	var resp ListToolsResponse
+	if err := fromMessage(out, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tools, nil
+}
+
+// CallTool calls the tool name with arguments
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResponse, error) {
+	in, err := toMessage("CallToolRequest", CallToolRequest{Name: name, Arguments: arguments})
+	if err != nil {
+		return nil, err
+	}
+	out := newMessage("CallToolResponse")
+	if err := c.conn.Invoke(ctx, method("CallTool"), in, out); err != nil {
+		return nil, err
+	}
+	var resp CallToolResponse
+	if err := fromMessage(out, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StreamTool calls the tool name with arguments like CallTool, passing the
+// progress notifications of the call to progress as they arrive
+func (c *Client) StreamTool(ctx context.Context, name string, arguments map[string]any, progress func(Progress)) (*CallToolResponse, error) {
+	in, err := toMessage("CallToolRequest", CallToolRequest{Name: name, Arguments: arguments})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.conn.NewStream(ctx, &serviceDesc.Streams[0], method("StreamTool"))
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	for {
+		out := newMessage("ToolEvent")
+		if err := stream.RecvMsg(out); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, errors.New("stream ended without a result")
+			}
+			return nil, err
+		}
+		var event ToolEvent
+		if err := fromMessage(out, &event); err != nil {
+			return nil, err
+		}
+		switch {
+		case event.Result != nil:
+			return event.Result, nil
+		case event.Progress != nil && progress != nil:
+			progress(*event.Progress)
+		}
+	}
+}
+
+// method returns the full name of a method of the service
+func method(name string) string {
+	return "/" + ServiceName + "/" + name
+}
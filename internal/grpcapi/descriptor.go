@@ -0,0 +1,131 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/structpb" // Registers google/protobuf/struct.proto
+)
+
+// ServiceName is the full name of the gRPC service. Its version is part of
+// the package, so an incompatible API gets a new one (geminimcp.v2).
+const ServiceName = "geminimcp.v1.ToolService"
+
+// protoFile is the descriptor of proto/geminimcp/v1/tools.proto, from which
+// callers generate their clients. Both must describe the same messages.
+var protoFile = mustBuildFile()
+
+func mustBuildFile() protoreflect.FileDescriptor {
+	scalar := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   kind.Enum(),
+		}
+	}
+	message := func(name string, number int32, typeName string) *descriptorpb.FieldDescriptorProto {
+		field := scalar(name, number, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+		field.TypeName = proto.String(typeName)
+		return field
+	}
+	repeated := func(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+		field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+		return field
+	}
+	oneof := func(field *descriptorpb.FieldDescriptorProto, index int32) *descriptorpb.FieldDescriptorProto {
+		field.OneofIndex = proto.Int32(index)
+		return field
+	}
+	const (
+		str     = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		boolean = descriptorpb.FieldDescriptorProto_TYPE_BOOL
+		double  = descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+		bytes   = descriptorpb.FieldDescriptorProto_TYPE_BYTES
+		int32_  = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		object  = ".google.protobuf.Struct"
+	)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("geminimcp/v1/tools.proto"),
+		Package:    proto.String("geminimcp.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/struct.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("ListToolsRequest")},
+			{Name: proto.String("Tool"), Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("name", 1, str),
+				scalar("description", 2, str),
+				message("input_schema", 3, object),
+				message("output_schema", 4, object),
+			}},
+			{Name: proto.String("ListToolsResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				repeated(message("tools", 1, ".geminimcp.v1.Tool")),
+			}},
+			{Name: proto.String("CallToolRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("name", 1, str),
+				message("arguments", 2, object),
+			}},
+			{Name: proto.String("Content"), Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("type", 1, str),
+				scalar("text", 2, str),
+				scalar("mime_type", 3, str),
+				scalar("uri", 4, str),
+				scalar("data", 5, bytes),
+			}},
+			{Name: proto.String("ToolError"), Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("code", 1, str),
+				scalar("message", 2, str),
+				scalar("retryable", 3, boolean),
+				scalar("retry_after_seconds", 4, int32_),
+			}},
+			{Name: proto.String("CallToolResponse"), Field: []*descriptorpb.FieldDescriptorProto{
+				message("output", 1, object),
+				repeated(message("content", 2, ".geminimcp.v1.Content")),
+				scalar("is_error", 3, boolean),
+				message("error", 4, ".geminimcp.v1.ToolError"),
+				message("meta", 5, object),
+			}},
+			{Name: proto.String("Progress"), Field: []*descriptorpb.FieldDescriptorProto{
+				scalar("progress", 1, double),
+				scalar("total", 2, double),
+				scalar("message", 3, str),
+			}},
+			{
+				Name: proto.String("ToolEvent"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					oneof(message("progress", 1, ".geminimcp.v1.Progress"), 0),
+					oneof(message("result", 2, ".geminimcp.v1.CallToolResponse"), 0),
+				},
+				OneofDecl: []*descriptorpb.OneofDescriptorProto{{Name: proto.String("event")}},
+			},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("ToolService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				{Name: proto.String("ListTools"), InputType: proto.String(".geminimcp.v1.ListToolsRequest"), OutputType: proto.String(".geminimcp.v1.ListToolsResponse")},
+				{Name: proto.String("CallTool"), InputType: proto.String(".geminimcp.v1.CallToolRequest"), OutputType: proto.String(".geminimcp.v1.CallToolResponse")},
+				{Name: proto.String("StreamTool"), InputType: proto.String(".geminimcp.v1.CallToolRequest"), OutputType: proto.String(".geminimcp.v1.ToolEvent"), ServerStreaming: proto.Bool(true)},
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("invalid gRPC API descriptor: %v", err))
+	}
+	// Registered for server reflection, so tools like grpcurl can describe it
+	if err := protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		panic(fmt.Sprintf("failed to register gRPC API descriptor: %v", err))
+	}
+	return fd
+}
+
+// messageDescriptor returns the descriptor of the message name of the API
+func messageDescriptor(name string) protoreflect.MessageDescriptor {
+	return protoFile.Messages().ByName(protoreflect.Name(name))
+}
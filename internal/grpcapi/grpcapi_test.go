@@ -0,0 +1,206 @@
+package grpcapi
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/service"
+	"gemini-mcp/internal/storage"
+)
+
+type echoInput struct {
+	Text string `json:"text"`
+}
+
+type echoOutput struct {
+	Text     string `json:"text"`
+	Priority string `json:"priority,omitempty"`
+}
+
+type storeOutput struct {
+	ObjectKey   string `json:"object_key"`
+	DownloadURL string `json:"download_url"`
+}
+
+// testAuthority is the host the test client dials
+const testAuthority = "media.example.com:8443"
+
+// newTestClient serves an MCP server with an echo tool and a store tool,
+// which stores a file locally and returns its signed download URL like the
+// server's file serving does, behind a handler requiring the bearer token
+// "secret", over the API
+func newTestClient(t *testing.T) *Client {
+	t.Helper()
+	mcpServer := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	mcp.AddTool(mcpServer, &mcp.Tool{Name: "echo", Description: "Echoes text"}, func(ctx context.Context, req *mcp.CallToolRequest, input echoInput) (*mcp.CallToolResult, echoOutput, error) {
+		if token := req.Params.GetProgressToken(); token != nil {
+			for i := 1; i <= 2; i++ {
+				if err := req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{ProgressToken: token, Progress: float64(i), Total: 2}); err != nil {
+					panic(err)
+				}
+			}
+		}
+		return nil, echoOutput{Text: input.Text, Priority: req.Extra.Header.Get("X-Priority")}, nil
+	})
+	local, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := storage.NewURLSigner("test", time.Hour)
+	mcp.AddTool(mcpServer, &mcp.Tool{Name: "store", Description: "Stores text"}, func(ctx context.Context, req *mcp.CallToolRequest, input echoInput) (*mcp.CallToolResult, storeOutput, error) {
+		result, err := local.Store(ctx, []byte(input.Text), "text/plain", "test")
+		if err != nil {
+			return nil, storeOutput{}, err
+		}
+		path, _ := signer.SignedPath(result.ObjectKey)
+		return nil, storeOutput{ObjectKey: result.ObjectKey, DownloadURL: middleware.GetServerURL(ctx) + path}, nil
+	})
+	mcpHandler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return mcpServer }, nil)
+	handler := middleware.HeadersMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mcpHandler.ServeHTTP(w, r)
+	}))
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	Register(grpcServer, service.New(handler, &mcp.Implementation{Name: "test-grpc", Version: "v0"}))
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithAuthority(testAuthority))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func authorized() context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret", "x-priority", "batch")
+}
+
+func TestCallTool(t *testing.T) {
+	client := newTestClient(t)
+
+	tools, err := client.ListTools(authorized())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[0].InputSchema["type"] != "object" {
+		t.Errorf("ListTools() = %+v, want the echo and store tools with their schemas", tools)
+	}
+
+	resp, err := client.CallTool(authorized(), "echo", map[string]any{"text": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.IsError || resp.Output["text"] != "hello" || resp.Output["priority"] != "batch" {
+		t.Errorf("CallTool() output = %+v, want the echoed text and priority header", resp.Output)
+	}
+	if len(resp.Content) != 1 || resp.Content[0].Type != "text" {
+		t.Errorf("CallTool() content = %+v, want the output as text", resp.Content)
+	}
+
+	_, err = client.CallTool(context.Background(), "echo", map[string]any{"text": "hello"})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Errorf("call without credentials: code %v (%v), want %v", code, err, codes.Unauthenticated)
+	}
+	_, err = client.CallTool(authorized(), "missing", nil)
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("call of an unknown tool: code %v (%v), want %v", code, err, codes.InvalidArgument)
+	}
+}
+
+func TestStreamTool(t *testing.T) {
+	client := newTestClient(t)
+
+	var progress []Progress
+	resp, err := client.StreamTool(authorized(), "echo", map[string]any{"text": "hello"}, func(p Progress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Output["text"] != "hello" {
+		t.Errorf("StreamTool() output = %+v, want the echoed text", resp.Output)
+	}
+	if len(progress) != 2 || progress[1].Progress != 2 || progress[1].Total != 2 {
+		t.Errorf("StreamTool() progress = %+v, want 2 notifications", progress)
+	}
+}
+
+// Download links of stored files point at the host the caller dialed, not
+// at the in-process endpoint the call is served through
+func TestDownloadURLUsesAuthority(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.CallTool(authorized(), "store", map[string]any{"text": "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := resp.Output["object_key"].(string)
+	want := "http://" + testAuthority + storage.FilesPathPrefix + key + "?"
+	if url, _ := resp.Output["download_url"].(string); key == "" || !strings.HasPrefix(url, want) {
+		t.Errorf("download_url = %q, want a URL starting with %q", url, want)
+	}
+}
+
+// The .proto file callers generate clients from must declare the messages
+// and fields the server uses
+func TestProtoFile(t *testing.T) {
+	data, err := os.ReadFile("../../proto/geminimcp/v1/tools.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto := string(data)
+	messages := protoFile.Messages()
+	for i := 0; i < messages.Len(); i++ {
+		message := messages.Get(i)
+		if !strings.Contains(proto, "message "+string(message.Name())+" {") {
+			t.Errorf("tools.proto does not declare message %s", message.Name())
+		}
+		fields := message.Fields()
+		for j := 0; j < fields.Len(); j++ {
+			if decl := fieldDecl(fields.Get(j)); !strings.Contains(proto, decl) {
+				t.Errorf("tools.proto does not declare %s.%s as %q", message.Name(), fields.Get(j).Name(), decl)
+			}
+		}
+	}
+}
+
+// fieldDecl returns the declaration of field in a .proto file
+func fieldDecl(field protoreflect.FieldDescriptor) string {
+	kind := field.Kind().String()
+	if field.Message() != nil {
+		kind = string(field.Message().Name())
+		if field.Message().ParentFile() != protoFile {
+			kind = string(field.Message().FullName())
+		}
+	}
+	if field.Cardinality() == protoreflect.Repeated {
+		kind = "repeated " + kind
+	}
+	return fmt.Sprintf("%s %s = %d;", kind, field.Name(), field.Number())
+}
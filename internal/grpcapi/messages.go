@@ -0,0 +1,99 @@
+package grpcapi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Go forms of the API's messages. They travel as dynamic protobuf messages
+// of protoFile and are converted through their JSON mapping, whose field
+// names are those of the .proto file.
+
+type ListToolsRequest struct{}
+
+type Tool struct {
+	Name         string         `json:"name"`
+	Description  string         `json:"description,omitempty"`
+	InputSchema  map[string]any `json:"input_schema,omitempty"`
+	OutputSchema map[string]any `json:"output_schema,omitempty"`
+}
+
+type ListToolsResponse struct {
+	Tools []Tool `json:"tools,omitempty"`
+}
+
+type CallToolRequest struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Content is one item of a tool result: text, an inline image or audio
+// file, a link to a stored file or an embedded resource
+type Content struct {
+	Type     string `json:"type"` // text, image, audio, resource_link or resource
+	Text     string `json:"text,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	URI      string `json:"uri,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// ToolError is the classification of a failed call, as in _meta.error over
+// MCP
+type ToolError struct {
+	Code              string `json:"code"`
+	Message           string `json:"message"`
+	Retryable         bool   `json:"retryable,omitempty"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+type CallToolResponse struct {
+	Output  map[string]any `json:"output,omitempty"` // The tool's structured output
+	Content []Content      `json:"content,omitempty"`
+	IsError bool           `json:"is_error,omitempty"`
+	Error   *ToolError     `json:"error,omitempty"`
+	Meta    map[string]any `json:"meta,omitempty"` // _meta of the result, e.g. quota
+}
+
+type Progress struct {
+	Progress float64 `json:"progress"`
+	Total    float64 `json:"total,omitempty"`
+	Message  string  `json:"message,omitempty"`
+}
+
+// ToolEvent is a message of StreamTool: progress of the call, or its result
+// as the last message
+type ToolEvent struct {
+	Progress *Progress         `json:"progress,omitempty"`
+	Result   *CallToolResponse `json:"result,omitempty"`
+}
+
+// newMessage returns an empty dynamic message of the API's type name
+func newMessage(name string) *dynamicpb.Message {
+	return dynamicpb.NewMessage(messageDescriptor(name))
+}
+
+// toMessage converts v to a new message of type name
+func toMessage(name string, v any) (*dynamicpb.Message, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	msg := newMessage(name)
+	if err := protojson.Unmarshal(data, msg); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", name, err)
+	}
+	return msg, nil
+}
+
+// fromMessage converts msg to v
+func fromMessage(msg proto.Message, v any) error {
+	data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
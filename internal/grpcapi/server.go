@@ -0,0 +1,255 @@
+// Package grpcapi serves the server's tools over a versioned gRPC API for
+// callers that do not speak MCP, such as batch pipelines. Calls go through
+// the service package, so they reach the same tool implementations as MCP
+// requests, behind the same authentication, tenants, priorities and quotas.
+//
+// The API is proto/geminimcp/v1/tools.proto. Its messages are served as
+// dynamic protobuf messages of the equivalent descriptor, which needs no
+// generated code in this module; callers generate their clients from the
+// .proto file, or use Client.
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"gemini-mcp/internal/service"
+	"gemini-mcp/internal/toolerr"
+)
+
+// Register registers the API on s, serving the calls with tools
+func Register(s *grpc.Server, tools *service.Tools) {
+	s.RegisterService(&serviceDesc, &server{tools: tools})
+}
+
+type server struct {
+	tools *service.Tools
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "ListTools", Handler: unary("ListToolsRequest", (*server).listTools)},
+		{MethodName: "CallTool", Handler: unary("CallToolRequest", (*server).callTool)},
+	},
+	Streams: []grpc.StreamDesc{
+		{StreamName: "StreamTool", Handler: streamTool, ServerStreams: true},
+	},
+	Metadata: protoFile.Path(),
+}
+
+// unary returns the handler of a unary method taking messages of type
+// request
+func unary(request string, handle func(*server, context.Context, any) (any, error)) func(any, context.Context, func(any) error, grpc.UnaryServerInterceptor) (any, error) {
+	return func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+		in := newMessage(request)
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req any) (any, error) {
+			return handle(srv.(*server), ctx, req)
+		}
+		if interceptor == nil {
+			return handler(ctx, in)
+		}
+		info := &grpc.UnaryServerInfo{Server: srv, FullMethod: method(strings.TrimSuffix(request, "Request"))}
+		return interceptor(ctx, in, info, handler)
+	}
+}
+
+func (s *server) listTools(ctx context.Context, _ any) (any, error) {
+	tools, err := s.tools.List(ctx, callerOf(ctx))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	var resp ListToolsResponse
+	for _, tool := range tools {
+		t := Tool{Name: tool.Name, Description: tool.Description}
+		if err := convert(tool.InputSchema, &t.InputSchema); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid input schema of %s: %v", tool.Name, err)
+		}
+		if err := convert(tool.OutputSchema, &t.OutputSchema); err != nil {
+			return nil, status.Errorf(codes.Internal, "invalid output schema of %s: %v", tool.Name, err)
+		}
+		resp.Tools = append(resp.Tools, t)
+	}
+	return encode("ListToolsResponse", resp)
+}
+
+func (s *server) callTool(ctx context.Context, in any) (any, error) {
+	var req CallToolRequest
+	if err := fromMessage(in.(proto.Message), &req); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+	result, err := s.tools.Call(ctx, callerOf(ctx), req.Name, req.Arguments, nil)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp, err := toResponse(result)
+	if err != nil {
+		return nil, err
+	}
+	return encode("CallToolResponse", resp)
+}
+
+// streamTool serves StreamTool: progress events while the tool runs, then
+// the result
+func streamTool(srv any, stream grpc.ServerStream) error {
+	in := newMessage("CallToolRequest")
+	if err := stream.RecvMsg(in); err != nil {
+		return err
+	}
+	var req CallToolRequest
+	if err := fromMessage(in, &req); err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid request: %v", err)
+	}
+
+	// Progress is sent from the MCP client's notification handler while the
+	// call runs; a failed send ends the call
+	ctx, cancel := context.WithCancelCause(stream.Context())
+	defer cancel(nil)
+	progress := func(p service.Progress) {
+		msg, err := toMessage("ToolEvent", ToolEvent{Progress: &Progress{Progress: p.Progress, Total: p.Total, Message: p.Message}})
+		if err == nil {
+			err = stream.SendMsg(msg)
+		}
+		if err != nil {
+			cancel(err)
+		}
+	}
+	result, err := srv.(*server).tools.Call(ctx, callerOf(ctx), req.Name, req.Arguments, progress)
+	if cause := context.Cause(ctx); err != nil && cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	if err != nil {
+		return statusError(err)
+	}
+	resp, err := toResponse(result)
+	if err != nil {
+		return err
+	}
+	msg, err := encode("ToolEvent", ToolEvent{Result: &resp})
+	if err != nil {
+		return err
+	}
+	return stream.SendMsg(msg)
+}
+
+// callerOf returns the caller of a gRPC call. Its metadata becomes the HTTP
+// headers of the MCP request, so credentials are sent as the authorization
+// key and headers like X-Priority under their own names.
+func callerOf(ctx context.Context) service.Caller {
+	caller := service.Caller{Header: make(http.Header)}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for key, values := range md {
+		if key == "authorization" || strings.HasPrefix(key, "x-") {
+			caller.Header[http.CanonicalHeaderKey(key)] = values
+		}
+	}
+	// The :authority is the host the caller dialed, which download links
+	// must point back at
+	if authority := md.Get(":authority"); len(authority) > 0 {
+		caller.Host = authority[0]
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			caller.RemoteAddr = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			caller.TLS = &info.State
+		}
+	}
+	return caller
+}
+
+// statusError converts an error of the service to a gRPC status
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrRateLimited):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, service.ErrInvalidCall):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// toResponse converts the result of a tool call
+func toResponse(result *mcp.CallToolResult) (CallToolResponse, error) {
+	resp := CallToolResponse{IsError: result.IsError}
+	if err := convert(result.StructuredContent, &resp.Output); err != nil {
+		return resp, status.Errorf(codes.Internal, "invalid tool output: %v", err)
+	}
+	for _, content := range result.Content {
+		switch c := content.(type) {
+		case *mcp.TextContent:
+			resp.Content = append(resp.Content, Content{Type: "text", Text: c.Text})
+		case *mcp.ImageContent:
+			resp.Content = append(resp.Content, Content{Type: "image", MIMEType: c.MIMEType, Data: c.Data})
+		case *mcp.AudioContent:
+			resp.Content = append(resp.Content, Content{Type: "audio", MIMEType: c.MIMEType, Data: c.Data})
+		case *mcp.ResourceLink:
+			resp.Content = append(resp.Content, Content{Type: "resource_link", MIMEType: c.MIMEType, URI: c.URI, Text: c.Description})
+		case *mcp.EmbeddedResource:
+			if c.Resource != nil {
+				resp.Content = append(resp.Content, Content{Type: "resource", MIMEType: c.Resource.MIMEType, URI: c.Resource.URI, Text: c.Resource.Text, Data: c.Resource.Blob})
+			}
+		}
+	}
+	// Meta is a Struct, whose values are JSON values
+	if err := convert(result.Meta, &resp.Meta); err != nil {
+		return resp, status.Errorf(codes.Internal, "invalid result metadata: %v", err)
+	}
+	if info, ok := resp.Meta["error"]; ok {
+		var toolErr toolerr.Info
+		if err := convert(info, &toolErr); err == nil && toolErr.Code != "" {
+			resp.Error = &ToolError{
+				Code:              string(toolErr.Code),
+				Message:           toolErr.Message,
+				Retryable:         toolErr.Retryable,
+				RetryAfterSeconds: toolErr.RetryAfterSeconds,
+			}
+		}
+	}
+	return resp, nil
+}
+
+// encode converts v to a message of type name, failing the call if it
+// cannot be
+func encode(name string, v any) (proto.Message, error) {
+	msg, err := toMessage(name, v)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return msg, nil
+}
+
+// convert converts v to out through JSON; nil leaves out unchanged
+func convert(v, out any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
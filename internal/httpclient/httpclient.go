@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// PoolConfig tunes the shared connection pool used for Gemini API and S3 calls
+type PoolConfig struct {
+	MaxIdleConns        int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // Idle connections kept per host
+	IdleConnTimeout     time.Duration // How long an idle connection is kept open
+}
+
+// NewTransport returns an HTTP/2-capable transport with a connection pool
+// sized by cfg. Zero values fall back to net/http defaults.
+func NewTransport(cfg PoolConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// WithCABundle returns a copy of t that also trusts the PEM-encoded CA
+// certificates in file, e.g. of an S3-compatible store behind a private CA.
+// The copy keeps the settings of t but has its own connection pool.
+func WithCABundle(t *http.Transport, file string) (*http.Transport, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", file)
+	}
+	clone := t.Clone()
+	if clone.TLSClientConfig == nil {
+		clone.TLSClientConfig = &tls.Config{}
+	}
+	clone.TLSClientConfig.RootCAs = roots
+	return clone, nil
+}
+
+// HeadPinger returns a ping function that opens (or reuses) a connection to
+// url with a HEAD request. Any HTTP response counts as success, since only the
+// connection matters.
+func HeadPinger(client *http.Client, url string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		// Drain so the connection returns to the pool
+		io.Copy(io.Discard, resp.Body)
+		return resp.Body.Close()
+	}
+}
+
+// KeepWarm pings once immediately and then every interval until ctx is done,
+// so that pooled connections are established before the first real call and
+// are not dropped during idle periods. An interval of 0 pings only once. It
+// blocks, so run it in the background.
+func KeepWarm(ctx context.Context, name string, interval time.Duration, ping func(context.Context) error) {
+	warm := func(first bool) {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		start := time.Now()
+		if err := ping(pingCtx); err != nil {
+			log.Printf("Warning: failed to warm %s connection: %v", name, err)
+			return
+		}
+		if first {
+			log.Printf("Warmed %s connection in %v", name, time.Since(start).Round(time.Millisecond))
+		}
+	}
+
+	warm(true)
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			warm(false)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
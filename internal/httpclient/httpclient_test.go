@@ -0,0 +1,80 @@
+package httpclient
+
+import (
+	"context"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestKeepWarmReusesConnection(t *testing.T) {
+	var requests, conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client := &http.Client{Transport: NewTransport(PoolConfig{MaxIdleConns: 4, MaxIdleConnsPerHost: 2, IdleConnTimeout: time.Minute})}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go KeepWarm(ctx, "test", 10*time.Millisecond, HeadPinger(client, srv.URL))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for requests.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected repeated pings, got %d", requests.Load())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("expected pings to reuse one connection, opened %d", n)
+	}
+}
+
+func TestWithCABundle(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	shared := NewTransport(PoolConfig{})
+	if _, err := (&http.Client{Transport: shared}).Get(srv.URL); err == nil {
+		t.Fatal("request to a server with an untrusted certificate succeeded")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trusting, err := WithCABundle(shared, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: trusting}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("request with the CA bundle failed: %v", err)
+	}
+	resp.Body.Close()
+	if shared.TLSClientConfig != nil && shared.TLSClientConfig.RootCAs != nil {
+		t.Error("WithCABundle changed the shared transport")
+	}
+
+	if err := os.WriteFile(bundle, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WithCABundle(shared, bundle); err == nil {
+		t.Error("WithCABundle accepted a file without certificates")
+	}
+}
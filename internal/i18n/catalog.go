@@ -0,0 +1,75 @@
+package i18n
+
+// catalog maps each supported language to translations of the English
+// message templates. Translations must keep the format verbs of the English
+// template, in the same order.
+var catalog = map[string]map[string]string{
+	"es": {
+		"prompt is required":                                "el parámetro prompt es obligatorio",
+		"input_image_path is required":                      "el parámetro input_image_path es obligatorio",
+		"edit_prompt is required":                           "el parámetro edit_prompt es obligatorio",
+		"Successfully generated %d image(s) using %s":       "Se generaron %d imagen(es) con %s",
+		"Generated %d image(s). Download URLs:\n":           "Se generaron %d imagen(es). URLs de descarga:\n",
+		"Edited image. Download URLs:\n":                    "Imagen editada. URLs de descarga:\n",
+		"Combined image. Download URLs:\n":                  "Imagen combinada. URLs de descarga:\n",
+		"\nURLs expire at: %s":                              "\nLas URLs caducan el: %s",
+		"Video generated. Download URL:\n%s":                "Video generado. URL de descarga:\n%s",
+		"Text-to-video generated. Download URL:\n%s":        "Video generado a partir de texto. URL de descarga:\n%s",
+		"Image-to-video generated. Download URL:\n%s":       "Video generado a partir de imagen. URL de descarga:\n%s",
+		"\n\nURL expires at: %s":                            "\n\nLa URL caduca el: %s",
+		"first_frame_path and last_frame_path are required": "se requieren los parámetros first_frame_path y last_frame_path",
+		"Interpolated video generated. Download URL:\n%s":   "Video interpolado generado. URL de descarga:\n%s",
+		"Video generation started. Check its progress with veo_job_status and operation_id %s.": "Generación de video iniciada. Consulta su progreso con veo_job_status y operation_id %s.",
+	},
+	"ja": {
+		"prompt is required":                                "prompt は必須です",
+		"input_image_path is required":                      "input_image_path は必須です",
+		"edit_prompt is required":                           "edit_prompt は必須です",
+		"Successfully generated %d image(s) using %s":       "%d 枚の画像を生成しました（モデル: %s）",
+		"Generated %d image(s). Download URLs:\n":           "%d 枚の画像を生成しました。ダウンロード URL:\n",
+		"Edited image. Download URLs:\n":                    "画像を編集しました。ダウンロード URL:\n",
+		"Combined image. Download URLs:\n":                  "画像を合成しました。ダウンロード URL:\n",
+		"\nURLs expire at: %s":                              "\nURL の有効期限: %s",
+		"Video generated. Download URL:\n%s":                "動画を生成しました。ダウンロード URL:\n%s",
+		"Text-to-video generated. Download URL:\n%s":        "テキストから動画を生成しました。ダウンロード URL:\n%s",
+		"Image-to-video generated. Download URL:\n%s":       "画像から動画を生成しました。ダウンロード URL:\n%s",
+		"\n\nURL expires at: %s":                            "\n\nURL の有効期限: %s",
+		"first_frame_path and last_frame_path are required": "first_frame_path と last_frame_path は必須です",
+		"Interpolated video generated. Download URL:\n%s":   "補間動画を生成しました。ダウンロード URL:\n%s",
+		"Video generation started. Check its progress with veo_job_status and operation_id %s.": "動画の生成を開始しました。veo_job_status と operation_id %s で進捗を確認してください。",
+	},
+	"zh": {
+		"prompt is required":                                "prompt 为必填参数",
+		"input_image_path is required":                      "input_image_path 为必填参数",
+		"edit_prompt is required":                           "edit_prompt 为必填参数",
+		"Successfully generated %d image(s) using %s":       "已生成 %d 张图片（模型：%s）",
+		"Generated %d image(s). Download URLs:\n":           "已生成 %d 张图片。下载链接：\n",
+		"Edited image. Download URLs:\n":                    "图片已编辑。下载链接：\n",
+		"Combined image. Download URLs:\n":                  "图片已合成。下载链接：\n",
+		"\nURLs expire at: %s":                              "\n链接过期时间：%s",
+		"Video generated. Download URL:\n%s":                "视频已生成。下载链接：\n%s",
+		"Text-to-video generated. Download URL:\n%s":        "已根据文本生成视频。下载链接：\n%s",
+		"Image-to-video generated. Download URL:\n%s":       "已根据图片生成视频。下载链接：\n%s",
+		"\n\nURL expires at: %s":                            "\n\n链接过期时间：%s",
+		"first_frame_path and last_frame_path are required": "first_frame_path 和 last_frame_path 为必填参数",
+		"Interpolated video generated. Download URL:\n%s":   "插帧视频已生成。下载链接：\n%s",
+		"Video generation started. Check its progress with veo_job_status and operation_id %s.": "视频生成已开始。请使用 veo_job_status 和 operation_id %s 查看进度。",
+	},
+	"hi": {
+		"prompt is required":                                "prompt आवश्यक है",
+		"input_image_path is required":                      "input_image_path आवश्यक है",
+		"edit_prompt is required":                           "edit_prompt आवश्यक है",
+		"Successfully generated %d image(s) using %s":       "%d छवि(याँ) बनाई गईं (मॉडल: %s)",
+		"Generated %d image(s). Download URLs:\n":           "%d छवि(याँ) बनाई गईं। डाउनलोड URL:\n",
+		"Edited image. Download URLs:\n":                    "छवि संपादित की गई। डाउनलोड URL:\n",
+		"Combined image. Download URLs:\n":                  "छवियाँ संयोजित की गईं। डाउनलोड URL:\n",
+		"\nURLs expire at: %s":                              "\nURL की समाप्ति: %s",
+		"Video generated. Download URL:\n%s":                "वीडियो बनाया गया। डाउनलोड URL:\n%s",
+		"Text-to-video generated. Download URL:\n%s":        "टेक्स्ट से वीडियो बनाया गया। डाउनलोड URL:\n%s",
+		"Image-to-video generated. Download URL:\n%s":       "छवि से वीडियो बनाया गया। डाउनलोड URL:\n%s",
+		"\n\nURL expires at: %s":                            "\n\nURL की समाप्ति: %s",
+		"first_frame_path and last_frame_path are required": "first_frame_path और last_frame_path आवश्यक हैं",
+		"Interpolated video generated. Download URL:\n%s":   "इंटरपोलेटेड वीडियो बनाया गया। डाउनलोड URL:\n%s",
+		"Video generation started. Check its progress with veo_job_status and operation_id %s.": "वीडियो बनाना शुरू हुआ। veo_job_status और operation_id %s से प्रगति देखें।",
+	},
+}
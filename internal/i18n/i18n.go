@@ -0,0 +1,80 @@
+// Package i18n localizes the human-readable text of tool results. Messages
+// are written in English at the call site, as fmt format strings, and looked
+// up in a per-language template catalog; messages without a translation are
+// returned in English.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is the language messages are written in
+const DefaultLanguage = "en"
+
+type languageKey struct{}
+
+// WithLanguage returns a context whose results are localized into lang
+func WithLanguage(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, languageKey{}, Normalize(lang))
+}
+
+// Language returns the language results for ctx are localized into
+func Language(ctx context.Context) string {
+	if lang, ok := ctx.Value(languageKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return DefaultLanguage
+}
+
+// Normalize reduces a language tag such as "es-MX" or "zh_Hans" to the
+// catalog language it uses, returning DefaultLanguage for unsupported tags
+func Normalize(lang string) string {
+	if base, ok := Lookup(lang); ok {
+		return base
+	}
+	return DefaultLanguage
+}
+
+// Lookup reduces a language tag to the catalog language it uses and reports
+// whether results can be returned in that language
+func Lookup(lang string) (string, bool) {
+	base, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(lang)), "-")
+	base, _, _ = strings.Cut(base, "_")
+	if base == DefaultLanguage {
+		return base, true
+	}
+	_, ok := catalog[base]
+	return base, ok
+}
+
+// Supported lists the languages results can be returned in
+func Supported() []string {
+	langs := []string{DefaultLanguage}
+	for lang := range catalog {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs[1:])
+	return langs
+}
+
+// Translate returns the template for an English message in the language of ctx
+func Translate(ctx context.Context, message string) string {
+	if translated, ok := catalog[Language(ctx)][message]; ok {
+		return translated
+	}
+	return message
+}
+
+// Sprintf formats a message in the language of ctx
+func Sprintf(ctx context.Context, format string, args ...any) string {
+	return fmt.Sprintf(Translate(ctx, format), args...)
+}
+
+// Errorf returns an error whose message is in the language of ctx. Like
+// fmt.Errorf, %w wraps an error.
+func Errorf(ctx context.Context, format string, args ...any) error {
+	return fmt.Errorf(Translate(ctx, format), args...)
+}
@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	for in, want := range map[string]string{
+		"":        "en",
+		"es-MX":   "es",
+		"ES":      "es",
+		"zh_Hans": "zh",
+		"ja":      "ja",
+		"klingon": "en",
+	} {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if lang, ok := Lookup("en-GB"); !ok || lang != "en" {
+		t.Errorf("Lookup(en-GB) = %q, %v", lang, ok)
+	}
+	if lang, ok := Lookup("hi-IN"); !ok || lang != "hi" {
+		t.Errorf("Lookup(hi-IN) = %q, %v", lang, ok)
+	}
+	if _, ok := Lookup("tlh"); ok {
+		t.Error("Lookup(tlh) reported an unsupported language as supported")
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	ctx := WithLanguage(context.Background(), "es-MX")
+	if got := Sprintf(ctx, "Generated %d image(s). Download URLs:\n", 2); !strings.HasPrefix(got, "Se generaron 2") {
+		t.Errorf("Sprintf = %q", got)
+	}
+	if got := Sprintf(ctx, "untranslated %s", "message"); got != "untranslated message" {
+		t.Errorf("untranslated message = %q, want English fallback", got)
+	}
+	if got := Sprintf(context.Background(), "Edited image. Download URLs:\n"); got != "Edited image. Download URLs:\n" {
+		t.Errorf("default language = %q, want English", got)
+	}
+	if err := Errorf(WithLanguage(context.Background(), "ja"), "prompt is required"); err.Error() != "prompt は必須です" {
+		t.Errorf("Errorf = %q", err)
+	}
+}
+
+// verbs matches fmt verbs such as %d, %s and %.2f
+var verbs = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func TestCatalogKeepsFormatVerbs(t *testing.T) {
+	for lang, messages := range catalog {
+		for english, translated := range messages {
+			want := strings.Join(verbs.FindAllString(english, -1), " ")
+			if got := strings.Join(verbs.FindAllString(translated, -1), " "); got != want {
+				t.Errorf("%s translation of %q has verbs %q, want %q", lang, english, got, want)
+			}
+		}
+	}
+}
+
+func TestCatalogLanguagesTranslateTheSameMessages(t *testing.T) {
+	reference := catalog["es"]
+	for lang, messages := range catalog {
+		for english := range reference {
+			if _, ok := messages[english]; !ok {
+				t.Errorf("%s is missing a translation of %q", lang, english)
+			}
+		}
+		if len(messages) != len(reference) {
+			t.Errorf("%s has %d messages, es has %d", lang, len(messages), len(reference))
+		}
+	}
+}
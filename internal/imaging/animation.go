@@ -0,0 +1,337 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+)
+
+// Frame is one frame of an animation. For GIFs, Image holds the frame
+// composited onto the full canvas. For WebP, which has no decoder in the
+// standard library, Data holds the frame as a standalone still WebP that
+// models accept as input, placed at Rect on the canvas.
+type Frame struct {
+	Image image.Image
+	Data  []byte
+	Rect  image.Rectangle
+	Delay int // Display time in milliseconds
+}
+
+// Animation is a decoded animated GIF or WebP
+type Animation struct {
+	Format    string // "gif" or "webp"
+	Width     int
+	Height    int
+	Frames    []Frame
+	LoopCount int // 0 loops forever
+}
+
+// IsAnimated reports whether data is a GIF with more than one frame or an
+// animated WebP
+func IsAnimated(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		g, err := gif.DecodeAll(bytes.NewReader(data))
+		return err == nil && len(g.Image) > 1
+	case isWebP(data):
+		flags, ok := webpFlags(data)
+		return ok && flags&webpAnimationFlag != 0
+	}
+	return false
+}
+
+// DecodeAnimation decodes an animated GIF or WebP into its frames
+func DecodeAnimation(data []byte) (*Animation, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return decodeGIF(data)
+	case isWebP(data):
+		return decodeWebP(data)
+	}
+	return nil, fmt.Errorf("not an animated GIF or WebP")
+}
+
+// RepresentativeFrame returns the first frame of an animation as a still image
+// and its MIME type: PNG for GIFs and a still WebP for animated WebP
+func RepresentativeFrame(data []byte) ([]byte, string, error) {
+	anim, err := DecodeAnimation(data)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(anim.Frames) == 0 {
+		return nil, "", fmt.Errorf("animation has no frames")
+	}
+	first := anim.Frames[0]
+	if first.Data != nil {
+		return first.Data, "image/webp", nil
+	}
+	png, err := EncodePNG(first.Image)
+	if err != nil {
+		return nil, "", err
+	}
+	return png, "image/png", nil
+}
+
+// EncodeGIF encodes frames, each drawn over the full width x height canvas,
+// as an animated GIF. delays are in milliseconds.
+func EncodeGIF(frames []image.Image, delays []int, loopCount int) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames to encode")
+	}
+	out := &gif.GIF{LoopCount: loopCount}
+	for i, frame := range frames {
+		bounds := frame.Bounds()
+		paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.Plan9)
+		draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), frame, bounds.Min)
+
+		delay := 100
+		if i < len(delays) {
+			delay = delays[i]
+		}
+		out.Image = append(out.Image, paletted)
+		out.Delay = append(out.Delay, max(2, delay/10)) // GIF delays are in 100ths of a second
+		out.Disposal = append(out.Disposal, gif.DisposalNone)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return nil, fmt.Errorf("failed to encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Sample returns an animation with at most maxFrames frames picked evenly
+// across a, each dropped frame's display time added to the frame before it so
+// that the total duration is unchanged
+func (a *Animation) Sample(maxFrames int) *Animation {
+	if maxFrames <= 0 || len(a.Frames) <= maxFrames {
+		return a
+	}
+	sampled := *a
+	sampled.Frames = make([]Frame, 0, maxFrames)
+	for i := 0; i < maxFrames; i++ {
+		start, end := i*len(a.Frames)/maxFrames, (i+1)*len(a.Frames)/maxFrames
+		frame := a.Frames[start]
+		for _, dropped := range a.Frames[start+1 : end] {
+			frame.Delay += dropped.Delay
+		}
+		sampled.Frames = append(sampled.Frames, frame)
+	}
+	return &sampled
+}
+
+// Reassemble encodes edited versions of a's frames as an animated GIF the
+// size of a's canvas. Each edited frame is scaled to its original frame
+// rectangle and drawn over the frames before it, so partial WebP frames
+// update only their own region.
+func (a *Animation) Reassemble(edited []image.Image) ([]byte, error) {
+	if len(edited) != len(a.Frames) {
+		return nil, fmt.Errorf("got %d edited frames for %d frames", len(edited), len(a.Frames))
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, a.Width, a.Height))
+	frames := make([]image.Image, len(edited))
+	delays := make([]int, len(edited))
+	for i, img := range edited {
+		rect := a.Frames[i].Rect
+		draw.Draw(canvas, rect, Resize(img, rect.Dx(), rect.Dy()), image.Point{}, draw.Over)
+		frame := image.NewRGBA(canvas.Bounds())
+		copy(frame.Pix, canvas.Pix)
+		frames[i] = frame
+		delays[i] = a.Frames[i].Delay
+	}
+	return EncodeGIF(frames, delays, a.LoopCount)
+}
+
+// decodeGIF composites each GIF frame onto the canvas, honouring disposal
+// methods, so that every frame is a complete picture
+func decodeGIF(data []byte) (*Animation, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GIF: %w", err)
+	}
+
+	width, height := g.Config.Width, g.Config.Height
+	if width == 0 || height == 0 {
+		for _, img := range g.Image {
+			width, height = max(width, img.Bounds().Max.X), max(height, img.Bounds().Max.Y)
+		}
+	}
+
+	anim := &Animation{Format: "gif", Width: width, Height: height, LoopCount: g.LoopCount}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i, img := range g.Image {
+		var previous *image.RGBA
+		disposal := byte(0)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(canvas.Bounds())
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		frame := image.NewRGBA(canvas.Bounds())
+		copy(frame.Pix, canvas.Pix)
+
+		delay := 100
+		if i < len(g.Delay) {
+			delay = g.Delay[i] * 10
+		}
+		anim.Frames = append(anim.Frames, Frame{Image: frame, Rect: canvas.Bounds(), Delay: delay})
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+	return anim, nil
+}
+
+const (
+	webpAnimationFlag = 0x02
+	webpAlphaFlag     = 0x10
+)
+
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
+
+// webpChunk is one chunk of a RIFF container
+type webpChunk struct {
+	id      string
+	payload []byte
+}
+
+// webpChunks splits a RIFF payload into chunks
+func webpChunks(data []byte) ([]webpChunk, error) {
+	var chunks []webpChunk
+	for pos := 0; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size < 0 || pos+8+size > len(data) {
+			return nil, fmt.Errorf("truncated WebP chunk %q", id)
+		}
+		chunks = append(chunks, webpChunk{id: id, payload: data[pos+8 : pos+8+size]})
+		pos += 8 + size + size%2 // chunks are padded to an even size
+	}
+	return chunks, nil
+}
+
+func webpFlags(data []byte) (byte, bool) {
+	chunks, err := webpChunks(data[12:])
+	if err != nil || len(chunks) == 0 || chunks[0].id != "VP8X" || len(chunks[0].payload) < 1 {
+		return 0, false
+	}
+	return chunks[0].payload[0], true
+}
+
+// decodeWebP splits an animated WebP into standalone still WebP frames
+func decodeWebP(data []byte) (*Animation, error) {
+	chunks, err := webpChunks(data[12:])
+	if err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 || chunks[0].id != "VP8X" || len(chunks[0].payload) < 10 {
+		return nil, fmt.Errorf("not an animated WebP")
+	}
+	anim := &Animation{
+		Format: "webp",
+		Width:  int(uint24(chunks[0].payload[4:])) + 1,
+		Height: int(uint24(chunks[0].payload[7:])) + 1,
+	}
+
+	for _, c := range chunks[1:] {
+		switch c.id {
+		case "ANIM":
+			if len(c.payload) >= 6 {
+				anim.LoopCount = int(binary.LittleEndian.Uint16(c.payload[4:]))
+			}
+		case "ANMF":
+			if len(c.payload) < 16 {
+				return nil, fmt.Errorf("truncated WebP frame")
+			}
+			x, y := int(uint24(c.payload))*2, int(uint24(c.payload[3:]))*2
+			w, h := int(uint24(c.payload[6:]))+1, int(uint24(c.payload[9:]))+1
+			still, err := stillWebP(c.payload[16:], w, h)
+			if err != nil {
+				return nil, err
+			}
+			anim.Frames = append(anim.Frames, Frame{
+				Data:  still,
+				Rect:  image.Rect(x, y, x+w, y+h),
+				Delay: int(uint24(c.payload[12:])),
+			})
+		}
+	}
+	if len(anim.Frames) == 0 {
+		return nil, fmt.Errorf("animated WebP has no frames")
+	}
+	return anim, nil
+}
+
+// stillWebP wraps the bitstream chunks of one animation frame (an optional
+// ALPH chunk followed by VP8 or VP8L) into a standalone WebP file
+func stillWebP(frameData []byte, width, height int) ([]byte, error) {
+	chunks, err := webpChunks(frameData)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	hasAlpha := false
+	for _, c := range chunks {
+		if c.id == "ALPH" {
+			hasAlpha = true
+		}
+	}
+	if hasAlpha {
+		// ALPH chunks are only valid in the extended format
+		vp8x := make([]byte, 10)
+		vp8x[0] = webpAlphaFlag
+		putUint24(vp8x[4:], uint32(width-1))
+		putUint24(vp8x[7:], uint32(height-1))
+		writeWebPChunk(&body, "VP8X", vp8x)
+	}
+	found := false
+	for _, c := range chunks {
+		if c.id == "ALPH" || c.id == "VP8 " || c.id == "VP8L" {
+			writeWebPChunk(&body, c.id, c.payload)
+			found = found || c.id != "ALPH"
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("WebP frame has no image data")
+	}
+
+	out := make([]byte, 12, 12+body.Len())
+	copy(out, "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+body.Len()))
+	copy(out[8:], "WEBP")
+	return append(out, body.Bytes()...), nil
+}
+
+func writeWebPChunk(buf *bytes.Buffer, id string, payload []byte) {
+	header := make([]byte, 8)
+	copy(header, id)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(payload)))
+	buf.Write(header)
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+func uint24(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func putUint24(b []byte, v uint32) {
+	b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16)
+}
@@ -0,0 +1,217 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/gif"
+	"testing"
+)
+
+// testGIF is a 3-frame 8x8 animation: a red background, then a blue square
+// in the top-left corner that is cleared afterwards, then a green square in
+// the bottom-right corner
+func testGIF(t *testing.T) []byte {
+	t.Helper()
+	fill := func(r image.Rectangle, c color.Color) *image.Paletted {
+		img := image.NewPaletted(r, palette.Plan9)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			fill(image.Rect(0, 0, 8, 8), color.RGBA{255, 0, 0, 255}),
+			fill(image.Rect(0, 0, 4, 4), color.RGBA{0, 0, 255, 255}),
+			fill(image.Rect(4, 4, 8, 8), color.RGBA{0, 255, 0, 255}),
+		},
+		Delay:    []int{10, 20, 30},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+		Config:   image.Config{Width: 8, Height: 8},
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// testWebP builds an animated WebP container with two frames. The frame
+// bitstreams are placeholders, which is enough to exercise the container
+// handling.
+func testWebP() []byte {
+	var body bytes.Buffer
+	vp8x := make([]byte, 10)
+	vp8x[0] = webpAnimationFlag | webpAlphaFlag
+	putUint24(vp8x[4:], 15)
+	putUint24(vp8x[7:], 9)
+	writeWebPChunk(&body, "VP8X", vp8x)
+	writeWebPChunk(&body, "ANIM", []byte{0, 0, 0, 0, 3, 0})
+
+	frame := func(x, y, w, h, duration int, sub func(*bytes.Buffer)) []byte {
+		header := make([]byte, 16)
+		putUint24(header, uint32(x/2))
+		putUint24(header[3:], uint32(y/2))
+		putUint24(header[6:], uint32(w-1))
+		putUint24(header[9:], uint32(h-1))
+		putUint24(header[12:], uint32(duration))
+		buf := bytes.NewBuffer(header)
+		sub(buf)
+		return buf.Bytes()
+	}
+	writeWebPChunk(&body, "ANMF", frame(0, 0, 16, 10, 80, func(b *bytes.Buffer) {
+		writeWebPChunk(b, "VP8L", []byte{0x2f, 1, 2, 3, 4})
+	}))
+	writeWebPChunk(&body, "ANMF", frame(4, 2, 6, 6, 120, func(b *bytes.Buffer) {
+		writeWebPChunk(b, "ALPH", []byte{0, 9})
+		writeWebPChunk(b, "VP8 ", []byte{1, 2, 3, 4})
+	}))
+
+	out := []byte("RIFF\x00\x00\x00\x00WEBP")
+	binary.LittleEndian.PutUint32(out[4:], uint32(4+body.Len()))
+	return append(out, body.Bytes()...)
+}
+
+func chunkIDs(t *testing.T, webp []byte) []string {
+	t.Helper()
+	if !isWebP(webp) {
+		t.Fatalf("not a WebP file: %q", webp[:min(12, len(webp))])
+	}
+	if size := int(binary.LittleEndian.Uint32(webp[4:])); size != len(webp)-8 {
+		t.Errorf("RIFF size %d, want %d", size, len(webp)-8)
+	}
+	chunks, err := webpChunks(webp[12:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range chunks {
+		ids = append(ids, c.id)
+	}
+	return ids
+}
+
+func TestIsAnimated(t *testing.T) {
+	still, err := EncodePNG(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var oneFrame bytes.Buffer
+	if err := gif.Encode(&oneFrame, image.NewRGBA(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want bool
+	}{
+		"animated GIF":  {testGIF(t), true},
+		"animated WebP": {testWebP(), true},
+		"one-frame GIF": {oneFrame.Bytes(), false},
+		"PNG":           {still, false},
+	} {
+		if got := IsAnimated(tc.data); got != tc.want {
+			t.Errorf("%s: IsAnimated = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestDecodeGIFComposites(t *testing.T) {
+	anim, err := DecodeAnimation(testGIF(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Frames) != 3 || anim.Width != 8 || anim.Height != 8 {
+		t.Fatalf("got %d frames of %dx%d", len(anim.Frames), anim.Width, anim.Height)
+	}
+	if d := anim.Frames[2].Delay; d != 300 {
+		t.Errorf("frame 3 delay %dms, want 300", d)
+	}
+
+	at := func(frame, x, y int) color.RGBA {
+		return anim.Frames[frame].Image.(*image.RGBA).RGBAAt(x, y)
+	}
+	red, blue, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}, color.RGBA{0, 255, 0, 255}
+	if got := at(1, 1, 1); got != blue {
+		t.Errorf("frame 2 top-left = %v, want blue", got)
+	}
+	if got := at(1, 6, 6); got != red {
+		t.Errorf("frame 2 bottom-right = %v, want the red background", got)
+	}
+	// Frame 2 is disposed to the previous canvas, so frame 3 shows red again
+	if got := at(2, 1, 1); got != red {
+		t.Errorf("frame 3 top-left = %v, want red after disposal", got)
+	}
+	if got := at(2, 6, 6); got != green {
+		t.Errorf("frame 3 bottom-right = %v, want green", got)
+	}
+}
+
+func TestDecodeWebPFrames(t *testing.T) {
+	anim, err := DecodeAnimation(testWebP())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anim.Width != 16 || anim.Height != 10 || anim.LoopCount != 3 || len(anim.Frames) != 2 {
+		t.Fatalf("got %dx%d loop %d with %d frames", anim.Width, anim.Height, anim.LoopCount, len(anim.Frames))
+	}
+
+	second := anim.Frames[1]
+	if second.Rect != image.Rect(4, 2, 10, 8) || second.Delay != 120 {
+		t.Errorf("frame 2 at %v for %dms", second.Rect, second.Delay)
+	}
+	if ids := chunkIDs(t, anim.Frames[0].Data); len(ids) != 1 || ids[0] != "VP8L" {
+		t.Errorf("opaque frame chunks %v, want [VP8L]", ids)
+	}
+	ids := chunkIDs(t, second.Data)
+	if len(ids) != 3 || ids[0] != "VP8X" || ids[1] != "ALPH" || ids[2] != "VP8 " {
+		t.Errorf("alpha frame chunks %v, want [VP8X ALPH VP8 ]", ids)
+	}
+	if flags, _ := webpFlags(second.Data); flags&webpAnimationFlag != 0 {
+		t.Error("still frame is flagged as animated")
+	}
+
+	still, mimeType, err := RepresentativeFrame(testWebP())
+	if err != nil || mimeType != "image/webp" || !bytes.Equal(still, anim.Frames[0].Data) {
+		t.Errorf("RepresentativeFrame = %s, %v", mimeType, err)
+	}
+}
+
+func TestSampleAndReassemble(t *testing.T) {
+	anim, err := DecodeAnimation(testGIF(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sampled := anim.Sample(2)
+	if len(sampled.Frames) != 2 || len(anim.Frames) != 3 {
+		t.Fatalf("Sample(2) kept %d frames (original now %d)", len(sampled.Frames), len(anim.Frames))
+	}
+	if total := sampled.Frames[0].Delay + sampled.Frames[1].Delay; total != 600 {
+		t.Errorf("sampled duration %dms, want 600", total)
+	}
+
+	// Edited frames come back at a different size and are scaled to the canvas
+	edited := make([]image.Image, len(sampled.Frames))
+	for i := range edited {
+		edited[i] = image.NewRGBA(image.Rect(0, 0, 16, 16))
+	}
+	data, err := sampled.Reassemble(edited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Image) != 2 || g.Config.Width != 8 || g.Config.Height != 8 {
+		t.Errorf("reassembled %d frames of %dx%d", len(g.Image), g.Config.Width, g.Config.Height)
+	}
+	if _, err := sampled.Reassemble(edited[:1]); err == nil {
+		t.Error("expected an error for a missing edited frame")
+	}
+}
@@ -0,0 +1,200 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// DefaultBackgroundTolerance is the RGB distance within which a pixel is
+// considered part of the background
+const DefaultBackgroundTolerance = 40
+
+// HasTransparency reports whether any pixel in img is not fully opaque
+func HasTransparency(img image.Image) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok {
+		return !opaque.Opaque()
+	}
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0xffff {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoveBackground makes the background of img transparent.
+// The background colour is estimated from the image border, then flood-filled
+// inward from every edge pixel so that interior regions of a similar colour
+// (e.g. white text on a white-background logo) are preserved. Pixels bordering
+// the removed region within twice the tolerance receive partial alpha to keep
+// edges smooth.
+func RemoveBackground(img image.Image, tolerance int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+
+	if w == 0 || h == 0 {
+		return dst
+	}
+
+	bg := borderColor(dst)
+	tol2 := tolerance * tolerance
+
+	removed := make([]bool, w*h)
+	queue := make([]int, 0, 2*(w+h))
+	push := func(x, y int) {
+		i := y*w + x
+		if removed[i] || colorDist2(dst.NRGBAAt(x, y), bg) > tol2 {
+			return
+		}
+		removed[i] = true
+		queue = append(queue, i)
+	}
+
+	for x := 0; x < w; x++ {
+		push(x, 0)
+		push(x, h-1)
+	}
+	for y := 0; y < h; y++ {
+		push(0, y)
+		push(w-1, y)
+	}
+
+	for len(queue) > 0 {
+		i := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		x, y := i%w, i/w
+		if x > 0 {
+			push(x-1, y)
+		}
+		if x < w-1 {
+			push(x+1, y)
+		}
+		if y > 0 {
+			push(x, y-1)
+		}
+		if y < h-1 {
+			push(x, y+1)
+		}
+	}
+
+	// Clear background and feather the pixels touching it
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			if removed[i] {
+				dst.Pix[dst.PixOffset(x, y)+3] = 0
+				continue
+			}
+			if !touches(removed, w, h, x, y) {
+				continue
+			}
+			d2 := colorDist2(dst.NRGBAAt(x, y), bg)
+			if d2 >= 4*tol2 {
+				continue
+			}
+			// Map distance in (tol, 2*tol) to alpha in (0, 255)
+			d := isqrt(d2)
+			alpha := (d - tolerance) * 255 / max(1, tolerance)
+			off := dst.PixOffset(x, y) + 3
+			dst.Pix[off] = uint8(max(0, min(int(dst.Pix[off]), alpha)))
+		}
+	}
+
+	return dst
+}
+
+// borderColor returns the most common colour along the image border,
+// quantised to 4 bits per channel to absorb compression noise
+func borderColor(img *image.NRGBA) color.NRGBA {
+	b := img.Bounds()
+	counts := make(map[uint16]int)
+	sums := make(map[uint16][3]int)
+
+	sample := func(x, y int) {
+		c := img.NRGBAAt(x, y)
+		key := uint16(c.R>>4)<<8 | uint16(c.G>>4)<<4 | uint16(c.B>>4)
+		counts[key]++
+		s := sums[key]
+		s[0] += int(c.R)
+		s[1] += int(c.G)
+		s[2] += int(c.B)
+		sums[key] = s
+	}
+
+	for x := b.Min.X; x < b.Max.X; x++ {
+		sample(x, b.Min.Y)
+		sample(x, b.Max.Y-1)
+	}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		sample(b.Min.X, y)
+		sample(b.Max.X-1, y)
+	}
+
+	var best uint16
+	bestCount := -1
+	for key, n := range counts {
+		if n > bestCount || (n == bestCount && key < best) {
+			best, bestCount = key, n
+		}
+	}
+
+	s := sums[best]
+	return color.NRGBA{
+		R: uint8(s[0] / bestCount),
+		G: uint8(s[1] / bestCount),
+		B: uint8(s[2] / bestCount),
+		A: 255,
+	}
+}
+
+func touches(removed []bool, w, h, x, y int) bool {
+	return (x > 0 && removed[y*w+x-1]) ||
+		(x < w-1 && removed[y*w+x+1]) ||
+		(y > 0 && removed[(y-1)*w+x]) ||
+		(y < h-1 && removed[(y+1)*w+x])
+}
+
+func colorDist2(c, bg color.NRGBA) int {
+	dr := int(c.R) - int(bg.R)
+	dg := int(c.G) - int(bg.G)
+	db := int(c.B) - int(bg.B)
+	return dr*dr + dg*dg + db*db
+}
+
+func isqrt(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	x := n
+	y := (x + 1) / 2
+	for y < x {
+		x = y
+		y = (x + n/x) / 2
+	}
+	return x
+}
+
+// TransparentPNG returns data as a PNG with a transparent background.
+// Images that already carry transparency are passed through (re-encoded as
+// PNG if necessary); otherwise the background is removed with RemoveBackground.
+func TransparentPNG(data []byte, tolerance int) ([]byte, error) {
+	img, format, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if HasTransparency(img) {
+		if format == "png" {
+			return data, nil
+		}
+		return EncodePNG(img)
+	}
+
+	return EncodePNG(RemoveBackground(img, tolerance))
+}
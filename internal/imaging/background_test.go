@@ -0,0 +1,39 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRemoveBackground(t *testing.T) {
+	// White canvas with a black ring enclosing a white centre
+	img := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			c := color.RGBA{255, 255, 255, 255}
+			if x >= 5 && x < 15 && y >= 5 && y < 15 {
+				c = color.RGBA{0, 0, 0, 255}
+				if x >= 8 && x < 12 && y >= 8 && y < 12 {
+					c = color.RGBA{255, 255, 255, 255}
+				}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	out := RemoveBackground(img, DefaultBackgroundTolerance)
+
+	if a := out.NRGBAAt(0, 0).A; a != 0 {
+		t.Errorf("expected border pixel to be transparent, got alpha %d", a)
+	}
+	if a := out.NRGBAAt(6, 6).A; a != 255 {
+		t.Errorf("expected subject pixel to stay opaque, got alpha %d", a)
+	}
+	if a := out.NRGBAAt(10, 10).A; a != 255 {
+		t.Errorf("expected enclosed white pixel to stay opaque, got alpha %d", a)
+	}
+	if !HasTransparency(out) {
+		t.Error("expected result to report transparency")
+	}
+}
@@ -0,0 +1,89 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Box is an axis-aligned bounding box in normalized coordinates (0-1, origin
+// at the top-left corner)
+type Box struct {
+	XMin float64 `json:"x_min"`
+	YMin float64 `json:"y_min"`
+	XMax float64 `json:"x_max"`
+	YMax float64 `json:"y_max"`
+}
+
+// BoxFromGemini converts a Gemini box_2d ([ymin, xmin, ymax, xmax] scaled to
+// 0-1000) into a normalized Box, clamping coordinates and ordering corners
+func BoxFromGemini(box2d []float64) (Box, bool) {
+	if len(box2d) != 4 {
+		return Box{}, false
+	}
+	norm := func(v float64) float64 {
+		v /= 1000
+		if v < 0 {
+			return 0
+		}
+		if v > 1 {
+			return 1
+		}
+		return v
+	}
+	b := Box{YMin: norm(box2d[0]), XMin: norm(box2d[1]), YMax: norm(box2d[2]), XMax: norm(box2d[3])}
+	if b.XMin > b.XMax {
+		b.XMin, b.XMax = b.XMax, b.XMin
+	}
+	if b.YMin > b.YMax {
+		b.YMin, b.YMax = b.YMax, b.YMin
+	}
+	if b.XMax-b.XMin <= 0 || b.YMax-b.YMin <= 0 {
+		return Box{}, false
+	}
+	return b, true
+}
+
+// Pixels returns the box in pixel coordinates of a width x height image
+func (b Box) Pixels(width, height int) image.Rectangle {
+	return image.Rect(
+		int(b.XMin*float64(width)+0.5), int(b.YMin*float64(height)+0.5),
+		int(b.XMax*float64(width)+0.5), int(b.YMax*float64(height)+0.5),
+	)
+}
+
+// boxColors are high-contrast outline colors cycled through by DrawBoxes
+var boxColors = []color.RGBA{
+	{230, 25, 75, 255}, {60, 180, 75, 255}, {0, 130, 200, 255}, {245, 130, 48, 255},
+	{145, 30, 180, 255}, {70, 240, 240, 255}, {240, 50, 230, 255}, {255, 225, 25, 255},
+}
+
+// DrawBoxes returns a copy of img with each box outlined. Boxes sharing a
+// group index share a color, so that objects with the same label match.
+func DrawBoxes(img image.Image, boxes []Box, groups []int) *image.RGBA {
+	bounds := img.Bounds()
+	out := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(out, out.Bounds(), img, bounds.Min, draw.Src)
+
+	// Scale line width with the image so outlines stay visible on large photos
+	thickness := max(2, min(bounds.Dx(), bounds.Dy())/200)
+
+	for i, b := range boxes {
+		group := i
+		if i < len(groups) {
+			group = groups[i]
+		}
+		c := image.NewUniform(boxColors[group%len(boxColors)])
+		r := b.Pixels(bounds.Dx(), bounds.Dy())
+		edges := []image.Rectangle{
+			image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+thickness),
+			image.Rect(r.Min.X, r.Max.Y-thickness, r.Max.X, r.Max.Y),
+			image.Rect(r.Min.X, r.Min.Y, r.Min.X+thickness, r.Max.Y),
+			image.Rect(r.Max.X-thickness, r.Min.Y, r.Max.X, r.Max.Y),
+		}
+		for _, e := range edges {
+			draw.Draw(out, e.Intersect(out.Bounds()), c, image.Point{}, draw.Src)
+		}
+	}
+	return out
+}
@@ -0,0 +1,47 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestBoxFromGemini(t *testing.T) {
+	b, ok := BoxFromGemini([]float64{100, 250, 600, 750})
+	if !ok {
+		t.Fatal("expected a valid box")
+	}
+	if b != (Box{XMin: 0.25, YMin: 0.1, XMax: 0.75, YMax: 0.6}) {
+		t.Errorf("got %+v", b)
+	}
+	if got := b.Pixels(200, 100); got != image.Rect(50, 10, 150, 60) {
+		t.Errorf("Pixels(200, 100) = %v", got)
+	}
+
+	// Swapped corners are reordered and out-of-range values clamped
+	b, ok = BoxFromGemini([]float64{600, 1200, 100, -50})
+	if !ok || b != (Box{XMin: 0, YMin: 0.1, XMax: 1, YMax: 0.6}) {
+		t.Errorf("got %+v, %v", b, ok)
+	}
+
+	for _, bad := range [][]float64{{1, 2, 3}, {100, 100, 100, 500}} {
+		if _, ok := BoxFromGemini(bad); ok {
+			t.Errorf("BoxFromGemini(%v) accepted an invalid box", bad)
+		}
+	}
+}
+
+func TestDrawBoxes(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	out := DrawBoxes(img, []Box{{XMin: 0.2, YMin: 0.2, XMax: 0.8, YMax: 0.8}}, nil)
+
+	if got := out.RGBAAt(20, 50); got != boxColors[0] {
+		t.Errorf("left edge = %v, want outline color %v", got, boxColors[0])
+	}
+	if got := out.RGBAAt(50, 50); got != (color.RGBA{}) {
+		t.Errorf("box interior = %v, want untouched", got)
+	}
+	if got := img.RGBAAt(20, 50); got != (color.RGBA{}) {
+		t.Error("DrawBoxes modified its input")
+	}
+}
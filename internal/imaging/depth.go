@@ -0,0 +1,76 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Grayscale converts img to 8-bit luminance, optionally inverting it so that
+// dark values become light
+func Grayscale(img image.Image, invert bool) *image.Gray {
+	b := img.Bounds()
+	gray := image.NewGray(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, b.Min, draw.Src)
+	if invert {
+		for i, v := range gray.Pix {
+			gray.Pix[i] = 255 - v
+		}
+	}
+	return gray
+}
+
+// Normalize stretches the luminance range of a grayscale image to 0-255 so
+// that depth maps with a compressed tonal range use the full scale
+func Normalize(gray *image.Gray) *image.Gray {
+	lo, hi := uint8(255), uint8(0)
+	for _, v := range gray.Pix {
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	out := image.NewGray(gray.Bounds())
+	if hi <= lo {
+		copy(out.Pix, gray.Pix)
+		return out
+	}
+	scale := 255 / float64(hi-lo)
+	for i, v := range gray.Pix {
+		out.Pix[i] = uint8(math.Round(float64(v-lo) * scale))
+	}
+	return out
+}
+
+// NormalMap derives a tangent-space normal map from a depth map, where
+// lighter pixels are nearer. Strength scales the surface slope; higher values
+// exaggerate relief. The result uses the common OpenGL (Y+) convention.
+func NormalMap(depth *image.Gray, strength float64) *image.NRGBA {
+	b := depth.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	at := func(x, y int) float64 {
+		x = min(max(x, 0), w-1)
+		y = min(max(y, 0), h-1)
+		return float64(depth.Pix[y*depth.Stride+x]) / 255
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// Sobel gradients
+			dx := (at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1)) - (at(x-1, y-1) + 2*at(x-1, y) + at(x-1, y+1))
+			dy := (at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1)) - (at(x-1, y-1) + 2*at(x, y-1) + at(x+1, y-1))
+
+			nx, ny, nz := -dx*strength, dy*strength, 1.0
+			length := math.Sqrt(nx*nx + ny*ny + nz*nz)
+			nx, ny, nz = nx/length, ny/length, nz/length
+
+			out.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(math.Round((nx + 1) * 127.5)),
+				G: uint8(math.Round((ny + 1) * 127.5)),
+				B: uint8(math.Round((nz + 1) * 127.5)),
+				A: 255,
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,46 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestNormalizeStretchesRange(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 3, 1))
+	gray.Pix = []uint8{100, 125, 150}
+
+	out := Normalize(gray)
+	if out.Pix[0] != 0 || out.Pix[2] != 255 {
+		t.Errorf("expected range stretched to 0-255, got %v", out.Pix)
+	}
+	if out.Pix[1] < 126 || out.Pix[1] > 129 {
+		t.Errorf("expected midpoint near 128, got %d", out.Pix[1])
+	}
+}
+
+func TestNormalMap(t *testing.T) {
+	// Flat depth points straight at the viewer
+	flat := image.NewGray(image.Rect(0, 0, 4, 4))
+	for i := range flat.Pix {
+		flat.Pix[i] = 128
+	}
+	n := NormalMap(flat, 2).NRGBAAt(1, 1)
+	if n.R != 128 || n.G != 128 || n.B != 255 {
+		t.Errorf("flat surface: expected (128,128,255), got (%d,%d,%d)", n.R, n.G, n.B)
+	}
+
+	// Depth rising to the right tilts normals towards -X
+	ramp := image.NewGray(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			ramp.Pix[y*ramp.Stride+x] = uint8(x * 60)
+		}
+	}
+	n = NormalMap(ramp, 2).NRGBAAt(1, 1)
+	if n.R >= 128 {
+		t.Errorf("ramp: expected normal tilted towards -X (R < 128), got R=%d", n.R)
+	}
+	if n.G != 128 {
+		t.Errorf("ramp: expected no Y tilt (G = 128), got G=%d", n.G)
+	}
+}
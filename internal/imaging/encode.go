@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Output formats images can be converted to
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// DefaultQuality is the JPEG and WebP quality used when none is given
+const DefaultQuality = 85
+
+// ParseFormat normalizes an output format name ("jpg" is JPEG) and returns
+// it with its MIME type
+func ParseFormat(name string) (format, mimeType string, err error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "png":
+		return FormatPNG, "image/png", nil
+	case "jpeg", "jpg":
+		return FormatJPEG, "image/jpeg", nil
+	case "webp":
+		return FormatWebP, "image/webp", nil
+	default:
+		return "", "", fmt.Errorf("unsupported output format %q (supported: png, jpeg, webp)", name)
+	}
+}
+
+// EncodeCompactPNG encodes an image as PNG with the best compression
+func EncodeCompactPNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeJPEG encodes an image as JPEG at quality (1-100). JPEG has no alpha
+// channel, so transparent pixels are flattened onto white.
+func EncodeJPEG(img image.Image, quality int) ([]byte, error) {
+	if HasTransparency(img) {
+		flat := image.NewRGBA(img.Bounds())
+		draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+		draw.Draw(flat, flat.Bounds(), img, img.Bounds().Min, draw.Over)
+		img = flat
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, fmt.Errorf("failed to encode JPEG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// EncodeWebP converts an image in any format ffmpeg reads to WebP at quality
+// (1-100, 100 is lossless). The standard library has no WebP encoder, so
+// this runs ffmpeg, which must be built with libwebp.
+func EncodeWebP(ctx context.Context, ffmpegPath string, data []byte, quality int) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, webpArgs(quality)...)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg returned no image")
+	}
+	return stdout.Bytes(), nil
+}
+
+// webpArgs builds the ffmpeg arguments for EncodeWebP, reading the image
+// from stdin and writing the WebP to stdout
+func webpArgs(quality int) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-f", "image2pipe", "-i", "-",
+		"-frames:v", "1",
+		"-c:v", "libwebp",
+	}
+	if quality >= 100 {
+		args = append(args, "-lossless", "1")
+	} else {
+		args = append(args, "-quality", strconv.Itoa(quality))
+	}
+	return append(args, "-f", "webp", "-")
+}
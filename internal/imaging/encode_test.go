@@ -0,0 +1,73 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string][2]string{
+		"png":   {FormatPNG, "image/png"},
+		"JPG":   {FormatJPEG, "image/jpeg"},
+		"jpeg":  {FormatJPEG, "image/jpeg"},
+		" webp": {FormatWebP, "image/webp"},
+	}
+	for name, want := range tests {
+		format, mimeType, err := ParseFormat(name)
+		if err != nil || format != want[0] || mimeType != want[1] {
+			t.Errorf("ParseFormat(%q) = %q, %q, %v, want %v", name, format, mimeType, err, want)
+		}
+	}
+	if _, _, err := ParseFormat("tiff"); err == nil {
+		t.Error("expected an error for tiff")
+	}
+}
+
+func TestEncodeJPEGFlattensTransparency(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 8; x++ {
+			img.Set(x, y, color.NRGBA{255, 0, 0, 255})
+		}
+	}
+
+	data, err := EncodeJPEG(img, 90)
+	if err != nil {
+		t.Fatalf("EncodeJPEG: %v", err)
+	}
+	decoded, format, err := Decode(data)
+	if err != nil || format != "jpeg" {
+		t.Fatalf("Decode = %q, %v", format, err)
+	}
+	// The transparent half becomes white rather than black
+	if r, g, b, _ := decoded.At(12, 8).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("transparent pixel = %d,%d,%d, want white", r>>8, g>>8, b>>8)
+	}
+	if r, g, _, _ := decoded.At(3, 8).RGBA(); r>>8 < 200 || g>>8 > 50 {
+		t.Errorf("opaque pixel = %d,%d, want red", r>>8, g>>8)
+	}
+}
+
+func TestEncodeCompactPNG(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	data, err := EncodeCompactPNG(img)
+	if err != nil {
+		t.Fatalf("EncodeCompactPNG: %v", err)
+	}
+	if _, format, err := Decode(data); err != nil || format != "png" {
+		t.Errorf("Decode = %q, %v", format, err)
+	}
+}
+
+func TestWebPArgs(t *testing.T) {
+	lossy := strings.Join(webpArgs(80), " ")
+	if !strings.Contains(lossy, "-c:v libwebp -quality 80 -f webp -") {
+		t.Errorf("lossy args = %s", lossy)
+	}
+	lossless := strings.Join(webpArgs(100), " ")
+	if !strings.Contains(lossless, "-lossless 1") || strings.Contains(lossless, "-quality") {
+		t.Errorf("lossless args = %s", lossless)
+	}
+}
@@ -0,0 +1,56 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Tile is a single cell cut from a grid image
+type Tile struct {
+	Row    int
+	Col    int
+	Bounds image.Rectangle
+	Image  image.Image
+}
+
+// SplitGrid cuts an image into rows x cols equally sized tiles.
+// gutter is the number of pixels separating adjacent cells (and surrounding
+// the grid), which is excluded from every tile.
+func SplitGrid(img image.Image, rows, cols, gutter int) ([]Tile, error) {
+	if rows < 1 || cols < 1 {
+		return nil, fmt.Errorf("rows and cols must be at least 1")
+	}
+	if gutter < 0 {
+		return nil, fmt.Errorf("gutter must not be negative")
+	}
+
+	bounds := img.Bounds()
+	cellW := (bounds.Dx() - gutter*(cols+1)) / cols
+	cellH := (bounds.Dy() - gutter*(rows+1)) / rows
+	if cellW < 1 || cellH < 1 {
+		return nil, fmt.Errorf("image %dx%d is too small for a %dx%d grid", bounds.Dx(), bounds.Dy(), rows, cols)
+	}
+
+	tiles := make([]Tile, 0, rows*cols)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			x0 := bounds.Min.X + gutter + c*(cellW+gutter)
+			y0 := bounds.Min.Y + gutter + r*(cellH+gutter)
+			rect := image.Rect(x0, y0, x0+cellW, y0+cellH)
+
+			// Copy into a fresh image so the tile's origin is (0,0)
+			tile := image.NewRGBA(image.Rect(0, 0, cellW, cellH))
+			draw.Draw(tile, tile.Bounds(), img, rect.Min, draw.Src)
+
+			tiles = append(tiles, Tile{
+				Row:    r,
+				Col:    c,
+				Bounds: rect,
+				Image:  tile,
+			})
+		}
+	}
+
+	return tiles, nil
+}
@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSplitGrid(t *testing.T) {
+	// 2x3 grid of 10x10 cells separated by a 2px gutter
+	img := image.NewRGBA(image.Rect(0, 0, 3*10+4*2, 2*10+3*2))
+	for r := 0; r < 2; r++ {
+		for c := 0; c < 3; c++ {
+			x0, y0 := 2+c*12, 2+r*12
+			shade := uint8(r*3 + c + 1)
+			for y := y0; y < y0+10; y++ {
+				for x := x0; x < x0+10; x++ {
+					img.Set(x, y, color.RGBA{R: shade, A: 255})
+				}
+			}
+		}
+	}
+
+	tiles, err := SplitGrid(img, 2, 3, 2)
+	if err != nil {
+		t.Fatalf("SplitGrid returned error: %v", err)
+	}
+	if len(tiles) != 6 {
+		t.Fatalf("expected 6 tiles, got %d", len(tiles))
+	}
+
+	for i, tile := range tiles {
+		if tile.Image.Bounds() != image.Rect(0, 0, 10, 10) {
+			t.Errorf("tile %d: expected 10x10 bounds at origin, got %v", i, tile.Image.Bounds())
+		}
+		want := uint8(tile.Row*3 + tile.Col + 1)
+		for _, p := range []image.Point{{0, 0}, {9, 9}} {
+			r, _, _, _ := tile.Image.At(p.X, p.Y).RGBA()
+			if uint8(r>>8) != want {
+				t.Errorf("tile r%d c%d at %v: expected shade %d, got %d", tile.Row, tile.Col, p, want, r>>8)
+			}
+		}
+	}
+}
+
+func TestSplitGridTooSmall(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	if _, err := SplitGrid(img, 8, 8, 0); err == nil {
+		t.Error("expected error for grid larger than image")
+	}
+}
@@ -0,0 +1,159 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IconImage is a single square PNG rendition of an icon
+type IconImage struct {
+	Size int
+	PNG  []byte
+}
+
+// ParseHexColor parses "#RGB" or "#RRGGBB" into an opaque colour
+func ParseHexColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: expected #RRGGBB", s)
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q: %w", s, err)
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
+
+// TrimTransparent crops away fully transparent rows and columns around the
+// image content. Opaque images are returned unchanged.
+func TrimTransparent(img image.Image) image.Image {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X, b.Min.Y
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > 0 {
+				minX, minY = min(minX, x), min(minY, y)
+				maxX, maxY = max(maxX, x+1), max(maxY, y+1)
+			}
+		}
+	}
+	if minX >= maxX || minY >= maxY {
+		return img
+	}
+	crop := image.NewNRGBA(image.Rect(0, 0, maxX-minX, maxY-minY))
+	draw.Draw(crop, crop.Bounds(), img, image.Pt(minX, minY), draw.Src)
+	return crop
+}
+
+// PadSquare centres img on a square canvas of the given size, leaving
+// paddingPercent of the canvas empty on every side. bg may be nil for a
+// transparent canvas.
+func PadSquare(img image.Image, size int, paddingPercent int, bg color.Color) image.Image {
+	canvas := image.NewNRGBA(image.Rect(0, 0, size, size))
+	if bg != nil {
+		draw.Draw(canvas, canvas.Bounds(), image.NewUniform(bg), image.Point{}, draw.Src)
+	}
+
+	inner := size - 2*size*paddingPercent/100
+	if inner < 1 {
+		inner = 1
+	}
+
+	b := img.Bounds()
+	w, h := inner, inner
+	if b.Dx() > b.Dy() {
+		h = max(1, b.Dy()*inner/b.Dx())
+	} else if b.Dy() > b.Dx() {
+		w = max(1, b.Dx()*inner/b.Dy())
+	}
+
+	scaled := Resize(img, w, h)
+	offset := image.Pt((size-w)/2, (size-h)/2)
+	draw.Draw(canvas, image.Rectangle{Min: offset, Max: offset.Add(image.Pt(w, h))}, scaled, image.Point{}, draw.Over)
+	return canvas
+}
+
+// EncodeICO assembles PNG renditions of 256px or smaller into a Windows .ico file
+func EncodeICO(images []IconImage) ([]byte, error) {
+	var entries []IconImage
+	for _, img := range images {
+		if img.Size <= 256 {
+			entries = append(entries, img)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("ico requires at least one size of 256px or smaller")
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Size < entries[j].Size })
+
+	var buf bytes.Buffer
+	// ICONDIR: reserved, type (1 = icon), image count
+	binary.Write(&buf, binary.LittleEndian, [3]uint16{0, 1, uint16(len(entries))})
+
+	offset := 6 + 16*len(entries)
+	for _, e := range entries {
+		dim := uint8(e.Size)
+		if e.Size == 256 {
+			dim = 0 // 0 means 256 in the ICONDIRENTRY
+		}
+		buf.Write([]byte{dim, dim, 0, 0})
+		binary.Write(&buf, binary.LittleEndian, uint16(1))  // colour planes
+		binary.Write(&buf, binary.LittleEndian, uint16(32)) // bits per pixel
+		binary.Write(&buf, binary.LittleEndian, uint32(len(e.PNG)))
+		binary.Write(&buf, binary.LittleEndian, uint32(offset))
+		offset += len(e.PNG)
+	}
+	for _, e := range entries {
+		buf.Write(e.PNG)
+	}
+	return buf.Bytes(), nil
+}
+
+// icnsTypes maps pixel sizes to the PNG-capable icns element types.
+// Retina variants reuse the same pixels under a second type code.
+var icnsTypes = map[int][]string{
+	16:   {"icp4"},
+	32:   {"icp5", "ic11"},
+	64:   {"icp6", "ic12"},
+	128:  {"ic07"},
+	256:  {"ic08", "ic13"},
+	512:  {"ic09", "ic14"},
+	1024: {"ic10"},
+}
+
+// EncodeICNS assembles PNG renditions into a macOS .icns file. Sizes without
+// an icns element type are skipped.
+func EncodeICNS(images []IconImage) ([]byte, error) {
+	var body bytes.Buffer
+	count := 0
+	sorted := append([]IconImage(nil), images...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Size < sorted[j].Size })
+
+	for _, img := range sorted {
+		for _, osType := range icnsTypes[img.Size] {
+			body.WriteString(osType)
+			binary.Write(&body, binary.BigEndian, uint32(8+len(img.PNG)))
+			body.Write(img.PNG)
+			count++
+		}
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("icns requires at least one of the sizes 16, 32, 64, 128, 256, 512, 1024")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("icns")
+	binary.Write(&buf, binary.BigEndian, uint32(8+body.Len()))
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
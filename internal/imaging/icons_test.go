@@ -0,0 +1,44 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestEncodeICO(t *testing.T) {
+	icons := []IconImage{
+		{Size: 256, PNG: []byte("png-256")},
+		{Size: 16, PNG: []byte("png-16")},
+		{Size: 512, PNG: []byte("png-512")}, // too large for ico, skipped
+	}
+
+	ico, err := EncodeICO(icons)
+	if err != nil {
+		t.Fatalf("EncodeICO returned error: %v", err)
+	}
+	if count := binary.LittleEndian.Uint16(ico[4:6]); count != 2 {
+		t.Fatalf("expected 2 entries, got %d", count)
+	}
+	// Entries are sorted by size; 256 is encoded as 0
+	if ico[6] != 16 || ico[6+16] != 0 {
+		t.Errorf("unexpected entry dimensions %d and %d", ico[6], ico[6+16])
+	}
+	offset := binary.LittleEndian.Uint32(ico[6+12 : 6+16])
+	if got := string(ico[offset : offset+6]); got != "png-16" {
+		t.Errorf("first entry offset points at %q", got)
+	}
+}
+
+func TestEncodeICNS(t *testing.T) {
+	icns, err := EncodeICNS([]IconImage{{Size: 16, PNG: []byte("a")}, {Size: 48, PNG: []byte("b")}})
+	if err != nil {
+		t.Fatalf("EncodeICNS returned error: %v", err)
+	}
+	if string(icns[:4]) != "icns" || int(binary.BigEndian.Uint32(icns[4:8])) != len(icns) {
+		t.Fatalf("invalid icns header")
+	}
+	// 48px has no icns slot, so only the 16px element is present
+	if string(icns[8:12]) != "icp4" || len(icns) != 8+8+1 {
+		t.Errorf("unexpected icns body: %q", icns[8:])
+	}
+}
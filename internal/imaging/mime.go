@@ -0,0 +1,36 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+)
+
+// SupportedInputMIMETypes lists the image formats accepted as model input
+var SupportedInputMIMETypes = []string{"image/png", "image/jpeg", "image/webp", "image/heic", "image/heif"}
+
+// DetectMIME sniffs the MIME type of image data from its leading bytes.
+// It recognises everything http.DetectContentType does plus HEIC/HEIF.
+func DetectMIME(data []byte) string {
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		switch string(data[8:12]) {
+		case "heic", "heix", "hevc", "hevx":
+			return "image/heic"
+		case "mif1", "msf1", "heif":
+			return "image/heif"
+		}
+	}
+	return http.DetectContentType(data)
+}
+
+// DetectInputMIME sniffs the MIME type of image data and returns an error if
+// the format is not one the Gemini models accept as input
+func DetectInputMIME(data []byte) (string, error) {
+	mimeType := DetectMIME(data)
+	for _, supported := range SupportedInputMIMETypes {
+		if mimeType == supported {
+			return mimeType, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported image format %s (supported: PNG, JPEG, WebP, HEIC, HEIF)", mimeType)
+}
@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// withOrientation inserts an EXIF APP1 segment carrying orientation after the
+// JPEG start-of-image marker
+func withOrientation(t *testing.T, data []byte, orientation uint16, order binary.ByteOrder) []byte {
+	t.Helper()
+	tiff := make([]byte, 8+2+12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 1)
+	order.PutUint16(tiff[10:], orientationTag)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+// testPhoto is a 32x16 JPEG whose left half is red and right half is blue
+func testPhoto(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 32; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if x >= 16 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	photo := testPhoto(t)
+	if got := JPEGOrientation(photo); got != 1 {
+		t.Errorf("no EXIF: orientation %d, want 1", got)
+	}
+	if got := JPEGOrientation(withOrientation(t, photo, 6, binary.LittleEndian)); got != 6 {
+		t.Errorf("little-endian EXIF: orientation %d, want 6", got)
+	}
+	if got := JPEGOrientation(withOrientation(t, photo, 8, binary.BigEndian)); got != 8 {
+		t.Errorf("big-endian EXIF: orientation %d, want 8", got)
+	}
+	if got := JPEGOrientation([]byte("\x89PNG\r\n\x1a\n")); got != 1 {
+		t.Errorf("PNG: orientation %d, want 1", got)
+	}
+}
+
+func TestNormalizeOrientation(t *testing.T) {
+	photo := testPhoto(t)
+
+	if out, changed, err := NormalizeOrientation(photo); err != nil || changed || !bytes.Equal(out, photo) {
+		t.Errorf("upright photo: changed=%v err=%v, want unchanged", changed, err)
+	}
+
+	// Orientation 6: rotate 90 degrees clockwise, so the red left half ends up on top
+	out, changed, err := NormalizeOrientation(withOrientation(t, photo, 6, binary.LittleEndian))
+	if err != nil || !changed {
+		t.Fatalf("rotated photo: changed=%v err=%v", changed, err)
+	}
+	if JPEGOrientation(out) != 1 {
+		t.Error("normalized photo still carries an EXIF rotation")
+	}
+	img, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 32 {
+		t.Fatalf("size %dx%d, want 16x32", b.Dx(), b.Dy())
+	}
+	if r, _, bl, _ := img.At(8, 4).RGBA(); r < bl {
+		t.Errorf("top half is not red after rotation")
+	}
+	if r, _, bl, _ := img.At(8, 28).RGBA(); bl < r {
+		t.Errorf("bottom half is not blue after rotation")
+	}
+}
@@ -0,0 +1,91 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// OutpaintFill is the neutral colour used for the area a model is asked to paint in
+var OutpaintFill = color.RGBA{R: 128, G: 128, B: 128, A: 255}
+
+// OutpaintCanvas returns a canvas the size of prev whose left overlap columns
+// are copied from the right edge of prev. If wrap is non-nil its left overlap
+// columns are copied to the right edge of the canvas, so the painted segment
+// joins back onto the start of a 360° panorama. Everything else is OutpaintFill.
+func OutpaintCanvas(prev image.Image, overlap int, wrap image.Image) *image.RGBA {
+	b := prev.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(OutpaintFill), image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(0, 0, overlap, b.Dy()), prev, image.Pt(b.Max.X-overlap, b.Min.Y), draw.Src)
+	if wrap != nil {
+		wb := wrap.Bounds()
+		draw.Draw(canvas, image.Rect(b.Dx()-overlap, 0, b.Dx(), b.Dy()), wrap, wb.Min, draw.Src)
+	}
+	return canvas
+}
+
+// StitchHorizontal joins equally sized segments left to right, where each
+// segment's first overlap columns depict the same content as the previous
+// segment's last overlap columns. Overlaps are cross-faded. When wrap is true
+// the final overlap is blended into the start of the first segment and
+// trimmed, producing an image that tiles seamlessly horizontally.
+func StitchHorizontal(segments []image.Image, overlap int, wrap bool) (*image.RGBA, error) {
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no segments to stitch")
+	}
+	w, h := segments[0].Bounds().Dx(), segments[0].Bounds().Dy()
+	for i, seg := range segments {
+		if seg.Bounds().Dx() != w || seg.Bounds().Dy() != h {
+			return nil, fmt.Errorf("segment %d is %dx%d, expected %dx%d", i+1, seg.Bounds().Dx(), seg.Bounds().Dy(), w, h)
+		}
+	}
+	if overlap < 0 || overlap >= w {
+		return nil, fmt.Errorf("overlap must be between 0 and %d pixels", w-1)
+	}
+
+	step := w - overlap
+	width := w + (len(segments)-1)*step
+	if wrap {
+		width -= overlap
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, h))
+
+	srcs := make([]*image.RGBA, len(segments))
+	for i, seg := range segments {
+		srcs[i] = toRGBA(seg)
+	}
+
+	for i, src := range srcs {
+		x0 := i * step
+		for x := 0; x < w; x++ {
+			dx := x0 + x
+			// Columns beyond the trimmed width wrap around to the start
+			fade := i > 0 && x < overlap
+			wrapped := dx >= width
+			if wrapped {
+				dx -= width
+				fade = true
+			}
+			for y := 0; y < h; y++ {
+				si := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+				di := out.PixOffset(dx, y)
+				if !fade {
+					copy(out.Pix[di:di+4], src.Pix[si:si+4])
+					continue
+				}
+				// Weight of the incoming segment rises across the overlap
+				t := float64(x+1) / float64(overlap+1)
+				if wrapped {
+					// Trailing wrap strip: blend from this segment into the first
+					t = 1 - float64(dx+1)/float64(overlap+1)
+				}
+				for c := 0; c < 4; c++ {
+					out.Pix[di+c] = uint8(float64(out.Pix[di+c])*(1-t) + float64(src.Pix[si+c])*t + 0.5)
+				}
+			}
+		}
+	}
+	return out, nil
+}
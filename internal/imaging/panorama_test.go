@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func solid(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestStitchHorizontal(t *testing.T) {
+	red := solid(10, 4, color.RGBA{R: 255, A: 255})
+	blue := solid(10, 4, color.RGBA{B: 255, A: 255})
+
+	out, err := StitchHorizontal([]image.Image{red, blue}, 4, false)
+	if err != nil {
+		t.Fatalf("StitchHorizontal: %v", err)
+	}
+	if out.Bounds().Dx() != 16 {
+		t.Fatalf("expected width 16, got %d", out.Bounds().Dx())
+	}
+	if c := out.RGBAAt(0, 0); c.R != 255 || c.B != 0 {
+		t.Errorf("left edge: expected red, got %v", c)
+	}
+	if c := out.RGBAAt(15, 0); c.B != 255 || c.R != 0 {
+		t.Errorf("right edge: expected blue, got %v", c)
+	}
+	// Overlap columns 6-9 fade from red to blue
+	prev := 256
+	for x := 6; x < 10; x++ {
+		c := out.RGBAAt(x, 0)
+		if int(c.R) >= prev {
+			t.Errorf("overlap column %d: red should decrease, got %d after %d", x, c.R, prev)
+		}
+		prev = int(c.R)
+	}
+}
+
+func TestStitchHorizontalWrap(t *testing.T) {
+	red := solid(10, 4, color.RGBA{R: 255, A: 255})
+	blue := solid(10, 4, color.RGBA{B: 255, A: 255})
+
+	out, err := StitchHorizontal([]image.Image{red, blue}, 4, true)
+	if err != nil {
+		t.Fatalf("StitchHorizontal: %v", err)
+	}
+	if out.Bounds().Dx() != 12 {
+		t.Fatalf("expected width 12, got %d", out.Bounds().Dx())
+	}
+	// The start blends from the trailing blue strip into red so the ends meet
+	if c := out.RGBAAt(0, 0); c.B == 0 {
+		t.Errorf("wrapped start: expected some blue, got %v", c)
+	}
+	if c := out.RGBAAt(5, 0); c.R != 255 || c.B != 0 {
+		t.Errorf("middle of first segment: expected pure red, got %v", c)
+	}
+}
+
+func TestStitchHorizontalRejectsMismatchedSizes(t *testing.T) {
+	_, err := StitchHorizontal([]image.Image{solid(10, 4, color.RGBA{}), solid(8, 4, color.RGBA{})}, 2, false)
+	if err == nil {
+		t.Fatal("expected error for mismatched segment sizes")
+	}
+}
@@ -0,0 +1,121 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+)
+
+// Resize scales img to exactly width x height using box filtering when
+// shrinking and nearest-neighbour sampling when enlarging
+func Resize(img image.Image, width, height int) image.Image {
+	src := toRGBA(img)
+	sb := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	scaleX := float64(sb.Dx()) / float64(width)
+	scaleY := float64(sb.Dy()) / float64(height)
+
+	for y := 0; y < height; y++ {
+		y0 := int(float64(y) * scaleY)
+		y1 := int(float64(y+1) * scaleY)
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := int(float64(x) * scaleX)
+			x1 := int(float64(x+1) * scaleX)
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1 && sy < sb.Dy(); sy++ {
+				off := src.PixOffset(sb.Min.X+x0, sb.Min.Y+sy)
+				for sx := x0; sx < x1 && sx < sb.Dx(); sx++ {
+					r += uint32(src.Pix[off])
+					g += uint32(src.Pix[off+1])
+					b += uint32(src.Pix[off+2])
+					a += uint32(src.Pix[off+3])
+					off += 4
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+		}
+	}
+
+	return dst
+}
+
+// Fit scales img down (never up) so that neither side exceeds maxDim,
+// preserving the aspect ratio
+func Fit(img image.Image, maxDim int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxDim && h <= maxDim {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxDim/w)
+		w = maxDim
+	} else {
+		w = max(1, w*maxDim/h)
+		h = maxDim
+	}
+	return Resize(img, w, h)
+}
+
+// CropToFill centre-crops img to the aspect ratio of width x height and
+// scales the result to exactly that size
+func CropToFill(img image.Image, width, height int) image.Image {
+	b := img.Bounds()
+	cropW, cropH := b.Dx(), b.Dy()
+	if cropW*height > cropH*width {
+		cropW = max(1, cropH*width/height)
+	} else {
+		cropH = max(1, cropW*height/width)
+	}
+
+	crop := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+	offset := image.Pt(b.Min.X+(b.Dx()-cropW)/2, b.Min.Y+(b.Dy()-cropH)/2)
+	draw.Draw(crop, crop.Bounds(), img, offset, draw.Src)
+	return Resize(crop, width, height)
+}
+
+// Thumbnail decodes an image and returns a JPEG preview no larger than
+// maxDim on its longest side. Transparent areas are flattened onto white.
+func Thumbnail(data []byte, maxDim int) ([]byte, error) {
+	img, _, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	thumb := Fit(img, maxDim)
+	flat := image.NewRGBA(thumb.Bounds())
+	draw.Draw(flat, flat.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), thumb, thumb.Bounds().Min, draw.Over)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// toRGBA returns img as an *image.RGBA, converting if necessary
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
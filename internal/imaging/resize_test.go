@@ -0,0 +1,40 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestCropToFill(t *testing.T) {
+	// 40x20 image: red left quarter, green centre half, blue right quarter
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			c := color.RGBA{G: 255, A: 255}
+			if x < 10 {
+				c = color.RGBA{R: 255, A: 255}
+			} else if x >= 30 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	out := CropToFill(img, 10, 10)
+	if out.Bounds() != image.Rect(0, 0, 10, 10) {
+		t.Fatalf("expected 10x10 output, got %v", out.Bounds())
+	}
+	// The square centre crop keeps only the green band
+	for _, p := range []image.Point{{0, 0}, {9, 9}, {5, 5}} {
+		r, g, b, _ := out.At(p.X, p.Y).RGBA()
+		if r != 0 || b != 0 || g>>8 != 255 {
+			t.Errorf("pixel %v: expected pure green, got r=%d g=%d b=%d", p, r>>8, g>>8, b>>8)
+		}
+	}
+
+	wide := CropToFill(img, 30, 5)
+	if wide.Bounds() != image.Rect(0, 0, 30, 5) {
+		t.Fatalf("expected 30x5 output, got %v", wide.Bounds())
+	}
+}
@@ -0,0 +1,79 @@
+package imaging
+
+import (
+	"image"
+	"math"
+)
+
+// DefaultSeamThreshold is the SeamRatio above which a texture is considered
+// to have a visible seam when tiled
+const DefaultSeamThreshold = 1.5
+
+// SeamRatio compares the colour jump across the wrap-around edges of img
+// (right column to left column, bottom row to top row) with the typical jump
+// between neighbouring pixels inside it. Values near 1 mean the edges join as
+// smoothly as the interior does; larger values mean a visible seam.
+func SeamRatio(img image.Image) float64 {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	if w < 2 || h < 2 {
+		return 0
+	}
+
+	origin := src.Rect.Min
+	diff := func(x0, y0, x1, y1 int) float64 {
+		a := src.PixOffset(origin.X+x0, origin.Y+y0)
+		b := src.PixOffset(origin.X+x1, origin.Y+y1)
+		var d float64
+		for c := 0; c < 3; c++ {
+			d += math.Abs(float64(src.Pix[a+c]) - float64(src.Pix[b+c]))
+		}
+		return d / 3
+	}
+
+	var seam, interior float64
+	for y := 0; y < h; y++ {
+		seam += diff(w-1, y, 0, y)
+		interior += diff(0, y, 1, y) + diff(w-2, y, w-1, y)
+	}
+	for x := 0; x < w; x++ {
+		seam += diff(x, h-1, x, 0)
+		interior += diff(x, 0, x, 1) + diff(x, h-2, x, h-1)
+	}
+	seam /= float64(w + h)
+	interior /= float64(2 * (w + h))
+
+	// Avoid dividing by ~0 for flat textures; a jump of one level is invisible
+	return seam / math.Max(interior, 1)
+}
+
+// MakeSeamless blends img with a copy of itself offset by half its size, so
+// that the edges of the result are drawn from the continuous interior of the
+// original. The original dominates towards the centre and the offset copy
+// towards the edges, which hides the offset copy's own seam in the middle
+// under the original.
+func MakeSeamless(img image.Image) *image.RGBA {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// weight is 0 at the edges and 1 from a quarter of the way in
+	weight := func(i, n int) float64 {
+		edge := math.Min(float64(i), float64(n-1-i)) / (float64(n) / 4)
+		return math.Min(edge, 1)
+	}
+
+	for y := 0; y < h; y++ {
+		wy := weight(y, h)
+		for x := 0; x < w; x++ {
+			t := math.Min(weight(x, w), wy)
+			a := src.PixOffset(src.Rect.Min.X+x, src.Rect.Min.Y+y)
+			b := src.PixOffset(src.Rect.Min.X+(x+w/2)%w, src.Rect.Min.Y+(y+h/2)%h)
+			o := out.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				out.Pix[o+c] = uint8(float64(src.Pix[a+c])*t + float64(src.Pix[b+c])*(1-t) + 0.5)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSeamRatioAndMakeSeamless(t *testing.T) {
+	// Horizontal gradient: smooth inside, hard jump from the right edge back to the left
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(x * 4)
+			img.Set(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	before := SeamRatio(img)
+	if before <= DefaultSeamThreshold {
+		t.Fatalf("expected gradient to have a seam, got ratio %.2f", before)
+	}
+
+	fixed := MakeSeamless(img)
+	if fixed.Bounds() != img.Bounds() {
+		t.Fatalf("expected bounds %v, got %v", img.Bounds(), fixed.Bounds())
+	}
+	if after := SeamRatio(fixed); after > DefaultSeamThreshold {
+		t.Errorf("expected seam to be removed, ratio %.2f -> %.2f", before, after)
+	}
+}
+
+func TestSeamRatioFlat(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	if r := SeamRatio(img); r != 0 {
+		t.Errorf("expected 0 for a flat image, got %.2f", r)
+	}
+}
@@ -0,0 +1,365 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strings"
+)
+
+// VectorizeOptions controls raster-to-SVG tracing
+type VectorizeOptions struct {
+	// Colors is the number of colours the image is quantised to (2-32)
+	Colors int
+	// Tolerance is the maximum distance in pixels a simplified path may
+	// deviate from the traced pixel boundary
+	Tolerance float64
+	// MinArea drops shapes smaller than this many pixels (speckle removal)
+	MinArea int
+	// MaxDim downsamples larger inputs before tracing to bound output size
+	MaxDim int
+}
+
+// VectorizeResult holds the generated SVG document and tracing statistics
+type VectorizeResult struct {
+	SVG     []byte
+	Width   int
+	Height  int
+	Palette []string
+	Paths   int
+}
+
+type point struct{ x, y int }
+
+// Vectorize traces img into an SVG document. The image is quantised to a small
+// palette; each colour layer's pixel boundaries are decomposed into closed
+// outlines (as in potrace's path decomposition), simplified with
+// Douglas-Peucker and emitted as even-odd filled paths. Fully transparent
+// pixels are left empty.
+func Vectorize(img image.Image, opts VectorizeOptions) (*VectorizeResult, error) {
+	if opts.Colors < 2 || opts.Colors > 32 {
+		return nil, fmt.Errorf("colors must be between 2 and 32")
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 1.0
+	}
+	if opts.MaxDim > 0 {
+		img = Fit(img, opts.MaxDim)
+	}
+
+	src := toRGBA(img)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+
+	palette, labels := quantize(src, opts.Colors)
+
+	// Count pixels per colour so the dominant colour can be drawn as backdrop
+	areas := make([]int, len(palette))
+	transparent := false
+	for _, l := range labels {
+		if l < 0 {
+			transparent = true
+			continue
+		}
+		areas[l]++
+	}
+
+	order := make([]int, len(palette))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return areas[order[a]] > areas[order[b]] })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n", w, h, w, h)
+
+	result := &VectorizeResult{Width: w, Height: h}
+	for i, c := range order {
+		if areas[c] == 0 {
+			continue
+		}
+		hex := fmt.Sprintf("#%02x%02x%02x", palette[c][0], palette[c][1], palette[c][2])
+		result.Palette = append(result.Palette, hex)
+
+		// The dominant colour of an opaque image becomes a full backdrop, which
+		// hides hairline seams between adjacent simplified shapes
+		if i == 0 && !transparent {
+			fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", w, h, hex)
+			result.Paths++
+			continue
+		}
+
+		loops := traceLayer(labels, w, h, c)
+		var d strings.Builder
+		for _, loop := range loops {
+			if polygonArea(loop) < float64(opts.MinArea) {
+				continue
+			}
+			simplified := simplifyClosed(loop, opts.Tolerance)
+			if len(simplified) < 3 {
+				continue
+			}
+			fmt.Fprintf(&d, "M%d %d", simplified[0].x, simplified[0].y)
+			for _, p := range simplified[1:] {
+				fmt.Fprintf(&d, "L%d %d", p.x, p.y)
+			}
+			d.WriteString("Z")
+		}
+		if d.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, `<path fill="%s" fill-rule="evenodd" d="%s"/>`+"\n", hex, d.String())
+		result.Paths++
+	}
+
+	b.WriteString("</svg>\n")
+	result.SVG = []byte(b.String())
+	return result, nil
+}
+
+// quantize reduces the image to at most k colours with k-means clustering.
+// It returns the palette and a per-pixel palette index (-1 for transparent).
+func quantize(img *image.RGBA, k int) ([][3]uint8, []int) {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	labels := make([]int, w*h)
+
+	// Gather opaque pixels, un-premultiplying partially transparent ones
+	pixels := make([][3]float64, 0, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			off := img.PixOffset(x, y)
+			a := img.Pix[off+3]
+			if a < 128 {
+				labels[y*w+x] = -1
+				continue
+			}
+			scale := 255.0 / float64(a)
+			pixels = append(pixels, [3]float64{
+				math.Min(255, float64(img.Pix[off])*scale),
+				math.Min(255, float64(img.Pix[off+1])*scale),
+				math.Min(255, float64(img.Pix[off+2])*scale),
+			})
+		}
+	}
+	if len(pixels) == 0 {
+		return nil, labels
+	}
+
+	// Deterministic farthest-point initialisation on a sample of pixels
+	step := max(1, len(pixels)/20000)
+	var sample [][3]float64
+	for i := 0; i < len(pixels); i += step {
+		sample = append(sample, pixels[i])
+	}
+	centers := [][3]float64{sample[0]}
+	for len(centers) < k {
+		best, bestDist := -1, 0.0
+		for i, p := range sample {
+			d := nearestDist(p, centers)
+			if d > bestDist {
+				best, bestDist = i, d
+			}
+		}
+		if best < 0 || bestDist < 1 {
+			break
+		}
+		centers = append(centers, sample[best])
+	}
+
+	for iter := 0; iter < 10; iter++ {
+		sums := make([][4]float64, len(centers))
+		for _, p := range sample {
+			c := nearest(p, centers)
+			sums[c][0] += p[0]
+			sums[c][1] += p[1]
+			sums[c][2] += p[2]
+			sums[c][3]++
+		}
+		for i := range centers {
+			if sums[i][3] > 0 {
+				centers[i] = [3]float64{sums[i][0] / sums[i][3], sums[i][1] / sums[i][3], sums[i][2] / sums[i][3]}
+			}
+		}
+	}
+
+	palette := make([][3]uint8, len(centers))
+	for i, c := range centers {
+		palette[i] = [3]uint8{uint8(math.Round(c[0])), uint8(math.Round(c[1])), uint8(math.Round(c[2]))}
+	}
+
+	idx := 0
+	for i := range labels {
+		if labels[i] == -1 {
+			continue
+		}
+		labels[i] = nearest(pixels[idx], centers)
+		idx++
+	}
+
+	return palette, labels
+}
+
+func nearest(p [3]float64, centers [][3]float64) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, c := range centers {
+		d := sqDist(p, c)
+		if d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+func nearestDist(p [3]float64, centers [][3]float64) float64 {
+	return sqDist(p, centers[nearest(p, centers)])
+}
+
+func sqDist(a, b [3]float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
+
+// traceLayer returns the closed pixel-boundary outlines of every region labelled c.
+// Boundary edges are directed so the region is always on the left, which keeps
+// outer contours and holes consistently oriented.
+func traceLayer(labels []int, w, h, c int) [][]point {
+	in := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && labels[y*w+x] == c
+	}
+
+	next := make(map[point][]point)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if !in(x, y) {
+				continue
+			}
+			if !in(x, y-1) { // top edge, heading left
+				next[point{x + 1, y}] = append(next[point{x + 1, y}], point{x, y})
+			}
+			if !in(x-1, y) { // left edge, heading down
+				next[point{x, y}] = append(next[point{x, y}], point{x, y + 1})
+			}
+			if !in(x, y+1) { // bottom edge, heading right
+				next[point{x, y + 1}] = append(next[point{x, y + 1}], point{x + 1, y + 1})
+			}
+			if !in(x+1, y) { // right edge, heading up
+				next[point{x + 1, y + 1}] = append(next[point{x + 1, y + 1}], point{x + 1, y})
+			}
+		}
+	}
+
+	// Visit start vertices in a stable order for reproducible output
+	starts := make([]point, 0, len(next))
+	for p := range next {
+		starts = append(starts, p)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		if starts[i].y != starts[j].y {
+			return starts[i].y < starts[j].y
+		}
+		return starts[i].x < starts[j].x
+	})
+
+	var loops [][]point
+	for _, start := range starts {
+		for len(next[start]) > 0 {
+			loop := []point{start}
+			cur := start
+			for {
+				outs := next[cur]
+				if len(outs) == 0 {
+					break
+				}
+				nxt := outs[len(outs)-1]
+				next[cur] = outs[:len(outs)-1]
+				if nxt == start {
+					break
+				}
+				loop = append(loop, nxt)
+				cur = nxt
+			}
+			loops = append(loops, dropCollinear(loop))
+		}
+	}
+	return loops
+}
+
+// dropCollinear removes vertices lying on a straight run between their neighbours
+func dropCollinear(loop []point) []point {
+	n := len(loop)
+	if n < 4 {
+		return loop
+	}
+	out := make([]point, 0, n)
+	for i := 0; i < n; i++ {
+		prev, cur, nxt := loop[(i+n-1)%n], loop[i], loop[(i+1)%n]
+		if (cur.x-prev.x)*(nxt.y-cur.y)-(cur.y-prev.y)*(nxt.x-cur.x) != 0 {
+			out = append(out, cur)
+		}
+	}
+	return out
+}
+
+// simplifyClosed applies Douglas-Peucker to a closed polygon, splitting it at
+// the vertex farthest from the first one
+func simplifyClosed(loop []point, tolerance float64) []point {
+	if len(loop) < 4 {
+		return loop
+	}
+	far, farDist := 0, -1
+	for i, p := range loop {
+		d := (p.x-loop[0].x)*(p.x-loop[0].x) + (p.y-loop[0].y)*(p.y-loop[0].y)
+		if d > farDist {
+			far, farDist = i, d
+		}
+	}
+
+	closed := append(append([]point{}, loop...), loop[0])
+	first := douglasPeucker(closed[:far+1], tolerance)
+	second := douglasPeucker(closed[far:], tolerance)
+
+	return append(first[:len(first)-1], second[:len(second)-1]...)
+}
+
+func douglasPeucker(pts []point, tolerance float64) []point {
+	if len(pts) < 3 {
+		return append([]point(nil), pts...)
+	}
+	a, b := pts[0], pts[len(pts)-1]
+	idx, maxDist := 0, 0.0
+	for i := 1; i < len(pts)-1; i++ {
+		d := segmentDist(pts[i], a, b)
+		if d > maxDist {
+			idx, maxDist = i, d
+		}
+	}
+	if maxDist <= tolerance {
+		return []point{a, b}
+	}
+	left := douglasPeucker(pts[:idx+1], tolerance)
+	right := douglasPeucker(pts[idx:], tolerance)
+	return append(left[:len(left)-1], right...)
+}
+
+func segmentDist(p, a, b point) float64 {
+	dx, dy := float64(b.x-a.x), float64(b.y-a.y)
+	if dx == 0 && dy == 0 {
+		return math.Hypot(float64(p.x-a.x), float64(p.y-a.y))
+	}
+	t := (float64(p.x-a.x)*dx + float64(p.y-a.y)*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(float64(p.x)-(float64(a.x)+t*dx), float64(p.y)-(float64(a.y)+t*dy))
+}
+
+// polygonArea returns the absolute area enclosed by a closed polygon
+func polygonArea(loop []point) float64 {
+	var sum int
+	for i := range loop {
+		j := (i + 1) % len(loop)
+		sum += loop[i].x*loop[j].y - loop[j].x*loop[i].y
+	}
+	return math.Abs(float64(sum)) / 2
+}
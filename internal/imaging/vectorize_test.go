@@ -0,0 +1,39 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestVectorizeRingWithHole(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			d := (x-32)*(x-32) + (y-32)*(y-32)
+			c := color.RGBA{255, 255, 255, 255}
+			if d < 400 && d >= 100 {
+				c = color.RGBA{200, 0, 0, 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+
+	result, err := Vectorize(img, VectorizeOptions{Colors: 4, Tolerance: 1, MinArea: 2})
+	if err != nil {
+		t.Fatalf("Vectorize returned error: %v", err)
+	}
+
+	svg := string(result.SVG)
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 64 64"`) {
+		t.Fatalf("unexpected SVG header: %s", svg)
+	}
+	if len(result.Palette) != 2 {
+		t.Errorf("expected 2 palette colors, got %v", result.Palette)
+	}
+	// The ring is drawn as one path with an outer contour and a hole
+	if n := strings.Count(svg, "Z"); n != 2 {
+		t.Errorf("expected 2 closed subpaths for ring, got %d", n)
+	}
+}
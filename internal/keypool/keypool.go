@@ -0,0 +1,230 @@
+package keypool
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyHeader is the header the Gemini API reads the API key from
+const apiKeyHeader = "x-goog-api-key"
+
+// Pool rotates Gemini API requests across several API keys. Keys that return a
+// quota error are shelved for a cooldown period and their requests are retried
+// on the next key. It is safe for concurrent use.
+type Pool struct {
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	keys []*apiKey
+	next int
+}
+
+type apiKey struct {
+	value        string
+	shelvedUntil time.Time
+}
+
+// New creates a pool over keys. Empty and duplicate keys are ignored.
+func New(keys []string, cooldown time.Duration) *Pool {
+	p := &Pool{cooldown: cooldown, now: time.Now}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		if k == "" || seen[k] {
+			continue
+		}
+		seen[k] = true
+		p.keys = append(p.keys, &apiKey{value: k})
+	}
+	return p
+}
+
+// Len returns the number of keys in the pool
+func (p *Pool) Len() int {
+	return len(p.keys)
+}
+
+// Available returns the number of keys that are not shelved
+func (p *Pool) Available() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	n := 0
+	for _, k := range p.keys {
+		if !now.Before(k.shelvedUntil) {
+			n++
+		}
+	}
+	return n
+}
+
+// pick returns the next key in rotation that is not shelved and not in tried.
+// When every untried key is shelved, the one whose cooldown ends first is
+// returned so the request still reaches the API. It returns "" once every key
+// has been tried.
+func (p *Pool) pick(tried map[string]bool) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	var fallback *apiKey
+	for i := 0; i < len(p.keys); i++ {
+		k := p.keys[(p.next+i)%len(p.keys)]
+		if tried[k.value] {
+			continue
+		}
+		if !now.Before(k.shelvedUntil) {
+			p.next = (p.next + i + 1) % len(p.keys)
+			return k.value
+		}
+		if fallback == nil || k.shelvedUntil.Before(fallback.shelvedUntil) {
+			fallback = k
+		}
+	}
+	if fallback == nil {
+		return ""
+	}
+	return fallback.value
+}
+
+// shelve takes a key out of rotation for the cooldown period
+func (p *Pool) shelve(value string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.value == value {
+			k.shelvedUntil = p.now().Add(p.cooldown)
+			log.Printf("API key %s hit its quota, shelved for %v", Mask(value), p.cooldown)
+			return
+		}
+	}
+}
+
+// Mask returns a loggable form of an API key showing only its last characters
+func Mask(key string) string {
+	if len(key) <= 4 {
+		return "****"
+	}
+	return "****" + key[len(key)-4:]
+}
+
+type bindingKey struct{}
+
+// binding pins the requests of one tool call to a single key once that key
+// holds server-side state (a long-running operation or an uploaded file),
+// which is only visible to the project the key belongs to
+type binding struct {
+	mu     sync.Mutex
+	key    string
+	pinned bool
+}
+
+// Bind returns a context whose Gemini API requests share one key. Requests
+// made with an unbound context pick a key independently.
+func Bind(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(bindingKey{}).(*binding); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, bindingKey{}, &binding{})
+}
+
+// Transport wraps next so that each request carries a key from the pool.
+// A request answered with 429 shelves its key and, unless the tool call is
+// pinned to that key or the body cannot be replayed, is retried on the next key.
+func (p *Pool) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if len(p.keys) == 0 {
+			return next.RoundTrip(req)
+		}
+		b, _ := req.Context().Value(bindingKey{}).(*binding)
+
+		tried := make(map[string]bool)
+		for {
+			key, pinned := p.keyFor(b, tried)
+			tried[key] = true
+
+			attempt := req.Clone(req.Context())
+			attempt.Header.Set(apiKeyHeader, key)
+			if len(tried) > 1 && req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attempt.Body = body
+			}
+
+			resp, err := next.RoundTrip(attempt)
+			if err != nil {
+				return nil, err
+			}
+
+			if resp.StatusCode != http.StatusTooManyRequests {
+				if resp.StatusCode < 300 && createsState(req) {
+					b.pin(key)
+				}
+				return resp, nil
+			}
+
+			p.shelve(key)
+			replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+			if pinned || len(tried) >= len(p.keys) || !replayable {
+				return resp, nil
+			}
+			resp.Body.Close()
+			log.Printf("Retrying %s %s with another API key", req.Method, req.URL.Path)
+		}
+	})
+}
+
+// keyFor returns the key for the next attempt of a request and whether the
+// request's tool call is pinned to it
+func (p *Pool) keyFor(b *binding, tried map[string]bool) (string, bool) {
+	if b == nil {
+		return p.pick(tried), false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pinned {
+		return b.key, true
+	}
+	if b.key == "" || tried[b.key] || !p.usable(b.key) {
+		b.key = p.pick(tried)
+	}
+	return b.key, false
+}
+
+// usable reports whether key is currently out of cooldown
+func (p *Pool) usable(key string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, k := range p.keys {
+		if k.value == key {
+			return !p.now().Before(k.shelvedUntil)
+		}
+	}
+	return false
+}
+
+func (b *binding) pin(key string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.key = key
+	b.pinned = true
+}
+
+// createsState reports whether a successful request leaves state behind that
+// later requests of the same tool call must reach with the same key
+func createsState(req *http.Request) bool {
+	return strings.HasSuffix(req.URL.Path, ":predictLongRunning") || strings.HasPrefix(req.URL.Path, "/upload/")
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
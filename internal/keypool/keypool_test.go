@@ -0,0 +1,122 @@
+package keypool
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeAPI answers 429 for keys listed in exhausted and 200 otherwise,
+// recording the key and body of every request
+type fakeAPI struct {
+	mu        sync.Mutex
+	exhausted map[string]bool
+	keys      []string
+	bodies    []string
+}
+
+func (f *fakeAPI) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Header.Get(apiKeyHeader)
+	body := ""
+	if req.Body != nil {
+		data, _ := io.ReadAll(req.Body)
+		body = string(data)
+	}
+
+	f.mu.Lock()
+	f.keys = append(f.keys, key)
+	f.bodies = append(f.bodies, body)
+	status := http.StatusOK
+	if f.exhausted[key] {
+		status = http.StatusTooManyRequests
+	}
+	f.mu.Unlock()
+
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader("{}")), Request: req}, nil
+}
+
+func post(t *testing.T, client *http.Client, ctx context.Context, path string) int {
+	t.Helper()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.example.com"+path, strings.NewReader("payload"))
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestPoolRotatesAndFailsOver(t *testing.T) {
+	api := &fakeAPI{exhausted: map[string]bool{"key-b": true}}
+	pool := New([]string{"key-a", "key-b", "key-c", "key-a"}, time.Hour)
+	client := &http.Client{Transport: pool.Transport(api)}
+
+	if pool.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3 after removing the duplicate", pool.Len())
+	}
+
+	for i := 0; i < 3; i++ {
+		if status := post(t, client, context.Background(), "/v1beta/models/m:generateContent"); status != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i, status)
+		}
+	}
+
+	// key-a, then key-b (429, shelved) retried on key-c, then key-a again
+	want := []string{"key-a", "key-b", "key-c", "key-a"}
+	if strings.Join(api.keys, ",") != strings.Join(want, ",") {
+		t.Errorf("keys used = %v, want %v", api.keys, want)
+	}
+	for i, body := range api.bodies {
+		if body != "payload" {
+			t.Errorf("request %d body = %q, want the original payload replayed", i, body)
+		}
+	}
+	if got := pool.Available(); got != 2 {
+		t.Errorf("Available() = %d, want 2 with key-b shelved", got)
+	}
+
+	// The shelved key returns to rotation after its cooldown
+	pool.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if got := pool.Available(); got != 3 {
+		t.Errorf("Available() after cooldown = %d, want 3", got)
+	}
+}
+
+func TestPoolReturnsQuotaErrorWhenAllKeysExhausted(t *testing.T) {
+	api := &fakeAPI{exhausted: map[string]bool{"key-a": true, "key-b": true}}
+	client := &http.Client{Transport: New([]string{"key-a", "key-b"}, time.Hour).Transport(api)}
+
+	if status := post(t, client, context.Background(), "/v1beta/models/m:generateContent"); status != http.StatusTooManyRequests {
+		t.Fatalf("status %d, want 429", status)
+	}
+	if len(api.keys) != 2 {
+		t.Errorf("made %d attempts, want one per key", len(api.keys))
+	}
+}
+
+func TestBoundCallStaysOnKeyAfterCreatingOperation(t *testing.T) {
+	api := &fakeAPI{exhausted: map[string]bool{}}
+	pool := New([]string{"key-a", "key-b"}, time.Hour)
+	client := &http.Client{Transport: pool.Transport(api)}
+
+	ctx := Bind(context.Background())
+	post(t, client, ctx, "/v1beta/models/veo:predictLongRunning")
+
+	// An unrelated request advances the rotation
+	post(t, client, context.Background(), "/v1beta/models/m:generateContent")
+
+	// Polls of the operation must use the key that created it, even once it is exhausted
+	api.exhausted["key-a"] = true
+	if status := post(t, client, ctx, "/v1beta/operations/123"); status != http.StatusTooManyRequests {
+		t.Fatalf("pinned poll status %d, want the quota error without failover", status)
+	}
+
+	want := []string{"key-a", "key-b", "key-a"}
+	if strings.Join(api.keys, ",") != strings.Join(want, ",") {
+		t.Errorf("keys used = %v, want %v", api.keys, want)
+	}
+}
@@ -0,0 +1,100 @@
+// Package lifecycle owns the server's background routines, such as cleanup
+// loops, schedulers and watchers, and stops them in order on shutdown. Once
+// they have stopped it runs the registered flushes, so bookkeeping that is
+// still pending, such as webhook deliveries for finished generations, is
+// written out before the process exits.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// routine is a background routine started with Go
+type routine struct {
+	name   string
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// flush is a final write registered with OnShutdown
+type flush struct {
+	name string
+	fn   func(context.Context) error
+}
+
+// Group runs background routines until Shutdown
+type Group struct {
+	mu       sync.Mutex
+	routines []*routine
+	flushes  []flush
+	stopped  bool
+}
+
+// New creates an empty group
+func New() *Group {
+	return &Group{}
+}
+
+// Go runs fn in the background until its context is cancelled by Shutdown.
+// fn should return promptly once its context is done. Routines started after
+// Shutdown are cancelled at once.
+func (g *Group) Go(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &routine{name: name, cancel: cancel, done: make(chan struct{})}
+
+	g.mu.Lock()
+	if g.stopped {
+		cancel()
+	} else {
+		g.routines = append(g.routines, r)
+	}
+	g.mu.Unlock()
+
+	go func() {
+		defer close(r.done)
+		fn(ctx)
+	}()
+}
+
+// OnShutdown registers fn to run once every routine has stopped. Flushes
+// run in the order they were registered.
+func (g *Group) OnShutdown(name string, fn func(ctx context.Context) error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushes = append(g.flushes, flush{name: name, fn: fn})
+}
+
+// Shutdown stops the routines in the reverse order they were started, like
+// deferred calls, waiting for each before stopping the next, and then runs
+// the flushes. It gives up waiting once ctx is done, but still runs every
+// flush with ctx. Calls after the first do nothing.
+func (g *Group) Shutdown(ctx context.Context) error {
+	g.mu.Lock()
+	if g.stopped {
+		g.mu.Unlock()
+		return nil
+	}
+	g.stopped = true
+	routines, flushes := g.routines, g.flushes
+	g.mu.Unlock()
+
+	var errs []error
+	for i := len(routines) - 1; i >= 0; i-- {
+		r := routines[i]
+		r.cancel()
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			errs = append(errs, fmt.Errorf("%s did not stop: %w", r.name, ctx.Err()))
+		}
+	}
+	for _, f := range flushes {
+		if err := f.fn(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
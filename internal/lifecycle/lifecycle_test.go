@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	g := New()
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, event)
+	}
+
+	for _, name := range []string{"first", "second", "third"} {
+		g.Go(name, func(ctx context.Context) {
+			<-ctx.Done()
+			time.Sleep(5 * time.Millisecond) // A later routine must still wait for this one
+			record(name)
+		})
+	}
+	g.OnShutdown("flush", func(ctx context.Context) error {
+		record("flush")
+		return nil
+	})
+
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if want := []string{"third", "second", "first", "flush"}; !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if err := g.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown = %v, want nil", err)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	g := New()
+	block := make(chan struct{})
+	defer close(block)
+	g.Go("stuck", func(ctx context.Context) { <-block })
+	flushed := false
+	g.OnShutdown("flush", func(ctx context.Context) error {
+		flushed = true
+		return errors.New("index not written")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := g.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown = %v, want the deadline error of the stuck routine", err)
+	}
+	if !flushed {
+		t.Error("flush did not run after the deadline")
+	}
+}
+
+func TestGoAfterShutdown(t *testing.T) {
+	g := New()
+	g.Shutdown(context.Background())
+	done := make(chan struct{})
+	g.Go("late", func(ctx context.Context) {
+		<-ctx.Done()
+		close(done)
+	})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("routine started after Shutdown was not cancelled")
+	}
+}
@@ -0,0 +1,237 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// Limiter bounds the number of concurrent operations of one kind.
+// Callers beyond the limit wait in a queue for up to the configured timeout.
+// Freed slots go to interactive callers before batch callers, and callers of
+// the same class are served round-robin by client so that one client's burst
+// cannot starve another. When more than one slot exists, batch callers never
+// occupy the last free slot, keeping it available for interactive requests.
+type Limiter struct {
+	name     string
+	max      int
+	timeout  time.Duration
+	maxQueue int
+
+	mu         sync.Mutex
+	inUse      int
+	batchInUse int
+	waiting    int
+	queues     [numPriorities]*classQueue
+}
+
+// waiter is a caller queued for a slot
+type waiter struct {
+	priority Priority
+	client   string
+	ready    chan struct{}
+	granted  bool
+}
+
+// classQueue holds the waiters of one priority class, grouped per client and
+// served round-robin across clients
+type classQueue struct {
+	order   []string // clients with pending waiters, next to serve first
+	pending map[string][]*waiter
+}
+
+// New creates a limiter allowing max concurrent holders. A max of 0 or less
+// disables limiting. maxQueue caps the number of waiting callers (0 = unbounded)
+// and timeout bounds how long each caller waits for a slot.
+func New(name string, max, maxQueue int, timeout time.Duration) *Limiter {
+	l := &Limiter{
+		name:     name,
+		max:      max,
+		timeout:  timeout,
+		maxQueue: maxQueue,
+	}
+	for i := range l.queues {
+		l.queues[i] = &classQueue{pending: make(map[string][]*waiter)}
+	}
+	return l
+}
+
+// Acquire blocks until a slot is available and returns a function that
+// releases it. It fails if the queue is full, the wait times out, or ctx is done.
+// The caller's priority class and client are taken from ctx (see WithPriority).
+func (l *Limiter) Acquire(ctx context.Context) (func(), error) {
+	if l == nil || l.max <= 0 {
+		return func() {}, nil
+	}
+	priority, client := priorityFromContext(ctx)
+
+	l.mu.Lock()
+	// Fast path: free slot available and nobody of equal or higher priority queued
+	if l.canRun(priority) && !l.queuedAtOrAbove(priority) {
+		l.take(priority)
+		l.mu.Unlock()
+		return l.releaseFunc(priority), nil
+	}
+
+	if l.maxQueue > 0 && l.waiting >= l.maxQueue {
+		l.mu.Unlock()
+		return nil, toolerr.Errorf(toolerr.QuotaExceeded, "too many concurrent %s requests: %d in progress and %d queued, please retry later", l.name, l.max, l.maxQueue)
+	}
+	w := &waiter{priority: priority, client: client, ready: make(chan struct{})}
+	l.queues[priority].push(w)
+	l.waiting++
+	l.mu.Unlock()
+
+	var timeoutCh <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return l.releaseFunc(priority), nil
+	case <-timeoutCh:
+		err = toolerr.Errorf(toolerr.Timeout, "timed out after %v waiting for a free %s slot (%d concurrent allowed), please retry later", l.timeout, l.name, l.max)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted {
+		// The slot was handed over while we were giving up; pass it on
+		l.release(priority)
+	} else {
+		l.queues[priority].remove(w)
+		l.waiting--
+	}
+	return nil, err
+}
+
+// Stats returns the number of slots in use and callers waiting
+func (l *Limiter) Stats() (inUse, waiting int) {
+	if l == nil || l.max <= 0 {
+		return 0, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inUse, l.waiting
+}
+
+func (l *Limiter) releaseFunc(priority Priority) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.release(priority)
+		})
+	}
+}
+
+// canRun reports whether a caller of the given class may take a slot now.
+// Must be called with l.mu held.
+func (l *Limiter) canRun(priority Priority) bool {
+	if l.inUse >= l.max {
+		return false
+	}
+	if priority == Batch && l.max > 1 && l.batchInUse >= l.max-1 {
+		return false
+	}
+	return true
+}
+
+// queuedAtOrAbove reports whether callers of the given or a higher class are
+// waiting. Must be called with l.mu held.
+func (l *Limiter) queuedAtOrAbove(priority Priority) bool {
+	for p := Priority(0); p <= priority; p++ {
+		if len(l.queues[p].order) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// take marks a slot as held by a caller of the given class.
+// Must be called with l.mu held.
+func (l *Limiter) take(priority Priority) {
+	l.inUse++
+	if priority == Batch {
+		l.batchInUse++
+	}
+}
+
+// release frees a slot held by a caller of the given class and hands free
+// slots to the next eligible waiters. Must be called with l.mu held.
+func (l *Limiter) release(priority Priority) {
+	l.inUse--
+	if priority == Batch {
+		l.batchInUse--
+	}
+
+	for p := Priority(0); p < numPriorities; p++ {
+		for l.canRun(p) {
+			w := l.queues[p].pop()
+			if w == nil {
+				break
+			}
+			l.waiting--
+			l.take(p)
+			w.granted = true
+			close(w.ready)
+		}
+	}
+}
+
+func (q *classQueue) push(w *waiter) {
+	if len(q.pending[w.client]) == 0 {
+		q.order = append(q.order, w.client)
+	}
+	q.pending[w.client] = append(q.pending[w.client], w)
+}
+
+// pop returns the oldest waiter of the next client in the rotation, or nil
+func (q *classQueue) pop() *waiter {
+	if len(q.order) == 0 {
+		return nil
+	}
+	client := q.order[0]
+	q.order = q.order[1:]
+
+	waiters := q.pending[client]
+	w := waiters[0]
+	if len(waiters) > 1 {
+		q.pending[client] = waiters[1:]
+		q.order = append(q.order, client)
+	} else {
+		delete(q.pending, client)
+	}
+	return w
+}
+
+func (q *classQueue) remove(w *waiter) {
+	waiters := q.pending[w.client]
+	for i, candidate := range waiters {
+		if candidate != w {
+			continue
+		}
+		waiters = append(waiters[:i], waiters[i+1:]...)
+		if len(waiters) > 0 {
+			q.pending[w.client] = waiters
+			return
+		}
+		delete(q.pending, w.client)
+		for j, client := range q.order {
+			if client == w.client {
+				q.order = append(q.order[:j], q.order[j+1:]...)
+				break
+			}
+		}
+		return
+	}
+}
@@ -0,0 +1,141 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiterTimeout(t *testing.T) {
+	l := New("image generation", 1, 0, 20*time.Millisecond)
+
+	release, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("first acquire failed: %v", err)
+	}
+
+	if _, err := l.Acquire(context.Background()); err == nil {
+		t.Fatal("expected second acquire to time out")
+	}
+
+	release()
+	release2, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("acquire after release failed: %v", err)
+	}
+	release2()
+}
+
+func TestLimiterQueueFull(t *testing.T) {
+	l := New("video generation", 1, 1, time.Second)
+
+	release, _ := l.Acquire(context.Background())
+	defer release()
+
+	// Occupy the single queue position
+	queued := make(chan error, 1)
+	go func() {
+		r, err := l.Acquire(context.Background())
+		if err == nil {
+			r()
+		}
+		queued <- err
+	}()
+	for {
+		if _, waiting := l.Stats(); waiting == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := l.Acquire(context.Background()); err == nil {
+		t.Fatal("expected acquire to fail when queue is full")
+	}
+
+	release()
+	if err := <-queued; err != nil {
+		t.Fatalf("queued acquire failed: %v", err)
+	}
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := New("image generation", 0, 0, 0)
+	for i := 0; i < 10; i++ {
+		if _, err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("disabled limiter returned error: %v", err)
+		}
+	}
+}
+
+// waitForQueue blocks until n callers are waiting on l
+func waitForQueue(t *testing.T, l *Limiter, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, waiting := l.Stats(); waiting == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d queued callers", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLimiterPriorityAndFairness(t *testing.T) {
+	l := New("image generation", 1, 0, time.Second)
+	release, _ := l.Acquire(context.Background())
+
+	order := make(chan string, 4)
+	enqueue := func(name string, priority Priority, client string) {
+		ctx := WithPriority(context.Background(), priority, client)
+		go func() {
+			r, err := l.Acquire(ctx)
+			if err != nil {
+				order <- "error: " + err.Error()
+				return
+			}
+			order <- name
+			r()
+		}()
+	}
+
+	// Client A queues two batch jobs before B and C each queue one request
+	enqueue("a1", Batch, "a")
+	waitForQueue(t, l, 1)
+	enqueue("a2", Batch, "a")
+	waitForQueue(t, l, 2)
+	enqueue("b1", Batch, "b")
+	waitForQueue(t, l, 3)
+	enqueue("c1", Interactive, "c")
+	waitForQueue(t, l, 4)
+
+	release()
+	want := []string{"c1", "a1", "b1", "a2"}
+	for i, name := range want {
+		if got := <-order; got != name {
+			t.Fatalf("position %d: expected %s, got %s", i, name, got)
+		}
+	}
+}
+
+func TestLimiterReservesSlotForInteractive(t *testing.T) {
+	l := New("video generation", 2, 0, 20*time.Millisecond)
+	batch := WithPriority(context.Background(), Batch, "a")
+
+	release, err := l.Acquire(batch)
+	if err != nil {
+		t.Fatalf("first batch acquire failed: %v", err)
+	}
+	defer release()
+
+	if _, err := l.Acquire(batch); err == nil {
+		t.Fatal("expected batch caller to be kept off the last slot")
+	}
+
+	interactive, err := l.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("interactive acquire failed: %v", err)
+	}
+	interactive()
+}
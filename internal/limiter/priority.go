@@ -0,0 +1,43 @@
+package limiter
+
+import "context"
+
+// Priority is the scheduling class of a caller. Lower values are served first.
+type Priority int
+
+const (
+	// Interactive callers are served before any queued batch work
+	Interactive Priority = iota
+	// Batch callers only get slots no interactive caller is waiting for
+	Batch
+
+	numPriorities
+)
+
+// String returns the configuration name of the priority class
+func (p Priority) String() string {
+	if p == Batch {
+		return "batch"
+	}
+	return "interactive"
+}
+
+type priorityKey struct{}
+
+type priorityInfo struct {
+	priority Priority
+	client   string
+}
+
+// WithPriority returns a context whose Acquire calls are scheduled in the given
+// class. client identifies the caller for round-robin fairness within a class.
+func WithPriority(ctx context.Context, priority Priority, client string) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priorityInfo{priority: priority, client: client})
+}
+
+func priorityFromContext(ctx context.Context) (Priority, string) {
+	if info, ok := ctx.Value(priorityKey{}).(priorityInfo); ok {
+		return info.priority, info.client
+	}
+	return Interactive, ""
+}
@@ -0,0 +1,221 @@
+// Package media performs the video and image operations that need ffmpeg.
+// It finds ffmpeg once, runs each operation with it when it is installed and
+// otherwise falls back to a pure-Go implementation where one exists, so the
+// tools degrade gracefully on minimal containers. Capabilities reports which
+// backend serves each operation.
+package media
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/tempfiles"
+	"gemini-mcp/internal/video"
+)
+
+// Operations performed by the toolkit
+const (
+	OpVideoThumbnail  = "video_thumbnail"  // Preview image of a stored video
+	OpFrameExtraction = "frame_extraction" // Frame of a video at an offset
+	OpVideoTrim       = "video_trim"       // Clip cut out of a video
+	OpVideoConcat     = "video_concat"     // Videos joined into one
+	OpWebPEncode      = "webp_encode"      // Images converted to WebP
+	OpGIFEncode       = "gif_encode"       // Animated GIFs from image frames
+)
+
+// Backend is how an operation is performed
+type Backend string
+
+const (
+	FFmpeg      Backend = "ffmpeg"
+	PureGo      Backend = "pure_go"
+	Unavailable Backend = "unavailable"
+)
+
+// ErrNoFFmpeg is returned by operations that cannot be performed without ffmpeg
+var ErrNoFFmpeg = errors.New("ffmpeg not found (set FFMPEG_PATH)")
+
+// Capabilities describes the media operations available on this server
+type Capabilities struct {
+	FFmpeg     bool               `json:"ffmpeg"`
+	FFmpegPath string             `json:"ffmpeg_path,omitempty"`
+	Operations map[string]Backend `json:"operations"`
+}
+
+// Toolkit performs media operations with ffmpeg or pure-Go fallbacks
+type Toolkit struct {
+	ffmpeg    string             // Resolved ffmpeg binary; empty if not installed
+	tempFiles *tempfiles.Manager // Directory of ffmpeg's inputs and outputs
+}
+
+// New creates a toolkit using the ffmpeg binary at ffmpegPath, a path or a
+// name looked up in PATH, and keeping its intermediate files in temp. A
+// missing binary enables the fallbacks.
+func New(ffmpegPath string, temp *tempfiles.Manager) *Toolkit {
+	path, err := exec.LookPath(ffmpegPath)
+	if err != nil {
+		path = ""
+	}
+	return &Toolkit{ffmpeg: path, tempFiles: temp}
+}
+
+// FFmpegPath returns the resolved ffmpeg binary, or "" if it is not installed
+func (t *Toolkit) FFmpegPath() string {
+	return t.ffmpeg
+}
+
+// Backend returns how an operation is performed on this server
+func (t *Toolkit) Backend(op string) Backend {
+	if t.ffmpeg != "" && op != OpGIFEncode {
+		return FFmpeg
+	}
+	switch op {
+	case OpVideoThumbnail, OpVideoTrim, OpGIFEncode:
+		return PureGo
+	default:
+		return Unavailable
+	}
+}
+
+// Capabilities reports whether ffmpeg is installed and the backend of every
+// operation
+func (t *Toolkit) Capabilities() Capabilities {
+	caps := Capabilities{FFmpeg: t.ffmpeg != "", FFmpegPath: t.ffmpeg, Operations: make(map[string]Backend)}
+	for _, op := range []string{OpVideoThumbnail, OpFrameExtraction, OpVideoTrim, OpVideoConcat, OpWebPEncode, OpGIFEncode} {
+		caps.Operations[op] = t.Backend(op)
+	}
+	return caps
+}
+
+// ExtractFrame returns the frame at the given offset of a video as a JPEG no
+// larger than maxDim on either side (0 keeps the original size). There is no
+// pure-Go video decoder, so this requires ffmpeg.
+func (t *Toolkit) ExtractFrame(ctx context.Context, videoPath string, at time.Duration, maxDim int) ([]byte, error) {
+	if t.ffmpeg == "" {
+		return nil, ErrNoFFmpeg
+	}
+	return video.ExtractFrame(ctx, t.ffmpeg, videoPath, at, maxDim)
+}
+
+// Thumbnail returns a JPEG preview of a video no larger than maxDim on either
+// side: its first frame with ffmpeg, or a placeholder poster of the video's
+// aspect ratio without it (MP4 and MOV only)
+func (t *Toolkit) Thumbnail(ctx context.Context, data []byte, ext string, maxDim int) ([]byte, Backend, error) {
+	if t.ffmpeg == "" {
+		poster, err := Poster(data, maxDim)
+		return poster, PureGo, err
+	}
+	path, cleanup, err := t.tempFile("thumb_*"+ext, data)
+	if err != nil {
+		return nil, FFmpeg, err
+	}
+	defer cleanup()
+	frame, err := video.ExtractFrame(ctx, t.ffmpeg, path, 0, maxDim)
+	return frame, FFmpeg, err
+}
+
+// Trim cuts the part between start and end out of a video. With ffmpeg the
+// clip is re-encoded for a frame-accurate cut or has its streams copied;
+// without it, MP4 and MOV videos get an edit list (see video.TrimEditList),
+// which players honor but which keeps the size of the full video.
+func (t *Toolkit) Trim(ctx context.Context, videoPath string, data []byte, start, end time.Duration, reencode bool) ([]byte, Backend, error) {
+	if t.ffmpeg == "" {
+		trimmed, err := video.TrimEditList(data, start, end)
+		return trimmed, PureGo, err
+	}
+	trimmed, err := t.toTemp("video_trim_*.mp4", func(out string) error {
+		return video.Trim(ctx, t.ffmpeg, videoPath, out, start, end, reencode)
+	})
+	return trimmed, FFmpeg, err
+}
+
+// Concat joins videos of the same size into an MP4 (see video.Concat). It
+// requires ffmpeg.
+func (t *Toolkit) Concat(ctx context.Context, videoPaths []string, durations []time.Duration, crossfade time.Duration, transition string, audio bool) ([]byte, error) {
+	if t.ffmpeg == "" {
+		return nil, ErrNoFFmpeg
+	}
+	return t.toTemp("video_concat_*.mp4", func(out string) error {
+		return video.Concat(ctx, t.ffmpeg, videoPaths, durations, out, crossfade, transition, audio)
+	})
+}
+
+// EncodeWebP converts an image to WebP (see imaging.EncodeWebP). The
+// standard library has no WebP encoder, so this requires ffmpeg.
+func (t *Toolkit) EncodeWebP(ctx context.Context, data []byte, quality int) ([]byte, error) {
+	if t.ffmpeg == "" {
+		return nil, ErrNoFFmpeg
+	}
+	return imaging.EncodeWebP(ctx, t.ffmpeg, data, quality)
+}
+
+// toTemp runs an ffmpeg operation writing to a temporary file and returns
+// the file's content
+func (t *Toolkit) toTemp(pattern string, run func(out string) error) ([]byte, error) {
+	out, err := t.tempFiles.Create(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	out.Close()
+	defer os.Remove(out.Name())
+
+	if err := run(out.Name()); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ffmpeg output: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("ffmpeg produced an empty video")
+	}
+	return data, nil
+}
+
+// tempFile writes data to a temporary file for ffmpeg to read
+func (t *Toolkit) tempFile(pattern string, data []byte) (string, func(), error) {
+	f, err := t.tempFiles.Create(pattern)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+// Poster renders a placeholder preview for a video that cannot be decoded: a
+// dark JPEG of the video's aspect ratio, no larger than maxDim on either
+// side, with a play symbol in its centre. It reads the video's size from the
+// MP4 or MOV container.
+func Poster(data []byte, maxDim int) ([]byte, error) {
+	info, err := video.Probe(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the video size: %w", err)
+	}
+	width, height := info.Width, info.Height
+	if maxDim > 0 && (width > maxDim || height > maxDim) {
+		if width >= height {
+			width, height = maxDim, max(1, height*maxDim/width)
+		} else {
+			width, height = max(1, width*maxDim/height), maxDim
+		}
+	}
+	var buf bytes.Buffer
+	if err := encodePoster(&buf, width, height); err != nil {
+		return nil, fmt.Errorf("failed to encode poster: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"image"
+	"testing"
+	"time"
+)
+
+func box(name string, body []byte) []byte {
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], name)
+	return append(out, body...)
+}
+
+// testMP4 builds an 8 second MP4 header with a video track of the given size
+func testMP4(width, height uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 8000)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[76:], width<<16)
+	binary.BigEndian.PutUint32(tkhd[80:], height<<16)
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:], "vide")
+	trak := box("trak", append(box("tkhd", tkhd), box("mdia", box("hdlr", hdlr))...))
+	return append(box("ftyp", []byte("isom\x00\x00\x02\x00")), box("moov", append(box("mvhd", mvhd), trak...))...)
+}
+
+func TestCapabilitiesWithoutFFmpeg(t *testing.T) {
+	toolkit := New("/nonexistent/ffmpeg", nil)
+	caps := toolkit.Capabilities()
+	if caps.FFmpeg || caps.FFmpegPath != "" {
+		t.Errorf("expected no ffmpeg, got %+v", caps)
+	}
+	want := map[string]Backend{
+		OpVideoThumbnail:  PureGo,
+		OpFrameExtraction: Unavailable,
+		OpVideoTrim:       PureGo,
+		OpVideoConcat:     Unavailable,
+		OpWebPEncode:      Unavailable,
+		OpGIFEncode:       PureGo,
+	}
+	for op, backend := range want {
+		if got := caps.Operations[op]; got != backend {
+			t.Errorf("%s = %s, want %s", op, got, backend)
+		}
+	}
+
+	if _, err := toolkit.ExtractFrame(context.Background(), "clip.mp4", 0, 0); !errors.Is(err, ErrNoFFmpeg) {
+		t.Errorf("ExtractFrame = %v, want ErrNoFFmpeg", err)
+	}
+	if _, err := toolkit.Concat(context.Background(), []string{"a.mp4", "b.mp4"}, nil, 0, "", false); !errors.Is(err, ErrNoFFmpeg) {
+		t.Errorf("Concat = %v, want ErrNoFFmpeg", err)
+	}
+}
+
+func TestCapabilitiesWithFFmpeg(t *testing.T) {
+	toolkit := &Toolkit{ffmpeg: "/usr/bin/ffmpeg"}
+	for op, backend := range toolkit.Capabilities().Operations {
+		want := FFmpeg
+		if op == OpGIFEncode {
+			want = PureGo
+		}
+		if backend != want {
+			t.Errorf("%s = %s, want %s", op, backend, want)
+		}
+	}
+}
+
+func TestThumbnailFallsBackToPoster(t *testing.T) {
+	thumb, backend, err := New("/nonexistent/ffmpeg", nil).Thumbnail(context.Background(), testMP4(1280, 720), ".mp4", 320)
+	if err != nil || backend != PureGo {
+		t.Fatalf("Thumbnail = %s, %v", backend, err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(thumb))
+	if err != nil || format != "jpeg" {
+		t.Fatalf("DecodeConfig = %q, %v", format, err)
+	}
+	if cfg.Width != 320 || cfg.Height != 180 {
+		t.Errorf("poster is %dx%d, want 320x180", cfg.Width, cfg.Height)
+	}
+}
+
+func TestPoster(t *testing.T) {
+	// Portrait videos keep their aspect ratio, small ones their size
+	data, err := Poster(testMP4(720, 1280), 256)
+	if err != nil {
+		t.Fatalf("Poster: %v", err)
+	}
+	if cfg, _, _ := image.DecodeConfig(bytes.NewReader(data)); cfg.Width != 144 || cfg.Height != 256 {
+		t.Errorf("portrait poster is %dx%d, want 144x256", cfg.Width, cfg.Height)
+	}
+	data, _ = Poster(testMP4(64, 48), 256)
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil || img.Bounds().Dx() != 64 || img.Bounds().Dy() != 48 {
+		t.Fatalf("small poster = %v, %v", img.Bounds(), err)
+	}
+	// The play symbol is lighter than the background
+	center, _, _, _ := img.At(32, 24).RGBA()
+	corner, _, _, _ := img.At(1, 1).RGBA()
+	if center <= corner {
+		t.Errorf("centre %d is not lighter than corner %d", center>>8, corner>>8)
+	}
+
+	if _, err := Poster([]byte("not a video"), 256); err == nil {
+		t.Error("expected an error for data that is not an MP4")
+	}
+}
+
+func TestTrimWithoutFFmpegNeedsMP4(t *testing.T) {
+	_, backend, err := New("/nonexistent/ffmpeg", nil).Trim(context.Background(), "clip.webm", []byte("webm"), 0, time.Second, false)
+	if backend != PureGo || err == nil {
+		t.Errorf("Trim = %s, %v; want a pure-Go failure", backend, err)
+	}
+}
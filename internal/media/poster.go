@@ -0,0 +1,39 @@
+package media
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+)
+
+var (
+	posterBackground = color.RGBA{32, 33, 36, 255}
+	posterSymbol     = color.RGBA{232, 234, 237, 255}
+)
+
+// encodePoster draws the placeholder poster as a JPEG: a dark canvas with a
+// play triangle a third of its shorter side high
+func encodePoster(w io.Writer, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	size := float64(min(width, height)) / 3
+	cx, cy := float64(width)/2, float64(height)/2
+	// The triangle points right; its left edge sits left of the centre so
+	// that it looks centred
+	left, right := cx-size*0.4, cx+size*0.6
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			px, py := float64(x)+0.5, float64(y)+0.5
+			c := posterBackground
+			if px >= left && px <= right {
+				// Half height of the triangle at this column
+				half := size / 2 * (right - px) / (right - left)
+				if py >= cy-half && py <= cy+half {
+					c = posterSymbol
+				}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: 80})
+}
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
@@ -17,6 +18,10 @@ const (
 	AuthTokenKey contextKey = "authToken"
 	// ServerURLKey is the context key for server base URL
 	ServerURLKey contextKey = "serverURL"
+	// ClaimsKey is the context key for validated JWT claims
+	ClaimsKey contextKey = "claims"
+	// TenantKey is the context key for the tenant of a static service token
+	TenantKey contextKey = "tenant"
 )
 
 // GetUploadMediaPath extracts the upload media path from context
@@ -43,6 +48,26 @@ func GetServerURL(ctx context.Context) string {
 	return ""
 }
 
+// GetClaims extracts validated JWT claims from context (nil for static tokens)
+func GetClaims(ctx context.Context) *Claims {
+	if v := ctx.Value(ClaimsKey); v != nil {
+		return v.(*Claims)
+	}
+	return nil
+}
+
+// GetTenant extracts the caller's tenant from context: the tenant mapped to
+// its service token (see TenantMiddleware) or the tenant claim of its JWT
+func GetTenant(ctx context.Context) string {
+	if v := ctx.Value(TenantKey); v != nil {
+		return v.(string)
+	}
+	if claims := GetClaims(ctx); claims != nil {
+		return claims.Tenant
+	}
+	return ""
+}
+
 // HeadersMiddleware injects custom headers into the request context
 func HeadersMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -78,8 +103,23 @@ func HeadersMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-// AuthMiddleware creates an HTTP middleware that validates Bearer tokens
-func AuthMiddleware(validTokens []string, next http.Handler) http.Handler {
+// ErrRateLimited is returned by a TokenStore when a token exceeded its
+// request rate limit
+var ErrRateLimited = errors.New("token rate limit exceeded")
+
+// TokenStore authenticates tokens managed at runtime, such as those created
+// through the admin API. Authenticate returns nil claims and a nil error for
+// tokens it does not know.
+type TokenStore interface {
+	Authenticate(token string) (*Claims, error)
+}
+
+// AuthMiddleware creates an HTTP middleware that validates Bearer tokens.
+// Tokens are accepted if they match a static service token, a token of
+// tokenStore when it is non-nil or, when jwtValidator is non-nil, if they are
+// valid JWTs; claims of managed tokens and JWTs are stored in the request
+// context.
+func AuthMiddleware(validTokens []string, tokenStore TokenStore, jwtValidator *JWTValidator, next http.Handler) http.Handler {
 	// Build a set for O(1) token lookup
 	tokenSet := make(map[string]struct{}, len(validTokens))
 	for _, token := range validTokens {
@@ -88,7 +128,7 @@ func AuthMiddleware(validTokens []string, next http.Handler) http.Handler {
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip authentication if no tokens are configured
-		if len(tokenSet) == 0 {
+		if len(tokenSet) == 0 && tokenStore == nil && jwtValidator == nil {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -115,10 +155,36 @@ func AuthMiddleware(validTokens []string, next http.Handler) http.Handler {
 
 		// Validate token
 		if _, valid := tokenSet[token]; !valid {
-			log.Printf("Auth failed: invalid token from %s", r.RemoteAddr)
-			w.Header().Set("Content-Type", "application/json")
-			http.Error(w, `{"jsonrpc":"2.0","error":{"code":-32001,"message":"Invalid token"}}`, http.StatusUnauthorized)
-			return
+			if tokenStore != nil {
+				claims, err := tokenStore.Authenticate(token)
+				if errors.Is(err, ErrRateLimited) {
+					log.Printf("Auth failed: token rate limit exceeded from %s", r.RemoteAddr)
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("Retry-After", "60")
+					http.Error(w, `{"jsonrpc":"2.0","error":{"code":-32001,"message":"Token rate limit exceeded"}}`, http.StatusTooManyRequests)
+					return
+				}
+				if claims != nil {
+					next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), ClaimsKey, claims)))
+					return
+				}
+			}
+
+			if jwtValidator == nil || !LooksLikeJWT(token) {
+				log.Printf("Auth failed: invalid token from %s", r.RemoteAddr)
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"jsonrpc":"2.0","error":{"code":-32001,"message":"Invalid token"}}`, http.StatusUnauthorized)
+				return
+			}
+
+			claims, err := jwtValidator.Validate(r.Context(), token)
+			if err != nil {
+				log.Printf("Auth failed: invalid JWT from %s: %v", r.RemoteAddr, err)
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"jsonrpc":"2.0","error":{"code":-32001,"message":"Invalid token"}}`, http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), ClaimsKey, claims))
 		}
 
 		// Token is valid, proceed to handler
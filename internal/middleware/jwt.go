@@ -0,0 +1,419 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTConfig configures validation of JWTs minted by an external identity provider
+type JWTConfig struct {
+	// JWKSURL is where signing keys are fetched from. When empty it is
+	// discovered from Issuer's /.well-known/openid-configuration.
+	JWKSURL string
+	// Issuer must match the token's iss claim exactly (optional when JWKSURL is set)
+	Issuer string
+	// Audience must be present in the token's aud claim (optional)
+	Audience string
+	// ScopeClaim names the claim holding granted scopes (default: "scope").
+	// Both space-separated strings and string arrays are accepted.
+	ScopeClaim string
+	// TenantClaim names the claim holding the caller's tenant (default: "tenant")
+	TenantClaim string
+	// RequiredScope, if set, must be among the token's scopes
+	RequiredScope string
+	// CacheTTL is how long fetched keys are reused (default: 1h)
+	CacheTTL time.Duration
+}
+
+// Claims is the subset of validated token claims made available to handlers
+type Claims struct {
+	Subject   string
+	Scopes    []string
+	Tenant    string
+	ExpiresAt time.Time
+}
+
+// HasScope reports whether the claims grant the given scope
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// clockSkew is the leeway applied to exp and nbf checks
+const clockSkew = time.Minute
+
+// minKeyRefresh limits how often an unknown kid can trigger a JWKS refetch
+const minKeyRefresh = time.Minute
+
+// JWTValidator verifies RS256/384/512 and ES256/384/512 signed JWTs against a JWKS
+type JWTValidator struct {
+	cfg    JWTConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	jwksURL     string
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewJWTValidator creates a validator. Keys are fetched lazily on first use.
+func NewJWTValidator(cfg JWTConfig) (*JWTValidator, error) {
+	if cfg.JWKSURL == "" && cfg.Issuer == "" {
+		return nil, fmt.Errorf("either a JWKS URL or an issuer is required")
+	}
+	if cfg.ScopeClaim == "" {
+		cfg.ScopeClaim = "scope"
+	}
+	if cfg.TenantClaim == "" {
+		cfg.TenantClaim = "tenant"
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	return &JWTValidator{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		jwksURL: cfg.JWKSURL,
+	}, nil
+}
+
+// LooksLikeJWT reports whether a bearer token has the three-segment JWT shape
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Validate checks the token's signature and standard claims and returns its claims
+func (v *JWTValidator) Validate(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var payload map[string]any
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, fmt.Errorf("invalid token payload: %w", err)
+	}
+	return v.checkClaims(payload, time.Now())
+}
+
+// checkClaims validates registered claims and extracts scopes and tenant
+func (v *JWTValidator) checkClaims(payload map[string]any, now time.Time) (*Claims, error) {
+	exp, ok := numericClaim(payload, "exp")
+	if !ok {
+		return nil, fmt.Errorf("token has no expiry")
+	}
+	expiresAt := time.Unix(exp, 0)
+	if now.After(expiresAt.Add(clockSkew)) {
+		return nil, fmt.Errorf("token expired")
+	}
+	if nbf, ok := numericClaim(payload, "nbf"); ok && now.Add(clockSkew).Before(time.Unix(nbf, 0)) {
+		return nil, fmt.Errorf("token not yet valid")
+	}
+
+	if v.cfg.Issuer != "" {
+		if iss, _ := payload["iss"].(string); iss != v.cfg.Issuer {
+			return nil, fmt.Errorf("unexpected issuer %q", iss)
+		}
+	}
+	if v.cfg.Audience != "" && !containsString(stringsClaim(payload["aud"]), v.cfg.Audience) {
+		return nil, fmt.Errorf("token not issued for audience %q", v.cfg.Audience)
+	}
+
+	claims := &Claims{
+		Scopes:    stringsClaim(payload[v.cfg.ScopeClaim]),
+		ExpiresAt: expiresAt,
+	}
+	claims.Subject, _ = payload["sub"].(string)
+	claims.Tenant, _ = payload[v.cfg.TenantClaim].(string)
+
+	if v.cfg.RequiredScope != "" && !claims.HasScope(v.cfg.RequiredScope) {
+		return nil, fmt.Errorf("token is missing required scope %q", v.cfg.RequiredScope)
+	}
+	return claims, nil
+}
+
+// key returns the signing key for kid, refreshing the JWKS when the cache is
+// stale or the kid is unknown
+func (v *JWTValidator) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	stale := time.Since(v.fetchedAt) > v.cfg.CacheTTL
+	_, known := v.lookup(kid)
+	if (stale || !known) && time.Since(v.lastAttempt) > minKeyRefresh {
+		v.lastAttempt = time.Now()
+		if err := v.refresh(ctx); err != nil {
+			if v.keys == nil {
+				return nil, err
+			}
+			// Keep serving cached keys if the identity provider is briefly unavailable
+		}
+	}
+
+	key, ok := v.lookup(kid)
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// FetchKeys fetches the signing keys now, rather than on first use, and
+// returns how many are usable
+func (v *JWTValidator) FetchKeys(ctx context.Context) (int, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.lastAttempt = time.Now()
+	if err := v.refresh(ctx); err != nil {
+		return 0, err
+	}
+	return len(v.keys), nil
+}
+
+// lookup finds a cached key; an empty kid matches when exactly one key is cached
+func (v *JWTValidator) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+// refresh fetches the JWKS, discovering its URL from the issuer if needed.
+// Callers must hold v.mu.
+func (v *JWTValidator) refresh(ctx context.Context) error {
+	if v.jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(ctx, strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+			return fmt.Errorf("OIDC discovery failed: %w", err)
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("OIDC discovery document has no jwks_uri")
+		}
+		v.jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, v.jwksURL, &set); err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue // unsupported key types are skipped
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS contains no usable signing keys")
+	}
+
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+func (v *JWTValidator) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey is an RSA or EC public key in JWK form
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// verifySignature checks signature over signingInput for the given JWS alg.
+// Symmetric and "none" algorithms are rejected.
+func verifySignature(alg string, key crypto.PublicKey, signingInput string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", alg)
+		}
+		if err := rsa.VerifyPKCS1v15(k, hash, digest, signature); err != nil {
+			return fmt.Errorf("invalid token signature")
+		}
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match EC key", alg)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("invalid token signature")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid token signature")
+		}
+	default:
+		return fmt.Errorf("unsupported key type")
+	}
+	return nil
+}
+
+func decodeSegment(segment string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func numericClaim(payload map[string]any, name string) (int64, bool) {
+	switch v := payload[name].(type) {
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// stringsClaim normalizes a claim that may be a space-separated string or a string array
+func stringsClaim(v any) []string {
+	switch c := v.(type) {
+	case string:
+		return strings.Fields(c)
+	case []any:
+		var out []string
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	default:
+		return nil
+	}
+}
+
+func containsString(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := enc(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + enc(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func newTestIssuer(t *testing.T) (*rsa.PrivateKey, *httptest.Server) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]string{"jwks_uri": srv.URL + "/jwks"})
+		case "/jwks":
+			json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return key, srv
+}
+
+func TestJWTValidator(t *testing.T) {
+	key, srv := newTestIssuer(t)
+	v, err := NewJWTValidator(JWTConfig{Issuer: srv.URL, Audience: "gemini-mcp", RequiredScope: "media:generate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := map[string]any{
+		"iss":    srv.URL,
+		"aud":    []string{"gemini-mcp", "other"},
+		"sub":    "user-1",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"scope":  "media:read media:generate",
+		"tenant": "acme",
+	}
+	claims, err := v.Validate(context.Background(), signRS256(t, key, "k1", valid))
+	if err != nil {
+		t.Fatalf("expected valid token, got %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Tenant != "acme" || !claims.HasScope("media:read") {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+
+	cases := map[string]func(map[string]any){
+		"expired":        func(c map[string]any) { c["exp"] = time.Now().Add(-time.Hour).Unix() },
+		"wrong issuer":   func(c map[string]any) { c["iss"] = "https://evil.example" },
+		"wrong audience": func(c map[string]any) { c["aud"] = "someone-else" },
+		"missing scope":  func(c map[string]any) { c["scope"] = "media:read" },
+		"no expiry":      func(c map[string]any) { delete(c, "exp") },
+	}
+	for name, mutate := range cases {
+		c := map[string]any{}
+		for k, val := range valid {
+			c[k] = val
+		}
+		mutate(c)
+		if _, err := v.Validate(context.Background(), signRS256(t, key, "k1", c)); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+
+	// A token signed by a different key must be rejected
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := v.Validate(context.Background(), signRS256(t, other, "k1", valid)); err == nil {
+		t.Error("expected signature from unknown key to be rejected")
+	}
+}
+
+func TestAuthMiddlewareAcceptsJWTAndStaticTokens(t *testing.T) {
+	key, srv := newTestIssuer(t)
+	v, err := NewJWTValidator(JWTConfig{JWKSURL: srv.URL + "/jwks"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tenant string
+	handler := AuthMiddleware([]string{"static-token"}, nil, v, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = GetTenant(r.Context())
+	}))
+
+	jwt := signRS256(t, key, "k1", map[string]any{"exp": time.Now().Add(time.Hour).Unix(), "tenant": "acme"})
+	for token, wantStatus := range map[string]int{
+		"static-token": http.StatusOK,
+		jwt:            http.StatusOK,
+		"nope":         http.StatusUnauthorized,
+		"a.b.c":        http.StatusUnauthorized,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != wantStatus {
+			t.Errorf("token %.12q: expected status %d, got %d", token, wantStatus, rec.Code)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if tenant != "acme" {
+		t.Errorf("expected tenant from JWT claims, got %q", tenant)
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+
+	"gemini-mcp/internal/limiter"
+)
+
+// PriorityMiddleware assigns each request a generation scheduling class.
+// Requests authenticated with one of batchTokens, or with a JWT carrying
+// batchScope, are scheduled as batch work; everything else is interactive.
+// Callers are identified by JWT subject or bearer token so that queued work
+// is shared fairly between clients. It must run after AuthMiddleware.
+func PriorityMiddleware(batchTokens []string, batchScope string, next http.Handler) http.Handler {
+	batchSet := make(map[string]struct{}, len(batchTokens))
+	for _, token := range batchTokens {
+		batchSet[token] = struct{}{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		priority := limiter.Interactive
+		client := GetAuthToken(ctx)
+
+		if claims := GetClaims(ctx); claims != nil {
+			client = "sub:" + claims.Subject
+			if batchScope != "" && claims.HasScope(batchScope) {
+				priority = limiter.Batch
+			}
+		} else if _, ok := batchSet[client]; ok {
+			priority = limiter.Batch
+		}
+
+		next.ServeHTTP(w, r.WithContext(limiter.WithPriority(ctx, priority, client)))
+	})
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ParseTokenTenants reads service token to tenant mappings of the form
+// "token=tenant"
+func ParseTokenTenants(entries []string) (map[string]string, error) {
+	tenants := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		token, tenant, ok := strings.Cut(entry, "=")
+		token, tenant = strings.TrimSpace(token), strings.TrimSpace(tenant)
+		if !ok || token == "" || tenant == "" {
+			return nil, fmt.Errorf("invalid token tenant %q (expected token=tenant)", entry)
+		}
+		tenants[token] = tenant
+	}
+	return tenants, nil
+}
+
+// TenantMiddleware records the tenant mapped to the caller's service token in
+// the request context, where GetTenant finds it. Callers authenticated with a
+// JWT keep the tenant of its claims. It must run after AuthMiddleware.
+func TenantMiddleware(tokenTenants map[string]string, next http.Handler) http.Handler {
+	if len(tokenTenants) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetClaims(r.Context()) == nil {
+			token := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+			if tenant, ok := tokenTenants[token]; ok {
+				r = r.WithContext(context.WithValue(r.Context(), TenantKey, tenant))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTokenTenants(t *testing.T) {
+	tenants, err := ParseTokenTenants([]string{"token1=acme", " token2 = globex "})
+	if err != nil {
+		t.Fatalf("ParseTokenTenants: %v", err)
+	}
+	if tenants["token1"] != "acme" || tenants["token2"] != "globex" {
+		t.Errorf("tenants = %v", tenants)
+	}
+	for _, entry := range []string{"token1", "=acme", "token1="} {
+		if _, err := ParseTokenTenants([]string{entry}); err == nil {
+			t.Errorf("ParseTokenTenants(%q) accepted an invalid entry", entry)
+		}
+	}
+}
+
+func TestTenantMiddleware(t *testing.T) {
+	var got string
+	handler := TenantMiddleware(map[string]string{"token1": "acme"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = GetTenant(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		token  string
+		claims *Claims
+		want   string
+	}{
+		{"mapped token", "token1", nil, "acme"},
+		{"unmapped token", "token2", nil, ""},
+		{"jwt", "token1", &Claims{Tenant: "globex"}, "globex"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		r.Header.Set("Authorization", "Bearer "+tt.token)
+		if tt.claims != nil {
+			r = r.WithContext(context.WithValue(r.Context(), ClaimsKey, tt.claims))
+		}
+		got = ""
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if got != tt.want {
+			t.Errorf("%s: tenant = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package models restricts which models tool calls may use, as configured by
+// MODEL_ALLOWLIST.
+package models
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// Allowlist maps tool names to the model patterns the tool may use. Patterns
+// under the empty tool name apply to every tool without entries of its own.
+// An empty Allowlist allows every model.
+type Allowlist map[string][]string
+
+// Parse reads allowlist entries of the form "pattern" or "tool=pattern".
+// Patterns are model names and may use path.Match wildcards, e.g. "veo-3.1-*".
+func Parse(entries []string) (Allowlist, error) {
+	allowlist := Allowlist{}
+	for _, entry := range entries {
+		tool, pattern, found := strings.Cut(entry, "=")
+		if !found {
+			tool, pattern = "", entry
+		}
+		tool, pattern = strings.TrimSpace(tool), strings.TrimSpace(pattern)
+		if pattern == "" || found && tool == "" {
+			return nil, fmt.Errorf("invalid entry %q (use model or tool=model)", entry)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		allowlist[tool] = append(allowlist[tool], pattern)
+	}
+	return allowlist, nil
+}
+
+// Patterns returns the model patterns that apply to tool, or nil when the tool
+// may use any model
+func (a Allowlist) Patterns(tool string) []string {
+	if patterns, ok := a[tool]; ok {
+		return patterns
+	}
+	return a[""]
+}
+
+// Allowed reports whether tool may use model
+func (a Allowlist) Allowed(tool, model string) bool {
+	patterns := a.Patterns(tool)
+	if patterns == nil {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns an error naming the allowed models when tool may not use model
+func (a Allowlist) Check(tool, model string) error {
+	if a.Allowed(tool, model) {
+		return nil
+	}
+	patterns := append([]string(nil), a.Patterns(tool)...)
+	sort.Strings(patterns)
+	return toolerr.Errorf(toolerr.InvalidInput, "model %q is not allowed for %s (allowed: %s)", model, tool, strings.Join(patterns, ", "))
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	allowlist, err := Parse([]string{
+		"gemini-3-pro-image-preview",
+		"gemini-2.5-*",
+		"veo_text_to_video=veo-3.1-fast-generate-preview",
+		" veo_image_to_video = veo-3.1-* ",
+	})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	tests := []struct {
+		tool, model string
+		want        bool
+	}{
+		{"gemini_image_generation", "gemini-3-pro-image-preview", true},
+		{"gemini_image_generation", "gemini-2.5-flash-image", true},
+		{"gemini_image_generation", "imagen-4.0-generate-001", false},
+		{"veo_text_to_video", "veo-3.1-fast-generate-preview", true},
+		{"veo_text_to_video", "veo-3.1-generate-preview", false},
+		{"veo_text_to_video", "gemini-2.5-flash", false},
+		{"veo_image_to_video", "veo-3.1-generate-preview", true},
+		{"veo_image_to_video", "veo-3.0-generate-preview", false},
+	}
+	for _, tt := range tests {
+		if got := allowlist.Allowed(tt.tool, tt.model); got != tt.want {
+			t.Errorf("Allowed(%q, %q) = %v, want %v", tt.tool, tt.model, got, tt.want)
+		}
+	}
+
+	err = allowlist.Check("veo_text_to_video", "veo-3.1-generate-preview")
+	if err == nil || !strings.Contains(err.Error(), "veo-3.1-fast-generate-preview") {
+		t.Errorf("Check error = %v, want the allowed models listed", err)
+	}
+}
+
+func TestEmptyAllowlistAllowsEverything(t *testing.T) {
+	allowlist, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !allowlist.Allowed("gemini_image_generation", "any-model") {
+		t.Error("empty allowlist rejected a model")
+	}
+	if err := allowlist.Check("veo_text_to_video", "any-model"); err != nil {
+		t.Errorf("Check = %v", err)
+	}
+
+	// Tools with entries of their own are restricted even without global entries
+	allowlist, _ = Parse([]string{"veo_text_to_video=veo-3.1-*"})
+	if !allowlist.Allowed("gemini_image_generation", "any-model") || allowlist.Allowed("veo_text_to_video", "veo-3.0-generate-preview") {
+		t.Error("per-tool entries did not restrict only their tool")
+	}
+}
+
+func TestParseRejectsInvalidEntries(t *testing.T) {
+	for _, entry := range []string{"=veo-3.1-*", "veo_text_to_video=", "gemini-[", " "} {
+		if _, err := Parse([]string{entry}); err == nil {
+			t.Errorf("Parse(%q) succeeded", entry)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Package music generates music with Lyria RealTime, Google's streaming
+// music model. The genai SDK has no client for it yet, so this package speaks
+// the BidiGenerateMusic WebSocket protocol of the Gemini API directly: it
+// sets up a session, sends weighted prompts and a generation config, starts
+// playback and collects the streamed PCM audio until the requested duration
+// is reached.
+package music
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// DefaultModel is the Lyria RealTime model
+	DefaultModel = "lyria-realtime-exp"
+
+	// DefaultEndpoint is the Gemini API's music generation WebSocket
+	DefaultEndpoint = "wss://generativelanguage.googleapis.com/ws/google.ai.generativelanguage.v1alpha.GenerativeService.BidiGenerateMusic"
+
+	// readTimeout bounds the wait for each message from the server
+	readTimeout = 30 * time.Second
+
+	// Lyria streams 48 kHz stereo unless a chunk's MIME type says otherwise
+	defaultSampleRate = 48000
+	defaultChannels   = 2
+)
+
+// WeightedPrompt steers the music; weights are relative to the other prompts
+type WeightedPrompt struct {
+	Text   string  `json:"text"`
+	Weight float64 `json:"weight"`
+}
+
+// Config is the music generation config of a session. Zero values are left
+// to the model.
+type Config struct {
+	BPM         int      `json:"bpm,omitempty"`         // Beats per minute, 60-200
+	Temperature *float64 `json:"temperature,omitempty"` // 0-3
+	Guidance    *float64 `json:"guidance,omitempty"`    // How strictly the prompts are followed, 0-6
+	Density     *float64 `json:"density,omitempty"`     // Density of notes and sounds, 0-1
+	Brightness  *float64 `json:"brightness,omitempty"`  // Tonal brightness, 0-1
+	Scale       string   `json:"scale,omitempty"`       // e.g. C_MAJOR_A_MINOR
+}
+
+// Request describes a piece of music to generate
+type Request struct {
+	Model    string // Defaults to DefaultModel
+	Prompts  []WeightedPrompt
+	Config   Config
+	Duration time.Duration
+}
+
+// Result is the generated audio as signed 16-bit little-endian PCM
+type Result struct {
+	Data       []byte
+	SampleRate int
+	Channels   int
+	Warnings   []string // Warnings the server sent during the session
+}
+
+// FilteredError reports a prompt the model refused, e.g. for naming an artist
+type FilteredError struct {
+	Prompt string
+	Reason string
+}
+
+func (e *FilteredError) Error() string {
+	return fmt.Sprintf("prompt %q was filtered: %s", e.Prompt, e.Reason)
+}
+
+// Client connects to Lyria RealTime
+type Client struct {
+	Endpoint string // Defaults to DefaultEndpoint
+	APIKey   string
+	Dialer   *websocket.Dialer // Defaults to websocket.DefaultDialer
+}
+
+// clientMessage is one message sent to the server; exactly one field is set
+type clientMessage struct {
+	Setup                 *setup         `json:"setup,omitempty"`
+	ClientContent         *clientContent `json:"clientContent,omitempty"`
+	MusicGenerationConfig *Config        `json:"musicGenerationConfig,omitempty"`
+	PlaybackControl       string         `json:"playbackControl,omitempty"`
+}
+
+type setup struct {
+	Model string `json:"model"`
+}
+
+type clientContent struct {
+	WeightedPrompts []WeightedPrompt `json:"weightedPrompts"`
+}
+
+// serverMessage is one message received from the server
+type serverMessage struct {
+	SetupComplete *struct{} `json:"setupComplete,omitempty"`
+	ServerContent *struct {
+		AudioChunks []struct {
+			Data     string `json:"data"`
+			MIMEType string `json:"mimeType"`
+		} `json:"audioChunks"`
+	} `json:"serverContent,omitempty"`
+	FilteredPrompt *struct {
+		Text           string `json:"text"`
+		FilteredReason string `json:"filteredReason"`
+	} `json:"filteredPrompt,omitempty"`
+	Warning string `json:"warning,omitempty"`
+}
+
+// Generate streams music for req.Duration and returns the audio, trimmed to
+// that length
+func (c *Client) Generate(ctx context.Context, req Request) (*Result, error) {
+	if len(req.Prompts) == 0 {
+		return nil, fmt.Errorf("at least one prompt is required")
+	}
+	if req.Duration <= 0 {
+		return nil, fmt.Errorf("duration must be positive")
+	}
+	model := req.Model
+	if model == "" {
+		model = DefaultModel
+	}
+	if !strings.HasPrefix(model, "models/") {
+		model = "models/" + model
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	header := http.Header{}
+	header.Set("x-goog-api-key", c.APIKey)
+	conn, resp, err := dialer.DialContext(ctx, endpoint, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("failed to connect to Lyria: %w (HTTP %d)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to connect to Lyria: %w", err)
+	}
+	defer conn.Close()
+
+	// Unblock reads when the caller gives up
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	session := &session{conn: conn}
+	if err := session.send(clientMessage{Setup: &setup{Model: model}}); err != nil {
+		return nil, err
+	}
+	for {
+		msg, err := session.receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg.SetupComplete != nil {
+			break
+		}
+	}
+
+	config := req.Config
+	for _, msg := range []clientMessage{
+		{ClientContent: &clientContent{WeightedPrompts: req.Prompts}},
+		{MusicGenerationConfig: &config},
+		{PlaybackControl: "PLAY"},
+	} {
+		if err := session.send(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{SampleRate: defaultSampleRate, Channels: defaultChannels}
+	want := 0
+	for want == 0 || len(result.Data) < want {
+		msg, err := session.receive(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if msg.FilteredPrompt != nil {
+			return nil, &FilteredError{Prompt: msg.FilteredPrompt.Text, Reason: msg.FilteredPrompt.FilteredReason}
+		}
+		if msg.Warning != "" {
+			result.Warnings = append(result.Warnings, msg.Warning)
+		}
+		if msg.ServerContent == nil {
+			continue
+		}
+		for _, chunk := range msg.ServerContent.AudioChunks {
+			data, err := base64.StdEncoding.DecodeString(chunk.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid audio chunk: %w", err)
+			}
+			if want == 0 {
+				result.SampleRate, result.Channels = parseAudioMIME(chunk.MIMEType)
+				want = int(req.Duration.Seconds()*float64(result.SampleRate)) * result.Channels * 2
+			}
+			result.Data = append(result.Data, data...)
+		}
+	}
+	result.Data = result.Data[:want]
+
+	// Stopping is a courtesy to the server; the audio is complete either way
+	session.send(clientMessage{PlaybackControl: "STOP"})
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return result, nil
+}
+
+// session reads and writes the JSON messages of a connection
+type session struct {
+	conn *websocket.Conn
+}
+
+func (s *session) send(msg clientMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if err := s.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+		return fmt.Errorf("failed to send to Lyria: %w", err)
+	}
+	return nil
+}
+
+func (s *session) receive(ctx context.Context) (*serverMessage, error) {
+	s.conn.SetReadDeadline(time.Now().Add(readTimeout))
+	_, data, err := s.conn.ReadMessage()
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var closeErr *websocket.CloseError
+		if errors.As(err, &closeErr) && closeErr.Text != "" {
+			return nil, fmt.Errorf("session closed by Lyria: %s", closeErr.Text)
+		}
+		return nil, fmt.Errorf("failed to read from Lyria: %w", err)
+	}
+	var msg serverMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("invalid message from Lyria: %w", err)
+	}
+	return &msg, nil
+}
+
+// parseAudioMIME returns the sample rate and channels of a MIME type such as
+// "audio/l16;rate=48000;channels=2", with Lyria's defaults for missing values
+func parseAudioMIME(mimeType string) (sampleRate, channels int) {
+	sampleRate, channels = defaultSampleRate, defaultChannels
+	for _, param := range strings.Split(mimeType, ";")[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "rate":
+			sampleRate = n
+		case "channels":
+			channels = n
+		}
+	}
+	return sampleRate, channels
+}
@@ -0,0 +1,137 @@
+package music
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeLyria serves the music protocol, streaming chunks of the given size
+// after PLAY, or filtering the first prompt when filter is set
+func fakeLyria(t *testing.T, chunkSize int, filter string) (*httptest.Server, chan clientMessage) {
+	t.Helper()
+	received := make(chan clientMessage, 16)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			http.Error(w, "missing key", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var msg clientMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+			received <- msg
+			switch {
+			case msg.Setup != nil:
+				conn.WriteJSON(map[string]any{"setupComplete": map[string]any{}})
+			case msg.PlaybackControl == "PLAY" && filter != "":
+				conn.WriteJSON(map[string]any{"filteredPrompt": map[string]any{"text": "in the style of someone", "filteredReason": filter}})
+			case msg.PlaybackControl == "PLAY":
+				conn.WriteJSON(map[string]any{"warning": "slow down"})
+				chunk := base64.StdEncoding.EncodeToString(make([]byte, chunkSize))
+				for range 3 {
+					err := conn.WriteJSON(map[string]any{"serverContent": map[string]any{"audioChunks": []map[string]any{
+						{"data": chunk, "mimeType": "audio/l16;rate=100;channels=2"},
+					}}})
+					if err != nil {
+						return
+					}
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+func wsURL(server *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestGenerate(t *testing.T) {
+	server, received := fakeLyria(t, 300, "")
+	client := &Client{Endpoint: wsURL(server), APIKey: "test-key"}
+	temperature := 1.2
+
+	result, err := client.Generate(context.Background(), Request{
+		Model:    "lyria-realtime-exp",
+		Prompts:  []WeightedPrompt{{Text: "minimal techno", Weight: 1}},
+		Config:   Config{BPM: 120, Temperature: &temperature},
+		Duration: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	// 2s at 100 Hz, stereo, 16-bit, trimmed from three 300-byte chunks
+	if result.SampleRate != 100 || result.Channels != 2 || len(result.Data) != 800 {
+		t.Errorf("result = %d Hz, %d channels, %d bytes", result.SampleRate, result.Channels, len(result.Data))
+	}
+	if len(result.Warnings) != 1 {
+		t.Errorf("warnings = %q", result.Warnings)
+	}
+
+	setup := <-received
+	if setup.Setup == nil || setup.Setup.Model != "models/lyria-realtime-exp" {
+		t.Errorf("setup = %+v", setup)
+	}
+	if content := <-received; content.ClientContent == nil || content.ClientContent.WeightedPrompts[0].Text != "minimal techno" {
+		t.Errorf("client content = %+v", content)
+	}
+	config := <-received
+	data, _ := json.Marshal(config)
+	if string(data) != `{"musicGenerationConfig":{"bpm":120,"temperature":1.2}}` {
+		t.Errorf("config message = %s", data)
+	}
+}
+
+func TestGenerateFiltered(t *testing.T) {
+	server, _ := fakeLyria(t, 300, "artist name")
+	client := &Client{Endpoint: wsURL(server), APIKey: "test-key"}
+
+	_, err := client.Generate(context.Background(), Request{
+		Prompts:  []WeightedPrompt{{Text: "in the style of someone", Weight: 1}},
+		Duration: time.Second,
+	})
+	var filtered *FilteredError
+	if !errors.As(err, &filtered) || filtered.Reason != "artist name" {
+		t.Errorf("Generate error = %v, want a FilteredError", err)
+	}
+}
+
+func TestGenerateRejectsBadKey(t *testing.T) {
+	server, _ := fakeLyria(t, 300, "")
+	client := &Client{Endpoint: wsURL(server), APIKey: "wrong"}
+	_, err := client.Generate(context.Background(), Request{Prompts: []WeightedPrompt{{Text: "jazz", Weight: 1}}, Duration: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "HTTP 401") {
+		t.Errorf("Generate error = %v, want HTTP 401", err)
+	}
+}
+
+func TestParseAudioMIME(t *testing.T) {
+	tests := map[string][2]int{
+		"audio/l16;rate=48000;channels=2": {48000, 2},
+		"audio/pcm; rate=24000":           {24000, 2},
+		"audio/l16":                       {48000, 2},
+		"audio/l16;channels=1;rate=x":     {48000, 1},
+	}
+	for mimeType, want := range tests {
+		if rate, channels := parseAudioMIME(mimeType); rate != want[0] || channels != want[1] {
+			t.Errorf("parseAudioMIME(%q) = %d, %d, want %v", mimeType, rate, channels, want)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package poller waits for long-running Veo operations from a single
+// scheduler loop. Instead of every tool call sleeping and polling on its own,
+// callers register their operation and the loop checks all operations that
+// are due in one round, with bounded parallelism, so the number of status
+// requests in flight is capped and the backlog can be observed with Stats.
+package poller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// checkTimeout bounds one status check, so a hung request cannot hold up
+// the round and with it every other pending operation
+const checkTimeout = 30 * time.Second
+
+// CheckFunc fetches the current state of an operation
+type CheckFunc func(ctx context.Context, operation *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error)
+
+// Stats describes the poller's backlog
+type Stats struct {
+	Pending   int           // Operations being waited for
+	Rounds    int           // Polling rounds run so far
+	LastBatch int           // Operations checked in the last round
+	LastRound time.Duration // How long the last round took
+	LastLag   time.Duration // How far behind schedule the most overdue check of the last round ran
+}
+
+// Poller waits for operations on behalf of its callers
+type Poller struct {
+	check       CheckFunc
+	interval    time.Duration
+	attempts    int
+	concurrency int
+	timeout     time.Duration // Of one status check
+
+	mu      sync.Mutex
+	waiters map[*waiter]struct{}
+	running bool
+	stats   Stats
+}
+
+// waiter is an operation registered by a caller of Wait
+type waiter struct {
+	ctx       context.Context
+	label     string
+	operation *genai.GenerateVideosOperation
+	attempts  int
+	next      time.Time // When the operation is due to be checked
+	done      chan struct{}
+}
+
+// New creates a poller that checks each operation every interval, at most
+// attempts times, running up to concurrency checks at once
+func New(check CheckFunc, interval time.Duration, attempts, concurrency int) *Poller {
+	return &Poller{
+		check:       check,
+		interval:    interval,
+		attempts:    attempts,
+		concurrency: max(concurrency, 1),
+		timeout:     checkTimeout,
+		waiters:     make(map[*waiter]struct{}),
+	}
+}
+
+// Wait blocks until operation is done or has been checked the configured
+// number of times, and returns its last known state. It returns early with
+// the context's error when ctx is done; a failed status check stops waiting
+// and returns the last known state, while one that times out is retried in
+// a later round. Checks run with ctx, so they use the values it carries,
+// such as a bound API key.
+func (p *Poller) Wait(ctx context.Context, operation *genai.GenerateVideosOperation, label string) (*genai.GenerateVideosOperation, error) {
+	if operation.Done || p.attempts <= 0 {
+		return operation, nil
+	}
+	w := &waiter{
+		ctx:       ctx,
+		label:     label,
+		operation: operation,
+		next:      time.Now().Add(p.interval),
+		done:      make(chan struct{}),
+	}
+
+	p.mu.Lock()
+	p.waiters[w] = struct{}{}
+	p.stats.Pending = len(p.waiters)
+	if !p.running {
+		p.running = true
+		go p.loop()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-w.done:
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		return w.operation, nil
+	case <-ctx.Done():
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.remove(w)
+		log.Printf("Stopped waiting for %s operation %s: %v", label, w.operation.Name, ctx.Err())
+		return w.operation, ctx.Err()
+	}
+}
+
+// Stats returns the current backlog and the figures of the last round
+func (p *Poller) Stats() Stats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stats
+}
+
+// loop runs polling rounds until no operation is left to wait for
+func (p *Poller) loop() {
+	for {
+		p.mu.Lock()
+		if len(p.waiters) == 0 {
+			p.running = false
+			p.mu.Unlock()
+			return
+		}
+		var earliest time.Time
+		for w := range p.waiters {
+			if earliest.IsZero() || w.next.Before(earliest) {
+				earliest = w.next
+			}
+		}
+		p.mu.Unlock()
+
+		// Waiters registered meanwhile are due an interval from now, never
+		// before the earliest known one
+		time.Sleep(time.Until(earliest))
+		p.round()
+	}
+}
+
+// round checks every operation that is due, or will be within half an
+// interval, so operations started close together share a round
+func (p *Poller) round() {
+	start := time.Now()
+	var due []*waiter
+	var lag time.Duration
+
+	p.mu.Lock()
+	for w := range p.waiters {
+		if w.next.Before(start.Add(p.interval / 2)) {
+			due = append(due, w)
+			lag = max(lag, start.Sub(w.next))
+		}
+	}
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, p.concurrency)
+	for _, w := range due {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			p.checkOne(w)
+		}()
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.stats.Rounds++
+	p.stats.LastBatch = len(due)
+	p.stats.LastRound = time.Since(start)
+	p.stats.LastLag = lag
+	p.stats.Pending = len(p.waiters)
+	pending := p.stats.Pending
+	p.mu.Unlock()
+	log.Printf("Checked %d video operation(s) in %v (%d still pending, %v behind schedule)", len(due), time.Since(start).Round(time.Millisecond), pending, lag.Round(time.Millisecond))
+}
+
+// checkOne checks one operation and finishes its wait when it is done, out
+// of attempts or its status could not be fetched. A check that times out is
+// retried in a later round, as long as attempts remain.
+func (p *Poller) checkOne(w *waiter) {
+	p.mu.Lock()
+	operation := w.operation
+	w.attempts++
+	attempt := w.attempts
+	p.mu.Unlock()
+
+	log.Printf("Waiting for %s to complete... (attempt %d/%d)", w.label, attempt, p.attempts)
+	ctx, cancel := context.WithTimeout(w.ctx, p.timeout)
+	updated, err := p.check(ctx, operation)
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded) && w.ctx.Err() == nil
+	cancel()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.waiters[w]; !ok {
+		return // The caller stopped waiting
+	}
+	switch {
+	case timedOut:
+		log.Printf("Checking %s operation %s timed out after %v; retrying", w.label, operation.Name, p.timeout)
+		updated = operation
+	case err != nil:
+		if w.ctx.Err() == nil {
+			log.Printf("Error checking operation status: %v", err)
+			p.finish(w)
+		}
+		return // A cancelled caller removes itself
+	}
+	w.operation = updated
+	if updated.Done || attempt >= p.attempts {
+		p.finish(w)
+		return
+	}
+	w.next = w.next.Add(p.interval)
+	if now := time.Now(); w.next.Before(now) {
+		w.next = now
+	}
+}
+
+// finish ends a wait with the operation's current state. p.mu must be held.
+func (p *Poller) finish(w *waiter) {
+	p.remove(w)
+	close(w.done)
+}
+
+// remove forgets a waiter. p.mu must be held.
+func (p *Poller) remove(w *waiter) {
+	delete(p.waiters, w)
+	p.stats.Pending = len(p.waiters)
+}
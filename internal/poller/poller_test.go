@@ -0,0 +1,129 @@
+package poller
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestWaitBatchesOperations(t *testing.T) {
+	var mu sync.Mutex
+	checks := map[string]int{}
+	var inFlight, maxInFlight atomic.Int32
+	p := New(func(ctx context.Context, op *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+		checks[op.Name]++
+		return &genai.GenerateVideosOperation{Name: op.Name, Done: checks[op.Name] == 2}, nil
+	}, 20*time.Millisecond, 10, 2)
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"a", "b", "c", "d"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op, err := p.Wait(context.Background(), &genai.GenerateVideosOperation{Name: name}, "test")
+			if err != nil || !op.Done {
+				t.Errorf("Wait(%s) = %+v, %v; want a done operation", name, op, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("%d checks ran at once, want at most 2", got)
+	}
+	stats := p.Stats()
+	if stats.Pending != 0 {
+		t.Errorf("Pending = %d after all waits returned", stats.Pending)
+	}
+	// Operations registered together are checked in shared rounds
+	if stats.Rounds > 4 {
+		t.Errorf("Rounds = %d, want the operations batched", stats.Rounds)
+	}
+}
+
+func TestWaitStopsAfterAttempts(t *testing.T) {
+	var checks atomic.Int32
+	p := New(func(ctx context.Context, op *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		checks.Add(1)
+		return op, nil
+	}, time.Millisecond, 3, 1)
+
+	op, err := p.Wait(context.Background(), &genai.GenerateVideosOperation{Name: "slow"}, "test")
+	if err != nil || op.Done {
+		t.Errorf("Wait = %+v, %v; want the unfinished operation", op, err)
+	}
+	if checks.Load() != 3 {
+		t.Errorf("checked %d times, want 3", checks.Load())
+	}
+}
+
+func TestWaitCheckError(t *testing.T) {
+	p := New(func(ctx context.Context, op *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		return nil, errors.New("unavailable")
+	}, time.Millisecond, 3, 1)
+
+	op, err := p.Wait(context.Background(), &genai.GenerateVideosOperation{Name: "broken"}, "test")
+	if err != nil || op == nil || op.Name != "broken" {
+		t.Errorf("Wait = %+v, %v; want the last known operation", op, err)
+	}
+}
+
+func TestWaitCheckTimeout(t *testing.T) {
+	var checks atomic.Int32
+	p := New(func(ctx context.Context, op *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		if op.Name == "hung" && checks.Add(1) == 1 {
+			<-ctx.Done() // The first check never answers
+			return nil, ctx.Err()
+		}
+		return &genai.GenerateVideosOperation{Name: op.Name, Done: true}, nil
+	}, time.Millisecond, 3, 2)
+	p.timeout = 20 * time.Millisecond
+
+	var wg sync.WaitGroup
+	for _, name := range []string{"hung", "healthy"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op, err := p.Wait(context.Background(), &genai.GenerateVideosOperation{Name: name}, "test")
+			if err != nil || !op.Done {
+				t.Errorf("Wait(%s) = %+v, %v; want the operation done after a retry", name, op, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if checks.Load() != 2 {
+		t.Errorf("hung operation checked %d times, want 2", checks.Load())
+	}
+}
+
+func TestWaitCancelled(t *testing.T) {
+	p := New(func(ctx context.Context, op *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		return op, nil
+	}, time.Hour, 3, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Wait(ctx, &genai.GenerateVideosOperation{Name: "cancelled"}, "test"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait error = %v, want the context's error", err)
+	}
+	if pending := p.Stats().Pending; pending != 0 {
+		t.Errorf("Pending = %d after the caller stopped waiting", pending)
+	}
+}
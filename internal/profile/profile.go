@@ -0,0 +1,141 @@
+// Package profile reads a JSON config file with named deployment profiles
+// (e.g. dev, staging, prod). Settings are keyed by the environment variable
+// they stand for, so a profile can set anything the environment can, and
+// variables that are already set always win over the file.
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is a config file. Defaults apply to every profile; a profile's own
+// settings override them.
+type File struct {
+	Defaults map[string]json.RawMessage            `json:"defaults"`
+	Profiles map[string]map[string]json.RawMessage `json:"profiles"`
+}
+
+// settingName matches the environment variable names settings may use
+var settingName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+// reserved settings select the file and profile and cannot be set by them
+var reserved = map[string]bool{"CONFIG_FILE": true, "CONFIG_PROFILE": true}
+
+// Load reads and parses a config file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var f File
+	if err := dec.Decode(&f); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &f, nil
+}
+
+// Names returns the sorted names of the profiles in the file
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Settings returns the defaults merged with the settings of the named
+// profile, as environment variable values. An empty name returns only the
+// defaults.
+func (f *File) Settings(name string) (map[string]string, error) {
+	settings := make(map[string]string)
+	if err := merge(settings, f.Defaults); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	if name == "" {
+		return settings, nil
+	}
+	p, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(f.Names(), ", "))
+	}
+	if err := merge(settings, p); err != nil {
+		return nil, fmt.Errorf("profile %s: %w", name, err)
+	}
+	return settings, nil
+}
+
+func merge(settings map[string]string, values map[string]json.RawMessage) error {
+	for key, raw := range values {
+		if !settingName.MatchString(key) || reserved[key] {
+			return fmt.Errorf("invalid setting name %q (use the environment variable name, e.g. OUTPUT_DIR)", key)
+		}
+		value, err := settingValue(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		settings[key] = value
+	}
+	return nil
+}
+
+// settingValue converts a JSON string, number, boolean or list of those to
+// the form its environment variable takes; lists are joined with commas
+func settingValue(raw json.RawMessage) (string, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+	if list, ok := v.([]any); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			s, err := scalar(item)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return scalar(v)
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("value must be a string, number, boolean or list of those")
+}
+
+// Apply sets the environment variables of settings that are not already set
+// (or set to an empty value) and returns their sorted names
+func Apply(settings map[string]string) ([]string, error) {
+	var applied []string
+	for key, value := range settings {
+		if os.Getenv(key) != "" {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return nil, err
+		}
+		applied = append(applied, key)
+	}
+	sort.Strings(applied)
+	return applied, nil
+}
@@ -0,0 +1,101 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSettings(t *testing.T) {
+	f, err := Load(writeFile(t, `{
+		"defaults": {"TEXT_DEFAULT_MODEL": "gemini-2.5-flash", "MAX_CONCURRENT_GENERATIONS": 8},
+		"profiles": {
+			"dev": {"CHAOS_ENABLED": true},
+			"prod": {"MAX_CONCURRENT_GENERATIONS": 4, "MODEL_ALLOWLIST": ["gemini-2.5-*", "veo-3.1-*"]}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if names := f.Names(); !reflect.DeepEqual(names, []string{"dev", "prod"}) {
+		t.Errorf("Names = %v", names)
+	}
+
+	got, err := f.Settings("prod")
+	if err != nil {
+		t.Fatalf("Settings: %v", err)
+	}
+	want := map[string]string{
+		"TEXT_DEFAULT_MODEL":         "gemini-2.5-flash",
+		"MAX_CONCURRENT_GENERATIONS": "4",
+		"MODEL_ALLOWLIST":            "gemini-2.5-*,veo-3.1-*",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Settings(prod) = %v, want %v", got, want)
+	}
+	if got, _ := f.Settings("dev"); got["CHAOS_ENABLED"] != "true" || got["MAX_CONCURRENT_GENERATIONS"] != "8" {
+		t.Errorf("Settings(dev) = %v", got)
+	}
+	if got, _ := f.Settings(""); len(got) != 2 {
+		t.Errorf("Settings without a profile = %v, want the defaults", got)
+	}
+	if _, err := f.Settings("staging"); err == nil || !strings.Contains(err.Error(), "dev, prod") {
+		t.Errorf("unknown profile error = %v", err)
+	}
+}
+
+func TestSettingsErrors(t *testing.T) {
+	for _, content := range []string{
+		`{"profiles": {"dev": {"output_dir": "/tmp"}}}`,
+		`{"profiles": {"dev": {"CONFIG_PROFILE": "prod"}}}`,
+		`{"profiles": {"dev": {"OUTPUT_DIR": {"path": "/tmp"}}}}`,
+		`{"profiles": {"dev": {"OUTPUT_DIR": null}}}`,
+	} {
+		f, err := Load(writeFile(t, content))
+		if err != nil {
+			t.Fatalf("Load(%s): %v", content, err)
+		}
+		if _, err := f.Settings("dev"); err == nil {
+			t.Errorf("Settings accepted %s", content)
+		}
+	}
+	if _, err := Load(writeFile(t, `{"profile": {}}`)); err == nil {
+		t.Error("Load accepted an unknown field")
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Setenv("PROFILE_TEST_SET", "from-env")
+	t.Setenv("PROFILE_TEST_EMPTY", "")
+	t.Setenv("PROFILE_TEST_UNSET", "")
+	os.Unsetenv("PROFILE_TEST_UNSET")
+
+	applied, err := Apply(map[string]string{
+		"PROFILE_TEST_SET":   "from-profile",
+		"PROFILE_TEST_EMPTY": "from-profile",
+		"PROFILE_TEST_UNSET": "from-profile",
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if want := []string{"PROFILE_TEST_EMPTY", "PROFILE_TEST_UNSET"}; !reflect.DeepEqual(applied, want) {
+		t.Errorf("Apply = %v, want %v", applied, want)
+	}
+	if v := os.Getenv("PROFILE_TEST_SET"); v != "from-env" {
+		t.Errorf("PROFILE_TEST_SET = %q, the environment must win", v)
+	}
+	if v := os.Getenv("PROFILE_TEST_UNSET"); v != "from-profile" {
+		t.Errorf("PROFILE_TEST_UNSET = %q", v)
+	}
+}
@@ -0,0 +1,323 @@
+// Package quota counts the images and videos each caller generates per day
+// and per month, so several clients can share one Google billing account
+// fairly. Counts are kept in a small JSON file, so they survive restarts.
+// Days and months are UTC calendar periods.
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// Kinds of generations counted
+const (
+	Images = "images"
+	Videos = "videos"
+)
+
+// Periods quotas are counted over
+const (
+	Day   = "day"
+	Month = "month"
+)
+
+var (
+	kinds   = []string{Images, Videos}
+	periods = []string{Day, Month}
+)
+
+// Limits are the most generations of each kind per period, e.g.
+// {"images": {"day": 200}, "videos": {"day": 20, "month": 400}}. Kinds and
+// periods without a limit are not limited.
+type Limits map[string]map[string]int
+
+// ParseLimits parses a comma-separated list of kind=limit/period entries,
+// e.g. "images=200/day,videos=20/day,videos=400/month". An empty string
+// gives no limits.
+func ParseLimits(s string) (Limits, error) {
+	limits := Limits{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		kind, rest, ok := strings.Cut(entry, "=")
+		count, period, ok2 := strings.Cut(rest, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || !ok2 || err != nil {
+			return nil, fmt.Errorf("invalid quota %q: expected kind=limit/period, e.g. images=200/day", entry)
+		}
+		limits.set(strings.TrimSpace(kind), strings.TrimSpace(period), n)
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (l Limits) set(kind, period string, n int) {
+	if l[kind] == nil {
+		l[kind] = make(map[string]int)
+	}
+	l[kind][period] = n
+}
+
+// Validate checks that the limits name known kinds and periods and are not
+// negative
+func (l Limits) Validate() error {
+	for kind, byPeriod := range l {
+		if !slices.Contains(kinds, kind) {
+			return fmt.Errorf("unknown quota kind %q: expected one of %s", kind, strings.Join(kinds, ", "))
+		}
+		for period, n := range byPeriod {
+			if !slices.Contains(periods, period) {
+				return fmt.Errorf("unknown quota period %q: expected one of %s", period, strings.Join(periods, ", "))
+			}
+			if n < 0 {
+				return fmt.Errorf("quota of %s per %s must not be negative", kind, period)
+			}
+		}
+	}
+	return nil
+}
+
+// Empty reports whether no limit is set
+func (l Limits) Empty() bool {
+	for _, byPeriod := range l {
+		if len(byPeriod) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Status is a caller's use of one quota
+type Status struct {
+	Kind      string    `json:"kind"`
+	Period    string    `json:"period"`
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// counter is the use of one kind in the current day and month
+type counter struct {
+	Day        string `json:"day"` // 2006-01-02
+	DayCount   int    `json:"day_count"`
+	Month      string `json:"month"` // 2006-01
+	MonthCount int    `json:"month_count"`
+}
+
+// roll starts new periods that began since the counter was last used
+func (c *counter) roll(now time.Time) {
+	if day := now.Format("2006-01-02"); c.Day != day {
+		c.Day, c.DayCount = day, 0
+	}
+	if month := now.Format("2006-01"); c.Month != month {
+		c.Month, c.MonthCount = month, 0
+	}
+}
+
+func (c *counter) count(period string) *int {
+	if period == Day {
+		return &c.DayCount
+	}
+	return &c.MonthCount
+}
+
+// Tracker counts the generations of each caller
+type Tracker struct {
+	path string
+	now  func() time.Time
+
+	mu       sync.Mutex
+	counters map[string]map[string]*counter // By caller and kind
+}
+
+// Open loads the counts saved at path. A missing file starts from zero, and
+// an empty path keeps counts in memory only.
+func Open(path string) (*Tracker, error) {
+	t := &Tracker{
+		path:     path,
+		now:      func() time.Time { return time.Now().UTC() },
+		counters: make(map[string]map[string]*counter),
+	}
+	if path == "" {
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read quota usage: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.counters); err != nil {
+		return nil, fmt.Errorf("invalid quota usage file %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Reserve counts one generation of kind for caller, or fails with
+// toolerr.QuotaExceeded if that would exceed one of limits. The returned
+// function gives the generation back, for calls that end before generating
+// anything.
+func (t *Tracker) Reserve(caller, kind string, limits Limits) (refund func(), err error) {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.counter(caller, kind, now)
+	for _, period := range periods {
+		limit, ok := limits[kind][period]
+		if ok && *c.count(period) >= limit {
+			return nil, toolerr.Errorf(toolerr.QuotaExceeded, "%s quota of %d %s per %s is used up; it resets at %s",
+				adjective(period), limit, kind, period, resetAt(period, now).Format(time.RFC3339))
+		}
+	}
+	c.DayCount++
+	c.MonthCount++
+	t.save()
+
+	day, month := c.Day, c.Month
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if c.Day == day && c.DayCount > 0 {
+				c.DayCount--
+			}
+			if c.Month == month && c.MonthCount > 0 {
+				c.MonthCount--
+			}
+			t.save()
+		})
+	}, nil
+}
+
+// Status returns caller's use of each of limits, by kind and period
+func (t *Tracker) Status(caller string, limits Limits) []Status {
+	now := t.now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var statuses []Status
+	for _, kind := range kinds {
+		c := counter{}
+		if saved := t.counters[caller][kind]; saved != nil {
+			c = *saved
+		}
+		c.roll(now)
+		for _, period := range periods {
+			limit, ok := limits[kind][period]
+			if !ok {
+				continue
+			}
+			used := *c.count(period)
+			statuses = append(statuses, Status{
+				Kind:      kind,
+				Period:    period,
+				Limit:     limit,
+				Used:      used,
+				Remaining: max(limit-used, 0),
+				ResetsAt:  resetAt(period, now),
+			})
+		}
+	}
+	return statuses
+}
+
+// counter returns the counter of caller and kind for the current periods.
+// The caller must hold t.mu.
+func (t *Tracker) counter(caller, kind string, now time.Time) *counter {
+	byKind := t.counters[caller]
+	if byKind == nil {
+		byKind = make(map[string]*counter)
+		t.counters[caller] = byKind
+	}
+	c := byKind[kind]
+	if c == nil {
+		c = &counter{}
+		byKind[kind] = c
+	}
+	c.roll(now)
+	return c
+}
+
+// save writes the counts to the usage file. Failures are logged, since
+// counting goes on in memory. The caller must hold t.mu.
+func (t *Tracker) save() {
+	if t.path == "" {
+		return
+	}
+	if err := t.write(); err != nil {
+		log.Printf("Warning: failed to save quota usage: %v", err)
+	}
+}
+
+func (t *Tracker) write() error {
+	data, err := json.MarshalIndent(t.counters, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(t.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(t.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), t.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Summary renders statuses as the remaining generations of each quota, e.g.
+// "images=150/day, videos=3/day"
+func Summary(statuses []Status) string {
+	parts := make([]string, len(statuses))
+	for i, status := range statuses {
+		parts[i] = fmt.Sprintf("%s=%d/%s", status.Kind, status.Remaining, status.Period)
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// resetAt returns when the period containing now ends
+func resetAt(period string, now time.Time) time.Time {
+	year, month, day := now.Date()
+	if period == Day {
+		return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(year, month+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+func adjective(period string) string {
+	if period == Day {
+		return "daily"
+	}
+	return "monthly"
+}
@@ -0,0 +1,110 @@
+package quota
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("images=200/day, videos=20/day,videos=400/month")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Limits{Images: {Day: 200}, Videos: {Day: 20, Month: 400}}
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("ParseLimits() = %v, want %v", limits, want)
+	}
+
+	if limits, err := ParseLimits(""); err != nil || !limits.Empty() {
+		t.Errorf("ParseLimits(\"\") = %v, %v, want no limits", limits, err)
+	}
+	for _, invalid := range []string{"images=200", "images/day", "images=x/day", "songs=5/day", "images=5/week", "images=-1/day"} {
+		if _, err := ParseLimits(invalid); err == nil {
+			t.Errorf("ParseLimits(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestReserve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tracker, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 31, 23, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+	limits := Limits{Images: {Day: 2, Month: 3}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tracker.Reserve("alice", Images, limits); err != nil {
+			t.Fatalf("reservation %d: %v", i+1, err)
+		}
+	}
+	_, err = tracker.Reserve("alice", Images, limits)
+	if code := toolerr.Classify(err).Code; code != toolerr.QuotaExceeded {
+		t.Fatalf("third reservation of the day: code %q (%v), want %q", code, err, toolerr.QuotaExceeded)
+	}
+	if _, err := tracker.Reserve("bob", Images, limits); err != nil {
+		t.Errorf("another caller was limited: %v", err)
+	}
+	if _, err := tracker.Reserve("alice", Videos, limits); err != nil {
+		t.Errorf("a kind without limits was limited: %v", err)
+	}
+
+	if got := Summary(tracker.Status("alice", limits)); got != "images=0/day, images=1/month" {
+		t.Errorf("Summary() = %q", got)
+	}
+
+	// A new month starts both periods over
+	now = now.Add(2 * time.Hour)
+	refund, err := tracker.Reserve("alice", Images, limits)
+	if err != nil {
+		t.Fatalf("reservation in a new month: %v", err)
+	}
+	refund()
+	refund()
+
+	// Counts and refunds survive a restart
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened.now = tracker.now
+	want := []Status{
+		{Kind: Images, Period: Day, Limit: 2, Used: 0, Remaining: 2, ResetsAt: time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC)},
+		{Kind: Images, Period: Month, Limit: 3, Used: 0, Remaining: 3, ResetsAt: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if got := reopened.Status("alice", limits); !reflect.DeepEqual(got, want) {
+		t.Errorf("Status() = %+v, want %+v", got, want)
+	}
+	if got := reopened.Status("bob", limits); got[0].Used != 0 || got[1].Remaining != 3 {
+		t.Errorf("Status() of a caller from the previous month = %+v, want none used", got)
+	}
+}
+
+func TestReserveMonthlyLimit(t *testing.T) {
+	tracker, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return day }
+	limits := Limits{Videos: {Month: 2}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := tracker.Reserve("alice", Videos, limits); err != nil {
+			t.Fatal(err)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	_, err = tracker.Reserve("alice", Videos, limits)
+	var classified *toolerr.Error
+	if !errors.As(err, &classified) || classified.Code != toolerr.QuotaExceeded {
+		t.Errorf("third video of the month: %v, want a quota error", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package redact hides prompts in results that list other callers' work, so
+// a team can browse shared assets and jobs without reading each other's
+// creative briefs.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Mode is how prompts are redacted
+type Mode string
+
+const (
+	Off  Mode = "off"  // Prompts are shown
+	Hide Mode = "hide" // Prompt fields are removed
+	Hash Mode = "hash" // Prompts are replaced by a short SHA-256 hash, so equal prompts can still be matched
+)
+
+// ParseMode parses a mode name; an empty name is Off
+func ParseMode(name string) (Mode, error) {
+	switch mode := Mode(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return Off, nil
+	case Off, Hide, Hash:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown redaction mode %q (use off, hide or hash)", name)
+	}
+}
+
+// IsPromptField reports whether a field holds a prompt: "prompt", "prompts"
+// or a name ending in "_prompt", such as original_prompt or negative_prompt
+func IsPromptField(name string) bool {
+	name = strings.ToLower(name)
+	return name == "prompt" || name == "prompts" || strings.HasSuffix(name, "_prompt")
+}
+
+// Text returns the redacted form of a prompt: empty for Hide and
+// "sha256:" followed by 16 hex digits for Hash
+func (m Mode) Text(prompt string) string {
+	switch m {
+	case Off:
+		return prompt
+	case Hash:
+		sum := sha256.Sum256([]byte(prompt))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	default:
+		return ""
+	}
+}
+
+// Fields returns a copy of v, a value decoded from JSON, with its prompt
+// fields redacted at any depth. v itself is not modified.
+func (m Mode) Fields(v any) any {
+	if m == Off {
+		return v
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			switch {
+			case !IsPromptField(key):
+				out[key] = m.Fields(value)
+			case m == Hash:
+				out[key] = m.values(value)
+			}
+		}
+		return out
+	case map[string]string:
+		out := make(map[string]string, len(v))
+		for key, value := range v {
+			switch {
+			case !IsPromptField(key):
+				out[key] = value
+			case m == Hash:
+				out[key] = m.Text(value)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = m.Fields(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// values hashes the strings of a prompt field, which holds a prompt or a
+// list of prompts
+func (m Mode) values(v any) any {
+	switch v := v.(type) {
+	case string:
+		return m.Text(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, value := range v {
+			out[i] = m.values(value)
+		}
+		return out
+	case map[string]any:
+		// A structured prompt keeps its shape, with every string hashed
+		out := make(map[string]any, len(v))
+		for key, value := range v {
+			out[key] = m.values(value)
+		}
+		return out
+	default:
+		return v
+	}
+}
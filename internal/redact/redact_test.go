@@ -0,0 +1,67 @@
+package redact
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFields(t *testing.T) {
+	output := map[string]any{
+		"status": "completed",
+		"metadata": map[string]any{
+			"original_prompt": "a red fox",
+			"negative_prompt": "blur",
+			"aspect_ratio":    "1:1",
+		},
+		"items":   []any{map[string]any{"prompt": "a blue fox", "object_key": "a.png"}},
+		"prompts": []any{"one", "two"},
+	}
+
+	hidden := Hide.Fields(output)
+	want := map[string]any{
+		"status":   "completed",
+		"metadata": map[string]any{"aspect_ratio": "1:1"},
+		"items":    []any{map[string]any{"object_key": "a.png"}},
+	}
+	if !reflect.DeepEqual(hidden, want) {
+		t.Errorf("Hide.Fields = %v, want %v", hidden, want)
+	}
+
+	hashed := Hash.Fields(output).(map[string]any)
+	metadata := hashed["metadata"].(map[string]any)
+	if metadata["original_prompt"] != Hash.Text("a red fox") || metadata["aspect_ratio"] != "1:1" {
+		t.Errorf("hashed metadata = %v", metadata)
+	}
+	if prompts := hashed["prompts"].([]any); prompts[1] != Hash.Text("two") {
+		t.Errorf("hashed prompts = %v", prompts)
+	}
+
+	// The input is left as it was
+	if output["metadata"].(map[string]any)["original_prompt"] != "a red fox" {
+		t.Error("Fields modified its input")
+	}
+	if Off.Fields(output).(map[string]any)["prompts"] == nil {
+		t.Error("Off.Fields redacted a prompt")
+	}
+}
+
+func TestText(t *testing.T) {
+	hash := Hash.Text("a red fox")
+	if len(hash) != len("sha256:")+16 || hash != Hash.Text("a red fox") || hash == Hash.Text("a red cat") {
+		t.Errorf("Hash.Text = %q", hash)
+	}
+	if Hide.Text("a red fox") != "" || Off.Text("a red fox") != "a red fox" {
+		t.Error("unexpected Hide or Off text")
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for name, want := range map[string]Mode{"": Off, "off": Off, "HASH": Hash, " hide ": Hide} {
+		if mode, err := ParseMode(name); err != nil || mode != want {
+			t.Errorf("ParseMode(%q) = %q, %v", name, mode, err)
+		}
+	}
+	if _, err := ParseMode("blur"); err == nil {
+		t.Error("ParseMode accepted an unknown mode")
+	}
+}
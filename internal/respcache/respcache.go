@@ -0,0 +1,303 @@
+// Package respcache caches the results of generation calls keyed by a hash
+// of the tool, model, prompt and parameters, so identical repeated requests,
+// such as agent retries, return the files already stored instead of
+// generating them again. Entries are kept in an in-memory LRU and, when a
+// storage is given, as small JSON index objects next to the generated files,
+// so they survive restarts and are shared by replicas using the same bucket.
+// Results include the prompt, so with a fieldcrypt.Keyring they are
+// encrypted in the index objects.
+package respcache
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/fieldcrypt"
+	"gemini-mcp/internal/storage"
+)
+
+// indexPrefix is the key prefix of the index objects in storage
+const indexPrefix = "response_cache/"
+
+// Entry is a cached call result
+type Entry struct {
+	Result     json.RawMessage `json:"result"`      // The call's *mcp.CallToolResult (null if none)
+	Output     json.RawMessage `json:"output"`      // The call's structured output
+	ObjectKeys []string        `json:"object_keys"` // Stored files the result refers to
+	ExpiresAt  time.Time       `json:"expires_at"`
+}
+
+// record is the index object of an entry. With a keyring, the result and
+// output are left out and stored sealed instead.
+type record struct {
+	Entry
+	Sealed string `json:"sealed,omitempty"`
+}
+
+// sealedFields are the parts of an entry sealed in its index object
+type sealedFields struct {
+	Result json.RawMessage `json:"result"`
+	Output json.RawMessage `json:"output"`
+}
+
+// Cache is an LRU of call results backed by index objects in storage
+type Cache struct {
+	store storage.Storage     // nil keeps entries in memory only
+	keys  *fieldcrypt.Keyring // Encrypts results in index objects; nil stores them in plaintext
+	max   int
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	order   *list.List // Most recently used first
+	entries map[string]*list.Element
+}
+
+type item struct {
+	key   string
+	entry Entry
+}
+
+// New creates a cache of up to maxEntries results in memory that are served
+// for at most ttl. With a nil store, entries are not persisted; otherwise
+// their results are encrypted in storage if keys is set.
+func New(store storage.Storage, maxEntries int, ttl time.Duration, keys *fieldcrypt.Keyring) *Cache {
+	return &Cache{
+		store:   store,
+		keys:    keys,
+		max:     maxEntries,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// TTL returns how long entries are served at most
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
+// Key hashes the tool and its parameters. input is encoded as JSON with the
+// fields named in ignore removed, so options that do not change the
+// generated files, such as bypass_cache, do not split the cache.
+func Key(tool, tenant, model string, input any, ignore ...string) (string, error) {
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode input: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return "", fmt.Errorf("input must encode as a JSON object: %w", err)
+	}
+	for _, name := range ignore {
+		delete(fields, name)
+	}
+	// Map keys are encoded in sorted order, so equal inputs hash equally
+	canonical, err := json.Marshal([]any{tool, tenant, model, fields})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode input: %w", err)
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get returns the entry stored under key. Expired entries and entries whose
+// files were deleted from storage are dropped and reported as misses.
+func (c *Cache) Get(ctx context.Context, key string) (Entry, bool) {
+	c.mu.Lock()
+	elem, ok := c.entries[key]
+	var entry Entry
+	if ok {
+		c.order.MoveToFront(elem)
+		entry = elem.Value.(*item).entry
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		var err error
+		if entry, ok, err = c.load(ctx, key); err != nil {
+			log.Printf("Warning: failed to read response cache entry %s: %v", key[:12], err)
+		}
+		if !ok {
+			return Entry{}, false
+		}
+	}
+
+	if !time.Now().Before(entry.ExpiresAt) || !c.filesExist(ctx, entry.ObjectKeys) {
+		c.Remove(ctx, key)
+		return Entry{}, false
+	}
+	c.add(key, entry)
+	return entry, true
+}
+
+// Put stores entry under key. Entries that already expired are ignored.
+// The index object is written to storage too; failing to write it only
+// keeps the entry in memory.
+func (c *Cache) Put(ctx context.Context, key string, entry Entry) error {
+	if entry.ExpiresAt.IsZero() || entry.ExpiresAt.After(time.Now().Add(c.ttl)) {
+		entry.ExpiresAt = time.Now().Add(c.ttl)
+	}
+	if !time.Now().Before(entry.ExpiresAt) {
+		return nil
+	}
+	c.add(key, entry)
+	return c.save(ctx, key, entry)
+}
+
+// Remove drops the entry stored under key from memory and storage. The
+// files it refers to are kept.
+func (c *Cache) Remove(ctx context.Context, key string) {
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+
+	if c.store != nil {
+		if err := c.store.Delete(ctx, indexKey(ctx, key)); err != nil {
+			log.Printf("Warning: failed to delete response cache entry %s: %v", key[:12], err)
+		}
+	}
+}
+
+// Len returns the number of entries in memory
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// add puts entry at the front of the LRU, evicting the least recently used
+// entries beyond the limit
+func (c *Cache) add(key string, entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*item).entry = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&item{key: key, entry: entry})
+	for c.order.Len() > c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*item).key)
+	}
+}
+
+// filesExist reports whether all keys are still in storage, since the files
+// may have been deleted with delete_media or by a bucket lifecycle rule
+func (c *Cache) filesExist(ctx context.Context, keys []string) bool {
+	if c.store == nil {
+		return true
+	}
+	for _, key := range keys {
+		objects, err := c.store.List(ctx, key)
+		if err != nil {
+			return false
+		}
+		found := false
+		for _, object := range objects {
+			found = found || object.ObjectKey == key
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// load reads the index object of key from storage
+func (c *Cache) load(ctx context.Context, key string) (Entry, bool, error) {
+	if c.store == nil {
+		return Entry{}, false, nil
+	}
+	path, cleanup, err := c.store.Retrieve(ctx, indexKey(ctx, key))
+	if errors.Is(err, storage.ErrNotFound) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	defer cleanup()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	var rec record
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return Entry{}, false, fmt.Errorf("invalid entry: %w", err)
+	}
+	if rec.Sealed == "" {
+		return rec.Entry, true, nil // Written without a keyring
+	}
+	if c.keys == nil {
+		return Entry{}, false, errors.New("entry is encrypted but no encryption key is configured")
+	}
+	plaintext, err := c.keys.Open(rec.Sealed, key)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	var fields sealedFields
+	if err := json.Unmarshal(plaintext, &fields); err != nil {
+		return Entry{}, false, fmt.Errorf("invalid entry: %w", err)
+	}
+	rec.Result, rec.Output = fields.Result, fields.Output
+	return rec.Entry, true, nil
+}
+
+// save writes the index object of key to storage under a stable key. The
+// immutable version copy that key hints also produce is not needed.
+func (c *Cache) save(ctx context.Context, key string, entry Entry) error {
+	if c.store == nil {
+		return nil
+	}
+	rec := record{Entry: entry}
+	if c.keys != nil {
+		plaintext, err := json.Marshal(sealedFields{Result: entry.Result, Output: entry.Output})
+		if err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+		// Binding the value to the cache key keeps it from being copied
+		// into another entry's index object
+		if rec.Sealed, err = c.keys.Seal(plaintext, key); err != nil {
+			return fmt.Errorf("failed to encrypt entry: %w", err)
+		}
+		rec.Result, rec.Output = nil, nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+	result, err := c.store.Store(storage.WithKeyHint(ctx, indexPrefix+key), data, "application/json", "response_cache")
+	if err != nil {
+		return fmt.Errorf("failed to store entry: %w", err)
+	}
+	if result.VersionKey != "" {
+		c.store.Delete(ctx, result.VersionKey)
+	}
+	return nil
+}
+
+// IsIndexKey reports whether objectKey is the key of an index object rather
+// than of a generated file
+func IsIndexKey(ctx context.Context, objectKey string) bool {
+	return strings.HasPrefix(objectKey, storage.TenantPrefix(ctx)+indexPrefix)
+}
+
+// indexKey returns the object key of key's index object for ctx's tenant
+func indexKey(ctx context.Context, key string) string {
+	return storage.TenantPrefix(ctx) + indexPrefix + key + storage.LatestSuffix + ".json"
+}
@@ -0,0 +1,179 @@
+package respcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-mcp/internal/fieldcrypt"
+	"gemini-mcp/internal/storage"
+)
+
+func TestKey(t *testing.T) {
+	type input struct {
+		Prompt      string `json:"prompt"`
+		Style       string `json:"style,omitempty"`
+		BypassCache bool   `json:"bypass_cache,omitempty"`
+	}
+	key := func(tool, tenant, model string, in input) string {
+		k, err := Key(tool, tenant, model, in, "bypass_cache")
+		if err != nil {
+			t.Fatalf("Key: %v", err)
+		}
+		return k
+	}
+
+	base := key("gemini_image_generation", "", "m", input{Prompt: "a cat"})
+	if got := key("gemini_image_generation", "", "m", input{Prompt: "a cat", BypassCache: true}); got != base {
+		t.Error("bypass_cache changed the key")
+	}
+	for name, other := range map[string]string{
+		"prompt": key("gemini_image_generation", "", "m", input{Prompt: "a dog"}),
+		"param":  key("gemini_image_generation", "", "m", input{Prompt: "a cat", Style: "sketch"}),
+		"tool":   key("veo_text_to_video", "", "m", input{Prompt: "a cat"}),
+		"tenant": key("gemini_image_generation", "acme", "m", input{Prompt: "a cat"}),
+		"model":  key("gemini_image_generation", "", "n", input{Prompt: "a cat"}),
+	} {
+		if other == base {
+			t.Errorf("changing the %s kept the key", name)
+		}
+	}
+}
+
+func storeFile(t *testing.T, s storage.Storage, content string) string {
+	t.Helper()
+	result, err := s.Store(context.Background(), []byte(content), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	return result.ObjectKey
+}
+
+func TestGetPut(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := New(s, 10, time.Hour, nil)
+	file := storeFile(t, s, "image")
+	entry := Entry{Output: json.RawMessage(`{"status":"completed"}`), ObjectKeys: []string{file}}
+
+	if _, ok := c.Get(ctx, "k1"); ok {
+		t.Fatal("Get of an empty cache hit")
+	}
+	if err := c.Put(ctx, "k1", entry); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	got, ok := c.Get(ctx, "k1")
+	if !ok || string(got.Output) != `{"status":"completed"}` {
+		t.Fatalf("Get = %+v, %v", got, ok)
+	}
+	if got.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("ExpiresAt %v is beyond the TTL", got.ExpiresAt)
+	}
+
+	// A new cache on the same storage finds the persisted entry
+	restarted := New(s, 10, time.Hour, nil)
+	if _, ok := restarted.Get(ctx, "k1"); !ok {
+		t.Fatal("entry was not persisted to storage")
+	}
+	objects, _ := s.List(ctx, indexPrefix)
+	if len(objects) != 1 {
+		t.Errorf("storage holds %d index objects, want 1", len(objects))
+	}
+
+	// Deleting the file invalidates the entry in memory and storage
+	if err := s.Delete(ctx, file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Get(ctx, "k1"); ok {
+		t.Error("Get hit an entry whose file was deleted")
+	}
+	if _, ok := New(s, 10, time.Hour, nil).Get(ctx, "k1"); ok {
+		t.Error("index object of an invalid entry was kept")
+	}
+}
+
+func TestSealedEntries(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := storage.NewLocalStorage(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := fieldcrypt.ParseKeyring(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.Repeat("ab", 32)
+	file := storeFile(t, s, "image")
+	output := `{"metadata":{"original_prompt":"a secret cat"}}`
+	if err := New(s, 10, time.Hour, keys).Put(ctx, key, Entry{Output: json.RawMessage(output), ObjectKeys: []string{file}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, indexKey(ctx, key)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), "secret cat") {
+		t.Errorf("index object holds the prompt in plaintext: %s", index)
+	}
+	got, ok := New(s, 10, time.Hour, keys).Get(ctx, key)
+	if !ok || string(got.Output) != output {
+		t.Errorf("Get with the keyring = %+v, %v; want the decrypted output", got, ok)
+	}
+	if _, ok := New(s, 10, time.Hour, nil).Get(ctx, key); ok {
+		t.Error("Get without the keyring hit an encrypted entry")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	ctx := context.Background()
+	c := New(nil, 10, time.Hour, nil)
+	if err := c.Put(ctx, "expired", Entry{ExpiresAt: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Len() != 0 {
+		t.Error("an expired entry was cached")
+	}
+
+	c.Put(ctx, "short", Entry{ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	if _, ok := c.Get(ctx, "short"); !ok {
+		t.Fatal("Get missed a fresh entry")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := c.Get(ctx, "short"); ok {
+		t.Error("Get hit an expired entry")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	ctx := context.Background()
+	c := New(nil, 3, time.Hour, nil)
+	for i := range 3 {
+		c.Put(ctx, fmt.Sprintf("k%d", i), Entry{})
+	}
+	c.Get(ctx, "k0") // k1 is now the least recently used
+	c.Put(ctx, "k3", Entry{})
+
+	if c.Len() != 3 {
+		t.Errorf("Len = %d, want 3", c.Len())
+	}
+	if _, ok := c.Get(ctx, "k1"); ok {
+		t.Error("least recently used entry was kept")
+	}
+	for _, key := range []string{"k0", "k2", "k3"} {
+		if _, ok := c.Get(ctx, key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
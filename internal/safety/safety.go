@@ -0,0 +1,159 @@
+package safety
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Feedback explains why Gemini, Imagen or Veo blocked or filtered a generation,
+// so that callers can adjust the prompt instead of retrying it unchanged
+type Feedback struct {
+	Blocked           bool     `json:"blocked"`
+	BlockReason       string   `json:"block_reason,omitempty"`
+	FinishReason      string   `json:"finish_reason,omitempty"`
+	BlockedCategories []string `json:"blocked_categories,omitempty"`
+	Ratings           []Rating `json:"ratings,omitempty"`
+	FilteredReasons   []string `json:"filtered_reasons,omitempty"`
+	Message           string   `json:"message,omitempty"`
+}
+
+// Rating is the assessed harm probability of one category
+type Rating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability,omitempty"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// blockingFinishReasons are the finish reasons that mean output was withheld
+var blockingFinishReasons = map[genai.FinishReason]bool{
+	genai.FinishReasonSafety:                 true,
+	genai.FinishReasonRecitation:             true,
+	genai.FinishReasonBlocklist:              true,
+	genai.FinishReasonProhibitedContent:      true,
+	genai.FinishReasonSPII:                   true,
+	genai.FinishReasonImageSafety:            true,
+	genai.FinishReasonImageProhibitedContent: true,
+	genai.FinishReasonImageRecitation:        true,
+}
+
+// FromContentResponse extracts safety feedback from a GenerateContent response.
+// It returns nil when the response carries no block and no safety ratings.
+func FromContentResponse(resp *genai.GenerateContentResponse) *Feedback {
+	if resp == nil {
+		return nil
+	}
+	f := &Feedback{}
+	categories := map[string]bool{}
+
+	if pf := resp.PromptFeedback; pf != nil {
+		if pf.BlockReason != "" && pf.BlockReason != genai.BlockedReasonUnspecified {
+			f.Blocked = true
+			f.BlockReason = string(pf.BlockReason)
+			f.Message = pf.BlockReasonMessage
+		}
+		f.addRatings(pf.SafetyRatings, categories)
+	}
+
+	for _, c := range resp.Candidates {
+		if c == nil {
+			continue
+		}
+		if c.FinishReason != "" && c.FinishReason != genai.FinishReasonStop && f.FinishReason == "" {
+			f.FinishReason = string(c.FinishReason)
+			if f.Message == "" {
+				f.Message = c.FinishMessage
+			}
+		}
+		if blockingFinishReasons[c.FinishReason] {
+			f.Blocked = true
+		}
+		f.addRatings(c.SafetyRatings, categories)
+	}
+
+	for category := range categories {
+		f.BlockedCategories = append(f.BlockedCategories, category)
+	}
+	sort.Strings(f.BlockedCategories)
+
+	if !f.Blocked && f.FinishReason == "" && len(f.Ratings) == 0 {
+		return nil
+	}
+	return f
+}
+
+func (f *Feedback) addRatings(ratings []*genai.SafetyRating, blocked map[string]bool) {
+	for _, r := range ratings {
+		if r == nil {
+			continue
+		}
+		f.Ratings = append(f.Ratings, Rating{
+			Category:    string(r.Category),
+			Probability: string(r.Probability),
+			Blocked:     r.Blocked,
+		})
+		if r.Blocked {
+			blocked[string(r.Category)] = true
+		}
+	}
+}
+
+// FromImagesResponse extracts the responsible-AI filter reasons from an Imagen
+// response. It returns nil when no image was filtered.
+func FromImagesResponse(resp *genai.GenerateImagesResponse) *Feedback {
+	if resp == nil {
+		return nil
+	}
+	var reasons []string
+	for _, img := range resp.GeneratedImages {
+		if img != nil && img.RAIFilteredReason != "" {
+			reasons = append(reasons, img.RAIFilteredReason)
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return &Feedback{Blocked: true, FinishReason: "RAI_FILTERED", FilteredReasons: reasons}
+}
+
+// FromVideosResponse extracts the responsible-AI filter reasons from a Veo
+// response. It returns nil when no video was filtered.
+func FromVideosResponse(resp *genai.GenerateVideosResponse) *Feedback {
+	if resp == nil || (resp.RAIMediaFilteredCount == 0 && len(resp.RAIMediaFilteredReasons) == 0) {
+		return nil
+	}
+	return &Feedback{
+		Blocked:         len(resp.GeneratedVideos) == 0,
+		FinishReason:    "RAI_FILTERED",
+		FilteredReasons: resp.RAIMediaFilteredReasons,
+		Message:         fmt.Sprintf("%d generated video(s) were filtered", resp.RAIMediaFilteredCount),
+	}
+}
+
+// Summary describes the feedback in one sentence for tool result text
+func (f *Feedback) Summary() string {
+	var details []string
+	if f.BlockReason != "" {
+		details = append(details, "prompt blocked: "+f.BlockReason)
+	}
+	if f.FinishReason != "" {
+		details = append(details, "finish reason: "+f.FinishReason)
+	}
+	if len(f.BlockedCategories) > 0 {
+		details = append(details, "categories: "+strings.Join(f.BlockedCategories, ", "))
+	}
+	if len(f.FilteredReasons) > 0 {
+		details = append(details, "filter reasons: "+strings.Join(f.FilteredReasons, "; "))
+	}
+	if f.Message != "" {
+		details = append(details, f.Message)
+	}
+
+	summary := "Generation was blocked by safety filters"
+	if len(details) > 0 {
+		summary += " (" + strings.Join(details, "; ") + ")"
+	}
+	return summary + ". Rephrase the prompt to avoid the flagged content rather than retrying it unchanged."
+}
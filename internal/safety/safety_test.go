@@ -0,0 +1,59 @@
+package safety
+
+import (
+	"strings"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestFromContentResponse(t *testing.T) {
+	if f := FromContentResponse(&genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{FinishReason: genai.FinishReasonStop}},
+	}); f != nil {
+		t.Errorf("clean response: got %+v, want nil", f)
+	}
+
+	f := FromContentResponse(&genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{{
+			FinishReason: genai.FinishReasonImageSafety,
+			SafetyRatings: []*genai.SafetyRating{
+				{Category: genai.HarmCategoryDangerousContent, Probability: genai.HarmProbabilityHigh, Blocked: true},
+				{Category: genai.HarmCategoryHarassment, Probability: genai.HarmProbabilityNegligible},
+			},
+		}},
+	})
+	if f == nil || !f.Blocked {
+		t.Fatalf("blocked candidate: got %+v, want blocked feedback", f)
+	}
+	if f.FinishReason != "IMAGE_SAFETY" {
+		t.Errorf("FinishReason = %q, want IMAGE_SAFETY", f.FinishReason)
+	}
+	if len(f.Ratings) != 2 || len(f.BlockedCategories) != 1 || f.BlockedCategories[0] != string(genai.HarmCategoryDangerousContent) {
+		t.Errorf("ratings = %+v, blocked categories = %v", f.Ratings, f.BlockedCategories)
+	}
+	if !strings.Contains(f.Summary(), "IMAGE_SAFETY") {
+		t.Errorf("Summary() = %q, want the finish reason", f.Summary())
+	}
+
+	f = FromContentResponse(&genai.GenerateContentResponse{
+		PromptFeedback: &genai.GenerateContentResponsePromptFeedback{BlockReason: genai.BlockedReasonProhibitedContent},
+	})
+	if f == nil || !f.Blocked || f.BlockReason != "PROHIBITED_CONTENT" {
+		t.Errorf("blocked prompt: got %+v", f)
+	}
+}
+
+func TestFromVideosResponse(t *testing.T) {
+	if f := FromVideosResponse(&genai.GenerateVideosResponse{GeneratedVideos: []*genai.GeneratedVideo{{}}}); f != nil {
+		t.Errorf("unfiltered response: got %+v, want nil", f)
+	}
+
+	f := FromVideosResponse(&genai.GenerateVideosResponse{
+		RAIMediaFilteredCount:   1,
+		RAIMediaFilteredReasons: []string{"The prompt contains a celebrity likeness."},
+	})
+	if f == nil || !f.Blocked || len(f.FilteredReasons) != 1 {
+		t.Errorf("filtered response: got %+v", f)
+	}
+}
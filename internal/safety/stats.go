@@ -0,0 +1,209 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats counts tool calls and the safety blocks among them per tool, caller,
+// category and reason, so operators can tune safety defaults and spot callers
+// whose prompts are blocked unusually often
+type Stats struct {
+	mu         sync.Mutex
+	since      time.Time
+	tools      map[string]*usage
+	callers    map[string]*usage
+	categories map[string]int
+	reasons    map[string]int
+}
+
+type usage struct {
+	calls   int
+	blocked int
+}
+
+// NewStats creates empty statistics
+func NewStats() *Stats {
+	return &Stats{
+		since:      time.Now(),
+		tools:      make(map[string]*usage),
+		callers:    make(map[string]*usage),
+		categories: make(map[string]int),
+		reasons:    make(map[string]int),
+	}
+}
+
+// Call is one counted tool call. Blocks recorded for it count toward its
+// tool's and caller's blocked calls once, however many generations of the
+// call were blocked.
+type Call struct {
+	stats   *Stats
+	tool    string
+	caller  string
+	blocked bool
+}
+
+// Start counts a call of tool by caller
+func (s *Stats) Start(tool, caller string) *Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entry(s.tools, tool).calls++
+	s.entry(s.callers, caller).calls++
+	return &Call{stats: s, tool: tool, caller: caller}
+}
+
+func (s *Stats) entry(m map[string]*usage, name string) *usage {
+	u, ok := m[name]
+	if !ok {
+		u = &usage{}
+		m[name] = u
+	}
+	return u
+}
+
+// Block records a blocked generation of the call
+func (c *Call) Block(f *Feedback) {
+	s := c.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !c.blocked {
+		c.blocked = true
+		s.entry(s.tools, c.tool).blocked++
+		s.entry(s.callers, c.caller).blocked++
+	}
+	for _, category := range f.BlockedCategories {
+		s.categories[category]++
+	}
+	for _, reason := range f.reasons() {
+		s.reasons[reason]++
+	}
+}
+
+// reasons returns the block reason, finish reason and filter reasons of f
+func (f *Feedback) reasons() []string {
+	var reasons []string
+	for _, reason := range []string{f.BlockReason, f.FinishReason} {
+		if reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return append(reasons, f.FilteredReasons...)
+}
+
+type callKey struct{}
+
+// WithCall returns a context under which RecordBlock counts blocks for call
+func WithCall(ctx context.Context, call *Call) context.Context {
+	return context.WithValue(ctx, callKey{}, call)
+}
+
+// RecordBlock records a blocked generation for the call of ctx, if any
+func RecordBlock(ctx context.Context, f *Feedback) {
+	if call, ok := ctx.Value(callKey{}).(*Call); ok && f != nil {
+		call.Block(f)
+	}
+}
+
+// Report is a snapshot of the statistics
+type Report struct {
+	Since        string         `json:"since"`
+	Calls        int            `json:"calls"`
+	BlockedCalls int            `json:"blocked_calls"`
+	BlockRate    float64        `json:"block_rate"`
+	Tools        []Usage        `json:"tools"`
+	Callers      []Usage        `json:"callers"`
+	Categories   map[string]int `json:"categories"` // Blocks per harm category
+	Reasons      map[string]int `json:"reasons"`    // Blocks per block, finish or filter reason
+}
+
+// Usage is the call and block count of a tool or caller
+type Usage struct {
+	Name         string  `json:"name"`
+	Calls        int     `json:"calls"`
+	BlockedCalls int     `json:"blocked_calls"`
+	BlockRate    float64 `json:"block_rate"`
+}
+
+// Report returns a snapshot. Tools and callers are ordered by blocked calls,
+// then calls, most first.
+func (s *Stats) Report() Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	report := Report{
+		Since:      s.since.Format(time.RFC3339),
+		Tools:      usages(s.tools),
+		Callers:    usages(s.callers),
+		Categories: make(map[string]int, len(s.categories)),
+		Reasons:    make(map[string]int, len(s.reasons)),
+	}
+	for _, u := range report.Tools {
+		report.Calls += u.Calls
+		report.BlockedCalls += u.BlockedCalls
+	}
+	report.BlockRate = rate(report.BlockedCalls, report.Calls)
+	for category, n := range s.categories {
+		report.Categories[category] = n
+	}
+	for reason, n := range s.reasons {
+		report.Reasons[reason] = n
+	}
+	return report
+}
+
+func usages(m map[string]*usage) []Usage {
+	list := make([]Usage, 0, len(m))
+	for name, u := range m {
+		list = append(list, Usage{Name: name, Calls: u.calls, BlockedCalls: u.blocked, BlockRate: rate(u.blocked, u.calls)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].BlockedCalls != list[j].BlockedCalls {
+			return list[i].BlockedCalls > list[j].BlockedCalls
+		}
+		if list[i].Calls != list[j].Calls {
+			return list[i].Calls > list[j].Calls
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func rate(blocked, calls int) float64 {
+	if calls == 0 {
+		return 0
+	}
+	return float64(blocked) / float64(calls)
+}
+
+// WriteMetrics writes the report's per-tool, per-category and per-reason
+// counters in the Prometheus text format. Callers are left out to keep label
+// cardinality bounded.
+func (r Report) WriteMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP gemini_mcp_tool_calls_total Tool calls received.")
+	fmt.Fprintln(w, "# TYPE gemini_mcp_tool_calls_total counter")
+	for _, u := range r.Tools {
+		fmt.Fprintf(w, "gemini_mcp_tool_calls_total{tool=%q} %d\n", u.Name, u.Calls)
+	}
+	fmt.Fprintln(w, "# HELP gemini_mcp_safety_blocked_calls_total Tool calls with at least one generation blocked by safety filters.")
+	fmt.Fprintln(w, "# TYPE gemini_mcp_safety_blocked_calls_total counter")
+	for _, u := range r.Tools {
+		fmt.Fprintf(w, "gemini_mcp_safety_blocked_calls_total{tool=%q} %d\n", u.Name, u.BlockedCalls)
+	}
+	writeCounts(w, "gemini_mcp_safety_blocks_by_category_total", "Blocked generations per harm category.", "category", r.Categories)
+	writeCounts(w, "gemini_mcp_safety_blocks_by_reason_total", "Blocked generations per block, finish or filter reason.", "reason", r.Reasons)
+}
+
+func writeCounts(w io.Writer, name, help, label string, counts map[string]int) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, key, counts[key])
+	}
+}
@@ -0,0 +1,48 @@
+package safety
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	stats := NewStats()
+	stats.Start("gemini_image", "sub:alice")
+	blocked := stats.Start("gemini_image", "sub:mallory")
+	ctx := WithCall(context.Background(), blocked)
+	// A call blocked twice, e.g. a batch, counts as one blocked call
+	RecordBlock(ctx, &Feedback{Blocked: true, FinishReason: "IMAGE_SAFETY", BlockedCategories: []string{"HARM_CATEGORY_DANGEROUS_CONTENT"}})
+	RecordBlock(ctx, &Feedback{Blocked: true, BlockReason: "PROHIBITED_CONTENT"})
+	stats.Start("veo_text_to_video", "sub:alice")
+	RecordBlock(context.Background(), &Feedback{Blocked: true, BlockReason: "OTHER"})
+
+	report := stats.Report()
+	if report.Calls != 3 || report.BlockedCalls != 1 {
+		t.Fatalf("calls = %d, blocked = %d; want 3, 1", report.Calls, report.BlockedCalls)
+	}
+	if report.Tools[0].Name != "gemini_image" || report.Tools[0].BlockRate != 0.5 {
+		t.Errorf("top tool = %+v, want gemini_image at 0.5", report.Tools[0])
+	}
+	if report.Callers[0].Name != "sub:mallory" || report.Callers[0].BlockedCalls != 1 {
+		t.Errorf("top caller = %+v, want sub:mallory", report.Callers[0])
+	}
+	if report.Categories["HARM_CATEGORY_DANGEROUS_CONTENT"] != 1 || report.Reasons["IMAGE_SAFETY"] != 1 || report.Reasons["PROHIBITED_CONTENT"] != 1 || report.Reasons["OTHER"] != 0 {
+		t.Errorf("categories = %v, reasons = %v", report.Categories, report.Reasons)
+	}
+
+	var metrics strings.Builder
+	report.WriteMetrics(&metrics)
+	for _, line := range []string{
+		`gemini_mcp_tool_calls_total{tool="gemini_image"} 2`,
+		`gemini_mcp_safety_blocked_calls_total{tool="gemini_image"} 1`,
+		`gemini_mcp_safety_blocks_by_category_total{category="HARM_CATEGORY_DANGEROUS_CONTENT"} 1`,
+	} {
+		if !strings.Contains(metrics.String(), line+"\n") {
+			t.Errorf("metrics missing %q:\n%s", line, metrics.String())
+		}
+	}
+	if strings.Contains(metrics.String(), "mallory") {
+		t.Error("metrics expose callers")
+	}
+}
@@ -0,0 +1,51 @@
+// Package schedule works out when deferred jobs may start. Start times are
+// given as a wall-clock time of day in a time zone, so off-peak windows can
+// be named the way operators think of them ("after 22:00 Berlin time"), or
+// as an absolute RFC 3339 timestamp.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoadLocation returns the time zone named by an IANA name such as
+// "Europe/Berlin". An empty name, or "Local", is the server's time zone.
+func LoadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q (use an IANA name such as Europe/Berlin)", name)
+	}
+	return loc, nil
+}
+
+// NextRun returns when a job whose start is given by runAfter may start.
+// runAfter is either "HH:MM" (24-hour clock) in loc, meaning its next
+// occurrence after now (today if it is still ahead, otherwise tomorrow), or
+// an RFC 3339 timestamp. An empty runAfter starts at now.
+func NextRun(runAfter string, loc *time.Location, now time.Time) (time.Time, error) {
+	runAfter = strings.TrimSpace(runAfter)
+	if runAfter == "" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, runAfter); err == nil {
+		return t, nil
+	}
+
+	clock, err := time.Parse("15:04", runAfter)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid run_after %q (use HH:MM or an RFC 3339 timestamp)", runAfter)
+	}
+	local := now.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+	if !next.After(local) {
+		// time.Date normalizes the day, so this also crosses month ends; on
+		// DST changes the wall-clock time is kept
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, clock.Hour(), clock.Minute(), 0, 0, loc)
+	}
+	return next, nil
+}
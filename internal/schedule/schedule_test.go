@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRun(t *testing.T) {
+	berlin, err := LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	// 20:30 in Berlin (CEST, UTC+2)
+	now := time.Date(2026, 6, 30, 18, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		runAfter string
+		want     time.Time
+	}{
+		{"", now},
+		{"22:00", time.Date(2026, 6, 30, 20, 0, 0, 0, time.UTC)},
+		{"06:15", time.Date(2026, 7, 1, 4, 15, 0, 0, time.UTC)}, // Tomorrow, across the month end
+		{"20:30", time.Date(2026, 7, 1, 18, 30, 0, 0, time.UTC)},
+		{"2026-07-02T03:00:00Z", time.Date(2026, 7, 2, 3, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := NextRun(tt.runAfter, berlin, now)
+		if err != nil {
+			t.Errorf("NextRun(%q): %v", tt.runAfter, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("NextRun(%q) = %v, want %v", tt.runAfter, got.UTC(), tt.want)
+		}
+	}
+
+	for _, invalid := range []string{"25:00", "10pm", "tomorrow"} {
+		if _, err := NextRun(invalid, berlin, now); err == nil {
+			t.Errorf("NextRun(%q) accepted an invalid time", invalid)
+		}
+	}
+}
+
+func TestLoadLocation(t *testing.T) {
+	if loc, err := LoadLocation(""); err != nil || loc != time.Local {
+		t.Errorf("LoadLocation(\"\") = %v, %v", loc, err)
+	}
+	if _, err := LoadLocation("Mars/Olympus"); err == nil {
+		t.Error("LoadLocation accepted an unknown zone")
+	}
+}
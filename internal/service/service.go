@@ -0,0 +1,143 @@
+// Package service calls the server's tools for APIs other than MCP, such as
+// the gRPC API. Calls are served in process by the same HTTP handler as MCP
+// requests, so they share the tool implementations with the MCP layer along
+// with everything around them: authentication, tenants, priorities, quotas,
+// error classification and the drain on shutdown.
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonrpc"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ErrUnauthenticated and ErrPermissionDenied report callers the handler
+// refused (HTTP 401 and 403), ErrRateLimited callers over their rate limit
+// (HTTP 429) and ErrInvalidCall calls of unknown tools or with malformed
+// arguments
+var (
+	ErrUnauthenticated  = errors.New("missing or invalid credentials")
+	ErrPermissionDenied = errors.New("the credentials do not allow this call")
+	ErrRateLimited      = errors.New("rate limit exceeded")
+	ErrInvalidCall      = errors.New("invalid tool call")
+)
+
+// Tools calls the tools of an MCP streamable HTTP handler
+type Tools struct {
+	handler http.Handler
+	client  *mcp.Implementation
+}
+
+// New returns Tools calling the tools of handler, which serves MCP's
+// streamable HTTP transport behind the server's usual middleware. client
+// names the API in the MCP sessions it opens.
+func New(handler http.Handler, client *mcp.Implementation) *Tools {
+	return &Tools{handler: handler, client: client}
+}
+
+// Caller is who makes a call: the HTTP headers that authenticate and
+// describe it, such as Authorization and X-Priority, and its address. Host
+// and TLS are the host the caller reached and its TLS connection, if any;
+// tools build links back to the server, such as download URLs, from them.
+type Caller struct {
+	Header     http.Header
+	RemoteAddr string
+	Host       string
+	TLS        *tls.ConnectionState
+}
+
+// Progress is a progress notification of a tool call
+type Progress struct {
+	Progress float64
+	Total    float64
+	Message  string
+}
+
+// List returns the tools caller can call
+func (t *Tools) List(ctx context.Context, caller Caller) ([]*mcp.Tool, error) {
+	session, transport, err := t.connect(ctx, caller, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var tools []*mcp.Tool
+	for tool, err := range session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, callerError(transport, err)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// Call calls the tool name with arguments for caller. progress, if not nil,
+// receives the progress notifications of the call. Failures of the tool are
+// reported in the result, like over MCP; the error is for calls that did
+// not reach the tool.
+func (t *Tools) Call(ctx context.Context, caller Caller, name string, arguments map[string]any, progress func(Progress)) (*mcp.CallToolResult, error) {
+	session, transport, err := t.connect(ctx, caller, progress)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	params := &mcp.CallToolParams{Name: name, Arguments: arguments}
+	if progress != nil {
+		// SetProgressToken only adds the token to existing metadata
+		params.Meta = mcp.Meta{}
+		params.SetProgressToken(name)
+	}
+	result, err := session.CallTool(ctx, params)
+	var rpcErr *jsonrpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc.CodeInvalidParams {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCall, rpcErr.Message)
+	}
+	if err != nil {
+		return nil, callerError(transport, err)
+	}
+	return result, nil
+}
+
+// connect opens an MCP session for one call of caller. Sessions are not
+// shared, so each call is authenticated on its own.
+func (t *Tools) connect(ctx context.Context, caller Caller, progress func(Progress)) (*mcp.ClientSession, *handlerTransport, error) {
+	transport := &handlerTransport{handler: t.handler, header: caller.Header, remoteAddr: caller.RemoteAddr, host: caller.Host, tls: caller.TLS}
+	var opts *mcp.ClientOptions
+	if progress != nil {
+		opts = &mcp.ClientOptions{
+			ProgressNotificationHandler: func(_ context.Context, req *mcp.ProgressNotificationClientRequest) {
+				progress(Progress{Progress: req.Params.Progress, Total: req.Params.Total, Message: req.Params.Message})
+			},
+		}
+	}
+	client := mcp.NewClient(t.client, opts)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{
+		Endpoint:   "http://in-process/mcp",
+		HTTPClient: &http.Client{Transport: transport},
+		MaxRetries: -1,
+	}, nil)
+	if err != nil {
+		return nil, nil, callerError(transport, err)
+	}
+	return session, transport, nil
+}
+
+// callerError reports err as the refusal of the caller if the handler
+// refused it
+func callerError(transport *handlerTransport, err error) error {
+	switch transport.deniedStatus() {
+	case http.StatusUnauthorized:
+		return ErrUnauthenticated
+	case http.StatusForbidden:
+		return ErrPermissionDenied
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	return fmt.Errorf("MCP call failed: %w", err)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// handlerTransport is an http.RoundTripper that serves requests with an
+// http.Handler in process. Responses are streamed, so server-sent events
+// reach the client as the handler flushes them.
+type handlerTransport struct {
+	handler    http.Handler
+	header     http.Header // Added to every request, e.g. Authorization
+	remoteAddr string
+	host       string               // Host the caller reached, instead of the placeholder endpoint's
+	tls        *tls.ConnectionState // The caller's TLS connection, if any
+
+	mu     sync.Mutex
+	denied int // Status of the last response refusing the caller, if any
+}
+
+func (t *handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for key, values := range t.header {
+		req.Header[key] = values
+	}
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	req.RemoteAddr = t.remoteAddr
+	if t.host != "" {
+		req.Host = t.host
+	}
+	req.TLS = t.tls
+	req.RequestURI = req.URL.RequestURI()
+
+	body, pw := io.Pipe()
+	w := &streamWriter{header: make(http.Header), body: pw, ready: make(chan struct{})}
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				pw.CloseWithError(fmt.Errorf("handler panicked: %v", r))
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		body.Close()
+		return nil, req.Context().Err()
+	}
+	switch w.status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+		t.mu.Lock()
+		t.denied = w.status
+		t.mu.Unlock()
+	}
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode: w.status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     w.sent,
+		Body:       body,
+		Request:    req,
+	}, nil
+}
+
+// deniedStatus returns the status of the last response that refused the
+// caller: 401, 403 or 429, or 0
+func (t *handlerTransport) deniedStatus() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.denied
+}
+
+// streamWriter is the http.ResponseWriter of an in-process request. The
+// response is handed to the client once the status is written, and the body
+// is piped to it.
+type streamWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+	ready  chan struct{}
+
+	once   sync.Once
+	status int
+	sent   http.Header // The header as of WriteHeader
+}
+
+func (w *streamWriter) Header() http.Header { return w.header }
+
+func (w *streamWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush hands the response to the client; the pipe needs no flushing
+func (w *streamWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
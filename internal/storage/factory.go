@@ -3,26 +3,20 @@ package storage
 import (
 	"fmt"
 	"log"
+	"net/http"
 
 	"gemini-mcp/internal/common"
+	"gemini-mcp/internal/tempfiles"
 )
 
-// NewStorage creates the appropriate storage backend based on configuration
-func NewStorage(config *common.Config) (Storage, error) {
+// NewStorage creates the appropriate storage backend based on configuration.
+// transport is the shared connection pool used by remote backends, and
+// temp keeps the objects they download.
+func NewStorage(config *common.Config, transport http.RoundTripper, temp *tempfiles.Manager) (Storage, error) {
 	// Use S3 only in HTTP mode when S3 is configured
 	if config.S3Enabled {
-		log.Printf("Initializing S3 storage (endpoint: %s, bucket: %s)", config.S3Endpoint, config.S3Bucket)
-		return NewS3Storage(S3Config{
-			Endpoint:        config.S3Endpoint,
-			AccessKeyID:     config.S3AccessKeyID,
-			SecretAccessKey: config.S3SecretAccessKey,
-			Region:          config.S3Region,
-			Bucket:          config.S3Bucket,
-			UseSSL:          config.S3UseSSL,
-			PresignTTL:      config.S3PresignTTL,
-			ObjectTTL:       config.S3ObjectTTL,
-			CleanupInterval: config.S3CleanupInterval,
-		})
+		log.Printf("Initializing S3 storage (endpoint: %s, bucket: %s, credentials: %s)", config.S3Endpoint, config.S3Bucket, config.S3Credentials)
+		return NewS3Storage(s3ConfigFor(config, config.S3Bucket, transport, temp))
 	}
 
 	// Default to local storage
@@ -33,3 +27,59 @@ func NewStorage(config *common.Config) (Storage, error) {
 	}
 	return stor, nil
 }
+
+// NewTargetBackends creates the backends of the storage targets parsed by
+// ParseTargets: local storage in the output directory for "local", and
+// buckets on the configured S3 endpoint for the others
+func NewTargetBackends(config *common.Config, targets map[string]string, transport http.RoundTripper, temp *tempfiles.Manager) (map[string]Storage, error) {
+	backends := make(map[string]Storage, len(targets))
+	for name, bucket := range targets {
+		var backend Storage
+		var err error
+		if bucket == "" {
+			log.Printf("Initializing storage target %s (local directory: %s)", name, config.OutputDir)
+			backend, err = NewLocalStorage(config.OutputDir)
+		} else {
+			if config.S3Endpoint == "" {
+				return nil, fmt.Errorf("storage target %s requires S3_ENDPOINT", name)
+			}
+			log.Printf("Initializing storage target %s (endpoint: %s, bucket: %s)", name, config.S3Endpoint, bucket)
+			backend, err = NewS3Storage(s3ConfigFor(config, bucket, transport, temp))
+		}
+		if err != nil {
+			for _, created := range backends {
+				created.Close()
+			}
+			return nil, fmt.Errorf("failed to create storage target %s: %w", name, err)
+		}
+		backends[name] = backend
+	}
+	return backends, nil
+}
+
+// s3ConfigFor returns the S3 settings of the configuration for bucket
+func s3ConfigFor(config *common.Config, bucket string, transport http.RoundTripper, temp *tempfiles.Manager) S3Config {
+	return S3Config{
+		Endpoint:             config.S3Endpoint,
+		Credentials:          config.S3Credentials,
+		AccessKeyID:          config.S3AccessKeyID,
+		SecretAccessKey:      config.S3SecretAccessKey,
+		Region:               config.S3Region,
+		Bucket:               bucket,
+		UseSSL:               config.S3UseSSL,
+		PresignTTL:           config.S3PresignTTL,
+		ClockSkew:            config.PresignClockSkew,
+		ObjectTTL:            config.S3ObjectTTL,
+		CleanupInterval:      config.S3CleanupInterval,
+		LifecycleExpiry:      config.S3LifecycleExpiry,
+		ForcePathStyle:       config.S3ForcePathStyle,
+		SignatureVersion:     config.S3SignatureVersion,
+		Transport:            transport,
+		TempFiles:            temp,
+		RoleARN:              config.S3RoleARN,
+		RoleSessionName:      config.S3RoleSessionName,
+		ExternalID:           config.S3ExternalID,
+		WebIdentityTokenFile: config.S3WebIdentityTokenFile,
+		STSEndpoint:          config.S3STSEndpoint,
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// maxFilenameLength caps the length of sanitized filenames, before the
+// extension and any suffix
+const maxFilenameLength = 100
+
+type filenameKey struct{}
+
+// filename numbers the objects stored under one name per extension, so a
+// call that stores several files of a format gives each its own name
+type filename struct {
+	name   string
+	mu     sync.Mutex
+	stored map[string]int
+}
+
+// WithFilename returns a context under which stored objects are named after
+// name, e.g. "hero_banner_v3.png", instead of their prefix and content hash.
+// The name is sanitized with SanitizeFilename and the extension follows the
+// stored format. The second and later objects of a format stored under the
+// same context get "_2", "_3" and so on appended. If an object of that name
+// already holds different content, the content hash is appended instead of
+// replacing it, e.g. "hero_banner_v3_ab12cd34ef567890.png". Key hints take
+// precedence over names. An empty name clears an earlier one.
+func WithFilename(ctx context.Context, name string) context.Context {
+	name = SanitizeFilename(name)
+	if name == "" {
+		return context.WithValue(ctx, filenameKey{}, (*filename)(nil))
+	}
+	return context.WithValue(ctx, filenameKey{}, &filename{name: name, stored: make(map[string]int)})
+}
+
+// SanitizeFilename turns a requested filename into a safe single key
+// segment: directories and the extension are dropped, characters other than
+// ASCII letters, digits, '-' and '_' become '_', and the result is cut to
+// maxFilenameLength. It returns "" if nothing usable is left.
+func SanitizeFilename(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	if ext := path.Ext(name); ext != name {
+		name = strings.TrimSuffix(name, ext)
+	}
+	var b strings.Builder
+	underscore := false
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+			underscore = false
+		case !underscore:
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	name = strings.Trim(b.String(), "_-")
+	if len(name) > maxFilenameLength {
+		name = strings.TrimRight(name[:maxFilenameLength], "_-")
+	}
+	return name
+}
+
+// namedBase returns the name, without extension, of the next object with ext
+// stored under ctx's filename, or ok=false if ctx has none or has a key hint
+func namedBase(ctx context.Context, ext string) (base string, ok bool) {
+	f, _ := ctx.Value(filenameKey{}).(*filename)
+	if f == nil || ctx.Value(keyHintKey{}) != nil {
+		return "", false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stored[ext]++
+	if n := f.stored[ext]; n > 1 {
+		return fmt.Sprintf("%s_%d", f.name, n), true
+	}
+	return f.name, true
+}
+
+// hashedName is the fallback key of a named object whose name is taken by
+// different content
+func hashedName(base, contentHash, ext string) string {
+	return fmt.Sprintf("%s_%s%s", base, contentHash[:16], ext)
+}
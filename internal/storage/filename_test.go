@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"hero_banner_v3":          "hero_banner_v3",
+		"hero banner v3.png":      "hero_banner_v3",
+		"../../etc/passwd":        "passwd",
+		`C:\art\Logo (final).jpg`: "Logo_final",
+		"über-cover":              "ber-cover",
+		"...":                     "",
+		"__":                      "",
+	} {
+		if got := SanitizeFilename(name); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestLocalStorageFilename(t *testing.T) {
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithFilename(context.Background(), "hero banner")
+
+	first, err := local.Store(ctx, []byte("one"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ObjectKey != "hero_banner.png" {
+		t.Errorf("first key = %q, want hero_banner.png", first.ObjectKey)
+	}
+	second, err := local.Store(ctx, []byte("two"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ObjectKey != "hero_banner_2.png" {
+		t.Errorf("second key = %q, want hero_banner_2.png", second.ObjectKey)
+	}
+
+	// Identical content reuses the name; different content keeps the
+	// existing file and falls back to the hash
+	again, err := local.Store(WithFilename(context.Background(), "hero_banner"), []byte("one"), "image/png", "gemini_image")
+	if err != nil || again.ObjectKey != "hero_banner.png" || !again.Deduplicated {
+		t.Errorf("same content = %+v, %v; want hero_banner.png deduplicated", again, err)
+	}
+	other, err := local.Store(WithFilename(context.Background(), "hero_banner"), []byte("three"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := hashedName("hero_banner", other.ContentHash, ".png"); other.ObjectKey != want {
+		t.Errorf("conflicting key = %q, want %q", other.ObjectKey, want)
+	}
+
+	// Key hints take precedence
+	hinted, err := local.Store(WithKeyHint(ctx, "project/hero"), []byte("four"), "image/png", "gemini_image")
+	if err != nil || hinted.ObjectKey != "project/hero_latest.png" {
+		t.Errorf("hinted key = %+v, %v; want project/hero_latest.png", hinted, err)
+	}
+}
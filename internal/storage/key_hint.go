@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// LatestSuffix ends the stable key of objects stored under a key hint, e.g.
+// "project/hero_16x9_latest.png"
+const LatestSuffix = "_latest"
+
+// keyHintPattern is the form of key hints: slash-separated names of letters,
+// digits, '_' and '-'
+var keyHintPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-]+)*$`)
+
+type keyHintKey struct{}
+
+// keyHint numbers the objects stored under one hint, so a call that stores
+// several files gives each its own stable key
+type keyHint struct {
+	name   string
+	mu     sync.Mutex
+	stored int
+}
+
+// WithKeyHint returns a context under which stored objects get predictable
+// keys derived from hint instead of date and content hash based ones. Each
+// object is written twice: to a versioned key "<hint>_<hash><ext>" that never
+// changes, and to "<hint>_latest<ext>", which every re-run overwrites. The
+// second and later objects stored under the same context use "<hint>_2",
+// "<hint>_3" and so on. The hint must pass ValidateKeyHint.
+func WithKeyHint(ctx context.Context, hint string) context.Context {
+	return context.WithValue(ctx, keyHintKey{}, &keyHint{name: hint})
+}
+
+// ValidateKeyHint checks that hint can be used as a key prefix, such as
+// "hero" or "project/hero_16x9". Hints may not start in the namespaces of
+// tenants or the trash.
+func ValidateKeyHint(hint string) error {
+	if len(hint) > 200 || !keyHintPattern.MatchString(hint) {
+		return fmt.Errorf("invalid key hint %q (use letters, digits, '_' and '-', with '/' between names)", hint)
+	}
+	if strings.HasPrefix(hint+"/", TenantRoot) || strings.HasPrefix(hint+"/", TrashPrefix) {
+		return fmt.Errorf("invalid key hint %q (%q and %q are reserved)", hint, strings.TrimSuffix(TenantRoot, "/"), strings.TrimSuffix(TrashPrefix, "/"))
+	}
+	return ValidateObjectKey(hint)
+}
+
+// hintedKeys returns the stable and versioned keys of the next object stored
+// under ctx's key hint, below the tenant prefix, or ok=false if ctx has none
+func hintedKeys(ctx context.Context, contentHash, ext string) (latest, version string, ok bool) {
+	hint, _ := ctx.Value(keyHintKey{}).(*keyHint)
+	if hint == nil {
+		return "", "", false
+	}
+	hint.mu.Lock()
+	hint.stored++
+	name := hint.name
+	if hint.stored > 1 {
+		name = fmt.Sprintf("%s_%d", name, hint.stored)
+	}
+	hint.mu.Unlock()
+	name = TenantPrefix(ctx) + name
+	return name + LatestSuffix + ext, fmt.Sprintf("%s_%s%s", name, contentHash[:16], ext), true
+}
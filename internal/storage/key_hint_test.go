@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateKeyHint(t *testing.T) {
+	for _, hint := range []string{"hero", "project/hero_16x9", "a-b/c_d/e", "tenants_page/hero"} {
+		if err := ValidateKeyHint(hint); err != nil {
+			t.Errorf("ValidateKeyHint(%q) = %v", hint, err)
+		}
+	}
+	for _, hint := range []string{"", "/hero", "hero/", "../hero", "project//hero", "hero.png", "hero latest", "tenants/other/x", "tenants", "_trash/x"} {
+		if err := ValidateKeyHint(hint); err == nil {
+			t.Errorf("ValidateKeyHint(%q) accepted an invalid hint", hint)
+		}
+	}
+}
+
+func TestLocalStorageKeyHint(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	ctx := WithKeyHint(context.Background(), "project/hero_16x9")
+	first, err := s.Store(ctx, []byte("first"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if first.ObjectKey != "project/hero_16x9_latest.png" {
+		t.Errorf("ObjectKey = %q, want the stable key", first.ObjectKey)
+	}
+	if first.VersionKey != "project/hero_16x9_"+first.ContentHash[:16]+".png" {
+		t.Errorf("VersionKey = %q, want the hint and content hash", first.VersionKey)
+	}
+	second, err := s.Store(ctx, []byte("second"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if second.ObjectKey != "project/hero_16x9_2_latest.png" {
+		t.Errorf("second ObjectKey = %q, want a numbered stable key", second.ObjectKey)
+	}
+
+	// A re-run overwrites the stable key and keeps earlier versions
+	rerun, err := s.Store(WithKeyHint(context.Background(), "project/hero_16x9"), []byte("rerun"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if rerun.ObjectKey != first.ObjectKey {
+		t.Errorf("re-run ObjectKey = %q, want %q", rerun.ObjectKey, first.ObjectKey)
+	}
+	for key, want := range map[string]string{
+		rerun.ObjectKey:  "rerun",
+		rerun.VersionKey: "rerun",
+		first.VersionKey: "first",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, key))
+		if err != nil || string(data) != want {
+			t.Errorf("%s = %q, %v; want %q", key, data, err, want)
+		}
+	}
+
+	plain, err := s.Store(context.Background(), []byte("plain"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if plain.VersionKey != "" || filepath.Dir(plain.ObjectKey) != "." {
+		t.Errorf("Store without a hint = %+v, want a content hash key", plain)
+	}
+}
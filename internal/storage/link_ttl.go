@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bounds for per-request link TTLs. S3 rejects presigned URLs valid for
+// longer than 7 days.
+const (
+	MinLinkTTL = time.Minute
+	MaxLinkTTL = 7 * 24 * time.Hour
+)
+
+type linkTTLKey struct{}
+
+// WithLinkTTL returns a context under which stored objects are reported with
+// download URLs valid for ttl instead of the configured default
+func WithLinkTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, linkTTLKey{}, ttl)
+}
+
+// LinkTTL returns the link TTL requested for ctx, or fallback if none was set
+func LinkTTL(ctx context.Context, fallback time.Duration) time.Duration {
+	if ttl, ok := ctx.Value(linkTTLKey{}).(time.Duration); ok && ttl > 0 {
+		return ttl
+	}
+	return fallback
+}
+
+// ParseLinkTTL parses a link TTL such as "5m", "12h", "7d" or "1d12h" and
+// checks that it lies between MinLinkTTL and MaxLinkTTL
+func ParseLinkTTL(s string) (time.Duration, error) {
+	var ttl time.Duration
+	rest := s
+	if days, after, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid link TTL %q: expected a duration such as '5m', '12h' or '7d'", s)
+		}
+		ttl = time.Duration(n) * 24 * time.Hour
+		rest = after
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid link TTL %q: expected a duration such as '5m', '12h' or '7d'", s)
+		}
+		ttl += d
+	}
+	if ttl < MinLinkTTL || ttl > MaxLinkTTL {
+		return 0, fmt.Errorf("link TTL %q must be between %v and 7 days", s, MinLinkTTL)
+	}
+	return ttl, nil
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLinkTTL(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"5m":    5 * time.Minute,
+		"12h":   12 * time.Hour,
+		"7d":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+	} {
+		got, err := ParseLinkTTL(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLinkTTL(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+
+	for _, bad := range []string{"", "soon", "30s", "8d", "7d1m", "-1d", "d"} {
+		if _, err := ParseLinkTTL(bad); err == nil {
+			t.Errorf("ParseLinkTTL(%q) accepted an invalid TTL", bad)
+		}
+	}
+}
+
+func TestLinkTTLContext(t *testing.T) {
+	ctx := context.Background()
+	if got := LinkTTL(ctx, time.Hour); got != time.Hour {
+		t.Errorf("LinkTTL without override = %v, want fallback", got)
+	}
+	if got := LinkTTL(WithLinkTTL(ctx, 5*time.Minute), time.Hour); got != 5*time.Minute {
+		t.Errorf("LinkTTL with override = %v, want 5m", got)
+	}
+}
@@ -6,7 +6,13 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
 )
 
 // LocalStorage implements Storage interface for local filesystem
@@ -31,27 +37,111 @@ func (s *LocalStorage) Store(ctx context.Context, data []byte, mimeType string,
 	// Determine file extension from MIME type
 	ext := ExtensionFromMIME(mimeType)
 
-	// Build filename with prefix and hash (first 16 chars)
-	filename := fmt.Sprintf("%s_%s%s", prefix, contentHash[:16], ext)
-
-	// Full path in base directory
-	outputPath := filepath.Join(s.baseDir, filename)
+	// Build filename with the tenant prefix, prefix and hash (first 16 chars),
+	// or the stable and versioned keys requested with WithKeyHint, or the
+	// name requested with WithFilename
+	filename := fmt.Sprintf("%s%s_%s%s", TenantPrefix(ctx), prefix, contentHash[:16], ext)
+	latest, version, hinted := hintedKeys(ctx, contentHash, ext)
+	if base, named := namedBase(ctx, ext); named {
+		filename = s.namedKey(TenantPrefix(ctx)+base, contentHash, ext)
+	}
 
-	// Write file
-	if err := os.WriteFile(outputPath, data, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write file: %w", err)
+	// Keys carry the content hash, or hold identical content if named, so
+	// identical content is already in place; the stable key under
+	// WithKeyHint is always rewritten
+	contentKey := filename
+	if hinted {
+		contentKey = version
+	}
+	deduplicated := s.stored(contentKey, len(data))
+	if !deduplicated {
+		if err := s.writeFile(ctx, contentKey, data); err != nil {
+			return nil, err
+		}
+	}
+	if hinted {
+		filename = latest
+		if err := s.writeFile(ctx, filename, data); err != nil {
+			return nil, err
+		}
 	}
+	outputPath := filepath.Join(s.baseDir, filename)
 
 	return &StorageResult{
-		Location:    outputPath,
-		ObjectKey:   filename,
-		ContentHash: contentHash,
-		MIMEType:    mimeType,
-		Size:        int64(len(data)),
-		ExpiresAt:   nil, // Local storage doesn't expire
+		Location:     outputPath,
+		ObjectKey:    filename,
+		ContentHash:  contentHash,
+		MIMEType:     mimeType,
+		Size:         int64(len(data)),
+		ExpiresAt:    nil, // Local storage doesn't expire
+		VersionKey:   version,
+		Deduplicated: deduplicated,
 	}, nil
 }
 
+// namedKey returns base with ext, unless a file of that name holds other
+// content than contentHash, in which case the hash is appended
+func (s *LocalStorage) namedKey(base, contentHash, ext string) string {
+	data, err := os.ReadFile(filepath.Join(s.baseDir, base+ext))
+	if err != nil {
+		return base + ext
+	}
+	if hash := sha256.Sum256(data); hex.EncodeToString(hash[:]) == contentHash {
+		return base + ext
+	}
+	return hashedName(base, contentHash, ext)
+}
+
+// stored reports whether objectKey already holds size bytes, marking the
+// file as modified now so that age-based cleanup counts from this store
+func (s *LocalStorage) stored(objectKey string, size int) bool {
+	outputPath := filepath.Join(s.baseDir, objectKey)
+	info, err := os.Stat(outputPath)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != int64(size) {
+		return false
+	}
+	now := time.Now()
+	return os.Chtimes(outputPath, now, now) == nil
+}
+
+// writeFile atomically writes data to objectKey in the base directory
+func (s *LocalStorage) writeFile(ctx context.Context, objectKey string, data []byte) error {
+	// Full path in base directory; prefixes such as "thumb/" become subdirectories
+	outputPath := filepath.Join(s.baseDir, objectKey)
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename it into place, so an interrupted write
+	// never leaves a partial file under the final name
+	tmpFile, err := os.CreateTemp(filepath.Dir(outputPath), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmpFile.Name(), outputPath); err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	return nil
+}
+
 // Retrieve returns the local file path for a given object key
 // For local storage, no download is needed - just verify the file exists
 func (s *LocalStorage) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
@@ -59,7 +149,7 @@ func (s *LocalStorage) Retrieve(ctx context.Context, objectKey string) (string,
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", nil, fmt.Errorf("file not found: %s", objectKey)
+		return "", nil, fmt.Errorf("%w: %s", ErrNotFound, objectKey)
 	} else if err != nil {
 		return "", nil, fmt.Errorf("failed to stat file: %w", err)
 	}
@@ -72,6 +162,9 @@ func (s *LocalStorage) Retrieve(ctx context.Context, objectKey string) (string,
 
 // Delete removes a file from local storage
 func (s *LocalStorage) Delete(ctx context.Context, objectKey string) error {
+	if err := ValidateObjectKey(objectKey); err != nil {
+		return err
+	}
 	filePath := filepath.Join(s.baseDir, objectKey)
 	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete file: %w", err)
@@ -79,6 +172,71 @@ func (s *LocalStorage) Delete(ctx context.Context, objectKey string) error {
 	return nil
 }
 
+// Move renames a file within local storage
+func (s *LocalStorage) Move(ctx context.Context, objectKey, newKey string) error {
+	for _, key := range []string{objectKey, newKey} {
+		if err := ValidateObjectKey(key); err != nil {
+			return err
+		}
+	}
+	newPath := filepath.Join(s.baseDir, newKey)
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.Rename(filepath.Join(s.baseDir, objectKey), newPath); os.IsNotExist(err) {
+		return fmt.Errorf("%w: %s", ErrNotFound, objectKey)
+	} else if err != nil {
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	now := time.Now()
+	if err := os.Chtimes(newPath, now, now); err != nil {
+		return fmt.Errorf("failed to update file time: %w", err)
+	}
+	return nil
+}
+
+// List returns the files whose key starts with prefix. A prefix with a
+// directory, such as "tenants/acme/gemini_image", lists that directory.
+func (s *LocalStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	dir, namePrefix := path.Split(prefix)
+	if dir != "" {
+		if err := ValidateObjectKey(strings.TrimSuffix(dir, "/")); err != nil {
+			return nil, err
+		}
+	}
+	entries, err := os.ReadDir(filepath.Join(s.baseDir, dir))
+	if os.IsNotExist(err) && dir != "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage directory: %w", err)
+	}
+
+	var objects []ObjectInfo
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !strings.HasPrefix(entry.Name(), namePrefix) || strings.HasPrefix(entry.Name(), ".tmp-") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		objects = append(objects, ObjectInfo{
+			ObjectKey:    dir + entry.Name(),
+			Size:         info.Size(),
+			LastModified: info.ModTime(),
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ObjectKey < objects[j].ObjectKey })
+	return objects, nil
+}
+
+// ShareLink is not supported for local storage, whose files are only
+// reachable by path
+func (s *LocalStorage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	return "", time.Time{}, toolerr.Errorf(toolerr.InvalidInput, "share links require S3 storage or the HTTP transport")
+}
+
 // Close is a no-op for local storage
 func (s *LocalStorage) Close() error {
 	return nil
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocalStorageListAndDelete(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	img, err := s.Store(ctx, []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if _, err := s.Store(ctx, []byte("video"), "video/mp4", "veo_video"); err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+
+	all, err := s.List(ctx, "")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(all))
+	}
+
+	images, err := s.List(ctx, "gemini_image")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(images) != 1 || images[0].ObjectKey != img.ObjectKey || images[0].Size != 5 {
+		t.Fatalf("unexpected prefix listing: %+v", images)
+	}
+
+	if err := s.Delete(ctx, img.ObjectKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(img.Location); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", img.Location)
+	}
+}
+
+func TestLocalStorageDeleteRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside.txt")
+	if err := os.WriteFile(outside, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewLocalStorage(filepath.Join(dir, "media"))
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	for _, key := range []string{"../outside.txt", "/etc/passwd", "a/../../outside.txt", ""} {
+		if err := s.Delete(context.Background(), key); err == nil {
+			t.Errorf("expected Delete(%q) to fail", key)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("file outside storage root was touched: %v", err)
+	}
+}
+
+func TestLocalStorageNestedPrefix(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	result, err := s.Store(ctx, []byte("preview"), "image/jpeg", "thumb/gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if filepath.Dir(result.ObjectKey) != "thumb" {
+		t.Errorf("object key %q, want it under thumb/", result.ObjectKey)
+	}
+	if _, err := os.Stat(filepath.Join(dir, result.ObjectKey)); err != nil {
+		t.Errorf("stored file missing: %v", err)
+	}
+}
+
+func TestLocalStorageStoreHonorsCancellation(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocalStorage(dir)
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Store(ctx, []byte("image"), "image/png", "gemini_image"); err != context.Canceled {
+		t.Fatalf("Store with cancelled context: err = %v, want context.Canceled", err)
+	}
+
+	result, err := s.Store(context.Background(), []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	info, err := os.Stat(result.Location)
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Fatalf("stored file: %v, %v", info, err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Fatalf("expected only the stored file in %s, got %d entries", dir, len(entries))
+	}
+}
+
+func TestLocalStorageDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+
+	first, err := s.Store(ctx, []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if first.Deduplicated {
+		t.Error("first store reported as deduplicated")
+	}
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(first.Location, old, old)
+
+	second, err := s.Store(ctx, []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if !second.Deduplicated || second.ObjectKey != first.ObjectKey {
+		t.Errorf("second store = %+v, want the existing key reused", second)
+	}
+	if info, _ := os.Stat(second.Location); info.ModTime().Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("reused file keeps its old time %v, want it refreshed", info.ModTime())
+	}
+}
@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathPolicy restricts which user-supplied local paths may be read or written.
+// A nil or zero PathPolicy allows every path.
+type PathPolicy struct {
+	// FollowSymlinks allows paths that are or pass through symbolic links.
+	// Links in the allowed roots themselves are always resolved.
+	FollowSymlinks bool
+	// AllowedRoots are the directories (typically bind-mounted workspaces)
+	// that paths must resolve into. Empty allows any location.
+	AllowedRoots []string
+}
+
+// NewPathPolicy creates a policy, resolving allowed roots to absolute paths
+// with symlinks evaluated so that mount points reached through links match.
+func NewPathPolicy(followSymlinks bool, allowedRoots []string) (*PathPolicy, error) {
+	p := &PathPolicy{FollowSymlinks: followSymlinks}
+	for _, root := range allowedRoots {
+		resolved, err := resolvePath(root)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowed root %s: %w", root, err)
+		}
+		p.AllowedRoots = append(p.AllowedRoots, resolved)
+	}
+	return p, nil
+}
+
+// Check returns an error if path is not permitted by the policy. The path
+// does not need to exist yet, so output locations can be checked before they
+// are created.
+func (p *PathPolicy) Check(path string) error {
+	if p == nil || (p.FollowSymlinks && len(p.AllowedRoots) == 0) {
+		return nil
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+	resolved, err := resolvePath(abs)
+	if err != nil {
+		return fmt.Errorf("invalid path %s: %w", path, err)
+	}
+
+	if len(p.AllowedRoots) > 0 {
+		root := p.rootOf(resolved)
+		if root == "" {
+			return fmt.Errorf("path %s is outside the allowed mounts (%s)", path, strings.Join(p.AllowedRoots, ", "))
+		}
+		if !p.FollowSymlinks && !p.lexicallyMatches(abs, resolved) {
+			return fmt.Errorf("path %s is or passes through a symbolic link, which is not allowed (FOLLOW_SYMLINKS=false)", path)
+		}
+		return nil
+	}
+
+	if !p.FollowSymlinks && resolved != abs {
+		return fmt.Errorf("path %s is or passes through a symbolic link, which is not allowed (FOLLOW_SYMLINKS=false)", path)
+	}
+	return nil
+}
+
+// rootOf returns the allowed root containing resolved, or ""
+func (p *PathPolicy) rootOf(resolved string) string {
+	for _, root := range p.AllowedRoots {
+		if within(root, resolved) {
+			return root
+		}
+	}
+	return ""
+}
+
+// lexicallyMatches reports whether abs reaches resolved without following
+// links below an allowed root. abs may name a root through a link of its own
+// (e.g. /workspace -> /mnt/data/workspace), which is resolved like the root.
+func (p *PathPolicy) lexicallyMatches(abs, resolved string) bool {
+	if abs == resolved {
+		return true
+	}
+	// Walk down from the filesystem root to find the shallowest directory that
+	// names an allowed root; nothing below it may be a link
+	var ancestors []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		ancestors = append([]string{dir}, ancestors...)
+		if parent := filepath.Dir(dir); parent == dir {
+			break
+		}
+	}
+	for _, dir := range ancestors {
+		resolvedDir, err := resolvePath(dir)
+		if err != nil {
+			return false
+		}
+		for _, root := range p.AllowedRoots {
+			if resolvedDir == root {
+				rel, _ := filepath.Rel(dir, abs)
+				return filepath.Join(root, rel) == resolved
+			}
+		}
+	}
+	return false
+}
+
+// within reports whether path is root or inside it
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolvePath returns the absolute form of path with symlinks evaluated. The
+// deepest existing ancestor is resolved and missing components are appended.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	var missing []string
+	for dir := abs; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return abs, nil
+		}
+		missing = append([]string{filepath.Base(dir)}, missing...)
+	}
+}
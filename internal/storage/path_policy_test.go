@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathPolicy(t *testing.T) {
+	// Resolve the temp dir itself, which is a link on some systems (e.g. macOS /var)
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspace := filepath.Join(base, "workspace")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{workspace, outside} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "in.png"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.png"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A link inside the workspace escaping it, a link inside it staying inside,
+	// and a link naming the workspace itself (as a bind mount alias would)
+	mustSymlink(t, filepath.Join(outside, "secret.png"), filepath.Join(workspace, "escape.png"))
+	mustSymlink(t, filepath.Join(workspace, "in.png"), filepath.Join(workspace, "alias.png"))
+	mustSymlink(t, workspace, filepath.Join(base, "ws"))
+
+	following, err := NewPathPolicy(true, []string{workspace})
+	if err != nil {
+		t.Fatal(err)
+	}
+	strict, err := NewPathPolicy(false, []string{filepath.Join(base, "ws")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noLinks, err := NewPathPolicy(false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		policy *PathPolicy
+		path   string
+		ok     bool
+	}{
+		{"file in root", following, filepath.Join(workspace, "in.png"), true},
+		{"new output dir in root", following, filepath.Join(workspace, "out", "new"), true},
+		{"file outside root", following, filepath.Join(outside, "secret.png"), false},
+		{"dot-dot escape", following, filepath.Join(workspace, "..", "outside", "secret.png"), false},
+		{"link escaping root", following, filepath.Join(workspace, "escape.png"), false},
+		{"link within root", following, filepath.Join(workspace, "alias.png"), true},
+		{"root reached through its own link", strict, filepath.Join(base, "ws", "in.png"), true},
+		{"root reached directly", strict, filepath.Join(workspace, "in.png"), true},
+		{"link within root when not following", strict, filepath.Join(workspace, "alias.png"), false},
+		{"plain file without roots", noLinks, filepath.Join(outside, "secret.png"), true},
+		{"link without roots", noLinks, filepath.Join(workspace, "alias.png"), false},
+		{"nil policy", nil, filepath.Join(workspace, "escape.png"), true},
+	}
+	for _, c := range cases {
+		err := c.policy.Check(c.path)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: Check(%s) = %v, want ok=%v", c.name, c.path, err, c.ok)
+		}
+	}
+}
+
+func mustSymlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+}
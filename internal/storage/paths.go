@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// NormalizeLocalPath cleans a user-supplied file path and reports whether it
+// is absolute. It strips surrounding quotes, accepts file:// URIs, expands a
+// leading "~" to the home directory and $VAR, ${VAR} and %VAR% environment
+// variables, and recognises Windows drive-letter (C:\...) and UNC
+// (\\server\share\...) paths regardless of the host OS.
+func NormalizeLocalPath(p string) (string, bool) {
+	p = strings.TrimSpace(p)
+	if len(p) >= 2 && (p[0] == '"' || p[0] == '\'') && p[len(p)-1] == p[0] {
+		p = p[1 : len(p)-1]
+	}
+
+	if strings.HasPrefix(strings.ToLower(p), "file://") {
+		if u, err := url.Parse(p); err == nil {
+			p = u.Path
+			if u.Host != "" && u.Host != "localhost" {
+				p = "//" + u.Host + p // file://server/share/x is a UNC path
+			} else if isDrivePath(strings.TrimPrefix(p, "/")) {
+				p = strings.TrimPrefix(p, "/") // file:///C:/x
+			}
+		}
+	}
+
+	p = expandEnv(p)
+
+	if p == "~" || strings.HasPrefix(p, "~/") || strings.HasPrefix(p, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+
+	if isDrivePath(p) || isUNCPath(p) {
+		if runtime.GOOS == "windows" {
+			p = filepath.Clean(p)
+		}
+		return p, true
+	}
+
+	if p == "" {
+		return p, false
+	}
+	return filepath.Clean(p), filepath.IsAbs(p) || strings.HasPrefix(p, "/")
+}
+
+// envVarRef matches $VAR, ${VAR} and Windows-style %VAR% references
+var envVarRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}|\$([A-Za-z_][A-Za-z0-9_]*)|%([A-Za-z_][A-Za-z0-9_]*)%`)
+
+// expandEnv expands references to set environment variables. References to
+// unset variables are left untouched so that file names which happen to
+// contain "$" or "%" still resolve.
+func expandEnv(p string) string {
+	return envVarRef.ReplaceAllStringFunc(p, func(ref string) string {
+		m := envVarRef.FindStringSubmatch(ref)
+		name := m[1] + m[2] + m[3]
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// isDrivePath reports whether p starts with a Windows drive letter and root, e.g. C:\ or C:/
+func isDrivePath(p string) bool {
+	if len(p) < 3 || p[1] != ':' || (p[2] != '\\' && p[2] != '/') {
+		return false
+	}
+	c := p[0] | 0x20 // lower-case ASCII letters
+	return c >= 'a' && c <= 'z'
+}
+
+// isUNCPath reports whether p is a Windows UNC path such as \\server\share
+func isUNCPath(p string) bool {
+	return len(p) > 2 && (strings.HasPrefix(p, `\\`) || (strings.HasPrefix(p, "//") && p[2] != '/'))
+}
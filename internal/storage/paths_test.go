@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNormalizeLocalPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("expectations use Unix separators; filepath.Clean rewrites them on Windows")
+	}
+	home, _ := os.UserHomeDir()
+	t.Setenv("GEMINI_MCP_TEST_DIR", "/data/assets")
+	cases := []struct {
+		in       string
+		want     string
+		absolute bool
+	}{
+		{"/tmp/a/../b.png", "/tmp/b.png", true},
+		{`"/tmp/with space.png"`, "/tmp/with space.png", true},
+		{"file:///tmp/x.png", "/tmp/x.png", true},
+		{"~/pics/x.png", filepath.Join(home, "pics/x.png"), true},
+		{"$GEMINI_MCP_TEST_DIR/x.png", "/data/assets/x.png", true},
+		{"${GEMINI_MCP_TEST_DIR}/sub/x.png", "/data/assets/sub/x.png", true},
+		{"%GEMINI_MCP_TEST_DIR%/x.png", "/data/assets/x.png", true},
+		{"/tmp/$GEMINI_MCP_UNSET_VAR/x.png", "/tmp/$GEMINI_MCP_UNSET_VAR/x.png", true},
+		{"gemini_image_abc.png", "gemini_image_abc.png", false},
+		{"2024/12/23/upload_abc.png", "2024/12/23/upload_abc.png", false},
+		{`C:\Users\me\photo.png`, `C:\Users\me\photo.png`, true},
+		{"c:/Users/me/photo.png", "c:/Users/me/photo.png", true},
+		{"file:///C:/Users/me/photo.png", "C:/Users/me/photo.png", true},
+		{`\\fileserver\share\photo.png`, `\\fileserver\share\photo.png`, true},
+		{"file://fileserver/share/photo.png", "//fileserver/share/photo.png", true},
+	}
+	for _, c := range cases {
+		got, absolute := NormalizeLocalPath(c.in)
+		if got != c.want || absolute != c.absolute {
+			t.Errorf("NormalizeLocalPath(%q) = %q, %v; want %q, %v", c.in, got, absolute, c.want, c.absolute)
+		}
+	}
+}
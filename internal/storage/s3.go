@@ -8,13 +8,15 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"time"
 
+	"gemini-mcp/internal/tempfiles"
+
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
 // S3Storage implements Storage interface for S3/MinIO
@@ -22,22 +24,40 @@ type S3Storage struct {
 	client          *minio.Client
 	bucket          string
 	presignTTL      time.Duration
+	clockSkew       time.Duration
 	objectTTL       time.Duration
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
+	tempFiles       *tempfiles.Manager
 }
 
 // S3Config holds S3 storage configuration
 type S3Config struct {
 	Endpoint        string
+	Credentials     string // Credential provider, one of the S3Credentials* constants (default: static)
 	AccessKeyID     string
 	SecretAccessKey string
 	Region          string
 	Bucket          string
 	UseSSL          bool
 	PresignTTL      time.Duration
+	ClockSkew       time.Duration // Added to the validity of presigned URLs, which is signed against the server's clock
 	ObjectTTL       time.Duration
 	CleanupInterval time.Duration
+	LifecycleExpiry bool               // Expire objects with a bucket lifecycle rule instead of listing the bucket
+	Transport       http.RoundTripper  // Shared connection pool (nil = minio default)
+	TempFiles       *tempfiles.Manager // Directory of downloaded objects (nil = system temp directory)
+
+	// S3-compatible stores
+	ForcePathStyle   bool   // Address buckets as endpoint/bucket even where minio would use bucket.endpoint
+	SignatureVersion string // One of the S3Signature* constants (default: v4)
+
+	// Role-based credentials (web_identity and assume_role)
+	RoleARN              string
+	RoleSessionName      string
+	ExternalID           string
+	WebIdentityTokenFile string
+	STSEndpoint          string // Empty uses AWS STS
 }
 
 // parseEndpoint extracts host:port from an endpoint that may include a protocol
@@ -66,10 +86,21 @@ func NewS3Storage(cfg S3Config) (*S3Storage, error) {
 	// Parse endpoint to extract host:port and detect SSL from scheme
 	endpoint, useSSL := parseEndpoint(cfg.Endpoint, cfg.UseSSL)
 
+	creds, err := s3Credentials(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketLookup := minio.BucketLookupAuto
+	if cfg.ForcePathStyle {
+		bucketLookup = minio.BucketLookupPath
+	}
 	client, err := minio.New(endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""),
-		Secure: useSSL,
-		Region: cfg.Region,
+		Creds:        creds,
+		Secure:       useSSL,
+		Region:       cfg.Region,
+		BucketLookup: bucketLookup,
+		Transport:    cfg.Transport,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create S3 client: %w", err)
@@ -98,12 +129,21 @@ func NewS3Storage(cfg S3Config) (*S3Storage, error) {
 		client:          client,
 		bucket:          cfg.Bucket,
 		presignTTL:      cfg.PresignTTL,
+		clockSkew:       cfg.ClockSkew,
 		objectTTL:       cfg.ObjectTTL,
 		cleanupInterval: cfg.CleanupInterval,
 		stopCleanup:     make(chan struct{}),
+		tempFiles:       cfg.TempFiles,
 	}
 
-	// Start cleanup routine
+	// Let the bucket expire objects itself if possible, otherwise scan it
+	if cfg.LifecycleExpiry {
+		err := s.configureLifecycle(ctx)
+		if err == nil {
+			return s, nil
+		}
+		log.Printf("Warning: %v; falling back to listing the bucket every %v", err, s.cleanupInterval)
+	}
 	go s.startCleanupRoutine()
 
 	return s, nil
@@ -115,44 +155,95 @@ func (s *S3Storage) Store(ctx context.Context, data []byte, mimeType string, pre
 	hash := sha256.Sum256(data)
 	contentHash := hex.EncodeToString(hash[:])
 
-	// Build date-organized path: YYYY/MM/DD/prefix_hash.ext
+	// Build date-organized path: [tenants/<tenant>/]YYYY/MM/DD/prefix_hash.ext
 	now := time.Now().UTC()
 	datePath := now.Format("2006/01/02")
 	ext := ExtensionFromMIME(mimeType)
 	filename := fmt.Sprintf("%s_%s%s", prefix, contentHash[:16], ext)
-	objectKey := fmt.Sprintf("%s/%s", datePath, filename)
+	objectKey := fmt.Sprintf("%s%s/%s", TenantPrefix(ctx), datePath, filename)
+	latest, version, hinted := hintedKeys(ctx, contentHash, ext)
+	if base, named := namedBase(ctx, ext); named {
+		objectKey = s.namedKey(ctx, fmt.Sprintf("%s%s/%s", TenantPrefix(ctx), datePath, base), contentHash, ext)
+	}
+	presignTTL := LinkTTL(ctx, s.presignTTL)
 
-	// Upload to S3
-	reader := bytes.NewReader(data)
-	_, err := s.client.PutObject(ctx, s.bucket, objectKey, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType: mimeType,
-		UserMetadata: map[string]string{
-			"created-at": now.Format(time.RFC3339),
-			"expires-at": now.Add(s.objectTTL).Format(time.RFC3339),
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload to S3: %w", err)
+	// Skip uploading content the content-addressed key already holds; the
+	// stable key under WithKeyHint is always rewritten
+	contentKey := objectKey
+	if hinted {
+		contentKey = version
+	}
+	deduplicated := s.stored(ctx, contentKey, contentHash, now.Add(presignTTL))
+	if deduplicated {
+		log.Printf("Reusing S3 object %s with identical content", contentKey)
+	} else if err := s.put(ctx, contentKey, data, mimeType, contentHash, now); err != nil {
+		return nil, err
+	}
+	if hinted {
+		objectKey = latest
+		if err := s.put(ctx, objectKey, data, mimeType, contentHash, now); err != nil {
+			return nil, err
+		}
 	}
 
 	// Generate presigned URL
-	presignedURL, err := s.client.PresignedGetObject(ctx, s.bucket, objectKey, s.presignTTL, url.Values{})
+	presignedURL, err := s.client.PresignedGetObject(ctx, s.bucket, objectKey, s.presignValidity(presignTTL), url.Values{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate presigned URL: %w", err)
 	}
 
-	expiresAt := now.Add(s.presignTTL)
+	expiresAt := now.Add(presignTTL)
 
 	return &StorageResult{
-		Location:    presignedURL.String(),
-		ObjectKey:   objectKey,
-		ContentHash: contentHash,
-		MIMEType:    mimeType,
-		Size:        int64(len(data)),
-		ExpiresAt:   &expiresAt,
+		Location:     presignedURL.String(),
+		ObjectKey:    objectKey,
+		ContentHash:  contentHash,
+		MIMEType:     mimeType,
+		Size:         int64(len(data)),
+		ExpiresAt:    &expiresAt,
+		VersionKey:   version,
+		Deduplicated: deduplicated,
 	}, nil
 }
 
+// namedKey returns base with ext, unless an object of that name holds other
+// content than contentHash, in which case the hash is appended
+func (s *S3Storage) namedKey(ctx context.Context, base, contentHash, ext string) string {
+	stat, err := s.client.StatObject(ctx, s.bucket, base+ext, minio.StatObjectOptions{})
+	if err != nil || stat.UserMetadata["Content-Sha256"] == contentHash {
+		return base + ext
+	}
+	return hashedName(base, contentHash, ext)
+}
+
+// stored reports whether objectKey already holds the content with
+// contentHash and will not expire before until, so that links to it stay
+// valid. Older copies are uploaded again, which restarts their expiry.
+func (s *S3Storage) stored(ctx context.Context, objectKey, contentHash string, until time.Time) bool {
+	stat, err := s.client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{})
+	if err != nil {
+		return false
+	}
+	return stat.UserMetadata["Content-Sha256"] == contentHash && stat.LastModified.Add(s.objectTTL).After(until)
+}
+
+// put uploads data to objectKey with the metadata the cleanup routine and
+// deduplication read
+func (s *S3Storage) put(ctx context.Context, objectKey string, data []byte, mimeType, contentHash string, now time.Time) error {
+	_, err := s.client.PutObject(ctx, s.bucket, objectKey, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: mimeType,
+		UserMetadata: map[string]string{
+			"created-at":     now.Format(time.RFC3339),
+			"expires-at":     now.Add(s.objectTTL).Format(time.RFC3339),
+			"content-sha256": contentHash,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload to S3: %w", err)
+	}
+	return nil
+}
+
 // Retrieve downloads an object from S3 to a local temp file
 // Returns the local file path and a cleanup function to remove the temp file
 func (s *S3Storage) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
@@ -165,6 +256,9 @@ func (s *S3Storage) Retrieve(ctx context.Context, objectKey string) (string, fun
 
 	// Get object info to determine extension
 	stat, err := object.Stat()
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return "", nil, fmt.Errorf("%w: %s", ErrNotFound, objectKey)
+	}
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to stat object: %w", err)
 	}
@@ -176,7 +270,7 @@ func (s *S3Storage) Retrieve(ctx context.Context, objectKey string) (string, fun
 	}
 
 	// Create temp file
-	tmpFile, err := os.CreateTemp("/tmp", "gemini-mcp-*"+ext)
+	tmpFile, err := s.tempFiles.Create("gemini-mcp-*" + ext)
 	if err != nil {
 		return "", nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
@@ -214,6 +308,65 @@ func (s *S3Storage) Delete(ctx context.Context, objectKey string) error {
 	return nil
 }
 
+// Move copies an object to newKey within the bucket and removes the original
+func (s *S3Storage) Move(ctx context.Context, objectKey, newKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: newKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: objectKey})
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return fmt.Errorf("%w: %s", ErrNotFound, objectKey)
+	} else if err != nil {
+		return fmt.Errorf("failed to copy object: %w", err)
+	}
+	if err := s.client.RemoveObject(ctx, s.bucket, objectKey, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to remove moved object: %w", err)
+	}
+	return nil
+}
+
+// List returns the objects in the bucket whose key starts with prefix
+func (s *S3Storage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", object.Err)
+		}
+		expiresAt := object.LastModified.Add(s.objectTTL)
+		objects = append(objects, ObjectInfo{
+			ObjectKey:    object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			ExpiresAt:    &expiresAt,
+		})
+	}
+	return objects, nil
+}
+
+// ShareLink presigns a new URL for an existing object
+func (s *S3Storage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	if _, err := s.client.StatObject(ctx, s.bucket, objectKey, minio.StatObjectOptions{}); err != nil {
+		return "", time.Time{}, fmt.Errorf("object not found: %w", err)
+	}
+	expiresAt := time.Now().Add(ttl)
+	presignedURL, err := s.client.PresignedGetObject(ctx, s.bucket, objectKey, s.presignValidity(ttl), url.Values{})
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate presigned URL: %w", err)
+	}
+	return presignedURL.String(), expiresAt, nil
+}
+
+// presignValidity returns how long a presigned URL reported as valid for ttl
+// is signed for. S3 checks the URL against its own clock, so a server clock
+// running behind would expire links early; the clock skew tolerance is added
+// within the 7 day limit of S3, while results keep reporting the nominal
+// expiry.
+func (s *S3Storage) presignValidity(ttl time.Duration) time.Duration {
+	return min(ttl+s.clockSkew, MaxLinkTTL)
+}
+
 // Close stops the cleanup routine
 func (s *S3Storage) Close() error {
 	close(s.stopCleanup)
@@ -225,6 +378,12 @@ func (s *S3Storage) IsRemote() bool {
 	return true
 }
 
+// Ping checks that the bucket is reachable, keeping a pooled connection open
+func (s *S3Storage) Ping(ctx context.Context) error {
+	_, err := s.client.BucketExists(ctx, s.bucket)
+	return err
+}
+
 // startCleanupRoutine periodically cleans up expired objects
 func (s *S3Storage) startCleanupRoutine() {
 	ticker := time.NewTicker(s.cleanupInterval)
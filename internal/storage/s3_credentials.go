@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 credential providers
+const (
+	S3CredentialsStatic      = "static"       // S3_ACCESS_KEY_ID / S3_SECRET_ACCESS_KEY
+	S3CredentialsDefault     = "default"      // AWS environment variables, shared credentials file, then IAM role
+	S3CredentialsIAM         = "iam"          // IAM role from the environment (IRSA, ECS, EKS Pod Identity) or EC2 instance metadata
+	S3CredentialsWebIdentity = "web_identity" // STS AssumeRoleWithWebIdentity with a projected token file
+	S3CredentialsAssumeRole  = "assume_role"  // STS AssumeRole using the static keys as source credentials
+)
+
+// S3 request signature versions
+const (
+	S3SignatureV4 = "v4" // AWS Signature Version 4, supported by AWS, R2, B2, MinIO and most others
+	S3SignatureV2 = "v2" // Legacy Signature Version 2, for older stores without v4 support
+)
+
+// s3Credentials builds the credential provider selected by cfg.Credentials,
+// signing with cfg.SignatureVersion. Temporary credentials are refreshed by
+// minio before they expire.
+func s3Credentials(cfg S3Config) (*credentials.Credentials, error) {
+	creds, err := s3Keys(cfg)
+	if err != nil {
+		return nil, err
+	}
+	switch cfg.SignatureVersion {
+	case "", S3SignatureV4:
+		return creds, nil
+	case S3SignatureV2:
+		return credentials.New(&signerOverride{creds: creds, signer: credentials.SignatureV2}), nil
+	default:
+		return nil, fmt.Errorf("unknown S3 signature version %q", cfg.SignatureVersion)
+	}
+}
+
+// s3Keys builds the provider of the keys selected by cfg.Credentials
+func s3Keys(cfg S3Config) (*credentials.Credentials, error) {
+	switch cfg.Credentials {
+	case "", S3CredentialsStatic:
+		return credentials.NewStaticV4(cfg.AccessKeyID, cfg.SecretAccessKey, ""), nil
+
+	case S3CredentialsDefault:
+		return credentials.NewChainCredentials([]credentials.Provider{
+			&credentials.EnvAWS{},
+			&credentials.FileAWSCredentials{},
+			&credentials.IAM{Region: cfg.Region},
+		}), nil
+
+	case S3CredentialsIAM:
+		return credentials.New(&credentials.IAM{Region: cfg.Region}), nil
+
+	case S3CredentialsWebIdentity:
+		if cfg.WebIdentityTokenFile == "" || cfg.RoleARN == "" {
+			return nil, fmt.Errorf("web_identity credentials require a token file and role ARN")
+		}
+		iam := &credentials.IAM{Endpoint: cfg.STSEndpoint, Region: cfg.Region}
+		iam.EKSIdentity.TokenFile = cfg.WebIdentityTokenFile
+		iam.EKSIdentity.RoleARN = cfg.RoleARN
+		iam.EKSIdentity.RoleSessionName = cfg.RoleSessionName
+		return credentials.New(iam), nil
+
+	case S3CredentialsAssumeRole:
+		if cfg.RoleARN == "" {
+			return nil, fmt.Errorf("assume_role credentials require a role ARN")
+		}
+		stsEndpoint := cfg.STSEndpoint
+		if stsEndpoint == "" {
+			stsEndpoint = credentials.DefaultSTSRoleEndpoint
+		}
+		return credentials.NewSTSAssumeRole(stsEndpoint, credentials.STSAssumeRoleOptions{
+			AccessKey:       cfg.AccessKeyID,
+			SecretKey:       cfg.SecretAccessKey,
+			Location:        cfg.Region,
+			RoleARN:         cfg.RoleARN,
+			RoleSessionName: cfg.RoleSessionName,
+			ExternalID:      cfg.ExternalID,
+		})
+
+	default:
+		return nil, fmt.Errorf("unknown S3 credential provider %q", cfg.Credentials)
+	}
+}
+
+// signerOverride signs with a fixed signature version, whichever provider
+// supplies the keys
+type signerOverride struct {
+	creds  *credentials.Credentials
+	signer credentials.SignatureType
+}
+
+func (p *signerOverride) RetrieveWithCredContext(cc *credentials.CredContext) (credentials.Value, error) {
+	value, err := p.creds.GetWithContext(cc)
+	if err != nil {
+		return value, err
+	}
+	value.SignerType = p.signer
+	return value, nil
+}
+
+func (p *signerOverride) Retrieve() (credentials.Value, error) {
+	return p.RetrieveWithCredContext(nil)
+}
+
+// IsExpired defers to the wrapped credentials, which refresh themselves
+func (p *signerOverride) IsExpired() bool {
+	return p.creds.IsExpired()
+}
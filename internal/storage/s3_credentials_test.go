@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+func TestS3CredentialsStatic(t *testing.T) {
+	creds, err := s3Credentials(S3Config{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := creds.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.AccessKeyID != "AKID" || value.SecretAccessKey != "secret" {
+		t.Errorf("static credentials = %+v", value)
+	}
+}
+
+func TestS3CredentialsProviders(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     S3Config
+		wantErr bool
+	}{
+		{"default chain", S3Config{Credentials: S3CredentialsDefault}, false},
+		{"iam", S3Config{Credentials: S3CredentialsIAM, Region: "eu-west-1"}, false},
+		{"web identity", S3Config{Credentials: S3CredentialsWebIdentity, WebIdentityTokenFile: "/var/run/token", RoleARN: "arn:aws:iam::123:role/media"}, false},
+		{"web identity without token file", S3Config{Credentials: S3CredentialsWebIdentity, RoleARN: "arn:aws:iam::123:role/media"}, true},
+		{"assume role", S3Config{Credentials: S3CredentialsAssumeRole, AccessKeyID: "AKID", SecretAccessKey: "secret", RoleARN: "arn:aws:iam::123:role/media"}, false},
+		{"assume role without role", S3Config{Credentials: S3CredentialsAssumeRole, AccessKeyID: "AKID", SecretAccessKey: "secret"}, true},
+		{"assume role without source keys", S3Config{Credentials: S3CredentialsAssumeRole, RoleARN: "arn:aws:iam::123:role/media"}, true},
+		{"unknown", S3Config{Credentials: "ldap"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s3Credentials(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("s3Credentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestS3CredentialsSignatureVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    credentials.SignatureType
+		wantErr bool
+	}{
+		{"", credentials.SignatureV4, false},
+		{S3SignatureV4, credentials.SignatureV4, false},
+		{S3SignatureV2, credentials.SignatureV2, false},
+		{"v3", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			creds, err := s3Credentials(S3Config{AccessKeyID: "AKID", SecretAccessKey: "secret", SignatureVersion: tt.version})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("s3Credentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			value, err := creds.Get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value.SignerType != tt.want || value.AccessKeyID != "AKID" {
+				t.Errorf("credentials = %+v, want signer %v", value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// lifecycleRuleID identifies the expiration rule this server manages, so it
+// can be updated without touching other rules of the bucket
+const lifecycleRuleID = "gemini-mcp-object-ttl"
+
+// configureLifecycle installs a bucket lifecycle rule that expires objects
+// after the object TTL, so the bucket does not have to be listed to clean it
+// up. Other lifecycle rules of the bucket are kept.
+func (s *S3Storage) configureLifecycle(ctx context.Context) error {
+	current, err := s.client.GetBucketLifecycle(ctx, s.bucket)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code != "NoSuchLifecycleConfiguration" {
+			return fmt.Errorf("failed to read bucket lifecycle: %w", err)
+		}
+		current = lifecycle.NewConfiguration()
+	}
+
+	days := lifecycleDays(s.objectTTL)
+	if err := s.client.SetBucketLifecycle(ctx, s.bucket, withExpiryRule(current, days)); err != nil {
+		return fmt.Errorf("failed to set bucket lifecycle: %w", err)
+	}
+	if time.Duration(days)*24*time.Hour != s.objectTTL {
+		log.Printf("Warning: lifecycle rules count whole days, so objects are kept for %d day(s) instead of %v", days, s.objectTTL)
+	}
+	log.Printf("S3 bucket %s expires objects after %d day(s) through lifecycle rule %s", s.bucket, days, lifecycleRuleID)
+	return nil
+}
+
+// lifecycleDays rounds an object TTL up to the whole days lifecycle rules use
+func lifecycleDays(ttl time.Duration) int {
+	return max(int(math.Ceil(ttl.Hours()/24)), 1)
+}
+
+// withExpiryRule returns config with this server's expiration rule set to
+// days, replacing an earlier version of the rule
+func withExpiryRule(config *lifecycle.Configuration, days int) *lifecycle.Configuration {
+	updated := lifecycle.NewConfiguration()
+	for _, rule := range config.Rules {
+		if rule.ID != lifecycleRuleID {
+			updated.Rules = append(updated.Rules, rule)
+		}
+	}
+	updated.Rules = append(updated.Rules, lifecycle.Rule{
+		ID:         lifecycleRuleID,
+		Status:     "Enabled",
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(days)},
+		AbortIncompleteMultipartUpload: lifecycle.AbortIncompleteMultipartUpload{
+			DaysAfterInitiation: lifecycle.ExpirationDays(1),
+		},
+	})
+	return updated
+}
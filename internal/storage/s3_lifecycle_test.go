@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+func TestLifecycleDays(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want int
+	}{
+		{time.Hour, 1},
+		{24 * time.Hour, 1},
+		{25 * time.Hour, 2},
+		{7 * 24 * time.Hour, 7},
+	}
+	for _, tt := range tests {
+		if got := lifecycleDays(tt.ttl); got != tt.want {
+			t.Errorf("lifecycleDays(%v) = %d, want %d", tt.ttl, got, tt.want)
+		}
+	}
+}
+
+func TestWithExpiryRule(t *testing.T) {
+	current := lifecycle.NewConfiguration()
+	current.Rules = []lifecycle.Rule{
+		{ID: "archive", Status: "Enabled", Expiration: lifecycle.Expiration{Days: 30}},
+		{ID: lifecycleRuleID, Status: "Enabled", Expiration: lifecycle.Expiration{Days: 1}},
+	}
+
+	updated := withExpiryRule(current, 3)
+	if len(updated.Rules) != 2 {
+		t.Fatalf("got %d rules, want the other rule kept and ours replaced", len(updated.Rules))
+	}
+	if updated.Rules[0].ID != "archive" || updated.Rules[0].Expiration.Days != 30 {
+		t.Errorf("other rule changed: %+v", updated.Rules[0])
+	}
+	if rule := updated.Rules[1]; rule.ID != lifecycleRuleID || rule.Expiration.Days != 3 || rule.Status != "Enabled" {
+		t.Errorf("expiry rule = %+v", rule)
+	}
+	if len(current.Rules) != 2 || current.Rules[1].Expiration.Days != 1 {
+		t.Error("withExpiryRule modified its input")
+	}
+}
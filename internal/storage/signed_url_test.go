@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner("secret", time.Hour)
+	path, expiresAt := signer.SignedPath("gemini_image_abc 1.png")
+	if time.Until(expiresAt) <= 0 {
+		t.Fatalf("expected expiry in the future, got %v", expiresAt)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := strings.TrimPrefix(u.Path, FilesPathPrefix)
+	if key != "gemini_image_abc 1.png" {
+		t.Fatalf("unexpected key %q", key)
+	}
+	q := u.Query()
+	if err := signer.Verify(key, q.Get("expires"), q.Get("signature")); err != nil {
+		t.Fatalf("expected valid signature: %v", err)
+	}
+
+	if err := signer.Verify("other.png", q.Get("expires"), q.Get("signature")); err == nil {
+		t.Error("expected signature for a different key to be rejected")
+	}
+	if err := NewURLSigner("other", time.Hour).Verify(key, q.Get("expires"), q.Get("signature")); err == nil {
+		t.Error("expected signature from a different secret to be rejected")
+	}
+
+	expired, _ := NewURLSigner("secret", -time.Minute).SignedPath(key)
+	u, _ = url.Parse(expired)
+	if err := signer.Verify(key, u.Query().Get("expires"), u.Query().Get("signature")); err == nil {
+		t.Error("expected expired URL to be rejected")
+	}
+}
+
+func TestURLSignerCustomTTL(t *testing.T) {
+	signer := NewURLSigner("secret", time.Hour)
+	path, expiresAt := signer.SignedPathTTL("gemini_image_abc.png", 5*time.Minute)
+	if remaining := time.Until(expiresAt); remaining > 5*time.Minute || remaining < 4*time.Minute {
+		t.Errorf("URL expires in %v, want about 5m", remaining)
+	}
+
+	u, err := url.Parse(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.Verify("gemini_image_abc.png", u.Query().Get("expires"), u.Query().Get("signature")); err != nil {
+		t.Errorf("expected valid signature: %v", err)
+	}
+}
+
+func TestURLSignerClockSkew(t *testing.T) {
+	expired, _ := NewURLSigner("secret", -time.Minute).SignedPath("gemini_image_abc.png")
+	u, _ := url.Parse(expired)
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	signer := NewURLSigner("secret", time.Hour)
+	if err := signer.Verify("gemini_image_abc.png", expires, signature); !errors.Is(err, ErrURLExpired) {
+		t.Errorf("Verify = %v, want ErrURLExpired", err)
+	}
+	forged := strings.Repeat("0", len(signature))
+	if err := signer.Verify("gemini_image_abc.png", expires, forged); errors.Is(err, ErrURLExpired) {
+		t.Error("expected a forged expired URL to be reported as invalid, not expired")
+	}
+
+	signer.SetClockSkew(5 * time.Minute)
+	if err := signer.Verify("gemini_image_abc.png", expires, signature); err != nil {
+		t.Errorf("expected a URL expired within the clock skew to be accepted: %v", err)
+	}
+}
+
+func TestPresignValidity(t *testing.T) {
+	s := &S3Storage{clockSkew: 5 * time.Minute}
+	if got := s.presignValidity(time.Hour); got != time.Hour+5*time.Minute {
+		t.Errorf("presignValidity(1h) = %v", got)
+	}
+	if got := s.presignValidity(MaxLinkTTL); got != MaxLinkTTL {
+		t.Errorf("presignValidity(7d) = %v, want the S3 limit", got)
+	}
+}
@@ -2,9 +2,16 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
 	"time"
 )
 
+// ErrNotFound is returned, wrapped, by Retrieve for objects that do not exist
+var ErrNotFound = errors.New("file not found")
+
 // StorageResult represents the result of a storage operation
 type StorageResult struct {
 	// Location is the access URL/path for the stored content
@@ -13,8 +20,13 @@ type StorageResult struct {
 	Location string
 
 	// ObjectKey is the storage path (e.g., "2024/12/23/gemini_image_abc123.png")
+	// Under WithKeyHint it is the stable key (e.g., "project/hero_latest.png")
 	ObjectKey string
 
+	// VersionKey is the key of the immutable copy stored under WithKeyHint
+	// (e.g., "project/hero_abc123.png"; empty otherwise)
+	VersionKey string
+
 	// ContentHash is the SHA256 hash of the content (first 16 chars used in filename)
 	ContentHash string
 
@@ -26,6 +38,29 @@ type StorageResult struct {
 
 	// Size is the content size in bytes
 	Size int64
+
+	// Deduplicated is true if identical content was already stored under
+	// the content-addressed key and was not written again
+	Deduplicated bool
+
+	// Thumbnail is the stored JPEG preview of the content when
+	// STORE_THUMBNAILS is enabled (nil if none was generated)
+	Thumbnail *StorageResult
+}
+
+// ObjectInfo describes a stored object returned by List
+type ObjectInfo struct {
+	// ObjectKey is the storage path, as returned by Store
+	ObjectKey string
+
+	// Size is the content size in bytes
+	Size int64
+
+	// LastModified is when the object was written
+	LastModified time.Time
+
+	// ExpiresAt is when the object is deleted for its age (nil if it is kept)
+	ExpiresAt *time.Time
 }
 
 // Storage defines the interface for storing generated content
@@ -44,6 +79,17 @@ type Storage interface {
 	// Delete removes an object by its key
 	Delete(ctx context.Context, objectKey string) error
 
+	// Move renames an object, replacing any object stored under newKey. The
+	// moved object counts as last modified at the time of the move.
+	Move(ctx context.Context, objectKey, newKey string) error
+
+	// List returns all objects whose key starts with prefix (empty for all objects)
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+
+	// ShareLink returns a download URL for an existing object that expires
+	// after ttl. Fails for storage that cannot serve URLs (local stdio mode).
+	ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (url string, expiresAt time.Time, err error)
+
 	// Close cleans up any resources (stops cleanup goroutines, etc.)
 	Close() error
 
@@ -51,6 +97,26 @@ type Storage interface {
 	IsRemote() bool
 }
 
+// ValidateObjectKey rejects keys that could escape the storage root, such as
+// absolute paths or keys containing ".." segments
+func ValidateObjectKey(objectKey string) error {
+	if objectKey == "" {
+		return fmt.Errorf("object key is required")
+	}
+	if strings.HasPrefix(objectKey, "/") || strings.Contains(objectKey, "\\") {
+		return fmt.Errorf("invalid object key %q", objectKey)
+	}
+	for _, segment := range strings.Split(objectKey, "/") {
+		if segment == ".." {
+			return fmt.Errorf("invalid object key %q", objectKey)
+		}
+	}
+	if path.Clean(objectKey) != objectKey {
+		return fmt.Errorf("invalid object key %q", objectKey)
+	}
+	return nil
+}
+
 // extensionFromMIME returns the file extension for a given MIME type
 func ExtensionFromMIME(mimeType string) string {
 	switch mimeType {
@@ -62,10 +128,20 @@ func ExtensionFromMIME(mimeType string) string {
 		return ".webp"
 	case "image/gif":
 		return ".gif"
+	case "image/svg+xml":
+		return ".svg"
+	case "image/x-icon", "image/vnd.microsoft.icon":
+		return ".ico"
+	case "image/icns":
+		return ".icns"
+	case "application/zip":
+		return ".zip"
 	case "video/mp4":
 		return ".mp4"
 	case "video/webm":
 		return ".webm"
+	case "audio/wav":
+		return ".wav"
 	case "application/json":
 		return ".json"
 	default:
@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// Names of the built-in storage targets
+const (
+	TargetLocal = "local" // The output directory of the server
+	TargetS3    = "s3"    // S3_BUCKET
+)
+
+var targetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// ParseTargets parses STORAGE_TARGETS entries into a map of target names to
+// S3 buckets: "local" adds the local output directory (with an empty
+// bucket), "name=bucket" another bucket on the configured S3 endpoint
+func ParseTargets(entries []string) (map[string]string, error) {
+	targets := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		name, bucket, hasBucket := strings.Cut(entry, "=")
+		name, bucket = strings.TrimSpace(name), strings.TrimSpace(bucket)
+		switch {
+		case name == TargetLocal && !hasBucket:
+		case !hasBucket || bucket == "":
+			return nil, fmt.Errorf("invalid storage target %q (expected local or name=bucket)", entry)
+		case name == TargetLocal || name == TargetS3:
+			return nil, fmt.Errorf("storage target name %q is reserved", name)
+		case !targetNamePattern.MatchString(name):
+			return nil, fmt.Errorf("invalid storage target name %q (use lowercase letters, digits, '-' and '_')", name)
+		}
+		if _, ok := targets[name]; ok {
+			return nil, fmt.Errorf("duplicate storage target %q", name)
+		}
+		targets[name] = bucket
+	}
+	return targets, nil
+}
+
+type targetKey struct{}
+
+// WithTarget returns a context under which content is stored in the named
+// storage target
+func WithTarget(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, targetKey{}, name)
+}
+
+// TargetName returns the storage target requested for ctx, or "" if none was
+func TargetName(ctx context.Context) string {
+	name, _ := ctx.Value(targetKey{}).(string)
+	return name
+}
+
+// Targets routes storage operations to one of several named backends. New
+// content goes to the target named by WithTarget, or the default target.
+// Existing objects are looked up in the named target, or else in every
+// target, the default first, so keys returned by any target keep working
+// without naming it again.
+type Targets struct {
+	names    []string // Default first, then the others sorted
+	backends map[string]Storage
+}
+
+// NewTargets creates a router over backends whose default is defaultName
+func NewTargets(defaultName string, backends map[string]Storage) *Targets {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		if name != defaultName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return &Targets{names: append([]string{defaultName}, names...), backends: backends}
+}
+
+// Names returns the names of the targets, the default first
+func (t *Targets) Names() []string {
+	return slices.Clone(t.names)
+}
+
+// Validate checks that name is a configured target ("" selects the default)
+func (t *Targets) Validate(name string) error {
+	if _, ok := t.backends[name]; !ok && name != "" {
+		return toolerr.Errorf(toolerr.InvalidInput, "unknown storage_target %q (available: %s)", name, strings.Join(t.names, ", "))
+	}
+	return nil
+}
+
+// target returns the backend named for ctx, or the default
+func (t *Targets) target(ctx context.Context) (Storage, error) {
+	name := TargetName(ctx)
+	if name == "" {
+		return t.backends[t.names[0]], nil
+	}
+	if err := t.Validate(name); err != nil {
+		return nil, err
+	}
+	return t.backends[name], nil
+}
+
+// candidates returns the backends an existing object is looked up in
+func (t *Targets) candidates(ctx context.Context) ([]Storage, error) {
+	if TargetName(ctx) != "" {
+		backend, err := t.target(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return []Storage{backend}, nil
+	}
+	backends := make([]Storage, len(t.names))
+	for i, name := range t.names {
+		backends[i] = t.backends[name]
+	}
+	return backends, nil
+}
+
+// locate returns the backend holding objectKey, or the first candidate if
+// no backend lists it
+func (t *Targets) locate(ctx context.Context, objectKey string) (Storage, error) {
+	backends, err := t.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, backend := range backends[:len(backends)-1] {
+		objects, err := backend.List(ctx, objectKey)
+		if err != nil {
+			continue
+		}
+		for _, object := range objects {
+			if object.ObjectKey == objectKey {
+				return backend, nil
+			}
+		}
+	}
+	return backends[len(backends)-1], nil
+}
+
+// Store saves content in the target named for ctx
+func (t *Targets) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*StorageResult, error) {
+	backend, err := t.target(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Store(ctx, data, mimeType, prefix)
+}
+
+// Retrieve downloads an object from the first target that has it
+func (t *Targets) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
+	backends, err := t.candidates(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	var firstErr error
+	for _, backend := range backends {
+		localPath, cleanup, err := backend.Retrieve(ctx, objectKey)
+		if err == nil {
+			return localPath, cleanup, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", nil, firstErr
+}
+
+// Delete removes an object from the target holding it
+func (t *Targets) Delete(ctx context.Context, objectKey string) error {
+	backend, err := t.locate(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	return backend.Delete(ctx, objectKey)
+}
+
+// Move renames an object within the target holding it
+func (t *Targets) Move(ctx context.Context, objectKey, newKey string) error {
+	backend, err := t.locate(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	return backend.Move(ctx, objectKey, newKey)
+}
+
+// List returns the objects of the target named for ctx, or of every target
+func (t *Targets) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	backends, err := t.candidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var objects []ObjectInfo
+	for _, backend := range backends {
+		listed, err := backend.List(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range listed {
+			if !seen[object.ObjectKey] {
+				seen[object.ObjectKey] = true
+				objects = append(objects, object)
+			}
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return objects[i].ObjectKey < objects[j].ObjectKey })
+	return objects, nil
+}
+
+// ShareLink returns a download URL from the first target that has the object
+func (t *Targets) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	backends, err := t.candidates(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var firstErr error
+	for _, backend := range backends {
+		url, expiresAt, err := backend.ShareLink(ctx, objectKey, ttl)
+		if err == nil {
+			return url, expiresAt, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return "", time.Time{}, firstErr
+}
+
+// Close closes every target
+func (t *Targets) Close() error {
+	var errs []error
+	for _, name := range t.names {
+		errs = append(errs, t.backends[name].Close())
+	}
+	return errors.Join(errs...)
+}
+
+// IsRemote reports whether the default target is remote. All targets must
+// agree, which is why targets are only offered in HTTP mode, where local
+// files are served over /files.
+func (t *Targets) IsRemote() bool {
+	return t.backends[t.names[0]].IsRemote()
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+func TestParseTargets(t *testing.T) {
+	targets, err := ParseTargets([]string{"local", " finals = gemini-finals "})
+	if err != nil {
+		t.Fatalf("ParseTargets: %v", err)
+	}
+	if len(targets) != 2 || targets["local"] != "" || targets["finals"] != "gemini-finals" {
+		t.Errorf("unexpected targets: %v", targets)
+	}
+
+	for _, entries := range [][]string{
+		{"finals"},
+		{"finals="},
+		{"s3=other-bucket"},
+		{"local=bucket"},
+		{"Finals=bucket"},
+		{"a=one", "a=two"},
+	} {
+		if _, err := ParseTargets(entries); err == nil {
+			t.Errorf("ParseTargets(%q): expected an error", entries)
+		}
+	}
+}
+
+func TestTargetsRouting(t *testing.T) {
+	ctx := context.Background()
+	drafts, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	finals, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := NewTargets("drafts", map[string]Storage{"drafts": drafts, "finals": finals})
+	if names := targets.Names(); len(names) != 2 || names[0] != "drafts" {
+		t.Errorf("Names = %v, want the default first", names)
+	}
+
+	draft, err := targets.Store(ctx, []byte("draft"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	final, err := targets.Store(WithTarget(ctx, "finals"), []byte("final"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store in finals: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(finals.baseDir, final.ObjectKey)); err != nil {
+		t.Errorf("final not stored in the finals target: %v", err)
+	}
+
+	// Objects are found without naming their target again
+	localPath, _, err := targets.Retrieve(ctx, final.ObjectKey)
+	if err != nil {
+		t.Fatalf("Retrieve: %v", err)
+	}
+	if data, _ := os.ReadFile(localPath); string(data) != "final" {
+		t.Errorf("retrieved %q, want final", data)
+	}
+	if _, _, err := targets.Retrieve(WithTarget(ctx, "finals"), draft.ObjectKey); err == nil {
+		t.Error("expected the draft to be missing from the finals target")
+	}
+	if objects, _ := targets.List(ctx, ""); len(objects) != 2 {
+		t.Errorf("List = %d objects, want 2 across targets", len(objects))
+	}
+
+	if err := targets.Delete(ctx, final.ObjectKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if objects, _ := finals.List(ctx, ""); len(objects) != 0 {
+		t.Errorf("final not deleted: %v", objects)
+	}
+
+	_, err = targets.Store(WithTarget(ctx, "archive"), []byte("x"), "image/png", "gemini_image")
+	var toolErr *toolerr.Error
+	if !errors.As(err, &toolErr) || toolErr.Code != toolerr.InvalidInput {
+		t.Errorf("Store in an unknown target = %v, want an invalid_input error", err)
+	}
+}
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// TenantRoot is the key prefix under which each tenant's objects are stored,
+// e.g. "tenants/acme/2024/12/23/gemini_image_abc123.png"
+const TenantRoot = "tenants/"
+
+// tenantPattern is the form of tenant names, which become a key segment
+var tenantPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+type tenantKey struct{}
+
+// WithTenant returns a context under which objects are stored below the
+// tenant's prefix and only keys below it can be read, deleted or listed (see
+// NewTenantStorage). An empty tenant leaves ctx unscoped. The tenant must
+// pass ValidateTenant.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// ValidateTenant checks that tenant can be used as a key segment
+func ValidateTenant(tenant string) error {
+	if !tenantPattern.MatchString(tenant) {
+		return fmt.Errorf("invalid tenant %q (use up to 64 letters, digits, '.', '_' and '-', starting with a letter or digit)", tenant)
+	}
+	return nil
+}
+
+// TenantPrefix returns the key prefix of ctx's tenant, such as
+// "tenants/acme/", or "" if ctx has none
+func TenantPrefix(ctx context.Context) string {
+	if tenant, _ := ctx.Value(tenantKey{}).(string); tenant != "" {
+		return TenantRoot + tenant + "/"
+	}
+	return ""
+}
+
+// CheckTenantKey rejects object keys outside the prefix of ctx's tenant
+func CheckTenantKey(ctx context.Context, objectKey string) error {
+	prefix := TenantPrefix(ctx)
+	if prefix == "" {
+		return nil
+	}
+	// Keys such as "tenants/acme/../globex/x" must not escape the prefix
+	if err := ValidateObjectKey(objectKey); err != nil {
+		return toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+	if strings.HasPrefix(objectKey, prefix) {
+		return nil
+	}
+	return toolerr.Errorf(toolerr.InvalidInput, "object key %s belongs to another tenant or was not stored for this caller", objectKey)
+}
+
+// tenantStorage confines callers with a tenant to their own objects
+type tenantStorage struct {
+	Storage
+}
+
+// NewTenantStorage wraps s so that calls under WithTenant can only reach the
+// tenant's objects. Objects of callers without a tenant are not restricted.
+// Keys of new objects get the tenant prefix from the backends' Store.
+func NewTenantStorage(s Storage) Storage {
+	return tenantStorage{Storage: s}
+}
+
+func (t tenantStorage) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
+	if err := CheckTenantKey(ctx, objectKey); err != nil {
+		return "", nil, err
+	}
+	return t.Storage.Retrieve(ctx, objectKey)
+}
+
+func (t tenantStorage) Delete(ctx context.Context, objectKey string) error {
+	if err := CheckTenantKey(ctx, objectKey); err != nil {
+		return err
+	}
+	return t.Storage.Delete(ctx, objectKey)
+}
+
+func (t tenantStorage) Move(ctx context.Context, objectKey, newKey string) error {
+	for _, key := range []string{objectKey, newKey} {
+		if err := CheckTenantKey(ctx, key); err != nil {
+			return err
+		}
+	}
+	return t.Storage.Move(ctx, objectKey, newKey)
+}
+
+// List lists prefix within the tenant's objects, whether or not prefix
+// already starts with the tenant prefix
+func (t tenantStorage) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	if tenantPrefix := TenantPrefix(ctx); !strings.HasPrefix(prefix, tenantPrefix) {
+		prefix = tenantPrefix + prefix
+	}
+	return t.Storage.List(ctx, prefix)
+}
+
+func (t tenantStorage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	if err := CheckTenantKey(ctx, objectKey); err != nil {
+		return "", time.Time{}, err
+	}
+	return t.Storage.ShareLink(ctx, objectKey, ttl)
+}
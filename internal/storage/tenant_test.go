@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTenantStorage(t *testing.T) {
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStorage: %v", err)
+	}
+	s := NewTenantStorage(local)
+	acme := WithTenant(context.Background(), "acme")
+	globex := WithTenant(context.Background(), "globex")
+
+	result, err := s.Store(acme, []byte("acme"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if !strings.HasPrefix(result.ObjectKey, "tenants/acme/gemini_image_") {
+		t.Fatalf("ObjectKey = %q, want it under the tenant prefix", result.ObjectKey)
+	}
+	hinted, err := s.Store(WithKeyHint(acme, "project/hero"), []byte("hero"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	if hinted.ObjectKey != "tenants/acme/project/hero_latest.png" {
+		t.Errorf("hinted ObjectKey = %q, want it under the tenant prefix", hinted.ObjectKey)
+	}
+
+	if _, _, err := s.Retrieve(acme, result.ObjectKey); err != nil {
+		t.Errorf("Retrieve by owner: %v", err)
+	}
+	if _, _, err := s.Retrieve(context.Background(), result.ObjectKey); err != nil {
+		t.Errorf("Retrieve without a tenant: %v", err)
+	}
+	for _, key := range []string{result.ObjectKey, "tenants/globex/../acme/" + strings.TrimPrefix(result.ObjectKey, "tenants/acme/")} {
+		if _, _, err := s.Retrieve(globex, key); err == nil {
+			t.Errorf("Retrieve(%q) by another tenant succeeded", key)
+		}
+	}
+	if err := s.Delete(globex, result.ObjectKey); err == nil {
+		t.Error("Delete by another tenant succeeded")
+	}
+
+	objects, err := s.List(globex, "")
+	if err != nil || len(objects) != 0 {
+		t.Errorf("List by another tenant = %v, %v; want nothing", objects, err)
+	}
+	objects, err = s.List(acme, "gemini_image")
+	if err != nil || len(objects) != 1 || objects[0].ObjectKey != result.ObjectKey {
+		t.Errorf("List by owner = %v, %v; want %s", objects, err, result.ObjectKey)
+	}
+}
+
+func TestValidateTenant(t *testing.T) {
+	for _, tenant := range []string{"acme", "team-1", "example.com"} {
+		if err := ValidateTenant(tenant); err != nil {
+			t.Errorf("ValidateTenant(%q) = %v", tenant, err)
+		}
+	}
+	for _, tenant := range []string{"", "..", "a/b", ".hidden", strings.Repeat("a", 65)} {
+		if err := ValidateTenant(tenant); err == nil {
+			t.Errorf("ValidateTenant(%q) accepted an invalid tenant", tenant)
+		}
+	}
+}
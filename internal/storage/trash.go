@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// TrashPrefix is the key prefix deleted objects are moved under until they
+// are purged. The original key is escaped into a single segment, e.g.
+// "_trash/tenants%2Facme%2F2024%2F12%2F23%2Fgemini_image_abc123.png", so
+// that one listing covers every tenant and directory.
+const TrashPrefix = "_trash/"
+
+// TrashKey returns the key objectKey is kept under while in the trash
+func TrashKey(objectKey string) string {
+	return TrashPrefix + url.PathEscape(objectKey)
+}
+
+// TrashedKey returns the original key of a trash key, or false if trashKey
+// is not one
+func TrashedKey(trashKey string) (string, bool) {
+	escaped, ok := strings.CutPrefix(trashKey, TrashPrefix)
+	if !ok || escaped == "" {
+		return "", false
+	}
+	objectKey, err := url.PathUnescape(escaped)
+	if err != nil {
+		return "", false
+	}
+	return objectKey, true
+}
+
+// TrashedObject describes an object waiting in the trash
+type TrashedObject struct {
+	ObjectKey string    `json:"object_key"` // Key the object is restored to
+	TrashKey  string    `json:"trash_key"`
+	Size      int64     `json:"size"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// Trash turns deletes into moves below TrashPrefix, which are purged once
+// they are older than the retention period. A zero retention disables the
+// trash and deletes objects at once.
+type Trash struct {
+	storage   Storage
+	retention time.Duration
+}
+
+// NewTrash creates a trash over s keeping deleted objects for retention
+func NewTrash(s Storage, retention time.Duration) *Trash {
+	return &Trash{storage: s, retention: retention}
+}
+
+// Enabled reports whether deleted objects are kept for restoring
+func (t *Trash) Enabled() bool {
+	return t.retention > 0
+}
+
+// Retention returns how long deleted objects are kept
+func (t *Trash) Retention() time.Duration {
+	return t.retention
+}
+
+// Delete moves objectKey to the trash, or deletes it if the trash is
+// disabled. An object already in the trash is deleted for good.
+func (t *Trash) Delete(ctx context.Context, objectKey string) error {
+	if !t.Enabled() || strings.HasPrefix(objectKey, TrashPrefix) {
+		return t.storage.Delete(ctx, objectKey)
+	}
+	return t.storage.Move(ctx, objectKey, TrashKey(objectKey))
+}
+
+// Restore moves a trashed object back to objectKey. It refuses to replace an
+// object stored under objectKey since it was deleted.
+func (t *Trash) Restore(ctx context.Context, objectKey string) error {
+	if err := ValidateObjectKey(objectKey); err != nil {
+		return toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+	objects, err := t.storage.List(ctx, objectKey)
+	if err != nil {
+		return err
+	}
+	for _, object := range objects {
+		if object.ObjectKey == objectKey {
+			return toolerr.Errorf(toolerr.InvalidInput, "%s already exists; delete it before restoring the trashed copy", objectKey)
+		}
+	}
+	err = t.storage.Move(ctx, TrashKey(objectKey), objectKey)
+	if errors.Is(err, ErrNotFound) {
+		return toolerr.Errorf(toolerr.InvalidInput, "%s is not in the trash (it may have been purged)", objectKey)
+	}
+	return err
+}
+
+// List returns the trashed objects, oldest first
+func (t *Trash) List(ctx context.Context) ([]TrashedObject, error) {
+	objects, err := t.storage.List(ctx, TrashPrefix)
+	if err != nil {
+		return nil, err
+	}
+	trashed := make([]TrashedObject, 0, len(objects))
+	for _, object := range objects {
+		objectKey, ok := TrashedKey(object.ObjectKey)
+		if !ok {
+			continue
+		}
+		trashed = append(trashed, TrashedObject{
+			ObjectKey: objectKey,
+			TrashKey:  object.ObjectKey,
+			Size:      object.Size,
+			DeletedAt: object.LastModified,
+			PurgeAt:   object.LastModified.Add(t.retention),
+		})
+	}
+	return trashed, nil
+}
+
+// Purge deletes the trashed objects older than the retention period and
+// returns how many it deleted
+func (t *Trash) Purge(ctx context.Context) (int, error) {
+	trashed, err := t.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-t.retention)
+	var purged int
+	var errs []error
+	for _, object := range trashed {
+		if !object.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := t.storage.Delete(ctx, object.TrashKey); err != nil {
+			errs = append(errs, fmt.Errorf("failed to purge %s: %w", object.TrashKey, err))
+			continue
+		}
+		purged++
+	}
+	return purged, errors.Join(errs...)
+}
+
+// Run purges the trash every interval until ctx is done
+func (t *Trash) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := t.Purge(ctx)
+			if err != nil {
+				log.Printf("Trash purge: %v", err)
+			}
+			if purged > 0 {
+				log.Printf("Purged %d object(s) from the trash", purged)
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+func TestTrashKeyRoundTrip(t *testing.T) {
+	for _, key := range []string{"gemini_image_abc.png", "tenants/acme/2024/12/23/veo video.mp4"} {
+		trashKey := TrashKey(key)
+		if err := ValidateObjectKey(trashKey); err != nil {
+			t.Errorf("TrashKey(%q) = %q is not a valid key: %v", key, trashKey, err)
+		}
+		if got, ok := TrashedKey(trashKey); !ok || got != key {
+			t.Errorf("TrashedKey(%q) = %q, %v; want %q", trashKey, got, ok, key)
+		}
+	}
+	if _, ok := TrashedKey("gemini_image_abc.png"); ok {
+		t.Error("TrashedKey accepted a key outside the trash")
+	}
+}
+
+func TestTrashDeleteRestorePurge(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash := NewTrash(local, time.Hour)
+
+	result, err := local.Store(ctx, []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trash.Delete(ctx, result.ObjectKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(local.baseDir, result.ObjectKey)); !os.IsNotExist(err) {
+		t.Errorf("deleted object still in place: %v", err)
+	}
+	trashed, err := trash.List(ctx)
+	if err != nil || len(trashed) != 1 || trashed[0].ObjectKey != result.ObjectKey {
+		t.Fatalf("List = %v, %v; want the deleted object", trashed, err)
+	}
+
+	if err := trash.Restore(ctx, result.ObjectKey); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(local.baseDir, result.ObjectKey)); err != nil || string(data) != "image" {
+		t.Errorf("restored %q, %v; want image", data, err)
+	}
+	var toolErr *toolerr.Error
+	if err := trash.Restore(ctx, result.ObjectKey); !errors.As(err, &toolErr) || toolErr.Code != toolerr.InvalidInput {
+		t.Errorf("Restore of an existing object = %v, want an invalid_input error", err)
+	}
+
+	// Only objects past the retention period are purged
+	if err := trash.Delete(ctx, result.ObjectKey); err != nil {
+		t.Fatal(err)
+	}
+	if purged, err := trash.Purge(ctx); err != nil || purged != 0 {
+		t.Errorf("Purge = %d, %v; want nothing purged yet", purged, err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(local.baseDir, TrashKey(result.ObjectKey)), old, old)
+	if purged, err := trash.Purge(ctx); err != nil || purged != 1 {
+		t.Errorf("Purge = %d, %v; want 1", purged, err)
+	}
+	if err := trash.Restore(ctx, result.ObjectKey); !errors.As(err, &toolErr) || toolErr.Code != toolerr.InvalidInput {
+		t.Errorf("Restore of a purged object = %v, want an invalid_input error", err)
+	}
+}
+
+func TestTrashDisabled(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := local.Store(ctx, []byte("image"), "image/png", "gemini_image")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTrash(local, 0).Delete(ctx, result.ObjectKey); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if objects, _ := local.List(ctx, TrashPrefix); len(objects) != 0 {
+		t.Errorf("disabled trash kept %v", objects)
+	}
+}
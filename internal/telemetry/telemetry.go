@@ -0,0 +1,199 @@
+// Package telemetry reports anonymous, aggregate usage statistics - tool call
+// and error counts, version and platform - to an endpoint the operator
+// configures. It is off unless explicitly enabled, and never sends prompts,
+// outputs, file names, keys, tokens or caller identities.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Report is the JSON document POSTed to the endpoint. Counts cover the
+// period since the previous report.
+type Report struct {
+	InstanceID    string         `json:"instance_id"` // Random per process, so reports of one run can be grouped
+	Version       string         `json:"version"`
+	GoVersion     string         `json:"go_version"`
+	Platform      string         `json:"platform"`  // GOOS/GOARCH
+	Transport     string         `json:"transport"` // stdio, http or sse
+	Storage       string         `json:"storage"`   // local or s3
+	UptimeSeconds int64          `json:"uptime_seconds"`
+	PeriodSeconds int64          `json:"period_seconds"`
+	Calls         int            `json:"calls"`
+	Errors        int            `json:"errors"`
+	Tools         []ToolUsage    `json:"tools"`
+	ErrorCodes    map[string]int `json:"error_codes"` // Failed calls per error code (see internal/toolerr)
+	Timestamp     time.Time      `json:"timestamp"`
+}
+
+// ToolUsage is the call and error count of one tool
+type ToolUsage struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Errors int    `json:"errors"`
+}
+
+// Environment describes the deployment in reports
+type Environment struct {
+	Version   string
+	Transport string
+	Storage   string
+}
+
+// Reporter counts tool calls and periodically sends them to an endpoint
+type Reporter struct {
+	endpoint   string
+	interval   time.Duration
+	env        Environment
+	client     *http.Client
+	instanceID string
+	started    time.Time
+
+	mu         sync.Mutex
+	since      time.Time
+	tools      map[string]*ToolUsage
+	errorCodes map[string]int
+}
+
+// New creates a reporter sending to endpoint every interval once Run is called
+func New(endpoint string, interval time.Duration, env Environment) *Reporter {
+	b := make([]byte, 8)
+	rand.Read(b)
+	now := time.Now()
+	return &Reporter{
+		endpoint:   endpoint,
+		interval:   interval,
+		env:        env,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		instanceID: hex.EncodeToString(b),
+		started:    now,
+		since:      now,
+		tools:      make(map[string]*ToolUsage),
+		errorCodes: make(map[string]int),
+	}
+}
+
+// Record counts a call of tool; errorCode is empty for calls that succeeded
+func (r *Reporter) Record(tool, errorCode string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.tools[tool]
+	if !ok {
+		u = &ToolUsage{Name: tool}
+		r.tools[tool] = u
+	}
+	u.Calls++
+	if errorCode != "" {
+		u.Errors++
+		r.errorCodes[errorCode]++
+	}
+}
+
+// Run sends a report every interval until ctx is done, then sends the counts
+// of the last period
+func (r *Reporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.Flush(ctx)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			r.Flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Flush sends the counts since the previous report. Counts that could not be
+// sent are kept for the next report.
+func (r *Reporter) Flush(ctx context.Context) {
+	report := r.take()
+	if err := r.send(ctx, report); err != nil {
+		log.Printf("Warning: failed to send usage statistics: %v", err)
+		r.restore(report)
+	}
+}
+
+// take returns a report of the current period and starts a new one
+func (r *Reporter) take() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	report := Report{
+		InstanceID:    r.instanceID,
+		Version:       r.env.Version,
+		GoVersion:     runtime.Version(),
+		Platform:      runtime.GOOS + "/" + runtime.GOARCH,
+		Transport:     r.env.Transport,
+		Storage:       r.env.Storage,
+		UptimeSeconds: int64(now.Sub(r.started).Seconds()),
+		PeriodSeconds: int64(now.Sub(r.since).Seconds()),
+		Tools:         make([]ToolUsage, 0, len(r.tools)),
+		ErrorCodes:    r.errorCodes,
+		Timestamp:     now.UTC(),
+	}
+	for _, u := range r.tools {
+		report.Tools = append(report.Tools, *u)
+		report.Calls += u.Calls
+		report.Errors += u.Errors
+	}
+	sort.Slice(report.Tools, func(i, j int) bool { return report.Tools[i].Name < report.Tools[j].Name })
+	r.since = now
+	r.tools = make(map[string]*ToolUsage)
+	r.errorCodes = make(map[string]int)
+	return report
+}
+
+// restore adds the counts of an unsent report back to the current period
+func (r *Reporter) restore(report Report) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.since = r.since.Add(-time.Duration(report.PeriodSeconds) * time.Second)
+	for _, sent := range report.Tools {
+		u, ok := r.tools[sent.Name]
+		if !ok {
+			u = &ToolUsage{Name: sent.Name}
+			r.tools[sent.Name] = u
+		}
+		u.Calls += sent.Calls
+		u.Errors += sent.Errors
+	}
+	for code, n := range report.ErrorCodes {
+		r.errorCodes[code] += n
+	}
+}
+
+func (r *Reporter) send(ctx context.Context, report Report) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFlush(t *testing.T) {
+	var received []Report
+	fail := true
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var report Report
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("decode report: %v", err)
+		}
+		received = append(received, report)
+	}))
+	defer endpoint.Close()
+
+	r := New(endpoint.URL, time.Hour, Environment{Version: "1.2.3", Transport: "http", Storage: "s3"})
+	r.Record("gemini_image_generation", "")
+	r.Record("gemini_image_generation", "model_blocked")
+	r.Flush(context.Background())
+
+	// Counts that could not be sent are included in the next report
+	fail = false
+	r.Record("veo_text_to_video", "timeout")
+	r.Flush(context.Background())
+	if len(received) != 1 {
+		t.Fatalf("received %d reports, want 1", len(received))
+	}
+	report := received[0]
+	if report.Version != "1.2.3" || report.Storage != "s3" || report.InstanceID == "" {
+		t.Errorf("report environment = %+v", report)
+	}
+	if report.Calls != 3 || report.Errors != 2 || len(report.Tools) != 2 {
+		t.Fatalf("report counts = %+v", report)
+	}
+	if tool := report.Tools[0]; tool.Name != "gemini_image_generation" || tool.Calls != 2 || tool.Errors != 1 {
+		t.Errorf("first tool = %+v", tool)
+	}
+	if report.ErrorCodes["model_blocked"] != 1 || report.ErrorCodes["timeout"] != 1 {
+		t.Errorf("error codes = %v", report.ErrorCodes)
+	}
+
+	// Sent counts start over
+	r.Flush(context.Background())
+	if len(received) != 2 || received[1].Calls != 0 {
+		t.Errorf("second report = %+v, want no calls", received[len(received)-1])
+	}
+}
@@ -0,0 +1,131 @@
+// Package tempfiles keeps the server's temporary files, such as S3 objects
+// downloaded as tool inputs and ffmpeg intermediates, in one directory.
+// Their removal is normally left to the cleanup of the call that created
+// them; files that a crash left behind are swept up at startup and then
+// periodically, once they are older than a maximum age.
+package tempfiles
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Usage is the content of the managed directory
+type Usage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Manager keeps the temporary files in its directory and removes the
+// orphaned ones. A nil Manager creates files in the system temp directory.
+type Manager struct {
+	dir    string
+	maxAge time.Duration
+	now    func() time.Time
+
+	removed atomic.Int64 // Files removed by sweeps since startup
+}
+
+// New creates path if needed and returns a Manager keeping temporary files
+// in it. Files in it older than maxAge count as orphaned.
+func New(path string, maxAge time.Duration) (*Manager, error) {
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	return &Manager{dir: path, maxAge: maxAge, now: time.Now}, nil
+}
+
+// Dir returns the directory temporary files are created in
+func (m *Manager) Dir() string {
+	if m == nil {
+		return os.TempDir()
+	}
+	return m.dir
+}
+
+// Create creates a temporary file in Dir, like os.CreateTemp
+func (m *Manager) Create(pattern string) (*os.File, error) {
+	return os.CreateTemp(m.Dir(), pattern)
+}
+
+// Sweep removes the files in the directory last modified more than maxAge
+// ago and returns how many it removed and their size. Subdirectories are
+// left alone; their owners, such as resumable uploads, clean them up.
+func (m *Manager) Sweep() (removed int, freed int64) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		log.Printf("Warning: failed to read temp directory %s: %v", m.dir, err)
+		return 0, 0
+	}
+	cutoff := m.now().Add(-m.maxAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		path := filepath.Join(m.dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			log.Printf("Warning: failed to remove orphaned temp file %s: %v", path, err)
+			continue
+		}
+		removed++
+		freed += info.Size()
+	}
+	m.removed.Add(int64(removed))
+	if removed > 0 {
+		log.Printf("Removed %d orphaned temp file(s) (%d bytes) older than %v from %s", removed, freed, m.maxAge, m.dir)
+	}
+	return removed, freed
+}
+
+// Run sweeps the directory every interval until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Sweep()
+		}
+	}
+}
+
+// Usage returns the number and total size of the files in the directory
+func (m *Manager) Usage() Usage {
+	var usage Usage
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return usage
+	}
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil && info.Mode().IsRegular() {
+			usage.Files++
+			usage.Bytes += info.Size()
+		}
+	}
+	return usage
+}
+
+// WriteMetrics writes the directory's usage and the files removed by sweeps
+// in the Prometheus text format
+func (m *Manager) WriteMetrics(w io.Writer) {
+	usage := m.Usage()
+	fmt.Fprintln(w, "# HELP gemini_mcp_temp_files Files in the temp directory.")
+	fmt.Fprintln(w, "# TYPE gemini_mcp_temp_files gauge")
+	fmt.Fprintf(w, "gemini_mcp_temp_files %d\n", usage.Files)
+	fmt.Fprintln(w, "# HELP gemini_mcp_temp_bytes Total size of the files in the temp directory.")
+	fmt.Fprintln(w, "# TYPE gemini_mcp_temp_bytes gauge")
+	fmt.Fprintf(w, "gemini_mcp_temp_bytes %d\n", usage.Bytes)
+	fmt.Fprintln(w, "# HELP gemini_mcp_temp_files_removed_total Orphaned temp files removed by sweeps.")
+	fmt.Fprintln(w, "# TYPE gemini_mcp_temp_files_removed_total counter")
+	fmt.Fprintf(w, "gemini_mcp_temp_files_removed_total %d\n", m.removed.Load())
+}
@@ -0,0 +1,69 @@
+package tempfiles
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tmp")
+	m, err := New(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Dir() != path {
+		t.Fatalf("Dir() = %q, want %q", m.Dir(), path)
+	}
+	var unmanaged *Manager
+	if unmanaged.Dir() != os.TempDir() {
+		t.Errorf("Dir() of a nil Manager = %q, want the system temp directory", unmanaged.Dir())
+	}
+
+	orphan, err := m.Create("gemini-mcp-*.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan.WriteString("orphan")
+	orphan.Close()
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(orphan.Name(), old, old)
+
+	fresh, err := m.Create("gemini-mcp-*.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fresh.WriteString("in use")
+	fresh.Close()
+
+	// Subdirectories are not swept, however old
+	sub := filepath.Join(path, "uploads")
+	os.Mkdir(sub, 0700)
+	os.Chtimes(sub, old, old)
+
+	if usage := m.Usage(); usage.Files != 2 || usage.Bytes != 12 {
+		t.Errorf("Usage() = %+v, want 2 files of 12 bytes", usage)
+	}
+	if removed, freed := m.Sweep(); removed != 1 || freed != 6 {
+		t.Errorf("Sweep() = %d, %d, want 1 file of 6 bytes", removed, freed)
+	}
+	if _, err := os.Stat(orphan.Name()); !os.IsNotExist(err) {
+		t.Errorf("orphaned file was kept: %v", err)
+	}
+	for _, kept := range []string{fresh.Name(), sub} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("%s was removed: %v", kept, err)
+		}
+	}
+
+	var metrics bytes.Buffer
+	m.WriteMetrics(&metrics)
+	for _, want := range []string{"gemini_mcp_temp_files 1\n", "gemini_mcp_temp_bytes 6\n", "gemini_mcp_temp_files_removed_total 1\n"} {
+		if !strings.Contains(metrics.String(), want) {
+			t.Errorf("metrics lack %q:\n%s", want, metrics.String())
+		}
+	}
+}
@@ -0,0 +1,312 @@
+// Package tokens keeps service tokens created through the admin API, so new
+// clients can be given a token, scopes and a rate limit without changing
+// SERVICE_TOKENS and restarting. Tokens are persisted to a small JSON file;
+// only a SHA-256 hash of each secret is stored, so the secret is shown once,
+// when the token is created.
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/quota"
+)
+
+// secretPrefix marks managed tokens, so they are not mistaken for JWTs
+const secretPrefix = "gmt_"
+
+var (
+	// ErrNotFound is returned for unknown token IDs
+	ErrNotFound = errors.New("token not found")
+	// ErrRevoked is returned when changing a revoked token
+	ErrRevoked = errors.New("token is revoked")
+)
+
+// Token is a managed service token. The secret itself is not kept.
+type Token struct {
+	ID        string       `json:"id"`
+	Label     string       `json:"label,omitempty"`
+	Tenant    string       `json:"tenant,omitempty"`     // Tenant whose storage the token is confined to
+	Scopes    []string     `json:"scopes,omitempty"`     // Scopes granted, checked like JWT scopes
+	RateLimit int          `json:"rate_limit,omitempty"` // Requests per minute (0 for no limit)
+	Quotas    quota.Limits `json:"quotas,omitempty"`     // Generations per day and month, replacing the server's QUOTAS
+	CreatedAt time.Time    `json:"created_at"`
+	ExpiresAt *time.Time   `json:"expires_at,omitempty"`
+	RevokedAt *time.Time   `json:"revoked_at,omitempty"`
+	LastUsed  *time.Time   `json:"last_used,omitempty"`
+	Hash      string       `json:"hash"`
+}
+
+// Active reports whether the token is neither revoked nor expired
+func (t *Token) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// Update changes the settings of a token; nil fields are kept
+type Update struct {
+	Label     *string
+	Tenant    *string
+	Scopes    *[]string
+	RateLimit *int
+	Quotas    *quota.Limits
+	ExpiresAt *time.Time
+}
+
+// window counts a token's requests in the current minute
+type window struct {
+	start time.Time
+	count int
+}
+
+// Store keeps managed tokens and persists them to a JSON file
+type Store struct {
+	path string
+
+	mu      sync.Mutex
+	tokens  map[string]*Token // By ID
+	hashes  map[string]string // Secret hash to ID
+	windows map[string]*window
+}
+
+// Open loads the tokens saved at path. A missing file starts an empty store.
+func Open(path string) (*Store, error) {
+	st := &Store{
+		path:    path,
+		tokens:  make(map[string]*Token),
+		hashes:  make(map[string]string),
+		windows: make(map[string]*window),
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token store: %w", err)
+	}
+	var tokens []*Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("invalid token store %s: %w", path, err)
+	}
+	for _, t := range tokens {
+		st.tokens[t.ID] = t
+		st.hashes[t.Hash] = t.ID
+	}
+	return st, nil
+}
+
+// Create adds a token with the settings of t and returns it with its secret
+func (st *Store) Create(t Token) (Token, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return Token{}, "", err
+	}
+	key, err := randomHex(24)
+	if err != nil {
+		return Token{}, "", err
+	}
+	secret := secretPrefix + id + "_" + key
+
+	t.ID = id
+	t.Hash = hash(secret)
+	t.CreatedAt = time.Now().UTC()
+	t.RevokedAt, t.LastUsed = nil, nil
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.tokens[t.ID] = &t
+	st.hashes[t.Hash] = t.ID
+	if err := st.save(); err != nil {
+		delete(st.tokens, t.ID)
+		delete(st.hashes, t.Hash)
+		return Token{}, "", err
+	}
+	return t, secret, nil
+}
+
+// List returns all tokens, revoked ones included, oldest first
+func (st *Store) List() []Token {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	list := make([]Token, 0, len(st.tokens))
+	for _, t := range st.tokens {
+		list = append(list, *t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Get returns the token with the given ID
+func (st *Store) Get(id string) (Token, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	t, ok := st.tokens[id]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	return *t, nil
+}
+
+// Update changes the label, tenant, scopes, rate limit, quotas or expiry of
+// a token
+func (st *Store) Update(id string, u Update) (Token, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	t, ok := st.tokens[id]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	if t.RevokedAt != nil {
+		return *t, ErrRevoked
+	}
+	previous := *t
+	if u.Label != nil {
+		t.Label = *u.Label
+	}
+	if u.Tenant != nil {
+		t.Tenant = *u.Tenant
+	}
+	if u.Scopes != nil {
+		t.Scopes = *u.Scopes
+	}
+	if u.RateLimit != nil {
+		t.RateLimit = *u.RateLimit
+	}
+	if u.Quotas != nil {
+		t.Quotas = *u.Quotas
+	}
+	if u.ExpiresAt != nil {
+		expiresAt := *u.ExpiresAt
+		t.ExpiresAt = &expiresAt
+	}
+	if err := st.save(); err != nil {
+		*t = previous
+		return Token{}, err
+	}
+	return *t, nil
+}
+
+// Revoke stops a token from authenticating. It stays listed, so its label
+// and last use remain visible.
+func (st *Store) Revoke(id string) (Token, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	t, ok := st.tokens[id]
+	if !ok {
+		return Token{}, ErrNotFound
+	}
+	if t.RevokedAt != nil {
+		return *t, nil
+	}
+	now := time.Now().UTC()
+	t.RevokedAt = &now
+	if err := st.save(); err != nil {
+		t.RevokedAt = nil
+		return Token{}, err
+	}
+	delete(st.windows, id)
+	return *t, nil
+}
+
+// Authenticate implements middleware.TokenStore. It returns the claims of an
+// active managed token, nil for secrets that are not managed tokens, and
+// middleware.ErrRateLimited once the token used up its requests this minute.
+func (st *Store) Authenticate(secret string) (*middleware.Claims, error) {
+	if !strings.HasPrefix(secret, secretPrefix) {
+		return nil, nil
+	}
+	now := time.Now()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	id, ok := st.hashes[hash(secret)]
+	if !ok {
+		return nil, nil
+	}
+	t := st.tokens[id]
+	if !t.Active(now) {
+		return nil, nil
+	}
+	if t.RateLimit > 0 {
+		w := st.windows[id]
+		if w == nil || now.Sub(w.start) >= time.Minute {
+			w = &window{start: now}
+			st.windows[id] = w
+		}
+		if w.count >= t.RateLimit {
+			return nil, middleware.ErrRateLimited
+		}
+		w.count++
+	}
+	// Last use is only kept in memory until the next change is saved, so
+	// requests do not rewrite the file
+	used := now.UTC()
+	t.LastUsed = &used
+
+	claims := &middleware.Claims{
+		Subject: "token:" + t.ID,
+		Scopes:  t.Scopes,
+		Tenant:  t.Tenant,
+	}
+	if t.ExpiresAt != nil {
+		claims.ExpiresAt = *t.ExpiresAt
+	}
+	return claims, nil
+}
+
+// save writes all tokens to the store file. The caller must hold st.mu.
+func (st *Store) save() error {
+	list := make([]*Token, 0, len(st.tokens))
+	for _, t := range st.tokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	dir := filepath.Dir(st.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(st.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), st.path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to save tokens: %w", err)
+	}
+	return nil
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,123 @@
+package tokens
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/quota"
+)
+
+func openStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	st, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return st, path
+}
+
+func TestCreateAuthenticate(t *testing.T) {
+	st, path := openStore(t)
+	created, secret, err := st.Create(Token{Label: "ci", Tenant: "acme", Scopes: []string{"batch"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	claims, err := st.Authenticate(secret)
+	if err != nil || claims == nil {
+		t.Fatalf("Authenticate = %v, %v", claims, err)
+	}
+	if claims.Subject != "token:"+created.ID || claims.Tenant != "acme" || !claims.HasScope("batch") {
+		t.Errorf("claims = %+v", claims)
+	}
+	for _, other := range []string{"", "static-token", secret + "x"} {
+		if claims, err := st.Authenticate(other); claims != nil || err != nil {
+			t.Errorf("Authenticate(%q) = %v, %v", other, claims, err)
+		}
+	}
+
+	// Only the hash of the secret is saved, and a reopened store accepts it
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Error("store file contains the secret")
+	}
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if claims, _ := reopened.Authenticate(secret); claims == nil {
+		t.Error("token was not persisted")
+	}
+}
+
+func TestUpdateRevoke(t *testing.T) {
+	st, path := openStore(t)
+	created, secret, err := st.Create(Token{Label: "ci"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label, scopes := "nightly", []string{"batch"}
+	quotas := quota.Limits{quota.Videos: {quota.Day: 5}}
+	updated, err := st.Update(created.ID, Update{Label: &label, Scopes: &scopes, Quotas: &quotas})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Label != "nightly" || len(updated.Scopes) != 1 || updated.Quotas[quota.Videos][quota.Day] != 5 {
+		t.Errorf("updated = %+v", updated)
+	}
+	if _, err := st.Update("missing", Update{Label: &label}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Update of an unknown token: %v", err)
+	}
+
+	if _, err := st.Revoke(created.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if claims, _ := st.Authenticate(secret); claims != nil {
+		t.Error("revoked token authenticated")
+	}
+	if _, err := st.Update(created.ID, Update{Label: &label}); !errors.Is(err, ErrRevoked) {
+		t.Errorf("Update of a revoked token: %v", err)
+	}
+	reopened, _ := Open(path)
+	if list := reopened.List(); len(list) != 1 || list[0].RevokedAt == nil || list[0].Quotas[quota.Videos][quota.Day] != 5 {
+		t.Errorf("revocation was not persisted: %+v", list)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	st, _ := openStore(t)
+	expiresAt := time.Now().Add(-time.Minute)
+	_, secret, err := st.Create(Token{ExpiresAt: &expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, _ := st.Authenticate(secret); claims != nil {
+		t.Error("expired token authenticated")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	st, _ := openStore(t)
+	_, secret, err := st.Create(Token{RateLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2 {
+		if claims, err := st.Authenticate(secret); claims == nil || err != nil {
+			t.Fatalf("request %d: %v, %v", i, claims, err)
+		}
+	}
+	if _, err := st.Authenticate(secret); !errors.Is(err, middleware.ErrRateLimited) {
+		t.Errorf("third request: %v, want ErrRateLimited", err)
+	}
+}
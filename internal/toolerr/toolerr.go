@@ -0,0 +1,145 @@
+// Package toolerr classifies the errors of tool calls into a small set of
+// codes with retry hints, so clients can decide whether to retry, repair
+// their input or give up without parsing error messages.
+package toolerr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// Code identifies the kind of failure of a tool call
+type Code string
+
+const (
+	InvalidInput  Code = "invalid_input"  // The arguments must be changed before retrying
+	ModelBlocked  Code = "model_blocked"  // Safety filters blocked the prompt or output
+	QuotaExceeded Code = "quota_exceeded" // Rate or quota limits; retry later
+	StorageError  Code = "storage_error"  // Storing or reading media failed
+	Timeout       Code = "timeout"        // The call or an upstream request ran out of time
+	Upstream      Code = "upstream_error" // The Gemini API failed
+	Unavailable   Code = "unavailable"    // The server is shutting down; retry, possibly on another instance
+	Internal      Code = "internal_error" // Any other failure
+)
+
+// Retryable reports whether calls failing with code may succeed when retried
+// unchanged
+func (c Code) Retryable() bool {
+	switch c {
+	case QuotaExceeded, StorageError, Timeout, Unavailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// Error is a classified tool error. It keeps the message of the error it wraps.
+type Error struct {
+	Code       Code
+	Retryable  bool
+	RetryAfter time.Duration // Suggested wait before retrying (0 if unknown)
+	Err        error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Info is the JSON form of an error reported to clients
+type Info struct {
+	Code              Code   `json:"code"`
+	Message           string `json:"message"`
+	Retryable         bool   `json:"retryable"`
+	RetryAfterSeconds int    `json:"retry_after_seconds,omitempty"`
+}
+
+// Info returns the JSON form of e
+func (e *Error) Info() Info {
+	return Info{
+		Code:              e.Code,
+		Message:           e.Error(),
+		Retryable:         e.Retryable,
+		RetryAfterSeconds: int(math.Ceil(e.RetryAfter.Seconds())),
+	}
+}
+
+// Errorf formats an error like fmt.Errorf and classifies it as code
+func Errorf(code Code, format string, args ...any) error {
+	return &Error{Code: code, Retryable: code.Retryable(), Err: fmt.Errorf(format, args...)}
+}
+
+// Wrap classifies err as code, unless it already carries a code. It returns
+// nil for a nil err.
+func Wrap(code Code, err error) error {
+	var classified *Error
+	if err == nil || errors.As(err, &classified) {
+		return err
+	}
+	return &Error{Code: code, Retryable: code.Retryable(), Err: err}
+}
+
+// Classify returns err as a classified error: the first *Error in its chain,
+// or a code derived from context, file system and Gemini API errors. Other
+// errors are classified as Internal.
+func Classify(err error) *Error {
+	var classified *Error
+	if errors.As(err, &classified) {
+		if classified.Err != err {
+			// Keep the message of the outermost error
+			outer := *classified
+			outer.Err = err
+			return &outer
+		}
+		return classified
+	}
+
+	var apiErr genai.APIError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return &Error{Code: Timeout, Retryable: true, Err: err}
+	case errors.Is(err, fs.ErrNotExist):
+		return &Error{Code: InvalidInput, Err: err}
+	case errors.As(err, &apiErr):
+		return classifyAPIError(apiErr, err)
+	default:
+		return &Error{Code: Internal, Err: err}
+	}
+}
+
+// classifyAPIError classifies a Gemini API error by its HTTP status
+func classifyAPIError(apiErr genai.APIError, err error) *Error {
+	switch {
+	case apiErr.Code == 429:
+		return &Error{Code: QuotaExceeded, Retryable: true, RetryAfter: retryDelay(apiErr), Err: err}
+	case apiErr.Code == 408 || apiErr.Code == 504:
+		return &Error{Code: Timeout, Retryable: true, Err: err}
+	case apiErr.Code == 400 || apiErr.Code == 404 || apiErr.Code == 422:
+		return &Error{Code: InvalidInput, Err: err}
+	case apiErr.Code >= 500:
+		return &Error{Code: Upstream, Retryable: true, Err: err}
+	default:
+		return &Error{Code: Upstream, Err: err}
+	}
+}
+
+// retryDelay returns the delay suggested by the google.rpc.RetryInfo detail
+// of an API error, or 0 if it has none
+func retryDelay(apiErr genai.APIError) time.Duration {
+	for _, detail := range apiErr.Details {
+		if detail["@type"] != "type.googleapis.com/google.rpc.RetryInfo" {
+			continue
+		}
+		if s, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,63 @@
+package toolerr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+func TestClassify(t *testing.T) {
+	_, notExist := os.Open("/nonexistent/input.png")
+	quota := genai.APIError{Code: 429, Status: "RESOURCE_EXHAUSTED", Details: []map[string]any{
+		{"@type": "type.googleapis.com/google.rpc.RetryInfo", "retryDelay": "31.5s"},
+	}}
+
+	tests := []struct {
+		name       string
+		err        error
+		code       Code
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{"explicit", Errorf(InvalidInput, "prompt is required"), InvalidInput, false, 0},
+		{"wrapped explicit", fmt.Errorf("step 1: %w", Errorf(QuotaExceeded, "busy")), QuotaExceeded, true, 0},
+		{"deadline", fmt.Errorf("error generating image: %w", context.DeadlineExceeded), Timeout, true, 0},
+		{"missing file", fmt.Errorf("failed to read input image: %w", notExist), InvalidInput, false, 0},
+		{"quota", fmt.Errorf("error generating image: %w", quota), QuotaExceeded, true, 31500 * time.Millisecond},
+		{"bad request", genai.APIError{Code: 400}, InvalidInput, false, 0},
+		{"unavailable", genai.APIError{Code: 503}, Upstream, true, 0},
+		{"forbidden", genai.APIError{Code: 403}, Upstream, false, 0},
+		{"unknown", fmt.Errorf("something broke"), Internal, false, 0},
+	}
+	for _, tt := range tests {
+		got := Classify(tt.err)
+		if got.Code != tt.code || got.Retryable != tt.retryable || got.RetryAfter != tt.retryAfter {
+			t.Errorf("%s: Classify = %s (retryable %v, after %v), want %s (retryable %v, after %v)",
+				tt.name, got.Code, got.Retryable, got.RetryAfter, tt.code, tt.retryable, tt.retryAfter)
+		}
+		if got.Error() != tt.err.Error() {
+			t.Errorf("%s: message = %q, want %q", tt.name, got.Error(), tt.err.Error())
+		}
+	}
+}
+
+func TestWrapKeepsExistingCode(t *testing.T) {
+	err := Wrap(StorageError, Errorf(InvalidInput, "file not found"))
+	if code := Classify(err).Code; code != InvalidInput {
+		t.Errorf("Wrap replaced the code with %s", code)
+	}
+	if Wrap(StorageError, nil) != nil {
+		t.Error("Wrap(nil) != nil")
+	}
+}
+
+func TestInfo(t *testing.T) {
+	info := (&Error{Code: QuotaExceeded, Retryable: true, RetryAfter: 1500 * time.Millisecond, Err: fmt.Errorf("slow down")}).Info()
+	if info.Code != QuotaExceeded || info.Message != "slow down" || !info.Retryable || info.RetryAfterSeconds != 2 {
+		t.Errorf("Info = %+v", info)
+	}
+}
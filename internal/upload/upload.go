@@ -0,0 +1,285 @@
+// Package upload keeps the state of resumable uploads: the bytes received so
+// far are written to a temporary file keyed by a random upload ID, so a
+// client whose connection dropped mid-transfer can ask for the offset the
+// server reached and send only the rest.
+package upload
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned for unknown, expired or completed uploads
+	ErrNotFound = errors.New("upload not found or expired")
+	// ErrOffsetMismatch is returned when a chunk does not start where the
+	// bytes received so far end
+	ErrOffsetMismatch = errors.New("offset does not match the bytes received")
+	// ErrTooLarge is returned when a chunk goes past the declared length
+	ErrTooLarge = errors.New("chunk exceeds the upload length")
+	// ErrLimitExceeded is returned for uploads larger than the manager allows
+	ErrLimitExceeded = errors.New("upload exceeds the size limit")
+	// ErrBusy is returned while another request is appending to the upload
+	ErrBusy = errors.New("another request is writing to this upload")
+)
+
+// Session is the state of one resumable upload
+type Session struct {
+	ID        string
+	Filename  string
+	Tenant    string // Tenant whose storage receives the upload (empty for none)
+	Length    int64  // Declared size of the file
+	Offset    int64  // Bytes received so far
+	ExpiresAt time.Time
+}
+
+// Complete reports whether all bytes have been received
+func (s *Session) Complete() bool {
+	return s.Offset == s.Length
+}
+
+// Manager keeps resumable uploads. Uploads expire ttl after the last chunk
+// was received, and their partial data is deleted.
+type Manager struct {
+	dir     string
+	ttl     time.Duration
+	maxSize int64
+
+	mu       sync.Mutex
+	sessions map[string]*session
+}
+
+// session is an upload and the file its data is written to. Session fields
+// are guarded by Manager.mu.
+type session struct {
+	Session
+	path    string
+	writing sync.Mutex // Held while a chunk is appended
+}
+
+// NewManager creates a manager keeping partial uploads of up to maxSize bytes
+// in dir
+func NewManager(dir string, ttl time.Duration, maxSize int64) *Manager {
+	return &Manager{
+		dir:      dir,
+		ttl:      ttl,
+		maxSize:  maxSize,
+		sessions: make(map[string]*session),
+	}
+}
+
+// Create starts an upload of length bytes into tenant's storage
+func (m *Manager) Create(filename, tenant string, length int64) (Session, error) {
+	if length <= 0 {
+		return Session{}, fmt.Errorf("upload length must be positive")
+	}
+	if length > m.maxSize {
+		return Session{}, fmt.Errorf("%w: %d bytes, the limit is %d bytes", ErrLimitExceeded, length, m.maxSize)
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return Session{}, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	b := make([]byte, 32)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	path := filepath.Join(m.dir, id+".part")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to create upload file: %w", err)
+	}
+	f.Close()
+
+	s := &session{
+		Session: Session{
+			ID:        id,
+			Filename:  filepath.Base(filename),
+			Tenant:    tenant,
+			Length:    length,
+			ExpiresAt: time.Now().Add(m.ttl),
+		},
+		path: path,
+	}
+	m.mu.Lock()
+	m.sessions[id] = s
+	m.mu.Unlock()
+	return s.Session, nil
+}
+
+// Get returns the state of an upload
+func (m *Manager) Get(id string) (Session, error) {
+	s, err := m.session(id)
+	if err != nil {
+		return Session{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return s.Session, nil
+}
+
+// Append writes the chunk read from r at offset. Bytes read before r fails,
+// e.g. because the client disconnected, are kept, and the returned session
+// tells where the next chunk must start.
+func (m *Manager) Append(id string, offset int64, r io.Reader) (Session, error) {
+	s, err := m.session(id)
+	if err != nil {
+		return Session{}, err
+	}
+	if !s.writing.TryLock() {
+		state, _ := m.Get(id)
+		return state, ErrBusy
+	}
+	defer s.writing.Unlock()
+
+	m.mu.Lock()
+	current := s.Offset
+	m.mu.Unlock()
+	if offset != current {
+		state, _ := m.Get(id)
+		return state, ErrOffsetMismatch
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return Session{}, fmt.Errorf("failed to open upload file: %w", err)
+	}
+	// One byte more than remains tells a chunk that is too long apart from
+	// one that ends the upload
+	remaining := s.Length - current
+	n, copyErr := io.Copy(f, io.LimitReader(r, remaining+1))
+	if n > remaining {
+		f.Truncate(s.Length)
+		n, copyErr = remaining, ErrTooLarge
+	}
+	if err := f.Close(); err != nil && copyErr == nil {
+		copyErr = fmt.Errorf("failed to write upload file: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s.Offset += n
+	s.ExpiresAt = time.Now().Add(m.ttl)
+	return s.Session, copyErr
+}
+
+// Read returns the data of a complete upload
+func (m *Manager) Read(id string) ([]byte, error) {
+	s, err := m.session(id)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	complete := s.Complete()
+	m.mu.Unlock()
+	if !complete {
+		return nil, fmt.Errorf("upload is not complete")
+	}
+	return os.ReadFile(s.path)
+}
+
+// Remove deletes an upload and its partial data
+func (m *Manager) Remove(id string) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+	if ok {
+		os.Remove(s.path)
+	}
+}
+
+// Cleanup removes uploads that expired before now and returns how many
+func (m *Manager) Cleanup(now time.Time) int {
+	m.mu.Lock()
+	var expired []*session
+	for id, s := range m.sessions {
+		if now.After(s.ExpiresAt) {
+			expired = append(expired, s)
+			delete(m.sessions, id)
+		}
+	}
+	m.mu.Unlock()
+	for _, s := range expired {
+		os.Remove(s.path)
+	}
+	return len(expired)
+}
+
+// RemoveOrphans removes partial data in the directory that belongs to no
+// upload of this manager, such as that of a process that crashed, once it
+// is older than the upload lifetime, and returns how many files it removed.
+// Files of other processes sharing the directory are left until their
+// uploads would have expired.
+func (m *Manager) RemoveOrphans(now time.Time) int {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return 0
+	}
+	m.mu.Lock()
+	known := make(map[string]bool, len(m.sessions))
+	for _, s := range m.sessions {
+		known[filepath.Base(s.path)] = true
+	}
+	m.mu.Unlock()
+
+	removed := 0
+	for _, entry := range entries {
+		if known[entry.Name()] || filepath.Ext(entry.Name()) != ".part" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || now.Sub(info.ModTime()) < m.ttl {
+			continue
+		}
+		if os.Remove(filepath.Join(m.dir, entry.Name())) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Run removes expired uploads and orphaned partial data every interval
+// until ctx is done
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.Cleanup(now)
+			m.RemoveOrphans(now)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Manager) session(id string) (*session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[id]
+	if !ok || time.Now().After(s.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// Close removes all uploads and their partial data
+func (m *Manager) Close() {
+	m.mu.Lock()
+	sessions := m.sessions
+	m.sessions = make(map[string]*session)
+	m.mu.Unlock()
+	for _, s := range sessions {
+		os.Remove(s.path)
+	}
+	os.Remove(m.dir)
+}
@@ -0,0 +1,126 @@
+package upload
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingReader returns data, then fails like a dropped connection
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestResume(t *testing.T) {
+	m := NewManager(t.TempDir(), time.Hour, 1<<20)
+	s, err := m.Create("../photo.png", "acme", 10)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if s.Filename != "photo.png" || s.Tenant != "acme" || s.Offset != 0 {
+		t.Errorf("Create = %+v", s)
+	}
+
+	// The connection drops after 4 bytes; they are kept
+	s, err = m.Append(s.ID, 0, &failingReader{data: []byte("0123")})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || s.Offset != 4 {
+		t.Fatalf("interrupted Append = %+v, %v; want offset 4", s, err)
+	}
+	if _, err := m.Read(s.ID); err == nil {
+		t.Error("Read of an incomplete upload succeeded")
+	}
+
+	if s, err = m.Append(s.ID, 2, strings.NewReader("23456789")); !errors.Is(err, ErrOffsetMismatch) || s.Offset != 4 {
+		t.Errorf("Append at a stale offset = %+v, %v", s, err)
+	}
+	if s, err = m.Append(s.ID, 4, strings.NewReader("456789")); err != nil || !s.Complete() {
+		t.Fatalf("resumed Append = %+v, %v", s, err)
+	}
+	data, err := m.Read(s.ID)
+	if err != nil || string(data) != "0123456789" {
+		t.Errorf("Read = %q, %v", data, err)
+	}
+
+	m.Remove(s.ID)
+	if _, err := m.Get(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Remove = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAppendTooLarge(t *testing.T) {
+	m := NewManager(t.TempDir(), time.Hour, 1<<20)
+	s, _ := m.Create("a.bin", "", 4)
+	s, err := m.Append(s.ID, 0, bytes.NewReader([]byte("012345")))
+	if !errors.Is(err, ErrTooLarge) || s.Offset != 4 {
+		t.Errorf("Append = %+v, %v; want ErrTooLarge at offset 4", s, err)
+	}
+	if data, _ := m.Read(s.ID); string(data) != "0123" {
+		t.Errorf("Read = %q, want the declared length only", data)
+	}
+}
+
+func TestCreateLimits(t *testing.T) {
+	m := NewManager(t.TempDir(), time.Hour, 100)
+	if _, err := m.Create("a.bin", "", 101); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("Create over the limit = %v, want ErrLimitExceeded", err)
+	}
+	if _, err := m.Create("a.bin", "", 0); err == nil {
+		t.Error("empty upload accepted")
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir, time.Minute, 100)
+	s, _ := m.Create("a.bin", "", 10)
+	if n := m.Cleanup(time.Now()); n != 0 {
+		t.Errorf("Cleanup removed %d fresh uploads", n)
+	}
+	if n := m.Cleanup(time.Now().Add(2 * time.Minute)); n != 1 {
+		t.Errorf("Cleanup removed %d uploads, want 1", n)
+	}
+	if _, err := m.Get(s.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Cleanup = %v, want ErrNotFound", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("partial data left behind: %v", entries)
+	}
+}
+
+func TestRemoveOrphans(t *testing.T) {
+	dir := t.TempDir()
+	orphan := filepath.Join(dir, "deadbeef.part")
+	if err := os.WriteFile(orphan, []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(dir, time.Minute, 100)
+	live, _ := m.Create("a.bin", "", 10)
+
+	if n := m.RemoveOrphans(time.Now()); n != 0 {
+		t.Errorf("RemoveOrphans removed %d files younger than the upload lifetime", n)
+	}
+	later := time.Now().Add(2 * time.Minute)
+	if n := m.RemoveOrphans(later); n != 1 {
+		t.Errorf("RemoveOrphans removed %d files, want the orphan", n)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Errorf("orphaned partial data left behind: %v", err)
+	}
+	if _, err := m.Get(live.ID); err != nil {
+		t.Errorf("live upload lost its data: %v", err)
+	}
+}
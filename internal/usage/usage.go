@@ -0,0 +1,74 @@
+// Package usage adds up the tokens the Gemini API reports for the
+// GenerateContent calls of a tool call, so clients can account for the cost
+// of a call from the result instead of estimating it from the prompt.
+package usage
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/genai"
+)
+
+// Tokens are the token counts of one or more GenerateContent calls, as
+// reported in their usage metadata
+type Tokens struct {
+	Prompt     int64 `json:"prompt_tokens"`
+	Candidates int64 `json:"candidates_tokens"`
+	Thoughts   int64 `json:"thoughts_tokens,omitempty"`
+	Cached     int64 `json:"cached_tokens,omitempty"` // Part of Prompt served from the context cache
+	ToolUse    int64 `json:"tool_use_prompt_tokens,omitempty"`
+	Total      int64 `json:"total_tokens"`
+	Calls      int   `json:"calls"` // GenerateContent calls counted
+}
+
+type collectorKey struct{}
+
+// Collector adds up the tokens of one tool call. It is safe for concurrent
+// use.
+type Collector struct {
+	parent *Collector
+
+	mu     sync.Mutex
+	tokens Tokens
+}
+
+// WithCollector returns a context whose usage is recorded on the returned
+// collector. Unlike warnings, usage is also recorded on every enclosing
+// collector, so a pipeline reports the tokens of all of its steps.
+func WithCollector(ctx context.Context) (context.Context, *Collector) {
+	parent, _ := ctx.Value(collectorKey{}).(*Collector)
+	c := &Collector{parent: parent}
+	return context.WithValue(ctx, collectorKey{}, c), c
+}
+
+// Add records the usage metadata of a GenerateContent response on the
+// collectors of ctx, if any. Responses without usage metadata are ignored.
+func Add(ctx context.Context, response *genai.GenerateContentResponse) {
+	if response == nil || response.UsageMetadata == nil {
+		return
+	}
+	metadata := response.UsageMetadata
+	for c, _ := ctx.Value(collectorKey{}).(*Collector); c != nil; c = c.parent {
+		c.mu.Lock()
+		c.tokens.Prompt += int64(metadata.PromptTokenCount)
+		c.tokens.Candidates += int64(metadata.CandidatesTokenCount)
+		c.tokens.Thoughts += int64(metadata.ThoughtsTokenCount)
+		c.tokens.Cached += int64(metadata.CachedContentTokenCount)
+		c.tokens.ToolUse += int64(metadata.ToolUsePromptTokenCount)
+		c.tokens.Total += int64(metadata.TotalTokenCount)
+		c.tokens.Calls++
+		c.mu.Unlock()
+	}
+}
+
+// Tokens returns the recorded token counts, or nil if no usage was recorded
+func (c *Collector) Tokens() *Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens.Calls == 0 {
+		return nil
+	}
+	tokens := c.tokens
+	return &tokens
+}
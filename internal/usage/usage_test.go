@@ -0,0 +1,37 @@
+package usage
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/genai"
+)
+
+func TestCollector(t *testing.T) {
+	ctx, outer := WithCollector(context.Background())
+	stepCtx, step := WithCollector(ctx)
+
+	Add(stepCtx, response(genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 10, CandidatesTokenCount: 1290, TotalTokenCount: 1300}))
+	Add(stepCtx, nil)
+	Add(stepCtx, &genai.GenerateContentResponse{})
+	Add(ctx, response(genai.GenerateContentResponseUsageMetadata{PromptTokenCount: 5, CandidatesTokenCount: 7, ThoughtsTokenCount: 3, TotalTokenCount: 15}))
+
+	if got, want := *step.Tokens(), (Tokens{Prompt: 10, Candidates: 1290, Total: 1300, Calls: 1}); got != want {
+		t.Errorf("step tokens = %+v, want %+v", got, want)
+	}
+	if got, want := *outer.Tokens(), (Tokens{Prompt: 15, Candidates: 1297, Thoughts: 3, Total: 1315, Calls: 2}); got != want {
+		t.Errorf("outer tokens = %+v, want %+v", got, want)
+	}
+}
+
+func TestNoUsage(t *testing.T) {
+	_, c := WithCollector(context.Background())
+	if tokens := c.Tokens(); tokens != nil {
+		t.Errorf("Tokens() = %+v without usage", tokens)
+	}
+	Add(context.Background(), response(genai.GenerateContentResponseUsageMetadata{TotalTokenCount: 1}))
+}
+
+func response(metadata genai.GenerateContentResponseUsageMetadata) *genai.GenerateContentResponse {
+	return &genai.GenerateContentResponse{UsageMetadata: &metadata}
+}
@@ -0,0 +1,151 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transitions are the ffmpeg xfade transitions Concat accepts
+var Transitions = []string{"fade", "dissolve", "fadeblack", "fadewhite", "wipeleft", "wiperight", "slideleft", "slideright"}
+
+// Info describes an MP4 or MOV file
+type Info struct {
+	Duration time.Duration
+	Width    int
+	Height   int
+	HasAudio bool
+}
+
+// Probe reads the duration, the size of the first video track and whether
+// the file has an audio track from the moov box of an MP4 or MOV file
+func Probe(data []byte) (Info, error) {
+	moov, _, _, err := parseMoov(data)
+	if err != nil {
+		return Info{}, err
+	}
+	d, err := Duration(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Duration: d}
+
+	for _, trak := range moov.children {
+		if trak.typ != "trak" {
+			continue
+		}
+		hdlr := trak.path("mdia", "hdlr")
+		if hdlr == nil || len(hdlr.body) < 12 {
+			continue
+		}
+		switch string(hdlr.body[8:12]) {
+		case "soun":
+			info.HasAudio = true
+		case "vide":
+			// The width and height are the last two 16.16 fixed-point fields
+			// of the track header
+			tkhd := trak.child("tkhd")
+			if info.Width == 0 && tkhd != nil && len(tkhd.body) >= 84 {
+				size := tkhd.body[len(tkhd.body)-8:]
+				info.Width = int(binary.BigEndian.Uint32(size) >> 16)
+				info.Height = int(binary.BigEndian.Uint32(size[4:]) >> 16)
+			}
+		}
+	}
+	if info.Width == 0 {
+		return Info{}, fmt.Errorf("no video track found")
+	}
+	return info, nil
+}
+
+// Concat joins videos of the same size into outputPath as an MP4 with ffmpeg.
+// durations holds the length of each input. With a crossfade, consecutive
+// clips overlap by that long with the xfade transition; otherwise they are
+// joined with hard cuts. Audio is kept only when every input has it.
+func Concat(ctx context.Context, ffmpegPath string, inputPaths []string, durations []time.Duration, outputPath string, crossfade time.Duration, transition string, audio bool) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, concatArgs(inputPaths, durations, outputPath, crossfade, transition, audio)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// concatArgs builds the ffmpeg arguments for Concat. Every input is
+// normalized to a common timebase and pixel format first, which xfade
+// requires; clips are then chained with xfade/acrossfade or joined with the
+// concat filter.
+func concatArgs(inputPaths []string, durations []time.Duration, outputPath string, crossfade time.Duration, transition string, audio bool) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	for _, p := range inputPaths {
+		args = append(args, "-i", p)
+	}
+
+	var filters []string
+	for i := range inputPaths {
+		filters = append(filters, fmt.Sprintf("[%d:v]settb=AVTB,setpts=PTS-STARTPTS,format=yuv420p[v%d]", i, i))
+		if audio {
+			filters = append(filters, fmt.Sprintf("[%d:a]asetpts=PTS-STARTPTS,aresample=48000[a%d]", i, i))
+		}
+	}
+
+	if crossfade > 0 {
+		fade := seconds(crossfade)
+		video, sound := "[v0]", "[a0]"
+		var offset time.Duration
+		for i := 1; i < len(inputPaths); i++ {
+			// Each clip starts fading in crossfade before the end of the
+			// previous one
+			offset += durations[i-1] - crossfade
+			out := fmt.Sprintf("[vx%d]", i)
+			filters = append(filters, fmt.Sprintf("%s[v%d]xfade=transition=%s:duration=%s:offset=%s%s", video, i, transition, fade, seconds(offset), out))
+			video = out
+			if audio {
+				out := fmt.Sprintf("[ax%d]", i)
+				filters = append(filters, fmt.Sprintf("%s[a%d]acrossfade=d=%s%s", sound, i, fade, out))
+				sound = out
+			}
+		}
+		args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", video)
+		if audio {
+			args = append(args, "-map", sound)
+		}
+	} else {
+		var in strings.Builder
+		for i := range inputPaths {
+			fmt.Fprintf(&in, "[v%d]", i)
+			if audio {
+				fmt.Fprintf(&in, "[a%d]", i)
+			}
+		}
+		a, out := 0, "[v]"
+		if audio {
+			a, out = 1, "[v][a]"
+		}
+		filters = append(filters, fmt.Sprintf("%sconcat=n=%d:v=1:a=%d%s", in.String(), len(inputPaths), a, out))
+		args = append(args, "-filter_complex", strings.Join(filters, ";"), "-map", "[v]")
+		if audio {
+			args = append(args, "-map", "[a]")
+		}
+	}
+
+	args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "18", "-pix_fmt", "yuv420p")
+	if audio {
+		args = append(args, "-c:a", "aac", "-b:a", "192k")
+	}
+	return append(args, "-movflags", "+faststart", "-f", "mp4", "-y", outputPath)
+}
+
+// seconds formats d for ffmpeg filter options
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
@@ -0,0 +1,74 @@
+package video
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// probeTestMP4 builds an 8 second MP4 with a 1280x720 video track and, if
+// audio is set, an audio track
+func probeTestMP4(audio bool) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 8000)
+	track := func(handler string, width, height uint32) []byte {
+		tkhd := make([]byte, 84)
+		binary.BigEndian.PutUint32(tkhd[76:], width<<16)
+		binary.BigEndian.PutUint32(tkhd[80:], height<<16)
+		hdlr := make([]byte, 24)
+		copy(hdlr[8:], handler)
+		return box("trak", append(box("tkhd", tkhd), box("mdia", box("hdlr", hdlr))...))
+	}
+	moov := append(box("mvhd", mvhd), track("vide", 1280, 720)...)
+	if audio {
+		moov = append(moov, track("soun", 0, 0)...)
+	}
+	return append(box("ftyp", []byte("isom\x00\x00\x02\x00")), box("moov", moov)...)
+}
+
+func TestProbe(t *testing.T) {
+	info, err := Probe(probeTestMP4(true))
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if want := (Info{Duration: 8 * time.Second, Width: 1280, Height: 720, HasAudio: true}); info != want {
+		t.Errorf("Probe = %+v, want %+v", info, want)
+	}
+	if info, err := Probe(probeTestMP4(false)); err != nil || info.HasAudio {
+		t.Errorf("Probe without audio = %+v, %v", info, err)
+	}
+	if _, err := Probe(box("ftyp", []byte("isom"))); err == nil {
+		t.Error("file without moov accepted")
+	}
+	if _, err := Probe(trimTestMP4()); err == nil {
+		t.Error("file without video track accepted")
+	}
+}
+
+func TestConcatArgs(t *testing.T) {
+	inputs := []string{"a.mp4", "b.mp4", "c.mp4"}
+	durations := []time.Duration{8 * time.Second, 6 * time.Second, 8 * time.Second}
+
+	args := strings.Join(concatArgs(inputs, durations, "out.mp4", 0, "", true), " ")
+	for _, want := range []string{"-i a.mp4 -i b.mp4 -i c.mp4", "[v0][a0][v1][a1][v2][a2]concat=n=3:v=1:a=1[v][a]", "-map [v] -map [a]", "-c:a aac", "-y out.mp4"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("concatArgs = %q, missing %q", args, want)
+		}
+	}
+
+	args = strings.Join(concatArgs(inputs, durations, "out.mp4", time.Second, "fade", false), " ")
+	for _, want := range []string{
+		"[v0][v1]xfade=transition=fade:duration=1.000:offset=7.000[vx1]",
+		"[vx1][v2]xfade=transition=fade:duration=1.000:offset=12.000[vx2]",
+		"-map [vx2]",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("concatArgs with crossfade = %q, missing %q", args, want)
+		}
+	}
+	if strings.Contains(args, "[0:a]") || strings.Contains(args, "-c:a") {
+		t.Errorf("concatArgs without audio = %q, maps audio", args)
+	}
+}
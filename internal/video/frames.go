@@ -0,0 +1,46 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExtractFrame grabs the frame at the given offset of a video with ffmpeg and
+// returns it as a JPEG no larger than maxDim on either side (0 keeps the
+// original size)
+func ExtractFrame(ctx context.Context, ffmpegPath, videoPath string, at time.Duration, maxDim int) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, frameArgs(videoPath, at, maxDim)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return nil, fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg returned no frame at %s", FormatTimestamp(at))
+	}
+	return stdout.Bytes(), nil
+}
+
+// frameArgs builds the ffmpeg arguments for ExtractFrame. Seeking before the
+// input keeps extraction fast on long videos.
+func frameArgs(videoPath string, at time.Duration, maxDim int) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(at.Seconds(), 'f', 3, 64),
+		"-i", videoPath,
+		"-frames:v", "1",
+	}
+	if maxDim > 0 {
+		args = append(args, "-vf", fmt.Sprintf("scale='min(iw,%d)':'min(ih,%d)':force_original_aspect_ratio=decrease", maxDim, maxDim))
+	}
+	return append(args, "-f", "image2pipe", "-vcodec", "mjpeg", "-")
+}
@@ -0,0 +1,407 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trim writes the part of a video between start and end to outputPath as an
+// MP4 with ffmpeg. Re-encoding cuts at the exact frame; copying the streams is
+// lossless and fast but starts at the keyframe before start.
+func Trim(ctx context.Context, ffmpegPath, inputPath, outputPath string, start, end time.Duration, reencode bool) error {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpegPath, trimArgs(inputPath, outputPath, start, end, reencode)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("ffmpeg failed: %w: %s", err, msg)
+		}
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+	return nil
+}
+
+// trimArgs builds the ffmpeg arguments for Trim. Seeking before the input is
+// fast, and frame-accurate when re-encoding.
+func trimArgs(inputPath, outputPath string, start, end time.Duration, reencode bool) []string {
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(start.Seconds(), 'f', 3, 64),
+		"-i", inputPath,
+		"-t", strconv.FormatFloat((end - start).Seconds(), 'f', 3, 64),
+	}
+	if reencode {
+		args = append(args, "-c:v", "libx264", "-preset", "veryfast", "-crf", "18", "-pix_fmt", "yuv420p", "-c:a", "aac", "-b:a", "192k")
+	} else {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	}
+	return append(args, "-movflags", "+faststart", "-f", "mp4", "-y", outputPath)
+}
+
+// mp4Box is a box of the moov tree. Boxes listed in containerBoxes are parsed
+// into children; all others keep their body as is.
+type mp4Box struct {
+	typ      string
+	body     []byte
+	children []*mp4Box
+}
+
+var containerBoxes = map[string]bool{"moov": true, "trak": true, "edts": true, "mdia": true, "minf": true, "stbl": true}
+
+// TrimEditList trims an MP4 or MOV file without ffmpeg by giving every track
+// an edit list that starts at start and lasts until end. Players that honor
+// edit lists - browsers, QuickTime, VLC, ffmpeg - show only that part; the
+// media data is kept, so the file does not get smaller. Fragmented files and
+// tracks with multi-entry edit lists are not supported.
+func TrimEditList(data []byte, start, end time.Duration) ([]byte, error) {
+	if end <= start {
+		return nil, fmt.Errorf("end must be after start")
+	}
+	moov, moovStart, moovEnd, err := parseMoov(data)
+	if err != nil {
+		return nil, err
+	}
+	if moov.child("mvex") != nil {
+		return nil, fmt.Errorf("fragmented MP4 files are not supported")
+	}
+
+	mvhd := moov.child("mvhd")
+	if mvhd == nil {
+		return nil, fmt.Errorf("mvhd box not found")
+	}
+	movieScale, err := headerTimescale(mvhd.body, 12, 20)
+	if err != nil {
+		return nil, fmt.Errorf("mvhd: %w", err)
+	}
+	length := scaled(end-start, movieScale)
+	if err := setHeaderDuration(mvhd.body, 16, 24, length); err != nil {
+		return nil, fmt.Errorf("mvhd: %w", err)
+	}
+
+	tracks := 0
+	for _, trak := range moov.children {
+		if trak.typ != "trak" {
+			continue
+		}
+		tracks++
+		if err := trimTrack(trak, movieScale, start, length); err != nil {
+			return nil, fmt.Errorf("track %d: %w", tracks, err)
+		}
+	}
+	if tracks == 0 {
+		return nil, fmt.Errorf("no tracks found")
+	}
+
+	newMoov := moov.bytes()
+	// Media data after the moov box moves by the change in its size
+	if delta := int64(len(newMoov)) - int64(moovEnd-moovStart); delta != 0 {
+		if err := shiftChunkOffsets(moov, int64(moovEnd), delta); err != nil {
+			return nil, err
+		}
+		newMoov = moov.bytes()
+	}
+
+	out := make([]byte, 0, len(data)+len(newMoov)-(moovEnd-moovStart))
+	out = append(out, data[:moovStart]...)
+	out = append(out, newMoov...)
+	return append(out, data[moovEnd:]...), nil
+}
+
+// trimTrack replaces the edit list of a track with one edit showing length
+// (in the movie timescale) of its media from start
+func trimTrack(trak *mp4Box, movieScale uint64, start time.Duration, length uint64) error {
+	tkhd := trak.child("tkhd")
+	mdhd := trak.path("mdia", "mdhd")
+	if tkhd == nil || mdhd == nil {
+		return fmt.Errorf("tkhd or mdhd box not found")
+	}
+	mediaScale, err := headerTimescale(mdhd.body, 12, 20)
+	if err != nil {
+		return fmt.Errorf("mdhd: %w", err)
+	}
+
+	// An existing single edit may skip encoder delay at the start of the
+	// media; the trimmed edit starts that much later too
+	var offset uint64
+	if edts := trak.child("edts"); edts != nil {
+		if elst := edts.child("elst"); elst != nil {
+			if offset, err = singleEditOffset(elst.body); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := setHeaderDuration(tkhd.body, 20, 28, length); err != nil {
+		return fmt.Errorf("tkhd: %w", err)
+	}
+	edts := &mp4Box{typ: "edts", children: []*mp4Box{{typ: "elst", body: editList(length, offset+scaled(start, mediaScale))}}}
+	children := make([]*mp4Box, 0, len(trak.children)+1)
+	for _, c := range trak.children {
+		switch c.typ {
+		case "edts":
+		case "tkhd":
+			children = append(children, c, edts)
+		default:
+			children = append(children, c)
+		}
+	}
+	trak.children = children
+	return nil
+}
+
+// singleEditOffset returns the media time of an edit list with one edit
+func singleEditOffset(elst []byte) (uint64, error) {
+	if len(elst) < 8 {
+		return 0, fmt.Errorf("elst box too short")
+	}
+	count := binary.BigEndian.Uint32(elst[4:])
+	if count == 0 {
+		return 0, nil
+	}
+	if count > 1 {
+		return 0, fmt.Errorf("edit lists with %d edits are not supported", count)
+	}
+	switch {
+	case elst[0] == 0 && len(elst) >= 20:
+		mediaTime := int32(binary.BigEndian.Uint32(elst[12:]))
+		if mediaTime < 0 {
+			return 0, fmt.Errorf("empty edits are not supported")
+		}
+		return uint64(mediaTime), nil
+	case elst[0] == 1 && len(elst) >= 28:
+		mediaTime := int64(binary.BigEndian.Uint64(elst[16:]))
+		if mediaTime < 0 {
+			return 0, fmt.Errorf("empty edits are not supported")
+		}
+		return uint64(mediaTime), nil
+	}
+	return 0, fmt.Errorf("unsupported elst box")
+}
+
+// editList returns the body of a version 1 elst box with one edit at normal
+// speed
+func editList(segmentDuration, mediaTime uint64) []byte {
+	body := make([]byte, 28)
+	body[0] = 1
+	binary.BigEndian.PutUint32(body[4:], 1)
+	binary.BigEndian.PutUint64(body[8:], segmentDuration)
+	binary.BigEndian.PutUint64(body[16:], mediaTime)
+	binary.BigEndian.PutUint32(body[24:], 0x00010000)
+	return body
+}
+
+// headerTimescale reads the timescale of an mvhd or mdhd box body, which is
+// at v0 or v1 depending on the box version
+func headerTimescale(body []byte, v0, v1 int) (uint64, error) {
+	at := v0
+	if len(body) > 0 && body[0] == 1 {
+		at = v1
+	}
+	if len(body) < at+4 {
+		return 0, fmt.Errorf("box too short")
+	}
+	scale := uint64(binary.BigEndian.Uint32(body[at:]))
+	if scale == 0 {
+		return 0, fmt.Errorf("no timescale")
+	}
+	return scale, nil
+}
+
+// setHeaderDuration sets the duration of an mvhd or tkhd box body, which is a
+// 32-bit field at v0 or a 64-bit field at v1 depending on the box version
+func setHeaderDuration(body []byte, v0, v1 int, duration uint64) error {
+	if len(body) > 0 && body[0] == 1 {
+		if len(body) < v1+8 {
+			return fmt.Errorf("box too short")
+		}
+		binary.BigEndian.PutUint64(body[v1:], duration)
+		return nil
+	}
+	if len(body) < v0+4 {
+		return fmt.Errorf("box too short")
+	}
+	if duration > math.MaxUint32 {
+		return fmt.Errorf("duration does not fit a version 0 box")
+	}
+	binary.BigEndian.PutUint32(body[v0:], uint32(duration))
+	return nil
+}
+
+// shiftChunkOffsets moves the chunk offsets of all tracks that point at or
+// after from by delta
+func shiftChunkOffsets(moov *mp4Box, from, delta int64) error {
+	for _, trak := range moov.children {
+		if trak.typ != "trak" {
+			continue
+		}
+		stbl := trak.path("mdia", "minf", "stbl")
+		if stbl == nil {
+			continue
+		}
+		for _, c := range stbl.children {
+			if (c.typ != "stco" && c.typ != "co64") || len(c.body) < 8 {
+				continue
+			}
+			count := int(binary.BigEndian.Uint32(c.body[4:]))
+			width := 4
+			if c.typ == "co64" {
+				width = 8
+			}
+			if len(c.body) < 8+count*width {
+				return fmt.Errorf("%s box too short", c.typ)
+			}
+			for i := 0; i < count; i++ {
+				entry := c.body[8+i*width:]
+				if width == 8 {
+					if v := int64(binary.BigEndian.Uint64(entry)); v >= from {
+						binary.BigEndian.PutUint64(entry, uint64(v+delta))
+					}
+					continue
+				}
+				if v := int64(binary.BigEndian.Uint32(entry)); v >= from {
+					if v+delta > math.MaxUint32 {
+						return fmt.Errorf("chunk offset overflows stco")
+					}
+					binary.BigEndian.PutUint32(entry, uint32(v+delta))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// scaled converts d to units of timescale
+func scaled(d time.Duration, timescale uint64) uint64 {
+	return uint64(math.Round(d.Seconds() * float64(timescale)))
+}
+
+// parseMoov parses the moov box of an MP4 file and returns it with its start
+// and end offsets
+func parseMoov(data []byte) (*mp4Box, int, int, error) {
+	start, end, err := topLevelBox(data, "moov")
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	children, err := parseBoxes(data[start+boxHeaderSize(data[start:]) : end])
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("invalid moov box: %w", err)
+	}
+	return &mp4Box{typ: "moov", children: children}, start, end, nil
+}
+
+// topLevelBox returns the start and end offsets of the first top-level box
+// of type name
+func topLevelBox(data []byte, name string) (int, int, error) {
+	for pos := 0; pos+8 <= len(data); {
+		size, err := boxSize(data[pos:])
+		if err != nil {
+			return 0, 0, err
+		}
+		if string(data[pos+4:pos+8]) == name {
+			return pos, pos + size, nil
+		}
+		pos += size
+	}
+	return 0, 0, fmt.Errorf("%s box not found", name)
+}
+
+// boxSize returns the size of the box at the start of data, header included
+func boxSize(data []byte) (int, error) {
+	if len(data) < 8 {
+		return 0, fmt.Errorf("truncated box header")
+	}
+	size := uint64(binary.BigEndian.Uint32(data))
+	switch size {
+	case 0:
+		size = uint64(len(data))
+	case 1:
+		if len(data) < 16 {
+			return 0, fmt.Errorf("truncated box header")
+		}
+		size = binary.BigEndian.Uint64(data[8:])
+	}
+	if size < uint64(boxHeaderSize(data)) || size > uint64(len(data)) {
+		return 0, fmt.Errorf("invalid %q box size %d", data[4:8], size)
+	}
+	return int(size), nil
+}
+
+// boxHeaderSize returns 16 for boxes with a 64-bit size and 8 otherwise
+func boxHeaderSize(data []byte) int {
+	if binary.BigEndian.Uint32(data) == 1 {
+		return 16
+	}
+	return 8
+}
+
+// parseBoxes parses sibling boxes, descending into containerBoxes
+func parseBoxes(data []byte) ([]*mp4Box, error) {
+	var boxes []*mp4Box
+	for pos := 0; pos < len(data); {
+		size, err := boxSize(data[pos:])
+		if err != nil {
+			return nil, err
+		}
+		b := &mp4Box{typ: string(data[pos+4 : pos+8])}
+		body := data[pos+boxHeaderSize(data[pos:]) : pos+size]
+		if containerBoxes[b.typ] {
+			if b.children, err = parseBoxes(body); err != nil {
+				return nil, err
+			}
+		} else {
+			b.body = append([]byte(nil), body...)
+		}
+		boxes = append(boxes, b)
+		pos += size
+	}
+	return boxes, nil
+}
+
+// child returns the first child box of type typ
+func (b *mp4Box) child(typ string) *mp4Box {
+	for _, c := range b.children {
+		if c.typ == typ {
+			return c
+		}
+	}
+	return nil
+}
+
+// path returns the box reached by following child types from b
+func (b *mp4Box) path(types ...string) *mp4Box {
+	for _, typ := range types {
+		if b = b.child(typ); b == nil {
+			return nil
+		}
+	}
+	return b
+}
+
+// bytes serializes the box and its children
+func (b *mp4Box) bytes() []byte {
+	body := b.body
+	if containerBoxes[b.typ] {
+		body = nil
+		for _, c := range b.children {
+			body = append(body, c.bytes()...)
+		}
+	}
+	if size := uint64(len(body)) + 8; size <= math.MaxUint32 {
+		out := make([]byte, 8, size)
+		binary.BigEndian.PutUint32(out, uint32(size))
+		copy(out[4:], b.typ)
+		return append(out, body...)
+	}
+	out := make([]byte, 16, uint64(len(body))+16)
+	binary.BigEndian.PutUint32(out, 1)
+	copy(out[4:], b.typ)
+	binary.BigEndian.PutUint64(out[8:], uint64(len(body))+16)
+	return append(out, body...)
+}
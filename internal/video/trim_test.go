@@ -0,0 +1,111 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trimTestMP4 builds an MP4 with the moov box before the media data: one
+// track with a 12800 Hz media timescale, an edit skipping 1024 units of
+// encoder delay and two chunks marked "AAAA" and "BBBB"
+func trimTestMP4() []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:], 8000)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[20:], 8000)
+	elst := make([]byte, 20)
+	binary.BigEndian.PutUint32(elst[4:], 1)
+	binary.BigEndian.PutUint32(elst[8:], 8000)
+	binary.BigEndian.PutUint32(elst[12:], 1024)
+	binary.BigEndian.PutUint32(elst[16:], 0x00010000)
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], 12800)
+
+	build := func(first, second uint32) []byte {
+		stco := make([]byte, 16)
+		binary.BigEndian.PutUint32(stco[4:], 2)
+		binary.BigEndian.PutUint32(stco[8:], first)
+		binary.BigEndian.PutUint32(stco[12:], second)
+		trak := box("trak", bytes.Join([][]byte{
+			box("tkhd", tkhd),
+			box("edts", box("elst", elst)),
+			box("mdia", append(box("mdhd", mdhd), box("minf", box("stbl", box("stco", stco)))...)),
+		}, nil))
+		return bytes.Join([][]byte{
+			box("ftyp", []byte("isom\x00\x00\x02\x00")),
+			box("moov", append(box("mvhd", mvhd), trak...)),
+			box("mdat", []byte("AAAABBBB")),
+		}, nil)
+	}
+	file := build(0, 0)
+	mdat := uint32(len(file) - 8)
+	return build(mdat, mdat+4)
+}
+
+func TestTrimEditList(t *testing.T) {
+	out, err := TrimEditList(trimTestMP4(), 2*time.Second, 5*time.Second)
+	if err != nil {
+		t.Fatalf("TrimEditList: %v", err)
+	}
+	if d, err := Duration(bytes.NewReader(out)); err != nil || d != 3*time.Second {
+		t.Errorf("Duration = %v, %v; want 3s", d, err)
+	}
+
+	start, end, err := topLevelBox(out, "moov")
+	if err != nil {
+		t.Fatal(err)
+	}
+	children, err := parseBoxes(out[start+8 : end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	trak := (&mp4Box{children: children}).child("trak")
+	if trak.children[0].typ != "tkhd" || trak.children[1].typ != "edts" {
+		t.Errorf("trak children start with %s, %s; want tkhd, edts", trak.children[0].typ, trak.children[1].typ)
+	}
+	if d := binary.BigEndian.Uint32(trak.child("tkhd").body[20:]); d != 3000 {
+		t.Errorf("tkhd duration = %d, want 3000", d)
+	}
+	elst := trak.path("edts", "elst").body
+	if n := binary.BigEndian.Uint32(elst[4:]); n != 1 {
+		t.Fatalf("elst has %d edits, want 1", n)
+	}
+	// The new edit keeps skipping the encoder delay: 1024 + 2s * 12800
+	if segment, mediaTime := binary.BigEndian.Uint64(elst[8:]), binary.BigEndian.Uint64(elst[16:]); segment != 3000 || mediaTime != 26624 {
+		t.Errorf("edit = %d units from %d, want 3000 from 26624", segment, mediaTime)
+	}
+
+	// Chunk offsets still point at the chunks after the moov box grew
+	stco := trak.path("mdia", "minf", "stbl", "stco").body
+	for i, want := range []string{"AAAA", "BBBB"} {
+		offset := binary.BigEndian.Uint32(stco[8+4*i:])
+		if got := string(out[offset : offset+4]); got != want {
+			t.Errorf("chunk %d points at %q, want %q", i+1, got, want)
+		}
+	}
+}
+
+func TestTrimEditListErrors(t *testing.T) {
+	if _, err := TrimEditList(trimTestMP4(), 5*time.Second, 2*time.Second); err == nil {
+		t.Error("end before start accepted")
+	}
+	if _, err := TrimEditList(box("ftyp", []byte("isom")), 0, time.Second); err == nil {
+		t.Error("file without moov accepted")
+	}
+}
+
+func TestTrimArgs(t *testing.T) {
+	args := strings.Join(trimArgs("in.mp4", "out.mp4", 1500*time.Millisecond, 4*time.Second, true), " ")
+	for _, want := range []string{"-ss 1.500 -i in.mp4 -t 2.500", "-c:v libx264", "-movflags +faststart", "-y out.mp4"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("trimArgs = %q, missing %q", args, want)
+		}
+	}
+	if args := strings.Join(trimArgs("in.mp4", "out.mp4", 0, time.Second, false), " "); !strings.Contains(args, "-c copy") {
+		t.Errorf("trimArgs without re-encoding = %q, want stream copy", args)
+	}
+}
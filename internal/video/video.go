@@ -0,0 +1,163 @@
+// Package video provides container parsing, timeline helpers used to split
+// long videos into segments for analysis, ffmpeg-based frame extraction and
+// trimming, and trimming of MP4 files through edit lists without ffmpeg.
+package video
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration reads the duration of an MP4 or QuickTime (MOV) file from the
+// movie header (moov/mvhd) box
+func Duration(r io.ReadSeeker) (time.Duration, error) {
+	moov, err := findBox(r, "moov", -1)
+	if err != nil {
+		return 0, err
+	}
+	mvhd, err := findBox(r, "mvhd", moov)
+	if err != nil {
+		return 0, err
+	}
+	if mvhd > 1<<20 {
+		return 0, fmt.Errorf("mvhd box too large")
+	}
+
+	body := make([]byte, mvhd)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, fmt.Errorf("failed to read mvhd box: %w", err)
+	}
+	var timescale, units uint64
+	switch {
+	case len(body) >= 20 && body[0] == 0:
+		timescale = uint64(binary.BigEndian.Uint32(body[12:]))
+		units = uint64(binary.BigEndian.Uint32(body[16:]))
+	case len(body) >= 32 && body[0] == 1:
+		timescale = uint64(binary.BigEndian.Uint32(body[20:]))
+		units = binary.BigEndian.Uint64(body[24:])
+	default:
+		return 0, fmt.Errorf("unsupported mvhd box")
+	}
+	if timescale == 0 {
+		return 0, fmt.Errorf("mvhd box has no timescale")
+	}
+	return time.Duration(float64(units) / float64(timescale) * float64(time.Second)), nil
+}
+
+// findBox scans sibling boxes from the current position until one of type
+// name is found, leaving the reader at the start of its body and returning
+// the body size. limit bounds the scan to a parent box body (-1 for the whole
+// file).
+func findBox(r io.ReadSeeker, name string, limit int64) (int64, error) {
+	header := make([]byte, 16)
+	for limit < 0 || limit >= 8 {
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return 0, fmt.Errorf("%s box not found", name)
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		headerLen := int64(8)
+		switch size {
+		case 1: // 64-bit size follows the type
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return 0, fmt.Errorf("truncated %q box", header[4:8])
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:]))
+			headerLen = 16
+		case 0: // box extends to the end of the file
+			if string(header[4:8]) != name {
+				return 0, fmt.Errorf("%s box not found", name)
+			}
+			pos, err := r.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return 0, err
+			}
+			end, err := r.Seek(0, io.SeekEnd)
+			if err != nil {
+				return 0, err
+			}
+			if _, err := r.Seek(pos, io.SeekStart); err != nil {
+				return 0, err
+			}
+			return end - pos, nil
+		}
+		if size < headerLen {
+			return 0, fmt.Errorf("invalid %q box size", header[4:8])
+		}
+		if string(header[4:8]) == name {
+			return size - headerLen, nil
+		}
+		if _, err := r.Seek(size-headerLen, io.SeekCurrent); err != nil {
+			return 0, err
+		}
+		if limit >= 0 {
+			limit -= size
+		}
+	}
+	return 0, fmt.Errorf("%s box not found", name)
+}
+
+// Segment is a span of a video's timeline
+type Segment struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Split divides a video of the given duration into consecutive segments of
+// length. A remainder shorter than a quarter of length is merged into the
+// last segment rather than analysed on its own.
+func Split(total, length time.Duration) []Segment {
+	if total <= 0 || length <= 0 || total <= length {
+		return []Segment{{Start: 0, End: total}}
+	}
+	var segments []Segment
+	for start := time.Duration(0); start < total; start += length {
+		end := min(start+length, total)
+		if len(segments) > 0 && end-start < length/4 {
+			segments[len(segments)-1].End = end
+			break
+		}
+		segments = append(segments, Segment{Start: start, End: end})
+	}
+	return segments
+}
+
+// ParseTimestamp parses an "SS", "MM:SS" or "HH:MM:SS" timestamp, allowing
+// fractional seconds
+func ParseTimestamp(s string) (time.Duration, bool) {
+	fields := strings.Split(strings.TrimSpace(s), ":")
+	if len(fields) > 3 || fields[0] == "" {
+		return 0, false
+	}
+	var total float64
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil || v < 0 {
+			return 0, false
+		}
+		total = total*60 + v
+	}
+	return time.Duration(total * float64(time.Second)), true
+}
+
+// FormatTimestamp formats d as MM:SS, or HH:MM:SS from one hour on
+func FormatTimestamp(d time.Duration) string {
+	secs := int(d.Round(time.Second) / time.Second)
+	if secs >= 3600 {
+		return fmt.Sprintf("%02d:%02d:%02d", secs/3600, secs/60%60, secs%60)
+	}
+	return fmt.Sprintf("%02d:%02d", secs/60, secs%60)
+}
+
+// ShiftTimestamp moves a timestamp relative to a segment onto the full video's
+// timeline. Timestamps that cannot be parsed are returned unchanged.
+func ShiftTimestamp(s string, offset time.Duration) string {
+	d, ok := ParseTimestamp(s)
+	if !ok {
+		return s
+	}
+	return FormatTimestamp(d + offset)
+}
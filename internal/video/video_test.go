@@ -0,0 +1,115 @@
+package video
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+func box(name string, body []byte) []byte {
+	out := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(out, uint32(8+len(body)))
+	copy(out[4:], name)
+	return append(out, body...)
+}
+
+// testMP4 builds a minimal MP4 whose movie header declares duration units at
+// the given timescale
+func testMP4(version byte, timescale uint32, units uint64) []byte {
+	var mvhd []byte
+	if version == 0 {
+		mvhd = make([]byte, 100)
+		binary.BigEndian.PutUint32(mvhd[12:], timescale)
+		binary.BigEndian.PutUint32(mvhd[16:], uint32(units))
+	} else {
+		mvhd = make([]byte, 112)
+		mvhd[0] = 1
+		binary.BigEndian.PutUint32(mvhd[20:], timescale)
+		binary.BigEndian.PutUint64(mvhd[24:], units)
+	}
+	var file []byte
+	file = append(file, box("ftyp", []byte("isom\x00\x00\x02\x00"))...)
+	file = append(file, box("mdat", make([]byte, 64))...)
+	file = append(file, box("moov", append(box("trak", make([]byte, 16)), box("mvhd", mvhd)...))...)
+	return file
+}
+
+func TestDuration(t *testing.T) {
+	d, err := Duration(bytes.NewReader(testMP4(0, 1000, 754500)))
+	if err != nil || d != 754500*time.Millisecond {
+		t.Errorf("version 0: got %v, %v; want 12m34.5s", d, err)
+	}
+	d, err = Duration(bytes.NewReader(testMP4(1, 90000, 90000*3*3600)))
+	if err != nil || d != 3*time.Hour {
+		t.Errorf("version 1: got %v, %v; want 3h", d, err)
+	}
+
+	if _, err := Duration(bytes.NewReader(box("ftyp", []byte("isom")))); err == nil {
+		t.Error("expected an error for a file without a movie header")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := Split(25*time.Minute, 10*time.Minute)
+	want := []Segment{{0, 10 * time.Minute}, {10 * time.Minute, 20 * time.Minute}, {20 * time.Minute, 25 * time.Minute}}
+	if len(got) != len(want) {
+		t.Fatalf("Split(25m, 10m) = %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("segment %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+
+	// A short remainder is folded into the last segment
+	got = Split(21*time.Minute, 10*time.Minute)
+	if len(got) != 2 || got[1].End != 21*time.Minute {
+		t.Errorf("Split(21m, 10m) = %v, want two segments ending at 21m", got)
+	}
+
+	if got := Split(5*time.Minute, 10*time.Minute); len(got) != 1 || got[0].End != 5*time.Minute {
+		t.Errorf("Split(5m, 10m) = %v, want one segment", got)
+	}
+}
+
+func TestTimestamps(t *testing.T) {
+	for in, want := range map[string]time.Duration{
+		"42":       42 * time.Second,
+		"01:30":    90 * time.Second,
+		"1:02:03":  time.Hour + 2*time.Minute + 3*time.Second,
+		" 00:05.5": 5500 * time.Millisecond,
+	} {
+		if got, ok := ParseTimestamp(in); !ok || got != want {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	for _, bad := range []string{"", "soon", "1:2:3:4", "-1:00"} {
+		if _, ok := ParseTimestamp(bad); ok {
+			t.Errorf("ParseTimestamp(%q) accepted an invalid timestamp", bad)
+		}
+	}
+
+	if got := ShiftTimestamp("02:15", 50*time.Minute); got != "52:15" {
+		t.Errorf("ShiftTimestamp(02:15, 50m) = %q", got)
+	}
+	if got := ShiftTimestamp("09:59", 55*time.Minute); got != "01:04:59" {
+		t.Errorf("ShiftTimestamp(09:59, 55m) = %q", got)
+	}
+	if got := ShiftTimestamp("the end", time.Minute); got != "the end" {
+		t.Errorf("ShiftTimestamp kept %q, want it unchanged", got)
+	}
+}
+
+func TestFrameArgs(t *testing.T) {
+	args := strings.Join(frameArgs("in.mp4", 90500*time.Millisecond, 320), " ")
+	for _, want := range []string{"-ss 90.500 -i in.mp4", "-frames:v 1", "min(iw,320)", "-vcodec mjpeg -"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("frameArgs = %q, missing %q", args, want)
+		}
+	}
+	if args := strings.Join(frameArgs("in.mp4", 0, 0), " "); strings.Contains(args, "-vf") {
+		t.Errorf("frameArgs without maxDim = %q, want no scale filter", args)
+	}
+}
@@ -0,0 +1,57 @@
+// Package warnings collects the non-fatal conditions of a tool call, such as
+// an ignored parameter, a fallback or a failed post-processing step, so they
+// are returned to the calling agent instead of only appearing in server logs.
+package warnings
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+)
+
+type collectorKey struct{}
+
+// Collector accumulates the warnings of one tool call. It is safe for
+// concurrent use.
+type Collector struct {
+	mu   sync.Mutex
+	list []string
+}
+
+// WithCollector returns a context whose warnings are recorded on the returned
+// collector. Warnings are recorded on the innermost collector only.
+func WithCollector(ctx context.Context) (context.Context, *Collector) {
+	c := &Collector{}
+	return context.WithValue(ctx, collectorKey{}, c), c
+}
+
+// Add logs a warning and records it on the collector of ctx, if any. Repeated
+// warnings are recorded once.
+func Add(ctx context.Context, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", message)
+
+	c, ok := ctx.Value(collectorKey{}).(*Collector)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, existing := range c.list {
+		if existing == message {
+			return
+		}
+	}
+	c.list = append(c.list, message)
+}
+
+// List returns the recorded warnings in the order they were added
+func (c *Collector) List() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.list) == 0 {
+		return nil
+	}
+	return append([]string(nil), c.list...)
+}
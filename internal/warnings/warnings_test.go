@@ -0,0 +1,49 @@
+package warnings
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCollector(t *testing.T) {
+	ctx, c := WithCollector(context.Background())
+	Add(ctx, "output_directory %q ignored", "/tmp/out")
+	Add(ctx, "fell back to %s", "prompt concatenation")
+	Add(ctx, "output_directory %q ignored", "/tmp/out")
+
+	want := []string{`output_directory "/tmp/out" ignored`, "fell back to prompt concatenation"}
+	if got := c.List(); !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %q, want %q", got, want)
+	}
+}
+
+func TestAddWithoutCollector(t *testing.T) {
+	Add(context.Background(), "only logged")
+}
+
+func TestInnermostCollector(t *testing.T) {
+	ctx, outer := WithCollector(context.Background())
+	inner, c := WithCollector(ctx)
+	Add(inner, "inner")
+	if outer.List() != nil || len(c.List()) != 1 {
+		t.Errorf("outer = %q, inner = %q", outer.List(), c.List())
+	}
+}
+
+func TestConcurrentAdd(t *testing.T) {
+	ctx, c := WithCollector(context.Background())
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Add(ctx, "warning %d", i)
+		}(i)
+	}
+	wg.Wait()
+	if len(c.List()) != 8 {
+		t.Errorf("expected 8 warnings, got %d", len(c.List()))
+	}
+}
@@ -0,0 +1,232 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Event types sent to webhook receivers
+const (
+	EventCompleted = "generation.completed"
+	EventFailed    = "generation.failed"
+)
+
+// Signature headers. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the configured secret.
+const (
+	SignatureHeader = "X-Gemini-MCP-Signature"
+	TimestampHeader = "X-Gemini-MCP-Timestamp"
+	EventHeader     = "X-Gemini-MCP-Event"
+)
+
+// Event is the JSON payload POSTed when a generation finishes
+type Event struct {
+	Event        string    `json:"event"`
+	Tool         string    `json:"tool"`
+	Status       string    `json:"status"`
+	OperationID  string    `json:"operation_id,omitempty"`
+	SavedFiles   []string  `json:"saved_files,omitempty"`
+	DownloadURLs []string  `json:"download_urls,omitempty"`
+	ExpiresAt    string    `json:"expires_at,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ErrorCode    string    `json:"error_code,omitempty"` // See internal/toolerr
+	Tenant       string    `json:"tenant,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Notifier delivers events to a default URL or a per-request override
+type Notifier struct {
+	defaultURL     string
+	secret         string
+	allowedHosts   []string     // Hosts per-request URLs may point at
+	client         *http.Client // Delivers to the operator's default URL
+	overrideClient *http.Client // Delivers to per-request URLs, refusing private addresses
+	retries        int
+	backoff        time.Duration
+	pending        sync.WaitGroup // Deliveries in flight
+}
+
+// NewNotifier creates a notifier. defaultURL may be empty, in which case only
+// events with an explicit URL are delivered. secret may be empty to send
+// unsigned events. Per-request URLs are accepted only for allowedHosts;
+// without any, only the default URL is used.
+func NewNotifier(defaultURL, secret string, allowedHosts []string) *Notifier {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: refusePrivateAddress}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Notifier{
+		defaultURL:   defaultURL,
+		secret:       secret,
+		allowedHosts: allowedHosts,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		overrideClient: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			// A redirect could lead the signed event past the allowlist
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		retries: 3,
+		backoff: time.Second,
+	}
+}
+
+// ValidateURL checks that a per-request webhook URL is an absolute http(s)
+// URL whose host is allowed. Entries of the allowlist match a host exactly,
+// or any subdomain when written as "*.example.com".
+func (n *Notifier) ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q: must be an absolute http or https URL", raw)
+	}
+	if n == nil || len(n.allowedHosts) == 0 {
+		return fmt.Errorf("per-request webhook URLs are disabled on this server (WEBHOOK_ALLOWED_HOSTS is not set)")
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range n.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook URL host %q is not in WEBHOOK_ALLOWED_HOSTS", u.Hostname())
+}
+
+// refusePrivateAddress is a dialer control that stops connections to
+// loopback, private, link-local and other non-public addresses. It runs after
+// name resolution, so hosts that resolve to such addresses are refused too.
+func refusePrivateAddress(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("webhook address %q is not an IP address", host)
+	}
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return fmt.Errorf("webhook address %s is not a public address", ip)
+	}
+	return nil
+}
+
+// Notify delivers event in the background to overrideURL, or to the default
+// URL when overrideURL is empty. It does nothing if neither is set.
+func (n *Notifier) Notify(overrideURL string, event Event) {
+	if n == nil {
+		return
+	}
+	target, client := overrideURL, n.overrideClient
+	if target == "" {
+		target, client = n.defaultURL, n.client
+	}
+	if target == "" {
+		return
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now().UTC()
+	}
+
+	n.pending.Add(1)
+	go func() {
+		defer n.pending.Done()
+		if err := n.deliver(context.Background(), client, target, event); err != nil {
+			log.Printf("Webhook delivery of %s for %s failed: %v", event.Event, event.Tool, err)
+		}
+	}()
+}
+
+// Wait blocks until the deliveries in flight have finished, including their
+// retries, or ctx is done
+func (n *Notifier) Wait(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		n.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver POSTs event to target, retrying with exponential backoff on
+// network errors and non-2xx responses
+func (n *Notifier) deliver(ctx context.Context, client *http.Client, target string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, client, target, event.Event, body)
+		if err == nil || attempt >= n.retries {
+			return err
+		}
+		log.Printf("Webhook attempt %d/%d failed: %v, retrying in %v", attempt, n.retries, err, backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (n *Notifier) post(ctx context.Context, client *http.Client, target, eventType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, eventType)
+	if n.secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, timestamp)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(n.secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 signature of a payload sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
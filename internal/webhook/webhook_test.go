@@ -0,0 +1,108 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	var attempts int
+	var got Event
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		want := "sha256=" + Sign("s3cret", r.Header.Get(TimestampHeader), body)
+		if r.Header.Get(SignatureHeader) != want {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	n := NewNotifier("", "s3cret", nil)
+	n.backoff = time.Millisecond
+	err := n.deliver(context.Background(), n.client, srv.URL, Event{Event: EventCompleted, Tool: "veo_text_to_video", Status: "completed"})
+	if err != nil {
+		t.Fatalf("delivery failed: %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if got.Tool != "veo_text_to_video" || got.Event != EventCompleted {
+		t.Errorf("unexpected event: %+v", got)
+	}
+}
+
+func TestWaitForDeliveries(t *testing.T) {
+	delivered := make(chan struct{}, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		delivered <- struct{}{}
+	}))
+	defer srv.Close()
+
+	n := NewNotifier(srv.URL, "", nil)
+	n.Notify("", Event{Event: EventCompleted, Tool: "veo_text_to_video"})
+	if err := n.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	select {
+	case <-delivered:
+	default:
+		t.Error("Wait returned before the event was delivered")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	n := NewNotifier("", "", []string{"hooks.example.com", "*.example.org"})
+	for raw, ok := range map[string]bool{
+		"https://hooks.example.com/x":   true,
+		"https://a.b.example.org/x":     true,
+		"https://example.org/x":         false,
+		"https://evil.com/x":            false,
+		"http://localhost:9000":         false,
+		"http://169.254.169.254/latest": false,
+		"ftp://hooks.example.com":       false,
+		"/relative":                     false,
+	} {
+		if err := n.ValidateURL(raw); (err == nil) != ok {
+			t.Errorf("%s: unexpected result %v", raw, err)
+		}
+	}
+
+	if err := NewNotifier("", "", nil).ValidateURL("https://hooks.example.com/x"); err == nil {
+		t.Error("expected per-request URLs to be rejected without an allowlist")
+	}
+}
+
+func TestOverrideRefusesLoopback(t *testing.T) {
+	var received bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer srv.Close()
+
+	// Even an allowlisted host is not dialed when it resolves to loopback
+	n := NewNotifier("", "s3cret", []string{"127.0.0.1"})
+	n.retries = 1
+	if err := n.ValidateURL(srv.URL); err != nil {
+		t.Fatalf("ValidateURL: %v", err)
+	}
+	err := n.deliver(context.Background(), n.overrideClient, srv.URL, Event{Event: EventCompleted, Tool: "veo_text_to_video"})
+	if err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("expected the loopback override to be refused, got %v", err)
+	}
+	if received {
+		t.Error("the loopback receiver got the event")
+	}
+}
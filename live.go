@@ -0,0 +1,501 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/audio"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+const (
+	// maxLiveSessions caps concurrently open Live API sessions
+	maxLiveSessions = 8
+	// liveSessionIdleTimeout closes sessions that have not been used for a while
+	liveSessionIdleTimeout = 10 * time.Minute
+	// liveAudioChunkBytes is the size of each realtime audio message (~1s at 16kHz)
+	liveAudioChunkBytes = 32 * 1024
+	// liveOutputSampleRate is the Live API's audio output rate when not stated in the MIME type
+	liveOutputSampleRate = 24000
+)
+
+// Live API voice sessions
+type LiveSessionStartInput struct {
+	Model             string `json:"model,omitempty" jsonschema:"description:Live API model to connect to,default:gemini-live-2.5-flash-preview"`
+	ResponseModality  string `json:"response_modality,omitempty" jsonschema:"description:What the model replies with: 'audio' (spoken reply stored as WAV, plus transcript) or 'text',default:audio,enum:audio,enum:text"`
+	Voice             string `json:"voice,omitempty" jsonschema:"description:Prebuilt voice for audio replies (e.g., 'Puck', 'Charon', 'Kore', 'Fenrir', 'Aoede')"`
+	SystemInstruction string `json:"system_instruction,omitempty" jsonschema:"description:Optional system instruction that shapes the assistant's behavior for the whole session"`
+}
+
+type LiveSessionStartOutput struct {
+	SessionID        string `json:"session_id"`
+	Model            string `json:"model"`
+	ResponseModality string `json:"response_modality"`
+	IdleTimeout      string `json:"idle_timeout"`
+}
+
+type LiveSessionSendInput struct {
+	SessionID      string `json:"session_id" jsonschema:"description:Session ID returned by live_session_start"`
+	AudioPath      string `json:"audio_path,omitempty" jsonschema:"description:16-bit PCM WAV file with the user's speech. Can be a local file path or an object key returned by upload_media. Provide either audio_path or text."`
+	Text           string `json:"text,omitempty" jsonschema:"description:Text to send instead of audio. Provide either audio_path or text."`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" jsonschema:"description:How long to wait for the model to finish its reply,default:60"`
+	LinkTTL        string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget  string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type LiveSessionSendOutput struct {
+	SessionID        string            `json:"session_id"`
+	InputTranscript  string            `json:"input_transcript,omitempty"`
+	OutputTranscript string            `json:"output_transcript,omitempty"`
+	Text             string            `json:"text,omitempty"`
+	AudioFile        string            `json:"audio_file,omitempty"`
+	AudioSeconds     float64           `json:"audio_seconds,omitempty"`
+	Interrupted      bool              `json:"interrupted,omitempty"`
+	SavedFiles       []string          `json:"saved_files,omitempty"`
+	DataURIs         map[string]string `json:"data_uris,omitempty"`
+	DownloadURLs     []string          `json:"download_urls,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	GeneratedAt      string            `json:"generated_at"`
+}
+
+type LiveSessionStopInput struct {
+	SessionID string `json:"session_id" jsonschema:"description:Session ID returned by live_session_start"`
+}
+
+type LiveSessionStopOutput struct {
+	SessionID string `json:"session_id"`
+	Stopped   bool   `json:"stopped"`
+}
+
+// liveTurn is one complete model reply collected from the session
+type liveTurn struct {
+	audio            []byte
+	sampleRate       int
+	text             strings.Builder
+	inputTranscript  strings.Builder
+	outputTranscript strings.Builder
+	interrupted      bool
+}
+
+// liveSession wraps an open Live API connection and the goroutine reading from it
+type liveSession struct {
+	id       string
+	model    string
+	session  *genai.Session
+	turns    chan *liveTurn
+	err      error      // why the receive loop ended; valid once turns is closed
+	mu       sync.Mutex // serializes send/receive exchanges
+	lastUsed time.Time  // guarded by liveSessionManager.mu
+}
+
+// receiveLoop collects server messages into turns until the connection closes
+func (ls *liveSession) receiveLoop() {
+	defer close(ls.turns)
+
+	turn := &liveTurn{}
+	for {
+		msg, err := ls.session.Receive()
+		if err != nil {
+			ls.err = err
+			return
+		}
+		if msg.GoAway != nil {
+			log.Printf("Live session %s: server is closing the connection (time left: %v)", ls.id, msg.GoAway.TimeLeft)
+		}
+		content := msg.ServerContent
+		if content == nil {
+			continue
+		}
+
+		if content.ModelTurn != nil {
+			for _, part := range content.ModelTurn.Parts {
+				if part.InlineData != nil && strings.HasPrefix(part.InlineData.MIMEType, "audio/pcm") {
+					turn.audio = append(turn.audio, part.InlineData.Data...)
+					turn.sampleRate = pcmRate(part.InlineData.MIMEType)
+				} else if part.Text != "" {
+					turn.text.WriteString(part.Text)
+				}
+			}
+		}
+		if content.InputTranscription != nil {
+			turn.inputTranscript.WriteString(content.InputTranscription.Text)
+		}
+		if content.OutputTranscription != nil {
+			turn.outputTranscript.WriteString(content.OutputTranscription.Text)
+		}
+		if content.Interrupted {
+			turn.interrupted = true
+		}
+
+		if content.TurnComplete {
+			select {
+			case ls.turns <- turn:
+			default:
+				log.Printf("Live session %s: dropping unread reply", ls.id)
+			}
+			turn = &liveTurn{}
+		}
+	}
+}
+
+// pcmRate parses the sample rate from a MIME type such as "audio/pcm;rate=24000"
+func pcmRate(mimeType string) int {
+	if i := strings.Index(mimeType, "rate="); i >= 0 {
+		if rate, err := strconv.Atoi(mimeType[i+len("rate="):]); err == nil && rate > 0 {
+			return rate
+		}
+	}
+	return liveOutputSampleRate
+}
+
+// liveSessionManager tracks open Live API sessions
+type liveSessionManager struct {
+	sessions map[string]*liveSession
+	mu       sync.Mutex
+}
+
+// newLiveSessionManager creates a session manager; cleanupIdle closes idle
+// sessions
+func newLiveSessionManager() *liveSessionManager {
+	return &liveSessionManager{
+		sessions: make(map[string]*liveSession),
+	}
+}
+
+// add registers a session, failing if too many are open
+func (m *liveSessionManager) add(ls *liveSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sessions) >= maxLiveSessions {
+		return toolerr.Errorf(toolerr.QuotaExceeded, "too many open live sessions (max %d); stop one with live_session_stop", maxLiveSessions)
+	}
+	ls.lastUsed = time.Now()
+	m.sessions[ls.id] = ls
+	return nil
+}
+
+// get returns a session and marks it as used
+func (m *liveSessionManager) get(id string) (*liveSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ls, ok := m.sessions[id]
+	if !ok {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "live session %q not found (it may have been stopped or timed out)", id)
+	}
+	ls.lastUsed = time.Now()
+	return ls, nil
+}
+
+// remove closes and forgets a session, reporting whether it existed
+func (m *liveSessionManager) remove(id string) bool {
+	m.mu.Lock()
+	ls, ok := m.sessions[id]
+	delete(m.sessions, id)
+	m.mu.Unlock()
+
+	if ok {
+		if err := ls.session.Close(); err != nil {
+			log.Printf("Warning: failed to close live session %s: %v", id, err)
+		}
+	}
+	return ok
+}
+
+// CloseAll closes every open session
+func (m *liveSessionManager) CloseAll() {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.sessions))
+	for id := range m.sessions {
+		ids = append(ids, id)
+	}
+	m.mu.Unlock()
+
+	for _, id := range ids {
+		m.remove(id)
+	}
+}
+
+// cleanupIdle periodically closes sessions that have not been used recently,
+// until ctx is done
+func (m *liveSessionManager) cleanupIdle(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		m.mu.Lock()
+		var idle []string
+		for id, ls := range m.sessions {
+			if time.Since(ls.lastUsed) > liveSessionIdleTimeout {
+				idle = append(idle, id)
+			}
+		}
+		m.mu.Unlock()
+
+		for _, id := range idle {
+			log.Printf("Closing idle live session %s", id)
+			m.remove(id)
+		}
+	}
+}
+
+func (s *Server) handleLiveSessionStart(ctx context.Context, req *mcp.CallToolRequest, input LiveSessionStartInput) (*mcp.CallToolResult, LiveSessionStartOutput, error) {
+	// Set defaults
+	model := input.Model
+	if model == "" {
+		model = "gemini-live-2.5-flash-preview"
+	}
+	if err := s.allowlist.Check("live_session_start", model); err != nil {
+		return nil, LiveSessionStartOutput{}, err
+	}
+
+	modality := strings.ToLower(input.ResponseModality)
+	if modality == "" {
+		modality = "audio"
+	}
+
+	config := &genai.LiveConnectConfig{}
+	switch modality {
+	case "audio":
+		config.ResponseModalities = []genai.Modality{genai.ModalityAudio}
+		config.OutputAudioTranscription = &genai.AudioTranscriptionConfig{}
+		if input.Voice != "" {
+			config.SpeechConfig = &genai.SpeechConfig{
+				VoiceConfig: &genai.VoiceConfig{
+					PrebuiltVoiceConfig: &genai.PrebuiltVoiceConfig{VoiceName: input.Voice},
+				},
+			}
+		}
+	case "text":
+		config.ResponseModalities = []genai.Modality{genai.ModalityText}
+	default:
+		return nil, LiveSessionStartOutput{}, toolerr.Errorf(toolerr.InvalidInput, "response_modality must be 'audio' or 'text'")
+	}
+	config.InputAudioTranscription = &genai.AudioTranscriptionConfig{}
+	if input.SystemInstruction != "" {
+		config.SystemInstruction = genai.NewContentFromText(input.SystemInstruction, genai.RoleUser)
+	}
+
+	// The session outlives this request, so don't tie the connection to its context
+	session, err := s.client.Live.Connect(context.WithoutCancel(ctx), model, config)
+	if err != nil {
+		return nil, LiveSessionStartOutput{}, fmt.Errorf("failed to connect to Live API: %w", err)
+	}
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	ls := &liveSession{
+		id:      fmt.Sprintf("live_%x", b),
+		model:   model,
+		session: session,
+		turns:   make(chan *liveTurn, 4),
+	}
+	if err := s.liveSessions.add(ls); err != nil {
+		session.Close()
+		return nil, LiveSessionStartOutput{}, err
+	}
+	go ls.receiveLoop()
+
+	log.Printf("Started live session %s (model: %s, modality: %s)", ls.id, model, modality)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("Started live session %s. Send audio or text with live_session_send and close it with live_session_stop. Idle sessions close after %v.", ls.id, liveSessionIdleTimeout),
+			},
+		},
+	}, LiveSessionStartOutput{
+		SessionID:        ls.id,
+		Model:            model,
+		ResponseModality: modality,
+		IdleTimeout:      liveSessionIdleTimeout.String(),
+	}, nil
+}
+
+func (s *Server) handleLiveSessionSend(ctx context.Context, req *mcp.CallToolRequest, input LiveSessionSendInput) (*mcp.CallToolResult, LiveSessionSendOutput, error) {
+	if (input.AudioPath == "") == (input.Text == "") {
+		return nil, LiveSessionSendOutput{}, toolerr.Errorf(toolerr.InvalidInput, "exactly one of audio_path or text is required")
+	}
+
+	timeout := time.Duration(input.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	ls, err := s.liveSessions.get(input.SessionID)
+	if err != nil {
+		return nil, LiveSessionSendOutput{}, err
+	}
+
+	// Read the audio before taking the session lock
+	var pcm *audio.PCM
+	if input.AudioPath != "" {
+		localPath, cleanup, err := s.resolveInputPath(ctx, input.AudioPath)
+		if err != nil {
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to resolve input audio: %w", err)
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to read input audio: %w", err)
+		}
+		pcm, err = audio.DecodeWAV(data)
+		if err != nil {
+			return nil, LiveSessionSendOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid input audio: %w", err)
+		}
+	}
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	// Discard replies nobody waited for (e.g. after a previous timeout)
+drain:
+	for {
+		select {
+		case _, ok := <-ls.turns:
+			if !ok {
+				s.liveSessions.remove(ls.id)
+				return nil, LiveSessionSendOutput{}, fmt.Errorf("live session %s has ended: %w", ls.id, ls.err)
+			}
+		default:
+			break drain
+		}
+	}
+
+	if pcm != nil {
+		log.Printf("Live session %s: sending %.1fs of audio", ls.id, pcm.Duration())
+		for off := 0; off < len(pcm.Data); off += liveAudioChunkBytes {
+			end := min(off+liveAudioChunkBytes, len(pcm.Data))
+			if err := ls.session.SendRealtimeInput(genai.LiveRealtimeInput{
+				Audio: &genai.Blob{MIMEType: pcm.MIMEType(), Data: pcm.Data[off:end]},
+			}); err != nil {
+				return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to send audio: %w", err)
+			}
+		}
+		// Flush voice activity detection so the model replies without waiting for more audio
+		if err := ls.session.SendRealtimeInput(genai.LiveRealtimeInput{AudioStreamEnd: true}); err != nil {
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to end audio stream: %w", err)
+		}
+	} else {
+		log.Printf("Live session %s: sending text", ls.id)
+		if err := ls.session.SendRealtimeInput(genai.LiveRealtimeInput{Text: input.Text}); err != nil {
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to send text: %w", err)
+		}
+	}
+
+	var turn *liveTurn
+	select {
+	case t, ok := <-ls.turns:
+		if !ok {
+			s.liveSessions.remove(ls.id)
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("live session %s ended before replying: %w", ls.id, ls.err)
+		}
+		turn = t
+	case <-time.After(timeout):
+		return nil, LiveSessionSendOutput{}, toolerr.Errorf(toolerr.Timeout, "timed out after %v waiting for the model to reply", timeout)
+	case <-ctx.Done():
+		return nil, LiveSessionSendOutput{}, ctx.Err()
+	}
+
+	output := LiveSessionSendOutput{
+		SessionID:        ls.id,
+		InputTranscript:  strings.TrimSpace(turn.inputTranscript.String()),
+		OutputTranscript: strings.TrimSpace(turn.outputTranscript.String()),
+		Text:             turn.text.String(),
+		Interrupted:      turn.interrupted,
+		Metadata: map[string]string{
+			"model": ls.model,
+		},
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+
+	var contents []mcp.Content
+	if len(turn.audio) > 0 {
+		reply := &audio.PCM{Data: turn.audio, SampleRate: turn.sampleRate}
+		wav := audio.EncodeWAV(reply)
+		result, err := s.storage.Store(ctx, wav, "audio/wav", "live_audio")
+		if err != nil {
+			return nil, LiveSessionSendOutput{}, fmt.Errorf("failed to store reply audio: %w", err)
+		}
+		log.Printf("Stored live reply audio: %s (%.1fs)", result.Location, reply.Duration())
+
+		output.AudioFile = result.ObjectKey
+		output.AudioSeconds = reply.Duration()
+		output.SavedFiles = []string{result.ObjectKey}
+		output.DataURIs = s.addDataURI(nil, result, wav)
+		if s.storage.IsRemote() {
+			output.DownloadURLs = []string{result.Location}
+			if result.ExpiresAt != nil {
+				output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+		} else {
+			contents = s.mediaContent(wav, result)
+		}
+	}
+
+	// Build a readable summary of the exchange
+	var summary strings.Builder
+	if output.InputTranscript != "" {
+		fmt.Fprintf(&summary, "You said: %s\n", output.InputTranscript)
+	}
+	if output.Text != "" {
+		fmt.Fprintf(&summary, "Model: %s\n", output.Text)
+	} else if output.OutputTranscript != "" {
+		fmt.Fprintf(&summary, "Model said: %s\n", output.OutputTranscript)
+	}
+	if output.AudioFile != "" {
+		fmt.Fprintf(&summary, "Reply audio: %.1fs", output.AudioSeconds)
+		if len(output.DownloadURLs) > 0 {
+			fmt.Fprintf(&summary, "\n%s", output.DownloadURLs[0])
+			if output.ExpiresAt != "" {
+				fmt.Fprintf(&summary, "\n\nURL expires at: %s", output.ExpiresAt)
+			}
+		}
+	}
+	if output.Interrupted {
+		summary.WriteString("\n(reply was interrupted)")
+	}
+
+	return &mcp.CallToolResult{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: strings.TrimSpace(summary.String())}}, contents...),
+	}, output, nil
+}
+
+func (s *Server) handleLiveSessionStop(ctx context.Context, req *mcp.CallToolRequest, input LiveSessionStopInput) (*mcp.CallToolResult, LiveSessionStopOutput, error) {
+	if input.SessionID == "" {
+		return nil, LiveSessionStopOutput{}, toolerr.Errorf(toolerr.InvalidInput, "session_id is required")
+	}
+
+	stopped := s.liveSessions.remove(input.SessionID)
+	text := fmt.Sprintf("Stopped live session %s", input.SessionID)
+	if stopped {
+		log.Printf("Stopped live session %s", input.SessionID)
+	} else {
+		text = fmt.Sprintf("Live session %s was not open", input.SessionID)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: text,
+			},
+		},
+	}, LiveSessionStopOutput{SessionID: input.SessionID, Stopped: stopped}, nil
+}
@@ -3,11 +3,12 @@ package main
 import (
 	"context"
 	"crypto/rand"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -17,17 +18,59 @@ import (
 	"syscall"
 	"time"
 
+	"gemini-mcp/internal/bandwidth"
+	"gemini-mcp/internal/certs"
+	"gemini-mcp/internal/chaos"
+	"gemini-mcp/internal/chat"
 	"gemini-mcp/internal/common"
+	"gemini-mcp/internal/defaults"
+	"gemini-mcp/internal/drain"
+	"gemini-mcp/internal/fieldcrypt"
+	"gemini-mcp/internal/grpcapi"
+	"gemini-mcp/internal/httpclient"
+	"gemini-mcp/internal/i18n"
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/keypool"
+	"gemini-mcp/internal/lifecycle"
+	"gemini-mcp/internal/limiter"
+	"gemini-mcp/internal/media"
 	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/models"
+	"gemini-mcp/internal/music"
+	"gemini-mcp/internal/poller"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/respcache"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/service"
 	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/telemetry"
+	"gemini-mcp/internal/tempfiles"
+	"gemini-mcp/internal/tokens"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/upload"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+	"gemini-mcp/internal/webhook"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"google.golang.org/genai"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/reflection"
 )
 
 var (
 	transport   = flag.String("transport", "", "Transport type (stdio, http, or sse)")
 	showVersion = flag.Bool("version", false, "Show version information")
+	configFile  = flag.String("config", "", "JSON config file with settings and named profiles (default: CONFIG_FILE)")
+	profileName = flag.String("profile", "", "Config file profile to apply, e.g. dev, staging or prod (default: CONFIG_PROFILE)")
+
+	benchmark            = flag.Bool("benchmark", false, "Run a latency benchmark against the configured models and storage, then exit")
+	benchmarkModels      = flag.String("benchmark-models", "", "Comma-separated models to benchmark (default: gemini-2.5-flash)")
+	benchmarkIterations  = flag.Int("benchmark-iterations", 5, "Requests per model and storage operation")
+	benchmarkConcurrency = flag.Int("benchmark-concurrency", 1, "Benchmark requests in flight at once")
+	dumpSchemasFormat    = flag.String("dump-schemas", "", "Print the input/output JSON Schemas of all tools as 'json' or 'openapi', then exit")
+	doctorMode           = flag.Bool("doctor", false, "Check the configuration, Gemini API access, storage and directories, print a pass/fail report, then exit (also: 'gemini-mcp doctor')")
 )
 
 // Version information - these will be set during build
@@ -38,12 +81,18 @@ var (
 )
 
 const (
-	serviceName = "gemini-mcp"
+	serviceName      = "gemini-mcp"
+	geminiAPIBaseURL = "https://generativelanguage.googleapis.com/"
+
+	// shutdownTimeout bounds stopping the background routines and the final
+	// flush of webhook deliveries after the drain
+	shutdownTimeout = 30 * time.Second
 )
 
 // TempToken represents a one-time use temporary token
 type TempToken struct {
 	Token     string
+	Tenant    string // Tenant whose storage receives the upload (empty for none)
 	ExpiresAt time.Time
 }
 
@@ -56,17 +105,14 @@ type TokenManager struct {
 
 // NewTokenManager creates a new token manager with specified TTL
 func NewTokenManager(ttl time.Duration) *TokenManager {
-	tm := &TokenManager{
+	return &TokenManager{
 		tokens: make(map[string]*TempToken),
 		ttl:    ttl,
 	}
-	// Start cleanup goroutine
-	go tm.cleanupExpired()
-	return tm
 }
 
-// Generate creates a new one-time token
-func (tm *TokenManager) Generate() string {
+// Generate creates a new one-time token for uploads into tenant's storage
+func (tm *TokenManager) Generate(tenant string) string {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
@@ -77,55 +123,94 @@ func (tm *TokenManager) Generate() string {
 
 	tm.tokens[token] = &TempToken{
 		Token:     token,
+		Tenant:    tenant,
 		ExpiresAt: time.Now().Add(tm.ttl),
 	}
 
 	return token
 }
 
-// Validate checks if token is valid and consumes it (one-time use)
-func (tm *TokenManager) Validate(token string) bool {
+// Validate checks if token is valid and consumes it (one-time use),
+// returning the tenant it was issued for
+func (tm *TokenManager) Validate(token string) (tenant string, ok bool) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	t, exists := tm.tokens[token]
 	if !exists {
-		return false
+		return "", false
 	}
 
 	// Check expiration
 	if time.Now().After(t.ExpiresAt) {
 		delete(tm.tokens, token)
-		return false
+		return "", false
 	}
 
 	// Consume token (one-time use)
 	delete(tm.tokens, token)
-	return true
+	return t.Tenant, true
 }
 
-// cleanupExpired periodically removes expired tokens
-func (tm *TokenManager) cleanupExpired() {
+// Run periodically removes expired tokens until ctx is done
+func (tm *TokenManager) Run(ctx context.Context) {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
-	for range ticker.C {
-		tm.mu.Lock()
-		now := time.Now()
-		for token, t := range tm.tokens {
-			if now.After(t.ExpiresAt) {
-				delete(tm.tokens, token)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			tm.mu.Lock()
+			for token, t := range tm.tokens {
+				if now.After(t.ExpiresAt) {
+					delete(tm.tokens, token)
+				}
 			}
+			tm.mu.Unlock()
 		}
-		tm.mu.Unlock()
 	}
 }
 
 type Server struct {
-	config       *common.Config
-	client       *genai.Client
-	storage      storage.Storage
-	tokenManager *TokenManager
+	config        *common.Config
+	client        *genai.Client
+	vertex        *genai.Client // Vertex AI client for imagen_edit (nil unless GOOGLE_PROJECT_ID)
+	storage       storage.Storage
+	tokenManager  *TokenManager
+	uploads       *upload.Manager // Resumable uploads to /upload in progress
+	serviceTokens *tokens.Store   // Tokens managed through /admin/tokens (nil unless TOKEN_STORE_FILE)
+	imageLimiter  *limiter.Limiter
+	videoLimiter  *limiter.Limiter
+	liveSessions  *liveSessionManager
+	videoJobs     *videoJobStore     // Veo generations started with async=true
+	scheduler     *jobScheduler      // Tool calls deferred with schedule_job
+	music         *music.Client      // Lyria RealTime music generation
+	videoPoller   *poller.Poller     // Waits for running Veo operations
+	pendingOps    *pendingOperations // Veo operations being polled, saved if the drain times out
+	drainer       *drain.Drainer     // Tool calls and background jobs in flight, waited for on shutdown
+	routines      *lifecycle.Group   // Background routines, stopped in order after the drain
+	media         *media.Toolkit     // ffmpeg, or pure-Go fallbacks, for video and image processing
+	chats         *chat.Store
+	webhooks      *webhook.Notifier
+	fileSigner    *storage.URLSigner  // Set when local files are served over HTTP
+	fileLimiter   *bandwidth.Limiter  // Caps the bandwidth of served files (nil = unlimited)
+	targets       *storage.Targets    // Storage targets selectable with storage_target (nil unless STORAGE_TARGETS)
+	trash         *storage.Trash      // Keeps objects removed by delete_media and purge_media restorable
+	mcpServer     *mcp.Server         // Server the tools are registered on
+	pathPolicy    *storage.PathPolicy // Governs user-supplied local paths (nil allows all)
+	allowlist     models.Allowlist    // Models each tool may use (empty allows all)
+	safetyStats   *safety.Stats       // Tool calls and safety blocks per tool and caller
+	telemetry     *telemetry.Reporter // Anonymous usage statistics (nil unless TELEMETRY_ENABLED)
+	tempFiles     *tempfiles.Manager  // Temp directory swept for orphaned files
+	responseCache *respcache.Cache    // Results of earlier generation calls (nil unless RESPONSE_CACHE_ENABLED)
+	quotas        *quota.Tracker      // Generations of each HTTP caller, counted against quotas (nil without HTTP)
+	quotaLimits   quota.Limits        // QUOTAS, for callers whose managed token sets none
+	toolDefaults  defaults.Overrides  // DEFAULTS_<TOOL>_<ARGUMENT>, for calls that bypass the MCP server
+
+	pipelineTools    map[string]pipelineTool // Tools run_pipeline can call
+	schedulableTools map[string]pipelineTool // Tools schedule_job can defer
 }
 
 // Input types for tools
@@ -141,9 +226,25 @@ type GeminiImageGenerationInput struct {
 	IncludeText     bool     `json:"include_text,omitempty" jsonschema:"description:Whether to include high-fidelity text rendering in the image. Enable for images that need clear text elements.,default:false"`
 	Tags            []string `json:"tags,omitempty" jsonschema:"description:Optional tags to help categorize or describe the generated image"`
 	OutputDirectory string   `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the generated image and metadata will be saved. If not provided, files will be saved to the default output directory."`
+
+	TransparentBackground bool   `json:"transparent_background,omitempty" jsonschema:"description:Produce a PNG with a transparent (alpha channel) background. The subject is generated isolated on a plain background which is then removed. Ideal for logos, stickers, icons, and UI assets.,default:false"`
+	NegativePrompt        string `json:"negative_prompt,omitempty" jsonschema:"description:Elements, styles, or artifacts that should NOT appear in the image. Passed to Imagen models as a native negative prompt; appended to the prompt as an avoid-list for Gemini models."`
+	SeamlessTile          bool   `json:"seamless_tile,omitempty" jsonschema:"description:Generate a seamlessly tileable texture for game and 3D workflows. The result is checked for visible seams when wrapped and its edges are blended if needed. Defaults aspect_ratio to 1:1.,default:false"`
+	Preset                string `json:"preset,omitempty" jsonschema:"description:Optional output preset that sets aspect ratio and resolution and crops the result to exact pixel dimensions. Overrides aspect_ratio and image_size. Supported: 'favicon' (512x512), 'og_image' (1200x630), 'twitter_card' (1200x628), 'twitter_summary' (144x144), 'app_store_iphone' (1290x2796), 'app_store_ipad' (2048x2732), 'play_store_feature' (1024x500)"`
+	OutputFormat          string `json:"output_format,omitempty" jsonschema:"description:Optional format the image is converted to before it is stored: 'png', 'jpeg' or 'webp'. Defaults to the format returned by the model (usually PNG). JPEG has no transparency and cannot be combined with transparent_background. WebP requires ffmpeg on the server."`
+	CompressionQuality    int    `json:"compression_quality,omitempty" jsonschema:"description:Optional quality for jpeg and webp output, from 1 (smallest) to 100 (best; lossless for webp).,default:85"`
+	WebhookURL            string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL               string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget         string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename              string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage      string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+	BypassCache           bool   `json:"bypass_cache,omitempty" jsonschema:"description:Generate anew even if the server's response cache holds the result of an identical earlier call. The new result replaces the cached one. Has no effect unless RESPONSE_CACHE_ENABLED is set.,default:false"`
+	Enhance               bool   `json:"enhance,omitempty" jsonschema:"description:Ask the client's own model (MCP sampling) to expand a short prompt into a detailed image prompt before generating. The prompt used is returned in enhanced_prompt. Ignored with a warning if the client does not support sampling.,default:false"`
 }
 
 type GeminiImageGenerationOutput struct {
+	GenerationResult
+
 	Description   string            `json:"description"`
 	Model         string            `json:"model"`
 	Style         string            `json:"style,omitempty"`
@@ -153,123 +254,185 @@ type GeminiImageGenerationOutput struct {
 	Language      string            `json:"language,omitempty"`
 	Tags          []string          `json:"tags,omitempty"`
 	SavedFiles    []string          `json:"saved_files,omitempty"`
+	DataURIs      map[string]string `json:"data_uris,omitempty"`
+	Thumbnails    map[string]string `json:"thumbnails,omitempty"`
 	DownloadURLs  []string          `json:"download_urls,omitempty"`
 	ExpiresAt     string            `json:"expires_at,omitempty"`
-	Metadata      map[string]string `json:"metadata,omitempty"`
+	Safety        *safety.Feedback  `json:"safety,omitempty"`
 	GeneratedAt   string            `json:"generated_at"`
-	ImagesCreated int               `json:"images_created"`
+	ImagesCreated int               `json:"images_created,omitempty"`
 }
 
 type GeminiImageEditInput struct {
-	InputImagePath  string `json:"input_image_path" jsonschema:"description:Path to the input image file to edit. Can be a local file path or an S3 object key returned by upload_media (e.g., '2024/12/23/upload_abc123.png'). Supports PNG, JPEG, WebP formats."`
-	EditPrompt      string `json:"edit_prompt" jsonschema:"description:Detailed description of how to edit the image. Be specific about what changes to make."`
-	Model           string `json:"model,omitempty" jsonschema:"description:Gemini model to use for image editing,default:gemini-3-pro-image-preview"`
-	AspectRatio     string `json:"aspect_ratio,omitempty" jsonschema:"description:Preferred aspect ratio for the edited image. Common ratios: '1:1' (square), '16:9' (landscape), '9:16' (portrait), '4:3', '3:4'"`
-	PreserveStyle   bool   `json:"preserve_style,omitempty" jsonschema:"description:Whether to preserve the original image style during editing,default:true"`
-	EditType        string `json:"edit_type,omitempty" jsonschema:"description:Type of edit: 'modify' (change elements), 'add' (add new elements), 'remove' (remove elements), 'style' (change style),default:modify"`
-	MaskArea        string `json:"mask_area,omitempty" jsonschema:"description:Specific area to focus edits on (e.g., 'background', 'foreground', 'top-left', 'center')"`
-	OutputDirectory string `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the edited image will be saved."`
+	InputImagePath   string `json:"input_image_path" jsonschema:"description:Path to the input image file to edit. Can be a local file path or an S3 object key returned by upload_media (e.g., '2024/12/23/upload_abc123.png'). Supports PNG, JPEG, WebP, HEIC formats (detected from file contents), including animated GIF/WebP."`
+	EditPrompt       string `json:"edit_prompt" jsonschema:"description:Detailed description of how to edit the image. Be specific about what changes to make."`
+	Model            string `json:"model,omitempty" jsonschema:"description:Gemini model to use for image editing,default:gemini-3-pro-image-preview"`
+	AspectRatio      string `json:"aspect_ratio,omitempty" jsonschema:"description:Preferred aspect ratio for the edited image. Common ratios: '1:1' (square), '16:9' (landscape), '9:16' (portrait), '4:3', '3:4'"`
+	PreserveStyle    bool   `json:"preserve_style,omitempty" jsonschema:"description:Whether to preserve the original image style during editing,default:true"`
+	EditType         string `json:"edit_type,omitempty" jsonschema:"description:Type of edit: 'modify' (change elements), 'add' (add new elements), 'remove' (remove elements), 'style' (change style),default:modify"`
+	MaskArea         string `json:"mask_area,omitempty" jsonschema:"description:Specific area to focus edits on (e.g., 'background', 'foreground', 'top-left', 'center')"`
+	Animation        string `json:"animation,omitempty" jsonschema:"description:How to edit animated GIF/WebP inputs: 'first_frame' (edit the first frame as a still image) or 'all_frames' (edit every frame and reassemble them into an animated GIF),default:first_frame"`
+	MaxFrames        int    `json:"max_frames,omitempty" jsonschema:"description:With animation 'all_frames', the maximum number of frames to edit (1-48). Longer animations are sampled evenly,default:12"`
+	OutputDirectory  string `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the edited image will be saved."`
+	WebhookURL       string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL          string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename         string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
 }
 
 type GeminiImageEditOutput struct {
+	GenerationResult
+
 	OriginalImage string            `json:"original_image"`
 	EditedImage   string            `json:"edited_image,omitempty"`
 	EditType      string            `json:"edit_type"`
 	AspectRatio   string            `json:"aspect_ratio,omitempty"`
 	Model         string            `json:"model"`
 	SavedFiles    []string          `json:"saved_files,omitempty"`
+	DataURIs      map[string]string `json:"data_uris,omitempty"`
+	Thumbnails    map[string]string `json:"thumbnails,omitempty"`
 	DownloadURLs  []string          `json:"download_urls,omitempty"`
 	ExpiresAt     string            `json:"expires_at,omitempty"`
-	Metadata      map[string]string `json:"metadata,omitempty"`
+	Safety        *safety.Feedback  `json:"safety,omitempty"`
 	GeneratedAt   string            `json:"generated_at"`
 }
 
 type GeminiMultiImageInput struct {
-	InputImagePaths []string `json:"input_image_paths" jsonschema:"description:Paths to input image files to combine (2-3 images recommended). Can be local file paths or S3 object keys returned by upload_media."`
-	CombinePrompt   string   `json:"combine_prompt" jsonschema:"description:Description of how to combine or blend the images"`
-	Model           string   `json:"model,omitempty" jsonschema:"description:Gemini model to use for multi-image processing,default:gemini-3-pro-image-preview"`
-	AspectRatio     string   `json:"aspect_ratio,omitempty" jsonschema:"description:Preferred aspect ratio for the combined image. Common ratios: '1:1' (square), '16:9' (landscape), '9:16' (portrait), '4:3', '3:4'"`
-	BlendMode       string   `json:"blend_mode,omitempty" jsonschema:"description:How to blend images: 'merge', 'collage', 'overlay', 'sequence',default:merge"`
-	OutputStyle     string   `json:"output_style,omitempty" jsonschema:"description:Style for the combined image: 'photorealistic', 'artistic', 'seamless'"`
-	OutputDirectory string   `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the combined image will be saved."`
+	InputImagePaths  []string `json:"input_image_paths" jsonschema:"description:Paths to input image files to combine. Gemini 3 Pro Image accepts up to 14 images; other models up to 3. Can be local file paths or S3 object keys returned by upload_media."`
+	ImageRoles       []string `json:"image_roles,omitempty" jsonschema:"description:Optional role of each input image, in the same order as input_image_paths (e.g. 'subject', 'style reference', 'background'). Roles are described in the prompt and images are sent subject first and style references last. Use an empty string for images without a specific role."`
+	CombinePrompt    string   `json:"combine_prompt" jsonschema:"description:Description of how to combine or blend the images"`
+	Model            string   `json:"model,omitempty" jsonschema:"description:Gemini model to use for multi-image processing,default:gemini-3-pro-image-preview"`
+	AspectRatio      string   `json:"aspect_ratio,omitempty" jsonschema:"description:Preferred aspect ratio for the combined image. Common ratios: '1:1' (square), '16:9' (landscape), '9:16' (portrait), '4:3', '3:4'"`
+	BlendMode        string   `json:"blend_mode,omitempty" jsonschema:"description:How to blend images: 'merge', 'collage', 'overlay', 'sequence',default:merge"`
+	OutputStyle      string   `json:"output_style,omitempty" jsonschema:"description:Style for the combined image: 'photorealistic', 'artistic', 'seamless'"`
+	OutputDirectory  string   `json:"output_directory,omitempty" jsonschema:"description:Optional. Local directory path where the combined image will be saved."`
+	WebhookURL       string   `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL          string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename         string   `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage string   `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
 }
 
 type GeminiMultiImageOutput struct {
+	GenerationResult
+
 	InputImages     []string          `json:"input_images"`
 	CombinedImage   string            `json:"combined_image,omitempty"`
 	BlendMode       string            `json:"blend_mode"`
 	AspectRatio     string            `json:"aspect_ratio,omitempty"`
 	Model           string            `json:"model"`
 	SavedFiles      []string          `json:"saved_files,omitempty"`
+	DataURIs        map[string]string `json:"data_uris,omitempty"`
+	Thumbnails      map[string]string `json:"thumbnails,omitempty"`
 	DownloadURLs    []string          `json:"download_urls,omitempty"`
 	ExpiresAt       string            `json:"expires_at,omitempty"`
-	Metadata        map[string]string `json:"metadata,omitempty"`
+	Safety          *safety.Feedback  `json:"safety,omitempty"`
 	GeneratedAt     string            `json:"generated_at"`
 	ImagesProcessed int               `json:"images_processed"`
 }
 
 // Text-to-Video Generation
 type VeoTextToVideoInput struct {
-	Prompt          string `json:"prompt" jsonschema:"description:Detailed text prompt describing the video content (max 1024 tokens). Be specific about scenes, actions, camera movements, visual style, and any audio elements you want included."`
-	NegativePrompt  string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT appear in the video. Use to avoid unwanted content or styles."`
-	AspectRatio     string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
-	Resolution      string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
-	Model           string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
-	Seed            int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
-	OutputDirectory string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	Prompt             string `json:"prompt" jsonschema:"description:Detailed text prompt describing the video content (max 1024 tokens). Be specific about scenes, actions, camera movements, visual style, and any audio elements you want included."`
+	NegativePrompt     string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT appear in the video. Use to avoid unwanted content or styles."`
+	AspectRatio        string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
+	Resolution         string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
+	Model              string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
+	Seed               int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
+	BothOrientations   bool   `json:"both_orientations,omitempty" jsonschema:"description:Generate a 16:9 and a 9:16 version of the video from the same prompt and parameters in one call, e.g. for publishing on landscape and portrait platforms. aspect_ratio is ignored; both videos are returned and listed in orientations. Counts as two videos for the cost guardrail. Cannot be combined with async or 1080p.,default:false"`
+	ReferenceVideoPath string `json:"reference_video_path,omitempty" jsonschema:"description:Optional existing video (local path or object key, e.g. a clip generated earlier) whose look, subjects and setting the new video should match. Frames of the clip are sent as reference images; requires a Veo 3.1 model and ffmpeg on the server."`
+	OutputDirectory    string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	ConfirmCost        bool   `json:"confirm_cost,omitempty" jsonschema:"description:Confirm that this render may be billed at a higher rate. Required when the server's cost guardrail covers the requested resolution, model or number of videos and the client cannot ask the user.,default:false"`
+	Async              bool   `json:"async,omitempty" jsonschema:"description:Return as soon as the generation has started instead of waiting for the video. Follow it with veo_job_status, which reports the Google-hosted video URI as soon as the video is ready and the stored object key once it has been copied to storage.,default:false"`
+	WebhookURL         string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL            string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget      string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename           string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage   string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+	BypassCache        bool   `json:"bypass_cache,omitempty" jsonschema:"description:Generate anew even if the server's response cache holds the result of an identical earlier call. The new result replaces the cached one. Has no effect unless RESPONSE_CACHE_ENABLED is set.,default:false"`
+	Enhance            bool   `json:"enhance,omitempty" jsonschema:"description:Ask the client's own model (MCP sampling) to expand a short prompt into a detailed video prompt before generating. The prompt used is returned in enhanced_prompt. Ignored with a warning if the client does not support sampling.,default:false"`
 }
 
 // Image-to-Video Generation
 type VeoImageToVideoInput struct {
-	ImagePath       string `json:"image_path" jsonschema:"description:Path to the initial image file to animate as the starting frame of the video. Can be a local file path or an S3 object key returned by upload_media. Supports JPEG, PNG formats."`
-	Prompt          string `json:"prompt" jsonschema:"description:Text prompt describing how the image should be animated and what should happen in the video (max 1024 tokens)."`
-	NegativePrompt  string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT happen in the animation or appear in the video."`
-	AspectRatio     string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
-	Resolution      string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
-	Model           string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
-	Seed            int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
-	OutputDirectory string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	ImagePath        string `json:"image_path" jsonschema:"description:Path to the initial image file to animate as the starting frame of the video. Can be a local file path or an S3 object key returned by upload_media. Supports JPEG, PNG formats."`
+	Prompt           string `json:"prompt" jsonschema:"description:Text prompt describing how the image should be animated and what should happen in the video (max 1024 tokens)."`
+	NegativePrompt   string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT happen in the animation or appear in the video."`
+	AspectRatio      string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
+	Resolution       string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
+	Model            string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
+	Seed             int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
+	BothOrientations bool   `json:"both_orientations,omitempty" jsonschema:"description:Generate a 16:9 and a 9:16 version of the video from the same prompt and parameters in one call, e.g. for publishing on landscape and portrait platforms. aspect_ratio is ignored; both videos are returned and listed in orientations. Counts as two videos for the cost guardrail. Cannot be combined with async or 1080p.,default:false"`
+	OutputDirectory  string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	ConfirmCost      bool   `json:"confirm_cost,omitempty" jsonschema:"description:Confirm that this render may be billed at a higher rate. Required when the server's cost guardrail covers the requested resolution, model or number of videos and the client cannot ask the user.,default:false"`
+	Async            bool   `json:"async,omitempty" jsonschema:"description:Return as soon as the generation has started instead of waiting for the video. Follow it with veo_job_status, which reports the Google-hosted video URI as soon as the video is ready and the stored object key once it has been copied to storage.,default:false"`
+	WebhookURL       string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL          string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename         string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+	Enhance          bool   `json:"enhance,omitempty" jsonschema:"description:Ask the client's own model (MCP sampling) to expand a short prompt into a detailed video prompt before generating. The prompt used is returned in enhanced_prompt. Ignored with a warning if the client does not support sampling.,default:false"`
 }
 
 // Upload Media Input/Output types
 // UploadMediaInput - this tool now returns CLI usage instructions instead of performing uploads directly
 type UploadMediaInput struct {
-	// No input parameters required - this tool returns CLI guidance
+	UploadID string `json:"upload_id,omitempty" jsonschema:"description:Optional. ID of an interrupted upload (printed by the CLI when a transfer fails). Returns how much of the file arrived and the command that resumes it instead of a new token."`
 }
 
 // UploadMediaOutput provides CLI usage instructions for uploading files
 type UploadMediaOutput struct {
-	Instructions string `json:"instructions"`
-	CLIPath      string `json:"cli_path"`
-	Usage        string `json:"usage"`
-	Example      string `json:"example"`
+	Instructions  string `json:"instructions"`
+	CLIPath       string `json:"cli_path"`
+	Usage         string `json:"usage"`
+	Example       string `json:"example"`
+	UploadID      string `json:"upload_id,omitempty"`
+	ReceivedBytes int64  `json:"received_bytes,omitempty"`
+	TotalBytes    int64  `json:"total_bytes,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	MaxBytes      int64  `json:"max_bytes"` // Largest file the server accepts
 }
 
 // Legacy input type for backward compatibility
 type VeoGenerationInput struct {
-	Prompt          string `json:"prompt" jsonschema:"description:Detailed text prompt describing the video content (max 1024 tokens). Be specific about scenes, actions, camera movements, visual style, and any audio elements you want included."`
-	NegativePrompt  string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT appear in the video. Use to avoid unwanted content or styles."`
-	AspectRatio     string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
-	Resolution      string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
-	Model           string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
-	ImagePath       string `json:"image_path,omitempty" jsonschema:"description:Optional path to initial image file to animate as the starting frame of the video"`
-	Seed            int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
-	OutputDirectory string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	Prompt             string `json:"prompt" jsonschema:"description:Detailed text prompt describing the video content (max 1024 tokens). Be specific about scenes, actions, camera movements, visual style, and any audio elements you want included."`
+	NegativePrompt     string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT appear in the video. Use to avoid unwanted content or styles."`
+	AspectRatio        string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
+	Resolution         string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
+	Model              string `json:"model,omitempty" jsonschema:"description:Veo model version to use,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview,enum:veo-3.0-generate-preview,enum:veo-3.0-fast-generate-001"`
+	ImagePath          string `json:"image_path,omitempty" jsonschema:"description:Optional path to initial image file to animate as the starting frame of the video"`
+	ReferenceVideoPath string `json:"reference_video_path,omitempty" jsonschema:"description:Optional existing video (local path or object key, e.g. a clip generated earlier) whose look, subjects and setting the new video should match. Frames of the clip are sent as reference images; requires a Veo 3.1 model and ffmpeg on the server."`
+	Seed               int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
+	BothOrientations   bool   `json:"both_orientations,omitempty" jsonschema:"description:Generate a 16:9 and a 9:16 version of the video from the same prompt and parameters in one call, e.g. for publishing on landscape and portrait platforms. aspect_ratio is ignored; both videos are returned and listed in orientations. Counts as two videos for the cost guardrail. Cannot be combined with async or 1080p.,default:false"`
+	OutputDirectory    string `json:"output_directory,omitempty" jsonschema:"description:Local directory path where the MP4 video (4-8 seconds) will be saved. Videos have 2-day retention on server and include SynthID watermark."`
+	ConfirmCost        bool   `json:"confirm_cost,omitempty" jsonschema:"description:Confirm that this render may be billed at a higher rate. Required when the server's cost guardrail covers the requested resolution, model or number of videos and the client cannot ask the user.,default:false"`
+	Async              bool   `json:"async,omitempty" jsonschema:"description:Return as soon as the generation has started instead of waiting for the video. Follow it with veo_job_status, which reports the Google-hosted video URI as soon as the video is ready and the stored object key once it has been copied to storage.,default:false"`
+	WebhookURL         string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL            string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget      string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename           string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage   string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+	Enhance            bool   `json:"enhance,omitempty" jsonschema:"description:Ask the client's own model (MCP sampling) to expand a short prompt into a detailed video prompt before generating. The prompt used is returned in enhanced_prompt. Ignored with a warning if the client does not support sampling.,default:false"`
 }
 
 type VeoGenerationOutput struct {
-	OperationID     string            `json:"operation_id,omitempty"`
-	Status          string            `json:"status"`
-	VideoURL        string            `json:"video_url,omitempty"`
-	SavedFiles      []string          `json:"saved_files,omitempty"`
-	DownloadURLs    []string          `json:"download_urls,omitempty"`
-	ExpiresAt       string            `json:"expires_at,omitempty"`
-	Model           string            `json:"model"`
-	AspectRatio     string            `json:"aspect_ratio"`
-	Resolution      string            `json:"resolution"`
-	Metadata        map[string]string `json:"metadata,omitempty"`
-	GeneratedAt     string            `json:"generated_at"`
-	EstimatedLength string            `json:"estimated_length"`
+	GenerationResult
+
+	OperationID     string             `json:"operation_id,omitempty"`
+	VideoURL        string             `json:"video_url,omitempty"`
+	SavedFiles      []string           `json:"saved_files,omitempty"`
+	DataURIs        map[string]string  `json:"data_uris,omitempty"`
+	Thumbnails      map[string]string  `json:"thumbnails,omitempty"`
+	DownloadURLs    []string           `json:"download_urls,omitempty"`
+	ExpiresAt       string             `json:"expires_at,omitempty"`
+	Model           string             `json:"model"`
+	AspectRatio     string             `json:"aspect_ratio"`
+	Resolution      string             `json:"resolution"`
+	Safety          *safety.Feedback   `json:"safety,omitempty"`
+	GeneratedAt     string             `json:"generated_at"`
+	EstimatedLength string             `json:"estimated_length"`
+	Orientations    []VideoOrientation `json:"orientations,omitempty"` // Both videos of a both_orientations call
 }
 
 func main() {
@@ -283,10 +446,22 @@ func main() {
 		return
 	}
 
+	// The config file's profile fills in settings the environment leaves unset
+	if err := applyConfigProfile(); err != nil {
+		log.Fatalf("Failed to apply config profile: %v", err)
+	}
+
 	// Load configuration
 	config := common.LoadConfig()
-	if err := config.Validate(); err != nil {
-		log.Fatalf("Configuration error: %v", err)
+
+	// Schema export needs no credentials or backends
+	if *dumpSchemasFormat != "" {
+		mcpServer := mcp.NewServer(&mcp.Implementation{Name: serviceName, Version: version}, nil)
+		(&Server{config: config}).registerTools(mcpServer)
+		if err := dumpSchemas(context.Background(), mcpServer, *dumpSchemasFormat); err != nil {
+			log.Fatalf("Failed to dump schemas: %v", err)
+		}
+		return
 	}
 
 	// Override transport if specified via flag
@@ -294,13 +469,70 @@ func main() {
 		config.Transport = *transport
 	}
 
+	// The doctor reports configuration errors instead of stopping at the first
+	if *doctorMode || flag.Arg(0) == "doctor" {
+		if !runDoctor(context.Background(), config, os.Stdout) {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if err := config.Validate(); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
 	// Create Gemini client
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Background routines run until the final shutdown (see below)
+	routines := lifecycle.New()
+
+	// Shared connection pool for the Gemini API and S3
+	httpTransport := httpclient.NewTransport(httpclient.PoolConfig{
+		MaxIdleConns:        config.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: config.HTTPMaxIdleConnsPerHost,
+		IdleConnTimeout:     config.HTTPIdleConnTimeout,
+	})
+	httpClient := &http.Client{Transport: httpTransport}
+
+	// Cap the bandwidth of large transfers, shared by all calls of a kind
+	downloadLimiter := bandwidth.New(config.DownloadRateLimit)
+	s3HTTPTransport, err := s3Pool(config, httpTransport)
+	if err != nil {
+		log.Fatalf("Failed to create S3 connection pool: %v", err)
+	}
+	s3Transport := bandwidth.Transport(s3HTTPTransport, downloadLimiter, bandwidth.New(config.S3UploadRateLimit))
+	geminiHTTPClient := &http.Client{Transport: bandwidth.Transport(httpTransport, downloadLimiter, nil)}
+	if config.DownloadRateLimit > 0 || config.S3UploadRateLimit > 0 || config.FileServingRateLimit > 0 {
+		log.Printf("Bandwidth limits (bytes/s, 0 = unlimited): downloads %d, S3 uploads %d, file serving %d",
+			config.DownloadRateLimit, config.S3UploadRateLimit, config.FileServingRateLimit)
+	}
+
+	// Optional fault injection for client resilience testing
+	var injector *chaos.Injector
+	if config.ChaosEnabled {
+		injector = chaos.New(chaos.Config{
+			ErrorRate:    config.ChaosErrorRate,
+			PollDropRate: config.ChaosPollDropRate,
+			StorageDelay: config.ChaosStorageDelay,
+		})
+		geminiHTTPClient = &http.Client{Transport: injector.Transport(geminiHTTPClient.Transport)}
+		log.Printf("WARNING: Chaos mode enabled - injecting faults (error rate %.2f, poll drop rate %.2f, storage delay up to %v)",
+			config.ChaosErrorRate, config.ChaosPollDropRate, config.ChaosStorageDelay)
+	}
+
+	// Rotate requests across several API keys when more than one is configured
+	if len(config.APIKeys) > 1 {
+		keys := keypool.New(config.APIKeys, config.APIKeyCooldown)
+		geminiHTTPClient = &http.Client{Transport: keys.Transport(geminiHTTPClient.Transport)}
+		log.Printf("Rotating requests across %d API keys (quota cooldown: %v)", keys.Len(), config.APIKeyCooldown)
+	}
+
 	clientConfig := &genai.ClientConfig{
-		APIKey:  config.APIKey,
-		Backend: genai.BackendGeminiAPI,
+		APIKey:     config.APIKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: geminiHTTPClient,
 	}
 
 	client, err := genai.NewClient(ctx, clientConfig)
@@ -308,18 +540,223 @@ func main() {
 		log.Fatalf("Failed to create Gemini client: %v", err)
 	}
 
+	// Imagen editing is only served by Vertex AI, which authenticates with
+	// Application Default Credentials rather than API keys
+	var vertexClient *genai.Client
+	if config.ProjectID != "" {
+		vertexClient, err = genai.NewClient(ctx, &genai.ClientConfig{
+			Backend:  genai.BackendVertexAI,
+			Project:  config.ProjectID,
+			Location: config.Location,
+		})
+		if err != nil {
+			log.Printf("Warning: imagen_edit is unavailable: failed to create Vertex AI client: %v", err)
+			vertexClient = nil
+		}
+	}
+
+	// Keep temp files in one directory and remove those a crash left behind
+	tempFiles, err := tempfiles.New(config.TempDir, config.TempFileMaxAge)
+	if err != nil {
+		log.Fatalf("Failed to set up TEMP_DIR: %v", err)
+	}
+	tempFiles.Sweep()
+	routines.Go("temp file cleanup", func(ctx context.Context) {
+		tempFiles.Run(ctx, config.TempGCInterval)
+	})
+
 	// Initialize storage backend
-	stor, err := storage.NewStorage(config)
+	stor, err := storage.NewStorage(config, s3Transport, tempFiles)
 	if err != nil {
 		log.Fatalf("Failed to initialize storage: %v", err)
 	}
 	defer stor.Close()
 
+	// Pre-warm pooled connections and keep them alive across idle periods
+	routines.Go("Gemini API connection warming", func(ctx context.Context) {
+		httpclient.KeepWarm(ctx, "Gemini API", config.ConnectionWarmInterval, httpclient.HeadPinger(httpClient, geminiAPIBaseURL))
+	})
+	if pinger, ok := stor.(interface{ Ping(context.Context) error }); ok {
+		routines.Go("S3 connection warming", func(ctx context.Context) {
+			httpclient.KeepWarm(ctx, "S3", config.ConnectionWarmInterval, pinger.Ping)
+		})
+	}
+
+	// Create the further storage targets tools can pick with storage_target
+	var targetBackends map[string]storage.Storage
+	if len(config.StorageTargets) > 0 {
+		targets, err := storage.ParseTargets(config.StorageTargets)
+		if err != nil {
+			log.Fatalf("Invalid STORAGE_TARGETS: %v", err)
+		}
+		if _, ok := targets[storage.TargetLocal]; ok && !config.S3Enabled {
+			log.Fatalf("Invalid STORAGE_TARGETS: local is already the default storage")
+		}
+		if targetBackends, err = storage.NewTargetBackends(config, targets, s3Transport, tempFiles); err != nil {
+			log.Fatalf("Failed to initialize storage targets: %v", err)
+		}
+		for _, backend := range targetBackends {
+			defer backend.Close()
+		}
+	}
+
+	// Without S3, or with a local storage target, serve local files over HTTP
+	// so remote clients can download them
+	var fileSigner *storage.URLSigner
+	if local, hasLocalTarget := targetBackends[storage.TargetLocal]; config.HTTPEnabled() && (!config.S3Enabled || hasLocalTarget) {
+		fileSigner = storage.NewURLSigner(config.FilesURLSecret, config.FilesURLTTL)
+		fileSigner.SetClockSkew(config.PresignClockSkew)
+		if hasLocalTarget {
+			targetBackends[storage.TargetLocal] = &fileServingStorage{Storage: local, signer: fileSigner, baseURL: config.PublicBaseURL}
+		} else {
+			stor = &fileServingStorage{Storage: stor, signer: fileSigner, baseURL: config.PublicBaseURL}
+		}
+		log.Printf("Serving local files at %s (signed URLs valid for %v)", storage.FilesPathPrefix, config.FilesURLTTL)
+	}
+
+	var storageTargets *storage.Targets
+	if len(targetBackends) > 0 {
+		defaultTarget := storage.TargetLocal
+		if config.S3Enabled {
+			defaultTarget = storage.TargetS3
+		}
+		targetBackends[defaultTarget] = stor
+		storageTargets = storage.NewTargets(defaultTarget, targetBackends)
+		stor = storageTargets
+		log.Printf("Storage targets: %s (default: %s)", strings.Join(storageTargets.Names(), ", "), defaultTarget)
+	}
+
+	mediaToolkit := media.New(config.FFmpegPath, tempFiles)
+	if mediaToolkit.FFmpegPath() == "" {
+		log.Printf("Warning: ffmpeg not found (%s); using pure-Go fallbacks; frame extraction, video_concat and WebP output are unavailable", config.FFmpegPath)
+	}
+
+	if config.StoreThumbnails {
+		stor = &thumbnailStorage{Storage: stor, maxDim: config.ThumbnailSize, media: mediaToolkit}
+		log.Printf("Storing %dpx thumbnails for images and videos", config.ThumbnailSize)
+	}
+
+	if injector != nil {
+		stor = injector.Storage(stor)
+	}
+	// The trash sees every tenant's objects; tools check the caller's keys
+	trash := storage.NewTrash(classifiedStorage{Storage: stor}, config.TrashRetention)
+	stor = linkRecordingStorage{Storage: classifiedStorage{Storage: storage.NewTenantStorage(stor)}}
+
+	pathPolicy, err := storage.NewPathPolicy(config.FollowSymlinks, config.AllowedMounts)
+	if err != nil {
+		log.Fatalf("Invalid local path policy: %v", err)
+	}
+	if len(config.AllowedMounts) > 0 {
+		log.Printf("Local paths restricted to: %s", strings.Join(pathPolicy.AllowedRoots, ", "))
+	}
+
+	if _, ok := i18n.Lookup(config.ResponseLanguage); !ok {
+		log.Fatalf("Invalid RESPONSE_LANGUAGE %q (supported: %s)", config.ResponseLanguage, strings.Join(i18n.Supported(), ", "))
+	}
+
+	allowlist, err := models.Parse(config.ModelAllowlist)
+	if err != nil {
+		log.Fatalf("Invalid MODEL_ALLOWLIST: %v", err)
+	}
+	if len(allowlist) > 0 {
+		log.Printf("Models restricted by MODEL_ALLOWLIST: %s", strings.Join(config.ModelAllowlist, ", "))
+	}
+
+	promptKeys, err := fieldcrypt.ParseKeyring(config.PromptEncryptionKeys)
+	if err != nil {
+		log.Fatalf("Invalid PROMPT_ENCRYPTION_KEYS: %v", err)
+	}
+	if promptKeys != nil {
+		log.Printf("Persisted prompts (chat sessions, response cache) encrypted with key %s", promptKeys.CurrentKeyID())
+	}
+
+	chats, err := chat.NewStore(config.ChatSessionTTL, config.ChatSessionDir, maxChatSessions, promptKeys)
+	if err != nil {
+		log.Fatalf("Failed to initialize chat sessions: %v", err)
+	}
+
+	videoPoller := poller.New(func(ctx context.Context, operation *genai.GenerateVideosOperation) (*genai.GenerateVideosOperation, error) {
+		return client.Operations.GetVideosOperation(ctx, operation, nil)
+	}, videoPollInterval, videoPollAttempts, videoPollConcurrency)
+
+	quotaLimits, err := quota.ParseLimits(config.Quotas)
+	if err != nil {
+		log.Fatalf("Invalid QUOTAS: %v", err)
+	}
+	var quotas *quota.Tracker
+	if config.HTTPEnabled() {
+		if quotas, err = quota.Open(config.QuotaUsageFile); err != nil {
+			log.Fatalf("Failed to load quota usage: %v", err)
+		}
+		if !quotaLimits.Empty() {
+			log.Printf("Generation quotas per caller: %s", config.Quotas)
+		}
+	}
+
 	server := &Server{
 		config:       config,
 		client:       client,
+		vertex:       vertexClient,
 		storage:      stor,
 		tokenManager: NewTokenManager(12 * time.Hour), // 12-hour TTL for temp tokens
+		uploads:      newUploadManager(tempFiles, config.UploadSessionTTL, int64(config.MaxUploadBytes)),
+		imageLimiter: limiter.New("image generation", config.MaxConcurrentImageGenerations, config.GenerationQueueSize, config.GenerationQueueTimeout),
+		videoLimiter: limiter.New("video generation", config.MaxConcurrentVideoGenerations, config.GenerationQueueSize, config.GenerationQueueTimeout),
+		liveSessions: newLiveSessionManager(),
+		videoJobs:    newVideoJobStore(),
+		scheduler:    newJobScheduler(),
+		music:        &music.Client{APIKey: config.APIKey},
+		videoPoller:  videoPoller,
+		pendingOps:   newPendingOperations(),
+		drainer:      drain.New(),
+		routines:     routines,
+		media:        mediaToolkit,
+		chats:        chats,
+		webhooks:     webhook.NewNotifier(config.WebhookURL, config.WebhookSecret, config.WebhookAllowedHosts),
+		fileSigner:   fileSigner,
+		fileLimiter:  bandwidth.New(config.FileServingRateLimit),
+		targets:      storageTargets,
+		trash:        trash,
+		pathPolicy:   pathPolicy,
+		allowlist:    allowlist,
+		safetyStats:  safety.NewStats(),
+		quotas:       quotas,
+		quotaLimits:  quotaLimits,
+		tempFiles:    tempFiles,
+	}
+	routines.Go("upload token cleanup", server.tokenManager.Run)
+	routines.Go("chat session cleanup", chats.Run)
+	routines.Go("live session cleanup", server.liveSessions.cleanupIdle)
+	routines.OnShutdown("webhook deliveries", server.webhooks.Wait)
+	routines.OnShutdown("live sessions", func(context.Context) error {
+		server.liveSessions.CloseAll()
+		return nil
+	})
+	// Runs before the storage is closed; background routines stop in the
+	// reverse order they were started, then pending bookkeeping is flushed
+	defer func() {
+		shutdownCtx, stop := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer stop()
+		if err := routines.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Warning: shutdown incomplete: %v", err)
+		}
+	}()
+	reportPendingOperations(config.PendingOperationsFile)
+
+	if *benchmark {
+		input := BenchmarkInput{Iterations: *benchmarkIterations, Concurrency: *benchmarkConcurrency}
+		if *benchmarkModels != "" {
+			for _, model := range strings.Split(*benchmarkModels, ",") {
+				if model = strings.TrimSpace(model); model != "" {
+					input.Models = append(input.Models, model)
+				}
+			}
+		}
+		if err := runBenchmarkCLI(ctx, server, input); err != nil {
+			log.Fatalf("Benchmark failed: %v", err)
+		}
+		return
 	}
 
 	// Create MCP server
@@ -328,39 +765,103 @@ func main() {
 		Version: version,
 	}, nil)
 
-	// Register tools
+	// Register tools and prompt templates
 	server.registerTools(mcpServer)
+	server.registerPrompts(mcpServer)
+	mcpServer.AddReceivingMiddleware(bindAPIKeyMiddleware, bindTenantMiddleware, server.safetyStatsMiddleware, server.telemetryMiddleware, server.quotaMiddleware, toolErrorMiddleware, server.drainMiddleware)
+	if err := server.installToolDefaults(ctx, mcpServer); err != nil {
+		log.Fatalf("Failed to load tool default overrides: %v", err)
+	}
 
 	log.Printf("Starting %s v%s (Transport: %s)", serviceName, version, config.Transport)
 	if config.S3Enabled {
 		log.Printf("S3 storage enabled (bucket: %s, TTL: %v)", config.S3Bucket, config.S3ObjectTTL)
 	}
+	if config.MaxConcurrentImageGenerations > 0 || config.MaxConcurrentVideoGenerations > 0 {
+		log.Printf("Concurrency limits: %d image, %d video (0 = unlimited, queue timeout: %v)",
+			config.MaxConcurrentImageGenerations, config.MaxConcurrentVideoGenerations, config.GenerationQueueTimeout)
+	}
+	if config.ResponseCacheEnabled {
+		server.responseCache = respcache.New(stor, config.ResponseCacheSize, config.ResponseCacheTTL, promptKeys)
+		log.Printf("Response cache enabled (%d results in memory, TTL: %v)", config.ResponseCacheSize, config.ResponseCacheTTL)
+	}
+
+	if config.TelemetryEnabled {
+		storageName := "local"
+		if config.S3Enabled {
+			storageName = "s3"
+		}
+		server.telemetry = telemetry.New(config.TelemetryEndpoint, config.TelemetryInterval, telemetry.Environment{
+			Version:   version,
+			Transport: config.Transport,
+			Storage:   storageName,
+		})
+		// Started before the routines it counts, so it stops after them and
+		// sends the counts of the last period
+		routines.Go("usage statistics", server.telemetry.Run)
+		log.Printf("Anonymous usage statistics enabled: tool call and error counts, version and platform are sent to %s every %v", config.TelemetryEndpoint, config.TelemetryInterval)
+	}
+
+	routines.Go("job scheduler", server.runScheduler)
+	if trash.Enabled() {
+		routines.Go("trash purge", func(ctx context.Context) {
+			trash.Run(ctx, min(config.TrashRetention, time.Hour))
+		})
+		log.Printf("Deleted media is kept in %s for %v", storage.TrashPrefix, config.TrashRetention)
+	}
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// Let tool calls in flight finish before cancelling them; a second
+	// signal exits at once
 	go func() {
 		<-sigChan
-		log.Println("Received shutdown signal, cleaning up...")
+		log.Printf("Received shutdown signal, draining tool calls in flight for up to %v...", config.ShutdownDrainTimeout)
+		drainCtx, stop := context.WithTimeout(context.Background(), config.ShutdownDrainTimeout)
+		go func() {
+			select {
+			case <-sigChan:
+				log.Println("Received second shutdown signal, exiting without draining")
+				stop()
+			case <-drainCtx.Done():
+			}
+		}()
+		server.drain(drainCtx)
+		stop()
+		log.Println("Cleaning up...")
 		cancel()
 	}()
 
-	// Select transport based on configuration
-	switch config.Transport {
-	case "http", "sse":
-		// HTTP/SSE Transport using StreamableHTTPHandler
-		if err := runHTTPServer(ctx, mcpServer, config, server); err != nil {
-			log.Fatalf("HTTP server error: %v", err)
-		}
-	case "stdio":
-		fallthrough
-	default:
-		// stdio Transport (default)
-		if err := mcpServer.Run(ctx, &mcp.StdioTransport{}); err != nil {
-			log.Fatalf("Server error: %v", err)
-		}
+	// Run the configured transports against the same server. Each ends on
+	// its own, e.g. when the stdio client disconnects, and the process exits
+	// once all have ended.
+	transports := config.Transports()
+	var wg sync.WaitGroup
+	for _, transport := range transports {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch transport {
+			case "http":
+				// HTTP/SSE Transport using StreamableHTTPHandler
+				if err := runHTTPServer(ctx, mcpServer, config, server); err != nil {
+					log.Fatalf("HTTP server error: %v", err)
+				}
+			default:
+				// stdio Transport (default)
+				// Run ends with ctx's error after a shutdown signal
+				if err := mcpServer.Run(ctx, &mcp.StdioTransport{}); err != nil && ctx.Err() == nil {
+					log.Fatalf("Server error: %v", err)
+				}
+				if len(transports) > 1 && ctx.Err() == nil {
+					log.Println("stdio client disconnected; the other transports keep running")
+				}
+			}
+		}()
 	}
+	wg.Wait()
 }
 
 // runHTTPServer starts the MCP server with HTTP transport
@@ -370,14 +871,64 @@ func runHTTPServer(ctx context.Context, mcpServer *mcp.Server, config *common.Co
 		return mcpServer
 	}, nil)
 
+	// Service tokens confined to a tenant's storage
+	tokenTenants, err := middleware.ParseTokenTenants(config.ServiceTokenTenants)
+	if err != nil {
+		return fmt.Errorf("invalid SERVICE_TOKEN_TENANTS: %w", err)
+	}
+	for _, tenant := range tokenTenants {
+		if err := storage.ValidateTenant(tenant); err != nil {
+			return fmt.Errorf("invalid SERVICE_TOKEN_TENANTS: %w", err)
+		}
+	}
+	if len(tokenTenants) > 0 {
+		log.Printf("Storage of %d service token(s) scoped to their tenants under %s", len(tokenTenants), storage.TenantRoot)
+	}
+
+	// Service tokens created through the admin API
+	var tokenStore middleware.TokenStore
+	if config.TokenStoreFile != "" {
+		appServer.serviceTokens, err = tokens.Open(config.TokenStoreFile)
+		if err != nil {
+			return err
+		}
+		tokenStore = appServer.serviceTokens
+		log.Printf("Managed service tokens loaded from %s (%d tokens)", config.TokenStoreFile, len(appServer.serviceTokens.List()))
+	}
+
 	// Wrap MCP handler with headers middleware
 	var wrappedMCPHandler http.Handler = mcpHandler
+	wrappedMCPHandler = middleware.PriorityMiddleware(config.BatchTokens, config.JWTBatchScope, wrappedMCPHandler)
+	wrappedMCPHandler = middleware.TenantMiddleware(tokenTenants, wrappedMCPHandler)
+	wrappedMCPHandler = appServer.quotaHeaders(wrappedMCPHandler)
 	wrappedMCPHandler = middleware.HeadersMiddleware(wrappedMCPHandler)
+	wrappedMCPHandler = markHTTPCalls(wrappedMCPHandler)
 
 	// Wrap MCP handler with auth middleware if enabled
-	if config.AuthEnabled && len(config.ServiceTokens) > 0 {
+	authenticate := func(next http.Handler) http.Handler { return next }
+	if config.AuthEnabled {
+		var jwtValidator *middleware.JWTValidator
+		if config.JWTEnabled {
+			var err error
+			jwtValidator, err = middleware.NewJWTValidator(middleware.JWTConfig{
+				JWKSURL:       config.JWTJWKSURL,
+				Issuer:        config.JWTIssuer,
+				Audience:      config.JWTAudience,
+				ScopeClaim:    config.JWTScopeClaim,
+				TenantClaim:   config.JWTTenantClaim,
+				RequiredScope: config.JWTRequiredScope,
+				CacheTTL:      config.JWTCacheTTL,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to configure JWT authentication: %w", err)
+			}
+			log.Printf("JWT authentication enabled (issuer: %q, audience: %q)", config.JWTIssuer, config.JWTAudience)
+		}
 		log.Printf("Authentication enabled with %d configured tokens", len(config.ServiceTokens))
-		wrappedMCPHandler = middleware.AuthMiddleware(config.ServiceTokens, wrappedMCPHandler)
+		authenticate = func(next http.Handler) http.Handler {
+			return middleware.AuthMiddleware(config.ServiceTokens, tokenStore, jwtValidator, next)
+		}
+		wrappedMCPHandler = authenticate(wrappedMCPHandler)
 	} else {
 		log.Printf("WARNING: Authentication disabled for MCP - server is publicly accessible")
 	}
@@ -390,7 +941,47 @@ func runHTTPServer(ctx context.Context, mcpServer *mcp.Server, config *common.Co
 	mux.Handle("/mcp/", wrappedMCPHandler)
 
 	// Register upload endpoint (uses one-time token auth, not service tokens)
-	mux.HandleFunc("/upload", appServer.handleHTTPUpload)
+	mux.Handle("/upload", middleware.HeadersMiddleware(http.HandlerFunc(appServer.handleHTTPUpload)))
+
+	// Register resumable upload endpoints (the upload ID authenticates chunks)
+	mux.Handle("GET /upload/{id}", http.HandlerFunc(appServer.handleUploadStatus))
+	mux.Handle("PATCH /upload/{id}", http.HandlerFunc(appServer.handleUploadChunk))
+	mux.Handle("DELETE /upload/{id}", http.HandlerFunc(appServer.handleUploadCancel))
+	appServer.routines.Go("resumable upload cleanup", func(ctx context.Context) {
+		appServer.uploads.Run(ctx, time.Hour)
+	})
+	appServer.routines.OnShutdown("resumable uploads", func(context.Context) error {
+		appServer.uploads.Close()
+		return nil
+	})
+
+	// Register file download endpoint for local storage (signed URL or service auth).
+	// With S3 it re-issues presigned URLs to authenticated callers instead.
+	reissue := authenticate(middleware.TenantMiddleware(tokenTenants, http.HandlerFunc(appServer.handleFileReissue)))
+	if appServer.fileSigner != nil {
+		mux.Handle("GET "+storage.FilesPathPrefix+"{key...}", appServer.filesHandler(authenticate(middleware.TenantMiddleware(tokenTenants, http.HandlerFunc(appServer.handleFileDownload))), reissue))
+	} else if config.S3Enabled {
+		mux.Handle("GET "+storage.FilesPathPrefix+"{key...}", reissue)
+	}
+
+	// Register safety filter statistics (service auth, callers without a tenant)
+	operator := func(h http.HandlerFunc) http.Handler {
+		return authenticate(middleware.TenantMiddleware(tokenTenants, operatorOnly(h)))
+	}
+	mux.Handle("GET /metrics", operator(appServer.handleMetrics))
+	mux.Handle("GET /safety/report", operator(appServer.handleSafetyReport))
+
+	// Register token management (admin tokens only)
+	if appServer.serviceTokens != nil && len(config.AdminTokens) > 0 {
+		admin := func(h http.HandlerFunc) http.Handler {
+			return middleware.AuthMiddleware(config.AdminTokens, nil, nil, h)
+		}
+		mux.Handle("GET /admin/tokens", admin(appServer.handleListTokens))
+		mux.Handle("POST /admin/tokens", admin(appServer.handleCreateToken))
+		mux.Handle("PATCH /admin/tokens/{id}", admin(appServer.handleUpdateToken))
+		mux.Handle("DELETE /admin/tokens/{id}", admin(appServer.handleRevokeToken))
+		log.Printf("Token admin API enabled at /admin/tokens")
+	}
 
 	var httpHandler http.Handler = mux
 
@@ -401,11 +992,70 @@ func runHTTPServer(ctx context.Context, mcpServer *mcp.Server, config *common.Co
 		Handler: httpHandler,
 	}
 
-	// Start server in goroutine
-	errChan := make(chan error, 1)
+	// Serve HTTPS directly when a certificate is configured
+	var reloader *certs.Reloader
+	if config.TLSCertFile != "" {
+		var err error
+		if reloader, err = certs.New(config.TLSCertFile, config.TLSKeyFile); err != nil {
+			return err
+		}
+		server.TLSConfig = reloader.TLSConfig()
+		if config.TLSReloadInterval > 0 {
+			appServer.routines.Go("TLS certificate watcher", func(ctx context.Context) {
+				reloader.Watch(ctx, config.TLSReloadInterval)
+			})
+			log.Printf("Checking %s for a rotated TLS certificate every %v", config.TLSCertFile, config.TLSReloadInterval)
+		}
+	}
+
+	// Serve the tools over gRPC too, through the MCP handler and its middleware
+	var grpcServer *grpc.Server
+	if config.GRPCPort != "" {
+		var opts []grpc.ServerOption
+		if reloader != nil {
+			opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.TLSConfig())))
+		}
+		grpcServer = grpc.NewServer(opts...)
+		// Calls carry the host the caller dialed. Links back to the server,
+		// such as download URLs, must reach the HTTP listener on that host
+		// rather than the gRPC port.
+		grpcHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if host, port, err := net.SplitHostPort(r.Host); err == nil && port == config.GRPCPort {
+				r.Host = net.JoinHostPort(host, config.Port)
+			}
+			wrappedMCPHandler.ServeHTTP(w, r)
+		})
+		grpcapi.Register(grpcServer, service.New(grpcHandler, &mcp.Implementation{Name: serviceName + "-grpc", Version: version}))
+		if config.GRPCReflection {
+			reflection.Register(grpcServer)
+		}
+	}
+
+	// Start servers in goroutines
+	errChan := make(chan error, 2)
+	if grpcServer != nil {
+		grpcAddr := ":" + config.GRPCPort
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for gRPC: %w", err)
+		}
+		go func() {
+			log.Printf("gRPC API (%s) listening on %s", grpcapi.ServiceName, grpcAddr)
+			if err := grpcServer.Serve(listener); err != nil {
+				errChan <- fmt.Errorf("gRPC server error: %w", err)
+			}
+		}()
+	}
 	go func() {
-		log.Printf("HTTP server listening on %s", addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if reloader != nil {
+			log.Printf("HTTPS server listening on %s", addr)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("HTTP server listening on %s", addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
@@ -416,31 +1066,124 @@ func runHTTPServer(ctx context.Context, mcpServer *mcp.Server, config *common.Co
 		log.Println("Shutting down HTTP server...")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer cancel()
+		if grpcServer != nil {
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			defer func() {
+				select {
+				case <-stopped:
+				case <-shutdownCtx.Done():
+					grpcServer.Stop()
+				}
+			}()
+		}
 		return server.Shutdown(shutdownCtx)
 	case err := <-errChan:
 		return err
 	}
 }
 
-// resolveInputPath resolves an input path to a local file path
-// If the path looks like an S3 object key (contains / but doesn't start with /),
-// it downloads from S3 to a temp file. Otherwise treats it as a local file path.
+// httpCallKey marks the contexts of MCP requests received over HTTP
+type httpCallKey struct{}
+
+// markHTTPCalls marks the requests next handles as received over HTTP, so
+// tools can tell them from stdio calls when both transports run
+func markHTTPCalls(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), httpCallKey{}, true)))
+	})
+}
+
+// calledOverHTTP reports whether ctx belongs to a request received over HTTP
+func calledOverHTTP(ctx context.Context) bool {
+	return ctx.Value(httpCallKey{}) != nil
+}
+
+// s3Pool returns the connection pool for S3 calls: the shared one, or a copy
+// that trusts S3_CA_BUNDLE
+func s3Pool(config *common.Config, shared *http.Transport) (*http.Transport, error) {
+	if config.S3CABundle == "" {
+		return shared, nil
+	}
+	pool, err := httpclient.WithCABundle(shared, config.S3CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("S3_CA_BUNDLE: %w", err)
+	}
+	return pool, nil
+}
+
+// bindTenantMiddleware scopes the storage of tool calls to the caller's
+// tenant (see storage.WithTenant)
+func bindTenantMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if tenant := middleware.GetTenant(ctx); method == "tools/call" && tenant != "" {
+			if err := storage.ValidateTenant(tenant); err != nil {
+				return nil, err
+			}
+			ctx = storage.WithTenant(ctx, tenant)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// bindAPIKeyMiddleware makes all Gemini API requests of a tool call share one
+// API key, so that long-running operations and uploaded files are polled and
+// fetched with the key that created them
+func bindAPIKeyMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method == "tools/call" {
+			ctx = keypool.Bind(ctx)
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// resolveInputPath resolves an input path to a local file path, with the EXIF
+// orientation of JPEG photos applied (see uprightInput) and animated GIF/WebP
+// inputs reduced to their first frame (see stillInput).
+// Absolute paths (Unix, Windows drive-letter, UNC, file:// URIs and ~/...) are
+// used as-is. Anything else is treated as a storage object key, which may be
+// downloaded from S3 to a temp file, before falling back to a relative local path.
 // Returns the local path and a cleanup function (may be nil for local files).
 func (s *Server) resolveInputPath(ctx context.Context, inputPath string) (localPath string, cleanup func(), err error) {
-	// If path starts with /, it's an absolute local path
-	if strings.HasPrefix(inputPath, "/") {
+	localPath, cleanup, err = s.locateInputPath(ctx, inputPath)
+	if err != nil {
+		return "", nil, err
+	}
+	localPath, cleanup, err = s.uprightInput(localPath, cleanup)
+	if err != nil {
+		return "", nil, err
+	}
+	return s.stillInput(ctx, localPath, cleanup)
+}
+
+// locateInputPath finds the local file for an input path without modifying it
+func (s *Server) locateInputPath(ctx context.Context, inputPath string) (localPath string, cleanup func(), err error) {
+	inputPath, isAbs := storage.NormalizeLocalPath(inputPath)
+	// Tenants could reach each other's files through the server's file system
+	tenantScoped := storage.TenantPrefix(ctx) != ""
+	if isAbs {
+		if tenantScoped {
+			return "", nil, toolerr.Errorf(toolerr.InvalidInput, "local paths are not available to tenant-scoped callers; use an object key")
+		}
+		if err := s.pathPolicy.Check(inputPath); err != nil {
+			return "", nil, err
+		}
 		// Check if file exists locally
 		if _, err := os.Stat(inputPath); err == nil {
 			return inputPath, nil, nil
 		}
-		return "", nil, fmt.Errorf("local file not found: %s", inputPath)
+		return "", nil, toolerr.Errorf(toolerr.InvalidInput, "local file not found: %s", inputPath)
 	}
 
 	// If storage is remote, try to retrieve from S3
 	if s.storage.IsRemote() {
 		localPath, cleanup, err = s.storage.Retrieve(ctx, inputPath)
 		if err != nil {
-			return "", nil, fmt.Errorf("failed to retrieve from storage: %v", err)
+			return "", nil, fmt.Errorf("failed to retrieve from storage: %w", err)
 		}
 		return localPath, cleanup, nil
 	}
@@ -448,24 +1191,34 @@ func (s *Server) resolveInputPath(ctx context.Context, inputPath string) (localP
 	// For local storage, try to retrieve
 	localPath, cleanup, err = s.storage.Retrieve(ctx, inputPath)
 	if err != nil {
+		if tenantScoped {
+			return "", nil, fmt.Errorf("failed to retrieve from storage: %w", err)
+		}
 		// If not found in storage, treat as relative path and check if exists
+		if err := s.pathPolicy.Check(inputPath); err != nil {
+			return "", nil, err
+		}
 		if _, statErr := os.Stat(inputPath); statErr == nil {
 			return inputPath, nil, nil
 		}
-		return "", nil, fmt.Errorf("file not found: %s", inputPath)
+		return "", nil, toolerr.Errorf(toolerr.InvalidInput, "file not found: %s", inputPath)
 	}
 	return localPath, cleanup, nil
 }
 
 func (s *Server) registerTools(server *mcp.Server) {
+	s.mcpServer = server
+	s.pipelineTools = s.newPipelineTools()
+	s.schedulableTools = s.newSchedulableTools()
+
 	// Register gemini_image_generation tool
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "gemini_image_generation",
-		Description: "Generate high-quality images using Google's latest Gemini image generation models. Supports text-to-image generation with advanced style control, quality settings, and multi-language prompts. Features include customizable aspect ratios, artistic styles, content safety levels, and high-fidelity text rendering.",
-	}, s.handleGeminiImageGeneration)
+		Description: "Generate high-quality images using Google's latest Gemini image generation models. Supports text-to-image generation with advanced style control, quality settings, and multi-language prompts. Features include customizable aspect ratios, artistic styles, content safety levels, and high-fidelity text rendering. Use the preset parameter to get exact-size favicons, Open Graph/Twitter cards, and app store screenshots in one call.",
+	}, withResponseLanguage(s, withResponseCache(s, "gemini_image_generation", s.config.ImageDefaultModel, withGenerationResult(s, withWebhook(s, "gemini_image_generation", withStorageOptions(s, withPromptEnhancement("image", s.handleGeminiImageGeneration)))))))
 
 	// Register gemini_image_edit tool
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "gemini_image_edit",
 		Description: `Edit existing images using Google's Gemini AI models. Supports targeted image modifications, style transfers, object addition/removal, and background changes.
 
@@ -477,12 +1230,18 @@ Example workflow for local files:
 1. Call upload_media tool -> get CLI command with path
 2. Run CLI via Bash -> get object_key from JSON output
 3. Call gemini_image_edit with input_image_path=object_key`,
-	}, s.handleGeminiImageEdit)
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "gemini_image_edit", withStorageOptions(s, s.handleGeminiImageEdit)))))
+
+	// Register imagen_edit tool
+	addTool(server, &mcp.Tool{
+		Name:        "imagen_edit",
+		Description: "Edit images with Imagen's capability models on Vertex AI, which keep the untouched parts of the image closer to the original than gemini_image_edit. Modes: 'edit' applies a mask-free edit described by prompt, 'background_swap' keeps the foreground subject and replaces the background with the one described by prompt (the mask is found automatically), and 'product_recontext' places a product (image_path plus up to 2 further views in product_image_paths) in a new scene while preserving its appearance. Requires GOOGLE_PROJECT_ID and Google Cloud credentials on the server.",
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "imagen_edit", withStorageOptions(s, s.handleImagenEdit)))))
 
 	// Register gemini_multi_image tool
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "gemini_multi_image",
-		Description: `Combine and blend multiple images using Google's Gemini AI models. Supports merging 2-3 images into cohesive compositions, creating collages, overlays, and seamless blends.
+		Description: `Combine and blend multiple images using Google's Gemini AI models. Supports merging 2-14 images (up to 3 with non-Gemini 3 models) into cohesive compositions, creating collages, overlays, and seamless blends. Give each image a role with image_roles (e.g. "subject", "style reference", "background") for finer control over the composition.
 
 IMPORTANT - How to provide input_image_paths:
 1. For LOCAL files: First call upload_media tool for EACH image to get CLI instructions, then run the CLI to upload and get object_keys
@@ -492,16 +1251,16 @@ Example workflow for local files:
 1. Call upload_media tool -> get CLI command with path
 2. Run CLI via Bash for each image -> get object_keys from JSON outputs
 3. Call gemini_multi_image with input_image_paths=[object_key1, object_key2]`,
-	}, s.handleGeminiMultiImage)
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "gemini_multi_image", withStorageOptions(s, s.handleGeminiMultiImage)))))
 
 	// Register veo_text_to_video tool
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "veo_text_to_video",
 		Description: "Generate 8-second videos from text prompts using Google's Veo 3.0 models. Create videos with detailed scene descriptions, camera movements, and realistic physics. Supports 16:9/9:16 aspect ratios, 720p/1080p resolution, negative prompts, and includes SynthID watermarking.",
-	}, s.handleVeoTextToVideo)
+	}, withResponseLanguage(s, withResponseCache(s, "veo_text_to_video", s.config.VeoDefaultModel, withGenerationResult(s, withWebhook(s, "veo_text_to_video", withStorageOptions(s, withPromptEnhancement("video", withBothOrientations(s, "veo_text_to_video", s.handleVeoTextToVideo))))))))
 
 	// Register veo_image_to_video tool
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "veo_image_to_video",
 		Description: `Animate static images into 8-second videos using Google's Veo 3.0 models. Transform photos into dynamic scenes with natural motion, camera movements, and realistic physics.
 
@@ -513,16 +1272,238 @@ Example workflow for local files:
 1. Call upload_media tool -> get CLI command with path
 2. Run CLI via Bash -> get object_key from JSON output
 3. Call veo_image_to_video with image_path=object_key`,
-	}, s.handleVeoImageToVideo)
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "veo_image_to_video", withStorageOptions(s, withPromptEnhancement("video", withBothOrientations(s, "veo_image_to_video", s.handleVeoImageToVideo)))))))
 
 	// Register veo_generate_video tool (legacy)
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "veo_generate_video",
 		Description: "Generate high-quality 8-second videos using Google's Veo 3.0 video generation models. Supports both text-to-video and image-to-video creation with advanced scene composition, camera movements, and realistic physics. Features include 16:9 and 9:16 aspect ratios, 720p/1080p resolution, negative prompts for content exclusion, and automatic operation polling with video URL retrieval.",
-	}, s.handleVeoGeneration)
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "veo_generate_video", withStorageOptions(s, withPromptEnhancement("video", withBothOrientations(s, "veo_generate_video", s.handleVeoGeneration)))))))
+
+	// Register split_grid tool
+	addTool(server, &mcp.Tool{
+		Name:        "split_grid",
+		Description: `Split a grid or sprite-sheet image into individual tiles. Models often return several variations or frames arranged in a grid; this tool cuts the image into rows x cols equally sized cells and stores each one as a separate PNG.
+
+Use the object_key from gemini_image_generation (found in saved_files) or from upload_media as image_path. Tiles are returned in row-major order with their own object_keys, so they can be fed directly into gemini_image_edit or veo_image_to_video.`,
+	}, withStorageOptions(s, s.handleSplitGrid))
+
+	// Register gemini_video_analysis tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_video_analysis",
+		Description: `Analyze a video with Gemini's video understanding. Produces an overall summary, a timestamped scene breakdown, or answers to specific questions about the video. Useful for verifying Veo outputs programmatically (e.g., checking that requested elements appear, spotting artifacts).
+
+Use the object_key from veo_text_to_video / veo_image_to_video (found in saved_files) or from upload_media as video_path. Short videos are sent inline; larger ones are uploaded to the Gemini Files API for analysis and deleted afterwards.
+
+Long videos (over 40 minutes, or any video when segment_seconds is set) are analyzed segment by segment and the results combined; each segment's analysis and time range is returned in segments.`,
+	}, withGenerationResult(s, s.handleGeminiVideoAnalysis))
+
+	// Register vectorize_image tool
+	addTool(server, &mcp.Tool{
+		Name:        "vectorize_image",
+		Description: "Trace a raster image into a scalable SVG. The image is quantized to a small color palette and each color region is traced into smooth vector outlines. Best suited to flat, logo-style, or icon-style artwork (e.g. output of gemini_image_generation with style 'flat vector logo'); photographs produce large, blocky SVGs. Accepts an object_key from saved_files or upload_media.",
+	}, withStorageOptions(s, s.handleVectorizeImage))
+
+	// Register generate_icon_set tool
+	addTool(server, &mcp.Tool{
+		Name:        "generate_icon_set",
+		Description: `Generate a consistent, platform-ready icon set. Either generates a new icon from a prompt or uses an existing image, then trims it, applies padding and background rules, and renders it at every requested size (16-1024px).
+
+Returns a preview PNG, a Windows .ico (sizes up to 256px), a macOS .icns, and a zip bundle containing all renditions.`,
+	}, withStorageOptions(s, s.handleGenerateIconSet))
+
+	// Register generate_depth_map tool
+	addTool(server, &mcp.Tool{
+		Name:        "generate_depth_map",
+		Description: "Estimate a depth map for an existing image and store it as a grayscale PNG matching the source dimensions (near = white, far = black). Optionally derives a normal map from the depth. Useful for parallax effects, 3D photo animations, and relighting downstream. Accepts an object_key from saved_files or upload_media.",
+	}, withStorageOptions(s, s.handleGenerateDepthMap))
+
+	// Register delete_media tool
+	addTool(server, &mcp.Tool{
+		Name:        "delete_media",
+		Description: "Delete a single generated or uploaded file by its object key (as returned in saved_files or by upload_media). Deleting a key that no longer exists is not an error. Unless the trash is disabled, the file is moved to the trash and can be brought back with restore_media until it is purged.",
+	}, s.handleDeleteMedia)
+
+	// Register purge_media tool
+	addTool(server, &mcp.Tool{
+		Name:        "purge_media",
+		Description: "Delete stored media in bulk, filtered by key prefix and/or age (older_than, e.g. '24h'). At least one filter is required. Use dry_run to preview which objects would be removed before deleting them. Unless the trash is disabled, deleted files can be brought back with restore_media until they are purged.",
+	}, s.handlePurgeMedia)
+
+	// Register restore_media tool
+	addTool(server, &mcp.Tool{
+		Name:        "restore_media",
+		Description: "Restore a file removed by delete_media or purge_media from the trash to its original object key. Call without object_key to list the files in the trash and when each will be purged for good. A file is not restored over one stored under the same key since.",
+	}, s.handleRestoreMedia)
+
+	// Register generate_panorama tool
+	addTool(server, &mcp.Tool{
+		Name:        "generate_panorama",
+		Description: "Generate a panorama as a single wide image. Segments are generated one after another, each outpainted from the edge of the previous one, then blended together. Mode 'wide' gives a landscape strip for backdrops and banners; mode 'equirectangular' gives a 2:1 image covering a full 360 degrees for VR viewers and skyboxes, with the ends joined seamlessly. Each segment is a separate generation call, so this takes longer than gemini_image_generation.",
+	}, withStorageOptions(s, s.handleGeneratePanorama))
+
+	// Register live_session_start tool
+	addTool(server, &mcp.Tool{
+		Name:        "live_session_start",
+		Description: "Open a realtime Gemini Live API voice session. Returns a session_id for live_session_send. Replies can be spoken audio (stored as WAV, with a transcript) or text. Sessions stay open across calls, so the model remembers the conversation, and close after 10 minutes of inactivity.",
+	}, s.handleLiveSessionStart)
+
+	// Register live_session_send tool
+	addTool(server, &mcp.Tool{
+		Name:        "live_session_send",
+		Description: "Send one user turn to an open live session and wait for the model's reply. Provide either audio_path (a 16-bit PCM WAV recording, e.g. uploaded via upload_media) or text. Returns the transcript of what was heard and the model's reply as text and/or a stored WAV file.",
+	}, withStorageOptions(s, s.handleLiveSessionSend))
+
+	// Register live_session_stop tool
+	addTool(server, &mcp.Tool{
+		Name:        "live_session_stop",
+		Description: "Close a live session opened with live_session_start.",
+	}, s.handleLiveSessionStop)
+
+	// Register veo_interpolate tool
+	addTool(server, &mcp.Tool{
+		Name:        "veo_interpolate",
+		Description: `Generate an 8-second video that transitions from a given first frame to a given last frame using Veo 3.1 first/last-frame interpolation. The prompt describes the motion and events in between.
+
+Both frames accept object keys from saved_files (e.g. two gemini_image_generation or gemini_image_edit results) or from upload_media. Use frames with the same aspect ratio and similar framing for the smoothest result.`,
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "veo_interpolate", withStorageOptions(s, s.handleVeoInterpolate)))))
+
+	// Register veo_job_status tool
+	addTool(server, &mcp.Tool{
+		Name:        "veo_job_status",
+		Description: "Check a Veo generation started with async=true, by the operation_id the Veo tool returned. The status moves from 'generating' to 'storing' when the video is ready, at which point video_uri holds the Google-hosted video (downloadable with the server's Gemini API key), and to 'completed' once the video has been copied to storage, with its object_key and download_url. Failed, blocked and timed-out jobs report an error. Jobs are kept for 24 hours after they finish.",
+	}, s.handleVeoJobStatus)
+
+	// Register benchmark tool
+	addTool(server, &mcp.Tool{
+		Name:        "benchmark",
+		Description: `Run a small standardized workload and report p50/p95 latency and throughput for each model and storage operation. Text models answer a one-word prompt; image models generate one 1K image per request (billed as normal generations). Storage is measured with store, retrieve and delete round trips of a random object.
+
+Use it to compare models, regions and storage endpoints. Benchmark requests bypass the generation queue. The same workload is available from the command line with -benchmark.`,
+	}, s.handleBenchmark)
+
+	// Register export_tool_schemas tool
+	addTool(server, &mcp.Tool{
+		Name:        "export_tool_schemas",
+		Description: `Export the full input and output JSON Schemas of every tool on this server, as a plain list ('json') or an OpenAPI 3.1 document ('openapi'). Useful for client-side validation and code generation outside standard MCP SDKs. The same export is available from the command line with -dump-schemas.`,
+	}, s.handleExportToolSchemas)
+
+	// Register server_capabilities tool
+	addTool(server, &mcp.Tool{
+		Name:        "server_capabilities",
+		Description: "Report what this server can do: its version, transport and storage backend, and for each media operation (video thumbnails, frame extraction, trimming, joining, WebP and GIF encoding) whether it runs with ffmpeg, a pure-Go fallback, or is unavailable. Call it before relying on video post-processing on an unknown deployment.",
+	}, s.handleServerCapabilities)
+
+	// Register gemini_object_detection tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_object_detection",
+		Description: "Detect objects in an image with Gemini and return labels, confidence scores and bounding boxes as structured JSON. Boxes are given both normalized (0-1, origin top-left) and in pixels of the source image, ready for cropping or targeted editing. Optionally restrict detection to specific kinds of objects and store an annotated copy of the image with the boxes drawn on it.",
+	}, withStorageOptions(s, s.handleGeminiObjectDetection))
+
+	// Register gemini_image_batch tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_image_batch",
+		Description: "Generate images for many prompts in one call, e.g. every panel of a storyboard. Shared settings (model, style, aspect ratio, size, quality, negative prompt, preset) apply to every prompt. Prompts run through a bounded worker pool and results are returned per prompt in input order; a failed or blocked prompt is reported in its result without failing the rest of the batch.",
+	}, withStorageOptions(s, s.handleGeminiImageBatch))
+
+	// Register detect_scenes tool
+	addTool(server, &mcp.Tool{
+		Name:        "detect_scenes",
+		Description: "Detect the shot/scene boundaries of a stored video. Returns each scene's start and end (as timestamps and seconds), the transition into it and a short description, plus a thumbnail from the middle of each scene when ffmpeg is available on the server. Use the result to edit a video scene by scene or to rebuild a storyboard. Long videos are processed in segments like gemini_video_analysis.",
+	}, withStorageOptions(s, s.handleDetectScenes))
+
+	// Register video_trim tool
+	addTool(server, &mcp.Tool{
+		Name:        "video_trim",
+		Description: "Cut a clip out of a stored video, e.g. the part of an 8-second Veo video that is needed, and store it as a new MP4. Give start and end as seconds or timestamps (scene boundaries from detect_scenes can be used as is). Uses ffmpeg on the server, re-encoding for a frame-accurate cut or copying the streams losslessly; without ffmpeg, MP4/MOV videos are trimmed with an edit list, which players honor but which keeps the file's size.",
+	}, withStorageOptions(s, s.handleVideoTrim))
+
+	// Register video_concat tool
+	addTool(server, &mcp.Tool{
+		Name:        "video_concat",
+		Description: "Join several stored videos of the same resolution into one MP4 in the order given, e.g. the scenes of a storyboard generated with repeated veo_text_to_video calls. Clips are joined with hard cuts, or overlapped by crossfade_seconds with a fade or another transition. Audio is kept when every clip has it. Requires ffmpeg on the server.",
+	}, withStorageOptions(s, s.handleVideoConcat))
+
+	// Register gemini_speech_to_text tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_speech_to_text",
+		Description: "Transcribe speech from a stored audio recording or video (e.g. a voice note or interview) with a Gemini audio-capable model. Returns the verbatim transcript in the spoken language and its detected language; set timestamps for the start and end of each segment and speaker_labels to label who is speaking. Pass speaker names or uncommon terms in prompt to improve accuracy. The file is uploaded to the Gemini Files API for transcription and deleted afterwards.",
+	}, s.handleGeminiSpeechToText)
+
+	// Register gemini_music_generation tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_music_generation",
+		Description: "Generate instrumental music with Google's Lyria RealTime model from a text prompt, e.g. a background track for a Veo video. Add genre and mood to steer the style, and bpm, density, brightness or temperature for finer control. The track (5-120 seconds, 30 by default) is stored as a 48 kHz stereo WAV file. Music is streamed in real time, so the call takes about as long as the track. Prompts naming artists are refused.",
+	}, withResponseLanguage(s, withGenerationResult(s, withWebhook(s, "gemini_music_generation", withStorageOptions(s, s.handleGeminiMusicGeneration)))))
+
+	// Register gemini_ocr tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_ocr",
+		Description: "Extract the text of an image or a PDF page with Gemini, e.g. after generating or uploading an image with text in it. Returns the text verbatim in reading order and the languages it is written in; set layout to also get it as typed blocks (headings, paragraphs, table cells, ...) with per-block languages and bounding boxes, normalized (0-1, origin top-left) and, for images, in pixels. PDFs are read one page at a time (page, from 1).",
+	}, s.handleGeminiOCR)
+
+	// Register gemini_pdf_understanding tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_pdf_understanding",
+		Description: "Read a PDF with Gemini (object key from upload_media or a local path). Mode 'summary' returns its key points, 'tables' its tables as columns and rows, 'qa' answers to the given questions with the pages they are based on, and 'brief' ready-to-use gemini_image_generation prompts for the visuals the document calls for, e.g. turning a product sheet into image briefs. Limit the analysis to some pages with pages (e.g. '1-3,7'). Use gemini_ocr for the verbatim text of a page.",
+	}, s.handleGeminiPDFUnderstanding)
+
+	// Register run_pipeline tool
+	addTool(server, &mcp.Tool{
+		Name: "run_pipeline",
+		Description: fmt.Sprintf(`Run a multi-step asset workflow server-side in one call, e.g. generate an image, edit it, then animate it with veo_image_to_video. Each step names a tool and its arguments; steps run in order and every intermediate file is stored and returned.
+
+Arguments refer to earlier results with placeholders: {{previous}} or {{<step id>}} is the first file a step stored, {{<id>[n]}} its n-th file (from 0), {{<id>.files}} all of its files and {{<id>.<field>}} a field of its output (e.g. {{analyze.analysis}}). The plan is checked before anything runs; if a step fails or is blocked, the remaining steps are skipped.
+
+Tools available in pipelines: %s.`, strings.Join(sortedToolNames(s.pipelineTools), ", ")),
+	}, withStorageOptions(s, s.handleRunPipeline))
+
+	// Register schedule_job tool
+	addTool(server, &mcp.Tool{
+		Name: "schedule_job",
+		Description: fmt.Sprintf(`Defer a tool call, such as a large gemini_image_batch or run_pipeline, to off-peak hours so it does not compete with interactive work for the shared generation quota. Give run_after as a time of day (HH:MM in timezone, e.g. '22:00' with 'Europe/Berlin') or an RFC 3339 timestamp, and/or when_idle to start only once no generation is running or queued. Scheduled jobs run as batch work with the caller's credentials and tenant; follow them with list_scheduled. Jobs are kept in memory, so jobs that have not finished are lost when the server restarts. Cost confirmations cannot be asked while a job runs: pass confirm_cost in the arguments where needed.
+
+Tools that can be scheduled: %s.`, strings.Join(sortedToolNames(s.schedulableTools), ", ")),
+	}, s.handleScheduleJob)
+
+	// Register list_scheduled tool
+	addTool(server, &mcp.Tool{
+		Name:        "list_scheduled",
+		Description: "List the jobs deferred with schedule_job by the caller's tenant: when each may start, whether it is still waiting, running or finished, and the files, errors and warnings of finished jobs. Finished jobs are listed for 24 hours.",
+	}, s.handleListScheduled)
+
+	// Register gemini_chat tool
+	addTool(server, &mcp.Tool{
+		Name:        "gemini_chat",
+		Description: "Have a multi-turn conversation with Gemini. The server keeps the conversation history per session, so follow-up turns only send the new message: start without session_id, then pass the returned session_id to continue. Turns can attach stored images, audio, short videos or PDFs by object key. With an image model (e.g. gemini-3-pro-image-preview) this supports iterative workflows such as 'now make the sky darker' without re-sending earlier images. Sessions expire after a period without use (CHAT_SESSION_TTL); set end_session to delete one early.",
+	}, withStorageOptions(s, s.handleGeminiChat))
+
+	// Register revise_image tool
+	addTool(server, &mcp.Tool{
+		Name:        "revise_image",
+		Description: "Refine an image step by step. Each call feeds the latest revision (or the one named by from_revision) back to the image model together with the new instruction, and stores the result as the next revision of the chain. Start a chain from an existing image (image_path) or from a text instruction, then pass the returned session_id with follow-up instructions such as 'make the sky darker'. The result lists every revision with its prompt and object key, so any step can be downloaded or branched from again.",
+	}, withStorageOptions(s, s.handleReviseImage))
+
+	// Register create_share_link tool
+	addTool(server, &mcp.Tool{
+		Name:        "create_share_link",
+		Description: "Create a fresh download URL for a stored file by its object key (as returned in saved_files or by upload_media), valid for link_ttl (1 minute to 7 days). Use it when an earlier download URL has expired or needs a different lifetime. Requires S3 storage or the HTTP transport.",
+	}, s.handleCreateShareLink)
+
+	// Register get_media_info tool
+	addTool(server, &mcp.Tool{
+		Name:        "get_media_info",
+		Description: "Describe a stored file by its object key (as returned in saved_files or by upload_media): MIME type, size, SHA-256 content hash, dimensions of images and videos, duration of videos and WAV audio, when it was stored and when it expires, and a fresh download URL valid for link_ttl. Use it to recover the details of a file whose original tool output is gone.",
+	}, s.handleGetMediaInfo)
+
+	// Register get_quota tool
+	addTool(server, &mcp.Tool{
+		Name:        "get_quota",
+		Description: "Report how many image and video generations the caller has left today and this month, and when each quota resets. Each image or video the models generate counts once, including blocked ones. Callers without quotas are told so.",
+	}, s.handleGetQuota)
 
 	// Register upload_media tool (guidance only - actual upload done via CLI)
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "upload_media",
 		Description: `Get instructions for uploading local files to S3 storage using the upload_media CLI tool.
 
@@ -534,20 +1515,30 @@ WORKFLOW:
 3. Parse the JSON output to get the object_key
 4. Use the object_key with gemini_image_edit, gemini_multi_image, or veo_image_to_video tools
 
+If an upload is interrupted, call this tool with the upload_id the CLI printed to get the command that resumes it from where it stopped.
+
 The upload_media CLI must be installed locally and S3 environment variables configured.`,
 	}, s.handleUploadMedia)
 
 }
 
 func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallToolRequest, input GeminiImageGenerationInput) (*mcp.CallToolResult, GeminiImageGenerationOutput, error) {
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, GeminiImageGenerationOutput{}, err
+	}
+
 	if input.Prompt == "" {
-		return nil, GeminiImageGenerationOutput{}, fmt.Errorf("prompt is required")
+		return nil, GeminiImageGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "prompt is required"))
 	}
 
 	// Set defaults
 	model := input.Model
 	if model == "" {
-		model = "gemini-3-pro-image-preview" // Default to Gemini 3 Pro Image for native image generation
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_image_generation", model); err != nil {
+		return nil, GeminiImageGenerationOutput{}, err
 	}
 
 	style := input.Style
@@ -576,6 +1567,29 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		}
 	}
 
+	aspectRatio := input.AspectRatio
+
+	// Presets drive aspect ratio and resolution
+	var preset imagePreset
+	if input.Preset != "" {
+		var err error
+		preset, err = lookupImagePreset(input.Preset)
+		if err != nil {
+			return nil, GeminiImageGenerationOutput{}, err
+		}
+		aspectRatio = preset.AspectRatio
+		imageSize = preset.ImageSize
+	}
+
+	if input.SeamlessTile && aspectRatio == "" {
+		aspectRatio = "1:1"
+	}
+
+	outputFormat, err := s.resolveImageFormat(input.OutputFormat, input.CompressionQuality, input.TransparentBackground)
+	if err != nil {
+		return nil, GeminiImageGenerationOutput{}, err
+	}
+
 	log.Printf("Generating image with model %s for prompt: %s (style: %s, quality: %s, image_size: %s)", model, input.Prompt, style, quality, imageSize)
 
 	// Build enhanced prompt with style and parameters
@@ -594,18 +1608,48 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		promptParts = append(promptParts, "highly detailed")
 	}
 
+	if input.TransparentBackground {
+		promptParts = append(promptParts, "isolated subject centered on a plain solid white background, no shadows, no scenery, no border")
+	}
+
+	if preset.PromptHint != "" {
+		promptParts = append(promptParts, preset.PromptHint)
+	}
+
+	if input.SeamlessTile {
+		promptParts = append(promptParts, "seamless tileable texture, pattern continues across all four edges, flat even lighting, no vignetting, no borders, no single focal object")
+	}
+
 	promptText := strings.Join(promptParts, ", ")
 
+	// Gemini image models have no negative prompt field, so fall back to an avoid-list
+	isGeminiModel := strings.HasPrefix(model, "gemini-")
+	if input.NegativePrompt != "" && isGeminiModel {
+		promptText = fmt.Sprintf("%s. Avoid: %s", promptText, input.NegativePrompt)
+	}
+
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var imageContents []mcp.Content // Collect image data for MCP response
 	timestamp := time.Now().Format("20060102_150405")
 	var imagesCreated int
+	var safetyFeedback *safety.Feedback
 
-	// Check if using Gemini native image generation or Imagen
-	isGeminiModel := strings.HasPrefix(model, "gemini-")
+	// Seam check results per image, for seamless_tile
+	seamChecks := map[string]string{}
 
+	// Wait for a free image generation slot
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, GeminiImageGenerationOutput{}, err
+	}
+	defer release()
+
+	// Check if using Gemini native image generation or Imagen
 	if isGeminiModel {
 		// Use GenerateContent for Gemini native image generation models
 		log.Printf("Using GenerateContent API for Gemini model: %s", model)
@@ -619,7 +1663,7 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		config := &genai.GenerateContentConfig{
 			ResponseModalities: []string{"IMAGE", "TEXT"},
 			ImageConfig: &genai.ImageConfig{
-				AspectRatio: input.AspectRatio,
+				AspectRatio: aspectRatio,
 				ImageSize:   imageSize,
 			},
 		}
@@ -627,11 +1671,16 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		// Generate content
 		response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
 		if err != nil {
-			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("error generating image: %v", err)
+			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("error generating image: %w", err)
 		}
+		usage.Add(ctx, response)
 
+		safetyFeedback = safety.FromContentResponse(response)
+		if safetyFeedback != nil && safetyFeedback.Blocked && len(response.Candidates) == 0 {
+			return safetyBlockedResult(ctx, safetyFeedback), GeminiImageGenerationOutput{Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+		}
 		if response == nil || len(response.Candidates) == 0 {
-			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("no image was generated")
+			return nil, GeminiImageGenerationOutput{}, toolerr.Errorf(toolerr.Upstream, "no image was generated")
 		}
 
 		// Process response and extract images
@@ -649,14 +1698,30 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 						mimeType = "image/png"
 					}
 
+					imageData := part.InlineData.Data
+					if input.TransparentBackground {
+						imageData, mimeType = s.transparentImage(ctx, imageData, mimeType)
+					}
+					if input.SeamlessTile {
+						imageData, mimeType = s.seamlessTile(ctx, imageData, mimeType, seamChecks)
+					}
+					if input.Preset != "" {
+						imageData, mimeType = s.cropToPreset(ctx, imageData, mimeType, preset)
+					}
+					imageData, mimeType = s.transcodeImage(ctx, imageData, mimeType, outputFormat)
+
 					// Store via storage interface
-					result, err := s.storage.Store(ctx, part.InlineData.Data, mimeType, "gemini_image")
+					result, err := s.storage.Store(ctx, imageData, mimeType, "gemini_image")
 					if err != nil {
-						log.Printf("Error storing image: %v", err)
+						warnings.Add(ctx, "failed to store image: %v", err)
 						continue
 					}
 
 					savedFiles = append(savedFiles, result.ObjectKey)
+					stored = append(stored, result)
+					copyToOutputDirectory(outputDir, result.ObjectKey, imageData)
+					dataURIs = s.addDataURI(dataURIs, result, imageData)
+					thumbnails = addThumbnail(thumbnails, result)
 					log.Printf("Stored image: %s", result.Location)
 
 					if s.storage.IsRemote() {
@@ -666,18 +1731,18 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 							expiresAt = result.ExpiresAt.Format(time.RFC3339)
 						}
 					} else {
-						// For local storage: return base64 image content
-						imageContents = append(imageContents, &mcp.ImageContent{
-							Data:     part.InlineData.Data,
-							MIMEType: mimeType,
-						})
+						// For local storage: return inline image or resource link
+						imageContents = append(imageContents, s.mediaContent(imageData, result)...)
 					}
 				}
 			}
 		}
 
 		if imagesCreated == 0 {
-			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("no images were generated in response")
+			if safetyFeedback != nil && safetyFeedback.Blocked {
+				return safetyBlockedResult(ctx, safetyFeedback), GeminiImageGenerationOutput{Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+			}
+			return nil, GeminiImageGenerationOutput{}, toolerr.Errorf(toolerr.Upstream, "no images were generated in response")
 		}
 
 	} else {
@@ -687,11 +1752,12 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		// Configure GenerateImagesConfig
 		config := &genai.GenerateImagesConfig{
 			NumberOfImages: 1,
+			NegativePrompt: input.NegativePrompt,
 		}
 
 		// Set aspect ratio if provided
-		if input.AspectRatio != "" {
-			config.AspectRatio = input.AspectRatio
+		if aspectRatio != "" {
+			config.AspectRatio = aspectRatio
 		}
 
 		config.ImageSize = imageSize
@@ -725,26 +1791,54 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		// Generate images using the dedicated GenerateImages method
 		response, err := s.client.Models.GenerateImages(ctx, model, promptText, config)
 		if err != nil {
-			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("error generating images: %v", err)
+			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("error generating images: %w", err)
 		}
 
-		if response == nil || len(response.GeneratedImages) == 0 {
-			return nil, GeminiImageGenerationOutput{}, fmt.Errorf("no images were generated")
+		safetyFeedback = safety.FromImagesResponse(response)
+		usable := 0
+		if response != nil {
+			for _, genImage := range response.GeneratedImages {
+				if genImage.Image != nil && len(genImage.Image.ImageBytes) > 0 {
+					usable++
+				}
+			}
+		}
+		if usable == 0 {
+			if safetyFeedback != nil {
+				return safetyBlockedResult(ctx, safetyFeedback), GeminiImageGenerationOutput{Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+			}
+			return nil, GeminiImageGenerationOutput{}, toolerr.Errorf(toolerr.Upstream, "no images were generated")
 		}
 
-		imagesCreated = len(response.GeneratedImages)
+		imagesCreated = usable
 
 		// Process generated images
 		for _, genImage := range response.GeneratedImages {
 			if genImage.Image != nil && len(genImage.Image.ImageBytes) > 0 {
+				imageData, mimeType := genImage.Image.ImageBytes, "image/png"
+				if input.TransparentBackground {
+					imageData, mimeType = s.transparentImage(ctx, imageData, mimeType)
+				}
+				if input.SeamlessTile {
+					imageData, mimeType = s.seamlessTile(ctx, imageData, mimeType, seamChecks)
+				}
+				if input.Preset != "" {
+					imageData, mimeType = s.cropToPreset(ctx, imageData, mimeType, preset)
+				}
+				imageData, mimeType = s.transcodeImage(ctx, imageData, mimeType, outputFormat)
+
 				// Store via storage interface
-				result, err := s.storage.Store(ctx, genImage.Image.ImageBytes, "image/png", "imagen_image")
+				result, err := s.storage.Store(ctx, imageData, mimeType, "imagen_image")
 				if err != nil {
-					log.Printf("Error storing image: %v", err)
+					warnings.Add(ctx, "failed to store image: %v", err)
 					continue
 				}
 
 				savedFiles = append(savedFiles, result.ObjectKey)
+				stored = append(stored, result)
+				copyToOutputDirectory(outputDir, result.ObjectKey, imageData)
+				dataURIs = s.addDataURI(dataURIs, result, imageData)
+				thumbnails = addThumbnail(thumbnails, result)
 				log.Printf("Stored image: %s", result.Location)
 
 				if s.storage.IsRemote() {
@@ -754,18 +1848,15 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 						expiresAt = result.ExpiresAt.Format(time.RFC3339)
 					}
 				} else {
-					// For local storage: return base64 image content
-					imageContents = append(imageContents, &mcp.ImageContent{
-						Data:     genImage.Image.ImageBytes,
-						MIMEType: "image/png",
-					})
+					// For local storage: return inline image or resource link
+					imageContents = append(imageContents, s.mediaContent(imageData, result)...)
 				}
 			}
 		}
 	}
 
 	// Create result description
-	resultText := fmt.Sprintf("Successfully generated %d image(s) using %s", imagesCreated, model)
+	resultText := i18n.Sprintf(ctx, "Successfully generated %d image(s) using %s", imagesCreated, model)
 
 	// Create metadata
 	metadata := map[string]string{
@@ -776,18 +1867,51 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 		"image_size":      imageSize,
 	}
 
+	if input.TransparentBackground {
+		metadata["transparent_background"] = "true"
+	}
+
+	if input.NegativePrompt != "" {
+		metadata["negative_prompt"] = input.NegativePrompt
+	}
+
+	if input.SeamlessTile {
+		metadata["seamless_tile"] = "true"
+		for k, v := range seamChecks {
+			metadata[k] = v
+		}
+	}
+
+	if input.Preset != "" {
+		metadata["preset"] = strings.ToLower(input.Preset)
+		metadata["output_size"] = fmt.Sprintf("%dx%d", preset.Width, preset.Height)
+	}
+
+	if outputFormat.name != "" {
+		metadata["output_format"] = outputFormat.name
+		if outputFormat.name != imaging.FormatPNG {
+			metadata["compression_quality"] = fmt.Sprintf("%d", outputFormat.quality)
+		}
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, GeminiImageGenerationOutput{}, ctx.Err()
+	}
+
 	// Build result based on storage type
 	var result *mcp.CallToolResult
 	if s.storage.IsRemote() {
 		// For S3: return text content with download URLs
 		var contentText string
 		if len(downloadURLs) > 0 {
-			contentText = fmt.Sprintf("Generated %d image(s). Download URLs:\n", len(downloadURLs))
+			contentText = i18n.Sprintf(ctx, "Generated %d image(s). Download URLs:\n", len(downloadURLs))
 			for i, url := range downloadURLs {
 				contentText += fmt.Sprintf("%d. %s\n", i+1, url)
 			}
 			if expiresAt != "" {
-				contentText += fmt.Sprintf("\nURLs expire at: %s", expiresAt)
+				contentText += i18n.Sprintf(ctx, "\nURLs expire at: %s", expiresAt)
 			}
 		}
 		result = &mcp.CallToolResult{
@@ -798,7 +1922,7 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 			},
 		}
 	} else {
-		// For local storage: return base64 image content
+		// For local storage: return inline image or resource link
 		if len(imageContents) > 0 {
 			result = &mcp.CallToolResult{
 				Content: imageContents,
@@ -807,34 +1931,135 @@ func (s *Server) handleGeminiImageGeneration(ctx context.Context, req *mcp.CallT
 	}
 
 	return result, GeminiImageGenerationOutput{
+		GenerationResult: s.generationResult("completed", stored, dataURIs, metadata),
+
 		Description:   resultText,
 		Model:         model,
 		Style:         style,
-		AspectRatio:   input.AspectRatio,
+		AspectRatio:   aspectRatio,
 		ImageSize:     imageSize,
 		Quality:       quality,
 		Language:      language,
 		Tags:          input.Tags,
 		SavedFiles:    savedFiles,
+		DataURIs:      dataURIs,
+		Thumbnails:    thumbnails,
 		DownloadURLs:  downloadURLs,
 		ExpiresAt:     expiresAt,
-		Metadata:      metadata,
+		Safety:        safetyFeedback,
 		GeneratedAt:   timestamp,
 		ImagesCreated: imagesCreated,
 	}, nil
 }
 
+// transparentImage converts generated image data into a PNG with a transparent
+// background. On failure the original image is returned unchanged.
+func (s *Server) transparentImage(ctx context.Context, data []byte, mimeType string) ([]byte, string) {
+	pngData, err := imaging.TransparentPNG(data, imaging.DefaultBackgroundTolerance)
+	if err != nil {
+		warnings.Add(ctx, "background removal failed, kept the original image: %v", err)
+		return data, mimeType
+	}
+	return pngData, "image/png"
+}
+
+// seamlessTile checks a generated texture for seams when wrapped and blends
+// its edges if the check fails. Seam ratios are recorded in seams, keyed by
+// image index. On decode failure the original image is returned unchanged.
+func (s *Server) seamlessTile(ctx context.Context, data []byte, mimeType string, seams map[string]string) ([]byte, string) {
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		warnings.Add(ctx, "seam check failed, kept the original image: %v", err)
+		return data, mimeType
+	}
+
+	key := fmt.Sprintf("seam_ratio_%d", len(seams)+1)
+	ratio := imaging.SeamRatio(img)
+	if ratio <= imaging.DefaultSeamThreshold {
+		seams[key] = fmt.Sprintf("%.2f", ratio)
+		return data, mimeType
+	}
+
+	blended := imaging.MakeSeamless(img)
+	pngData, err := imaging.EncodePNG(blended)
+	if err != nil {
+		warnings.Add(ctx, "seam blending failed, kept the original image: %v", err)
+		return data, mimeType
+	}
+	fixed := imaging.SeamRatio(blended)
+	log.Printf("Blended texture edges (seam ratio %.2f -> %.2f)", ratio, fixed)
+	seams[key] = fmt.Sprintf("%.2f (blended, was %.2f)", fixed, ratio)
+	return pngData, "image/png"
+}
+
+// generateImage runs a single Gemini native image generation call and returns
+// the first image in the response along with its MIME type. Optional input parts
+// (e.g. reference images) are sent after the text prompt.
+func (s *Server) generateImage(ctx context.Context, model, prompt, aspectRatio, imageSize string, inputs ...*genai.Part) ([]byte, string, error) {
+	parts := append([]*genai.Part{genai.NewPartFromText(prompt)}, inputs...)
+	contents := []*genai.Content{
+		genai.NewContentFromParts(parts, genai.RoleUser),
+	}
+
+	config := &genai.GenerateContentConfig{
+		ResponseModalities: []string{"IMAGE", "TEXT"},
+		ImageConfig: &genai.ImageConfig{
+			AspectRatio: aspectRatio,
+			ImageSize:   imageSize,
+		},
+	}
+
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, "", err
+	}
+	defer release()
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, "", fmt.Errorf("error generating image: %w", err)
+	}
+	usage.Add(ctx, response)
+
+	if response != nil {
+		for _, candidate := range response.Candidates {
+			if candidate.Content == nil {
+				continue
+			}
+			for _, part := range candidate.Content.Parts {
+				if part.InlineData != nil && len(part.InlineData.Data) > 0 {
+					mimeType := part.InlineData.MIMEType
+					if mimeType == "" {
+						mimeType = "image/png"
+					}
+					return part.InlineData.Data, mimeType, nil
+				}
+			}
+		}
+	}
+
+	return nil, "", toolerr.Errorf(toolerr.Upstream, "no image was generated")
+}
+
 func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolRequest, input GeminiImageEditInput) (*mcp.CallToolResult, GeminiImageEditOutput, error) {
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, err
+	}
+
 	if input.InputImagePath == "" {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("input_image_path is required")
+		return nil, GeminiImageEditOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "input_image_path is required"))
 	}
 	if input.EditPrompt == "" {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("edit_prompt is required")
+		return nil, GeminiImageEditOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "edit_prompt is required"))
 	}
 
 	model := input.Model
 	if model == "" {
-		model = "gemini-3-pro-image-preview"
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_image_edit", model); err != nil {
+		return nil, GeminiImageEditOutput{}, err
 	}
 
 	editType := input.EditType
@@ -842,12 +2067,21 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 		editType = "modify"
 	}
 
+	switch input.Animation {
+	case "", "first_frame", "all_frames":
+	default:
+		return nil, GeminiImageEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "animation must be 'first_frame' or 'all_frames'")
+	}
+	if input.MaxFrames < 0 || input.MaxFrames > maxAnimationFrames {
+		return nil, GeminiImageEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "max_frames must be between 1 and %d", maxAnimationFrames)
+	}
+
 	log.Printf("Editing image %s with model %s: %s", input.InputImagePath, model, input.EditPrompt)
 
 	// Resolve input image path (may download from S3)
 	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.InputImagePath)
 	if err != nil {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to resolve input image: %v", err)
+		return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
 	}
 	if cleanup != nil {
 		defer cleanup()
@@ -856,7 +2090,13 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 	// Read input image
 	imgData, err := os.ReadFile(localImagePath)
 	if err != nil {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to read input image: %v", err)
+		return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to read input image: %w", err)
+	}
+
+	// Detect MIME type from file contents rather than assuming PNG
+	imgMIMEType, err := imaging.DetectInputMIME(imgData)
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid input image: %w", err)
 	}
 
 	// Build edit prompt with instructions
@@ -888,32 +2128,58 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 
 	promptText := strings.Join(promptParts, ". ")
 
-	// Create content parts with image and text
+	if input.Animation == "all_frames" {
+		anim, err := s.loadAnimation(ctx, input.InputImagePath)
+		if err != nil {
+			return nil, GeminiImageEditOutput{}, fmt.Errorf("failed to read animated input: %w", err)
+		}
+		if anim != nil {
+			return s.editAnimation(ctx, anim, input, model, editType, promptText, outputDir)
+		}
+	}
+
+	// Create content parts with image and text; large images are uploaded
+	inputs := s.newInputParts()
+	defer inputs.Close()
+	imagePart, err := inputs.add(ctx, imgData, imgMIMEType)
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, err
+	}
 	parts := []*genai.Part{
 		genai.NewPartFromText(promptText),
-		&genai.Part{
-			InlineData: &genai.Blob{
-				MIMEType: "image/png",
-				Data:     imgData,
-			},
-		},
+		imagePart,
 	}
 
 	contents := []*genai.Content{
 		genai.NewContentFromParts(parts, genai.RoleUser),
 	}
 
+	// Wait for a free image generation slot
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, GeminiImageEditOutput{}, err
+	}
+	defer release()
+
 	response, err := s.client.Models.GenerateContent(ctx, model, contents, nil)
 	if err != nil {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("error editing image: %v", err)
+		return nil, GeminiImageEditOutput{}, fmt.Errorf("error editing image: %w", err)
 	}
+	usage.Add(ctx, response)
 
+	safetyFeedback := safety.FromContentResponse(response)
 	if response == nil || len(response.Candidates) == 0 {
-		return nil, GeminiImageEditOutput{}, fmt.Errorf("no edited content was generated")
+		if safetyFeedback != nil && safetyFeedback.Blocked {
+			return safetyBlockedResult(ctx, safetyFeedback), GeminiImageEditOutput{Model: model, Safety: safetyFeedback, GeneratedAt: time.Now().Format("20060102_150405")}, nil
+		}
+		return nil, GeminiImageEditOutput{}, toolerr.Errorf(toolerr.Upstream, "no edited content was generated")
 	}
 
 	// Process response
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var imageContents []mcp.Content
@@ -935,11 +2201,15 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 				// Store via storage interface
 				result, err := s.storage.Store(ctx, part.InlineData.Data, mimeType, "gemini_edit")
 				if err != nil {
-					log.Printf("Error storing image: %v", err)
+					warnings.Add(ctx, "failed to store image: %v", err)
 					continue
 				}
 
 				savedFiles = append(savedFiles, result.ObjectKey)
+				stored = append(stored, result)
+				copyToOutputDirectory(outputDir, result.ObjectKey, part.InlineData.Data)
+				dataURIs = s.addDataURI(dataURIs, result, part.InlineData.Data)
+				thumbnails = addThumbnail(thumbnails, result)
 				editedImagePath = result.Location
 				log.Printf("Stored edited image: %s", result.Location)
 
@@ -950,24 +2220,27 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 						expiresAt = result.ExpiresAt.Format(time.RFC3339)
 					}
 				} else {
-					// For local storage: return base64 image content
-					imageContents = append(imageContents, &mcp.ImageContent{
-						Data:     part.InlineData.Data,
-						MIMEType: mimeType,
-					})
+					// For local storage: return inline image or resource link
+					imageContents = append(imageContents, s.mediaContent(part.InlineData.Data, result)...)
 				}
 			}
 		}
 	}
 
+	if len(savedFiles) == 0 && safetyFeedback != nil && safetyFeedback.Blocked {
+		return safetyBlockedResult(ctx, safetyFeedback), GeminiImageEditOutput{Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+	}
+
 	// Create metadata
-	metadata := map[string]string{
-		"original_image": input.InputImagePath,
-		"edit_prompt":    input.EditPrompt,
-		"edit_type":      editType,
-		"aspect_ratio":   input.AspectRatio,
-		"preserve_style": fmt.Sprintf("%t", input.PreserveStyle),
-		"mask_area":      input.MaskArea,
+	metadata := editMetadata(input, editType)
+	if uploaded := inputs.Uploaded(); len(uploaded) > 0 {
+		metadata["gemini_files"] = strings.Join(uploaded, ", ")
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, GeminiImageEditOutput{}, ctx.Err()
 	}
 
 	// Build result based on storage type
@@ -976,12 +2249,12 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 		// For S3: return text content with download URLs
 		var contentText string
 		if len(downloadURLs) > 0 {
-			contentText = fmt.Sprintf("Edited image. Download URLs:\n")
+			contentText = i18n.Sprintf(ctx, "Edited image. Download URLs:\n")
 			for i, url := range downloadURLs {
 				contentText += fmt.Sprintf("%d. %s\n", i+1, url)
 			}
 			if expiresAt != "" {
-				contentText += fmt.Sprintf("\nURLs expire at: %s", expiresAt)
+				contentText += i18n.Sprintf(ctx, "\nURLs expire at: %s", expiresAt)
 			}
 		}
 		result = &mcp.CallToolResult{
@@ -992,7 +2265,7 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 			},
 		}
 	} else {
-		// For local storage: return base64 image content
+		// For local storage: return inline image or resource link
 		if len(imageContents) > 0 {
 			result = &mcp.CallToolResult{
 				Content: imageContents,
@@ -1001,33 +2274,63 @@ func (s *Server) handleGeminiImageEdit(ctx context.Context, req *mcp.CallToolReq
 	}
 
 	return result, GeminiImageEditOutput{
+		GenerationResult: s.generationResult("completed", stored, dataURIs, metadata),
+
 		OriginalImage: input.InputImagePath,
 		EditedImage:   editedImagePath,
 		EditType:      editType,
 		AspectRatio:   input.AspectRatio,
 		Model:         model,
 		SavedFiles:    savedFiles,
+		DataURIs:      dataURIs,
+		Thumbnails:    thumbnails,
 		DownloadURLs:  downloadURLs,
 		ExpiresAt:     expiresAt,
-		Metadata:      metadata,
+		Safety:        safetyFeedback,
 		GeneratedAt:   timestamp,
 	}, nil
 }
 
+// editMetadata describes an image edit request
+func editMetadata(input GeminiImageEditInput, editType string) map[string]string {
+	return map[string]string{
+		"original_image": input.InputImagePath,
+		"edit_prompt":    input.EditPrompt,
+		"edit_type":      editType,
+		"aspect_ratio":   input.AspectRatio,
+		"preserve_style": fmt.Sprintf("%t", input.PreserveStyle),
+		"mask_area":      input.MaskArea,
+	}
+}
+
 func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRequest, input GeminiMultiImageInput) (*mcp.CallToolResult, GeminiMultiImageOutput, error) {
-	if len(input.InputImagePaths) < 2 {
-		return nil, GeminiMultiImageOutput{}, fmt.Errorf("at least 2 input images are required")
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, GeminiMultiImageOutput{}, err
 	}
-	if len(input.InputImagePaths) > 3 {
-		return nil, GeminiMultiImageOutput{}, fmt.Errorf("maximum 3 input images supported")
+
+	if len(input.InputImagePaths) < 2 {
+		return nil, GeminiMultiImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "at least 2 input images are required")
 	}
 	if input.CombinePrompt == "" {
-		return nil, GeminiMultiImageOutput{}, fmt.Errorf("combine_prompt is required")
+		return nil, GeminiMultiImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "combine_prompt is required")
 	}
 
 	model := input.Model
 	if model == "" {
-		model = "gemini-3-pro-image-preview"
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_multi_image", model); err != nil {
+		return nil, GeminiMultiImageOutput{}, err
+	}
+
+	if maxImages := maxMultiImageInputs(model); len(input.InputImagePaths) > maxImages {
+		return nil, GeminiMultiImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "maximum %d input images supported by %s", maxImages, model)
+	}
+
+	images, err := orderMultiImageInputs(input.InputImagePaths, input.ImageRoles)
+	if err != nil {
+		return nil, GeminiMultiImageOutput{}, err
 	}
 
 	blendMode := input.BlendMode
@@ -1041,6 +2344,10 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 	var promptParts []string
 	promptParts = append(promptParts, input.CombinePrompt)
 
+	if roles := describeImageRoles(images); roles != "" {
+		promptParts = append(promptParts, roles)
+	}
+
 	if input.AspectRatio != "" {
 		promptParts = append(promptParts, fmt.Sprintf("Aspect ratio: %s", input.AspectRatio))
 	}
@@ -1073,12 +2380,17 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 		}
 	}()
 
-	// Add all input images to parts
-	for i, imagePath := range input.InputImagePaths {
+	// Add all input images to parts, each labelled with its number and role.
+	// Images that do not fit inline are uploaded.
+	inputs := s.newInputParts()
+	defer inputs.Close()
+	for i, image := range images {
+		imagePath := image.Path
+
 		// Resolve input image path (may download from S3)
 		localImagePath, cleanup, err := s.resolveInputPath(ctx, imagePath)
 		if err != nil {
-			return nil, GeminiMultiImageOutput{}, fmt.Errorf("failed to resolve image %d (%s): %v", i+1, imagePath, err)
+			return nil, GeminiMultiImageOutput{}, fmt.Errorf("failed to resolve image %d (%s): %w", i+1, imagePath, err)
 		}
 		if cleanup != nil {
 			cleanups = append(cleanups, cleanup)
@@ -1086,32 +2398,56 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 
 		imgData, err := os.ReadFile(localImagePath)
 		if err != nil {
-			return nil, GeminiMultiImageOutput{}, fmt.Errorf("failed to read image %d (%s): %v", i+1, imagePath, err)
+			return nil, GeminiMultiImageOutput{}, fmt.Errorf("failed to read image %d (%s): %w", i+1, imagePath, err)
 		}
 
-		parts = append(parts, &genai.Part{
-			InlineData: &genai.Blob{
-				MIMEType: "image/png",
-				Data:     imgData,
-			},
-		})
+		// Detect MIME type from file contents rather than assuming PNG
+		imgMIMEType, err := imaging.DetectInputMIME(imgData)
+		if err != nil {
+			return nil, GeminiMultiImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid image %d (%s): %w", i+1, imagePath, err)
+		}
+
+		label := fmt.Sprintf("Image %d", i+1)
+		if image.Role != "" {
+			label += fmt.Sprintf(" (%s)", image.Role)
+		}
+		imagePart, err := inputs.add(ctx, imgData, imgMIMEType)
+		if err != nil {
+			return nil, GeminiMultiImageOutput{}, fmt.Errorf("image %d (%s): %w", i+1, imagePath, err)
+		}
+		parts = append(parts, genai.NewPartFromText(label+":"), imagePart)
 	}
 
 	contents := []*genai.Content{
 		genai.NewContentFromParts(parts, genai.RoleUser),
 	}
 
+	// Wait for a free image generation slot
+	release, err := s.acquireGeneration(ctx, quota.Images)
+	if err != nil {
+		return nil, GeminiMultiImageOutput{}, err
+	}
+	defer release()
+
 	response, err := s.client.Models.GenerateContent(ctx, model, contents, nil)
 	if err != nil {
-		return nil, GeminiMultiImageOutput{}, fmt.Errorf("error combining images: %v", err)
+		return nil, GeminiMultiImageOutput{}, fmt.Errorf("error combining images: %w", err)
 	}
+	usage.Add(ctx, response)
 
+	safetyFeedback := safety.FromContentResponse(response)
 	if response == nil || len(response.Candidates) == 0 {
-		return nil, GeminiMultiImageOutput{}, fmt.Errorf("no combined content was generated")
+		if safetyFeedback != nil && safetyFeedback.Blocked {
+			return safetyBlockedResult(ctx, safetyFeedback), GeminiMultiImageOutput{Model: model, Safety: safetyFeedback, GeneratedAt: time.Now().Format("20060102_150405")}, nil
+		}
+		return nil, GeminiMultiImageOutput{}, toolerr.Errorf(toolerr.Upstream, "no combined content was generated")
 	}
 
 	// Process response
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var imageContents []mcp.Content
@@ -1133,11 +2469,15 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 				// Store via storage interface
 				result, err := s.storage.Store(ctx, part.InlineData.Data, mimeType, "gemini_multi")
 				if err != nil {
-					log.Printf("Error storing image: %v", err)
+					warnings.Add(ctx, "failed to store image: %v", err)
 					continue
 				}
 
 				savedFiles = append(savedFiles, result.ObjectKey)
+				stored = append(stored, result)
+				copyToOutputDirectory(outputDir, result.ObjectKey, part.InlineData.Data)
+				dataURIs = s.addDataURI(dataURIs, result, part.InlineData.Data)
+				thumbnails = addThumbnail(thumbnails, result)
 				combinedImagePath = result.Location
 				log.Printf("Stored combined image: %s", result.Location)
 
@@ -1148,16 +2488,17 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 						expiresAt = result.ExpiresAt.Format(time.RFC3339)
 					}
 				} else {
-					// For local storage: return base64 image content
-					imageContents = append(imageContents, &mcp.ImageContent{
-						Data:     part.InlineData.Data,
-						MIMEType: mimeType,
-					})
+					// For local storage: return inline image or resource link
+					imageContents = append(imageContents, s.mediaContent(part.InlineData.Data, result)...)
 				}
 			}
 		}
 	}
 
+	if len(savedFiles) == 0 && safetyFeedback != nil && safetyFeedback.Blocked {
+		return safetyBlockedResult(ctx, safetyFeedback), GeminiMultiImageOutput{Model: model, Safety: safetyFeedback, GeneratedAt: timestamp}, nil
+	}
+
 	// Create metadata
 	metadata := map[string]string{
 		"combine_prompt": input.CombinePrompt,
@@ -1167,18 +2508,35 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 		"images_count":   fmt.Sprintf("%d", len(input.InputImagePaths)),
 	}
 
+	if len(input.ImageRoles) > 0 {
+		var order []string
+		for _, image := range images {
+			order = append(order, fmt.Sprintf("%s=%s", image.Path, image.Role))
+		}
+		metadata["image_roles"] = strings.Join(order, ", ")
+	}
+	if uploaded := inputs.Uploaded(); len(uploaded) > 0 {
+		metadata["gemini_files"] = strings.Join(uploaded, ", ")
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, GeminiMultiImageOutput{}, ctx.Err()
+	}
+
 	// Build result based on storage type
 	var result *mcp.CallToolResult
 	if s.storage.IsRemote() {
 		// For S3: return text content with download URLs
 		var contentText string
 		if len(downloadURLs) > 0 {
-			contentText = "Combined image. Download URLs:\n"
+			contentText = i18n.Sprintf(ctx, "Combined image. Download URLs:\n")
 			for i, url := range downloadURLs {
 				contentText += fmt.Sprintf("%d. %s\n", i+1, url)
 			}
 			if expiresAt != "" {
-				contentText += fmt.Sprintf("\nURLs expire at: %s", expiresAt)
+				contentText += i18n.Sprintf(ctx, "\nURLs expire at: %s", expiresAt)
 			}
 		}
 		result = &mcp.CallToolResult{
@@ -1189,7 +2547,7 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 			},
 		}
 	} else {
-		// For local storage: return base64 image content
+		// For local storage: return inline image or resource link
 		if len(imageContents) > 0 {
 			result = &mcp.CallToolResult{
 				Content: imageContents,
@@ -1198,23 +2556,32 @@ func (s *Server) handleGeminiMultiImage(ctx context.Context, req *mcp.CallToolRe
 	}
 
 	return result, GeminiMultiImageOutput{
+		GenerationResult: s.generationResult("completed", stored, dataURIs, metadata),
+
 		InputImages:     input.InputImagePaths,
 		CombinedImage:   combinedImagePath,
 		BlendMode:       blendMode,
 		AspectRatio:     input.AspectRatio,
 		Model:           model,
 		SavedFiles:      savedFiles,
+		DataURIs:        dataURIs,
+		Thumbnails:      thumbnails,
 		DownloadURLs:    downloadURLs,
 		ExpiresAt:       expiresAt,
-		Metadata:        metadata,
+		Safety:          safetyFeedback,
 		GeneratedAt:     timestamp,
 		ImagesProcessed: len(input.InputImagePaths),
 	}, nil
 }
 
 func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolRequest, input VeoGenerationInput) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
 	if input.Prompt == "" {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("prompt is required")
+		return nil, VeoGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "prompt is required"))
 	}
 
 	// Set defaults
@@ -1230,56 +2597,89 @@ func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolReque
 
 	model := input.Model
 	if model == "" {
-		model = "veo-3.1-generate-preview"
+		model = s.config.VeoDefaultModel
+	}
+	if err := s.allowlist.Check("veo_generate_video", model); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	if err := s.confirmVeoCost(ctx, req, model, resolution, 1, input.ConfirmCost); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	// Match the look of an existing clip
+	config := veoConfig(aspectRatio, resolution)
+	if input.ReferenceVideoPath != "" {
+		references, err := s.loadVeoVideoReference(ctx, input.ReferenceVideoPath, model)
+		if err != nil {
+			return nil, VeoGenerationOutput{}, err
+		}
+		config.ReferenceImages = references
 	}
 
 	log.Printf("Generating video with model %s for prompt: %s (aspect: %s, resolution: %s)", model, input.Prompt, aspectRatio, resolution)
 
 	timestamp := time.Now().Format("20060102_150405")
 
-	// Build prompt with negative prompt if specified
-	promptText := input.Prompt
-	if input.NegativePrompt != "" {
-		promptText = fmt.Sprintf("%s. Avoid: %s", input.Prompt, input.NegativePrompt)
+	// Wait for a free video generation slot (held while polling)
+	release, err := s.acquireGeneration(ctx, quota.Videos)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
 	}
+	defer func() { release() }()
 
 	// Generate video using Gemini API - correct signature from documentation
-	operation, err := s.client.Models.GenerateVideos(
+	operation, err := s.startVideoGeneration(
 		ctx,
 		model,
-		promptText,
+		input.Prompt,
+		input.NegativePrompt,
 		nil, // image parameter (nil for text-only)
-		nil, // config parameter (nil to use defaults)
+		config,
 	)
 	if err != nil {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting video generation: %v", err)
+		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting video generation: %w", err)
 	}
 
 	operationID := operation.Name
 	log.Printf("Video generation started with operation ID: %s", operationID)
 
-	// Poll operation status until completion
-	maxAttempts := 60 // 10 minutes max
-	for i := 0; i < maxAttempts && !operation.Done; i++ {
-		log.Printf("Waiting for video generation to complete... (attempt %d/%d)", i+1, maxAttempts)
-		time.Sleep(10 * time.Second)
-		operation, err = s.client.Operations.GetVideosOperation(ctx, operation, nil)
-		if err != nil {
-			log.Printf("Error checking operation status: %v", err)
-			break
-		}
+	if input.Async {
+		// The job takes over the generation slot until it is done
+		result := s.startVideoJob(ctx, "veo_generate_video", "video generation", "veo_video", operation, outputDir, input.WebhookURL, release)
+		release = func() {}
+		return result, VeoGenerationOutput{
+			GenerationResult: GenerationResult{Status: "generating"},
+			OperationID:      operationID,
+			Model:            model,
+			AspectRatio:      aspectRatio,
+			Resolution:       resolution,
+			GeneratedAt:      timestamp,
+			EstimatedLength:  "8 seconds",
+		}, nil
+	}
+
+	// Poll operation status until completion or cancellation
+	operation, err = s.pollVideoOperation(ctx, operation, "video generation")
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("video generation %s cancelled: %w", operationID, err)
 	}
 
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var videoURL string
+	var videoContents []mcp.Content
+	var safetyFeedback *safety.Feedback
 	status := "generating"
 
 	if operation.Done {
 		if operation.Error != nil {
 			status = "failed"
-			log.Printf("Video generation failed: %v", operation.Error)
+			warnings.Add(ctx, "video generation failed: %v", operation.Error)
 		} else if len(operation.Response.GeneratedVideos) > 0 {
 			status = "completed"
 			video := operation.Response.GeneratedVideos[0]
@@ -1289,14 +2689,18 @@ func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolReque
 			downloadURI := genai.NewDownloadURIFromVideo(video.Video)
 			videoData, err := s.client.Files.Download(ctx, downloadURI, nil)
 			if err != nil {
-				log.Printf("Error downloading video: %v", err)
+				warnings.Add(ctx, "failed to download video: %v", err)
 			} else {
 				// Store via storage interface
 				result, err := s.storage.Store(ctx, videoData, "video/mp4", "veo_video")
 				if err != nil {
-					log.Printf("Error storing video: %v", err)
+					warnings.Add(ctx, "failed to store video: %v", err)
 				} else {
 					savedFiles = append(savedFiles, result.ObjectKey)
+					stored = append(stored, result)
+					copyToOutputDirectory(outputDir, result.ObjectKey, videoData)
+					dataURIs = s.addDataURI(dataURIs, result, videoData)
+					thumbnails = addThumbnail(thumbnails, result)
 					videoURL = result.Location
 					log.Printf("Stored video: %s", result.Location)
 
@@ -1305,13 +2709,20 @@ func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolReque
 						if result.ExpiresAt != nil {
 							expiresAt = result.ExpiresAt.Format(time.RFC3339)
 						}
+					} else {
+						// For local storage: return a resource link to the video file
+						videoContents = s.mediaContent(videoData, result)
 					}
 				}
 			}
+		} else if feedback := safety.FromVideosResponse(operation.Response); feedback != nil && feedback.Blocked {
+			status = "blocked"
+			safetyFeedback = feedback
+			log.Printf("Video generation blocked by safety filters: %s", feedback.Summary())
 		}
 	} else {
 		status = "timeout"
-		log.Printf("Video generation timed out after 10 minutes")
+		warnings.Add(ctx, "video generation did not finish within 10 minutes")
 	}
 
 	// Create metadata
@@ -1320,13 +2731,22 @@ func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolReque
 		"negative_prompt": input.NegativePrompt,
 		"operation_id":    operationID,
 	}
+	if input.ReferenceVideoPath != "" {
+		metadata["reference_video"] = input.ReferenceVideoPath
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, VeoGenerationOutput{}, ctx.Err()
+	}
 
 	// Build result based on storage type
 	var result *mcp.CallToolResult
 	if s.storage.IsRemote() && len(downloadURLs) > 0 {
-		contentText := fmt.Sprintf("Video generated. Download URL:\n%s", downloadURLs[0])
+		contentText := i18n.Sprintf(ctx, "Video generated. Download URL:\n%s", downloadURLs[0])
 		if expiresAt != "" {
-			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+			contentText += i18n.Sprintf(ctx, "\n\nURL expires at: %s", expiresAt)
 		}
 		result = &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -1335,27 +2755,43 @@ func (s *Server) handleVeoGeneration(ctx context.Context, req *mcp.CallToolReque
 				},
 			},
 		}
+	} else if len(videoContents) > 0 {
+		result = &mcp.CallToolResult{
+			Content: videoContents,
+		}
+	}
+
+	if safetyFeedback != nil {
+		result = safetyBlockedResult(ctx, safetyFeedback)
 	}
 
 	return result, VeoGenerationOutput{
+		GenerationResult: s.generationResult(status, stored, dataURIs, metadata),
+
 		OperationID:     operationID,
-		Status:          status,
 		VideoURL:        videoURL,
 		SavedFiles:      savedFiles,
+		DataURIs:        dataURIs,
+		Thumbnails:      thumbnails,
 		DownloadURLs:    downloadURLs,
 		ExpiresAt:       expiresAt,
 		Model:           model,
 		AspectRatio:     aspectRatio,
 		Resolution:      resolution,
-		Metadata:        metadata,
+		Safety:          safetyFeedback,
 		GeneratedAt:     timestamp,
 		EstimatedLength: "8 seconds",
 	}, nil
 }
 
 func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequest, input VeoTextToVideoInput) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
 	if input.Prompt == "" {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("prompt is required")
+		return nil, VeoGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "prompt is required"))
 	}
 
 	// Set defaults
@@ -1371,56 +2807,89 @@ func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequ
 
 	model := input.Model
 	if model == "" {
-		model = "veo-3.1-generate-preview"
+		model = s.config.VeoDefaultModel
+	}
+	if err := s.allowlist.Check("veo_text_to_video", model); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	if err := s.confirmVeoCost(ctx, req, model, resolution, 1, input.ConfirmCost); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	// Match the look of an existing clip
+	config := veoConfig(aspectRatio, resolution)
+	if input.ReferenceVideoPath != "" {
+		references, err := s.loadVeoVideoReference(ctx, input.ReferenceVideoPath, model)
+		if err != nil {
+			return nil, VeoGenerationOutput{}, err
+		}
+		config.ReferenceImages = references
 	}
 
 	log.Printf("Generating text-to-video with model %s for prompt: %s (aspect: %s, resolution: %s)", model, input.Prompt, aspectRatio, resolution)
 
 	timestamp := time.Now().Format("20060102_150405")
 
-	// Build prompt with negative prompt if specified
-	promptText := input.Prompt
-	if input.NegativePrompt != "" {
-		promptText = fmt.Sprintf("%s. Avoid: %s", input.Prompt, input.NegativePrompt)
+	// Wait for a free video generation slot (held while polling)
+	release, err := s.acquireGeneration(ctx, quota.Videos)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
 	}
+	defer func() { release() }()
 
 	// Generate video using Gemini API - text-to-video (no image)
-	operation, err := s.client.Models.GenerateVideos(
+	operation, err := s.startVideoGeneration(
 		ctx,
 		model,
-		promptText,
+		input.Prompt,
+		input.NegativePrompt,
 		nil, // No image for text-to-video
-		nil, // Use default config
+		config,
 	)
 	if err != nil {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting text-to-video generation: %v", err)
+		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting text-to-video generation: %w", err)
 	}
 
 	operationID := operation.Name
 	log.Printf("Text-to-video generation started with operation ID: %s", operationID)
 
-	// Poll operation status until completion
-	maxAttempts := 60 // 10 minutes max
-	for i := 0; i < maxAttempts && !operation.Done; i++ {
-		log.Printf("Waiting for text-to-video generation to complete... (attempt %d/%d)", i+1, maxAttempts)
-		time.Sleep(10 * time.Second)
-		operation, err = s.client.Operations.GetVideosOperation(ctx, operation, nil)
-		if err != nil {
-			log.Printf("Error checking operation status: %v", err)
-			break
-		}
+	if input.Async {
+		// The job takes over the generation slot until it is done
+		result := s.startVideoJob(ctx, "veo_text_to_video", "text-to-video generation", "veo_text2video", operation, outputDir, input.WebhookURL, release)
+		release = func() {}
+		return result, VeoGenerationOutput{
+			GenerationResult: GenerationResult{Status: "generating"},
+			OperationID:      operationID,
+			Model:            model,
+			AspectRatio:      aspectRatio,
+			Resolution:       resolution,
+			GeneratedAt:      timestamp,
+			EstimatedLength:  "8 seconds",
+		}, nil
+	}
+
+	// Poll operation status until completion or cancellation
+	operation, err = s.pollVideoOperation(ctx, operation, "text-to-video generation")
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("text-to-video generation %s cancelled: %w", operationID, err)
 	}
 
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var videoURL string
+	var videoContents []mcp.Content
+	var safetyFeedback *safety.Feedback
 	status := "generating"
 
 	if operation.Done {
 		if operation.Error != nil {
 			status = "failed"
-			log.Printf("Text-to-video generation failed: %v", operation.Error)
+			warnings.Add(ctx, "video generation failed: %v", operation.Error)
 		} else if len(operation.Response.GeneratedVideos) > 0 {
 			status = "completed"
 			video := operation.Response.GeneratedVideos[0]
@@ -1430,14 +2899,18 @@ func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequ
 			downloadURI := genai.NewDownloadURIFromVideo(video.Video)
 			videoData, err := s.client.Files.Download(ctx, downloadURI, nil)
 			if err != nil {
-				log.Printf("Error downloading video: %v", err)
+				warnings.Add(ctx, "failed to download video: %v", err)
 			} else {
 				// Store via storage interface
 				result, err := s.storage.Store(ctx, videoData, "video/mp4", "veo_text2video")
 				if err != nil {
-					log.Printf("Error storing video: %v", err)
+					warnings.Add(ctx, "failed to store video: %v", err)
 				} else {
 					savedFiles = append(savedFiles, result.ObjectKey)
+					stored = append(stored, result)
+					copyToOutputDirectory(outputDir, result.ObjectKey, videoData)
+					dataURIs = s.addDataURI(dataURIs, result, videoData)
+					thumbnails = addThumbnail(thumbnails, result)
 					videoURL = result.Location
 					log.Printf("Stored text-to-video: %s", result.Location)
 
@@ -1446,13 +2919,20 @@ func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequ
 						if result.ExpiresAt != nil {
 							expiresAt = result.ExpiresAt.Format(time.RFC3339)
 						}
+					} else {
+						// For local storage: return a resource link to the video file
+						videoContents = s.mediaContent(videoData, result)
 					}
 				}
 			}
+		} else if feedback := safety.FromVideosResponse(operation.Response); feedback != nil && feedback.Blocked {
+			status = "blocked"
+			safetyFeedback = feedback
+			log.Printf("Video generation blocked by safety filters: %s", feedback.Summary())
 		}
 	} else {
 		status = "timeout"
-		log.Printf("Text-to-video generation timed out after 10 minutes")
+		warnings.Add(ctx, "video generation did not finish within 10 minutes")
 	}
 
 	// Create metadata
@@ -1462,17 +2942,26 @@ func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequ
 		"negative_prompt": input.NegativePrompt,
 		"operation_id":    operationID,
 	}
+	if input.ReferenceVideoPath != "" {
+		metadata["reference_video"] = input.ReferenceVideoPath
+	}
 
 	if input.Seed > 0 {
 		metadata["seed"] = fmt.Sprintf("%d", input.Seed)
 	}
 
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, VeoGenerationOutput{}, ctx.Err()
+	}
+
 	// Build result based on storage type
 	var result *mcp.CallToolResult
 	if s.storage.IsRemote() && len(downloadURLs) > 0 {
-		contentText := fmt.Sprintf("Text-to-video generated. Download URL:\n%s", downloadURLs[0])
+		contentText := i18n.Sprintf(ctx, "Text-to-video generated. Download URL:\n%s", downloadURLs[0])
 		if expiresAt != "" {
-			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+			contentText += i18n.Sprintf(ctx, "\n\nURL expires at: %s", expiresAt)
 		}
 		result = &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -1481,36 +2970,52 @@ func (s *Server) handleVeoTextToVideo(ctx context.Context, req *mcp.CallToolRequ
 				},
 			},
 		}
+	} else if len(videoContents) > 0 {
+		result = &mcp.CallToolResult{
+			Content: videoContents,
+		}
+	}
+
+	if safetyFeedback != nil {
+		result = safetyBlockedResult(ctx, safetyFeedback)
 	}
 
 	return result, VeoGenerationOutput{
+		GenerationResult: s.generationResult(status, stored, dataURIs, metadata),
+
 		OperationID:     operationID,
-		Status:          status,
 		VideoURL:        videoURL,
 		SavedFiles:      savedFiles,
+		DataURIs:        dataURIs,
+		Thumbnails:      thumbnails,
 		DownloadURLs:    downloadURLs,
 		ExpiresAt:       expiresAt,
 		Model:           model,
 		AspectRatio:     aspectRatio,
 		Resolution:      resolution,
-		Metadata:        metadata,
+		Safety:          safetyFeedback,
 		GeneratedAt:     timestamp,
 		EstimatedLength: "8 seconds",
 	}, nil
 }
 
 func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolRequest, input VeoImageToVideoInput) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+	outputDir, err := s.resolveOutputDirectory(ctx, input.OutputDirectory)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
 	if input.ImagePath == "" {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("image_path is required")
+		return nil, VeoGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
 	}
 	if input.Prompt == "" {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("prompt is required")
+		return nil, VeoGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "prompt is required"))
 	}
 
 	// Resolve input image path (may download from S3)
 	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
 	if err != nil {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("failed to resolve input image: %v", err)
+		return nil, VeoGenerationOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
 	}
 	if cleanup != nil {
 		defer cleanup()
@@ -1529,7 +3034,14 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 
 	model := input.Model
 	if model == "" {
-		model = "veo-3.1-generate-preview"
+		model = s.config.VeoDefaultModel
+	}
+	if err := s.allowlist.Check("veo_image_to_video", model); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	if err := s.confirmVeoCost(ctx, req, model, resolution, 1, input.ConfirmCost); err != nil {
+		return nil, VeoGenerationOutput{}, err
 	}
 
 	log.Printf("Generating image-to-video with model %s for image: %s, prompt: %s (aspect: %s, resolution: %s)",
@@ -1540,7 +3052,7 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 	// Read the input image file
 	imageData, err := os.ReadFile(localImagePath)
 	if err != nil {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("failed to read input image: %v", err)
+		return nil, VeoGenerationOutput{}, fmt.Errorf("failed to read input image: %w", err)
 	}
 
 	// Detect MIME type from file extension (required by Veo API)
@@ -1565,49 +3077,65 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 		MIMEType:   mimeType,
 	}
 
-	// Build prompt with negative prompt if specified
-	promptText := input.Prompt
-	if input.NegativePrompt != "" {
-		promptText = fmt.Sprintf("%s. Avoid: %s", input.Prompt, input.NegativePrompt)
+	// Wait for a free video generation slot (held while polling)
+	release, err := s.acquireGeneration(ctx, quota.Videos)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
 	}
+	defer func() { release() }()
 
 	// Generate video using Gemini API - image-to-video
-	operation, err := s.client.Models.GenerateVideos(
+	operation, err := s.startVideoGeneration(
 		ctx,
 		model,
-		promptText,
+		input.Prompt,
+		input.NegativePrompt,
 		inputImage, // Pass the processed image
-		nil,        // Use default config
+		veoConfig(aspectRatio, resolution),
 	)
 	if err != nil {
-		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting image-to-video generation: %v", err)
+		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting image-to-video generation: %w", err)
 	}
 
 	operationID := operation.Name
 	log.Printf("Image-to-video generation started with operation ID: %s", operationID)
 
-	// Poll operation status until completion
-	maxAttempts := 60 // 10 minutes max
-	for i := 0; i < maxAttempts && !operation.Done; i++ {
-		log.Printf("Waiting for image-to-video generation to complete... (attempt %d/%d)", i+1, maxAttempts)
-		time.Sleep(10 * time.Second)
-		operation, err = s.client.Operations.GetVideosOperation(ctx, operation, nil)
-		if err != nil {
-			log.Printf("Error checking operation status: %v", err)
-			break
-		}
+	if input.Async {
+		// The job takes over the generation slot until it is done
+		result := s.startVideoJob(ctx, "veo_image_to_video", "image-to-video generation", "veo_img2video", operation, outputDir, input.WebhookURL, release)
+		release = func() {}
+		return result, VeoGenerationOutput{
+			GenerationResult: GenerationResult{Status: "generating"},
+			OperationID:      operationID,
+			Model:            model,
+			AspectRatio:      aspectRatio,
+			Resolution:       resolution,
+			GeneratedAt:      timestamp,
+			EstimatedLength:  "8 seconds",
+		}, nil
+	}
+
+	// Poll operation status until completion or cancellation
+	operation, err = s.pollVideoOperation(ctx, operation, "image-to-video generation")
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("image-to-video generation %s cancelled: %w", operationID, err)
 	}
 
 	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
 	var downloadURLs []string
 	var expiresAt string
 	var videoURL string
+	var videoContents []mcp.Content
+	var safetyFeedback *safety.Feedback
 	status := "generating"
 
 	if operation.Done {
 		if operation.Error != nil {
 			status = "failed"
-			log.Printf("Image-to-video generation failed: %v", operation.Error)
+			warnings.Add(ctx, "video generation failed: %v", operation.Error)
 		} else if len(operation.Response.GeneratedVideos) > 0 {
 			status = "completed"
 			video := operation.Response.GeneratedVideos[0]
@@ -1617,14 +3145,18 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 			downloadURI := genai.NewDownloadURIFromVideo(video.Video)
 			videoData, err := s.client.Files.Download(ctx, downloadURI, nil)
 			if err != nil {
-				log.Printf("Error downloading video: %v", err)
+				warnings.Add(ctx, "failed to download video: %v", err)
 			} else {
 				// Store via storage interface
 				result, err := s.storage.Store(ctx, videoData, "video/mp4", "veo_img2video")
 				if err != nil {
-					log.Printf("Error storing video: %v", err)
+					warnings.Add(ctx, "failed to store video: %v", err)
 				} else {
 					savedFiles = append(savedFiles, result.ObjectKey)
+					stored = append(stored, result)
+					copyToOutputDirectory(outputDir, result.ObjectKey, videoData)
+					dataURIs = s.addDataURI(dataURIs, result, videoData)
+					thumbnails = addThumbnail(thumbnails, result)
 					videoURL = result.Location
 					log.Printf("Stored image-to-video: %s", result.Location)
 
@@ -1633,13 +3165,20 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 						if result.ExpiresAt != nil {
 							expiresAt = result.ExpiresAt.Format(time.RFC3339)
 						}
+					} else {
+						// For local storage: return a resource link to the video file
+						videoContents = s.mediaContent(videoData, result)
 					}
 				}
 			}
+		} else if feedback := safety.FromVideosResponse(operation.Response); feedback != nil && feedback.Blocked {
+			status = "blocked"
+			safetyFeedback = feedback
+			log.Printf("Video generation blocked by safety filters: %s", feedback.Summary())
 		}
 	} else {
 		status = "timeout"
-		log.Printf("Image-to-video generation timed out after 10 minutes")
+		warnings.Add(ctx, "video generation did not finish within 10 minutes")
 	}
 
 	// Create metadata
@@ -1655,12 +3194,18 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 		metadata["seed"] = fmt.Sprintf("%d", input.Seed)
 	}
 
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, VeoGenerationOutput{}, ctx.Err()
+	}
+
 	// Build result based on storage type
 	var result *mcp.CallToolResult
 	if s.storage.IsRemote() && len(downloadURLs) > 0 {
-		contentText := fmt.Sprintf("Image-to-video generated. Download URL:\n%s", downloadURLs[0])
+		contentText := i18n.Sprintf(ctx, "Image-to-video generated. Download URL:\n%s", downloadURLs[0])
 		if expiresAt != "" {
-			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+			contentText += i18n.Sprintf(ctx, "\n\nURL expires at: %s", expiresAt)
 		}
 		result = &mcp.CallToolResult{
 			Content: []mcp.Content{
@@ -1669,19 +3214,30 @@ func (s *Server) handleVeoImageToVideo(ctx context.Context, req *mcp.CallToolReq
 				},
 			},
 		}
+	} else if len(videoContents) > 0 {
+		result = &mcp.CallToolResult{
+			Content: videoContents,
+		}
+	}
+
+	if safetyFeedback != nil {
+		result = safetyBlockedResult(ctx, safetyFeedback)
 	}
 
 	return result, VeoGenerationOutput{
+		GenerationResult: s.generationResult(status, stored, dataURIs, metadata),
+
 		OperationID:     operationID,
-		Status:          status,
 		VideoURL:        videoURL,
 		SavedFiles:      savedFiles,
+		DataURIs:        dataURIs,
+		Thumbnails:      thumbnails,
 		DownloadURLs:    downloadURLs,
 		ExpiresAt:       expiresAt,
 		Model:           model,
 		AspectRatio:     aspectRatio,
 		Resolution:      resolution,
-		Metadata:        metadata,
+		Safety:          safetyFeedback,
 		GeneratedAt:     timestamp,
 		EstimatedLength: "8 seconds",
 	}, nil
@@ -1704,17 +3260,40 @@ func (s *Server) handleHTTPUpload(w http.ResponseWriter, r *http.Request) {
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 	token = strings.TrimSpace(token)
 
-	if !s.tokenManager.Validate(token) {
+	tenant, ok := s.tokenManager.Validate(token)
+	if !ok {
 		http.Error(w, `{"error":"Invalid or expired token. Tokens are one-time use only."}`, http.StatusUnauthorized)
 		return
 	}
 
-	// Parse multipart form (max 100MB)
-	if err := r.ParseMultipartForm(100 << 20); err != nil {
+	// A request declaring Upload-Length starts a resumable upload instead
+	if r.Header.Get("Upload-Length") != "" {
+		s.createResumableUpload(w, r, tenant)
+		return
+	}
+
+	// Refuse files over MAX_UPLOAD_BYTES before reading them, and stop
+	// reading bodies that turn out larger than they declared
+	maxBytes := s.config.MaxUploadBytes
+	if r.ContentLength > int64(maxBytes)+multipartOverhead {
+		writeUploadTooLarge(w, maxBytes)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes)+multipartOverhead)
+
+	// Parse multipart form, keeping up to 32MB in memory and the rest in
+	// temporary files
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeUploadTooLarge(w, maxBytes)
+			return
+		}
 		log.Printf("Failed to parse multipart form: %v", err)
 		http.Error(w, fmt.Sprintf(`{"error":"Failed to parse form: %v"}`, err), http.StatusBadRequest)
 		return
 	}
+	defer r.MultipartForm.RemoveAll()
 
 	// Get file from form
 	file, header, err := r.FormFile("file")
@@ -1724,73 +3303,24 @@ func (s *Server) handleHTTPUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	defer file.Close()
-
-	// Read file data
-	data, err := io.ReadAll(file)
-	if err != nil {
-		log.Printf("Failed to read file: %v", err)
-		http.Error(w, fmt.Sprintf(`{"error":"Failed to read file: %v"}`, err), http.StatusInternalServerError)
+	if header.Size > int64(maxBytes) {
+		writeUploadTooLarge(w, maxBytes)
 		return
 	}
 
-	// Detect MIME type from filename or Content-Type
-	mimeType := header.Header.Get("Content-Type")
-	if mimeType == "" || mimeType == "application/octet-stream" {
-		ext := strings.ToLower(filepath.Ext(header.Filename))
-		switch ext {
-		case ".png":
-			mimeType = "image/png"
-		case ".jpg", ".jpeg":
-			mimeType = "image/jpeg"
-		case ".gif":
-			mimeType = "image/gif"
-		case ".webp":
-			mimeType = "image/webp"
-		case ".mp4":
-			mimeType = "video/mp4"
-		case ".webm":
-			mimeType = "video/webm"
-		case ".mov":
-			mimeType = "video/quicktime"
-		default:
-			mimeType = "application/octet-stream"
-		}
-	}
-
-	log.Printf("Uploading file: %s (%s, %d bytes)", header.Filename, mimeType, len(data))
-
-	// Store via storage interface
-	ctx := r.Context()
-	result, err := s.storage.Store(ctx, data, mimeType, "upload")
+	// Read the file into a buffer of its size, without the regrowth of
+	// io.ReadAll, which would briefly need twice the memory
+	data := make([]byte, header.Size)
+	_, err = io.ReadFull(file, data)
 	if err != nil {
-		log.Printf("Failed to store file: %v", err)
-		http.Error(w, fmt.Sprintf(`{"error":"Failed to store file: %v"}`, err), http.StatusInternalServerError)
+		log.Printf("Failed to read file: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to read file: %v"}`, err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("File uploaded successfully: %s", result.ObjectKey)
-
-	// Build response
-	timestamp := time.Now().Format("20060102_150405")
-	var expiresAt string
-	if result.ExpiresAt != nil {
-		expiresAt = result.ExpiresAt.Format(time.RFC3339)
-	}
-
-	response := map[string]interface{}{
-		"object_key":   result.ObjectKey,
-		"download_url": result.Location,
-		"mime_type":    mimeType,
-		"size":         result.Size,
-		"message":      "Upload successful",
-		"uploaded_at":  timestamp,
-	}
-	if expiresAt != "" {
-		response["expires_at"] = expiresAt
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// Store via storage interface, in the storage of the tenant that requested the token
+	ctx := storage.WithTenant(r.Context(), tenant)
+	s.storeUpload(ctx, w, header.Filename, header.Header.Get("Content-Type"), data, nil)
 }
 
 func (s *Server) handleUploadMedia(ctx context.Context, req *mcp.CallToolRequest, input UploadMediaInput) (*mcp.CallToolResult, UploadMediaOutput, error) {
@@ -1808,8 +3338,13 @@ func (s *Server) handleUploadMedia(ctx context.Context, req *mcp.CallToolRequest
 
 	uploadURL := serverURL + "/upload"
 
+	// An interrupted upload resumes with its ID instead of a new token
+	if input.UploadID != "" {
+		return s.resumeUploadInstructions(ctx, cliPath, uploadURL, input.UploadID)
+	}
+
 	// Generate one-time temporary token (12-hour TTL, consumed on use)
-	tempToken := s.tokenManager.Generate()
+	tempToken := s.tokenManager.Generate(middleware.GetTenant(ctx))
 
 	// Build instructions
 	instructions := fmt.Sprintf(`To upload a local file, use the upload_media CLI tool.
@@ -1821,6 +3356,10 @@ EXAMPLE:
   %s --server "%s" --token "%s" /Users/example/photo.png
 
 NOTE: The token is ONE-TIME USE only. After uploading, the token will be invalidated.
+Files larger than %d bytes are refused.
+The file is sent in chunks, and dropped connections are retried from where they
+stopped. If the CLI still fails, it prints an upload_id; call upload_media with
+that upload_id to get the command that resumes the upload.
 
 The CLI will output JSON with the upload result:
   {
@@ -1835,13 +3374,14 @@ Use the object_key from the output with:
   - gemini_image_edit (input_image_path)
   - gemini_multi_image (input_image_paths)
   - veo_image_to_video (image_path)
-`, cliPath, uploadURL, tempToken, cliPath, uploadURL, tempToken)
+`, cliPath, uploadURL, tempToken, cliPath, uploadURL, tempToken, s.config.MaxUploadBytes)
 
 	output := UploadMediaOutput{
 		Instructions: instructions,
 		CLIPath:      cliPath,
 		Usage:        fmt.Sprintf("%s --server <url> --token <token> <file_path>", cliPath),
 		Example:      fmt.Sprintf("%s --server \"%s\" --token \"%s\" /Users/example/photo.png", cliPath, uploadURL, tempToken),
+		MaxBytes:     int64(s.config.MaxUploadBytes),
 	}
 
 	return &mcp.CallToolResult{
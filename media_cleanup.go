@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Media cleanup
+type DeleteMediaInput struct {
+	ObjectKey string `json:"object_key" jsonschema:"description:Object key of the file to delete, as returned in saved_files by another tool or by upload_media"`
+}
+
+type DeleteMediaOutput struct {
+	ObjectKey string     `json:"object_key"`
+	Deleted   bool       `json:"deleted"`
+	Trashed   bool       `json:"trashed"`            // Moved to the trash and restorable with restore_media
+	PurgeAt   *time.Time `json:"purge_at,omitempty"` // When the trashed object is deleted for good
+}
+
+type PurgeMediaInput struct {
+	Prefix    string `json:"prefix,omitempty" jsonschema:"description:Only purge objects whose key starts with this prefix. For local storage this is the filename prefix (e.g. 'gemini_image'); for S3 it is the key prefix (e.g. '2024/12/23/' or '2024/12/23/veo_video')."`
+	OlderThan string `json:"older_than,omitempty" jsonschema:"description:Only purge objects last modified longer ago than this duration (e.g. '24h', '30m', '168h')"`
+	DryRun    bool   `json:"dry_run,omitempty" jsonschema:"description:List the objects that would be deleted without deleting them,default:false"`
+}
+
+type PurgeMediaOutput struct {
+	Matched    []string `json:"matched"`
+	Deleted    []string `json:"deleted,omitempty"`
+	Failed     []string `json:"failed,omitempty"`
+	TotalBytes int64    `json:"total_bytes"`
+	DryRun     bool     `json:"dry_run"`
+	Trashed    bool     `json:"trashed"` // Deleted objects were moved to the trash
+}
+
+type RestoreMediaInput struct {
+	ObjectKey string `json:"object_key,omitempty" jsonschema:"description:Original object key of a file removed by delete_media or purge_media. Omit to list the files in the trash."`
+}
+
+type RestoreMediaOutput struct {
+	Restored string                  `json:"restored,omitempty"`
+	Trash    []storage.TrashedObject `json:"trash,omitempty"`
+}
+
+func (s *Server) handleDeleteMedia(ctx context.Context, req *mcp.CallToolRequest, input DeleteMediaInput) (*mcp.CallToolResult, DeleteMediaOutput, error) {
+	if err := storage.ValidateObjectKey(input.ObjectKey); err != nil {
+		return nil, DeleteMediaOutput{}, err
+	}
+
+	if err := storage.CheckTenantKey(ctx, input.ObjectKey); err != nil {
+		return nil, DeleteMediaOutput{}, err
+	}
+
+	if err := s.trash.Delete(ctx, input.ObjectKey); err != nil {
+		return nil, DeleteMediaOutput{}, fmt.Errorf("failed to delete %s: %w", input.ObjectKey, err)
+	}
+	output := DeleteMediaOutput{ObjectKey: input.ObjectKey, Deleted: true}
+	contentText := fmt.Sprintf("Deleted %s", input.ObjectKey)
+	if s.trash.Enabled() {
+		output.Trashed = true
+		output.PurgeAt = genai.Ptr(time.Now().Add(s.trash.Retention()))
+		contentText += fmt.Sprintf(" (moved to the trash; restore_media can bring it back within %v)", s.trash.Retention())
+		log.Printf("Moved media to the trash: %s", input.ObjectKey)
+	} else {
+		log.Printf("Deleted media: %s", input.ObjectKey)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: contentText,
+			},
+		},
+	}, output, nil
+}
+
+func (s *Server) handlePurgeMedia(ctx context.Context, req *mcp.CallToolRequest, input PurgeMediaInput) (*mcp.CallToolResult, PurgeMediaOutput, error) {
+	if input.Prefix == "" && input.OlderThan == "" {
+		return nil, PurgeMediaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "at least one of prefix or older_than is required")
+	}
+
+	var cutoff time.Time
+	if input.OlderThan != "" {
+		age, err := time.ParseDuration(input.OlderThan)
+		if err != nil || age <= 0 {
+			return nil, PurgeMediaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid older_than %q: expected a positive duration such as '24h'", input.OlderThan)
+		}
+		cutoff = time.Now().Add(-age)
+	}
+
+	objects, err := s.storage.List(ctx, input.Prefix)
+	if err != nil {
+		return nil, PurgeMediaOutput{}, fmt.Errorf("failed to list media: %w", err)
+	}
+
+	output := PurgeMediaOutput{Matched: []string{}, DryRun: input.DryRun, Trashed: s.trash.Enabled()}
+	for _, object := range objects {
+		// The trash purges itself
+		if strings.HasPrefix(object.ObjectKey, storage.TrashPrefix) {
+			continue
+		}
+		if !cutoff.IsZero() && !object.LastModified.Before(cutoff) {
+			continue
+		}
+		output.Matched = append(output.Matched, object.ObjectKey)
+		output.TotalBytes += object.Size
+
+		if input.DryRun {
+			continue
+		}
+		if err := s.trash.Delete(ctx, object.ObjectKey); err != nil {
+			log.Printf("Failed to purge %s: %v", object.ObjectKey, err)
+			output.Failed = append(output.Failed, object.ObjectKey)
+			continue
+		}
+		output.Deleted = append(output.Deleted, object.ObjectKey)
+	}
+
+	var contentText string
+	if input.DryRun {
+		contentText = fmt.Sprintf("Dry run: %d object(s) (%d bytes) would be deleted", len(output.Matched), output.TotalBytes)
+	} else {
+		log.Printf("Purged %d media object(s) (prefix: %q, older_than: %q)", len(output.Deleted), input.Prefix, input.OlderThan)
+		contentText = fmt.Sprintf("Deleted %d of %d matching object(s) (%d bytes)", len(output.Deleted), len(output.Matched), output.TotalBytes)
+		if len(output.Failed) > 0 {
+			contentText += fmt.Sprintf("; %d failed", len(output.Failed))
+		}
+		if output.Trashed {
+			contentText += fmt.Sprintf("; deleted objects stay restorable with restore_media for %v", s.trash.Retention())
+		}
+	}
+	for _, key := range output.Matched {
+		contentText += "\n- " + key
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: contentText,
+			},
+		},
+	}, output, nil
+}
+
+func (s *Server) handleRestoreMedia(ctx context.Context, req *mcp.CallToolRequest, input RestoreMediaInput) (*mcp.CallToolResult, RestoreMediaOutput, error) {
+	if !s.trash.Enabled() {
+		return nil, RestoreMediaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "the trash is disabled (TRASH_RETENTION=0); deleted media cannot be restored")
+	}
+
+	if input.ObjectKey == "" {
+		trashed, err := s.trash.List(ctx)
+		if err != nil {
+			return nil, RestoreMediaOutput{}, fmt.Errorf("failed to list the trash: %w", err)
+		}
+		output := RestoreMediaOutput{Trash: []storage.TrashedObject{}}
+		for _, object := range trashed {
+			if storage.CheckTenantKey(ctx, object.ObjectKey) == nil {
+				output.Trash = append(output.Trash, object)
+			}
+		}
+		contentText := fmt.Sprintf("%d object(s) in the trash", len(output.Trash))
+		for _, object := range output.Trash {
+			contentText += fmt.Sprintf("\n- %s (deleted %s, purged after %s)", object.ObjectKey,
+				object.DeletedAt.UTC().Format(time.RFC3339), object.PurgeAt.UTC().Format(time.RFC3339))
+		}
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}, output, nil
+	}
+
+	if err := storage.CheckTenantKey(ctx, input.ObjectKey); err != nil {
+		return nil, RestoreMediaOutput{}, err
+	}
+	if err := s.trash.Restore(ctx, input.ObjectKey); err != nil {
+		return nil, RestoreMediaOutput{}, fmt.Errorf("failed to restore %s: %w", input.ObjectKey, err)
+	}
+	log.Printf("Restored media from the trash: %s", input.ObjectKey)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("Restored %s", input.ObjectKey),
+			},
+		},
+	}, RestoreMediaOutput{Restored: input.ObjectKey}, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/audio"
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/video"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Media info
+type GetMediaInfoInput struct {
+	ObjectKey string `json:"object_key" jsonschema:"description:Object key of the file, as returned in saved_files by another tool or by upload_media"`
+	LinkTTL   string `json:"link_ttl,omitempty" jsonschema:"description:How long the returned download URL stays valid (e.g. '5m', '12h', '7d'; between 1m and 7d),default:24h"`
+}
+
+type GetMediaInfoOutput struct {
+	ObjectKey       string  `json:"object_key"`
+	MIMEType        string  `json:"mime_type"`
+	Size            int64   `json:"size"`
+	ContentHash     string  `json:"content_hash"` // SHA-256 of the content, hex-encoded
+	Width           int     `json:"width,omitempty"`
+	Height          int     `json:"height,omitempty"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"`
+	CreatedAt       string  `json:"created_at"`
+	ExpiresAt       string  `json:"expires_at,omitempty"` // When the file is deleted for its age; empty if it is kept
+	DownloadURL     string  `json:"download_url,omitempty"`
+	URLExpiresAt    string  `json:"url_expires_at,omitempty"`
+	LocalPath       string  `json:"local_path,omitempty"` // Local storage in stdio mode, which serves no URLs
+}
+
+func (s *Server) handleGetMediaInfo(ctx context.Context, req *mcp.CallToolRequest, input GetMediaInfoInput) (*mcp.CallToolResult, GetMediaInfoOutput, error) {
+	if err := storage.ValidateObjectKey(input.ObjectKey); err != nil {
+		return nil, GetMediaInfoOutput{}, err
+	}
+	if err := storage.CheckTenantKey(ctx, input.ObjectKey); err != nil {
+		return nil, GetMediaInfoOutput{}, err
+	}
+	ttl := 24 * time.Hour
+	if input.LinkTTL != "" {
+		var err error
+		if ttl, err = storage.ParseLinkTTL(input.LinkTTL); err != nil {
+			return nil, GetMediaInfoOutput{}, toolerr.Errorf(toolerr.InvalidInput, "link_ttl: %w", err)
+		}
+	}
+
+	object, err := s.findObject(ctx, input.ObjectKey)
+	if err != nil {
+		return nil, GetMediaInfoOutput{}, err
+	}
+	localPath, cleanup, err := s.storage.Retrieve(ctx, input.ObjectKey)
+	if err != nil {
+		return nil, GetMediaInfoOutput{}, fmt.Errorf("failed to read %s: %w", input.ObjectKey, err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	output, err := describeMedia(input.ObjectKey, localPath)
+	if err != nil {
+		return nil, GetMediaInfoOutput{}, toolerr.Wrap(toolerr.StorageError, err)
+	}
+	output.CreatedAt = object.LastModified.UTC().Format(time.RFC3339)
+	if object.ExpiresAt != nil {
+		output.ExpiresAt = object.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	if s.storage.IsRemote() {
+		url, expiresAt, err := s.storage.ShareLink(ctx, input.ObjectKey, ttl)
+		if err != nil {
+			return nil, GetMediaInfoOutput{}, fmt.Errorf("failed to create download URL for %s: %w", input.ObjectKey, err)
+		}
+		output.DownloadURL = url
+		output.URLExpiresAt = expiresAt.Format(time.RFC3339)
+	} else {
+		output.LocalPath = localPath
+	}
+	log.Printf("Described %s (%s, %d bytes)", input.ObjectKey, output.MIMEType, output.Size)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{Text: formatMediaInfo(output)},
+		},
+	}, output, nil
+}
+
+// findObject returns the listing of objectKey
+func (s *Server) findObject(ctx context.Context, objectKey string) (storage.ObjectInfo, error) {
+	objects, err := s.storage.List(ctx, objectKey)
+	if err != nil {
+		return storage.ObjectInfo{}, fmt.Errorf("failed to look up %s: %w", objectKey, err)
+	}
+	for _, object := range objects {
+		if object.ObjectKey == objectKey {
+			return object, nil
+		}
+	}
+	return storage.ObjectInfo{}, toolerr.Errorf(toolerr.InvalidInput, "no stored file has the object key %s", objectKey)
+}
+
+// describeMedia reads the type, size, hash and, for images, videos and WAV
+// audio, the dimensions and duration of the file at localPath
+func describeMedia(objectKey, localPath string) (GetMediaInfoOutput, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return GetMediaInfoOutput{}, err
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return GetMediaInfoOutput{}, fmt.Errorf("failed to read %s: %w", objectKey, err)
+	}
+	head := make([]byte, 512)
+	n, _ := f.ReadAt(head, 0)
+	output := GetMediaInfoOutput{
+		ObjectKey:   objectKey,
+		MIMEType:    mediaMIME(objectKey, head[:n]),
+		Size:        size,
+		ContentHash: hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	switch {
+	case strings.HasPrefix(output.MIMEType, "image/"):
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return output, nil
+		}
+		if config, _, err := image.DecodeConfig(f); err == nil {
+			output.Width, output.Height = config.Width, config.Height
+		}
+	case strings.HasPrefix(output.MIMEType, "video/"):
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return output, nil
+		}
+		if info, err := video.Probe(data); err == nil {
+			output.Width, output.Height = info.Width, info.Height
+			output.DurationSeconds = info.Duration.Seconds()
+		}
+	case output.MIMEType == "audio/wav":
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return output, nil
+		}
+		if pcm, err := audio.DecodeWAV(data); err == nil && pcm.SampleRate > 0 {
+			output.DurationSeconds = float64(len(pcm.Data)/2) / float64(pcm.SampleRate)
+		}
+	}
+	return output, nil
+}
+
+// mediaMIME returns the MIME type of a stored file: by extension for video
+// and audio, which sniffing does not tell apart reliably, and otherwise from
+// its leading bytes, falling back to the extension for text formats
+func mediaMIME(objectKey string, head []byte) string {
+	if mimeType := videoMIMEFromPath(objectKey); mimeType != "" {
+		return mimeType
+	}
+	if mimeType := audioMIMEFromPath(objectKey); mimeType != "" {
+		return mimeType
+	}
+	sniffed := imaging.DetectMIME(head)
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/") {
+		if byExt := mime.TypeByExtension(path.Ext(objectKey)); byExt != "" {
+			return byExt
+		}
+	}
+	return sniffed
+}
+
+// formatMediaInfo renders the info as text
+func formatMediaInfo(info GetMediaInfoOutput) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\nType: %s\nSize: %d bytes\nSHA-256: %s\n", info.ObjectKey, info.MIMEType, info.Size, info.ContentHash)
+	if info.Width > 0 {
+		fmt.Fprintf(&b, "Dimensions: %dx%d\n", info.Width, info.Height)
+	}
+	if info.DurationSeconds > 0 {
+		fmt.Fprintf(&b, "Duration: %.2fs\n", info.DurationSeconds)
+	}
+	fmt.Fprintf(&b, "Created at: %s\n", info.CreatedAt)
+	if info.ExpiresAt != "" {
+		fmt.Fprintf(&b, "Deleted at: %s\n", info.ExpiresAt)
+	}
+	if info.DownloadURL != "" {
+		fmt.Fprintf(&b, "\nDownload URL: %s\nURL expires at: %s", info.DownloadURL, info.URLExpiresAt)
+	} else {
+		fmt.Fprintf(&b, "\nLocal path: %s", info.LocalPath)
+	}
+	return b.String()
+}
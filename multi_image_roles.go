@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+// maxMultiImageInputs returns how many reference images a model accepts in
+// one gemini_multi_image request
+func maxMultiImageInputs(model string) int {
+	if strings.HasPrefix(model, "gemini-3") {
+		return 14
+	}
+	return 3
+}
+
+// multiImageInput is one reference image and the role it plays in the composition
+type multiImageInput struct {
+	Path string
+	Role string
+}
+
+// roleRank orders reference images so the subject is sent first and style
+// references last; unrecognised roles keep their place in between
+func roleRank(role string) int {
+	role = strings.ToLower(role)
+	switch {
+	case strings.Contains(role, "subject"), strings.Contains(role, "character"), strings.Contains(role, "product"):
+		return 0
+	case strings.Contains(role, "background"), strings.Contains(role, "scene"), strings.Contains(role, "setting"):
+		return 2
+	case strings.Contains(role, "style"):
+		return 3
+	default:
+		return 1
+	}
+}
+
+// orderMultiImageInputs pairs paths with roles and sorts them by role rank,
+// keeping the caller's order within each rank. roles may be empty; otherwise it
+// must have one entry per path (empty entries mean no particular role).
+func orderMultiImageInputs(paths, roles []string) ([]multiImageInput, error) {
+	if len(roles) > 0 && len(roles) != len(paths) {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "image_roles must have one entry per input image (got %d roles for %d images)", len(roles), len(paths))
+	}
+
+	inputs := make([]multiImageInput, len(paths))
+	for i, path := range paths {
+		inputs[i].Path = path
+		if len(roles) > 0 {
+			inputs[i].Role = strings.TrimSpace(roles[i])
+		}
+	}
+	if len(roles) > 0 {
+		sort.SliceStable(inputs, func(i, j int) bool {
+			return roleRank(inputs[i].Role) < roleRank(inputs[j].Role)
+		})
+	}
+	return inputs, nil
+}
+
+// describeImageRoles explains to the model which numbered image plays which role
+func describeImageRoles(inputs []multiImageInput) string {
+	var roles []string
+	for i, in := range inputs {
+		if in.Role != "" {
+			roles = append(roles, fmt.Sprintf("Image %d is the %s", i+1, in.Role))
+		}
+	}
+	return strings.Join(roles, ". ")
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/audio"
+	"gemini-mcp/internal/music"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// defaultMusicSeconds and maxMusicSeconds bound the length of a track
+	defaultMusicSeconds = 30
+	maxMusicSeconds     = 120
+)
+
+// Music generation
+type GeminiMusicGenerationInput struct {
+	Prompt           string   `json:"prompt" jsonschema:"description:Description of the music, e.g. 'warm lo-fi hip hop with vinyl crackle and soft piano'. Name instruments, genres and moods rather than artists: prompts naming artists are refused."`
+	Genre            string   `json:"genre,omitempty" jsonschema:"description:Optional genre, e.g. 'synthwave' or 'bossa nova'. Sent as a further prompt with the same weight as prompt."`
+	Mood             string   `json:"mood,omitempty" jsonschema:"description:Optional mood, e.g. 'uplifting' or 'tense'. Sent as a further prompt with the same weight as prompt."`
+	DurationSeconds  int      `json:"duration_seconds,omitempty" jsonschema:"description:Length of the track in seconds (5-120). Music is streamed in real time, so the call takes about as long as the track.,default:30"`
+	BPM              int      `json:"bpm,omitempty" jsonschema:"description:Optional tempo in beats per minute (60-200). Chosen by the model when omitted."`
+	Density          *float64 `json:"density,omitempty" jsonschema:"description:Optional density of notes and sounds, from 0 (sparse) to 1 (busy)"`
+	Brightness       *float64 `json:"brightness,omitempty" jsonschema:"description:Optional tonal brightness, from 0 (dark) to 1 (bright)"`
+	Temperature      *float64 `json:"temperature,omitempty" jsonschema:"description:Optional randomness of the music, from 0 to 3,default:1.1"`
+	Model            string   `json:"model,omitempty" jsonschema:"description:Lyria model used for generation,default:lyria-realtime-exp"`
+	WebhookURL       string   `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL          string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename         string   `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage string   `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+}
+
+type GeminiMusicGenerationOutput struct {
+	GenerationResult
+
+	Model           string  `json:"model"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	SampleRate      int     `json:"sample_rate"`
+	Channels        int     `json:"channels"`
+	GeneratedAt     string  `json:"generated_at"`
+}
+
+// Music generation returns its file in the envelope only
+func (o *GeminiMusicGenerationOutput) clearLegacyFields() {}
+
+func (s *Server) handleGeminiMusicGeneration(ctx context.Context, req *mcp.CallToolRequest, input GeminiMusicGenerationInput) (*mcp.CallToolResult, GeminiMusicGenerationOutput, error) {
+	if strings.TrimSpace(input.Prompt) == "" {
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "prompt is required")
+	}
+	seconds := input.DurationSeconds
+	if seconds == 0 {
+		seconds = defaultMusicSeconds
+	}
+	if seconds < 5 || seconds > maxMusicSeconds {
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "duration_seconds must be between 5 and %d", maxMusicSeconds)
+	}
+	if input.BPM != 0 && (input.BPM < 60 || input.BPM > 200) {
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "bpm must be between 60 and 200")
+	}
+	for name, value := range map[string]*float64{"density": input.Density, "brightness": input.Brightness} {
+		if value != nil && (*value < 0 || *value > 1) {
+			return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "%s must be between 0 and 1", name)
+		}
+	}
+	if input.Temperature != nil && (*input.Temperature < 0 || *input.Temperature > 3) {
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "temperature must be between 0 and 3")
+	}
+
+	model := strings.TrimPrefix(input.Model, "models/")
+	if model == "" {
+		model = music.DefaultModel
+	}
+	if err := s.allowlist.Check("gemini_music_generation", model); err != nil {
+		return nil, GeminiMusicGenerationOutput{}, err
+	}
+
+	prompts := []music.WeightedPrompt{{Text: input.Prompt, Weight: 1}}
+	for _, extra := range []string{input.Genre, input.Mood} {
+		if extra = strings.TrimSpace(extra); extra != "" {
+			prompts = append(prompts, music.WeightedPrompt{Text: extra, Weight: 1})
+		}
+	}
+
+	// Tracks stream for up to two minutes, so they wait for a video
+	// generation slot and count as a video against the caller's quota
+	release, err := s.acquireGeneration(ctx, quota.Videos)
+	if err != nil {
+		return nil, GeminiMusicGenerationOutput{}, err
+	}
+	defer release()
+
+	log.Printf("Generating %ds of music with model %s, prompt: %s", seconds, model, input.Prompt)
+	timestamp := time.Now().Format("20060102_150405")
+
+	// Music streams at about real time; allow for a slow start
+	duration := time.Duration(seconds) * time.Second
+	genCtx, cancel := context.WithTimeout(ctx, 2*duration+time.Minute)
+	defer cancel()
+	track, err := s.music.Generate(genCtx, music.Request{
+		Model:   model,
+		Prompts: prompts,
+		Config: music.Config{
+			BPM:         input.BPM,
+			Temperature: input.Temperature,
+			Density:     input.Density,
+			Brightness:  input.Brightness,
+		},
+		Duration: duration,
+	})
+	var filtered *music.FilteredError
+	switch {
+	case errors.As(err, &filtered):
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Wrap(toolerr.ModelBlocked, err)
+	case err != nil && ctx.Err() == nil && genCtx.Err() != nil:
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Errorf(toolerr.Timeout, "music generation did not finish within %v", 2*duration+time.Minute)
+	case err != nil:
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Wrap(toolerr.Upstream, fmt.Errorf("error generating music: %w", err))
+	}
+	for _, warning := range track.Warnings {
+		warnings.Add(ctx, "Lyria: %s", warning)
+	}
+
+	pcm := &audio.PCM{Data: track.Data, SampleRate: track.SampleRate, Channels: track.Channels}
+	wav := audio.EncodeWAV(pcm)
+	result, err := s.storage.Store(ctx, wav, "audio/wav", "gemini_music")
+	if err != nil {
+		return nil, GeminiMusicGenerationOutput{}, toolerr.Wrap(toolerr.StorageError, fmt.Errorf("failed to store music: %w", err))
+	}
+	log.Printf("Stored music: %s (%.1fs)", result.Location, pcm.Duration())
+
+	metadata := map[string]string{
+		"original_prompt": input.Prompt,
+	}
+	if input.Genre != "" {
+		metadata["genre"] = input.Genre
+	}
+	if input.Mood != "" {
+		metadata["mood"] = input.Mood
+	}
+	if input.BPM != 0 {
+		metadata["bpm"] = fmt.Sprintf("%d", input.BPM)
+	}
+
+	output := GeminiMusicGenerationOutput{
+		GenerationResult: s.generationResult("completed", []*storage.StorageResult{result}, s.addDataURI(nil, result, wav), metadata),
+		Model:            model,
+		DurationSeconds:  pcm.Duration(),
+		SampleRate:       pcm.SampleRate,
+		Channels:         max(pcm.Channels, 1),
+		GeneratedAt:      timestamp,
+	}
+
+	var content []mcp.Content
+	if s.storage.IsRemote() {
+		text := fmt.Sprintf("Generated %.0fs of music. Download URL:\n%s", output.DurationSeconds, result.Location)
+		if result.ExpiresAt != nil {
+			text += fmt.Sprintf("\n\nURL expires at: %s", result.ExpiresAt.Format(time.RFC3339))
+		}
+		content = []mcp.Content{&mcp.TextContent{Text: text}}
+	} else {
+		content = append([]mcp.Content{&mcp.TextContent{Text: fmt.Sprintf("Generated %.0fs of music: %s", output.DurationSeconds, result.ObjectKey)}},
+			s.mediaContent(wav, result)...)
+	}
+	return &mcp.CallToolResult{Content: content}, output, nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Object detection
+type GeminiObjectDetectionInput struct {
+	ImagePath     string   `json:"image_path" jsonschema:"description:Path to the image to analyze. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Objects       []string `json:"objects,omitempty" jsonschema:"description:Optional kinds of objects to look for (e.g. ['person', 'coffee mug', 'logo']). Defaults to all prominent objects."`
+	MaxObjects    int      `json:"max_objects,omitempty" jsonschema:"description:Maximum number of objects to return (1-100),default:25"`
+	MinConfidence float64  `json:"min_confidence,omitempty" jsonschema:"description:Drop detections whose confidence is below this value (0-1),default:0"`
+	Annotate      bool     `json:"annotate,omitempty" jsonschema:"description:Also store a copy of the image with the bounding boxes drawn on it,default:false"`
+	Model         string   `json:"model,omitempty" jsonschema:"description:Gemini model used for detection,default:gemini-2.5-flash"`
+	LinkTTL       string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+// DetectedObject is one detection. Box is normalized to 0-1 with the origin at
+// the top-left corner; PixelBox is the same box in pixels of the source image.
+type DetectedObject struct {
+	Label      string      `json:"label"`
+	Confidence float64     `json:"confidence"`
+	Box        imaging.Box `json:"box"`
+	PixelBox   PixelBox    `json:"pixel_box"`
+}
+
+type PixelBox struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type GeminiObjectDetectionOutput struct {
+	SourceImage   string            `json:"source_image"`
+	Model         string            `json:"model"`
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Objects       []DetectedObject  `json:"objects"`
+	AnnotatedFile string            `json:"annotated_file,omitempty"`
+	SavedFiles    []string          `json:"saved_files,omitempty"`
+	DataURIs      map[string]string `json:"data_uris,omitempty"`
+	Thumbnails    map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs  []string          `json:"download_urls,omitempty"`
+	ExpiresAt     string            `json:"expires_at,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Tokens        *usage.Tokens     `json:"tokens,omitempty"` // Gemini tokens used by the detection
+	GeneratedAt   string            `json:"generated_at"`
+}
+
+// detectionSchema is the structured response requested from Gemini
+var detectionSchema = &genai.Schema{
+	Type: genai.TypeArray,
+	Items: &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"label":      {Type: genai.TypeString},
+			"box_2d":     {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeNumber}},
+			"confidence": {Type: genai.TypeNumber},
+		},
+		Required: []string{"label", "box_2d", "confidence"},
+	},
+}
+
+func (s *Server) handleGeminiObjectDetection(ctx context.Context, req *mcp.CallToolRequest, input GeminiObjectDetectionInput) (*mcp.CallToolResult, GeminiObjectDetectionOutput, error) {
+	ctx, used := usage.WithCollector(ctx)
+	if input.ImagePath == "" {
+		return nil, GeminiObjectDetectionOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+
+	// Set defaults
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_object_detection", model); err != nil {
+		return nil, GeminiObjectDetectionOutput{}, err
+	}
+
+	maxObjects := input.MaxObjects
+	if maxObjects == 0 {
+		maxObjects = 25
+	}
+	if maxObjects < 1 || maxObjects > 100 {
+		return nil, GeminiObjectDetectionOutput{}, toolerr.Errorf(toolerr.InvalidInput, "max_objects must be between 1 and 100")
+	}
+	if input.MinConfidence < 0 || input.MinConfidence > 1 {
+		return nil, GeminiObjectDetectionOutput{}, toolerr.Errorf(toolerr.InvalidInput, "min_confidence must be between 0 and 1")
+	}
+
+	log.Printf("Detecting objects in %s with model %s", input.ImagePath, model)
+
+	// Resolve input image path (may download from S3)
+	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+	if err != nil {
+		return nil, GeminiObjectDetectionOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	imgData, err := os.ReadFile(localImagePath)
+	if err != nil {
+		return nil, GeminiObjectDetectionOutput{}, fmt.Errorf("failed to read input image: %w", err)
+	}
+
+	imgMIMEType, err := imaging.DetectInputMIME(imgData)
+	if err != nil {
+		return nil, GeminiObjectDetectionOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid input image: %w", err)
+	}
+
+	source, _, err := imaging.Decode(imgData)
+	if err != nil {
+		return nil, GeminiObjectDetectionOutput{}, err
+	}
+	width, height := source.Bounds().Dx(), source.Bounds().Dy()
+
+	prompt := fmt.Sprintf("Detect the prominent objects in this image, at most %d.", maxObjects)
+	if len(input.Objects) > 0 {
+		prompt = fmt.Sprintf("Detect every instance of the following in this image, at most %d in total: %s.", maxObjects, strings.Join(input.Objects, ", "))
+	}
+	prompt += " For each object return a short descriptive label, its bounding box as box_2d [ymin, xmin, ymax, xmax] normalized to 0-1000, and your confidence from 0 to 1 that the detection is correct. Give objects of the same kind distinguishing labels when they differ (e.g. 'red car', 'blue car')."
+
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			{InlineData: &genai.Blob{MIMEType: imgMIMEType, Data: imgData}},
+			genai.NewPartFromText(prompt),
+		}, genai.RoleUser),
+	}
+	config := &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   detectionSchema,
+	}
+	if strings.Contains(model, "flash") {
+		// Detection is more precise without thinking; only Flash models can turn it off
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](0)}
+	}
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, GeminiObjectDetectionOutput{}, fmt.Errorf("error detecting objects: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return nil, GeminiObjectDetectionOutput{}, toolerr.Errorf(toolerr.Upstream, "no detections were returned")
+	}
+
+	var raw []struct {
+		Label      string    `json:"label"`
+		Box2D      []float64 `json:"box_2d"`
+		Confidence float64   `json:"confidence"`
+	}
+	if err := json.Unmarshal([]byte(response.Text()), &raw); err != nil {
+		return nil, GeminiObjectDetectionOutput{}, fmt.Errorf("failed to parse detections: %w", err)
+	}
+
+	objects := []DetectedObject{}
+	for _, d := range raw {
+		box, ok := imaging.BoxFromGemini(d.Box2D)
+		if !ok || d.Confidence < input.MinConfidence {
+			continue
+		}
+		px := box.Pixels(width, height)
+		objects = append(objects, DetectedObject{
+			Label:      d.Label,
+			Confidence: d.Confidence,
+			Box:        box,
+			PixelBox:   PixelBox{X: px.Min.X, Y: px.Min.Y, Width: px.Dx(), Height: px.Dy()},
+		})
+	}
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].Confidence > objects[j].Confidence })
+	if len(objects) > maxObjects {
+		objects = objects[:maxObjects]
+	}
+
+	output := GeminiObjectDetectionOutput{
+		SourceImage: input.ImagePath,
+		Model:       model,
+		Width:       width,
+		Height:      height,
+		Objects:     objects,
+		Metadata: map[string]string{
+			"source_image":   input.ImagePath,
+			"detections":     fmt.Sprintf("%d", len(objects)),
+			"min_confidence": fmt.Sprintf("%.2f", input.MinConfidence),
+		},
+		Tokens:      used.Tokens(),
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	if len(input.Objects) > 0 {
+		output.Metadata["objects"] = strings.Join(input.Objects, ", ")
+	}
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Detected %d object(s) in %dx%d image", len(objects), width, height)
+	for i, o := range objects {
+		fmt.Fprintf(&summary, "\n%d. %s (%.2f) at x=%d y=%d w=%d h=%d", i+1, o.Label, o.Confidence, o.PixelBox.X, o.PixelBox.Y, o.PixelBox.Width, o.PixelBox.Height)
+	}
+	annotatedContents := []mcp.Content{}
+
+	if input.Annotate && len(objects) > 0 {
+		boxes := make([]imaging.Box, len(objects))
+		groups := make([]int, len(objects))
+		labelGroup := map[string]int{}
+		for i, o := range objects {
+			boxes[i] = o.Box
+			if _, ok := labelGroup[o.Label]; !ok {
+				labelGroup[o.Label] = len(labelGroup)
+			}
+			groups[i] = labelGroup[o.Label]
+		}
+
+		annotated, err := imaging.EncodePNG(imaging.DrawBoxes(source, boxes, groups))
+		if err != nil {
+			return nil, GeminiObjectDetectionOutput{}, err
+		}
+		result, err := s.storage.Store(ctx, annotated, "image/png", "detection")
+		if err != nil {
+			return nil, GeminiObjectDetectionOutput{}, fmt.Errorf("failed to store annotated image: %w", err)
+		}
+		log.Printf("Stored annotated image: %s", result.Location)
+
+		output.AnnotatedFile = result.ObjectKey
+		output.SavedFiles = []string{result.ObjectKey}
+		output.DataURIs = s.addDataURI(nil, result, annotated)
+		output.Thumbnails = addThumbnail(nil, result)
+		if s.storage.IsRemote() {
+			output.DownloadURLs = []string{result.Location}
+			if result.ExpiresAt != nil {
+				output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(&summary, "\n\nAnnotated image: %s", result.Location)
+			if output.ExpiresAt != "" {
+				fmt.Fprintf(&summary, "\nURL expires at: %s", output.ExpiresAt)
+			}
+		} else {
+			annotatedContents = s.mediaContent(annotated, result)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: summary.String()}}, annotatedContents...),
+	}, output, nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// OCR
+type GeminiOCRInput struct {
+	ImagePath string   `json:"image_path" jsonschema:"description:Path to the image or PDF to read. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Page      int      `json:"page,omitempty" jsonschema:"description:Page of a PDF to read, counted from 1 (ignored for images),default:1"`
+	Layout    bool     `json:"layout,omitempty" jsonschema:"description:Also return the text as blocks (headings, paragraphs, table cells, ...) with their bounding boxes and languages,default:false"`
+	Languages []string `json:"languages,omitempty" jsonschema:"description:Optional languages the text is expected to be in (e.g. ['en', 'Japanese']). Detected automatically when omitted."`
+	Model     string   `json:"model,omitempty" jsonschema:"description:Gemini model used for text extraction,default:gemini-2.5-flash"`
+}
+
+// OCRBlock is one block of extracted text. Box is normalized to 0-1 with the
+// origin at the top-left corner of the image or page; PixelBox is the same
+// box in pixels of a source image.
+type OCRBlock struct {
+	Text     string       `json:"text"`
+	Type     string       `json:"type"`
+	Language string       `json:"language,omitempty"`
+	Box      *imaging.Box `json:"box,omitempty"`
+	PixelBox *PixelBox    `json:"pixel_box,omitempty"`
+}
+
+type GeminiOCROutput struct {
+	SourceImage string        `json:"source_image"`
+	Model       string        `json:"model"`
+	Page        int           `json:"page,omitempty"`
+	Width       int           `json:"width,omitempty"`
+	Height      int           `json:"height,omitempty"`
+	Text        string        `json:"text"`
+	Languages   []string      `json:"languages"`
+	Blocks      []OCRBlock    `json:"blocks,omitempty"`
+	Warnings    []string      `json:"warnings,omitempty"`
+	Tokens      *usage.Tokens `json:"tokens,omitempty"` // Gemini tokens used by the extraction
+	GeneratedAt string        `json:"generated_at"`
+}
+
+// ocrBlockTypes are the kinds of text blocks Gemini is asked to classify
+var ocrBlockTypes = []string{"title", "heading", "paragraph", "list_item", "table_cell", "caption", "label", "handwriting", "other"}
+
+func (s *Server) handleGeminiOCR(ctx context.Context, req *mcp.CallToolRequest, input GeminiOCRInput) (*mcp.CallToolResult, GeminiOCROutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	ctx, used := usage.WithCollector(ctx)
+	if input.ImagePath == "" {
+		return nil, GeminiOCROutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+	if input.Page < 0 {
+		return nil, GeminiOCROutput{}, toolerr.Errorf(toolerr.InvalidInput, "page must be at least 1")
+	}
+
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_ocr", model); err != nil {
+		return nil, GeminiOCROutput{}, err
+	}
+
+	log.Printf("Extracting text from %s with model %s", input.ImagePath, model)
+
+	// Resolve input path (may download from S3)
+	localPath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+	if err != nil {
+		return nil, GeminiOCROutput{}, fmt.Errorf("failed to resolve input file: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, GeminiOCROutput{}, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	output := GeminiOCROutput{
+		SourceImage: input.ImagePath,
+		Model:       model,
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+
+	mimeType := imaging.DetectMIME(data)
+	isPDF := mimeType == "application/pdf"
+	if isPDF {
+		output.Page = max(input.Page, 1)
+	} else {
+		if mimeType, err = imaging.DetectInputMIME(data); err != nil {
+			return nil, GeminiOCROutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid input file: %w (PDFs are supported too)", err)
+		}
+		if input.Page > 1 {
+			warnings.Add(ctx, "page is ignored for images")
+		}
+		if input.Layout {
+			// Pixel boxes need the image size
+			source, _, err := imaging.Decode(data)
+			if err != nil {
+				warnings.Add(ctx, "failed to decode the image, returning normalized boxes only: %v", err)
+			} else {
+				output.Width, output.Height = source.Bounds().Dx(), source.Bounds().Dy()
+			}
+		}
+	}
+
+	prompt, config := ocrPrompt(input, output.Page)
+	if strings.Contains(model, "flash") {
+		// Boxes are more precise without thinking; only Flash models can turn it off
+		config.ThinkingConfig = &genai.ThinkingConfig{ThinkingBudget: genai.Ptr[int32](0)}
+	}
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{
+			{InlineData: &genai.Blob{MIMEType: mimeType, Data: data}},
+			genai.NewPartFromText(prompt),
+		}, genai.RoleUser),
+	}
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, GeminiOCROutput{}, fmt.Errorf("error extracting text: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return nil, GeminiOCROutput{}, toolerr.Errorf(toolerr.Upstream, "no text was returned")
+	}
+
+	var parsed struct {
+		Languages []string `json:"languages"`
+		Blocks    []struct {
+			Text     string    `json:"text"`
+			Type     string    `json:"type"`
+			Language string    `json:"language"`
+			Box2D    []float64 `json:"box_2d"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(response.Text()), &parsed); err != nil {
+		return nil, GeminiOCROutput{}, fmt.Errorf("failed to parse extracted text: %w", err)
+	}
+
+	output.Languages = []string{}
+	for _, language := range parsed.Languages {
+		if language = strings.TrimSpace(language); language != "" {
+			output.Languages = append(output.Languages, language)
+		}
+	}
+	texts := []string{}
+	for _, b := range parsed.Blocks {
+		text := strings.TrimSpace(b.Text)
+		if text == "" {
+			continue
+		}
+		texts = append(texts, text)
+		if !input.Layout {
+			continue
+		}
+
+		block := OCRBlock{Text: text, Type: b.Type, Language: b.Language}
+		if box, ok := imaging.BoxFromGemini(b.Box2D); ok {
+			block.Box = &box
+			if output.Width > 0 {
+				px := box.Pixels(output.Width, output.Height)
+				block.PixelBox = &PixelBox{X: px.Min.X, Y: px.Min.Y, Width: px.Dx(), Height: px.Dy()}
+			}
+		}
+		output.Blocks = append(output.Blocks, block)
+	}
+	output.Text = strings.Join(texts, "\n\n")
+	output.Warnings = collected.List()
+	output.Tokens = used.Tokens()
+
+	summary := output.Text
+	if summary == "" {
+		summary = "No text was found."
+		if isPDF {
+			summary = fmt.Sprintf("No text was found on page %d.", output.Page)
+		}
+	} else if len(output.Languages) > 0 {
+		summary = fmt.Sprintf("Languages: %s\n\n%s", strings.Join(output.Languages, ", "), summary)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: summary}},
+	}, output, nil
+}
+
+// ocrPrompt builds the extraction instructions and a response schema that
+// only asks for block boxes and languages when the layout was requested.
+// page is the PDF page to read, or 0 for an image.
+func ocrPrompt(input GeminiOCRInput, page int) (string, *genai.GenerateContentConfig) {
+	promptParts := []string{"Extract all text visible in this image exactly as written, including signs, labels, handwriting and text in tables. Do not translate, summarize or correct it. Return the text as blocks in natural reading order and classify each block's type. If there is no text, return no blocks."}
+	if page > 0 {
+		promptParts[0] = fmt.Sprintf("Extract all text on page %d of this PDF exactly as written, including headers, footers, handwriting and text in tables. Do not translate, summarize or correct it, and ignore every other page. Return the text as blocks in natural reading order and classify each block's type. If the document has no page %d or the page has no text, return no blocks.", page, page)
+	}
+	properties := map[string]*genai.Schema{
+		"text": {Type: genai.TypeString},
+		"type": {Type: genai.TypeString, Enum: ocrBlockTypes},
+	}
+	required := []string{"text", "type"}
+
+	if input.Layout {
+		promptParts = append(promptParts, "Give each block its bounding box as box_2d [ymin, xmin, ymax, xmax] normalized to 0-1000 and its language as an ISO 639-1 code.")
+		properties["box_2d"] = &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeNumber}}
+		properties["language"] = &genai.Schema{Type: genai.TypeString}
+		required = append(required, "box_2d", "language")
+	}
+	if len(input.Languages) > 0 {
+		promptParts = append(promptParts, fmt.Sprintf("The text is expected to be in %s.", strings.Join(input.Languages, ", ")))
+	}
+	promptParts = append(promptParts, "Report the languages of the text as ISO 639-1 codes, most common first.")
+
+	config := &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"languages": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+				"blocks": {
+					Type: genai.TypeArray,
+					Items: &genai.Schema{
+						Type:       genai.TypeObject,
+						Properties: properties,
+						Required:   required,
+					},
+				},
+			},
+			Required: []string{"languages", "blocks"},
+		},
+	}
+	return strings.Join(promptParts, "\n"), config
+}
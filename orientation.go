@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+
+	"gemini-mcp/internal/imaging"
+)
+
+// normalizeOrientation applies and strips the EXIF orientation of a JPEG
+// photo. Other data, and photos that fail to re-encode, are returned unchanged.
+func normalizeOrientation(data []byte, name string) []byte {
+	normalized, changed, err := imaging.NormalizeOrientation(data)
+	if err != nil {
+		log.Printf("Warning: failed to correct EXIF orientation of %s: %v", name, err)
+		return data
+	}
+	if changed {
+		log.Printf("Applied EXIF orientation of %s", name)
+	}
+	return normalized
+}
+
+// uprightInput returns a path to an upright copy of a rotated JPEG input,
+// chaining the removal of the copy onto cleanup. Other inputs are returned
+// as-is; only the file header is read to rule out non-JPEG files such as videos.
+func (s *Server) uprightInput(localPath string, cleanup func()) (string, func(), error) {
+	if !isJPEGFile(localPath) {
+		return localPath, cleanup, nil
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil || imaging.JPEGOrientation(data) == 1 {
+		return localPath, cleanup, nil
+	}
+
+	return s.replaceInput(localPath, normalizeOrientation(data, localPath), "gemini-mcp-upright-*.jpg", cleanup)
+}
+
+// replaceInput writes data to a temporary file standing in for localPath,
+// chaining the removal of the file onto cleanup. If the file cannot be
+// written, localPath is used unchanged.
+func (s *Server) replaceInput(localPath string, data []byte, pattern string, cleanup func()) (string, func(), error) {
+	tmp, err := s.tempFiles.Create(pattern)
+	if err != nil {
+		log.Printf("Warning: failed to write converted copy of %s: %v", localPath, err)
+		return localPath, cleanup, nil
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Warning: failed to write converted copy of %s: %v", localPath, err)
+		return localPath, cleanup, nil
+	}
+
+	return tmp.Name(), func() {
+		os.Remove(tmp.Name())
+		if cleanup != nil {
+			cleanup()
+		}
+	}, nil
+}
+
+// isJPEGFile reports whether the file at path starts with the JPEG signature
+func isJPEGFile(path string) bool {
+	header := readHeader(path, 3)
+	return header != nil && header[0] == 0xFF && header[1] == 0xD8 && header[2] == 0xFF
+}
+
+// readHeader returns the first n bytes of the file at path, or nil if the
+// file is shorter or cannot be read
+func readHeader(path string, n int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	header := make([]byte, n)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return nil
+	}
+	return header
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/warnings"
+)
+
+// resolveOutputDirectory normalises a tool's output_directory argument,
+// expanding ~ and environment variables, and creates the directory.
+// It returns "" when no directory was requested or when the call did not
+// arrive over stdio, where the caller shares the server's filesystem.
+func (s *Server) resolveOutputDirectory(ctx context.Context, dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	if !s.config.StdioEnabled() || calledOverHTTP(ctx) || s.storage.IsRemote() {
+		warnings.Add(ctx, "output_directory %q was ignored: it is only supported in stdio mode with local storage", dir)
+		return "", nil
+	}
+
+	dir, _ = storage.NormalizeLocalPath(dir)
+	if err := s.pathPolicy.Check(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+// copyToOutputDirectory writes a copy of a stored file into dir under the
+// file name of its object key. Failures are logged, as the file is already
+// saved in the default output directory.
+func copyToOutputDirectory(dir, objectKey string, data []byte) {
+	if dir == "" {
+		return
+	}
+	path := filepath.Join(dir, filepath.Base(objectKey))
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Error copying %s to output directory: %v", objectKey, err)
+		return
+	}
+	log.Printf("Copied %s to %s", objectKey, path)
+}
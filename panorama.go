@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Panorama generation
+type GeneratePanoramaInput struct {
+	Prompt        string `json:"prompt" jsonschema:"description:Description of the scene to render as a panorama (e.g., 'a misty pine forest at dawn with a lake in the foreground')"`
+	Model         string `json:"model,omitempty" jsonschema:"description:Gemini image model used for the segments,default:gemini-3-pro-image-preview"`
+	Mode          string `json:"mode,omitempty" jsonschema:"description:Panorama type: 'wide' (landscape strip for backdrops and banners) or 'equirectangular' (2:1 image wrapping a full 360 degrees for VR viewers and skyboxes),default:wide,enum:wide,enum:equirectangular"`
+	Segments      int    `json:"segments,omitempty" jsonschema:"description:Number of segments generated and stitched left to right (2-6). More segments give a wider panorama but take longer. Defaults to 3 for wide and 4 for equirectangular."`
+	ImageSize     string `json:"image_size,omitempty" jsonschema:"description:Resolution of each segment: '1K' or '2K',default:1K,enum:1K,enum:2K"`
+	Style         string `json:"style,omitempty" jsonschema:"description:Optional image style such as 'photorealistic', 'watercolor', 'anime'"`
+	LinkTTL       string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename      string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+}
+
+type GeneratePanoramaOutput struct {
+	PanoramaFile string            `json:"panorama_file"`
+	Mode         string            `json:"mode"`
+	Segments     int               `json:"segments"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+const maxPanoramaSegments = 6
+
+func (s *Server) handleGeneratePanorama(ctx context.Context, req *mcp.CallToolRequest, input GeneratePanoramaInput) (*mcp.CallToolResult, GeneratePanoramaOutput, error) {
+	if input.Prompt == "" {
+		return nil, GeneratePanoramaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "prompt is required")
+	}
+
+	// Set defaults
+	model := input.Model
+	if model == "" {
+		model = s.config.ImageDefaultModel
+	}
+	if err := s.allowlist.Check("generate_panorama", model); err != nil {
+		return nil, GeneratePanoramaOutput{}, err
+	}
+
+	mode := input.Mode
+	if mode == "" {
+		mode = "wide"
+	}
+
+	// Equirectangular segments are portrait so that 4 segments with a third
+	// of each overlapping cover exactly 2:1
+	var aspectRatio, projectionHint string
+	defaultSegments := 3
+	switch mode {
+	case "wide":
+		aspectRatio = "16:9"
+		projectionHint = "wide panoramic landscape photograph, continuous horizon"
+	case "equirectangular":
+		aspectRatio = "3:4"
+		projectionHint = "part of an equirectangular 360 degree panorama, horizon exactly at the vertical center, sky filling the top, ground filling the bottom"
+		defaultSegments = 4
+	default:
+		return nil, GeneratePanoramaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "mode must be 'wide' or 'equirectangular'")
+	}
+
+	segments := input.Segments
+	if segments == 0 {
+		segments = defaultSegments
+	}
+	if segments < 2 || segments > maxPanoramaSegments {
+		return nil, GeneratePanoramaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "segments must be between 2 and %d", maxPanoramaSegments)
+	}
+
+	imageSize := input.ImageSize
+	if imageSize == "" {
+		imageSize = "1K"
+	}
+	if imageSize != "1K" && imageSize != "2K" {
+		return nil, GeneratePanoramaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_size must be '1K' or '2K'")
+	}
+
+	scene := input.Prompt
+	if input.Style != "" {
+		scene = fmt.Sprintf("%s, in %s style", scene, input.Style)
+	}
+
+	log.Printf("Generating %s panorama with model %s (%d segments) for prompt: %s", mode, model, segments, input.Prompt)
+
+	// First segment establishes the scene, lighting and segment size
+	firstData, _, err := s.generateImage(ctx, model, fmt.Sprintf("%s, %s", scene, projectionHint), aspectRatio, imageSize)
+	if err != nil {
+		return nil, GeneratePanoramaOutput{}, fmt.Errorf("failed to generate segment 1: %w", err)
+	}
+	first, _, err := imaging.Decode(firstData)
+	if err != nil {
+		return nil, GeneratePanoramaOutput{}, err
+	}
+	segWidth, segHeight := first.Bounds().Dx(), first.Bounds().Dy()
+
+	overlap := segWidth / 4
+	if mode == "equirectangular" {
+		overlap = segWidth / 3
+	}
+
+	parts := []image.Image{first}
+	for i := 1; i < segments; i++ {
+		// The last equirectangular segment must also join back onto the first
+		var wrap image.Image
+		instruction := "The left part of this image is an existing panorama segment and the flat gray area is blank. Paint the gray area so the scene continues seamlessly to the right. Keep the existing left part unchanged and match its perspective, lighting, colors and horizon line exactly."
+		if mode == "equirectangular" && i == segments-1 {
+			wrap = first
+			instruction = "The left and right parts of this image are existing panorama segments and the flat gray area between them is blank. Paint the gray area so the scene flows seamlessly from the left part into the right part. Keep both existing parts unchanged and match their perspective, lighting, colors and horizon line exactly."
+		}
+
+		canvasData, err := imaging.EncodePNG(imaging.OutpaintCanvas(parts[i-1], overlap, wrap))
+		if err != nil {
+			return nil, GeneratePanoramaOutput{}, err
+		}
+
+		prompt := fmt.Sprintf("%s Scene: %s, %s. No borders, no visible seams, no gray areas.", instruction, scene, projectionHint)
+		segData, _, err := s.generateImage(ctx, model, prompt, aspectRatio, imageSize,
+			&genai.Part{InlineData: &genai.Blob{MIMEType: "image/png", Data: canvasData}})
+		if err != nil {
+			return nil, GeneratePanoramaOutput{}, fmt.Errorf("failed to generate segment %d: %w", i+1, err)
+		}
+		seg, _, err := imaging.Decode(segData)
+		if err != nil {
+			return nil, GeneratePanoramaOutput{}, err
+		}
+		parts = append(parts, imaging.CropToFill(seg, segWidth, segHeight))
+		log.Printf("Generated panorama segment %d/%d", i+1, segments)
+	}
+
+	stitched, err := imaging.StitchHorizontal(parts, overlap, mode == "equirectangular")
+	if err != nil {
+		return nil, GeneratePanoramaOutput{}, fmt.Errorf("failed to stitch panorama: %w", err)
+	}
+
+	var panorama image.Image = stitched
+	if mode == "equirectangular" {
+		// Viewers expect exactly 2:1
+		panorama = imaging.Resize(stitched, stitched.Bounds().Dx(), stitched.Bounds().Dx()/2)
+	}
+	width, height := panorama.Bounds().Dx(), panorama.Bounds().Dy()
+
+	panoramaData, err := imaging.EncodePNG(panorama)
+	if err != nil {
+		return nil, GeneratePanoramaOutput{}, err
+	}
+
+	// Store via storage interface
+	stored, err := s.storage.Store(ctx, panoramaData, "image/png", "panorama")
+	if err != nil {
+		return nil, GeneratePanoramaOutput{}, fmt.Errorf("failed to store panorama: %w", err)
+	}
+	log.Printf("Stored panorama: %s (%dx%d)", stored.Location, width, height)
+
+	timestamp := time.Now().Format("20060102_150405")
+	var downloadURLs []string
+	var expiresAt string
+	if s.storage.IsRemote() {
+		downloadURLs = append(downloadURLs, stored.Location)
+		if stored.ExpiresAt != nil {
+			expiresAt = stored.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+
+	metadata := map[string]string{
+		"prompt":     input.Prompt,
+		"model":      model,
+		"image_size": imageSize,
+		"overlap":    fmt.Sprintf("%d", overlap),
+	}
+
+	summary := fmt.Sprintf("Generated %s panorama (%dx%d) from %d segments", mode, width, height, segments)
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := fmt.Sprintf("%s\n\nDownload URL:\n%s", summary, stored.Location)
+		if expiresAt != "" {
+			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, s.mediaContent(panoramaData, stored)...),
+		}
+	}
+
+	return result, GeneratePanoramaOutput{
+		PanoramaFile: stored.ObjectKey,
+		Mode:         mode,
+		Segments:     segments,
+		Width:        width,
+		Height:       height,
+		SavedFiles:   []string{stored.ObjectKey},
+		DataURIs:     s.addDataURI(nil, stored, panoramaData),
+		Thumbnails:   addThumbnail(nil, stored),
+		DownloadURLs: downloadURLs,
+		ExpiresAt:    expiresAt,
+		Metadata:     metadata,
+		GeneratedAt:  timestamp,
+	}, nil
+}
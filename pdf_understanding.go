@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// PDF understanding
+type GeminiPDFUnderstandingInput struct {
+	PDFPath   string   `json:"pdf_path" jsonschema:"description:Path to the PDF to read. Can be a local file path or an object key returned by upload_media or another tool."`
+	Mode      string   `json:"mode,omitempty" jsonschema:"description:What to return: 'summary' (key points of the document), 'tables' (the tables as rows and columns), 'qa' (answer the provided questions), 'brief' (image-generation briefs: prompts for gemini_image_generation describing the visuals the document calls for),default:summary,enum:summary,enum:tables,enum:qa,enum:brief"`
+	Questions []string `json:"questions,omitempty" jsonschema:"description:Questions to answer about the document. Required when mode is 'qa'."`
+	Pages     string   `json:"pages,omitempty" jsonschema:"description:Optional pages to consider, counted from 1, as a comma-separated list of pages and ranges (e.g. '1-3,7' or '10-'). The whole document is read by default."`
+	Briefs    int      `json:"briefs,omitempty" jsonschema:"description:Number of image-generation briefs to write in 'brief' mode (1-10),default:3"`
+	Prompt    string   `json:"prompt,omitempty" jsonschema:"description:Optional additional instructions (e.g. 'focus on the product specifications', 'the briefs are for a 16:9 hero banner')"`
+	Model     string   `json:"model,omitempty" jsonschema:"description:Gemini model used to read the document,default:gemini-2.5-flash"`
+}
+
+// PDFTable is a table found in a PDF
+type PDFTable struct {
+	Page    int        `json:"page,omitempty"`
+	Title   string     `json:"title,omitempty"`
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// PDFAnswer answers one question about a PDF
+type PDFAnswer struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+	Pages    []int  `json:"pages,omitempty"` // Pages the answer is based on
+}
+
+// ImageBrief is a prompt for an image the document calls for
+type ImageBrief struct {
+	Title       string `json:"title"`
+	Prompt      string `json:"prompt"`
+	AspectRatio string `json:"aspect_ratio,omitempty"`
+	Pages       []int  `json:"pages,omitempty"` // Pages the brief draws on
+}
+
+type GeminiPDFUnderstandingOutput struct {
+	PDFPath     string        `json:"pdf_path"`
+	Mode        string        `json:"mode"`
+	Model       string        `json:"model"`
+	Pages       string        `json:"pages,omitempty"`
+	Analysis    string        `json:"analysis"`
+	Tables      []PDFTable    `json:"tables,omitempty"`
+	Answers     []PDFAnswer   `json:"answers,omitempty"`
+	Briefs      []ImageBrief  `json:"briefs,omitempty"`
+	Prompt      string        `json:"prompt,omitempty"` // The first brief's prompt, e.g. for {{step.prompt}} in run_pipeline
+	Warnings    []string      `json:"warnings,omitempty"`
+	Tokens      *usage.Tokens `json:"tokens,omitempty"` // Gemini tokens used by the request
+	GeneratedAt string        `json:"generated_at"`
+}
+
+// defaultPDFBriefs is the number of briefs written when a call names none
+const defaultPDFBriefs = 3
+
+// briefAspectRatios are the aspect ratios briefs may suggest, those of
+// gemini_image_generation
+var briefAspectRatios = []string{"1:1", "2:3", "3:2", "3:4", "4:3", "4:5", "5:4", "9:16", "16:9", "21:9"}
+
+func (s *Server) handleGeminiPDFUnderstanding(ctx context.Context, req *mcp.CallToolRequest, input GeminiPDFUnderstandingInput) (*mcp.CallToolResult, GeminiPDFUnderstandingOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	ctx, used := usage.WithCollector(ctx)
+	if input.PDFPath == "" {
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.InvalidInput, "pdf_path is required")
+	}
+
+	mode := input.Mode
+	if mode == "" {
+		mode = "summary"
+	}
+	switch mode {
+	case "summary", "tables", "brief":
+	case "qa":
+		if len(input.Questions) == 0 {
+			return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.InvalidInput, "questions are required when mode is 'qa'")
+		}
+	default:
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid mode %q (use summary, tables, qa or brief)", mode)
+	}
+	if input.Briefs == 0 {
+		input.Briefs = defaultPDFBriefs
+	}
+	if input.Briefs < 1 || input.Briefs > 10 {
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.InvalidInput, "briefs must be between 1 and 10")
+	}
+	pages, err := parsePageRanges(input.Pages)
+	if err != nil {
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_pdf_understanding", model); err != nil {
+		return nil, GeminiPDFUnderstandingOutput{}, err
+	}
+
+	log.Printf("Reading PDF %s with model %s (mode: %s)", input.PDFPath, model, mode)
+
+	// Resolve input path (may download from S3)
+	localPath, cleanup, err := s.resolveInputPath(ctx, input.PDFPath)
+	if err != nil {
+		return nil, GeminiPDFUnderstandingOutput{}, fmt.Errorf("failed to resolve input file: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if string(readHeader(localPath, 5)) != "%PDF-" {
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.InvalidInput, "%s is not a PDF; use gemini_ocr or gemini_video_analysis for images and videos", input.PDFPath)
+	}
+
+	// Small documents are sent inline; larger ones go through the Files API
+	inputs := s.newInputParts()
+	defer inputs.Close()
+	pdfPart, _, err := inputs.addFile(ctx, localPath, "application/pdf")
+	if err != nil {
+		return nil, GeminiPDFUnderstandingOutput{}, err
+	}
+
+	prompt, config := pdfPrompt(mode, pages, input)
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{pdfPart, genai.NewPartFromText(prompt)}, genai.RoleUser),
+	}
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, GeminiPDFUnderstandingOutput{}, fmt.Errorf("error reading PDF: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return nil, GeminiPDFUnderstandingOutput{}, toolerr.Errorf(toolerr.Upstream, "no analysis was generated")
+	}
+
+	output := GeminiPDFUnderstandingOutput{
+		PDFPath:     input.PDFPath,
+		Mode:        mode,
+		Model:       model,
+		Pages:       pages.String(),
+		Analysis:    response.Text(),
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+	if err := parsePDFResponse(&output, input.Questions); err != nil {
+		warnings.Add(ctx, "failed to parse the %s as JSON, returned it as text: %v", mode, err)
+	}
+	output.Warnings = collected.List()
+	output.Tokens = used.Tokens()
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: output.Analysis}},
+	}, output, nil
+}
+
+// pageRanges are the pages of a PDF to consider; an empty list is the whole
+// document. A range ending at 0 runs to the end.
+type pageRanges [][2]int
+
+// parsePageRanges parses a list such as "1-3,7,10-"
+func parsePageRanges(spec string) (pageRanges, error) {
+	var ranges pageRanges
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(item, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil || start < 1 {
+			return nil, fmt.Errorf("invalid pages %q: pages are counted from 1, e.g. '1-3,7'", spec)
+		}
+		end := start
+		if isRange {
+			if last = strings.TrimSpace(last); last == "" {
+				end = 0
+			} else if end, err = strconv.Atoi(last); err != nil || end < start {
+				return nil, fmt.Errorf("invalid page range %q in pages", item)
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, nil
+}
+
+// String formats the ranges as they are given in pages
+func (r pageRanges) String() string {
+	items := make([]string, len(r))
+	for i, pr := range r {
+		switch {
+		case pr[1] == 0:
+			items[i] = fmt.Sprintf("%d-", pr[0])
+		case pr[0] == pr[1]:
+			items[i] = strconv.Itoa(pr[0])
+		default:
+			items[i] = fmt.Sprintf("%d-%d", pr[0], pr[1])
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// describe returns the pages in words for the prompt
+func (r pageRanges) describe() string {
+	items := make([]string, len(r))
+	for i, pr := range r {
+		switch {
+		case pr[1] == 0:
+			items[i] = fmt.Sprintf("page %d to the end", pr[0])
+		case pr[0] == pr[1]:
+			items[i] = fmt.Sprintf("page %d", pr[0])
+		default:
+			items[i] = fmt.Sprintf("pages %d to %d", pr[0], pr[1])
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// pdfPrompt builds the instructions and response config for a mode
+func pdfPrompt(mode string, pages pageRanges, input GeminiPDFUnderstandingInput) (string, *genai.GenerateContentConfig) {
+	var promptParts []string
+	var config *genai.GenerateContentConfig
+	pageList := &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeInteger}}
+
+	switch mode {
+	case "tables":
+		promptParts = append(promptParts, "Extract every table in this PDF. For each table give the page it is on, its title or caption if it has one, the column headers and the rows, with each cell exactly as written. Keep the order of rows and columns, and repeat merged cells in every row they span. If there are no tables, return none.")
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"page":    {Type: genai.TypeInteger},
+						"title":   {Type: genai.TypeString},
+						"columns": {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}},
+						"rows":    {Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeArray, Items: &genai.Schema{Type: genai.TypeString}}},
+					},
+					Required: []string{"columns", "rows"},
+				},
+			},
+		}
+	case "qa":
+		promptParts = append(promptParts, "Answer the following questions about this PDF using only what the document says. For each question give the answer and the pages it is based on; if the document does not answer a question, say so.")
+		for i, q := range input.Questions {
+			promptParts = append(promptParts, fmt.Sprintf("%d. %s", i+1, q))
+		}
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"answer": {Type: genai.TypeString},
+						"pages":  pageList,
+					},
+					Required: []string{"answer"},
+				},
+			},
+		}
+	case "brief":
+		promptParts = append(promptParts, fmt.Sprintf("Read this PDF and write %d briefs for images that would illustrate it, such as a product shot, a hero banner or a diagram the document calls for. Each brief is a self-contained prompt for an image generation model: describe the subject, composition, setting, lighting, colors, style and any text that must appear, using the document's product names, brand colors and facts. Give each brief a short title, a suitable aspect ratio and the pages it draws on.", input.Briefs))
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"title":        {Type: genai.TypeString},
+						"prompt":       {Type: genai.TypeString},
+						"aspect_ratio": {Type: genai.TypeString, Enum: briefAspectRatios},
+						"pages":        pageList,
+					},
+					Required: []string{"title", "prompt"},
+				},
+			},
+		}
+	default:
+		promptParts = append(promptParts, "Summarize this PDF. Give its purpose, its key points and figures, and its conclusions, following the structure of the document and citing page numbers for important facts.")
+	}
+
+	if len(pages) > 0 {
+		promptParts = append(promptParts, fmt.Sprintf("Only consider %s of the document and ignore every other page.", pages.describe()))
+	}
+	if input.Prompt != "" {
+		promptParts = append(promptParts, input.Prompt)
+	}
+	return strings.Join(promptParts, "\n"), config
+}
+
+// parsePDFResponse fills the structured fields of a tables, qa or brief
+// response and replaces Analysis with a readable rendering of them
+func parsePDFResponse(output *GeminiPDFUnderstandingOutput, questions []string) error {
+	var b strings.Builder
+	switch output.Mode {
+	case "tables":
+		if err := json.Unmarshal([]byte(output.Analysis), &output.Tables); err != nil {
+			return err
+		}
+		if len(output.Tables) == 0 {
+			b.WriteString("No tables were found.")
+		}
+		for i, table := range output.Tables {
+			if i > 0 {
+				b.WriteString("\n\n")
+			}
+			if table.Title != "" {
+				fmt.Fprintf(&b, "%s", table.Title)
+			} else {
+				fmt.Fprintf(&b, "Table %d", i+1)
+			}
+			if table.Page > 0 {
+				fmt.Fprintf(&b, " (page %d)", table.Page)
+			}
+			fmt.Fprintf(&b, "\n| %s |\n|%s\n", strings.Join(table.Columns, " | "), strings.Repeat("---|", max(len(table.Columns), 1)))
+			for _, row := range table.Rows {
+				fmt.Fprintf(&b, "| %s |\n", strings.Join(row, " | "))
+			}
+		}
+	case "qa":
+		var answers []PDFAnswer
+		if err := json.Unmarshal([]byte(output.Analysis), &answers); err != nil {
+			return err
+		}
+		for i, answer := range answers {
+			if i < len(questions) {
+				answer.Question = questions[i]
+			}
+			output.Answers = append(output.Answers, answer)
+			fmt.Fprintf(&b, "%d. %s\n%s%s\n\n", i+1, answer.Question, answer.Answer, formatPages(answer.Pages))
+		}
+	case "brief":
+		if err := json.Unmarshal([]byte(output.Analysis), &output.Briefs); err != nil {
+			return err
+		}
+		for i, brief := range output.Briefs {
+			fmt.Fprintf(&b, "%d. %s", i+1, brief.Title)
+			if brief.AspectRatio != "" {
+				fmt.Fprintf(&b, " (%s)", brief.AspectRatio)
+			}
+			fmt.Fprintf(&b, "\n%s%s\n\n", brief.Prompt, formatPages(brief.Pages))
+		}
+		if len(output.Briefs) > 0 {
+			output.Prompt = output.Briefs[0].Prompt
+		}
+	default:
+		return nil
+	}
+	output.Analysis = strings.TrimSpace(b.String())
+	return nil
+}
+
+// formatPages cites the pages an answer or brief is based on
+func formatPages(pages []int) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	items := make([]string, len(pages))
+	for i, page := range pages {
+		items[i] = strconv.Itoa(page)
+	}
+	return fmt.Sprintf(" [pages %s]", strings.Join(items, ", "))
+}
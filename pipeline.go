@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// maxPipelineSteps bounds how many steps a run_pipeline call may chain
+const maxPipelineSteps = 10
+
+// Declarative asset pipelines
+type RunPipelineInput struct {
+	Steps         []PipelineStep `json:"steps" jsonschema:"description:Steps to run in order (at most 10). Each step calls one of the server's tools with the given arguments."`
+	Namespace     string         `json:"namespace,omitempty" jsonschema:"description:Optional. Stores every step's files under stable keys in this namespace (e.g. 'project' gives 'project/<step id>_latest.png'), so re-runs overwrite the same keys. Each file is also kept under a versioned key."`
+	LinkTTL       string         `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string         `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+// PipelineStep is one tool call of a pipeline. String arguments may refer to
+// the results of earlier steps with placeholders: {{id}} is the first file a
+// step stored, {{id[n]}} its n-th file (from 0), {{id.files}} the list of all
+// its files and {{id.field}} a top-level field of its output, such as
+// {{analyze.analysis}}. {{previous}} refers to the step before.
+type PipelineStep struct {
+	ID        string         `json:"id,omitempty" jsonschema:"description:Name later steps use to refer to this step's results (default: step1, step2, ...)"`
+	Tool      string         `json:"tool" jsonschema:"description:Tool to call, e.g. gemini_image_generation, gemini_image_edit, veo_image_to_video or vectorize_image"`
+	Arguments map[string]any `json:"arguments,omitempty" jsonschema:"description:Arguments of the tool call. Strings may contain placeholders such as {{previous}} or {{hero[0]}} that are replaced with the object keys or output fields of earlier steps."`
+	KeyHint   string         `json:"key_hint,omitempty" jsonschema:"description:Optional. Stores this step's files under stable keys, e.g. 'hero_16x9' gives 'hero_16x9_latest.png' (inside the pipeline's namespace, if any), so re-runs overwrite the same keys. Further files of the step get '_2', '_3', ... appended to the hint."`
+}
+
+type PipelineStepResult struct {
+	ID           string         `json:"id"`
+	Tool         string         `json:"tool"`
+	Status       string         `json:"status"` // completed, blocked, failed or skipped
+	Error        string         `json:"error,omitempty"`
+	ErrorCode    toolerr.Code   `json:"error_code,omitempty"`
+	Retryable    bool           `json:"retryable,omitempty"`
+	SavedFiles   []string       `json:"saved_files,omitempty"`
+	DownloadURLs []string       `json:"download_urls,omitempty"`
+	Warnings     []string       `json:"warnings,omitempty"`
+	DurationMS   int64          `json:"duration_ms"`
+	Tokens       *usage.Tokens  `json:"tokens,omitempty"` // Gemini tokens used by the step
+	Output       map[string]any `json:"output,omitempty"`
+}
+
+type RunPipelineOutput struct {
+	Status       string               `json:"status"` // completed, blocked or failed
+	Steps        []PipelineStepResult `json:"steps"`
+	Completed    int                  `json:"completed"`
+	FinalFiles   []string             `json:"final_files,omitempty"`
+	SavedFiles   []string             `json:"saved_files,omitempty"`
+	DownloadURLs []string             `json:"download_urls,omitempty"`
+	Tokens       *usage.Tokens        `json:"tokens,omitempty"` // Gemini tokens used by all steps
+	GeneratedAt  string               `json:"generated_at"`
+}
+
+// pipelineTool runs a tool with arguments decoded from a pipeline step
+type pipelineTool func(ctx context.Context, req *mcp.CallToolRequest, args map[string]any) (*mcp.CallToolResult, any, error)
+
+// pipelineStep adapts a tool handler to a pipelineTool. Unknown arguments are
+// rejected, like misspelled arguments of a direct tool call.
+func pipelineStep[In, Out any](next mcp.ToolHandlerFor[In, Out]) pipelineTool {
+	return func(ctx context.Context, req *mcp.CallToolRequest, args map[string]any) (*mcp.CallToolResult, any, error) {
+		var input In
+		data, err := json.Marshal(args)
+		if err != nil {
+			return nil, nil, toolerr.Errorf(toolerr.InvalidInput, "invalid arguments: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&input); err != nil {
+			return nil, nil, toolerr.Errorf(toolerr.InvalidInput, "invalid arguments: %w", err)
+		}
+		return next(ctx, req, input)
+	}
+}
+
+// newPipelineTools lists the tools a pipeline can call. Tools that manage
+// sessions or delete media are left out.
+func (s *Server) newPipelineTools() map[string]pipelineTool {
+	return map[string]pipelineTool{
+		"gemini_image_generation":  pipelineStep(withResponseCache(s, "gemini_image_generation", s.config.ImageDefaultModel, withGenerationResult(s, withStorageOptions(s, s.handleGeminiImageGeneration)))),
+		"gemini_image_edit":        pipelineStep(withGenerationResult(s, withStorageOptions(s, s.handleGeminiImageEdit))),
+		"imagen_edit":              pipelineStep(withGenerationResult(s, withStorageOptions(s, s.handleImagenEdit))),
+		"gemini_multi_image":       pipelineStep(withGenerationResult(s, withStorageOptions(s, s.handleGeminiMultiImage))),
+		"veo_text_to_video":        pipelineStep(withResponseCache(s, "veo_text_to_video", s.config.VeoDefaultModel, withGenerationResult(s, withStorageOptions(s, withBothOrientations(s, "veo_text_to_video", s.handleVeoTextToVideo))))),
+		"veo_image_to_video":       pipelineStep(withGenerationResult(s, withStorageOptions(s, withBothOrientations(s, "veo_image_to_video", s.handleVeoImageToVideo)))),
+		"veo_interpolate":          pipelineStep(withGenerationResult(s, withStorageOptions(s, s.handleVeoInterpolate))),
+		"gemini_video_analysis":    pipelineStep(withGenerationResult(s, s.handleGeminiVideoAnalysis)),
+		"gemini_object_detection":  pipelineStep(withStorageOptions(s, s.handleGeminiObjectDetection)),
+		"gemini_speech_to_text":    pipelineStep(s.handleGeminiSpeechToText),
+		"gemini_ocr":               pipelineStep(s.handleGeminiOCR),
+		"gemini_pdf_understanding": pipelineStep(s.handleGeminiPDFUnderstanding),
+		"gemini_music_generation":  pipelineStep(withGenerationResult(s, withStorageOptions(s, s.handleGeminiMusicGeneration))),
+		"split_grid":               pipelineStep(withStorageOptions(s, s.handleSplitGrid)),
+		"vectorize_image":          pipelineStep(withStorageOptions(s, s.handleVectorizeImage)),
+		"generate_icon_set":        pipelineStep(withStorageOptions(s, s.handleGenerateIconSet)),
+		"generate_depth_map":       pipelineStep(withStorageOptions(s, s.handleGenerateDepthMap)),
+		"generate_panorama":        pipelineStep(withStorageOptions(s, s.handleGeneratePanorama)),
+		"detect_scenes":            pipelineStep(withStorageOptions(s, s.handleDetectScenes)),
+		"video_trim":               pipelineStep(withStorageOptions(s, s.handleVideoTrim)),
+		"video_concat":             pipelineStep(withStorageOptions(s, s.handleVideoConcat)),
+	}
+}
+
+// sortedToolNames returns the sorted names of tools
+func sortedToolNames(tools map[string]pipelineTool) []string {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Server) handleRunPipeline(ctx context.Context, req *mcp.CallToolRequest, input RunPipelineInput) (*mcp.CallToolResult, RunPipelineOutput, error) {
+	if len(input.Steps) == 0 {
+		return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "steps is required")
+	}
+	if len(input.Steps) > maxPipelineSteps {
+		return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "at most %d steps are supported per pipeline", maxPipelineSteps)
+	}
+
+	// Check the whole plan before running anything
+	tools := s.pipelineTools
+	if input.Namespace != "" {
+		if err := storage.ValidateKeyHint(input.Namespace); err != nil {
+			return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "namespace: %w", err)
+		}
+	}
+	ids := make(map[string]bool, len(input.Steps))
+	keyHints := make(map[string]bool, len(input.Steps))
+	for i := range input.Steps {
+		step := &input.Steps[i]
+		if step.ID == "" {
+			step.ID = fmt.Sprintf("step%d", i+1)
+		}
+		if !pipelineStepID.MatchString(step.ID) || step.ID == "previous" {
+			return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %d: invalid id %q (use letters, digits, '_' and '-')", i+1, step.ID)
+		}
+		if ids[step.ID] {
+			return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %d: duplicate id %q", i+1, step.ID)
+		}
+		if _, ok := tools[step.Tool]; !ok {
+			return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %s: tool %q cannot be used in a pipeline", step.ID, step.Tool)
+		}
+		for _, ref := range pipelineReferences(step.Arguments) {
+			if ref == "previous" && i == 0 || ref != "previous" && !ids[ref] {
+				return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %s: {{%s}} does not refer to an earlier step", step.ID, ref)
+			}
+		}
+		if hint := pipelineKeyHint(input.Namespace, *step); hint != "" {
+			if err := storage.ValidateKeyHint(hint); err != nil {
+				return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %s: %w", step.ID, err)
+			}
+			if keyHints[hint] {
+				return nil, RunPipelineOutput{}, toolerr.Errorf(toolerr.InvalidInput, "step %s: key hint %q is used by an earlier step", step.ID, hint)
+			}
+			keyHints[hint] = true
+		}
+		ids[step.ID] = true
+	}
+
+	log.Printf("Running pipeline of %d steps", len(input.Steps))
+	ctx, used := usage.WithCollector(ctx)
+	// Steps are preallocated so results can point into the slice
+	output := RunPipelineOutput{Status: "completed", Steps: make([]PipelineStepResult, 0, len(input.Steps))}
+	results := make(map[string]*PipelineStepResult, len(input.Steps))
+	var previous *PipelineStepResult
+	for _, step := range input.Steps {
+		result := PipelineStepResult{ID: step.ID, Tool: step.Tool}
+		if output.Status != "completed" {
+			result.Status = "skipped"
+			output.Steps = append(output.Steps, result)
+			continue
+		}
+
+		stepCtx := ctx
+		if hint := pipelineKeyHint(input.Namespace, step); hint != "" {
+			stepCtx = storage.WithKeyHint(stepCtx, hint)
+		}
+		if args, err := resolvePipelineArguments(step.Arguments, results, previous); err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			classified := toolerr.Classify(err)
+			result.ErrorCode, result.Retryable = classified.Code, classified.Retryable
+		} else {
+			result = s.runPipelineTool(stepCtx, step.Tool, tools[step.Tool], req, args)
+			result.ID, result.Tool = step.ID, step.Tool
+		}
+		log.Printf("Pipeline step %s (%s) %s in %dms", step.ID, step.Tool, result.Status, result.DurationMS)
+
+		if result.Status == "completed" {
+			output.Completed++
+		} else {
+			output.Status = result.Status
+		}
+		output.SavedFiles = append(output.SavedFiles, result.SavedFiles...)
+		output.DownloadURLs = append(output.DownloadURLs, result.DownloadURLs...)
+		output.Steps = append(output.Steps, result)
+		results[step.ID] = &output.Steps[len(output.Steps)-1]
+		previous = results[step.ID]
+	}
+	if previous != nil && output.Status == "completed" {
+		output.FinalFiles = previous.SavedFiles
+	}
+	output.Tokens = used.Tokens()
+	output.GeneratedAt = time.Now().Format("20060102_150405")
+
+	var summary strings.Builder
+	fmt.Fprintf(&summary, "Pipeline %s: %d of %d steps completed.\n", output.Status, output.Completed, len(input.Steps))
+	for i, result := range output.Steps {
+		fmt.Fprintf(&summary, "%d. %s (%s): %s", i+1, result.ID, result.Tool, result.Status)
+		if result.Error != "" {
+			fmt.Fprintf(&summary, " - %s", result.Error)
+		}
+		if len(result.SavedFiles) > 0 {
+			fmt.Fprintf(&summary, " - %s", strings.Join(result.SavedFiles, ", "))
+		}
+		summary.WriteString("\n")
+	}
+	for i, url := range output.DownloadURLs {
+		fmt.Fprintf(&summary, "\nDownload %d: %s", i+1, url)
+	}
+
+	result := &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: summary.String()}},
+		IsError: output.Status != "completed",
+	}
+	// Report the error of the step that stopped the pipeline
+	for _, step := range output.Steps {
+		if step.ErrorCode != "" {
+			result.Meta = mcp.Meta{"error": toolerr.Info{Code: step.ErrorCode, Message: step.Error, Retryable: step.Retryable}}
+			break
+		}
+	}
+	return result, output, nil
+}
+
+// runPipelineTool calls the tool name and reports its outcome as a step
+// result: failed if it returned an error, blocked if it returned an error
+// result and completed otherwise, with the files and warnings of its output.
+// The tool's default overrides are applied as for a direct call.
+func (s *Server) runPipelineTool(ctx context.Context, name string, tool pipelineTool, req *mcp.CallToolRequest, args map[string]any) PipelineStepResult {
+	var result PipelineStepResult
+	start := time.Now()
+	toolCtx, collected := warnings.WithCollector(ctx)
+	toolCtx, used := usage.WithCollector(toolCtx)
+	args, err := applyToolDefaults(s, name, args)
+	var toolResult *mcp.CallToolResult
+	var output any
+	if err == nil {
+		toolResult, output, err = tool(toolCtx, req, args)
+	}
+	result.DurationMS = time.Since(start).Milliseconds()
+	result.Tokens = used.Tokens()
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+
+	switch {
+	case err != nil:
+		result.Status = "failed"
+		result.Error = err.Error()
+		classified := toolerr.Classify(err)
+		result.ErrorCode, result.Retryable = classified.Code, classified.Retryable
+	case toolResult != nil && toolResult.IsError:
+		result.Status = "blocked"
+		result.Error = "the tool returned an error result"
+		result.ErrorCode = toolerr.ModelBlocked
+		for _, content := range toolResult.Content {
+			if text, ok := content.(*mcp.TextContent); ok {
+				result.Error = text.Text
+				break
+			}
+		}
+	default:
+		result.Status = "completed"
+	}
+	if err == nil && output != nil {
+		result.Output = pipelineOutputFields(output)
+		result.SavedFiles = pipelineFiles(result.Output)
+		result.DownloadURLs = pipelineStrings(result.Output["download_urls"])
+		result.Warnings = pipelineStrings(result.Output["warnings"])
+	}
+	for _, warning := range collected.List() {
+		if !containsFold(result.Warnings, warning) {
+			result.Warnings = append(result.Warnings, warning)
+		}
+	}
+	return result
+}
+
+// pipelineKeyHint returns the key hint of a step's files: its key_hint,
+// inside the pipeline's namespace if one is set, or the step id when only a
+// namespace is set. It is empty when neither is set.
+func pipelineKeyHint(namespace string, step PipelineStep) string {
+	switch {
+	case namespace == "":
+		return step.KeyHint
+	case step.KeyHint == "":
+		return namespace + "/" + step.ID
+	default:
+		return namespace + "/" + step.KeyHint
+	}
+}
+
+var (
+	// pipelineStepID is the form of step ids
+	pipelineStepID = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	// pipelinePlaceholder matches {{id}}, {{id[n]}} and {{id.field}}
+	pipelinePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_-]+)(?:\[(\d+)\]|\.([A-Za-z0-9_]+))?\s*\}\}`)
+)
+
+// pipelineReferences returns the step ids the placeholders in args refer to
+func pipelineReferences(value any) []string {
+	var refs []string
+	switch v := value.(type) {
+	case string:
+		for _, m := range pipelinePlaceholder.FindAllStringSubmatch(v, -1) {
+			refs = append(refs, m[1])
+		}
+	case []any:
+		for _, item := range v {
+			refs = append(refs, pipelineReferences(item)...)
+		}
+	case map[string]any:
+		for _, item := range v {
+			refs = append(refs, pipelineReferences(item)...)
+		}
+	}
+	return refs
+}
+
+// resolvePipelineArguments replaces the placeholders in a step's arguments
+// with the results of earlier steps. A string that is a single placeholder
+// takes the referenced value as is, so {{id.files}} can fill a list argument.
+func resolvePipelineArguments(args map[string]any, results map[string]*PipelineStepResult, previous *PipelineStepResult) (map[string]any, error) {
+	resolved, err := resolvePipelineValue(args, results, previous)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := resolved.(map[string]any)
+	if !ok {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "arguments must be an object")
+	}
+	return m, nil
+}
+
+func resolvePipelineValue(value any, results map[string]*PipelineStepResult, previous *PipelineStepResult) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if m := pipelinePlaceholder.FindStringSubmatch(v); m != nil && m[0] == strings.TrimSpace(v) {
+			return pipelineLookup(m, results, previous)
+		}
+		var lookupErr error
+		replaced := pipelinePlaceholder.ReplaceAllStringFunc(v, func(placeholder string) string {
+			found, err := pipelineLookup(pipelinePlaceholder.FindStringSubmatch(placeholder), results, previous)
+			if err != nil {
+				lookupErr = err
+				return placeholder
+			}
+			if s, ok := found.(string); ok {
+				return s
+			}
+			data, _ := json.Marshal(found)
+			return string(data)
+		})
+		return replaced, lookupErr
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			resolved, err := resolvePipelineValue(item, results, previous)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = resolved
+		}
+		return items, nil
+	case map[string]any:
+		fields := make(map[string]any, len(v))
+		for key, item := range v {
+			resolved, err := resolvePipelineValue(item, results, previous)
+			if err != nil {
+				return nil, err
+			}
+			fields[key] = resolved
+		}
+		return fields, nil
+	default:
+		return value, nil
+	}
+}
+
+// pipelineLookup returns the value a placeholder match refers to
+func pipelineLookup(m []string, results map[string]*PipelineStepResult, previous *PipelineStepResult) (any, error) {
+	result := results[m[1]]
+	if m[1] == "previous" {
+		result = previous
+	}
+	if result == nil {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "%s does not refer to an earlier step", m[0])
+	}
+
+	switch {
+	case m[3] == "files":
+		return result.SavedFiles, nil
+	case m[3] != "":
+		value, ok := result.Output[m[3]]
+		if !ok {
+			return nil, toolerr.Errorf(toolerr.InvalidInput, "%s: step %s has no output field %q", m[0], result.ID, m[3])
+		}
+		return value, nil
+	}
+
+	index := 0
+	if m[2] != "" {
+		index, _ = strconv.Atoi(m[2])
+	}
+	if index >= len(result.SavedFiles) {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "%s: step %s stored %d file(s)", m[0], result.ID, len(result.SavedFiles))
+	}
+	return result.SavedFiles[index], nil
+}
+
+// pipelineOutputFields returns a tool output as a JSON object
+func pipelineOutputFields(output any) map[string]any {
+	data, err := json.Marshal(output)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// pipelineFiles returns the object keys a step stored, from the generation
+// envelope's assets or the saved_files field of other tools
+func pipelineFiles(fields map[string]any) []string {
+	var files []string
+	if assets, ok := fields["assets"].([]any); ok {
+		for _, asset := range assets {
+			if a, ok := asset.(map[string]any); ok {
+				if key, ok := a["object_key"].(string); ok {
+					files = append(files, key)
+				}
+			}
+		}
+	}
+	if len(files) > 0 {
+		return files
+	}
+	return pipelineStrings(fields["saved_files"])
+}
+
+// pipelineStrings converts a decoded JSON array of strings
+func pipelineStrings(value any) []string {
+	items, _ := value.([]any)
+	var result []string
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
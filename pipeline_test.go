@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"gemini-mcp/internal/toolerr"
+)
+
+func testPipelineResults() (map[string]*PipelineStepResult, *PipelineStepResult) {
+	hero := &PipelineStepResult{
+		ID:         "hero",
+		SavedFiles: []string{"images/hero.png", "images/hero_2.png"},
+		Output:     map[string]any{"model": "gemini-3-pro-image-preview", "count": float64(2), "size": map[string]any{"width": float64(1024)}},
+	}
+	empty := &PipelineStepResult{ID: "empty"}
+	return map[string]*PipelineStepResult{"hero": hero, "empty": empty}, hero
+}
+
+func TestPipelineLookup(t *testing.T) {
+	results, previous := testPipelineResults()
+	tests := []struct {
+		placeholder string
+		want        any
+		wantErr     bool
+	}{
+		{"{{hero}}", "images/hero.png", false},
+		{"{{hero[1]}}", "images/hero_2.png", false},
+		{"{{hero.files}}", []string{"images/hero.png", "images/hero_2.png"}, false},
+		{"{{hero.model}}", "gemini-3-pro-image-preview", false},
+		{"{{hero.count}}", float64(2), false},
+		{"{{previous[1]}}", "images/hero_2.png", false},
+		{"{{hero[2]}}", nil, true},
+		{"{{empty}}", nil, true},
+		{"{{hero.missing}}", nil, true},
+		{"{{later}}", nil, true},
+	}
+	for _, tt := range tests {
+		m := pipelinePlaceholder.FindStringSubmatch(tt.placeholder)
+		if m == nil {
+			t.Fatalf("%s does not match the placeholder pattern", tt.placeholder)
+		}
+		got, err := pipelineLookup(m, results, previous)
+		if tt.wantErr {
+			if toolerr.Classify(err).Code != toolerr.InvalidInput {
+				t.Errorf("pipelineLookup(%s) error = %v, want an invalid input error", tt.placeholder, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("pipelineLookup(%s) = %#v, %v, want %#v", tt.placeholder, got, err, tt.want)
+		}
+	}
+}
+
+func TestPipelineLookupWithoutPreviousStep(t *testing.T) {
+	results, _ := testPipelineResults()
+	m := pipelinePlaceholder.FindStringSubmatch("{{previous}}")
+	if _, err := pipelineLookup(m, results, nil); err == nil {
+		t.Error("{{previous}} in the first step resolved, want an error")
+	}
+}
+
+func TestResolvePipelineValue(t *testing.T) {
+	results, previous := testPipelineResults()
+	tests := []struct {
+		name    string
+		value   any
+		want    any
+		wantErr bool
+	}{
+		{"whole value keeps the type", "{{hero.files}}", []string{"images/hero.png", "images/hero_2.png"}, false},
+		{"whole value with spaces", " {{ hero.count }} ", float64(2), false},
+		{"embedded string", "Animate {{hero}} slowly", "Animate images/hero.png slowly", false},
+		{"embedded non-string as JSON", "{{hero.count}} images of {{hero.size}}", `2 images of {"width":1024}`, false},
+		{"several placeholders", "{{hero[0]}},{{hero[1]}}", "images/hero.png,images/hero_2.png", false},
+		{"no placeholder", "plain text", "plain text", false},
+		{"non-string value", float64(3), float64(3), false},
+		{"list", []any{"{{hero}}", true}, []any{"images/hero.png", true}, false},
+		{"object", map[string]any{"image": "{{previous}}"}, map[string]any{"image": "images/hero.png"}, false},
+		{"out-of-range index", "{{hero[5]}}", nil, true},
+		{"embedded out-of-range index", "Use {{hero[5]}}", nil, true},
+		{"unknown step", "{{later.files}}", nil, true},
+		{"unknown step in a list", []any{"ok", "{{later}}"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePipelineValue(tt.value, results, previous)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("resolvePipelineValue(%#v) = %#v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolvePipelineValue(%#v) = %#v, %v, want %#v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+)
+
+// imagePreset describes a fixed-size output target. The image is generated at
+// the closest supported aspect ratio and then centre-cropped to Width x Height.
+type imagePreset struct {
+	Width       int
+	Height      int
+	AspectRatio string
+	ImageSize   string
+	PromptHint  string
+}
+
+var imagePresets = map[string]imagePreset{
+	"favicon": {
+		Width: 512, Height: 512, AspectRatio: "1:1", ImageSize: "1K",
+		PromptHint: "simple bold icon, single centered symbol, legible at very small sizes, no text",
+	},
+	"og_image": {
+		Width: 1200, Height: 630, AspectRatio: "16:9", ImageSize: "2K",
+		PromptHint: "social media link preview banner, key subject centered with generous margins at the top and bottom",
+	},
+	"twitter_card": {
+		Width: 1200, Height: 628, AspectRatio: "16:9", ImageSize: "2K",
+		PromptHint: "social media card banner, key subject centered with generous margins at the top and bottom",
+	},
+	"twitter_summary": {
+		Width: 144, Height: 144, AspectRatio: "1:1", ImageSize: "1K",
+		PromptHint: "simple square thumbnail, single centered subject",
+	},
+	"app_store_iphone": {
+		Width: 1290, Height: 2796, AspectRatio: "9:16", ImageSize: "2K",
+		PromptHint: "tall portrait app store screenshot, important content away from the left and right edges",
+	},
+	"app_store_ipad": {
+		Width: 2048, Height: 2732, AspectRatio: "3:4", ImageSize: "2K",
+		PromptHint: "portrait tablet app store screenshot",
+	},
+	"play_store_feature": {
+		Width: 1024, Height: 500, AspectRatio: "16:9", ImageSize: "2K",
+		PromptHint: "wide feature graphic banner, key subject centered with generous margins at the top and bottom",
+	},
+}
+
+// lookupImagePreset returns the named preset or an error listing the valid names
+func lookupImagePreset(name string) (imagePreset, error) {
+	preset, ok := imagePresets[strings.ToLower(name)]
+	if !ok {
+		names := make([]string, 0, len(imagePresets))
+		for n := range imagePresets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return imagePreset{}, toolerr.Errorf(toolerr.InvalidInput, "unknown preset %q (supported: %s)", name, strings.Join(names, ", "))
+	}
+	return preset, nil
+}
+
+// cropToPreset crops and scales generated image data to the preset's exact
+// dimensions. On failure the original image is returned unchanged.
+func (s *Server) cropToPreset(ctx context.Context, data []byte, mimeType string, preset imagePreset) ([]byte, string) {
+	img, _, err := imaging.Decode(data)
+	if err != nil {
+		warnings.Add(ctx, "preset crop failed, kept the original image: %v", err)
+		return data, mimeType
+	}
+	pngData, err := imaging.EncodePNG(imaging.CropToFill(img, preset.Width, preset.Height))
+	if err != nil {
+		warnings.Add(ctx, "preset crop failed, kept the original image: %v", err)
+		return data, mimeType
+	}
+	return pngData, "image/png"
+}
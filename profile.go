@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gemini-mcp/internal/profile"
+)
+
+// applyConfigProfile loads the config file given by -config or CONFIG_FILE
+// and sets the environment variables its defaults and the profile selected
+// by -profile or CONFIG_PROFILE define, unless they are already set
+func applyConfigProfile() error {
+	path, name := *configFile, *profileName
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	if name == "" {
+		name = os.Getenv("CONFIG_PROFILE")
+	}
+	if path == "" {
+		if name != "" {
+			return fmt.Errorf("profile %q selected without a config file (set -config or CONFIG_FILE)", name)
+		}
+		return nil
+	}
+
+	f, err := profile.Load(path)
+	if err != nil {
+		return err
+	}
+	settings, err := f.Settings(name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	applied, err := profile.Apply(settings)
+	if err != nil {
+		return err
+	}
+
+	label := "defaults"
+	if name != "" {
+		label = "profile " + name
+	}
+	// Only names are logged, since settings may hold keys and secrets
+	if len(applied) > 0 {
+		log.Printf("Config %s from %s: %s", label, path, strings.Join(applied, ", "))
+	} else {
+		log.Printf("Config %s from %s: all settings are overridden by the environment", label, path)
+	}
+	return nil
+}
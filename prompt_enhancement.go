@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// enhancementMaxTokens caps the length of the prompt the client's model writes
+	enhancementMaxTokens = 1024
+	// enhancementTimeout bounds the wait for the client, which may ask the
+	// user to approve the request
+	enhancementTimeout = 2 * time.Minute
+)
+
+// enhancementInstructions are the system prompts sent with enhance, by the
+// kind of media the prompt is for
+var enhancementInstructions = map[string]string{
+	"image": "You turn short image ideas into detailed prompts for an image generation model. " +
+		"Describe the subject, composition, setting, lighting, colour palette, style and mood in one paragraph. " +
+		"Keep every element the user asked for and add nothing that contradicts it. Reply with the prompt only.",
+	"video": "You turn short video ideas into detailed prompts for a video generation model that renders 4 to 8 second clips. " +
+		"Describe the subject and its action, camera framing and movement, setting, lighting, visual style, pacing and sound in one paragraph. " +
+		"Keep every element the user asked for and add nothing that contradicts it. Reply with the prompt only.",
+}
+
+// enhanceableInput is implemented by tool inputs with enhance
+type enhanceableInput interface {
+	// enhanceablePrompt returns the prompt field and whether enhance is set
+	enhanceablePrompt() (prompt *string, enhance bool)
+}
+
+func (in *GeminiImageGenerationInput) enhanceablePrompt() (*string, bool) {
+	return &in.Prompt, in.Enhance
+}
+func (in *VeoTextToVideoInput) enhanceablePrompt() (*string, bool)  { return &in.Prompt, in.Enhance }
+func (in *VeoImageToVideoInput) enhanceablePrompt() (*string, bool) { return &in.Prompt, in.Enhance }
+func (in *VeoGenerationInput) enhanceablePrompt() (*string, bool)   { return &in.Prompt, in.Enhance }
+
+// withPromptEnhancement wraps a generation tool handler so that enhance asks
+// the client's model, through MCP sampling, to expand the prompt into a
+// detailed one for the kind of media ("image" or "video") before the tool
+// runs. The prompt used is reported as enhanced_prompt. Without sampling
+// support, or if the client fails, the original prompt is used with a
+// warning.
+func withPromptEnhancement[In, Out any](kind string, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		in, ok := any(&input).(enhanceableInput)
+		if !ok {
+			return next(ctx, req, input)
+		}
+		prompt, enhance := in.enhanceablePrompt()
+		if !enhance || strings.TrimSpace(*prompt) == "" {
+			return next(ctx, req, input)
+		}
+
+		enhanced, err := enhancePrompt(ctx, req, kind, *prompt)
+		if err != nil {
+			log.Printf("Prompt enhancement failed: %v", err)
+			warnings.Add(ctx, "enhance was skipped and the prompt used as given: %v", err)
+			return next(ctx, req, input)
+		}
+		log.Printf("Enhanced %d-character %s prompt to %d characters", len(*prompt), kind, len(enhanced))
+		*prompt = enhanced
+
+		result, output, err := next(ctx, req, input)
+		if out, ok := any(&output).(generationOutput); ok {
+			out.generation().EnhancedPrompt = enhanced
+		}
+		return result, output, err
+	}
+}
+
+// enhancePrompt asks the client's model to rewrite prompt
+func enhancePrompt(ctx context.Context, req *mcp.CallToolRequest, kind, prompt string) (string, error) {
+	if req == nil || req.Session == nil {
+		return "", errSamplingUnsupported
+	}
+	params := req.Session.InitializeParams()
+	if params == nil || params.Capabilities == nil || params.Capabilities.Sampling == nil {
+		return "", errSamplingUnsupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, enhancementTimeout)
+	defer cancel()
+	result, err := req.Session.CreateMessage(ctx, &mcp.CreateMessageParams{
+		SystemPrompt: enhancementInstructions[kind],
+		Messages: []*mcp.SamplingMessage{{
+			Role:    "user",
+			Content: &mcp.TextContent{Text: prompt},
+		}},
+		MaxTokens:        enhancementMaxTokens,
+		ModelPreferences: &mcp.ModelPreferences{IntelligencePriority: 0.5, SpeedPriority: 0.5},
+	})
+	if err != nil {
+		return "", err
+	}
+	text, ok := result.Content.(*mcp.TextContent)
+	if !ok {
+		return "", errSamplingNoText
+	}
+	enhanced := strings.Trim(strings.TrimSpace(text.Text), `"`)
+	if enhanced == "" {
+		return "", errSamplingNoText
+	}
+	return enhanced, nil
+}
+
+// Errors of enhancePrompt
+var (
+	errSamplingUnsupported = errors.New("the client does not support MCP sampling")
+	errSamplingNoText      = errors.New("the client's model returned no text")
+)
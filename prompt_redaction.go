@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/redact"
+)
+
+// readPromptsScope lets a caller read the prompts of other callers' work
+// when PROMPT_REDACTION is set
+const readPromptsScope = "read_prompts"
+
+// promptRedaction returns how the prompts of work started by owner, the
+// callerLabel of its request, are shown to the caller of ctx. Callers always
+// see their own prompts, and callers with the read_prompts scope see
+// everyone's.
+func (s *Server) promptRedaction(ctx context.Context, owner string) redact.Mode {
+	mode, err := redact.ParseMode(s.config.PromptRedaction)
+	if err != nil || mode == redact.Off || callerLabel(ctx) == owner {
+		return redact.Off
+	}
+	if claims := middleware.GetClaims(ctx); claims != nil && claims.HasScope(readPromptsScope) {
+		return redact.Off
+	}
+	return mode
+}
+
+// redactScheduledJob returns a copy of a scheduled job whose result output
+// has its prompts redacted
+func redactScheduledJob(job ScheduledJob, mode redact.Mode) ScheduledJob {
+	if mode == redact.Off || job.Result == nil {
+		return job
+	}
+	result := *job.Result
+	if result.Output != nil {
+		result.Output = mode.Fields(result.Output).(map[string]any)
+	}
+	job.Result = &result
+	return job
+}
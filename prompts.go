@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// promptArg is one argument of a media prompt template
+type promptArg struct {
+	Name        string
+	Description string
+	Required    bool
+	Default     string
+}
+
+// mediaPrompt is a curated template that expands user arguments into an
+// engineered Gemini/Veo prompt and tells the client which tool to call with it
+type mediaPrompt struct {
+	Name        string
+	Title       string
+	Description string
+	Args        []promptArg
+	Render      func(args map[string]string) string
+}
+
+var mediaPrompts = []mediaPrompt{
+	{
+		Name:        "product-shot",
+		Title:       "Product shot",
+		Description: "Commercial product photography of a single item, ready for an online store or ad.",
+		Args: []promptArg{
+			{Name: "product", Description: "The product to photograph, with material and colour (e.g. 'matte black ceramic coffee mug')", Required: true},
+			{Name: "setting", Description: "Background or scene", Default: "seamless light grey studio backdrop"},
+			{Name: "lighting", Description: "Lighting setup", Default: "soft three-point studio lighting with a gentle rim light"},
+			{Name: "aspect_ratio", Description: "Aspect ratio of the image", Default: "1:1"},
+		},
+		Render: func(a map[string]string) string {
+			prompt := fmt.Sprintf("Professional commercial product photograph of %s, centered and in sharp focus, placed on a %s. %s, realistic soft shadows and reflections, true-to-life colours and materials. Shot on a full-frame camera with a 100mm macro lens at f/8, high detail, clean composition with negative space around the product. No text, no watermarks, no extra props.",
+				a["product"], a["setting"], capitalize(a["lighting"]))
+			return toolInstruction("gemini_image_generation", prompt, map[string]string{"aspect_ratio": a["aspect_ratio"]})
+		},
+	},
+	{
+		Name:        "storyboard-scene",
+		Title:       "Storyboard scene",
+		Description: "A single cinematic shot for a storyboard, rendered as an 8-second Veo video.",
+		Args: []promptArg{
+			{Name: "scene", Description: "What happens in the shot: subject, action and setting", Required: true},
+			{Name: "shot_type", Description: "Framing of the shot (e.g. wide establishing shot, medium shot, close-up)", Default: "medium shot"},
+			{Name: "camera_movement", Description: "How the camera moves (e.g. slow dolly in, static tripod, handheld tracking)", Default: "slow dolly in"},
+			{Name: "mood", Description: "Mood, lighting and colour grade", Default: "cinematic, natural light, subtle film grain"},
+			{Name: "audio", Description: "Sound design or dialogue for the shot", Default: "ambient sound matching the scene"},
+		},
+		Render: func(a map[string]string) string {
+			prompt := fmt.Sprintf("%s of %s. Camera: %s. Mood and look: %s. Audio: %s. Keep the subject consistent for the whole shot, with realistic motion and physics.",
+				capitalize(a["shot_type"]), a["scene"], a["camera_movement"], a["mood"], a["audio"])
+			return toolInstruction("veo_text_to_video", prompt, map[string]string{
+				"aspect_ratio":    "16:9",
+				"negative_prompt": "text overlays, subtitles, watermarks, distorted faces",
+			})
+		},
+	},
+	{
+		Name:        "logo-iteration",
+		Title:       "Logo iteration",
+		Description: "Explore logo directions for a brand as a grid of variations that can be split with split_grid.",
+		Args: []promptArg{
+			{Name: "brand", Description: "Brand name exactly as it should be spelled", Required: true},
+			{Name: "concept", Description: "What the logo should convey or depict", Default: "a simple memorable symbol that reflects the brand name"},
+			{Name: "style", Description: "Visual style", Default: "minimal flat vector, geometric shapes, strong silhouette"},
+			{Name: "colors", Description: "Colour palette", Default: "two colours plus black"},
+		},
+		Render: func(a map[string]string) string {
+			prompt := fmt.Sprintf("A 2x2 grid of four distinct logo concepts for the brand \"%s\", each in its own quadrant on a plain white background with generous padding. Concept: %s. Style: %s. Colours: %s. Spell \"%s\" exactly, in clean legible typography. Each variation should explore a different composition (icon left of wordmark, stacked, monogram, emblem). No mockups, no gradients, no photographic effects.",
+				a["brand"], a["concept"], a["style"], a["colors"], a["brand"])
+			return toolInstruction("gemini_image_generation", prompt, map[string]string{"aspect_ratio": "1:1"}) +
+				"\n\nThen call split_grid on the result with rows=2 and cols=2 to get each concept as a separate image, and iterate on the favourite with gemini_image_edit."
+		},
+	},
+	{
+		Name:        "seamless-texture",
+		Title:       "Seamless texture",
+		Description: "A tileable material texture for games, 3D or web backgrounds.",
+		Args: []promptArg{
+			{Name: "material", Description: "The material or pattern (e.g. 'weathered oak planks', 'terrazzo with blue chips')", Required: true},
+			{Name: "scale", Description: "Apparent scale of the detail", Default: "medium scale, about one metre across"},
+		},
+		Render: func(a map[string]string) string {
+			prompt := fmt.Sprintf("Flat, top-down orthographic texture of %s, %s. Even, shadowless diffuse lighting, uniform detail across the whole image, no vignetting, no perspective, no objects or text.",
+				a["material"], a["scale"])
+			return toolInstruction("gemini_image_generation", prompt, map[string]string{"seamless_tile": "true"})
+		},
+	},
+}
+
+// toolInstruction tells the client LLM which tool to call with the engineered prompt
+func toolInstruction(tool, prompt string, params map[string]string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Call the %s tool with this prompt:\n\n%s", tool, prompt)
+
+	var extra []string
+	for _, key := range []string{"aspect_ratio", "negative_prompt", "seamless_tile"} {
+		if value := params[key]; value != "" {
+			extra = append(extra, fmt.Sprintf("%s: %s", key, value))
+		}
+	}
+	if len(extra) > 0 {
+		fmt.Fprintf(&b, "\n\nAlso set:\n- %s", strings.Join(extra, "\n- "))
+	}
+	return b.String()
+}
+
+// capitalize upper-cases the first letter of s
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// registerPrompts registers the curated media prompt templates
+func (s *Server) registerPrompts(server *mcp.Server) {
+	for _, p := range mediaPrompts {
+		prompt := &mcp.Prompt{
+			Name:        p.Name,
+			Title:       p.Title,
+			Description: p.Description,
+		}
+		for _, arg := range p.Args {
+			description := arg.Description
+			if arg.Default != "" {
+				description += fmt.Sprintf(" (default: %s)", arg.Default)
+			}
+			prompt.Arguments = append(prompt.Arguments, &mcp.PromptArgument{
+				Name:        arg.Name,
+				Description: description,
+				Required:    arg.Required,
+			})
+		}
+
+		server.AddPrompt(prompt, func(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			args := make(map[string]string, len(p.Args))
+			for _, arg := range p.Args {
+				value := strings.TrimSpace(req.Params.Arguments[arg.Name])
+				if value == "" {
+					if arg.Required {
+						return nil, fmt.Errorf("argument %q is required", arg.Name)
+					}
+					value = arg.Default
+				}
+				args[arg.Name] = value
+			}
+
+			return &mcp.GetPromptResult{
+				Description: p.Description,
+				Messages: []*mcp.PromptMessage{
+					{
+						Role:    "user",
+						Content: &mcp.TextContent{Text: p.Render(args)},
+					},
+				},
+			}, nil
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/quota"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Generation quotas
+type GetQuotaInput struct{}
+
+type GetQuotaOutput struct {
+	Limited bool           `json:"limited"` // Whether any quota applies to the caller
+	Quotas  []quota.Status `json:"quotas,omitempty"`
+}
+
+// quotaFor returns the caller of ctx and its quotas: those of QUOTAS, with
+// the kinds a managed token sets replaced by the token's. It returns "" for
+// callers without quotas, and for every caller unless authentication is on,
+// since anyone could pick a new identity otherwise.
+func (s *Server) quotaFor(ctx context.Context) (string, quota.Limits) {
+	if s.quotas == nil || !s.config.AuthEnabled {
+		return "", nil
+	}
+	// Callers without credentials have no quota
+	caller := callerIdentity(ctx)
+	if caller == "" {
+		return "", nil
+	}
+	limits := s.quotaLimits
+	if id, ok := strings.CutPrefix(caller, "sub:token:"); ok && s.serviceTokens != nil {
+		if t, err := s.serviceTokens.Get(id); err == nil && t.Quotas != nil {
+			limits = quota.Limits{}
+			for kind, byPeriod := range s.quotaLimits {
+				limits[kind] = byPeriod
+			}
+			for kind, byPeriod := range t.Quotas {
+				limits[kind] = byPeriod
+			}
+		}
+	}
+	if limits.Empty() {
+		return "", nil
+	}
+	return caller, limits
+}
+
+// acquireGeneration counts one generation of kind (quota.Images or
+// quota.Videos) against the caller's quota and waits for a slot of the
+// kind's limiter. A generation that never gets a slot is given back.
+func (s *Server) acquireGeneration(ctx context.Context, kind string) (func(), error) {
+	l := s.imageLimiter
+	if kind == quota.Videos {
+		l = s.videoLimiter
+	}
+	refund := func() {}
+	if caller, limits := s.quotaFor(ctx); caller != "" {
+		var err error
+		if refund, err = s.quotas.Reserve(caller, kind, limits); err != nil {
+			return nil, err
+		}
+	}
+	release, err := l.Acquire(ctx)
+	if err != nil {
+		refund()
+		return nil, err
+	}
+	return release, nil
+}
+
+// quotaMiddleware reports the caller's quotas after each tool call in the
+// result's _meta.quota
+func (s *Server) quotaMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		if method != "tools/call" {
+			return result, err
+		}
+		if res, ok := result.(*mcp.CallToolResult); ok && res != nil {
+			if caller, limits := s.quotaFor(ctx); caller != "" {
+				if res.Meta == nil {
+					res.Meta = mcp.Meta{}
+				}
+				res.Meta["quota"] = s.quotas.Status(caller, limits)
+			}
+		}
+		return result, err
+	}
+}
+
+// quotaHeaders reports the generations the caller has left, as of the start
+// of the request, in the X-Quota-Remaining header, e.g. "images=150/day,
+// videos=3/day"
+func (s *Server) quotaHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if caller, limits := s.quotaFor(r.Context()); caller != "" {
+			w.Header().Set("X-Quota-Remaining", quota.Summary(s.quotas.Status(caller, limits)))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleGetQuota(ctx context.Context, req *mcp.CallToolRequest, input GetQuotaInput) (*mcp.CallToolResult, GetQuotaOutput, error) {
+	caller, limits := s.quotaFor(ctx)
+	if caller == "" {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{&mcp.TextContent{Text: "No generation quotas apply to this caller."}},
+		}, GetQuotaOutput{}, nil
+	}
+
+	output := GetQuotaOutput{Limited: true, Quotas: s.quotas.Status(caller, limits)}
+	var b strings.Builder
+	b.WriteString("Generation quotas:\n")
+	for _, status := range output.Quotas {
+		fmt.Fprintf(&b, "- %s: %d of %d per %s left (resets at %s)\n",
+			status.Kind, status.Remaining, status.Limit, status.Period, status.ResetsAt.Format(time.RFC3339))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: b.String()}},
+	}, output, nil
+}
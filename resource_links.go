@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+
+	"gemini-mcp/internal/respcache"
+	"gemini-mcp/internal/storage"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+type storedAssetsKey struct{}
+
+// storedAssets records the objects stored during one tool call, keyed by
+// object key. Objects are also recorded on the enclosing call's list, e.g.
+// that of a pipeline.
+type storedAssets struct {
+	mu     sync.Mutex
+	keys   []string
+	links  map[string]*mcp.ResourceLink
+	parent *storedAssets
+}
+
+func (a *storedAssets) add(objectKey string, link *mcp.ResourceLink) {
+	for ; a != nil; a = a.parent {
+		a.mu.Lock()
+		if _, ok := a.links[objectKey]; !ok {
+			a.keys = append(a.keys, objectKey)
+		}
+		a.links[objectKey] = link
+		a.mu.Unlock()
+	}
+}
+
+// remove forgets an object deleted during the call, such as a probe object
+// of the benchmark
+func (a *storedAssets) remove(objectKey string) {
+	for ; a != nil; a = a.parent {
+		a.mu.Lock()
+		delete(a.links, objectKey)
+		a.mu.Unlock()
+	}
+}
+
+// list returns the links of the objects still stored, in the order they
+// were stored
+func (a *storedAssets) list() []*mcp.ResourceLink {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var links []*mcp.ResourceLink
+	for _, key := range a.keys {
+		if link, ok := a.links[key]; ok {
+			links = append(links, link)
+		}
+	}
+	return links
+}
+
+// linkRecordingStorage records a resource link for every object stored
+// under a tool call, so withResourceLinks can return it
+type linkRecordingStorage struct {
+	storage.Storage
+}
+
+// Store saves content and records its resource link. Index objects of the
+// response cache are not assets and are left out.
+func (l linkRecordingStorage) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*storage.StorageResult, error) {
+	result, err := l.Storage.Store(ctx, data, mimeType, prefix)
+	if err != nil {
+		return result, err
+	}
+	if assets, ok := ctx.Value(storedAssetsKey{}).(*storedAssets); ok && !respcache.IsIndexKey(ctx, result.ObjectKey) {
+		uri := result.Location
+		if !l.IsRemote() {
+			uri = resourceURI(result)
+		}
+		size := result.Size
+		assets.add(result.ObjectKey, &mcp.ResourceLink{
+			URI:      uri,
+			Name:     filepath.Base(result.ObjectKey),
+			MIMEType: result.MIMEType,
+			Size:     &size,
+		})
+	}
+	return result, nil
+}
+
+// Delete removes an object and forgets its resource link
+func (l linkRecordingStorage) Delete(ctx context.Context, objectKey string) error {
+	if err := l.Storage.Delete(ctx, objectKey); err != nil {
+		return err
+	}
+	if assets, ok := ctx.Value(storedAssetsKey{}).(*storedAssets); ok {
+		assets.remove(objectKey)
+	}
+	return nil
+}
+
+// withResourceLinks wraps a tool handler so that its result carries a
+// resource_link content item for every object it stored, next to the text
+// URLs, letting clients render and fetch assets without parsing the text.
+// Objects the handler already linked, e.g. through mediaContent, are not
+// linked again.
+func withResourceLinks[In, Out any](next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		parent, _ := ctx.Value(storedAssetsKey{}).(*storedAssets)
+		assets := &storedAssets{links: make(map[string]*mcp.ResourceLink), parent: parent}
+		ctx = context.WithValue(ctx, storedAssetsKey{}, assets)
+
+		result, output, err := next(ctx, req, input)
+		links := assets.list()
+		if err != nil || len(links) == 0 || (result != nil && result.IsError) {
+			return result, output, err
+		}
+
+		if result == nil {
+			result = &mcp.CallToolResult{}
+		}
+		if result.Content == nil {
+			// Keep the JSON text the SDK would otherwise fill in
+			data, err := json.Marshal(output)
+			if err != nil {
+				return result, output, err
+			}
+			result.Content = []mcp.Content{&mcp.TextContent{Text: string(data)}}
+		}
+		linked := make(map[string]bool)
+		for _, content := range result.Content {
+			if link, ok := content.(*mcp.ResourceLink); ok {
+				linked[link.URI] = true
+			}
+		}
+		for _, link := range links {
+			if !linked[link.URI] {
+				linked[link.URI] = true
+				result.Content = append(result.Content, link)
+			}
+		}
+		return result, output, nil
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"path/filepath"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// thumbnailMaxDim is the longest side of previews attached to resource links
+const thumbnailMaxDim = 256
+
+// mediaContent builds the MCP content returned for an asset held in local storage.
+// Depending on RESPONSE_MODE the asset is either inlined as base64 image data or
+// referenced through a resource link, accompanied by a small thumbnail for images.
+// Non-raster assets (e.g. videos, SVG, archives) are always returned as resource links.
+func (s *Server) mediaContent(data []byte, result *storage.StorageResult) []mcp.Content {
+	isImage := isRasterMIME(result.MIMEType)
+
+	if isImage && s.shouldInline(len(data)) {
+		return []mcp.Content{&mcp.ImageContent{
+			Data:     data,
+			MIMEType: result.MIMEType,
+		}}
+	}
+
+	size := result.Size
+	contents := []mcp.Content{&mcp.ResourceLink{
+		URI:      resourceURI(result),
+		Name:     filepath.Base(result.ObjectKey),
+		MIMEType: result.MIMEType,
+		Size:     &size,
+	}}
+
+	if isImage {
+		thumb, err := imaging.Thumbnail(data, thumbnailMaxDim)
+		if err != nil {
+			log.Printf("Warning: failed to create thumbnail for %s: %v", result.ObjectKey, err)
+		} else {
+			contents = append(contents, &mcp.ImageContent{
+				Data:     thumb,
+				MIMEType: "image/jpeg",
+			})
+		}
+	}
+
+	return contents
+}
+
+// isRasterMIME reports whether the MIME type is a raster format MCP clients can display
+func isRasterMIME(mimeType string) bool {
+	switch mimeType {
+	case "image/png", "image/jpeg", "image/webp", "image/gif":
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldInline reports whether an asset of the given size may be returned inline
+func (s *Server) shouldInline(size int) bool {
+	switch s.config.ResponseMode {
+	case "inline":
+		return true
+	case "link":
+		return false
+	default:
+		return size <= s.config.ResponseInlineMaxBytes
+	}
+}
+
+// addDataURI records a data: URI for a stored asset in uris when the asset is no
+// larger than DATA_URI_MAX_BYTES, so clients can render it without another
+// fetch. Like append, it returns the possibly newly allocated map.
+func (s *Server) addDataURI(uris map[string]string, result *storage.StorageResult, data []byte) map[string]string {
+	if s.config.DataURIMaxBytes <= 0 || len(data) > s.config.DataURIMaxBytes {
+		return uris
+	}
+	if uris == nil {
+		uris = make(map[string]string)
+	}
+	uris[result.ObjectKey] = "data:" + result.MIMEType + ";base64," + base64.StdEncoding.EncodeToString(data)
+	return uris
+}
+
+// safetyBlockedResult reports a generation blocked by safety filters as a tool
+// error; the structured output still carries the safety feedback. The block
+// is counted in the safety statistics of the tool call.
+func safetyBlockedResult(ctx context.Context, feedback *safety.Feedback) *mcp.CallToolResult {
+	safety.RecordBlock(ctx, feedback)
+	return &mcp.CallToolResult{
+		IsError: true,
+		Content: []mcp.Content{&mcp.TextContent{Text: feedback.Summary()}},
+		Meta:    mcp.Meta{"error": toolerr.Info{Code: toolerr.ModelBlocked, Message: feedback.Summary()}},
+	}
+}
+
+// resourceURI returns a file:// URI for locally stored assets, falling back to
+// a media:// URI keyed by object key when the absolute path cannot be determined
+func resourceURI(result *storage.StorageResult) string {
+	absPath, err := filepath.Abs(result.Location)
+	if err != nil {
+		return "media://" + result.ObjectKey
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(absPath)}).String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/respcache"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// uncachedFields are input fields that do not change the generated files,
+// so calls differing only in them share a cache entry
+var uncachedFields = []string{"bypass_cache", "webhook_url"}
+
+// withResponseCache wraps a generation tool handler so that a call with the
+// same model, prompt and parameters as an earlier completed one returns the
+// earlier result and its already stored files instead of generating them
+// again. bypass_cache skips the lookup; its result replaces the cached one.
+// It wraps withGenerationResult, so cache hits report cached=true, and
+// webhooks are sent only for calls that generate.
+func withResponseCache[In, Out any](s *Server, tool, defaultModel string, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		if s.responseCache == nil {
+			return next(ctx, req, input)
+		}
+		key, err := respcache.Key(tool, middleware.GetTenant(ctx), defaultModel, input, uncachedFields...)
+		if err != nil {
+			log.Printf("Warning: response cache skipped for %s: %v", tool, err)
+			return next(ctx, req, input)
+		}
+
+		var fields struct {
+			BypassCache bool `json:"bypass_cache"`
+		}
+		if data, err := json.Marshal(input); err == nil {
+			json.Unmarshal(data, &fields)
+		}
+		if !fields.BypassCache {
+			start := time.Now()
+			if entry, ok := s.responseCache.Get(ctx, key); ok {
+				if result, output, ok := cachedResult[Out](entry, start); ok {
+					log.Printf("Tool %s served from the response cache (%s...)", tool, key[:12])
+					return result, output, nil
+				}
+			}
+		}
+
+		// Links are added before caching, so cache hits return them too
+		result, output, err := withResourceLinks(next)(ctx, req, input)
+		if out, ok := any(&output).(generationOutput); ok && err == nil {
+			s.cacheResult(ctx, key, result, out.generation(), output)
+		}
+		return result, output, err
+	}
+}
+
+// cacheResult stores the result of a call that completed and stored files.
+// Entries expire before their download URLs would, so a cached result never
+// hands out links that are about to stop working.
+func (s *Server) cacheResult(ctx context.Context, key string, result *mcp.CallToolResult, envelope *GenerationResult, output any) {
+	if envelope.Status != "completed" || len(envelope.Assets) == 0 || (result != nil && result.IsError) {
+		return
+	}
+
+	entry := respcache.Entry{}
+	for _, asset := range envelope.Assets {
+		entry.ObjectKeys = append(entry.ObjectKeys, asset.ObjectKey)
+		if expiresAt, err := time.Parse(time.RFC3339, asset.ExpiresAt); err == nil {
+			if usable := expiresAt.Add(-urlExpiryWarning); entry.ExpiresAt.IsZero() || usable.Before(entry.ExpiresAt) {
+				entry.ExpiresAt = usable
+			}
+		}
+	}
+	if !entry.ExpiresAt.IsZero() && !entry.ExpiresAt.After(time.Now()) {
+		return
+	}
+
+	var err error
+	if entry.Result, err = json.Marshal(result); err != nil {
+		log.Printf("Warning: failed to cache result: %v", err)
+		return
+	}
+	if entry.Output, err = json.Marshal(output); err != nil {
+		log.Printf("Warning: failed to cache result: %v", err)
+		return
+	}
+	if err := s.responseCache.Put(ctx, key, entry); err != nil {
+		log.Printf("Warning: response cache entry kept in memory only: %v", err)
+	}
+}
+
+// cachedResult decodes a cache entry into the tool's result and output,
+// marking the output as served from the cache
+func cachedResult[Out any](entry respcache.Entry, start time.Time) (*mcp.CallToolResult, Out, bool) {
+	var output Out
+	if err := json.Unmarshal(entry.Output, &output); err != nil {
+		log.Printf("Warning: ignoring invalid response cache entry: %v", err)
+		return nil, output, false
+	}
+	var result *mcp.CallToolResult
+	if err := json.Unmarshal(entry.Result, &result); err != nil {
+		log.Printf("Warning: ignoring invalid response cache entry: %v", err)
+		return nil, output, false
+	}
+
+	if out, ok := any(&output).(generationOutput); ok {
+		envelope := out.generation()
+		envelope.Cached = true
+		envelope.Stats.DurationMS = time.Since(start).Milliseconds()
+		envelope.Stats.Tokens = nil // No tokens were used for this call
+		envelope.Warnings = append(envelope.Warnings, "returned the stored result of an identical earlier call; set bypass_cache to generate anew")
+	}
+	return result, output, true
+}
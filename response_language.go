@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"gemini-mcp/internal/i18n"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// withResponseLanguage wraps a tool handler so that the human-readable text
+// of its result is localized into the request's response_language, or the
+// server's RESPONSE_LANGUAGE when the request does not set one
+func withResponseLanguage[In, Out any](s *Server, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		var fields struct {
+			ResponseLanguage string `json:"response_language"`
+		}
+		if data, err := json.Marshal(input); err == nil {
+			json.Unmarshal(data, &fields)
+		}
+		lang := s.config.ResponseLanguage
+		if fields.ResponseLanguage != "" {
+			if _, ok := i18n.Lookup(fields.ResponseLanguage); !ok {
+				var zero Out
+				return nil, zero, toolerr.Errorf(toolerr.InvalidInput, "response_language: unsupported language %q (supported: %s)",
+					fields.ResponseLanguage, strings.Join(i18n.Supported(), ", "))
+			}
+			lang = fields.ResponseLanguage
+		}
+		return next(i18n.WithLanguage(ctx, lang), req, input)
+	}
+}
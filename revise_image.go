@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/chat"
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Iterative image revision
+type ReviseImageInput struct {
+	SessionID     string `json:"session_id,omitempty" jsonschema:"description:Revision session returned by an earlier revise_image call. Omit to start a new chain."`
+	Instruction   string `json:"instruction" jsonschema:"description:What to change, e.g. 'make the sky darker' or 'remove the person on the left'. When starting a chain without image_path, the first image is generated from this instruction."`
+	ImagePath     string `json:"image_path,omitempty" jsonschema:"description:Image to start a new chain from. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media. Only used when session_id is omitted."`
+	FromRevision  int    `json:"from_revision,omitempty" jsonschema:"description:Revise this revision (1-based, see revisions in the result) instead of the latest one, to roll back to an earlier step and branch from it"`
+	Model         string `json:"model,omitempty" jsonschema:"description:Image model for a new chain; existing chains keep their model,default:gemini-3-pro-image-preview"`
+	ImageSize     string `json:"image_size,omitempty" jsonschema:"description:Resolution of the revised image: '1K', '2K' or '4K',default:1K,enum:1K,enum:2K,enum:4K"`
+	LinkTTL       string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename      string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+}
+
+type ReviseImageOutput struct {
+	SessionID        string            `json:"session_id"`
+	Model            string            `json:"model"`
+	Revision         int               `json:"revision"`
+	ObjectKey        string            `json:"object_key"`
+	Revisions        []chat.Revision   `json:"revisions"`
+	SavedFiles       []string          `json:"saved_files,omitempty"`
+	DataURIs         map[string]string `json:"data_uris,omitempty"`
+	Thumbnails       map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs     []string          `json:"download_urls,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	SessionExpiresAt string            `json:"session_expires_at"`
+	Warnings         []string          `json:"warnings,omitempty"`
+	GeneratedAt      string            `json:"generated_at"`
+}
+
+func (s *Server) handleReviseImage(ctx context.Context, req *mcp.CallToolRequest, input ReviseImageInput) (*mcp.CallToolResult, ReviseImageOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	if strings.TrimSpace(input.Instruction) == "" {
+		return nil, ReviseImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "instruction is required")
+	}
+	if input.SessionID != "" && input.ImagePath != "" {
+		return nil, ReviseImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path can only be used to start a new chain; omit session_id or image_path")
+	}
+
+	imageSize := input.ImageSize
+	if imageSize == "" {
+		imageSize = "1K"
+	}
+
+	var sess *chat.Session
+	var err error
+	if input.SessionID == "" {
+		model := input.Model
+		if model == "" {
+			model = s.config.ImageDefaultModel
+		}
+		if err := s.allowlist.Check("revise_image", model); err != nil {
+			return nil, ReviseImageOutput{}, err
+		}
+		if sess, err = s.chats.Create(model, ""); err != nil {
+			return nil, ReviseImageOutput{}, err
+		}
+		log.Printf("Started revision chain %s (model: %s)", sess.ID, model)
+	} else if sess, err = s.chats.Get(input.SessionID); err != nil {
+		return nil, ReviseImageOutput{}, err
+	}
+
+	sess.Lock()
+	defer sess.Unlock()
+
+	// A chain started from an existing image keeps a stored copy as revision 1,
+	// so it can be rolled back to even if the original is later changed
+	var savedFiles []string
+	if input.ImagePath != "" {
+		data, err := s.readRevisionImage(ctx, input.ImagePath)
+		if err != nil {
+			s.chats.Delete(sess.ID)
+			return nil, ReviseImageOutput{}, err
+		}
+		mimeType, _ := imaging.DetectInputMIME(data)
+		result, err := s.storage.Store(ctx, data, mimeType, "revision")
+		if err != nil {
+			s.chats.Delete(sess.ID)
+			return nil, ReviseImageOutput{}, fmt.Errorf("failed to store original image: %w", err)
+		}
+		sess.AddRevision(0, "original: "+input.ImagePath, result.ObjectKey)
+		savedFiles = append(savedFiles, result.ObjectKey)
+	}
+
+	parent := len(sess.Revisions)
+	if input.FromRevision != 0 {
+		if input.FromRevision < 1 || input.FromRevision > len(sess.Revisions) {
+			return nil, ReviseImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "from_revision must be between 1 and %d", len(sess.Revisions))
+		}
+		parent = input.FromRevision
+	}
+
+	var newData []byte
+	var mimeType string
+	if parent == 0 {
+		log.Printf("Revision chain %s: generating first image", sess.ID)
+		newData, mimeType, err = s.generateImage(ctx, sess.Model, input.Instruction, "", imageSize)
+	} else {
+		base := sess.Revisions[parent-1]
+		log.Printf("Revision chain %s: revising revision %d (%s)", sess.ID, parent, base.ObjectKey)
+		baseData, readErr := s.readRevisionImage(ctx, base.ObjectKey)
+		if readErr != nil {
+			return nil, ReviseImageOutput{}, fmt.Errorf("revision %d: %w", parent, readErr)
+		}
+		baseMIME, _ := imaging.DetectInputMIME(baseData)
+		aspectRatio := ""
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(baseData)); err == nil {
+			aspectRatio = closestAspectRatio(cfg.Width, cfg.Height)
+		}
+		prompt := fmt.Sprintf("Revise this image: %s. Keep everything that is not mentioned unchanged, including composition, framing and style.", input.Instruction)
+		newData, mimeType, err = s.generateImage(ctx, sess.Model, prompt, aspectRatio, imageSize,
+			&genai.Part{InlineData: &genai.Blob{MIMEType: baseMIME, Data: baseData}})
+	}
+	if err != nil {
+		if len(sess.Revisions) == 0 {
+			s.chats.Delete(sess.ID)
+		} else if saveErr := s.chats.Save(sess); saveErr != nil {
+			log.Printf("Warning: %v", saveErr)
+		}
+		return nil, ReviseImageOutput{}, err
+	}
+
+	result, err := s.storage.Store(ctx, newData, mimeType, "revision")
+	if err != nil {
+		return nil, ReviseImageOutput{}, fmt.Errorf("failed to store revised image: %w", err)
+	}
+	log.Printf("Stored revision: %s", result.Location)
+	rev := sess.AddRevision(parent, input.Instruction, result.ObjectKey)
+	if err := s.chats.Save(sess); err != nil {
+		warnings.Add(ctx, "%v", err)
+	}
+
+	output := ReviseImageOutput{
+		SessionID:        sess.ID,
+		Model:            sess.Model,
+		Revision:         rev.Index,
+		ObjectKey:        rev.ObjectKey,
+		Revisions:        append([]chat.Revision(nil), sess.Revisions...),
+		SavedFiles:       append(savedFiles, result.ObjectKey),
+		DataURIs:         s.addDataURI(nil, result, newData),
+		Thumbnails:       addThumbnail(nil, result),
+		SessionExpiresAt: s.chats.ExpiresAt(sess).Format(time.RFC3339),
+		GeneratedAt:      time.Now().Format("20060102_150405"),
+	}
+
+	var history strings.Builder
+	for _, r := range sess.Revisions {
+		fmt.Fprintf(&history, "\n%d. %s", r.Index, r.Prompt)
+		if r.Parent != 0 && r.Parent != r.Index-1 {
+			fmt.Fprintf(&history, " (from revision %d)", r.Parent)
+		}
+	}
+	summary := fmt.Sprintf("Revision %d of chain %s. Pass session_id to keep refining, or from_revision to roll back.\n\nRevisions:%s", rev.Index, sess.ID, history.String())
+
+	var contents []mcp.Content
+	if s.storage.IsRemote() {
+		output.DownloadURLs = []string{result.Location}
+		summary += "\n\nDownload URL: " + result.Location
+		if result.ExpiresAt != nil {
+			output.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+			summary += fmt.Sprintf("\nURL expires at: %s", output.ExpiresAt)
+		}
+	} else {
+		contents = s.mediaContent(newData, result)
+	}
+	output.Warnings = collected.List()
+
+	return &mcp.CallToolResult{
+		Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, contents...),
+	}, output, nil
+}
+
+// readRevisionImage loads an image of a revision chain and checks that the
+// model accepts it as input
+func (s *Server) readRevisionImage(ctx context.Context, path string) ([]byte, error) {
+	localPath, cleanup, err := s.resolveInputPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if _, err := imaging.DetectInputMIME(data); err != nil {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "invalid image: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/safety"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// safetyStatsMiddleware counts tool calls per tool and caller; blocks are
+// recorded against the call by safetyBlockedResult (see safety.RecordBlock)
+func (s *Server) safetyStatsMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if call, ok := req.(*mcp.CallToolRequest); ok && method == "tools/call" {
+			ctx = safety.WithCall(ctx, s.safetyStats.Start(call.Params.Name, callerLabel(ctx)))
+		}
+		return next(ctx, method, req)
+	}
+}
+
+// operatorOnly rejects callers scoped to a tenant, whose service token or JWT
+// must not reveal other tenants' usage
+func operatorOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if middleware.GetTenant(r.Context()) != "" {
+			http.Error(w, `{"error":"Tenant-scoped callers cannot read server statistics"}`, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleSafetyReport serves the safety filter usage report as JSON
+func (s *Server) handleSafetyReport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.safetyStats.Report())
+}
+
+// handleMetrics serves tool call and safety block counters, and the usage of
+// the temp directory, for Prometheus
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.safetyStats.Report().WriteMetrics(w)
+	if s.tempFiles != nil {
+		s.tempFiles.WriteMetrics(w)
+	}
+}
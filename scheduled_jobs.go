@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/limiter"
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/schedule"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/webhook"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// scheduleCheckInterval is how often scheduled jobs are checked for a start
+	scheduleCheckInterval = 30 * time.Second
+	// maxScheduledJobs bounds the jobs a tenant may have waiting at once
+	maxScheduledJobs = 100
+	// scheduledJobRetention is how long finished scheduled jobs are listed
+	scheduledJobRetention = 24 * time.Hour
+)
+
+// Deferred tool calls
+type ScheduleJobInput struct {
+	Tool       string         `json:"tool" jsonschema:"description:Tool to run later, e.g. gemini_image_batch, run_pipeline or veo_text_to_video (the tools available in pipelines, gemini_image_batch and run_pipeline)"`
+	Arguments  map[string]any `json:"arguments,omitempty" jsonschema:"description:Arguments of the tool call, as for a direct call"`
+	RunAfter   string         `json:"run_after,omitempty" jsonschema:"description:Optional. Earliest start: a time of day as HH:MM (24-hour clock) in timezone, meaning its next occurrence, e.g. '22:00' for tonight's off-peak hours; or an RFC 3339 timestamp. Without run_after or when_idle the job starts at the next check."`
+	Timezone   string         `json:"timezone,omitempty" jsonschema:"description:Optional. IANA time zone of an HH:MM run_after, e.g. 'Europe/Berlin' or 'America/New_York'. Defaults to the server's SCHEDULE_TIMEZONE."`
+	WhenIdle   bool           `json:"when_idle,omitempty" jsonschema:"description:Start only when no image or video generation is running or queued on the server (and not before run_after), so the job does not compete with interactive work for the shared quota. Idle jobs start one at a time.,default:false"`
+	Label      string         `json:"label,omitempty" jsonschema:"description:Optional. Name shown by list_scheduled"`
+	WebhookURL string         `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when the job finishes. Overrides the server's WEBHOOK_URL."`
+}
+
+// ScheduledJob is the state of a job started by schedule_job
+type ScheduledJob struct {
+	JobID      string              `json:"job_id"`
+	Tool       string              `json:"tool"`
+	Label      string              `json:"label,omitempty"`
+	Status     string              `json:"status"` // scheduled, running, completed, blocked or failed
+	RunAfter   string              `json:"run_after,omitempty"`
+	WhenIdle   bool                `json:"when_idle,omitempty"`
+	CreatedAt  string              `json:"created_at"`
+	StartedAt  string              `json:"started_at,omitempty"`
+	FinishedAt string              `json:"finished_at,omitempty"`
+	Result     *PipelineStepResult `json:"result,omitempty"`
+}
+
+type ListScheduledInput struct {
+	Status string `json:"status,omitempty" jsonschema:"description:Optional. Only list jobs with this status,enum:scheduled,enum:running,enum:completed,enum:blocked,enum:failed"`
+}
+
+type ListScheduledOutput struct {
+	Jobs    []ScheduledJob `json:"jobs"`
+	Waiting int            `json:"waiting"` // Jobs of the caller that have not started yet
+}
+
+// scheduledJob is a deferred tool call. It keeps the values of the
+// scheduling call's context, such as the bound API key, link TTL and tenant.
+type scheduledJob struct {
+	ctx        context.Context
+	tenant     string
+	owner      string // callerLabel of the scheduling call
+	runAfter   time.Time
+	whenIdle   bool
+	args       map[string]any
+	webhookURL string
+
+	mu       sync.Mutex
+	status   ScheduledJob
+	finished time.Time // Zero until the job is done
+}
+
+// snapshot returns a copy of the job's state
+func (j *scheduledJob) snapshot() ScheduledJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+// jobScheduler keeps scheduled jobs in the order they were scheduled. Jobs
+// are kept in memory and are lost when the server restarts.
+type jobScheduler struct {
+	mu   sync.Mutex
+	jobs []*scheduledJob
+}
+
+func newJobScheduler() *jobScheduler {
+	return &jobScheduler{}
+}
+
+// add registers a job unless its tenant already has maxScheduledJobs waiting,
+// forgetting jobs that finished more than scheduledJobRetention ago
+func (sc *jobScheduler) add(job *scheduledJob) error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	kept := sc.jobs[:0]
+	waiting := 0
+	for _, j := range sc.jobs {
+		j.mu.Lock()
+		expired := !j.finished.IsZero() && time.Since(j.finished) > scheduledJobRetention
+		if j.tenant == job.tenant && j.status.Status == "scheduled" {
+			waiting++
+		}
+		j.mu.Unlock()
+		if !expired {
+			kept = append(kept, j)
+		}
+	}
+	sc.jobs = kept
+	if waiting >= maxScheduledJobs {
+		return toolerr.Errorf(toolerr.QuotaExceeded, "%d jobs are already waiting; wait for some to start before scheduling more", waiting)
+	}
+	sc.jobs = append(sc.jobs, job)
+	return nil
+}
+
+// list returns the jobs of a tenant, oldest first
+func (sc *jobScheduler) list(tenant string) []*scheduledJob {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var jobs []*scheduledJob
+	for _, j := range sc.jobs {
+		if j.tenant == tenant {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs
+}
+
+// due marks the jobs that may start at now as running and returns them.
+// Jobs waiting for an idle server start only if idle is set, one per check,
+// so that they do not make the server busy all at once.
+func (sc *jobScheduler) due(now time.Time, idle bool) []*scheduledJob {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	var due []*scheduledJob
+	idleStarted := false
+	for _, j := range sc.jobs {
+		j.mu.Lock()
+		ready := j.status.Status == "scheduled" && !now.Before(j.runAfter) && (!j.whenIdle || idle && !idleStarted)
+		if ready {
+			idleStarted = idleStarted || j.whenIdle
+			j.status.Status = "running"
+			j.status.StartedAt = now.Format(time.RFC3339)
+			due = append(due, j)
+		}
+		j.mu.Unlock()
+	}
+	return due
+}
+
+// newSchedulableTools lists the tools schedule_job can defer: those available
+// in pipelines, batches and pipelines themselves
+func (s *Server) newSchedulableTools() map[string]pipelineTool {
+	tools := maps.Clone(s.pipelineTools)
+	tools["gemini_image_batch"] = pipelineStep(withStorageOptions(s, s.handleGeminiImageBatch))
+	tools["run_pipeline"] = pipelineStep(withStorageOptions(s, s.handleRunPipeline))
+	return tools
+}
+
+// generationIdle reports whether no image or video generation is running or
+// waiting for a slot. Limiters without a limit do not count their callers, so
+// without MAX_CONCURRENT_GENERATIONS the server always looks idle.
+func (s *Server) generationIdle() bool {
+	imageInUse, imageWaiting := s.imageLimiter.Stats()
+	videoInUse, videoWaiting := s.videoLimiter.Stats()
+	return imageInUse+imageWaiting+videoInUse+videoWaiting == 0
+}
+
+// runScheduler starts scheduled jobs when they are due until ctx is done
+func (s *Server) runScheduler(ctx context.Context) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Jobs not yet due stay scheduled while the server drains. The
+			// check counts as work in flight, so a drain starting meanwhile
+			// waits for the jobs it starts.
+			leave, ok := s.drainer.Enter()
+			if !ok {
+				continue
+			}
+			for _, job := range s.scheduler.due(now, s.generationIdle()) {
+				hold, _ := s.drainer.Hold()
+				go func() {
+					defer hold()
+					s.runScheduledJob(job)
+				}()
+			}
+			leave()
+		}
+	}
+}
+
+// runScheduledJob runs a job's tool call as batch work and records its outcome
+func (s *Server) runScheduledJob(job *scheduledJob) {
+	status := job.snapshot()
+	log.Printf("Starting scheduled job %s (%s)", status.JobID, status.Tool)
+
+	ctx := limiter.WithPriority(job.ctx, limiter.Batch, "scheduled:"+job.tenant)
+	// There is no client session to ask for confirmations while the job runs
+	result := s.runPipelineTool(ctx, status.Tool, s.schedulableTools[status.Tool], nil, job.args)
+	result.ID, result.Tool = status.JobID, status.Tool
+	log.Printf("Scheduled job %s (%s) %s in %dms", status.JobID, status.Tool, result.Status, result.DurationMS)
+
+	job.mu.Lock()
+	job.finished = time.Now()
+	job.status.Status = result.Status
+	job.status.FinishedAt = job.finished.Format(time.RFC3339)
+	job.status.Result = &result
+	job.mu.Unlock()
+
+	event := webhook.Event{
+		Event:        webhook.EventCompleted,
+		Tool:         status.Tool,
+		Status:       result.Status,
+		OperationID:  status.JobID,
+		SavedFiles:   result.SavedFiles,
+		DownloadURLs: result.DownloadURLs,
+		Tenant:       job.tenant,
+	}
+	if result.Status != "completed" {
+		event.Event = webhook.EventFailed
+		event.Error = result.Error
+		event.ErrorCode = string(result.ErrorCode)
+	}
+	s.webhooks.Notify(job.webhookURL, event)
+}
+
+func (s *Server) handleScheduleJob(ctx context.Context, req *mcp.CallToolRequest, input ScheduleJobInput) (*mcp.CallToolResult, ScheduledJob, error) {
+	if input.Tool == "" {
+		return nil, ScheduledJob{}, toolerr.Errorf(toolerr.InvalidInput, "tool is required")
+	}
+	if _, ok := s.schedulableTools[input.Tool]; !ok {
+		return nil, ScheduledJob{}, toolerr.Errorf(toolerr.InvalidInput, "tool %q cannot be scheduled", input.Tool)
+	}
+	if input.WebhookURL != "" {
+		if err := s.webhooks.ValidateURL(input.WebhookURL); err != nil {
+			return nil, ScheduledJob{}, toolerr.Errorf(toolerr.InvalidInput, "webhook_url: %w", err)
+		}
+	}
+	timezone := input.Timezone
+	if timezone == "" {
+		timezone = s.config.ScheduleTimezone
+	}
+	loc, err := schedule.LoadLocation(timezone)
+	if err != nil {
+		return nil, ScheduledJob{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+	now := time.Now()
+	runAfter, err := schedule.NextRun(input.RunAfter, loc, now)
+	if err != nil {
+		return nil, ScheduledJob{}, toolerr.Wrap(toolerr.InvalidInput, err)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, ScheduledJob{}, fmt.Errorf("failed to create job ID: %w", err)
+	}
+	job := &scheduledJob{
+		// The job outlives the tool call but keeps its values
+		ctx:        context.WithoutCancel(ctx),
+		tenant:     middleware.GetTenant(ctx),
+		owner:      callerLabel(ctx),
+		runAfter:   runAfter,
+		whenIdle:   input.WhenIdle,
+		args:       input.Arguments,
+		webhookURL: input.WebhookURL,
+		status: ScheduledJob{
+			JobID:     "job_" + hex.EncodeToString(id),
+			Tool:      input.Tool,
+			Label:     input.Label,
+			Status:    "scheduled",
+			WhenIdle:  input.WhenIdle,
+			CreatedAt: now.Format(time.RFC3339),
+		},
+	}
+	if input.RunAfter != "" {
+		job.status.RunAfter = runAfter.In(loc).Format(time.RFC3339)
+	}
+	if err := s.scheduler.add(job); err != nil {
+		return nil, ScheduledJob{}, err
+	}
+
+	var when []string
+	if input.RunAfter != "" {
+		when = append(when, "after "+job.status.RunAfter)
+	}
+	if input.WhenIdle {
+		when = append(when, "when no generation is running")
+	}
+	if len(when) == 0 {
+		when = append(when, "at the next check")
+	}
+	log.Printf("Scheduled job %s (%s) to start %s", job.status.JobID, input.Tool, strings.Join(when, " and "))
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{
+			Text: fmt.Sprintf("Scheduled %s as job %s to start %s. Follow it with list_scheduled.", input.Tool, job.status.JobID, strings.Join(when, " and ")),
+		}},
+	}, job.snapshot(), nil
+}
+
+func (s *Server) handleListScheduled(ctx context.Context, req *mcp.CallToolRequest, input ListScheduledInput) (*mcp.CallToolResult, ListScheduledOutput, error) {
+	output := ListScheduledOutput{Jobs: []ScheduledJob{}}
+	for _, j := range s.scheduler.list(middleware.GetTenant(ctx)) {
+		// Prompts in other callers' results may be redacted
+		job := redactScheduledJob(j.snapshot(), s.promptRedaction(ctx, j.owner))
+		if job.Status == "scheduled" {
+			output.Waiting++
+		}
+		if input.Status == "" || job.Status == input.Status {
+			output.Jobs = append(output.Jobs, job)
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d scheduled jobs (%d waiting to start)", len(output.Jobs), output.Waiting)
+	for _, job := range output.Jobs {
+		fmt.Fprintf(&text, "\n- %s (%s", job.JobID, job.Tool)
+		if job.Label != "" {
+			fmt.Fprintf(&text, ", %s", job.Label)
+		}
+		fmt.Fprintf(&text, "): %s", job.Status)
+		switch {
+		case job.Status == "scheduled" && job.RunAfter != "":
+			fmt.Fprintf(&text, ", starts after %s", job.RunAfter)
+		case job.Result != nil && job.Result.Error != "":
+			fmt.Fprintf(&text, " - %s", job.Result.Error)
+		case job.Result != nil && len(job.Result.SavedFiles) > 0:
+			fmt.Fprintf(&text, " - %s", strings.Join(job.Result.SavedFiles, ", "))
+		}
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text.String()}},
+	}, output, nil
+}
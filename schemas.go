@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Tool schema export
+type ExportToolSchemasInput struct {
+	Format string   `json:"format,omitempty" jsonschema:"description:Output format: 'json' (list of tools with their input and output JSON Schemas) or 'openapi' (OpenAPI 3.1 document with one POST operation per tool),default:json,enum:json,enum:openapi"`
+	Tools  []string `json:"tools,omitempty" jsonschema:"description:Only export these tools (default: all tools)"`
+}
+
+type ExportToolSchemasOutput struct {
+	Format      string `json:"format"`
+	ToolCount   int    `json:"tool_count"`
+	Document    any    `json:"document"`
+	GeneratedAt string `json:"generated_at"`
+}
+
+// toolSchema is the JSON export of one tool
+type toolSchema struct {
+	Name         string               `json:"name"`
+	Description  string               `json:"description,omitempty"`
+	Annotations  *mcp.ToolAnnotations `json:"annotations,omitempty"`
+	InputSchema  any                  `json:"inputSchema"`
+	OutputSchema any                  `json:"outputSchema,omitempty"`
+}
+
+func (s *Server) handleExportToolSchemas(ctx context.Context, req *mcp.CallToolRequest, input ExportToolSchemasInput) (*mcp.CallToolResult, ExportToolSchemasOutput, error) {
+	format := input.Format
+	if format == "" {
+		format = "json"
+	}
+
+	document, count, err := exportToolSchemas(ctx, s.mcpServer, format, input.Tools)
+	if err != nil {
+		return nil, ExportToolSchemasOutput{}, err
+	}
+
+	text, err := json.MarshalIndent(document, "", "  ")
+	if err != nil {
+		return nil, ExportToolSchemasOutput{}, fmt.Errorf("failed to encode schemas: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: string(text),
+			},
+		},
+	}, ExportToolSchemasOutput{
+		Format:      format,
+		ToolCount:   count,
+		Document:    document,
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}, nil
+}
+
+// exportToolSchemas lists the tools registered on server, exactly as MCP
+// clients see them, and renders them in the requested format
+func exportToolSchemas(ctx context.Context, server *mcp.Server, format string, only []string) (any, int, error) {
+	if format != "json" && format != "openapi" {
+		return nil, 0, toolerr.Errorf(toolerr.InvalidInput, "format must be 'json' or 'openapi'")
+	}
+
+	tools, err := listRegisteredTools(ctx, server)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(only) > 0 {
+		wanted := make(map[string]bool, len(only))
+		for _, name := range only {
+			wanted[name] = true
+		}
+		var filtered []*mcp.Tool
+		for _, tool := range tools {
+			if wanted[tool.Name] {
+				filtered = append(filtered, tool)
+				delete(wanted, tool.Name)
+			}
+		}
+		for name := range wanted {
+			return nil, 0, toolerr.Errorf(toolerr.InvalidInput, "unknown tool %q", name)
+		}
+		tools = filtered
+	}
+
+	if format == "openapi" {
+		return openAPIDocument(tools), len(tools), nil
+	}
+
+	schemas := make([]toolSchema, 0, len(tools))
+	for _, tool := range tools {
+		schemas = append(schemas, toolSchema{
+			Name:         tool.Name,
+			Description:  tool.Description,
+			Annotations:  tool.Annotations,
+			InputSchema:  tool.InputSchema,
+			OutputSchema: tool.OutputSchema,
+		})
+	}
+	return map[string]any{
+		"server":  serviceName,
+		"version": version,
+		"tools":   schemas,
+	}, len(tools), nil
+}
+
+// listRegisteredTools connects an in-memory client to server and lists its tools
+func listRegisteredTools(ctx context.Context, server *mcp.Server) ([]*mcp.Tool, error) {
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+	serverSession, err := server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer serverSession.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: serviceName + "-schema-export", Version: version}, nil)
+	clientSession, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect client: %w", err)
+	}
+	defer clientSession.Close()
+
+	var tools []*mcp.Tool
+	for tool, err := range clientSession.Tools(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// openAPIDocument describes each tool as a POST operation whose request body
+// is the tool input and whose response is the tool's structured output
+func openAPIDocument(tools []*mcp.Tool) map[string]any {
+	paths := make(map[string]any, len(tools))
+	for _, tool := range tools {
+		responses := map[string]any{
+			"200": map[string]any{"description": "Tool result"},
+		}
+		if tool.OutputSchema != nil {
+			responses["200"] = map[string]any{
+				"description": "Structured tool result",
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": tool.OutputSchema},
+				},
+			}
+		}
+		paths["/tools/"+tool.Name] = map[string]any{
+			"post": map[string]any{
+				"operationId":       tool.Name,
+				"summary":           tool.Name,
+				"description":       tool.Description,
+				"x-mcp-annotations": tool.Annotations,
+				"requestBody": map[string]any{
+					"required": true,
+					"content": map[string]any{
+						"application/json": map[string]any{"schema": tool.InputSchema},
+					},
+				},
+				"responses": responses,
+			},
+		}
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       serviceName,
+			"version":     version,
+			"description": "Tool input and output schemas. Tools are invoked via MCP tools/call; paths are descriptive.",
+		},
+		"paths": paths,
+	}
+}
+
+// dumpSchemas prints the schemas of all tools to stdout for the -dump-schemas mode
+func dumpSchemas(ctx context.Context, server *mcp.Server, format string) error {
+	document, _, err := exportToolSchemas(ctx, server, format, nil)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(document)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Share links
+type CreateShareLinkInput struct {
+	ObjectKey string `json:"object_key" jsonschema:"description:Object key of the file to share, as returned in saved_files by another tool or by upload_media"`
+	LinkTTL   string `json:"link_ttl,omitempty" jsonschema:"description:How long the link stays valid (e.g. '5m', '12h', '7d'; between 1m and 7d),default:24h"`
+}
+
+type CreateShareLinkOutput struct {
+	ObjectKey string `json:"object_key"`
+	URL       string `json:"url"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// withStorageOptions wraps a handler so that the link_ttl of its input sets
+// how long the download URLs of everything it stores stay valid, its
+// storage_target where it is stored, and its filename what the files are
+// named (see storage.WithFilename)
+func withStorageOptions[In, Out any](s *Server, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		var fields struct {
+			LinkTTL       string `json:"link_ttl"`
+			StorageTarget string `json:"storage_target"`
+			Filename      string `json:"filename"`
+		}
+		if data, err := json.Marshal(input); err == nil {
+			json.Unmarshal(data, &fields)
+		}
+		if fields.LinkTTL != "" {
+			ttl, err := storage.ParseLinkTTL(fields.LinkTTL)
+			if err != nil {
+				var zero Out
+				return nil, zero, toolerr.Errorf(toolerr.InvalidInput, "link_ttl: %w", err)
+			}
+			ctx = storage.WithLinkTTL(ctx, ttl)
+		}
+		if fields.StorageTarget != "" {
+			if err := s.validateStorageTarget(fields.StorageTarget); err != nil {
+				var zero Out
+				return nil, zero, err
+			}
+			ctx = storage.WithTarget(ctx, fields.StorageTarget)
+		}
+		if fields.Filename != "" {
+			if storage.SanitizeFilename(fields.Filename) == "" {
+				warnings.Add(ctx, "filename %q was ignored: it has no letters or digits to name a file with", fields.Filename)
+			}
+			ctx = storage.WithFilename(ctx, fields.Filename)
+		}
+		return next(ctx, req, input)
+	}
+}
+
+// validateStorageTarget checks that a storage_target names a configured
+// target
+func (s *Server) validateStorageTarget(name string) error {
+	if s.targets == nil {
+		return toolerr.Errorf(toolerr.InvalidInput, "storage_target requires STORAGE_TARGETS to be configured")
+	}
+	return s.targets.Validate(name)
+}
+
+func (s *Server) handleCreateShareLink(ctx context.Context, req *mcp.CallToolRequest, input CreateShareLinkInput) (*mcp.CallToolResult, CreateShareLinkOutput, error) {
+	if err := storage.ValidateObjectKey(input.ObjectKey); err != nil {
+		return nil, CreateShareLinkOutput{}, err
+	}
+
+	ttl := 24 * time.Hour
+	if input.LinkTTL != "" {
+		var err error
+		if ttl, err = storage.ParseLinkTTL(input.LinkTTL); err != nil {
+			return nil, CreateShareLinkOutput{}, err
+		}
+	}
+
+	url, expiresAt, err := s.storage.ShareLink(ctx, input.ObjectKey, ttl)
+	if err != nil {
+		return nil, CreateShareLinkOutput{}, fmt.Errorf("failed to create share link for %s: %w", input.ObjectKey, err)
+	}
+	log.Printf("Created share link for %s (expires %s)", input.ObjectKey, expiresAt.Format(time.RFC3339))
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: fmt.Sprintf("Share link for %s:\n%s\n\nExpires at: %s", input.ObjectKey, url, expiresAt.Format(time.RFC3339)),
+			},
+		},
+	}, CreateShareLinkOutput{
+		ObjectKey: input.ObjectKey,
+		URL:       url,
+		ExpiresAt: expiresAt.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/video"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Speech to text
+type GeminiSpeechToTextInput struct {
+	AudioPath     string `json:"audio_path" jsonschema:"description:Path to the recording. Can be a local audio (WAV, MP3, AIFF, AAC, OGG, FLAC) or video (MP4, MOV, WebM) file path or an object key returned by another tool or by upload_media."`
+	Timestamps    bool   `json:"timestamps,omitempty" jsonschema:"description:Return the start and end of each segment of the transcript,default:false"`
+	SpeakerLabels bool   `json:"speaker_labels,omitempty" jsonschema:"description:Label who is speaking in each segment (Speaker 1, Speaker 2, ... or names when they are stated in the recording),default:false"`
+	Language      string `json:"language,omitempty" jsonschema:"description:Optional language of the recording (e.g. 'en', 'German'). Detected automatically when omitted."`
+	Prompt        string `json:"prompt,omitempty" jsonschema:"description:Optional context that improves accuracy, such as the topic, speaker names or uncommon terms and spellings"`
+	Model         string `json:"model,omitempty" jsonschema:"description:Gemini model used for transcription,default:gemini-2.5-flash"`
+}
+
+// TranscriptSegment is one utterance or paragraph of a transcript
+type TranscriptSegment struct {
+	Start   string `json:"start,omitempty"`
+	End     string `json:"end,omitempty"`
+	Speaker string `json:"speaker,omitempty"`
+	Text    string `json:"text"`
+}
+
+type GeminiSpeechToTextOutput struct {
+	AudioPath   string              `json:"audio_path"`
+	Model       string              `json:"model"`
+	Language    string              `json:"language,omitempty"`
+	Transcript  string              `json:"transcript"`
+	Segments    []TranscriptSegment `json:"segments,omitempty"`
+	Warnings    []string            `json:"warnings,omitempty"`
+	Tokens      *usage.Tokens       `json:"tokens,omitempty"` // Gemini tokens used by the transcription
+	GeneratedAt string              `json:"generated_at"`
+}
+
+func (s *Server) handleGeminiSpeechToText(ctx context.Context, req *mcp.CallToolRequest, input GeminiSpeechToTextInput) (*mcp.CallToolResult, GeminiSpeechToTextOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	ctx, used := usage.WithCollector(ctx)
+	if input.AudioPath == "" {
+		return nil, GeminiSpeechToTextOutput{}, toolerr.Errorf(toolerr.InvalidInput, "audio_path is required")
+	}
+
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_speech_to_text", model); err != nil {
+		return nil, GeminiSpeechToTextOutput{}, err
+	}
+
+	mimeType := audioMIMEFromPath(input.AudioPath)
+	if mimeType == "" {
+		mimeType = videoMIMEFromPath(input.AudioPath)
+	}
+	if mimeType == "" {
+		return nil, GeminiSpeechToTextOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported media format: %s (supported: .wav, .mp3, .aiff, .aac, .ogg, .flac, .mp4, .mov, .webm)", filepath.Ext(input.AudioPath))
+	}
+
+	log.Printf("Transcribing %s with model %s", input.AudioPath, model)
+
+	// Resolve input path (may download from S3)
+	localPath, cleanup, err := s.resolveInputPath(ctx, input.AudioPath)
+	if err != nil {
+		return nil, GeminiSpeechToTextOutput{}, fmt.Errorf("failed to resolve input media: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	// Recordings are often too large for inline data, so go through the Files API
+	file, deleteFile, err := s.uploadGeminiFile(ctx, localPath, mimeType)
+	if err != nil {
+		return nil, GeminiSpeechToTextOutput{}, err
+	}
+	defer deleteFile()
+
+	prompt, config := speechToTextPrompt(input)
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{genai.NewPartFromURI(file.URI, file.MIMEType), genai.NewPartFromText(prompt)}, genai.RoleUser),
+	}
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return nil, GeminiSpeechToTextOutput{}, fmt.Errorf("error transcribing: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return nil, GeminiSpeechToTextOutput{}, toolerr.Errorf(toolerr.Upstream, "no transcript was generated")
+	}
+
+	output := GeminiSpeechToTextOutput{
+		AudioPath:   input.AudioPath,
+		Model:       model,
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}
+
+	var parsed struct {
+		Language string              `json:"language"`
+		Segments []TranscriptSegment `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(response.Text()), &parsed); err != nil {
+		warnings.Add(ctx, "failed to parse the transcript as JSON, returned it as text: %v", err)
+		output.Transcript = strings.TrimSpace(response.Text())
+	} else {
+		output.Language = parsed.Language
+		output.Segments = normalizeTranscript(parsed.Segments, input)
+		output.Transcript = formatTranscript(output.Segments)
+	}
+	output.Warnings = collected.List()
+	output.Tokens = used.Tokens()
+
+	text := output.Transcript
+	if text == "" {
+		text = "No speech was detected."
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: text}},
+	}, output, nil
+}
+
+// speechToTextPrompt builds the transcription instructions and a response
+// schema that only asks for timestamps and speakers when they were requested
+func speechToTextPrompt(input GeminiSpeechToTextInput) (string, *genai.GenerateContentConfig) {
+	promptParts := []string{"Transcribe the speech in this recording verbatim, in the language it is spoken. Do not translate, summarize or correct it. Mark unintelligible passages as [inaudible]. If there is no speech, return no segments."}
+	properties := map[string]*genai.Schema{
+		"text": {Type: genai.TypeString},
+	}
+	required := []string{"text"}
+
+	if input.Timestamps {
+		promptParts = append(promptParts, "Split the transcript into segments at sentence or speaker boundaries and give each segment's start and end timestamps (MM:SS, or HH:MM:SS past one hour).")
+		properties["start"] = &genai.Schema{Type: genai.TypeString}
+		properties["end"] = &genai.Schema{Type: genai.TypeString}
+		required = append(required, "start", "end")
+	} else {
+		promptParts = append(promptParts, "Split the transcript into paragraphs.")
+	}
+	if input.SpeakerLabels {
+		promptParts = append(promptParts, "Start a new segment whenever the speaker changes and label each segment with its speaker: use a speaker's name if it is stated in the recording, otherwise 'Speaker 1', 'Speaker 2' and so on, consistently throughout.")
+		properties["speaker"] = &genai.Schema{Type: genai.TypeString}
+		required = append(required, "speaker")
+	}
+	if input.Language != "" {
+		promptParts = append(promptParts, fmt.Sprintf("The recording is in %s.", input.Language))
+	}
+	promptParts = append(promptParts, "Report the language of the recording as an ISO 639-1 code.")
+	if input.Prompt != "" {
+		promptParts = append(promptParts, "Context: "+input.Prompt)
+	}
+
+	config := &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema: &genai.Schema{
+			Type: genai.TypeObject,
+			Properties: map[string]*genai.Schema{
+				"language": {Type: genai.TypeString},
+				"segments": {
+					Type: genai.TypeArray,
+					Items: &genai.Schema{
+						Type:       genai.TypeObject,
+						Properties: properties,
+						Required:   required,
+					},
+				},
+			},
+			Required: []string{"language", "segments"},
+		},
+	}
+	return strings.Join(promptParts, "\n"), config
+}
+
+// normalizeTranscript drops empty segments and fields that were not
+// requested, and rewrites timestamps in the MM:SS / HH:MM:SS form used by the
+// video tools
+func normalizeTranscript(segments []TranscriptSegment, input GeminiSpeechToTextInput) []TranscriptSegment {
+	result := make([]TranscriptSegment, 0, len(segments))
+	for _, segment := range segments {
+		segment.Text = strings.TrimSpace(segment.Text)
+		if segment.Text == "" {
+			continue
+		}
+		if input.Timestamps {
+			if d, ok := video.ParseTimestamp(segment.Start); ok {
+				segment.Start = video.FormatTimestamp(d)
+			}
+			if d, ok := video.ParseTimestamp(segment.End); ok {
+				segment.End = video.FormatTimestamp(d)
+			}
+		} else {
+			segment.Start, segment.End = "", ""
+		}
+		if !input.SpeakerLabels {
+			segment.Speaker = ""
+		}
+		result = append(result, segment)
+	}
+	return result
+}
+
+// formatTranscript renders segments as text, one per line, prefixed with
+// their time range and speaker when present
+func formatTranscript(segments []TranscriptSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if segment.Start != "" {
+			fmt.Fprintf(&b, "[%s - %s] ", segment.Start, segment.End)
+		}
+		if segment.Speaker != "" {
+			fmt.Fprintf(&b, "%s: ", segment.Speaker)
+		}
+		b.WriteString(segment.Text)
+	}
+	return b.String()
+}
+
+// audioMIMEFromPath returns the MIME type of the audio formats Gemini accepts,
+// or "" for other files
+func audioMIMEFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".wav":
+		return "audio/wav"
+	case ".mp3":
+		return "audio/mp3"
+	case ".aiff", ".aif":
+		return "audio/aiff"
+	case ".aac":
+		return "audio/aac"
+	case ".ogg", ".oga":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Grid splitting
+type SplitGridInput struct {
+	ImagePath     string `json:"image_path" jsonschema:"description:Path to the grid or sprite-sheet image to split. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Rows          int    `json:"rows" jsonschema:"description:Number of rows in the grid (1-16)"`
+	Cols          int    `json:"cols" jsonschema:"description:Number of columns in the grid (1-16)"`
+	Gutter        int    `json:"gutter,omitempty" jsonschema:"description:Optional. Width in pixels of the gap between cells (and around the grid edge) that should be discarded,default:0"`
+	LinkTTL       string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type GridTile struct {
+	Row         int    `json:"row"`
+	Col         int    `json:"col"`
+	ObjectKey   string `json:"object_key"`
+	DownloadURL string `json:"download_url,omitempty"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+}
+
+type SplitGridOutput struct {
+	SourceImage  string            `json:"source_image"`
+	Rows         int               `json:"rows"`
+	Cols         int               `json:"cols"`
+	Tiles        []GridTile        `json:"tiles"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	Thumbnails   map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+const maxGridDimension = 16
+
+func (s *Server) handleSplitGrid(ctx context.Context, req *mcp.CallToolRequest, input SplitGridInput) (*mcp.CallToolResult, SplitGridOutput, error) {
+	if input.ImagePath == "" {
+		return nil, SplitGridOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+	if input.Rows < 1 || input.Rows > maxGridDimension {
+		return nil, SplitGridOutput{}, toolerr.Errorf(toolerr.InvalidInput, "rows must be between 1 and %d", maxGridDimension)
+	}
+	if input.Cols < 1 || input.Cols > maxGridDimension {
+		return nil, SplitGridOutput{}, toolerr.Errorf(toolerr.InvalidInput, "cols must be between 1 and %d", maxGridDimension)
+	}
+	if input.Rows*input.Cols < 2 {
+		return nil, SplitGridOutput{}, toolerr.Errorf(toolerr.InvalidInput, "grid must contain at least 2 cells")
+	}
+
+	log.Printf("Splitting image %s into %dx%d grid (gutter: %d)", input.ImagePath, input.Rows, input.Cols, input.Gutter)
+
+	// Resolve input image path (may download from S3)
+	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+	if err != nil {
+		return nil, SplitGridOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	imgData, err := os.ReadFile(localImagePath)
+	if err != nil {
+		return nil, SplitGridOutput{}, fmt.Errorf("failed to read input image: %w", err)
+	}
+
+	img, _, err := imaging.Decode(imgData)
+	if err != nil {
+		return nil, SplitGridOutput{}, err
+	}
+
+	tiles, err := imaging.SplitGrid(img, input.Rows, input.Cols, input.Gutter)
+	if err != nil {
+		return nil, SplitGridOutput{}, err
+	}
+
+	var gridTiles []GridTile
+	var savedFiles []string
+	var dataURIs map[string]string
+	var thumbnails map[string]string
+	var downloadURLs []string
+	var expiresAt string
+	var imageContents []mcp.Content
+	timestamp := time.Now().Format("20060102_150405")
+
+	for _, tile := range tiles {
+		tileData, err := imaging.EncodePNG(tile.Image)
+		if err != nil {
+			return nil, SplitGridOutput{}, fmt.Errorf("failed to encode tile r%d c%d: %w", tile.Row+1, tile.Col+1, err)
+		}
+
+		result, err := s.storage.Store(ctx, tileData, "image/png", fmt.Sprintf("grid_tile_r%dc%d", tile.Row+1, tile.Col+1))
+		if err != nil {
+			return nil, SplitGridOutput{}, fmt.Errorf("failed to store tile r%d c%d: %w", tile.Row+1, tile.Col+1, err)
+		}
+
+		gridTile := GridTile{
+			Row:       tile.Row + 1,
+			Col:       tile.Col + 1,
+			ObjectKey: result.ObjectKey,
+			Width:     tile.Image.Bounds().Dx(),
+			Height:    tile.Image.Bounds().Dy(),
+		}
+		savedFiles = append(savedFiles, result.ObjectKey)
+		dataURIs = s.addDataURI(dataURIs, result, tileData)
+		thumbnails = addThumbnail(thumbnails, result)
+
+		if s.storage.IsRemote() {
+			// For S3: return presigned URL
+			gridTile.DownloadURL = result.Location
+			downloadURLs = append(downloadURLs, result.Location)
+			if result.ExpiresAt != nil && expiresAt == "" {
+				expiresAt = result.ExpiresAt.Format(time.RFC3339)
+			}
+		} else {
+			// For local storage: return inline image or resource link
+			imageContents = append(imageContents, s.mediaContent(tileData, result)...)
+		}
+		gridTiles = append(gridTiles, gridTile)
+	}
+
+	log.Printf("Stored %d grid tiles from %s", len(gridTiles), input.ImagePath)
+
+	metadata := map[string]string{
+		"source_image": input.ImagePath,
+		"source_size":  fmt.Sprintf("%dx%d", img.Bounds().Dx(), img.Bounds().Dy()),
+		"gutter":       fmt.Sprintf("%d", input.Gutter),
+	}
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := fmt.Sprintf("Split image into %d tiles (%d rows x %d cols). Download URLs:\n", len(gridTiles), input.Rows, input.Cols)
+		for _, tile := range gridTiles {
+			contentText += fmt.Sprintf("r%d c%d. %s\n", tile.Row, tile.Col, tile.DownloadURL)
+		}
+		if expiresAt != "" {
+			contentText += fmt.Sprintf("\nURLs expire at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else if len(imageContents) > 0 {
+		result = &mcp.CallToolResult{
+			Content: imageContents,
+		}
+	}
+
+	return result, SplitGridOutput{
+		SourceImage:  input.ImagePath,
+		Rows:         input.Rows,
+		Cols:         input.Cols,
+		Tiles:        gridTiles,
+		SavedFiles:   savedFiles,
+		DataURIs:     dataURIs,
+		Thumbnails:   thumbnails,
+		DownloadURLs: downloadURLs,
+		ExpiresAt:    expiresAt,
+		Metadata:     metadata,
+		GeneratedAt:  timestamp,
+	}, nil
+}
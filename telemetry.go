@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// telemetryMiddleware counts tool calls and the error codes of failed ones
+// for the anonymous usage statistics, when they are enabled. Only the names
+// of called tools and error codes are recorded, never arguments or results.
+func (s *Server) telemetryMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if s.telemetry == nil || method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		result, err := next(ctx, method, req)
+		res, ok := result.(*mcp.CallToolResult)
+		switch {
+		case err != nil || !ok || res == nil:
+			// Unknown tools and malformed requests: the name is client input
+			s.telemetry.Record("invalid_request", "invalid_request")
+		case res.IsError:
+			code := "unknown"
+			if info, ok := res.Meta["error"].(toolerr.Info); ok {
+				code = string(info.Code)
+			}
+			s.telemetry.Record(req.(*mcp.CallToolRequest).Params.Name, code)
+		default:
+			s.telemetry.Record(req.(*mcp.CallToolRequest).Params.Name, "")
+		}
+		return result, err
+	}
+}
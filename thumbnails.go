@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"strings"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/warnings"
+)
+
+// thumbnailPrefix is prepended to the prefix of stored previews
+const thumbnailPrefix = "thumb/"
+
+// thumbnailStorage stores a small JPEG preview next to every image and video
+// and attaches it to the StorageResult, so clients can show previews without
+// downloading full-size assets. Failing to create a preview never fails the
+// store itself.
+type thumbnailStorage struct {
+	storage.Storage
+	maxDim int
+	media  *media.Toolkit
+}
+
+// Store saves content and, for images and videos, a preview of it
+func (t *thumbnailStorage) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*storage.StorageResult, error) {
+	result, err := t.Storage.Store(ctx, data, mimeType, prefix)
+	if err != nil || strings.HasPrefix(prefix, thumbnailPrefix) {
+		return result, err
+	}
+
+	thumb, err := t.preview(ctx, data, mimeType)
+	if err != nil {
+		warnings.Add(ctx, "no thumbnail for %s: %v", result.ObjectKey, err)
+		return result, nil
+	}
+	if thumb == nil {
+		return result, nil
+	}
+	// Previews of objects with stable keys get stable keys of their own, e.g.
+	// "thumb/project/hero_latest.jpg"; previews of named objects keep
+	// hash-based keys below thumb/
+	thumbCtx := storage.WithFilename(ctx, "")
+	if result.VersionKey != "" {
+		hint := strings.TrimPrefix(result.ObjectKey, storage.TenantPrefix(ctx))
+		hint = strings.TrimSuffix(hint, storage.LatestSuffix+storage.ExtensionFromMIME(mimeType))
+		thumbCtx = storage.WithKeyHint(thumbCtx, thumbnailPrefix+hint)
+	}
+	if result.Thumbnail, err = t.Storage.Store(thumbCtx, thumb, "image/jpeg", thumbnailPrefix+prefix); err != nil {
+		warnings.Add(ctx, "failed to store thumbnail for %s: %v", result.ObjectKey, err)
+	}
+	return result, nil
+}
+
+// preview renders the JPEG preview of an asset, or returns nil if the asset
+// needs none (non-visual content, or images already within the preview size)
+func (t *thumbnailStorage) preview(ctx context.Context, data []byte, mimeType string) ([]byte, error) {
+	switch {
+	case isRasterMIME(mimeType):
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err == nil && cfg.Width <= t.maxDim && cfg.Height <= t.maxDim {
+			return nil, nil
+		}
+		return imaging.Thumbnail(data, t.maxDim)
+	case strings.HasPrefix(mimeType, "video/"):
+		// Without ffmpeg, videos get a placeholder poster read from the MP4
+		// container; WebM videos get none
+		if t.media.Backend(media.OpVideoThumbnail) != media.FFmpeg && mimeType == "video/webm" {
+			return nil, nil
+		}
+		thumb, _, err := t.media.Thumbnail(ctx, data, storage.ExtensionFromMIME(mimeType), t.maxDim)
+		return thumb, err
+	default:
+		return nil, nil
+	}
+}
+
+// addThumbnail records where the preview of a stored asset can be fetched,
+// keyed by the asset's object key: a download URL for remote storage or a
+// local path otherwise. Like append, it returns the possibly newly allocated map.
+func addThumbnail(thumbs map[string]string, result *storage.StorageResult) map[string]string {
+	if result.Thumbnail == nil {
+		return thumbs
+	}
+	if thumbs == nil {
+		thumbs = make(map[string]string)
+	}
+	thumbs[result.ObjectKey] = result.Thumbnail.Location
+	return thumbs
+}
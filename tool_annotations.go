@@ -0,0 +1,88 @@
+package main
+
+import (
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// toolAnnotations are the behavior hints of each tool, so that clients which
+// honor them can approve safe calls without asking and confirm destructive
+// ones. Tools missing here get the protocol defaults: destructive and open
+// world.
+var toolAnnotations = map[string]*mcp.ToolAnnotations{
+	// Gemini, Imagen, Veo and Lyria generations: each call bills the API and
+	// stores new assets
+	"gemini_image_generation": generationTool(),
+	"gemini_image_edit":       generationTool(),
+	"imagen_edit":             generationTool(),
+	"gemini_multi_image":      generationTool(),
+	"gemini_image_batch":      generationTool(),
+	"revise_image":            generationTool(),
+	"generate_icon_set":       generationTool(),
+	"generate_depth_map":      generationTool(),
+	"generate_panorama":       generationTool(),
+	"veo_text_to_video":       generationTool(),
+	"veo_image_to_video":      generationTool(),
+	"veo_generate_video":      generationTool(),
+	"veo_interpolate":         generationTool(),
+	"gemini_music_generation": generationTool(),
+	"gemini_object_detection": generationTool(),
+	"detect_scenes":           generationTool(),
+	"gemini_chat":             generationTool(),
+	"live_session_start":      generationTool(),
+	"live_session_send":       generationTool(),
+	"run_pipeline":            generationTool(),
+	"schedule_job":            generationTool(),
+	"benchmark":               generationTool(),
+
+	// Analysis with Gemini that stores nothing
+	"gemini_video_analysis":    readOnlyTool(true),
+	"gemini_ocr":               readOnlyTool(true),
+	"gemini_pdf_understanding": readOnlyTool(true),
+	"gemini_speech_to_text":    readOnlyTool(true),
+	"veo_job_status":           readOnlyTool(true),
+
+	// Local processing of stored media into new assets
+	"split_grid":      processingTool(),
+	"vectorize_image": processingTool(),
+	"video_trim":      processingTool(),
+	"video_concat":    processingTool(),
+
+	// Information about the server and its storage
+	"export_tool_schemas": readOnlyTool(false),
+	"server_capabilities": readOnlyTool(false),
+	"list_scheduled":      readOnlyTool(false),
+	"create_share_link":   readOnlyTool(false),
+	"get_media_info":      readOnlyTool(false),
+	"get_quota":           readOnlyTool(false),
+	"upload_media":        readOnlyTool(false),
+
+	// Deleted media is gone for good once purged from the trash; deleting
+	// twice has no further effect
+	"delete_media":      destructiveTool(),
+	"purge_media":       destructiveTool(),
+	"live_session_stop": destructiveTool(),
+
+	// Moving trashed media back never replaces an existing object
+	"restore_media": {DestructiveHint: genai.Ptr(false), IdempotentHint: true, OpenWorldHint: genai.Ptr(false)},
+}
+
+// generationTool calls a model and adds new assets without changing existing ones
+func generationTool() *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{DestructiveHint: genai.Ptr(false), OpenWorldHint: genai.Ptr(true)}
+}
+
+// processingTool adds new assets computed locally from existing ones
+func processingTool() *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{DestructiveHint: genai.Ptr(false), OpenWorldHint: genai.Ptr(false)}
+}
+
+// readOnlyTool changes nothing; openWorld marks tools that call the Gemini API
+func readOnlyTool(openWorld bool) *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{ReadOnlyHint: true, OpenWorldHint: genai.Ptr(openWorld)}
+}
+
+// destructiveTool removes stored media or state
+func destructiveTool() *mcp.ToolAnnotations {
+	return &mcp.ToolAnnotations{DestructiveHint: genai.Ptr(true), IdempotentHint: true, OpenWorldHint: genai.Ptr(false)}
+}
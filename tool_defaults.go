@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"gemini-mcp/internal/defaults"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// installToolDefaults reads DEFAULTS_<TOOL>_<ARGUMENT> overrides for the tools
+// registered on server and, if there are any, adds the middleware that fills
+// them into tool calls. Arguments a client sets always take precedence, and
+// tool-specific defaults still apply to arguments that are not overridden.
+// Tools called by the server itself, such as pipeline steps, get them from
+// s.toolDefaults (see applyToolDefaults).
+func (s *Server) installToolDefaults(ctx context.Context, server *mcp.Server) error {
+	tools, err := listRegisteredTools(ctx, server)
+	if err != nil {
+		return err
+	}
+	types := make(defaults.ArgumentTypes, len(tools))
+	for _, tool := range tools {
+		types[tool.Name] = defaults.SchemaTypes(tool.InputSchema)
+	}
+
+	overrides, warnings := defaults.Parse(os.Environ(), types)
+	for _, warning := range warnings {
+		log.Printf("Warning: ignoring %s", warning)
+	}
+	if len(overrides) == 0 {
+		return nil
+	}
+	s.toolDefaults = overrides
+
+	names := make([]string, 0, len(overrides))
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		log.Printf("Default overrides for %s: %v", name, overrides[name])
+	}
+
+	server.AddReceivingMiddleware(func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if call, ok := req.(*mcp.CallToolRequest); ok && call.Params != nil {
+				args, err := overrides.Apply(call.Params.Name, call.Params.Arguments)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", call.Params.Name, err)
+				}
+				call.Params.Arguments = args
+			}
+			return next(ctx, method, req)
+		}
+	})
+	return nil
+}
+
+// applyToolDefaults fills the DEFAULTS_<TOOL>_<ARGUMENT> overrides of tool
+// into the arguments of a call that does not pass through the MCP server,
+// such as a pipeline step or an item of a batch
+func applyToolDefaults[In any](s *Server, tool string, input In) (In, error) {
+	if len(s.toolDefaults[tool]) == 0 {
+		return input, nil
+	}
+	data, err := json.Marshal(input)
+	if err != nil {
+		return input, toolerr.Errorf(toolerr.InvalidInput, "invalid arguments: %w", err)
+	}
+	if data, err = s.toolDefaults.Apply(tool, data); err != nil {
+		return input, toolerr.Errorf(toolerr.InvalidInput, "%s: %w", tool, err)
+	}
+	var filled In
+	if err := json.Unmarshal(data, &filled); err != nil {
+		return input, toolerr.Errorf(toolerr.InvalidInput, "invalid arguments: %w", err)
+	}
+	return filled, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// addTool registers a tool whose errors are classified (see withToolErrors),
+// whose stored assets are returned as resource links (see withResourceLinks)
+// and which carries its behavior hints (see toolAnnotations)
+func addTool[In, Out any](server *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, Out]) {
+	if tool.Annotations == nil {
+		tool.Annotations = toolAnnotations[tool.Name]
+	}
+	mcp.AddTool(server, tool, withToolErrors(withResourceLinks(handler)))
+}
+
+type toolErrorKey struct{}
+
+// withToolErrors wraps a tool handler so that the error of a failed call is
+// classified and recorded for toolErrorMiddleware
+func withToolErrors[In, Out any](next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		result, output, err := next(ctx, req, input)
+		if err == nil {
+			return result, output, nil
+		}
+		classified := toolerr.Classify(err)
+		if recorded, ok := ctx.Value(toolErrorKey{}).(**toolerr.Error); ok {
+			*recorded = classified
+		}
+		log.Printf("Tool %s failed (%s): %v", req.Params.Name, classified.Code, err)
+		return result, output, classified
+	}
+}
+
+// toolErrorMiddleware reports the errors of failed tool calls with their
+// code, whether retrying can help and how long to wait first: in the
+// result's _meta.error for clients, and at the end of its text for models
+func toolErrorMiddleware(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		if method != "tools/call" {
+			return next(ctx, method, req)
+		}
+		var classified *toolerr.Error
+		result, err := next(context.WithValue(ctx, toolErrorKey{}, &classified), method, req)
+		if res, ok := result.(*mcp.CallToolResult); ok && res != nil && res.IsError && classified != nil {
+			if res.Meta == nil {
+				res.Meta = mcp.Meta{}
+			}
+			res.Meta["error"] = classified.Info()
+			hint := fmt.Sprintf("Error code: %s", classified.Code)
+			switch {
+			case classified.Retryable && classified.RetryAfter > 0:
+				hint += fmt.Sprintf(" (retryable after %s)", classified.RetryAfter.Round(time.Second))
+			case classified.Retryable:
+				hint += " (retryable)"
+			}
+			res.Content = append(res.Content, &mcp.TextContent{Text: hint})
+		}
+		return result, err
+	}
+}
+
+// classifiedStorage classifies the errors of a storage backend: missing
+// objects are invalid input, other failures storage errors
+type classifiedStorage struct {
+	storage.Storage
+}
+
+func (c classifiedStorage) Store(ctx context.Context, data []byte, mimeType string, prefix string) (*storage.StorageResult, error) {
+	result, err := c.Storage.Store(ctx, data, mimeType, prefix)
+	return result, storageError(err)
+}
+
+func (c classifiedStorage) Retrieve(ctx context.Context, objectKey string) (string, func(), error) {
+	localPath, cleanup, err := c.Storage.Retrieve(ctx, objectKey)
+	return localPath, cleanup, storageError(err)
+}
+
+func (c classifiedStorage) Delete(ctx context.Context, objectKey string) error {
+	return storageError(c.Storage.Delete(ctx, objectKey))
+}
+
+func (c classifiedStorage) Move(ctx context.Context, objectKey, newKey string) error {
+	return storageError(c.Storage.Move(ctx, objectKey, newKey))
+}
+
+func (c classifiedStorage) List(ctx context.Context, prefix string) ([]storage.ObjectInfo, error) {
+	objects, err := c.Storage.List(ctx, prefix)
+	return objects, storageError(err)
+}
+
+func (c classifiedStorage) ShareLink(ctx context.Context, objectKey string, ttl time.Duration) (string, time.Time, error) {
+	url, expiresAt, err := c.Storage.ShareLink(ctx, objectKey, ttl)
+	return url, expiresAt, storageError(err)
+}
+
+// storageError classifies an error of a storage backend
+func storageError(err error) error {
+	switch {
+	case err == nil, errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return err
+	case errors.Is(err, storage.ErrNotFound):
+		return toolerr.Wrap(toolerr.InvalidInput, err)
+	default:
+		return toolerr.Wrap(toolerr.StorageError, err)
+	}
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/tempfiles"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/upload"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// multipartOverhead is the room left for the boundaries and headers of a
+// multipart upload on top of MAX_UPLOAD_BYTES
+const multipartOverhead = 1 << 20
+
+// newUploadManager keeps the partial data of resumable uploads of up to
+// maxBytes in the uploads directory of temp, and removes the data that a
+// crash left behind there
+func newUploadManager(temp *tempfiles.Manager, ttl time.Duration, maxBytes int64) *upload.Manager {
+	uploads := upload.NewManager(filepath.Join(temp.Dir(), "uploads"), ttl, maxBytes)
+	if n := uploads.RemoveOrphans(time.Now()); n > 0 {
+		log.Printf("Removed %d orphaned partial upload(s) older than %v", n, ttl)
+	}
+	return uploads
+}
+
+// writeUploadTooLarge answers an upload over MAX_UPLOAD_BYTES
+func writeUploadTooLarge(w http.ResponseWriter, maxBytes int) {
+	http.Error(w, fmt.Sprintf(`{"error":"File exceeds the upload limit of %d bytes (MAX_UPLOAD_BYTES). Compress or trim it before uploading."}`, maxBytes), http.StatusRequestEntityTooLarge)
+}
+
+// uploadMIMEType returns the Content-Type of an uploaded file, or the type
+// its extension implies when the client sent none
+func uploadMIMEType(contentType, filename string) string {
+	if contentType != "" && contentType != "application/octet-stream" {
+		return contentType
+	}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".webp":
+		return "image/webp"
+	case ".mp4":
+		return "video/mp4"
+	case ".webm":
+		return "video/webm"
+	case ".mov":
+		return "video/quicktime"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// storeUpload stores an uploaded file and writes the upload result, with the
+// fields of extra added. It returns false if the file could not be stored.
+func (s *Server) storeUpload(ctx context.Context, w http.ResponseWriter, filename, contentType string, data []byte, extra map[string]interface{}) bool {
+	mimeType := uploadMIMEType(contentType, filename)
+
+	// Phone photos often carry an EXIF rotation that Gemini ignores
+	data = normalizeOrientation(data, filename)
+
+	log.Printf("Uploading file: %s (%s, %d bytes)", filename, mimeType, len(data))
+
+	result, err := s.storage.Store(ctx, data, mimeType, "upload")
+	if err != nil {
+		log.Printf("Failed to store file: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to store file: %v"}`, err), http.StatusInternalServerError)
+		return false
+	}
+
+	log.Printf("File uploaded successfully: %s", result.ObjectKey)
+
+	// Build response
+	timestamp := time.Now().Format("20060102_150405")
+	var expiresAt string
+	if result.ExpiresAt != nil {
+		expiresAt = result.ExpiresAt.Format(time.RFC3339)
+	}
+
+	response := map[string]interface{}{
+		"object_key":   result.ObjectKey,
+		"download_url": result.Location,
+		"mime_type":    mimeType,
+		"size":         result.Size,
+		"message":      "Upload successful",
+		"uploaded_at":  timestamp,
+	}
+	if expiresAt != "" {
+		response["expires_at"] = expiresAt
+	}
+	if result.Thumbnail != nil {
+		response["thumbnail_url"] = result.Thumbnail.Location
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+	return true
+}
+
+// uploadStatus is the JSON state of a resumable upload
+type uploadStatus struct {
+	UploadID  string `json:"upload_id"`
+	UploadURL string `json:"upload_url"`
+	Filename  string `json:"filename"`
+	Offset    int64  `json:"offset"` // Bytes received; the next chunk starts here
+	Length    int64  `json:"length"`
+	ExpiresAt string `json:"expires_at"`
+	Error     string `json:"error,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// writeUploadStatus writes the state of a resumable upload, also as
+// Upload-Offset and Upload-Length headers
+func writeUploadStatus(w http.ResponseWriter, status int, session upload.Session, errMsg, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(session.Length, 10))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(uploadStatus{
+		UploadID:  session.ID,
+		UploadURL: "/upload/" + session.ID,
+		Filename:  session.Filename,
+		Offset:    session.Offset,
+		Length:    session.Length,
+		ExpiresAt: session.ExpiresAt.Format(time.RFC3339),
+		Error:     errMsg,
+		Message:   message,
+	})
+}
+
+// createResumableUpload starts an upload of Upload-Length bytes named by
+// Upload-Filename. The returned upload ID authenticates the chunks, so the
+// one-time token is not needed to resume.
+func (s *Server) createResumableUpload(w http.ResponseWriter, r *http.Request, tenant string) {
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"Upload-Length must be the file size in bytes"}`, http.StatusBadRequest)
+		return
+	}
+	filename := r.Header.Get("Upload-Filename")
+	if filename == "" {
+		http.Error(w, `{"error":"Upload-Filename header required"}`, http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.uploads.Create(filename, tenant, length)
+	if errors.Is(err, upload.ErrLimitExceeded) {
+		log.Printf("Refused resumable upload of %s: %v", filename, err)
+		writeUploadTooLarge(w, s.config.MaxUploadBytes)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to create resumable upload: %v", err)
+		http.Error(w, fmt.Sprintf(`{"error":%q}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Resumable upload %s... started: %s (%d bytes)", session.ID[:8], session.Filename, session.Length)
+
+	w.Header().Set("Location", "/upload/"+session.ID)
+	writeUploadStatus(w, http.StatusCreated, session, "", "Upload created; send the file with PATCH requests to upload_url, each starting at offset")
+}
+
+// handleUploadStatus reports how many bytes of a resumable upload arrived
+func (s *Server) handleUploadStatus(w http.ResponseWriter, r *http.Request) {
+	session, err := s.uploads.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, `{"error":"Upload not found or expired. Start a new upload with a new token."}`, http.StatusNotFound)
+		return
+	}
+	writeUploadStatus(w, http.StatusOK, session, "", "")
+}
+
+// handleUploadChunk appends the request body at Upload-Offset. Bytes that
+// arrive before a dropped connection are kept; the client asks for the
+// offset with GET and continues from there. The chunk that completes the
+// upload stores the file and returns the upload result.
+func (s *Server) handleUploadChunk(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		http.Error(w, `{"error":"Upload-Offset header must give the offset of the chunk in bytes"}`, http.StatusBadRequest)
+		return
+	}
+
+	session, err := s.uploads.Append(id, offset, r.Body)
+	switch {
+	case errors.Is(err, upload.ErrNotFound):
+		http.Error(w, `{"error":"Upload not found or expired. Start a new upload with a new token."}`, http.StatusNotFound)
+		return
+	case errors.Is(err, upload.ErrOffsetMismatch), errors.Is(err, upload.ErrBusy):
+		writeUploadStatus(w, http.StatusConflict, session, err.Error(), "")
+		return
+	case errors.Is(err, upload.ErrTooLarge):
+		writeUploadStatus(w, http.StatusRequestEntityTooLarge, session, err.Error(), "")
+		return
+	case err != nil && session.ID == "":
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to write upload: %v"}`, err), http.StatusInternalServerError)
+		return
+	case err != nil:
+		log.Printf("Resumable upload %s... interrupted at %d of %d bytes: %v", id[:min(len(id), 8)], session.Offset, session.Length, err)
+		writeUploadStatus(w, http.StatusBadRequest, session, "upload interrupted: "+err.Error(), "Resume from offset")
+		return
+	}
+	if !session.Complete() {
+		writeUploadStatus(w, http.StatusOK, session, "", "")
+		return
+	}
+
+	data, err := s.uploads.Read(id)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"Failed to read upload: %v"}`, err), http.StatusInternalServerError)
+		return
+	}
+	// Store in the storage of the tenant that requested the token. A failed
+	// store keeps the upload, so the last request can be retried.
+	ctx := storage.WithTenant(r.Context(), session.Tenant)
+	if s.storeUpload(ctx, w, session.Filename, "", data, map[string]interface{}{"upload_id": session.ID}) {
+		s.uploads.Remove(id)
+	}
+}
+
+// handleUploadCancel abandons a resumable upload and deletes its data
+func (s *Server) handleUploadCancel(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.uploads.Get(id); err != nil {
+		http.Error(w, `{"error":"Upload not found or expired"}`, http.StatusNotFound)
+		return
+	}
+	s.uploads.Remove(id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resumeUploadInstructions returns the CLI command that continues an
+// interrupted upload of the caller's tenant
+func (s *Server) resumeUploadInstructions(ctx context.Context, cliPath, uploadURL, uploadID string) (*mcp.CallToolResult, UploadMediaOutput, error) {
+	session, err := s.uploads.Get(uploadID)
+	if err != nil || session.Tenant != middleware.GetTenant(ctx) {
+		return nil, UploadMediaOutput{}, toolerr.Errorf(toolerr.InvalidInput, "upload %s not found or expired; call upload_media without upload_id to start a new upload", uploadID)
+	}
+
+	command := fmt.Sprintf("%s --server \"%s\" --resume \"%s\" <file_path>", cliPath, uploadURL, session.ID)
+	instructions := fmt.Sprintf(`Upload of %s is incomplete: %d of %d bytes arrived.
+
+To resume it, run the upload_media CLI with the same file:
+  %s
+
+Only the remaining bytes are sent. The upload can be resumed until %s.
+`, session.Filename, session.Offset, session.Length, command, session.ExpiresAt.Format(time.RFC3339))
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: instructions,
+			},
+		},
+	}, UploadMediaOutput{
+		Instructions:  instructions,
+		CLIPath:       cliPath,
+		Usage:         fmt.Sprintf("%s --server <url> --resume <upload_id> <file_path>", cliPath),
+		Example:       command,
+		UploadID:      session.ID,
+		ReceivedBytes: session.Offset,
+		TotalBytes:    session.Length,
+		ExpiresAt:     session.ExpiresAt.Format(time.RFC3339),
+	}, nil
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/toolerr"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Vectorization
+type VectorizeImageInput struct {
+	ImagePath     string  `json:"image_path" jsonschema:"description:Path to the raster image to trace. Works best with flat, logo-style or icon-style artwork with few colors. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	Colors        int     `json:"colors,omitempty" jsonschema:"description:Number of colors to quantize the image to before tracing (2-32). Use 2 for monochrome line art.,default:8"`
+	Smoothing     float64 `json:"smoothing,omitempty" jsonschema:"description:Maximum deviation in pixels allowed when simplifying traced outlines. Higher values give smaller files with smoother, less exact shapes.,default:1.0"`
+	MinArea       int     `json:"min_area,omitempty" jsonschema:"description:Discard shapes smaller than this many pixels to remove speckles and compression noise,default:4"`
+	MaxSize       int     `json:"max_size,omitempty" jsonschema:"description:Downscale the input so its longest side is at most this many pixels before tracing (64-2048),default:1024"`
+	LinkTTL       string  `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string  `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type VectorizeImageOutput struct {
+	SourceImage  string            `json:"source_image"`
+	SVGFile      string            `json:"svg_file"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Palette      []string          `json:"palette"`
+	PathCount    int               `json:"path_count"`
+	SizeBytes    int               `json:"size_bytes"`
+	SavedFiles   []string          `json:"saved_files,omitempty"`
+	DataURIs     map[string]string `json:"data_uris,omitempty"`
+	DownloadURLs []string          `json:"download_urls,omitempty"`
+	ExpiresAt    string            `json:"expires_at,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	GeneratedAt  string            `json:"generated_at"`
+}
+
+func (s *Server) handleVectorizeImage(ctx context.Context, req *mcp.CallToolRequest, input VectorizeImageInput) (*mcp.CallToolResult, VectorizeImageOutput, error) {
+	if input.ImagePath == "" {
+		return nil, VectorizeImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "image_path is required")
+	}
+
+	// Set defaults
+	colors := input.Colors
+	if colors == 0 {
+		colors = 8
+	}
+	if colors < 2 || colors > 32 {
+		return nil, VectorizeImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "colors must be between 2 and 32")
+	}
+
+	smoothing := input.Smoothing
+	if smoothing <= 0 {
+		smoothing = 1.0
+	}
+
+	minArea := input.MinArea
+	if minArea <= 0 {
+		minArea = 4
+	}
+
+	maxSize := input.MaxSize
+	if maxSize == 0 {
+		maxSize = 1024
+	}
+	if maxSize < 64 || maxSize > 2048 {
+		return nil, VectorizeImageOutput{}, toolerr.Errorf(toolerr.InvalidInput, "max_size must be between 64 and 2048")
+	}
+
+	log.Printf("Vectorizing image %s (colors: %d, smoothing: %.2f, min_area: %d)", input.ImagePath, colors, smoothing, minArea)
+
+	// Resolve input image path (may download from S3)
+	localImagePath, cleanup, err := s.resolveInputPath(ctx, input.ImagePath)
+	if err != nil {
+		return nil, VectorizeImageOutput{}, fmt.Errorf("failed to resolve input image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	imgData, err := os.ReadFile(localImagePath)
+	if err != nil {
+		return nil, VectorizeImageOutput{}, fmt.Errorf("failed to read input image: %w", err)
+	}
+
+	img, _, err := imaging.Decode(imgData)
+	if err != nil {
+		return nil, VectorizeImageOutput{}, err
+	}
+
+	traced, err := imaging.Vectorize(img, imaging.VectorizeOptions{
+		Colors:    colors,
+		Tolerance: smoothing,
+		MinArea:   minArea,
+		MaxDim:    maxSize,
+	})
+	if err != nil {
+		return nil, VectorizeImageOutput{}, fmt.Errorf("failed to vectorize image: %w", err)
+	}
+
+	// Store via storage interface
+	stored, err := s.storage.Store(ctx, traced.SVG, "image/svg+xml", "vector")
+	if err != nil {
+		return nil, VectorizeImageOutput{}, fmt.Errorf("failed to store SVG: %w", err)
+	}
+	log.Printf("Stored SVG: %s (%d paths, %d bytes)", stored.Location, traced.Paths, len(traced.SVG))
+
+	timestamp := time.Now().Format("20060102_150405")
+	var downloadURLs []string
+	var expiresAt string
+	if s.storage.IsRemote() {
+		downloadURLs = append(downloadURLs, stored.Location)
+		if stored.ExpiresAt != nil {
+			expiresAt = stored.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+
+	metadata := map[string]string{
+		"source_image": input.ImagePath,
+		"colors":       fmt.Sprintf("%d", colors),
+		"smoothing":    fmt.Sprintf("%.2f", smoothing),
+		"min_area":     fmt.Sprintf("%d", minArea),
+	}
+
+	summary := fmt.Sprintf("Vectorized image to SVG (%dx%d, %d paths, %d bytes). Palette: %s",
+		traced.Width, traced.Height, traced.Paths, len(traced.SVG), strings.Join(traced.Palette, ", "))
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := fmt.Sprintf("%s\n\nDownload URL:\n%s", summary, stored.Location)
+		if expiresAt != "" {
+			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, s.mediaContent(traced.SVG, stored)...),
+		}
+	}
+
+	return result, VectorizeImageOutput{
+		SourceImage:  input.ImagePath,
+		SVGFile:      stored.ObjectKey,
+		Width:        traced.Width,
+		Height:       traced.Height,
+		Palette:      traced.Palette,
+		PathCount:    traced.Paths,
+		SizeBytes:    len(traced.SVG),
+		SavedFiles:   []string{stored.ObjectKey},
+		DataURIs:     s.addDataURI(nil, stored, traced.SVG),
+		DownloadURLs: downloadURLs,
+		ExpiresAt:    expiresAt,
+		Metadata:     metadata,
+		GeneratedAt:  timestamp,
+	}, nil
+}
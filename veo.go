@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+const (
+	// videoPollInterval is how often a running Veo operation is checked
+	videoPollInterval = 10 * time.Second
+	// videoPollAttempts bounds polling to 10 minutes
+	videoPollAttempts = 60
+	// videoPollConcurrency caps the status checks run at once in a polling round
+	videoPollConcurrency = 8
+)
+
+// startVideoGeneration starts a Veo generation, passing negativePrompt through
+// the native NegativePrompt config field. If the model rejects the field with
+// a bad request naming it, the request is retried once with the negative
+// prompt folded into the prompt text.
+func (s *Server) startVideoGeneration(ctx context.Context, model, prompt, negativePrompt string, image *genai.Image, config *genai.GenerateVideosConfig) (*genai.GenerateVideosOperation, error) {
+	if config == nil {
+		config = &genai.GenerateVideosConfig{}
+	}
+	config.NegativePrompt = negativePrompt
+
+	operation, err := s.client.Models.GenerateVideos(ctx, model, prompt, image, config)
+	if err == nil || negativePrompt == "" || !rejectedField(err, "negativePrompt") {
+		return operation, err
+	}
+
+	warnings.Add(ctx, "model %s rejected negative_prompt (%v); it was added to the prompt instead", model, err)
+	config.NegativePrompt = ""
+	return s.client.Models.GenerateVideos(ctx, model, fmt.Sprintf("%s. Avoid: %s", prompt, negativePrompt), image, config)
+}
+
+// rejectedField reports whether err is a Gemini API bad request (400) about
+// field, the JSON name of a request field, given in a google.rpc.BadRequest
+// field violation or in the error message
+func rejectedField(err error, field string) bool {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 400 {
+		return false
+	}
+	for _, detail := range apiErr.Details {
+		violations, _ := detail["fieldViolations"].([]any)
+		for _, v := range violations {
+			violation, _ := v.(map[string]any)
+			if name, _ := violation["field"].(string); name == field || strings.HasSuffix(name, "."+field) {
+				return true
+			}
+		}
+	}
+	return strings.Contains(apiErr.Message, field)
+}
+
+// veoConfig returns the generation config for the requested aspect ratio
+// and resolution. The seed is not sent: the Gemini API rejects it.
+func veoConfig(aspectRatio, resolution string) *genai.GenerateVideosConfig {
+	return &genai.GenerateVideosConfig{
+		AspectRatio: aspectRatio,
+		Resolution:  resolution,
+	}
+}
+
+// pollVideoOperation waits for a Veo operation to finish, for at most
+// videoPollAttempts polls. Operations of all calls are checked together by
+// the server's poller (see internal/poller). It returns early with the
+// context's error when the request is cancelled; a failed status check stops
+// polling and returns the last known state of the operation.
+func (s *Server) pollVideoOperation(ctx context.Context, operation *genai.GenerateVideosOperation, label string) (*genai.GenerateVideosOperation, error) {
+	defer s.pendingOps.add(ctx, operation.Name, label)()
+	return s.videoPoller.Wait(ctx, operation, label)
+}
+
+// confirmVeoCost enforces the VEO_CONFIRM_RESOLUTIONS / VEO_CONFIRM_MODELS /
+// VEO_CONFIRM_MAX_VIDEOS guardrail for a call rendering videos videos. A
+// covered call proceeds only if it set confirm_cost or, when the client
+// supports elicitation, the user approves it; otherwise an error explains how
+// to confirm.
+func (s *Server) confirmVeoCost(ctx context.Context, req *mcp.CallToolRequest, model, resolution string, videos int, confirmed bool) error {
+	var reasons []string
+	if limit := s.config.VeoConfirmMaxVideos; limit > 0 && videos > limit {
+		reasons = append(reasons, fmt.Sprintf("%d videos", videos))
+	}
+	if containsFold(s.config.VeoConfirmResolutions, resolution) {
+		reasons = append(reasons, resolution+" resolution")
+	}
+	if containsFold(s.config.VeoConfirmModels, model) {
+		reasons = append(reasons, "model "+model)
+	}
+	if len(reasons) == 0 || confirmed {
+		return nil
+	}
+	summary := strings.Join(reasons, " and ")
+
+	if req != nil && req.Session != nil {
+		if params := req.Session.InitializeParams(); params != nil && params.Capabilities != nil && params.Capabilities.Elicitation != nil {
+			result, err := req.Session.Elicit(ctx, &mcp.ElicitParams{
+				Message: fmt.Sprintf("This Veo request uses %s, which is billed at a higher rate. Start it?", summary),
+				RequestedSchema: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"confirm": map[string]any{"type": "boolean", "title": "Start the render"},
+					},
+					"required": []string{"confirm"},
+				},
+			})
+			if err != nil {
+				log.Printf("Cost confirmation prompt failed: %v", err)
+			} else if result.Action == "accept" && result.Content["confirm"] == true {
+				log.Printf("User confirmed Veo render with %s", summary)
+				return nil
+			} else {
+				return toolerr.Errorf(toolerr.InvalidInput, "video generation with %s was not confirmed", summary)
+			}
+		}
+	}
+
+	return toolerr.Errorf(toolerr.InvalidInput, "video generation with %s requires cost confirmation: set confirm_cost to true to proceed", summary)
+}
+
+// containsFold reports whether list contains value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
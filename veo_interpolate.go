@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/i18n"
+	"gemini-mcp/internal/imaging"
+	"gemini-mcp/internal/quota"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/storage"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Veo first/last-frame interpolation
+type VeoInterpolateInput struct {
+	FirstFramePath   string `json:"first_frame_path" jsonschema:"description:Image used as the first frame of the video. Can be a local file path or an object key returned by another tool (found in saved_files) or by upload_media."`
+	LastFramePath    string `json:"last_frame_path" jsonschema:"description:Image used as the last frame of the video. Should share the aspect ratio and framing of the first frame for a smooth transition."`
+	Prompt           string `json:"prompt" jsonschema:"description:Text prompt describing the motion and events connecting the two frames (max 1024 tokens)."`
+	NegativePrompt   string `json:"negative_prompt,omitempty" jsonschema:"description:Description of what should NOT happen or appear in the video."`
+	AspectRatio      string `json:"aspect_ratio,omitempty" jsonschema:"description:Video width-to-height ratio,default:16:9,enum:16:9,enum:9:16"`
+	Resolution       string `json:"resolution,omitempty" jsonschema:"description:Video resolution. Note: 1080p only supported for 16:9 aspect ratio,default:720p,enum:720p,enum:1080p"`
+	Model            string `json:"model,omitempty" jsonschema:"description:Veo model version to use. First/last-frame interpolation requires Veo 3.1.,default:veo-3.1-generate-preview,enum:veo-3.1-generate-preview,enum:veo-3.1-fast-generate-preview"`
+	Seed             int    `json:"seed,omitempty" jsonschema:"description:Optional seed value for slight reproducibility in generation"`
+	ConfirmCost      bool   `json:"confirm_cost,omitempty" jsonschema:"description:Confirm that this render may be billed at a higher rate. Required when the server's cost guardrail covers the requested resolution, model or number of videos and the client cannot ask the user.,default:false"`
+	Async            bool   `json:"async,omitempty" jsonschema:"description:Return as soon as the generation has started instead of waiting for the video. Follow it with veo_job_status, which reports the Google-hosted video URI as soon as the video is ready and the stored object key once it has been copied to storage.,default:false"`
+	WebhookURL       string `json:"webhook_url,omitempty" jsonschema:"description:Optional URL that receives a signed POST when this generation completes or fails. Overrides the server's WEBHOOK_URL."`
+	LinkTTL          string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+	Filename         string `json:"filename,omitempty" jsonschema:"description:Optional. Name for the stored file, e.g. 'hero_banner_v3' gives 'hero_banner_v3.png' instead of a content hash based name. The extension follows the file's format and characters other than letters, digits, '-' and '_' are replaced. If a different file already has the name, the content hash is appended. Further files of the call get '_2', '_3', ... appended."`
+	ResponseLanguage string `json:"response_language,omitempty" jsonschema:"description:Optional. Language of the human-readable text in the result, such as status messages and errors (en, es, ja, zh or hi; tags like es-MX are accepted). Overrides the server's RESPONSE_LANGUAGE."`
+}
+
+func (s *Server) handleVeoInterpolate(ctx context.Context, req *mcp.CallToolRequest, input VeoInterpolateInput) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+	if input.FirstFramePath == "" || input.LastFramePath == "" {
+		return nil, VeoGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "first_frame_path and last_frame_path are required"))
+	}
+	if input.Prompt == "" {
+		return nil, VeoGenerationOutput{}, toolerr.Wrap(toolerr.InvalidInput, i18n.Errorf(ctx, "prompt is required"))
+	}
+
+	// Set defaults
+	aspectRatio := input.AspectRatio
+	if aspectRatio == "" {
+		aspectRatio = "16:9"
+	}
+
+	resolution := input.Resolution
+	if resolution == "" {
+		resolution = "720p"
+	}
+
+	model := input.Model
+	if model == "" {
+		// First/last-frame interpolation needs Veo 3.1
+		model = s.config.VeoDefaultModel
+		if !strings.HasPrefix(model, "veo-3.1-") {
+			model = "veo-3.1-generate-preview"
+		}
+	}
+	if err := s.allowlist.Check("veo_interpolate", model); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	if err := s.confirmVeoCost(ctx, req, model, resolution, 1, input.ConfirmCost); err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+
+	firstFrame, err := s.loadVeoImage(ctx, input.FirstFramePath)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("first frame: %w", err)
+	}
+	lastFrame, err := s.loadVeoImage(ctx, input.LastFramePath)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("last frame: %w", err)
+	}
+
+	log.Printf("Generating interpolated video with model %s from %s to %s, prompt: %s (aspect: %s, resolution: %s)",
+		model, input.FirstFramePath, input.LastFramePath, input.Prompt, aspectRatio, resolution)
+
+	timestamp := time.Now().Format("20060102_150405")
+
+	config := &genai.GenerateVideosConfig{
+		AspectRatio: aspectRatio,
+		Resolution:  resolution,
+		LastFrame:   lastFrame,
+	}
+	if input.Seed > 0 {
+		seed := int32(input.Seed)
+		config.Seed = &seed
+	}
+
+	// Wait for a free video generation slot (held while polling)
+	release, err := s.acquireGeneration(ctx, quota.Videos)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, err
+	}
+	defer func() { release() }()
+
+	operation, err := s.startVideoGeneration(ctx, model, input.Prompt, input.NegativePrompt, firstFrame, config)
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("error starting interpolation: %w", err)
+	}
+
+	operationID := operation.Name
+	log.Printf("Interpolation started with operation ID: %s", operationID)
+
+	if input.Async {
+		// The job takes over the generation slot until it is done
+		result := s.startVideoJob(ctx, "veo_interpolate", "interpolation", "veo_interpolate", operation, "", input.WebhookURL, release)
+		release = func() {}
+		return result, VeoGenerationOutput{
+			GenerationResult: GenerationResult{Status: "generating"},
+			OperationID:      operationID,
+			Model:            model,
+			AspectRatio:      aspectRatio,
+			Resolution:       resolution,
+			GeneratedAt:      timestamp,
+			EstimatedLength:  "8 seconds",
+		}, nil
+	}
+
+	// Poll operation status until completion or cancellation
+	operation, err = s.pollVideoOperation(ctx, operation, "interpolation")
+	if err != nil {
+		return nil, VeoGenerationOutput{}, fmt.Errorf("interpolation %s cancelled: %w", operationID, err)
+	}
+
+	var savedFiles []string
+	var stored []*storage.StorageResult
+	var dataURIs map[string]string
+	var thumbnails map[string]string
+	var downloadURLs []string
+	var expiresAt string
+	var videoURL string
+	var videoContents []mcp.Content
+	var safetyFeedback *safety.Feedback
+	status := "generating"
+
+	if operation.Done {
+		if operation.Error != nil {
+			status = "failed"
+			warnings.Add(ctx, "video generation failed: %v", operation.Error)
+		} else if operation.Response != nil && len(operation.Response.GeneratedVideos) > 0 {
+			status = "completed"
+			video := operation.Response.GeneratedVideos[0]
+			log.Printf("Interpolation completed successfully")
+
+			// Download the video file
+			downloadURI := genai.NewDownloadURIFromVideo(video.Video)
+			videoData, err := s.client.Files.Download(ctx, downloadURI, nil)
+			if err != nil {
+				warnings.Add(ctx, "failed to download video: %v", err)
+			} else {
+				// Store via storage interface
+				result, err := s.storage.Store(ctx, videoData, "video/mp4", "veo_interpolate")
+				if err != nil {
+					warnings.Add(ctx, "failed to store video: %v", err)
+				} else {
+					savedFiles = append(savedFiles, result.ObjectKey)
+					stored = append(stored, result)
+					dataURIs = s.addDataURI(dataURIs, result, videoData)
+					thumbnails = addThumbnail(thumbnails, result)
+					videoURL = result.Location
+					log.Printf("Stored interpolated video: %s", result.Location)
+
+					if s.storage.IsRemote() {
+						downloadURLs = append(downloadURLs, result.Location)
+						if result.ExpiresAt != nil {
+							expiresAt = result.ExpiresAt.Format(time.RFC3339)
+						}
+					} else {
+						// For local storage: return a resource link to the video file
+						videoContents = s.mediaContent(videoData, result)
+					}
+				}
+			}
+		} else if feedback := safety.FromVideosResponse(operation.Response); feedback != nil && feedback.Blocked {
+			status = "blocked"
+			safetyFeedback = feedback
+			log.Printf("Video generation blocked by safety filters: %s", feedback.Summary())
+		}
+	} else {
+		status = "timeout"
+		warnings.Add(ctx, "video generation did not finish within 10 minutes")
+	}
+
+	// Create metadata
+	metadata := map[string]string{
+		"generation_type": "interpolation",
+		"first_frame":     input.FirstFramePath,
+		"last_frame":      input.LastFramePath,
+		"original_prompt": input.Prompt,
+		"negative_prompt": input.NegativePrompt,
+		"operation_id":    operationID,
+	}
+
+	if input.Seed > 0 {
+		metadata["seed"] = fmt.Sprintf("%d", input.Seed)
+	}
+
+	// Drop what was stored if the client cancelled while it was being produced
+	if ctx.Err() != nil {
+		s.discardStored(ctx, stored)
+		return nil, VeoGenerationOutput{}, ctx.Err()
+	}
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() && len(downloadURLs) > 0 {
+		contentText := i18n.Sprintf(ctx, "Interpolated video generated. Download URL:\n%s", downloadURLs[0])
+		if expiresAt != "" {
+			contentText += i18n.Sprintf(ctx, "\n\nURL expires at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else if len(videoContents) > 0 {
+		result = &mcp.CallToolResult{
+			Content: videoContents,
+		}
+	}
+
+	if safetyFeedback != nil {
+		result = safetyBlockedResult(ctx, safetyFeedback)
+	}
+
+	return result, VeoGenerationOutput{
+		GenerationResult: s.generationResult(status, stored, dataURIs, metadata),
+
+		OperationID:     operationID,
+		VideoURL:        videoURL,
+		SavedFiles:      savedFiles,
+		DataURIs:        dataURIs,
+		Thumbnails:      thumbnails,
+		DownloadURLs:    downloadURLs,
+		ExpiresAt:       expiresAt,
+		Model:           model,
+		AspectRatio:     aspectRatio,
+		Resolution:      resolution,
+		Safety:          safetyFeedback,
+		GeneratedAt:     timestamp,
+		EstimatedLength: "8 seconds",
+	}, nil
+}
+
+// loadVeoImage resolves and reads an input image for Veo, detecting its MIME type from content
+func (s *Server) loadVeoImage(ctx context.Context, path string) (*genai.Image, error) {
+	localPath, cleanup, err := s.resolveInputPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input image: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	data, err := os.ReadFile(localPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read input image: %w", err)
+	}
+	mimeType, err := imaging.DetectInputMIME(data)
+	if err != nil {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "invalid input image: %w", err)
+	}
+	return &genai.Image{ImageBytes: data, MIMEType: mimeType}, nil
+}
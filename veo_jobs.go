@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-mcp/internal/i18n"
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/safety"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+	"gemini-mcp/internal/webhook"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// videoJobRetention is how long finished async video jobs can be looked up
+const videoJobRetention = 24 * time.Hour
+
+// Async Veo job status
+type VeoJobStatusInput struct {
+	OperationID string `json:"operation_id" jsonschema:"description:operation_id returned by a Veo tool called with async=true"`
+}
+
+type VeoJobStatusOutput struct {
+	OperationID string           `json:"operation_id"`
+	Tool        string           `json:"tool"`
+	Status      string           `json:"status"` // generating, storing, completed, failed, blocked or timeout
+	VideoURI    string           `json:"video_uri,omitempty"`
+	ObjectKey   string           `json:"object_key,omitempty"`
+	DownloadURL string           `json:"download_url,omitempty"`
+	ExpiresAt   string           `json:"expires_at,omitempty"`
+	Thumbnail   string           `json:"thumbnail,omitempty"`
+	Error       string           `json:"error,omitempty"`
+	ErrorCode   toolerr.Code     `json:"error_code,omitempty"`
+	Safety      *safety.Feedback `json:"safety,omitempty"`
+	Warnings    []string         `json:"warnings,omitempty"`
+	StartedAt   string           `json:"started_at"`
+	UpdatedAt   string           `json:"updated_at"`
+}
+
+// videoJob is a Veo generation started with async=true. It is polled,
+// downloaded and stored in the background while clients follow its status.
+type videoJob struct {
+	tenant string // Tenant of the caller that started the job
+
+	mu       sync.Mutex
+	status   VeoJobStatusOutput
+	finished time.Time // Zero while the job is running
+}
+
+// update changes the job's status under its lock
+func (j *videoJob) update(change func(status *VeoJobStatusOutput)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	change(&j.status)
+	j.status.UpdatedAt = time.Now().Format(time.RFC3339)
+	switch j.status.Status {
+	case "generating", "storing":
+	default:
+		j.finished = time.Now()
+	}
+}
+
+// snapshot returns a copy of the job's status
+func (j *videoJob) snapshot() VeoJobStatusOutput {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	status := j.status
+	status.Warnings = append([]string(nil), j.status.Warnings...)
+	return status
+}
+
+// expired reports whether the job finished longer than videoJobRetention ago
+func (j *videoJob) expired() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finished.IsZero() && time.Since(j.finished) > videoJobRetention
+}
+
+// videoJobStore tracks async video jobs by operation ID
+type videoJobStore struct {
+	jobs map[string]*videoJob
+	mu   sync.Mutex
+}
+
+func newVideoJobStore() *videoJobStore {
+	return &videoJobStore{jobs: make(map[string]*videoJob)}
+}
+
+// add registers a job, forgetting jobs that finished too long ago
+func (st *videoJobStore) add(job *videoJob) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for id, old := range st.jobs {
+		if old.expired() {
+			delete(st.jobs, id)
+		}
+	}
+	st.jobs[job.status.OperationID] = job
+}
+
+// get returns the job of an operation if it belongs to tenant
+func (st *videoJobStore) get(operationID, tenant string) (*videoJob, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	job, ok := st.jobs[operationID]
+	if !ok || job.tenant != tenant || job.expired() {
+		return nil, false
+	}
+	return job, true
+}
+
+// startVideoJob finishes a started Veo operation in the background and
+// returns the result reported to the caller right away. The job holds the
+// caller's generation slot until it is done; release frees it. Once the
+// operation completes, its Google-hosted URI is published before the video
+// is copied to storage, so clients can start fetching it early.
+func (s *Server) startVideoJob(ctx context.Context, tool, label, prefix string, operation *genai.GenerateVideosOperation, outputDir, webhookURL string, release func()) *mcp.CallToolResult {
+	now := time.Now().Format(time.RFC3339)
+	job := &videoJob{
+		tenant: middleware.GetTenant(ctx),
+		status: VeoJobStatusOutput{
+			OperationID: operation.Name,
+			Tool:        tool,
+			Status:      "generating",
+			StartedAt:   now,
+			UpdatedAt:   now,
+		},
+	}
+	s.videoJobs.add(job)
+
+	// The job outlives the tool call but keeps its values, such as the
+	// bound API key, link TTL and tenant
+	jobCtx := context.WithoutCancel(ctx)
+	// The call starting the job is in flight, so the drainer always holds it
+	hold, _ := s.drainer.Hold()
+	go func() {
+		defer hold()
+		defer release()
+		s.runVideoJob(jobCtx, job, operation, label, prefix, outputDir)
+		s.notifyVideoJob(jobCtx, webhookURL, job.snapshot())
+	}()
+
+	log.Printf("Running %s %s in the background", label, operation.Name)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{
+			Text: i18n.Sprintf(ctx, "Video generation started. Check its progress with veo_job_status and operation_id %s.", operation.Name),
+		}},
+	}
+}
+
+// runVideoJob waits for a Veo operation, then downloads and stores its video,
+// recording each step in the job's status
+func (s *Server) runVideoJob(ctx context.Context, job *videoJob, operation *genai.GenerateVideosOperation, label, prefix, outputDir string) {
+	ctx, collected := warnings.WithCollector(ctx)
+	fail := func(status string, code toolerr.Code, format string, args ...any) {
+		message := fmt.Sprintf(format, args...)
+		log.Printf("Background %s %s %s: %s", label, operation.Name, status, message)
+		job.update(func(st *VeoJobStatusOutput) {
+			st.Status, st.Error, st.ErrorCode = status, message, code
+			st.Warnings = collected.List()
+		})
+	}
+
+	operation, _ = s.pollVideoOperation(ctx, operation, label)
+	switch {
+	case !operation.Done:
+		fail("timeout", toolerr.Timeout, "video generation did not finish within 10 minutes")
+		return
+	case operation.Error != nil:
+		fail("failed", toolerr.Upstream, "video generation failed: %v", operation.Error)
+		return
+	case operation.Response == nil || len(operation.Response.GeneratedVideos) == 0:
+		if feedback := safety.FromVideosResponse(operation.Response); feedback != nil && feedback.Blocked {
+			safety.RecordBlock(ctx, feedback)
+			job.update(func(st *VeoJobStatusOutput) { st.Safety = feedback })
+			fail("blocked", toolerr.ModelBlocked, "%s", feedback.Summary())
+		} else {
+			fail("failed", toolerr.Upstream, "no video was generated")
+		}
+		return
+	}
+
+	video := operation.Response.GeneratedVideos[0]
+	job.update(func(st *VeoJobStatusOutput) {
+		st.Status = "storing"
+		if video.Video != nil {
+			st.VideoURI = video.Video.URI
+		}
+	})
+	log.Printf("Background %s %s completed; storing the video", label, operation.Name)
+
+	videoData, err := s.client.Files.Download(ctx, genai.NewDownloadURIFromVideo(video.Video), nil)
+	if err != nil {
+		fail("failed", toolerr.Classify(err).Code, "failed to download video: %v", err)
+		return
+	}
+	result, err := s.storage.Store(ctx, videoData, "video/mp4", prefix)
+	if err != nil {
+		fail("failed", toolerr.Classify(err).Code, "failed to store video: %v", err)
+		return
+	}
+	copyToOutputDirectory(outputDir, result.ObjectKey, videoData)
+	log.Printf("Stored background %s: %s", label, result.Location)
+
+	job.update(func(st *VeoJobStatusOutput) {
+		st.Status = "completed"
+		st.ObjectKey = result.ObjectKey
+		if s.storage.IsRemote() {
+			st.DownloadURL = result.Location
+		}
+		if result.ExpiresAt != nil {
+			st.ExpiresAt = result.ExpiresAt.Format(time.RFC3339)
+		}
+		if result.Thumbnail != nil {
+			st.Thumbnail = result.Thumbnail.Location
+		}
+		st.Warnings = collected.List()
+	})
+}
+
+// notifyVideoJob sends the webhook event of a finished job
+func (s *Server) notifyVideoJob(ctx context.Context, webhookURL string, status VeoJobStatusOutput) {
+	event := webhook.Event{
+		Event:       webhook.EventCompleted,
+		Tool:        status.Tool,
+		Status:      status.Status,
+		OperationID: status.OperationID,
+		ExpiresAt:   status.ExpiresAt,
+		Tenant:      middleware.GetTenant(ctx),
+	}
+	if status.Status == "completed" {
+		event.SavedFiles = []string{status.ObjectKey}
+		if status.DownloadURL != "" {
+			event.DownloadURLs = []string{status.DownloadURL}
+		}
+	} else {
+		event.Event = webhook.EventFailed
+		event.Error = status.Error
+		event.ErrorCode = string(status.ErrorCode)
+	}
+	s.webhooks.Notify(webhookURL, event)
+}
+
+func (s *Server) handleVeoJobStatus(ctx context.Context, req *mcp.CallToolRequest, input VeoJobStatusInput) (*mcp.CallToolResult, VeoJobStatusOutput, error) {
+	if input.OperationID == "" {
+		return nil, VeoJobStatusOutput{}, toolerr.Errorf(toolerr.InvalidInput, "operation_id is required")
+	}
+	job, ok := s.videoJobs.get(input.OperationID, middleware.GetTenant(ctx))
+	if !ok {
+		return nil, VeoJobStatusOutput{}, toolerr.Errorf(toolerr.InvalidInput, "no async video job %q (jobs are kept for %d hours after they finish and are lost when the server restarts)", input.OperationID, int(videoJobRetention.Hours()))
+	}
+	status := job.snapshot()
+
+	lines := []string{fmt.Sprintf("Status: %s", status.Status)}
+	switch {
+	case status.DownloadURL != "":
+		lines = append(lines, "Download URL: "+status.DownloadURL)
+	case status.ObjectKey != "":
+		lines = append(lines, "Object key: "+status.ObjectKey)
+	case status.VideoURI != "":
+		lines = append(lines, "Google-hosted video: "+status.VideoURI, "The video is being copied to storage; check again for its object key.")
+	}
+	if status.Error != "" {
+		lines = append(lines, "Error: "+status.Error)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{&mcp.TextContent{Text: strings.Join(lines, "\n")}},
+	}, status, nil
+}
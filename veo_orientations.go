@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// videoOrientations are the aspect ratios generated with both_orientations,
+// landscape first
+var videoOrientations = []string{"16:9", "9:16"}
+
+// VideoOrientation is the video of one aspect ratio of a both_orientations call
+type VideoOrientation struct {
+	AspectRatio string `json:"aspect_ratio"`
+	Status      string `json:"status"`
+	OperationID string `json:"operation_id,omitempty"`
+	ObjectKey   string `json:"object_key,omitempty"`
+	VideoURL    string `json:"video_url,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// orientationInput is implemented by Veo tool inputs with both_orientations
+type orientationInput interface {
+	bothOrientations() bool
+	async() bool
+	// veoParams returns the requested model, resolution and cost confirmation
+	veoParams() (model, resolution string, confirmCost bool)
+	// setOrientation turns the input into the call for one aspect ratio
+	// whose cost was already confirmed
+	setOrientation(aspectRatio string)
+}
+
+func (in *VeoTextToVideoInput) bothOrientations() bool { return in.BothOrientations }
+func (in *VeoTextToVideoInput) async() bool            { return in.Async }
+func (in *VeoTextToVideoInput) veoParams() (string, string, bool) {
+	return in.Model, in.Resolution, in.ConfirmCost
+}
+func (in *VeoTextToVideoInput) setOrientation(aspectRatio string) {
+	in.AspectRatio, in.ConfirmCost, in.BothOrientations = aspectRatio, true, false
+}
+
+func (in *VeoImageToVideoInput) bothOrientations() bool { return in.BothOrientations }
+func (in *VeoImageToVideoInput) async() bool            { return in.Async }
+func (in *VeoImageToVideoInput) veoParams() (string, string, bool) {
+	return in.Model, in.Resolution, in.ConfirmCost
+}
+func (in *VeoImageToVideoInput) setOrientation(aspectRatio string) {
+	in.AspectRatio, in.ConfirmCost, in.BothOrientations = aspectRatio, true, false
+}
+
+func (in *VeoGenerationInput) bothOrientations() bool { return in.BothOrientations }
+func (in *VeoGenerationInput) async() bool            { return in.Async }
+func (in *VeoGenerationInput) veoParams() (string, string, bool) {
+	return in.Model, in.Resolution, in.ConfirmCost
+}
+func (in *VeoGenerationInput) setOrientation(aspectRatio string) {
+	in.AspectRatio, in.ConfirmCost, in.BothOrientations = aspectRatio, true, false
+}
+
+// orientationCall is the outcome of the call for one aspect ratio
+type orientationCall struct {
+	result *mcp.CallToolResult
+	output VeoGenerationOutput
+	err    error
+}
+
+// withBothOrientations wraps a Veo tool handler so that both_orientations
+// runs it once per aspect ratio in videoOrientations, concurrently and with
+// otherwise identical input, and returns both videos in one result. The cost
+// guardrail asks once for both videos and counts them as two.
+func withBothOrientations[In any](s *Server, tool string, next mcp.ToolHandlerFor[In, VeoGenerationOutput]) mcp.ToolHandlerFor[In, VeoGenerationOutput] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+		in, ok := any(&input).(orientationInput)
+		if !ok || !in.bothOrientations() {
+			return next(ctx, req, input)
+		}
+		if in.async() {
+			return nil, VeoGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "both_orientations cannot be combined with async")
+		}
+
+		model, resolution, confirmCost := in.veoParams()
+		if model == "" {
+			model = s.config.VeoDefaultModel
+		}
+		if resolution == "" {
+			resolution = "720p"
+		}
+		if strings.EqualFold(resolution, "1080p") {
+			// The portrait video would fail after the landscape one was billed
+			return nil, VeoGenerationOutput{}, toolerr.Errorf(toolerr.InvalidInput, "both_orientations cannot be combined with 1080p, which Veo only renders in 16:9; use 720p")
+		}
+		if err := s.allowlist.Check(tool, model); err != nil {
+			return nil, VeoGenerationOutput{}, err
+		}
+		if err := s.confirmVeoCost(ctx, req, model, resolution, len(videoOrientations), confirmCost); err != nil {
+			return nil, VeoGenerationOutput{}, err
+		}
+		log.Printf("Generating %s versions of one video", strings.Join(videoOrientations, " and "))
+
+		calls := make([]orientationCall, len(videoOrientations))
+		var wg sync.WaitGroup
+		for i, aspectRatio := range videoOrientations {
+			variant := input
+			any(&variant).(orientationInput).setOrientation(aspectRatio)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, output, err := next(ctx, req, variant)
+				calls[i] = orientationCall{result: result, output: output, err: err}
+			}()
+		}
+		wg.Wait()
+
+		return mergeOrientations(ctx, calls)
+	}
+}
+
+// mergeOrientations combines the calls of a both_orientations request. The
+// request fails only if every call failed; the status is completed only if
+// every video was.
+func mergeOrientations(ctx context.Context, calls []orientationCall) (*mcp.CallToolResult, VeoGenerationOutput, error) {
+	var merged *VeoGenerationOutput
+	var orientations []VideoOrientation
+	var content []mcp.Content
+	var operationIDs []string
+	var blocked *mcp.CallToolResult
+	for i, call := range calls {
+		orientation := VideoOrientation{AspectRatio: videoOrientations[i], Status: "completed"}
+		if call.err != nil {
+			orientation.Status = "failed"
+			orientation.Error = call.err.Error()
+			warnings.Add(ctx, "%s video failed: %v", orientation.AspectRatio, call.err)
+			orientations = append(orientations, orientation)
+			continue
+		}
+
+		out := call.output
+		if merged == nil {
+			merged = &out
+			merged.Metadata = make(map[string]string, len(out.Metadata))
+			for k, v := range out.Metadata {
+				merged.Metadata[k] = v
+			}
+		} else {
+			mergeOrientationOutput(merged, out)
+		}
+
+		if out.Status != "" {
+			orientation.Status = out.Status
+		}
+		orientation.OperationID = out.OperationID
+		orientation.VideoURL = out.VideoURL
+		if len(out.SavedFiles) > 0 {
+			orientation.ObjectKey = out.SavedFiles[0]
+		}
+		if out.OperationID != "" {
+			operationIDs = append(operationIDs, out.OperationID)
+		}
+		orientations = append(orientations, orientation)
+
+		switch {
+		case call.result == nil:
+		case call.result.IsError:
+			if blocked == nil {
+				blocked = call.result
+			}
+		default:
+			content = append(content, call.result.Content...)
+		}
+	}
+	if merged == nil {
+		return nil, VeoGenerationOutput{}, calls[0].err
+	}
+
+	merged.Orientations = orientations
+	merged.Status = "completed"
+	for _, orientation := range orientations {
+		if orientation.Status != "completed" {
+			merged.Status = orientation.Status
+			break
+		}
+	}
+	merged.AspectRatio = strings.Join(videoOrientations, ",")
+	merged.Metadata["aspect_ratios"] = merged.AspectRatio
+	if len(operationIDs) > 0 {
+		merged.Metadata["operation_id"] = strings.Join(operationIDs, ",")
+	}
+
+	// A blocked video's error result is only returned if no video was made
+	result := blocked
+	if len(content) > 0 {
+		result = &mcp.CallToolResult{Content: content}
+	}
+	return result, *merged, nil
+}
+
+// mergeOrientationOutput adds the files and details of another orientation's
+// output to merged
+func mergeOrientationOutput(merged *VeoGenerationOutput, out VeoGenerationOutput) {
+	merged.Assets = append(merged.Assets, out.Assets...)
+	merged.URLs = append(merged.URLs, out.URLs...)
+	merged.Stats.Assets += out.Stats.Assets
+	merged.Stats.Bytes += out.Stats.Bytes
+	merged.SavedFiles = append(merged.SavedFiles, out.SavedFiles...)
+	merged.DownloadURLs = append(merged.DownloadURLs, out.DownloadURLs...)
+	for k, v := range out.DataURIs {
+		if merged.DataURIs == nil {
+			merged.DataURIs = make(map[string]string)
+		}
+		merged.DataURIs[k] = v
+	}
+	for k, v := range out.Thumbnails {
+		if merged.Thumbnails == nil {
+			merged.Thumbnails = make(map[string]string)
+		}
+		merged.Thumbnails[k] = v
+	}
+	if merged.ExpiresAt == "" || (out.ExpiresAt != "" && out.ExpiresAt < merged.ExpiresAt) {
+		merged.ExpiresAt = out.ExpiresAt
+	}
+	if merged.VideoURL == "" {
+		merged.VideoURL = out.VideoURL
+	}
+	if merged.OperationID == "" {
+		merged.OperationID = out.OperationID
+	}
+	if merged.Safety == nil {
+		merged.Safety = out.Safety
+	}
+}
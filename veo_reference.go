@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/video"
+
+	"google.golang.org/genai"
+)
+
+// veoReferenceFrames is how many frames of a reference video are sent to Veo,
+// the most reference images a generation accepts
+const veoReferenceFrames = 3
+
+// supportsVeoReferenceImages reports whether a Veo model accepts reference images
+func supportsVeoReferenceImages(model string) bool {
+	return strings.HasPrefix(model, "veo-3.1-")
+}
+
+// loadVeoVideoReference turns an existing video into reference images for a
+// new Veo generation, so a follow-up shot keeps the look, subjects and setting
+// of an earlier clip. Veo only accepts videos as input for extension, so
+// frames spread evenly across the clip are sent as asset references instead.
+func (s *Server) loadVeoVideoReference(ctx context.Context, path, model string) ([]*genai.VideoGenerationReferenceImage, error) {
+	if !supportsVeoReferenceImages(model) {
+		return nil, toolerr.Errorf(toolerr.InvalidInput, "reference_video_path requires a Veo 3.1 model (got %s)", model)
+	}
+	if s.media.Backend(media.OpFrameExtraction) == media.Unavailable {
+		return nil, fmt.Errorf("reference_video_path requires ffmpeg (set FFMPEG_PATH)")
+	}
+
+	localPath, cleanup, err := s.resolveInputPath(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve reference video: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	var duration time.Duration
+	if f, err := os.Open(localPath); err == nil {
+		duration, _ = video.Duration(f)
+		f.Close()
+	}
+
+	offsets := []time.Duration{0}
+	if duration > 0 {
+		offsets = offsets[:0]
+		for i := 0; i < veoReferenceFrames; i++ {
+			offsets = append(offsets, duration*time.Duration(2*i+1)/(2*veoReferenceFrames))
+		}
+	}
+
+	var references []*genai.VideoGenerationReferenceImage
+	for _, at := range offsets {
+		data, err := s.media.ExtractFrame(ctx, localPath, at, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract a reference frame at %s: %w", video.FormatTimestamp(at), err)
+		}
+		references = append(references, &genai.VideoGenerationReferenceImage{
+			Image:         &genai.Image{ImageBytes: data, MIMEType: "image/jpeg"},
+			ReferenceType: genai.VideoGenerationReferenceTypeAsset,
+		})
+	}
+	log.Printf("Using %d frames of %s as reference images", len(references), path)
+	return references, nil
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/usage"
+	"gemini-mcp/internal/video"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"google.golang.org/genai"
+)
+
+// Video Analysis
+type GeminiVideoAnalysisInput struct {
+	VideoPath      string   `json:"video_path" jsonschema:"description:Path to the video to analyze. Can be a local MP4/MOV/WebM file path or an object key returned by a Veo tool (found in saved_files) or by upload_media."`
+	Mode           string   `json:"mode,omitempty" jsonschema:"description:Type of analysis: 'summary' (overall description), 'scenes' (timestamped scene breakdown), 'qa' (answer the provided questions),default:summary,enum:summary,enum:scenes,enum:qa"`
+	Questions      []string `json:"questions,omitempty" jsonschema:"description:Questions to answer about the video. Required when mode is 'qa'."`
+	Prompt         string   `json:"prompt,omitempty" jsonschema:"description:Optional additional instructions for the analysis (e.g., 'focus on camera movement', 'check whether the logo is visible')"`
+	Model          string   `json:"model,omitempty" jsonschema:"description:Gemini model to use for video understanding,default:gemini-2.5-flash"`
+	SegmentSeconds int      `json:"segment_seconds,omitempty" jsonschema:"description:Analyze the video in segments of this many seconds and combine the results, for videos too long to analyze in one pass. By default videos longer than 40 minutes are split into 10-minute segments. Minimum 60."`
+}
+
+type VideoScene struct {
+	Start       string `json:"start"`
+	End         string `json:"end"`
+	Description string `json:"description"`
+}
+
+// VideoSegment is the analysis of one segment of a video analyzed in parts.
+// Timestamps refer to the full video.
+type VideoSegment struct {
+	Index    int          `json:"index"`
+	Start    string       `json:"start"`
+	End      string       `json:"end"`
+	Analysis string       `json:"analysis"`
+	Scenes   []VideoScene `json:"scenes,omitempty"`
+}
+
+type GeminiVideoAnalysisOutput struct {
+	GenerationResult
+
+	VideoPath   string         `json:"video_path"`
+	Mode        string         `json:"mode"`
+	Model       string         `json:"model"`
+	Analysis    string         `json:"analysis"`
+	Scenes      []VideoScene   `json:"scenes,omitempty"`
+	Duration    string         `json:"duration,omitempty"`
+	Segments    []VideoSegment `json:"segments,omitempty"`
+	GeneratedAt string         `json:"generated_at"`
+}
+
+const (
+	// longVideoThreshold is the duration above which videos are analyzed in
+	// segments by default, leaving headroom below the model's context window
+	longVideoThreshold = 40 * time.Minute
+	// defaultVideoSegment is the segment length used for long videos
+	defaultVideoSegment = 10 * time.Minute
+)
+
+func (s *Server) handleGeminiVideoAnalysis(ctx context.Context, req *mcp.CallToolRequest, input GeminiVideoAnalysisInput) (*mcp.CallToolResult, GeminiVideoAnalysisOutput, error) {
+	if input.VideoPath == "" {
+		return nil, GeminiVideoAnalysisOutput{}, toolerr.Errorf(toolerr.InvalidInput, "video_path is required")
+	}
+
+	mode := input.Mode
+	if mode == "" {
+		mode = "summary"
+	}
+	if mode == "qa" && len(input.Questions) == 0 {
+		return nil, GeminiVideoAnalysisOutput{}, toolerr.Errorf(toolerr.InvalidInput, "questions are required when mode is 'qa'")
+	}
+
+	model := input.Model
+	if model == "" {
+		model = s.config.TextDefaultModel
+	}
+	if err := s.allowlist.Check("gemini_video_analysis", model); err != nil {
+		return nil, GeminiVideoAnalysisOutput{}, err
+	}
+
+	if input.SegmentSeconds != 0 && input.SegmentSeconds < 60 {
+		return nil, GeminiVideoAnalysisOutput{}, toolerr.Errorf(toolerr.InvalidInput, "segment_seconds must be at least 60")
+	}
+
+	mimeType := videoMIMEFromPath(input.VideoPath)
+	if mimeType == "" {
+		return nil, GeminiVideoAnalysisOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported video format: %s (supported: .mp4, .mov, .webm)", filepath.Ext(input.VideoPath))
+	}
+
+	log.Printf("Analyzing video %s with model %s (mode: %s)", input.VideoPath, model, mode)
+
+	// Resolve input video path (may download from S3)
+	localVideoPath, cleanup, err := s.resolveInputPath(ctx, input.VideoPath)
+	if err != nil {
+		return nil, GeminiVideoAnalysisOutput{}, fmt.Errorf("failed to resolve input video: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+
+	// Short clips are sent inline; larger videos go through the Files API
+	inputs := s.newInputParts()
+	defer inputs.Close()
+	videoPart, file, err := inputs.addFile(ctx, localVideoPath, mimeType)
+	if err != nil {
+		return nil, GeminiVideoAnalysisOutput{}, err
+	}
+
+	prompt, config := videoAnalysisPrompt(mode, input)
+
+	metadata := map[string]string{
+		"mime_type": mimeType,
+	}
+	if file != nil {
+		metadata["gemini_file"] = file.Name
+	}
+	if len(input.Questions) > 0 {
+		metadata["questions_count"] = fmt.Sprintf("%d", len(input.Questions))
+	}
+
+	duration := videoDuration(file, localVideoPath)
+	segmentLength := time.Duration(input.SegmentSeconds) * time.Second
+	if segmentLength == 0 && duration > longVideoThreshold {
+		segmentLength = defaultVideoSegment
+	}
+	if segmentLength > 0 && duration == 0 {
+		warnings.Add(ctx, "could not determine the duration of %s; analyzed it in one pass", input.VideoPath)
+	}
+	if segmentLength > 0 && duration > segmentLength {
+		return s.analyzeVideoSegments(ctx, input, mode, model, videoPart, duration, segmentLength, prompt, config, metadata)
+	}
+
+	analysis, err := s.analyzeVideoPart(ctx, model, videoPart, nil, prompt, config)
+	if err != nil {
+		return nil, GeminiVideoAnalysisOutput{}, err
+	}
+
+	timestamp := time.Now().Format("20060102_150405")
+
+	var scenes []VideoScene
+	if mode == "scenes" {
+		if err := json.Unmarshal([]byte(analysis), &scenes); err != nil {
+			warnings.Add(ctx, "failed to parse scene breakdown as JSON, returned it as text: %v", err)
+		} else {
+			analysis = formatScenes(scenes)
+		}
+	}
+
+	output := GeminiVideoAnalysisOutput{
+		GenerationResult: s.generationResult("completed", nil, nil, metadata),
+
+		VideoPath:   input.VideoPath,
+		Mode:        mode,
+		Model:       model,
+		Analysis:    analysis,
+		Scenes:      scenes,
+		GeneratedAt: timestamp,
+	}
+	if duration > 0 {
+		output.Duration = video.FormatTimestamp(duration)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: analysis,
+			},
+		},
+	}, output, nil
+}
+
+// videoAnalysisPrompt builds the instructions and response config for a mode
+func videoAnalysisPrompt(mode string, input GeminiVideoAnalysisInput) (string, *genai.GenerateContentConfig) {
+	var promptParts []string
+	var config *genai.GenerateContentConfig
+
+	switch mode {
+	case "scenes":
+		promptParts = append(promptParts, "Break this video down into its distinct scenes or shots. For each scene give the start and end timestamps (MM:SS) and a concise description of what happens, including camera movement and notable visual or audio elements.")
+		config = &genai.GenerateContentConfig{
+			ResponseMIMEType: "application/json",
+			ResponseSchema: &genai.Schema{
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"start":       {Type: genai.TypeString},
+						"end":         {Type: genai.TypeString},
+						"description": {Type: genai.TypeString},
+					},
+					Required: []string{"start", "end", "description"},
+				},
+			},
+		}
+	case "qa":
+		promptParts = append(promptParts, "Answer the following questions about this video. Number each answer to match its question and be specific, citing timestamps (MM:SS) where relevant.")
+		for i, q := range input.Questions {
+			promptParts = append(promptParts, fmt.Sprintf("%d. %s", i+1, q))
+		}
+	default:
+		promptParts = append(promptParts, "Summarize this video. Describe the subject, setting, actions, camera work, visual style, and any audio, and note any visual artifacts or inconsistencies.")
+	}
+
+	if input.Prompt != "" {
+		promptParts = append(promptParts, input.Prompt)
+	}
+	return strings.Join(promptParts, "\n"), config
+}
+
+// analyzeVideoPart runs one analysis request over a video, inline or
+// uploaded, limited to a clip of it when clip is non-nil
+func (s *Server) analyzeVideoPart(ctx context.Context, model string, videoPart *genai.Part, clip *genai.VideoMetadata, prompt string, config *genai.GenerateContentConfig) (string, error) {
+	part := *videoPart
+	part.VideoMetadata = clip
+
+	contents := []*genai.Content{
+		genai.NewContentFromParts([]*genai.Part{&part, genai.NewPartFromText(prompt)}, genai.RoleUser),
+	}
+
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, config)
+	if err != nil {
+		return "", fmt.Errorf("error analyzing video: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return "", toolerr.Errorf(toolerr.Upstream, "no analysis was generated")
+	}
+	return response.Text(), nil
+}
+
+// analyzeVideoSegments analyzes a long video one segment at a time and
+// combines the results: scene lists are joined on the full video's timeline,
+// and summaries and answers are merged by a final text-only request
+func (s *Server) analyzeVideoSegments(ctx context.Context, input GeminiVideoAnalysisInput, mode, model string, videoPart *genai.Part, duration, segmentLength time.Duration, prompt string, config *genai.GenerateContentConfig, metadata map[string]string) (*mcp.CallToolResult, GeminiVideoAnalysisOutput, error) {
+	spans := video.Split(duration, segmentLength)
+	log.Printf("Analyzing %s (%s) in %d segments", input.VideoPath, video.FormatTimestamp(duration), len(spans))
+
+	var segments []VideoSegment
+	var scenes []VideoScene
+	for i, span := range spans {
+		start, end := video.FormatTimestamp(span.Start), video.FormatTimestamp(span.End)
+		segmentPrompt := fmt.Sprintf("This clip is segment %d of %d of a longer video and covers %s to %s of it.", i+1, len(spans), start, end)
+		if mode == "scenes" {
+			segmentPrompt += " Give timestamps relative to the start of this clip, which is 00:00."
+		} else {
+			segmentPrompt += fmt.Sprintf(" Give any timestamps relative to the full video by adding %s to positions within this clip.", start)
+		}
+
+		analysis, err := s.analyzeVideoPart(ctx, model, videoPart, &genai.VideoMetadata{StartOffset: span.Start, EndOffset: span.End}, segmentPrompt+"\n"+prompt, config)
+		if err != nil {
+			return nil, GeminiVideoAnalysisOutput{}, fmt.Errorf("segment %d (%s - %s): %w", i+1, start, end, err)
+		}
+		log.Printf("Analyzed segment %d/%d of %s", i+1, len(spans), input.VideoPath)
+
+		segment := VideoSegment{Index: i + 1, Start: start, End: end, Analysis: analysis}
+		if mode == "scenes" {
+			if err := json.Unmarshal([]byte(analysis), &segment.Scenes); err != nil {
+				warnings.Add(ctx, "failed to parse scene breakdown of segment %d as JSON, returned it as text: %v", i+1, err)
+			} else {
+				for j := range segment.Scenes {
+					segment.Scenes[j].Start = video.ShiftTimestamp(segment.Scenes[j].Start, span.Start)
+					segment.Scenes[j].End = video.ShiftTimestamp(segment.Scenes[j].End, span.Start)
+				}
+				segment.Analysis = formatScenes(segment.Scenes)
+				scenes = append(scenes, segment.Scenes...)
+			}
+		}
+		segments = append(segments, segment)
+	}
+
+	var analysis string
+	if mode == "scenes" {
+		analysis = formatScenes(scenes)
+	} else {
+		var err error
+		if analysis, err = s.combineSegmentAnalyses(ctx, mode, model, input, segments); err != nil {
+			return nil, GeminiVideoAnalysisOutput{}, err
+		}
+	}
+
+	metadata["segments"] = fmt.Sprintf("%d", len(segments))
+	metadata["segment_length"] = segmentLength.String()
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: analysis,
+			},
+		},
+	}, GeminiVideoAnalysisOutput{
+		GenerationResult: s.generationResult("completed", nil, nil, metadata),
+
+		VideoPath:   input.VideoPath,
+		Mode:        mode,
+		Model:       model,
+		Analysis:    analysis,
+		Scenes:      scenes,
+		Duration:    video.FormatTimestamp(duration),
+		Segments:    segments,
+		GeneratedAt: time.Now().Format("20060102_150405"),
+	}, nil
+}
+
+// combineSegmentAnalyses merges per-segment summaries or answers into one
+// result for the whole video
+func (s *Server) combineSegmentAnalyses(ctx context.Context, mode, model string, input GeminiVideoAnalysisInput, segments []VideoSegment) (string, error) {
+	var b strings.Builder
+	if mode == "qa" {
+		b.WriteString("The following are answers to questions about a long video, produced separately for each consecutive segment of it. Answer each question for the video as a whole, numbering each answer to match its question and citing timestamps (MM:SS or HH:MM:SS) where relevant. If a segment did not show the answer, rely on the segments that did.\n\nQuestions:\n")
+		for i, q := range input.Questions {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, q)
+		}
+	} else {
+		b.WriteString("The following are summaries of consecutive segments of one long video. Combine them into a single coherent summary of the whole video that describes the subject, setting, actions, camera work, visual style, and audio, follows how the video progresses, and notes any visual artifacts or inconsistencies. Keep the timestamps of key moments.\n")
+	}
+	if input.Prompt != "" {
+		fmt.Fprintf(&b, "\nAdditional instructions: %s\n", input.Prompt)
+	}
+	for _, segment := range segments {
+		fmt.Fprintf(&b, "\n--- Segment %d (%s - %s) ---\n%s\n", segment.Index, segment.Start, segment.End, segment.Analysis)
+	}
+
+	contents := []*genai.Content{genai.NewContentFromText(b.String(), genai.RoleUser)}
+	response, err := s.client.Models.GenerateContent(ctx, model, contents, nil)
+	if err != nil {
+		return "", fmt.Errorf("error combining segment analyses: %w", err)
+	}
+	usage.Add(ctx, response)
+	if response == nil || len(response.Candidates) == 0 {
+		return "", toolerr.Errorf(toolerr.Upstream, "no combined analysis was generated")
+	}
+	return response.Text(), nil
+}
+
+// videoDuration returns the duration reported by the Files API, falling back
+// to the MP4/MOV header of the local file, or 0 if neither is available
+func videoDuration(file *genai.File, localPath string) time.Duration {
+	if file != nil {
+		if value, ok := file.VideoMetadata["videoDuration"].(string); ok {
+			if d, err := time.ParseDuration(value); err == nil && d > 0 {
+				return d
+			}
+		}
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	d, err := video.Duration(f)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// formatScenes renders a numbered scene list
+func formatScenes(scenes []VideoScene) string {
+	var b strings.Builder
+	for i, scene := range scenes {
+		fmt.Fprintf(&b, "%d. [%s - %s] %s\n", i+1, scene.Start, scene.End, scene.Description)
+	}
+	return b.String()
+}
+
+// videoMIMEFromPath returns the MIME type for a supported video file extension,
+// or an empty string if the extension is not recognised
+func videoMIMEFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4":
+		return "video/mp4"
+	case ".mov":
+		return "video/quicktime"
+	case ".webm":
+		return "video/webm"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/video"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	// maxConcatClips limits the clips joined by one video_concat call
+	maxConcatClips = 20
+	// maxCrossfade limits the overlap between consecutive clips
+	maxCrossfade = 5 * time.Second
+)
+
+// Video concatenation
+type VideoConcatInput struct {
+	VideoPaths       []string `json:"video_paths" jsonschema:"description:Paths of the videos to join in playback order (2 to 20). Each can be a local MP4/MOV file path or an object key returned by a Veo tool (found in saved_files) or by upload_media. All videos must have the same resolution."`
+	CrossfadeSeconds float64  `json:"crossfade_seconds,omitempty" jsonschema:"description:Optional. Overlap consecutive clips by this many seconds with a transition (0 to 5; less than half of the shortest clip). 0 joins them with hard cuts. Each crossfade shortens the result by its length.,default:0"`
+	Transition       string   `json:"transition,omitempty" jsonschema:"description:Transition used for crossfades.,default:fade,enum:fade,enum:dissolve,enum:fadeblack,enum:fadewhite,enum:wipeleft,enum:wiperight,enum:slideleft,enum:slideright"`
+	LinkTTL          string   `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget    string   `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type VideoConcatOutput struct {
+	SourceVideos     []string          `json:"source_videos"`
+	VideoFile        string            `json:"video_file"`
+	ClipCount        int               `json:"clip_count"`
+	DurationSeconds  float64           `json:"duration_seconds"`
+	CrossfadeSeconds float64           `json:"crossfade_seconds,omitempty"`
+	Transition       string            `json:"transition,omitempty"`
+	Width            int               `json:"width"`
+	Height           int               `json:"height"`
+	HasAudio         bool              `json:"has_audio"`
+	SizeBytes        int               `json:"size_bytes"`
+	SavedFiles       []string          `json:"saved_files,omitempty"`
+	DataURIs         map[string]string `json:"data_uris,omitempty"`
+	Thumbnails       map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs     []string          `json:"download_urls,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+	Warnings         []string          `json:"warnings,omitempty"`
+	GeneratedAt      string            `json:"generated_at"`
+}
+
+func (s *Server) handleVideoConcat(ctx context.Context, req *mcp.CallToolRequest, input VideoConcatInput) (*mcp.CallToolResult, VideoConcatOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	if len(input.VideoPaths) < 2 {
+		return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "video_paths must list at least 2 videos")
+	}
+	if len(input.VideoPaths) > maxConcatClips {
+		return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "video_paths can list at most %d videos", maxConcatClips)
+	}
+	for _, p := range input.VideoPaths {
+		if mime := videoMIMEFromPath(p); mime != "video/mp4" && mime != "video/quicktime" {
+			return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported video format: %s (supported: .mp4, .mov)", filepath.Ext(p))
+		}
+	}
+
+	if input.CrossfadeSeconds < 0 || input.CrossfadeSeconds > maxCrossfade.Seconds() {
+		return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "crossfade_seconds must be between 0 and %g", maxCrossfade.Seconds())
+	}
+	crossfade := time.Duration(input.CrossfadeSeconds * float64(time.Second)).Round(time.Millisecond)
+	transition := ""
+	if crossfade > 0 {
+		transition = input.Transition
+		if transition == "" {
+			transition = "fade"
+		}
+		if !slices.Contains(video.Transitions, transition) {
+			return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "transition must be one of: %s", strings.Join(video.Transitions, ", "))
+		}
+	} else if input.Transition != "" {
+		warnings.Add(ctx, "transition is ignored without crossfade_seconds")
+	}
+
+	if s.media.Backend(media.OpVideoConcat) == media.Unavailable {
+		return nil, VideoConcatOutput{}, fmt.Errorf("joining videos requires ffmpeg (set FFMPEG_PATH)")
+	}
+
+	// Resolve input video paths (may download from S3) and read their size,
+	// length and audio from the container
+	localPaths := make([]string, len(input.VideoPaths))
+	durations := make([]time.Duration, len(input.VideoPaths))
+	infos := make([]video.Info, len(input.VideoPaths))
+	for i, p := range input.VideoPaths {
+		localPath, cleanup, err := s.resolveInputPath(ctx, p)
+		if err != nil {
+			return nil, VideoConcatOutput{}, fmt.Errorf("failed to resolve input video %s: %w", p, err)
+		}
+		if cleanup != nil {
+			defer cleanup()
+		}
+		data, err := os.ReadFile(localPath)
+		if err != nil {
+			return nil, VideoConcatOutput{}, fmt.Errorf("failed to read input video %s: %w", p, err)
+		}
+		info, err := video.Probe(data)
+		if err != nil {
+			return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "cannot read video %s: %v", p, err)
+		}
+		if i > 0 && (info.Width != infos[0].Width || info.Height != infos[0].Height) {
+			return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "all videos must have the same resolution: %s is %dx%d, %s is %dx%d", input.VideoPaths[0], infos[0].Width, infos[0].Height, p, info.Width, info.Height)
+		}
+		if crossfade > 0 && info.Duration < 2*crossfade {
+			return nil, VideoConcatOutput{}, toolerr.Errorf(toolerr.InvalidInput, "crossfade_seconds must be less than half of every clip; %s is %.1f seconds long", p, info.Duration.Seconds())
+		}
+		localPaths[i], durations[i], infos[i] = localPath, info.Duration, info
+	}
+
+	audio := true
+	var silent []string
+	for i, info := range infos {
+		if !info.HasAudio {
+			audio = false
+			silent = append(silent, input.VideoPaths[i])
+		}
+	}
+	if !audio && len(silent) < len(infos) {
+		warnings.Add(ctx, "the joined video has no sound because these videos have no audio track: %s", strings.Join(silent, ", "))
+	}
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	total -= time.Duration(len(durations)-1) * crossfade
+
+	log.Printf("Joining %d videos (%.1f seconds, crossfade: %.3fs)", len(localPaths), total.Seconds(), crossfade.Seconds())
+
+	joined, err := s.media.Concat(ctx, localPaths, durations, crossfade, transition, audio)
+	if err != nil {
+		return nil, VideoConcatOutput{}, err
+	}
+
+	// Store via storage interface
+	stored, err := s.storage.Store(ctx, joined, "video/mp4", "video_concat")
+	if err != nil {
+		return nil, VideoConcatOutput{}, fmt.Errorf("failed to store joined video: %w", err)
+	}
+	log.Printf("Stored joined video: %s (%d bytes)", stored.Location, len(joined))
+
+	timestamp := time.Now().Format("20060102_150405")
+	var downloadURLs []string
+	var expiresAt string
+	if s.storage.IsRemote() {
+		downloadURLs = append(downloadURLs, stored.Location)
+		if stored.ExpiresAt != nil {
+			expiresAt = stored.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+
+	metadata := map[string]string{
+		"source_videos": strings.Join(input.VideoPaths, ","),
+	}
+	if transition != "" {
+		metadata["transition"] = transition
+	}
+
+	summary := fmt.Sprintf("Joined %d videos into one %.1f second video", len(localPaths), total.Seconds())
+	if crossfade > 0 {
+		summary += fmt.Sprintf(" (%.1f second %s crossfades)", crossfade.Seconds(), transition)
+	}
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := fmt.Sprintf("%s\n\nDownload URL:\n%s", summary, stored.Location)
+		if expiresAt != "" {
+			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, s.mediaContent(joined, stored)...),
+		}
+	}
+
+	return result, VideoConcatOutput{
+		SourceVideos:     input.VideoPaths,
+		VideoFile:        stored.ObjectKey,
+		ClipCount:        len(localPaths),
+		DurationSeconds:  total.Seconds(),
+		CrossfadeSeconds: crossfade.Seconds(),
+		Transition:       transition,
+		Width:            infos[0].Width,
+		Height:           infos[0].Height,
+		HasAudio:         audio,
+		SizeBytes:        len(joined),
+		SavedFiles:       []string{stored.ObjectKey},
+		DataURIs:         s.addDataURI(nil, stored, joined),
+		Thumbnails:       addThumbnail(nil, stored),
+		DownloadURLs:     downloadURLs,
+		ExpiresAt:        expiresAt,
+		Metadata:         metadata,
+		Warnings:         collected.List(),
+		GeneratedAt:      timestamp,
+	}, nil
+}
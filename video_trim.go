@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemini-mcp/internal/media"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/video"
+	"gemini-mcp/internal/warnings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Video trimming
+type VideoTrimInput struct {
+	VideoPath     string `json:"video_path" jsonschema:"description:Path to the video to trim. Can be a local MP4/MOV/WebM file path or an object key returned by a Veo tool (found in saved_files) or by upload_media."`
+	Start         string `json:"start,omitempty" jsonschema:"description:Start of the clip as seconds ('2.5') or a timestamp ('00:02.5' or '00:00:02.5'). Scene start_seconds from detect_scenes can be passed as is.,default:0"`
+	End           string `json:"end,omitempty" jsonschema:"description:End of the clip in the same format as start. Defaults to the end of the video."`
+	Mode          string `json:"mode,omitempty" jsonschema:"description:'accurate' re-encodes the clip so it starts at the exact frame; 'copy' keeps the original encoding, which is lossless and faster but starts at the keyframe before start. Both require ffmpeg on the server; without it MP4/MOV videos are trimmed with an edit list (see the result's method).,default:accurate,enum:accurate,enum:copy"`
+	LinkTTL       string `json:"link_ttl,omitempty" jsonschema:"description:Optional. How long download URLs in the result stay valid (e.g. '5m', '12h', '7d'; between 1m and 7d). Overrides the server default."`
+	StorageTarget string `json:"storage_target,omitempty" jsonschema:"description:Optional. Storage target the results are stored in when the server has several (see server_capabilities), e.g. 'local' for drafts and 's3' for finals. Defaults to the server's default storage."`
+}
+
+type VideoTrimOutput struct {
+	SourceVideo     string            `json:"source_video"`
+	VideoFile       string            `json:"video_file"`
+	Start           string            `json:"start"`
+	End             string            `json:"end"`
+	StartSeconds    float64           `json:"start_seconds"`
+	EndSeconds      float64           `json:"end_seconds"`
+	DurationSeconds float64           `json:"duration_seconds"`
+	Method          string            `json:"method"` // ffmpeg_reencode, ffmpeg_copy or edit_list
+	SizeBytes       int               `json:"size_bytes"`
+	SavedFiles      []string          `json:"saved_files,omitempty"`
+	DataURIs        map[string]string `json:"data_uris,omitempty"`
+	Thumbnails      map[string]string `json:"thumbnails,omitempty"`
+	DownloadURLs    []string          `json:"download_urls,omitempty"`
+	ExpiresAt       string            `json:"expires_at,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	Warnings        []string          `json:"warnings,omitempty"`
+	GeneratedAt     string            `json:"generated_at"`
+}
+
+func (s *Server) handleVideoTrim(ctx context.Context, req *mcp.CallToolRequest, input VideoTrimInput) (*mcp.CallToolResult, VideoTrimOutput, error) {
+	ctx, collected := warnings.WithCollector(ctx)
+	if input.VideoPath == "" {
+		return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "video_path is required")
+	}
+	mimeType := videoMIMEFromPath(input.VideoPath)
+	if mimeType == "" {
+		return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "unsupported video format: %s (supported: .mp4, .mov, .webm)", filepath.Ext(input.VideoPath))
+	}
+
+	mode := input.Mode
+	if mode == "" {
+		mode = "accurate"
+	}
+	if mode != "accurate" && mode != "copy" {
+		return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "mode must be 'accurate' or 'copy'")
+	}
+
+	var start, end time.Duration
+	if input.Start != "" {
+		var ok bool
+		if start, ok = video.ParseTimestamp(input.Start); !ok {
+			return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid start %q (use seconds or MM:SS)", input.Start)
+		}
+	}
+	if input.End != "" {
+		var ok bool
+		if end, ok = video.ParseTimestamp(input.End); !ok {
+			return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "invalid end %q (use seconds or MM:SS)", input.End)
+		}
+		if end <= start {
+			return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "end must be after start")
+		}
+	}
+
+	// Resolve input video path (may download from S3)
+	localVideoPath, cleanup, err := s.resolveInputPath(ctx, input.VideoPath)
+	if err != nil {
+		return nil, VideoTrimOutput{}, fmt.Errorf("failed to resolve input video: %w", err)
+	}
+	if cleanup != nil {
+		defer cleanup()
+	}
+	videoData, err := os.ReadFile(localVideoPath)
+	if err != nil {
+		return nil, VideoTrimOutput{}, fmt.Errorf("failed to read input video: %w", err)
+	}
+
+	// The container's duration bounds the clip; WebM is left to ffmpeg
+	if duration, err := video.Duration(bytes.NewReader(videoData)); err == nil {
+		if start >= duration {
+			return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "start %s is not before the end of the %s video", video.FormatTimestamp(start), video.FormatTimestamp(duration))
+		}
+		if end == 0 {
+			end = duration
+		} else if end > duration {
+			warnings.Add(ctx, "end %s is past the end of the video; the clip ends at %s", input.End, video.FormatTimestamp(duration))
+			end = duration
+		}
+	} else if end == 0 {
+		return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "end is required: the length of this video could not be read")
+	}
+
+	log.Printf("Trimming video %s to %.3fs-%.3fs (mode: %s)", input.VideoPath, start.Seconds(), end.Seconds(), mode)
+
+	if s.media.Backend(media.OpVideoTrim) != media.FFmpeg && mimeType == "video/webm" {
+		return nil, VideoTrimOutput{}, fmt.Errorf("trimming WebM videos requires ffmpeg (set FFMPEG_PATH)")
+	}
+	trimmed, backend, err := s.media.Trim(ctx, localVideoPath, videoData, start, end, mode == "accurate")
+	var method string
+	switch {
+	case backend == media.PureGo && err != nil:
+		return nil, VideoTrimOutput{}, toolerr.Errorf(toolerr.InvalidInput, "cannot trim this video without ffmpeg: %v", err)
+	case err != nil:
+		return nil, VideoTrimOutput{}, err
+	case backend == media.PureGo:
+		method = "edit_list"
+		warnings.Add(ctx, "ffmpeg not found; the clip was trimmed with an MP4 edit list, so players show only the selected part but the file keeps the size of the full video")
+	case mode == "copy":
+		method = "ffmpeg_copy"
+	default:
+		method = "ffmpeg_reencode"
+	}
+
+	// Store via storage interface
+	stored, err := s.storage.Store(ctx, trimmed, "video/mp4", "video_trim")
+	if err != nil {
+		return nil, VideoTrimOutput{}, fmt.Errorf("failed to store trimmed video: %w", err)
+	}
+	log.Printf("Stored trimmed video: %s (%s, %d bytes)", stored.Location, method, len(trimmed))
+
+	timestamp := time.Now().Format("20060102_150405")
+	var downloadURLs []string
+	var expiresAt string
+	if s.storage.IsRemote() {
+		downloadURLs = append(downloadURLs, stored.Location)
+		if stored.ExpiresAt != nil {
+			expiresAt = stored.ExpiresAt.Format(time.RFC3339)
+		}
+	}
+
+	metadata := map[string]string{
+		"source_video": input.VideoPath,
+		"mode":         mode,
+	}
+
+	summary := fmt.Sprintf("Trimmed video to %s - %s (%.1f seconds, %s)", video.FormatTimestamp(start), video.FormatTimestamp(end), (end - start).Seconds(), method)
+
+	// Build result based on storage type
+	var result *mcp.CallToolResult
+	if s.storage.IsRemote() {
+		contentText := fmt.Sprintf("%s\n\nDownload URL:\n%s", summary, stored.Location)
+		if expiresAt != "" {
+			contentText += fmt.Sprintf("\n\nURL expires at: %s", expiresAt)
+		}
+		result = &mcp.CallToolResult{
+			Content: []mcp.Content{
+				&mcp.TextContent{
+					Text: contentText,
+				},
+			},
+		}
+	} else {
+		result = &mcp.CallToolResult{
+			Content: append([]mcp.Content{&mcp.TextContent{Text: summary}}, s.mediaContent(trimmed, stored)...),
+		}
+	}
+
+	return result, VideoTrimOutput{
+		SourceVideo:     input.VideoPath,
+		VideoFile:       stored.ObjectKey,
+		Start:           video.FormatTimestamp(start),
+		End:             video.FormatTimestamp(end),
+		StartSeconds:    start.Seconds(),
+		EndSeconds:      end.Seconds(),
+		DurationSeconds: (end - start).Seconds(),
+		Method:          method,
+		SizeBytes:       len(trimmed),
+		SavedFiles:      []string{stored.ObjectKey},
+		DataURIs:        s.addDataURI(nil, stored, trimmed),
+		Thumbnails:      addThumbnail(nil, stored),
+		DownloadURLs:    downloadURLs,
+		ExpiresAt:       expiresAt,
+		Metadata:        metadata,
+		Warnings:        collected.List(),
+		GeneratedAt:     timestamp,
+	}, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"gemini-mcp/internal/middleware"
+	"gemini-mcp/internal/toolerr"
+	"gemini-mcp/internal/webhook"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// webhookFields are the parts of a generation's input and output that are
+// reported in webhook events. They are read through the JSON encoding so that
+// any tool with the standard fields can be wrapped.
+type webhookFields struct {
+	WebhookURL   string   `json:"webhook_url"`
+	Status       string   `json:"status"`
+	OperationID  string   `json:"operation_id"`
+	SavedFiles   []string `json:"saved_files"`
+	DownloadURLs []string `json:"download_urls"`
+	ExpiresAt    string   `json:"expires_at"`
+}
+
+func readWebhookFields(v any) webhookFields {
+	var fields webhookFields
+	if data, err := json.Marshal(v); err == nil {
+		json.Unmarshal(data, &fields)
+	}
+	return fields
+}
+
+// withWebhook wraps a generation handler so that a webhook event is sent when
+// it completes or fails, to the request's webhook_url or the server default
+func withWebhook[In, Out any](s *Server, tool string, next mcp.ToolHandlerFor[In, Out]) mcp.ToolHandlerFor[In, Out] {
+	return func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, Out, error) {
+		override := readWebhookFields(input).WebhookURL
+		if override != "" {
+			if err := s.webhooks.ValidateURL(override); err != nil {
+				var zero Out
+				return nil, zero, toolerr.Errorf(toolerr.InvalidInput, "webhook_url: %w", err)
+			}
+		}
+
+		result, output, err := next(ctx, req, input)
+
+		fields := readWebhookFields(output)
+		event := webhook.Event{
+			Event:        webhook.EventCompleted,
+			Tool:         tool,
+			Status:       fields.Status,
+			OperationID:  fields.OperationID,
+			SavedFiles:   fields.SavedFiles,
+			DownloadURLs: fields.DownloadURLs,
+			ExpiresAt:    fields.ExpiresAt,
+			Tenant:       middleware.GetTenant(ctx),
+		}
+		switch {
+		case err != nil:
+			event.Event = webhook.EventFailed
+			event.Status = "failed"
+			event.Error = err.Error()
+			event.ErrorCode = string(toolerr.Classify(err).Code)
+		case result != nil && result.IsError:
+			// Generations blocked by safety filters are reported as tool errors
+			event.Event = webhook.EventFailed
+			if event.Status == "" {
+				event.Status = "blocked"
+			}
+			event.ErrorCode = string(toolerr.ModelBlocked)
+			if len(result.Content) > 0 {
+				if text, ok := result.Content[0].(*mcp.TextContent); ok {
+					event.Error = text.Text
+				}
+			}
+		case event.Status == "generating":
+			// Async video jobs send their event when they finish
+			return result, output, err
+		case event.Status == "":
+			event.Status = "completed"
+		case event.Status != "completed":
+			// Video operations that failed or timed out while polling
+			event.Event = webhook.EventFailed
+		}
+		s.webhooks.Notify(override, event)
+
+		return result, output, err
+	}
+}